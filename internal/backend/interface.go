@@ -47,6 +47,9 @@ type CompletionRequest struct {
 	MaxTokens     int
 	Temperature   float64
 	StopSequences []string
+	// JSONMode asks the backend to constrain output to a JSON object where
+	// it supports it; prompts should still ask for JSON
+	JSONMode bool
 }
 
 // CompletionResponse from inference
@@ -496,6 +496,10 @@ func (s *Server) Complete(ctx context.Context, prompt string, req *backend.Compl
 	if req.Temperature == 0 {
 		reqBody["temperature"] = 0.7
 	}
+	if req.JSONMode {
+		// llama-server turns a JSON schema into a sampling grammar
+		reqBody["json_schema"] = map[string]interface{}{"type": "object"}
+	}
 
 	jsonBody, err := json.Marshal(reqBody)
 	if err != nil {
@@ -656,6 +660,10 @@ func (b *Backend) runServerInference(ctx context.Context, prompt string, req *ba
 	if req.Temperature == 0 {
 		reqBody["temperature"] = 0.7
 	}
+	if req.JSONMode {
+		// llama-server turns a JSON schema into a sampling grammar
+		reqBody["json_schema"] = map[string]interface{}{"type": "object"}
+	}
 
 	jsonBody, err := json.Marshal(reqBody)
 	if err != nil {
@@ -111,6 +111,7 @@ type generateRequest struct {
 	Prompt  string         `json:"prompt"`
 	System  string         `json:"system,omitempty"`
 	Stream  bool           `json:"stream"`
+	Format  string         `json:"format,omitempty"`
 	Options map[string]any `json:"options,omitempty"`
 }
 
@@ -146,6 +147,9 @@ func (b *Backend) Complete(ctx context.Context, req *backend.CompletionRequest)
 	if req.MaxTokens > 0 {
 		ollamaReq.Options["num_predict"] = req.MaxTokens
 	}
+	if req.JSONMode {
+		ollamaReq.Format = "json"
+	}
 
 	body, err := json.Marshal(ollamaReq)
 	if err != nil {
@@ -201,6 +205,9 @@ func (b *Backend) Stream(ctx context.Context, req *backend.CompletionRequest) (<
 	if req.MaxTokens > 0 {
 		ollamaReq.Options["num_predict"] = req.MaxTokens
 	}
+	if req.JSONMode {
+		ollamaReq.Format = "json"
+	}
 
 	body, err := json.Marshal(ollamaReq)
 	if err != nil {
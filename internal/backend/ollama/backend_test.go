@@ -1,9 +1,14 @@
 package ollama
 
 import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/scmd/scmd/internal/backend"
 )
@@ -54,3 +59,18 @@ func TestBackend_SupportsToolCalling(t *testing.T) {
 	b := New(nil)
 	assert.False(t, b.SupportsToolCalling())
 }
+
+func TestBackend_Complete_JSONMode(t *testing.T) {
+	var got generateRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+		_ = json.NewEncoder(w).Encode(generateResponse{Response: `{"ok": true}`, Done: true})
+	}))
+	defer server.Close()
+
+	b := New(&Config{BaseURL: server.URL, Model: "m"})
+	resp, err := b.Complete(context.Background(), &backend.CompletionRequest{Prompt: "p", JSONMode: true})
+	require.NoError(t, err)
+	assert.Equal(t, `{"ok": true}`, resp.Content)
+	assert.Equal(t, "json", got.Format)
+}
@@ -153,6 +153,13 @@ type chatRequest struct {
 	Temperature float64       `json:"temperature,omitempty"`
 	Stream      bool          `json:"stream"`
 	Stop        []string      `json:"stop,omitempty"`
+	// ResponseFormat is {"type": "json_object"} for JSON mode
+	ResponseFormat *responseFormat `json:"response_format,omitempty"`
+}
+
+// responseFormat selects the output format of a chat completion
+type responseFormat struct {
+	Type string `json:"type"`
 }
 
 // chatResponse is the OpenAI chat completion response
@@ -217,6 +224,9 @@ func (b *Backend) Complete(ctx context.Context, req *backend.CompletionRequest)
 	if chatReq.MaxTokens == 0 {
 		chatReq.MaxTokens = 2048
 	}
+	if req.JSONMode {
+		chatReq.ResponseFormat = &responseFormat{Type: "json_object"}
+	}
 
 	body, err := json.Marshal(chatReq)
 	if err != nil {
@@ -293,6 +303,9 @@ func (b *Backend) Stream(ctx context.Context, req *backend.CompletionRequest) (<
 	if chatReq.MaxTokens == 0 {
 		chatReq.MaxTokens = 2048
 	}
+	if req.JSONMode {
+		chatReq.ResponseFormat = &responseFormat{Type: "json_object"}
+	}
 
 	body, err := json.Marshal(chatReq)
 	if err != nil {
@@ -0,0 +1,440 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+
+	"github.com/scmd/scmd/internal/command"
+	"github.com/scmd/scmd/internal/command/builtin"
+	"github.com/scmd/scmd/internal/repos"
+)
+
+// commandCmd is the parent command for authoring command specs
+var commandCmd = &cobra.Command{
+	Use:   "command",
+	Short: "Tools for writing command specs",
+	Long: `Tools for writing command specs and repositories.
+
+Command specs are the YAML files installed with 'scmd repo install'.`,
+}
+
+// commandLintCmd checks command specs and manifests
+var commandLintCmd = &cobra.Command{
+	Use:   "lint [path...]",
+	Short: "Check command specs and repository manifests",
+	Long: `Check command specs and repository manifests without running them.
+
+A path may be a command file, a repository's scmd-repo.yaml, or a directory.
+Directories holding a scmd-repo.yaml are linted as repositories, with every
+command file the manifest lists; other directories have their .yaml files
+linted. Without a path, the current directory is linted.
+
+Checks cover the spec schema, names and aliases, both prompt templates and
+the values they use, inputs, dependency constraints and cycles, compose
+steps, hook conditions, and shell hooks that look destructive. Compose
+steps and command hooks must name a built-in or installed command, or one
+from the same repository.
+
+Exit status is 0 when nothing is wrong, 1 if there are errors (or, with
+--strict, warnings) and 2 if a path can't be read. Use --format json for
+machine-readable output.`,
+	Example: `  scmd command lint my-command.yaml
+  scmd command lint ./my-repo
+  scmd command lint --strict --format json .`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		strict, _ := cmd.Flags().GetBool("strict")
+		allowUnknown, _ := cmd.Flags().GetBool("allow-unknown")
+
+		if len(args) == 0 {
+			args = []string{"."}
+		}
+
+		linter := &repos.Linter{}
+		if !allowUnknown {
+			linter.Known = knownCommands(args)
+		}
+
+		report := lintReport{Issues: []repos.LintIssue{}}
+		unreadable := false
+		for _, path := range args {
+			files, issues, err := lintPath(linter, path)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				unreadable = true
+				continue
+			}
+			report.Files += files
+			report.Issues = append(report.Issues, issues...)
+		}
+		for _, issue := range report.Issues {
+			if issue.Severity == repos.LintError {
+				report.Errors++
+			} else {
+				report.Warnings++
+			}
+		}
+
+		if formatFlag == "json" {
+			out, err := json.MarshalIndent(report, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(out))
+		} else {
+			for _, issue := range report.Issues {
+				fmt.Println(issue)
+			}
+			fmt.Printf("%d file(s) checked: %d error(s), %d warning(s)\n", report.Files, report.Errors, report.Warnings)
+		}
+
+		code := 0
+		switch {
+		case unreadable:
+			code = 2
+		case report.Errors > 0, strict && report.Warnings > 0:
+			code = 1
+		}
+		if code != 0 {
+			cmd.SilenceErrors = true
+			cmd.SilenceUsage = true
+			return &ExitError{Code: code}
+		}
+		return nil
+	},
+}
+
+// lintReport is the JSON output of scmd command lint
+type lintReport struct {
+	Files    int               `json:"files"`
+	Errors   int               `json:"errors"`
+	Warnings int               `json:"warnings"`
+	Issues   []repos.LintIssue `json:"issues"`
+}
+
+// lintPath lints a command file, manifest or directory, returning how many
+// files were checked
+func lintPath(linter *repos.Linter, path string) (int, []repos.LintIssue, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if !info.IsDir() {
+		if filepath.Base(path) == repos.ManifestFile {
+			return lintManifest(linter, filepath.Dir(path))
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return 0, nil, err
+		}
+		return 1, linter.LintSpec(path, data), nil
+	}
+
+	if _, err := os.Stat(filepath.Join(path, repos.ManifestFile)); err == nil {
+		return lintManifest(linter, path)
+	}
+
+	files, err := specFiles(path)
+	if err != nil {
+		return 0, nil, err
+	}
+	var issues []repos.LintIssue
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return 0, nil, err
+		}
+		issues = append(issues, linter.LintSpec(file, data)...)
+	}
+	return len(files), issues, nil
+}
+
+// lintManifest lints a repository, counting its manifest and the command
+// files it lists
+func lintManifest(linter *repos.Linter, dir string) (int, []repos.LintIssue, error) {
+	issues := linter.LintManifest(dir)
+	files := map[string]bool{filepath.Join(dir, repos.ManifestFile): true}
+	if manifest, err := readManifest(dir); err == nil {
+		for _, c := range manifest.Commands {
+			files[c.File] = true
+			for _, v := range c.Versions {
+				files[v.File] = true
+			}
+		}
+	}
+	delete(files, "")
+	return len(files), issues, nil
+}
+
+// specFiles finds the YAML files under dir, skipping hidden directories
+func specFiles(dir string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != dir && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if ext := filepath.Ext(path); ext == ".yaml" || ext == ".yml" {
+			files = append(files, path)
+		}
+		return nil
+	})
+	return files, err
+}
+
+// knownCommands returns the commands compose steps and hooks may use: the
+// built-in and installed ones, and those in the repositories being linted
+func knownCommands(paths []string) func(name string) bool {
+	registry := installedRegistry()
+
+	local := make(map[string]bool)
+	for _, path := range paths {
+		dir := path
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			dir = filepath.Dir(path)
+		}
+		if manifest, err := readManifest(dir); err == nil {
+			for _, c := range manifest.Commands {
+				local[c.Name] = true
+				for _, a := range c.Aliases {
+					local[a] = true
+				}
+			}
+		}
+	}
+
+	return func(name string) bool {
+		if local[name] {
+			return true
+		}
+		_, ok := registry.Get(name)
+		return ok
+	}
+}
+
+// installedRegistry returns a registry of the built-in, installed and
+// project commands
+func installedRegistry() *command.Registry {
+	registry := command.NewRegistry()
+	_ = builtin.RegisterAll(registry)
+	mgr := repos.NewManager(getDataDir())
+	_ = mgr.Load()
+	loader := repos.NewLoader(mgr, filepath.Join(getDataDir(), "commands"))
+	if p := currentProject(); p != nil {
+		loader.SetProject(p)
+	}
+	_ = loader.RegisterAll(registry)
+	return registry
+}
+
+// readManifest reads a repository's manifest from a directory
+func readManifest(dir string) (*repos.Manifest, error) {
+	data, err := os.ReadFile(filepath.Join(dir, repos.ManifestFile))
+	if err != nil {
+		return nil, err
+	}
+	var manifest repos.Manifest
+	if err := yaml.Unmarshal(data, &manifest); err != nil {
+		return nil, err
+	}
+	return &manifest, nil
+}
+
+// commandTestCmd runs the tests in command specs
+var commandTestCmd = &cobra.Command{
+	Use:   "test [path...]",
+	Short: "Run the tests in command specs",
+	Long: `Run the tests in the tests section of command specs.
+
+Each test runs the command against a mock backend that answers with the
+test's response, or a recorded response from response_file, and checks the
+output and the prompts sent. Hooks are skipped unless a test sets hooks:
+true. Paths may be command files or directories; without one, the current
+directory is searched.
+
+  tests:
+    - name: large files in home
+      args: ["500M", "~"]
+      response: "1.2G  ~/big.iso"
+      expect:
+        prompt: ["-size +500M"]
+        contains: ["big.iso"]
+        regex: ["\d+(\.\d+)?G"]
+        max_tokens: 200
+
+Expectations are contains, not_contains, regex, json (a path such as
+items.0.name and the value it must equal), max_tokens, prompt, and error for
+tests where the command should fail.
+
+Exit status is 0 when every test passes, 1 if any fails and 2 if a path
+can't be read.`,
+	Example: `  scmd command test my-command.yaml
+  scmd command test commands/
+  scmd command test --run 'defaults' --format json .`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := context.Background()
+		runPattern, _ := cmd.Flags().GetString("run")
+
+		var filter *regexp.Regexp
+		if runPattern != "" {
+			var err error
+			if filter, err = regexp.Compile(runPattern); err != nil {
+				return fmt.Errorf("--run: %w", err)
+			}
+		}
+		if len(args) == 0 {
+			args = []string{"."}
+		}
+
+		runner := &repos.TestRunner{Registry: installedRegistry(), DataDir: getDataDir()}
+		report := testReport{Results: []repos.TestResult{}, Untested: []string{}}
+		unreadable := false
+		for _, path := range args {
+			files := []string{path}
+			if info, err := os.Stat(path); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				unreadable = true
+				continue
+			} else if info.IsDir() {
+				if files, err = specFiles(path); err != nil {
+					fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+					unreadable = true
+					continue
+				}
+			}
+
+			for _, file := range files {
+				spec, err := readSpecFile(file)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+					unreadable = true
+					continue
+				}
+				if spec == nil {
+					continue // not a command spec
+				}
+				if filter != nil {
+					var tests []repos.CommandTest
+					for _, t := range spec.Tests {
+						if filter.MatchString(t.Name) {
+							tests = append(tests, t)
+						}
+					}
+					spec.Tests = tests
+				}
+				if len(spec.Tests) == 0 {
+					report.Untested = append(report.Untested, spec.Name)
+					continue
+				}
+
+				for _, res := range runner.Run(ctx, spec, filepath.Dir(file)) {
+					if res.Passed {
+						report.Passed++
+					} else {
+						report.Failed++
+					}
+					report.Results = append(report.Results, res)
+				}
+			}
+		}
+
+		if formatFlag == "json" {
+			out, err := json.MarshalIndent(report, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(out))
+		} else {
+			for _, res := range report.Results {
+				status := "PASS"
+				if !res.Passed {
+					status = "FAIL"
+				}
+				fmt.Printf("%s %s/%s (%s)\n", status, res.Command, res.Name, res.Duration.Round(time.Millisecond))
+				for _, f := range res.Failures {
+					fmt.Printf("    %s\n", f)
+				}
+			}
+			fmt.Printf("%d passed, %d failed", report.Passed, report.Failed)
+			if n := len(report.Untested); n > 0 {
+				fmt.Printf(", %d command(s) without tests", n)
+			}
+			fmt.Println()
+		}
+
+		code := 0
+		switch {
+		case unreadable:
+			code = 2
+		case report.Failed > 0:
+			code = 1
+		}
+		if code != 0 {
+			cmd.SilenceErrors = true
+			cmd.SilenceUsage = true
+			return &ExitError{Code: code}
+		}
+		return nil
+	},
+}
+
+// testReport is the JSON output of scmd command test
+type testReport struct {
+	Passed   int                `json:"passed"`
+	Failed   int                `json:"failed"`
+	Untested []string           `json:"untested"`
+	Results  []repos.TestResult `json:"results"`
+}
+
+// readSpecFile reads a command spec, returning nil for YAML files that are
+// not command specs, such as manifests
+func readSpecFile(path string) (*repos.CommandSpec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Specs have a name and a prompt or compose block
+	var fields map[string]interface{}
+	if err := yaml.Unmarshal(data, &fields); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	_, hasPrompt := fields["prompt"]
+	_, hasCompose := fields["compose"]
+	if fields["name"] == nil || !hasPrompt && !hasCompose {
+		return nil, nil
+	}
+
+	var spec repos.CommandSpec
+	if err := yaml.Unmarshal(data, &spec); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &spec, nil
+}
+
+func init() {
+	commandCmd.AddCommand(commandNewCmd)
+	commandCmd.AddCommand(commandDevCmd)
+	commandCmd.AddCommand(commandLintCmd)
+	commandCmd.AddCommand(commandTestCmd)
+
+	commandTestCmd.Flags().String("run", "", "only run tests whose name matches this regular expression")
+
+	commandLintCmd.Flags().Bool("strict", false, "exit with status 1 on warnings too")
+	commandLintCmd.Flags().Bool("allow-unknown", false, "don't check that compose steps and hooks name known commands")
+}
@@ -0,0 +1,261 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+
+	"github.com/scmd/scmd/internal/command"
+	"github.com/scmd/scmd/internal/repos"
+	"github.com/scmd/scmd/internal/slash"
+)
+
+// commandDevCmd runs a command spec straight from its file, again on every
+// save
+var commandDevCmd = &cobra.Command{
+	Use:   "dev <file.yaml> [args...]",
+	Short: "Run a command spec from its file, again on every save",
+	Long: `Run a command spec straight from its file, without installing it, and
+run it again every time the file is saved.
+
+Each run shows the rendered system and user prompts, then the command's
+output. The args after the file are the command's own, as in
+'scmd /name args': positional arguments and --name=value flags and inputs.
+Lint problems are shown when the file is loaded, and a file that doesn't
+parse is reported and waited on.
+
+While it runs, type new arguments and press Enter to run with them, press
+Enter alone to run again, or type q to quit. Piped input is read once and
+given to every run.
+
+The spec replaces any installed command of the same name for the session,
+so compose steps and hooks that use it get this version.`,
+	Args: cobra.MinimumNArgs(1),
+	Example: `  scmd command dev tone.yaml --tone=formal
+  git diff | scmd command dev review-diff.yaml
+  scmd command dev --no-run tone.yaml --tone=casual
+  scmd command dev --once tone.yaml --tone=formal`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := context.Background()
+		noRun, _ := cmd.Flags().GetBool("no-run")
+		once, _ := cmd.Flags().GetBool("once")
+		interval, _ := cmd.Flags().GetDuration("interval")
+
+		dev := &devSession{path: args[0], args: args[1:], noRun: noRun}
+
+		interactive := term.IsTerminal(int(os.Stdin.Fd()))
+		if !interactive && HasInput() {
+			stdin, err := NewStdinReader().Read(ctx)
+			if err != nil {
+				return fmt.Errorf("read stdin: %w", err)
+			}
+			dev.stdin = stdin
+		}
+
+		if !noRun {
+			be, err := getActiveBackend(ctx)
+			if err != nil {
+				return err
+			}
+			dev.execCtx = &command.ExecContext{
+				Config:   cfg,
+				Backend:  be,
+				UI:       NewConsoleUI(DetectIOMode()),
+				Registry: cmdRegistry,
+				DataDir:  getDataDir(),
+			}
+		}
+
+		ok := dev.reload() && dev.run(ctx)
+		if once {
+			if !ok {
+				cmd.SilenceErrors = true
+				cmd.SilenceUsage = true
+				return &ExitError{Code: 1}
+			}
+			return nil
+		}
+
+		changes := watchFile(dev.path, interval)
+		var lines <-chan string
+		if interactive {
+			lines = readLines(os.Stdin)
+			fmt.Fprintln(os.Stderr, "Watching for changes. Enter new arguments to run with them, Enter to run again, q to quit.")
+		} else {
+			fmt.Fprintln(os.Stderr, "Watching for changes. Press Ctrl+C to quit.")
+		}
+
+		for {
+			select {
+			case <-changes:
+				if dev.reload() {
+					dev.run(ctx)
+				}
+			case line, open := <-lines:
+				if !open {
+					return nil
+				}
+				line = strings.TrimSpace(line)
+				if line == "q" || line == "quit" {
+					return nil
+				}
+				if line != "" {
+					dev.args = strings.Fields(line)
+				}
+				dev.run(ctx)
+			}
+		}
+	},
+}
+
+// devSession is the state of scmd command dev
+type devSession struct {
+	path    string
+	args    []string // the last invocation's arguments
+	stdin   string
+	noRun   bool
+	execCtx *command.ExecContext
+
+	cmd  *repos.PluginCommand
+	runs int
+}
+
+// reload reads the spec and registers it, reporting problems. It returns
+// false if the file can't be used.
+func (d *devSession) reload() bool {
+	data, err := os.ReadFile(d.path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return false
+	}
+
+	issues := (&repos.Linter{Known: knownCommands([]string{d.path})}).LintSpec(d.path, data)
+	for _, issue := range issues {
+		fmt.Fprintln(os.Stderr, issue)
+	}
+
+	spec, err := readSpecFile(d.path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return false
+	}
+	if spec == nil {
+		fmt.Fprintf(os.Stderr, "Error: %s is not a command spec: it needs a name and a prompt or compose block\n", d.path)
+		return false
+	}
+
+	d.cmd = repos.NewPluginCommand(spec)
+	cmdRegistry.Replace(d.cmd)
+	return true
+}
+
+// run previews the prompt for the last invocation and runs the command,
+// returning whether it succeeded
+func (d *devSession) run(ctx context.Context) bool {
+	if d.cmd == nil {
+		return false
+	}
+	d.runs++
+
+	cmdArgs := slash.ParseArgs(d.args, d.cmd.FlagNames())
+	if d.stdin != "" {
+		cmdArgs.Options["stdin"] = d.stdin
+	}
+
+	invocation := strings.TrimSpace("/" + d.cmd.Name() + " " + strings.Join(d.args, " "))
+	fmt.Printf("\n── run %d: %s (%s) ──\n", d.runs, invocation, time.Now().Format("15:04:05"))
+
+	system, prompt, err := d.cmd.RenderPrompt(cmdArgs)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return false
+	}
+	if system != "" {
+		fmt.Printf("\nSystem prompt:\n%s\n", indent(system))
+	}
+	fmt.Printf("\nPrompt:\n%s\n", indent(prompt))
+
+	if d.noRun {
+		return true
+	}
+
+	result, err := d.cmd.Execute(ctx, cmdArgs, d.execCtx)
+	if err != nil {
+		fmt.Printf("\nError: %v\n", err)
+		return false
+	}
+	if !result.Success {
+		fmt.Printf("\nError: %s\n", result.Error)
+		return false
+	}
+	fmt.Printf("\nOutput:\n%s\n", strings.TrimRight(result.Output, "\n"))
+	return true
+}
+
+// indent indents text for the previews
+func indent(s string) string {
+	lines := strings.Split(strings.TrimRight(s, "\n"), "\n")
+	for i, l := range lines {
+		if l != "" {
+			lines[i] = "  " + l
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// watchFile polls a file, sending when its size or modification time
+// changes
+func watchFile(path string, interval time.Duration) <-chan struct{} {
+	changes := make(chan struct{}, 1)
+	stamp := func() (time.Time, int64) {
+		info, err := os.Stat(path)
+		if err != nil {
+			return time.Time{}, -1
+		}
+		return info.ModTime(), info.Size()
+	}
+
+	go func() {
+		lastMod, lastSize := stamp()
+		for range time.Tick(interval) {
+			mod, size := stamp()
+			if mod.Equal(lastMod) && size == lastSize {
+				continue
+			}
+			lastMod, lastSize = mod, size
+			select {
+			case changes <- struct{}{}:
+			default: // a reload is already pending
+			}
+		}
+	}()
+	return changes
+}
+
+// readLines sends the lines read from f, closing the channel at the end
+func readLines(f *os.File) <-chan string {
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+	return lines
+}
+
+func init() {
+	commandDevCmd.Flags().Bool("no-run", false, "only show the rendered prompts")
+	commandDevCmd.Flags().Bool("once", false, "run once and exit instead of watching")
+	commandDevCmd.Flags().Duration("interval", 500*time.Millisecond, "how often to check the file for changes")
+
+	// Flags after the file belong to the command being developed
+	commandDevCmd.Flags().SetInterspersed(false)
+}
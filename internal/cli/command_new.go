@@ -0,0 +1,231 @@
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/scmd/scmd/internal/repos"
+)
+
+// commandNewCmd scaffolds a command spec
+var commandNewCmd = &cobra.Command{
+	Use:   "new <name>",
+	Short: "Create a command spec interactively",
+	Long: `Create a command spec by answering a few questions: what it does,
+whether it reads piped input, its inputs, the prompt and model settings.
+
+Without a prompt template, one is written that uses every input. The spec
+gets a first test, so 'scmd command test' passes straight away, and is
+written to <dir>/<name>.yaml. Try it with 'scmd command dev'.`,
+	Args: cobra.ExactArgs(1),
+	Example: `  scmd command new tone
+  scmd command new deploy-notes --dir .scmd/commands
+  scmd command new summarize-pr --yes --description "Summarize a pull request"`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir, _ := cmd.Flags().GetString("dir")
+		force, _ := cmd.Flags().GetBool("force")
+		yes, _ := cmd.Flags().GetBool("yes")
+		description, _ := cmd.Flags().GetString("description")
+
+		s := &repos.Scaffold{Name: args[0], Description: description, Stdin: true}
+		if err := s.Validate(); err != nil {
+			return err
+		}
+
+		path := filepath.Join(dir, s.Name+".yaml")
+		if _, err := os.Stat(path); err == nil && !force {
+			return fmt.Errorf("%s already exists (use --force to overwrite)", path)
+		}
+
+		if !yes {
+			q := &questioner{in: bufio.NewReader(os.Stdin), out: os.Stderr}
+			if err := q.scaffold(s); err != nil {
+				return err
+			}
+		}
+		if err := s.Validate(); err != nil {
+			return err
+		}
+
+		data, err := s.YAML()
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			return err
+		}
+
+		fmt.Printf("Created %s\n", path)
+		for _, issue := range (&repos.Linter{}).LintSpec(path, data) {
+			fmt.Println(issue)
+		}
+		fmt.Println()
+		fmt.Printf("  Try it:   scmd command dev %s\n", path)
+		fmt.Printf("  Test it:  scmd command test %s\n", path)
+		return nil
+	},
+}
+
+// questioner asks the questions of scmd command new
+type questioner struct {
+	in  *bufio.Reader
+	out io.Writer
+	eof bool
+}
+
+// ask asks for a line, returning def for an empty answer
+func (q *questioner) ask(label, def string) string {
+	if def != "" {
+		fmt.Fprintf(q.out, "%s [%s]: ", label, def)
+	} else {
+		fmt.Fprintf(q.out, "%s: ", label)
+	}
+	if q.eof {
+		fmt.Fprintln(q.out)
+		return def
+	}
+
+	line, err := q.in.ReadString('\n')
+	if err != nil {
+		q.eof = true
+		if line == "" {
+			fmt.Fprintln(q.out)
+		}
+	}
+	if line = strings.TrimSpace(line); line == "" {
+		return def
+	}
+	return line
+}
+
+// confirm asks a yes or no question
+func (q *questioner) confirm(label string, def bool) bool {
+	hint := "y/N"
+	if def {
+		hint = "Y/n"
+	}
+	switch strings.ToLower(q.ask(label+" ("+hint+")", "")) {
+	case "y", "yes":
+		return true
+	case "n", "no":
+		return false
+	}
+	return def
+}
+
+// askNumber asks for a number, with an empty answer for none
+func (q *questioner) askNumber(label string, parse func(string) error) {
+	for {
+		answer := q.ask(label, "")
+		if answer == "" {
+			return
+		}
+		if err := parse(answer); err == nil {
+			return
+		}
+		fmt.Fprintf(q.out, "  %q is not a number\n", answer)
+		if q.eof {
+			return
+		}
+	}
+}
+
+// scaffold fills in a scaffold from the answers
+func (q *questioner) scaffold(s *repos.Scaffold) error {
+	s.Description = q.ask("Description", s.Description)
+	s.Category = q.ask("Category (optional)", s.Category)
+	s.Stdin = q.confirm("Does it work on piped input?", s.Stdin)
+
+	fmt.Fprintln(q.out, "Inputs, passed as --name=value; an empty name finishes.")
+	types := []string{repos.InputTypeString, repos.InputTypeFile, repos.InputTypeChoice, repos.InputTypeMultiline}
+	for {
+		name := q.ask("  Input name", "")
+		if name == "" {
+			break
+		}
+		if err := repos.ValidateInputName(name); err != nil {
+			fmt.Fprintf(q.out, "  %v\n", err)
+			if q.eof {
+				return err
+			}
+			continue
+		}
+
+		in := repos.InputSpec{Name: name}
+		for {
+			in.Type = q.ask("  Type ("+strings.Join(types, ", ")+")", repos.InputTypeString)
+			if slices.Contains(types, in.Type) {
+				break
+			}
+			fmt.Fprintf(q.out, "  unknown type %q\n", in.Type)
+			if q.eof {
+				return fmt.Errorf("input %s: unknown type %q", name, in.Type)
+			}
+		}
+		if in.Type == repos.InputTypeString {
+			in.Type = "" // the default
+		}
+		if in.Type == repos.InputTypeChoice {
+			for _, c := range strings.Split(q.ask("  Choices, comma-separated", ""), ",") {
+				if c = strings.TrimSpace(c); c != "" {
+					in.Choices = append(in.Choices, c)
+				}
+			}
+		}
+		in.Description = q.ask("  Description", "")
+		in.Required = q.confirm("  Required?", false)
+		if !in.Required {
+			in.Default = q.ask("  Default (optional)", "")
+		}
+		s.Inputs = append(s.Inputs, in)
+	}
+
+	s.Prompt.System = q.ask("System prompt (optional)", "")
+	fmt.Fprintln(q.out, "Prompt template, ending with a line holding only '.'; leave it empty to have one written:")
+	var lines []string
+	for !q.eof {
+		line, err := q.in.ReadString('\n')
+		if err != nil {
+			q.eof = true
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "." {
+			break
+		}
+		if line != "" || err == nil {
+			lines = append(lines, line)
+		}
+	}
+	s.Prompt.Template = strings.TrimSpace(strings.Join(lines, "\n"))
+	if s.Prompt.Template != "" {
+		s.Prompt.Template += "\n"
+	}
+
+	q.askNumber("Temperature (optional, 0-2)", func(v string) (err error) {
+		s.Model.Temperature, err = strconv.ParseFloat(v, 64)
+		return err
+	})
+	q.askNumber("Max tokens (optional)", func(v string) (err error) {
+		s.Model.MaxTokens, err = strconv.Atoi(v)
+		return err
+	})
+	return nil
+}
+
+func init() {
+	commandNewCmd.Flags().String("dir", ".", "directory to write the command file to")
+	commandNewCmd.Flags().String("description", "", "what the command does")
+	commandNewCmd.Flags().BoolP("yes", "y", false, "don't ask; use the flags and defaults")
+	commandNewCmd.Flags().Bool("force", false, "overwrite an existing file")
+}
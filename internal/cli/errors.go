@@ -41,6 +41,17 @@ func (e *CLIError) Error() string {
 	return b.String()
 }
 
+// ExitError carries a non-zero exit code for a command that ran successfully
+// but whose outcome should fail the process, such as a review with errors
+type ExitError struct {
+	Code int
+}
+
+// Error implements the error interface
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.Code)
+}
+
 // NewCommandNotFoundError creates a helpful error for unknown commands
 func NewCommandNotFoundError(cmdName string, availableCommands []string) error {
 	suggestions := []string{}
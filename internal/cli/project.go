@@ -0,0 +1,205 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/scmd/scmd/internal/repos"
+)
+
+// projectCmd is the parent command for project-local commands
+var projectCmd = &cobra.Command{
+	Use:   "project",
+	Short: "Manage project-local commands",
+	Long: `Manage the commands a repository ships in its .scmd directory.
+
+scmd looks for .scmd/commands/*.yaml and .scmd/slash.yaml in the current
+directory and its parents. Project commands take precedence over installed
+commands of the same name, and project slash commands over global ones.
+
+Hooks in project commands run shell commands, so they only run once you
+trust the project. scmd asks the first time, and again whenever the
+project's commands change.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return projectStatusCmd.RunE(cmd, args)
+	},
+}
+
+// projectStatusCmd shows the current project
+var projectStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the current project and its commands",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		p := currentProject()
+		if p == nil {
+			fmt.Println("No project: no .scmd/commands or .scmd/slash.yaml here or in any parent directory.")
+			return nil
+		}
+
+		mgr, err := getRepoManager()
+		if err != nil {
+			return err
+		}
+		trusted, changed, err := mgr.ProjectTrust(p)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Project: %s\n", p.Root)
+		switch {
+		case trusted:
+			fmt.Println("Trust:   trusted")
+		case changed:
+			fmt.Println("Trust:   commands changed since trusted (run 'scmd project trust')")
+		default:
+			fmt.Println("Trust:   not trusted (run 'scmd project trust')")
+		}
+
+		specs, err := mgr.LoadInstalledCommands(p.CommandsDir())
+		if err != nil {
+			return err
+		}
+		if len(specs) == 0 {
+			return nil
+		}
+		sort.Slice(specs, func(i, j int) bool { return specs[i].Name < specs[j].Name })
+
+		fmt.Println()
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "COMMAND\tHOOKS\tDESCRIPTION")
+		for _, s := range specs {
+			hooks := "-"
+			if s.Hooks != nil && len(s.Hooks.Pre)+len(s.Hooks.Post) > 0 {
+				hooks = fmt.Sprintf("%d", len(s.Hooks.Pre)+len(s.Hooks.Post))
+			}
+			fmt.Fprintf(w, "/%s\t%s\t%s\n", s.Name, hooks, s.Description)
+		}
+		return w.Flush()
+	},
+}
+
+// projectTrustCmd trusts the current project
+var projectTrustCmd = &cobra.Command{
+	Use:   "trust",
+	Short: "Allow the current project's commands to run hooks",
+	Long: `Allow the current project's commands to run hooks.
+
+Trust is recorded for the project's commands as they are now; if they
+change, scmd asks again. Review .scmd/commands before trusting a project
+you didn't write.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		p := currentProject()
+		if p == nil {
+			return fmt.Errorf("no project: no .scmd/commands or .scmd/slash.yaml here or in any parent directory")
+		}
+
+		mgr, err := getRepoManager()
+		if err != nil {
+			return err
+		}
+		if err := mgr.TrustProject(p); err != nil {
+			return err
+		}
+		if err := mgr.SaveProjects(); err != nil {
+			return fmt.Errorf("save trusted projects: %w", err)
+		}
+
+		fmt.Printf("Trusted %s\n", p.Root)
+		return nil
+	},
+}
+
+// projectUntrustCmd forgets a trusted project
+var projectUntrustCmd = &cobra.Command{
+	Use:   "untrust [path]",
+	Short: "Stop trusting a project",
+	Args:  cobra.MaximumNArgs(1),
+	Example: `  scmd project untrust
+  scmd project untrust ~/src/website`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var root string
+		if len(args) > 0 {
+			abs, err := filepath.Abs(args[0])
+			if err != nil {
+				return err
+			}
+			root = abs
+		} else if p := currentProject(); p != nil {
+			root = p.Root
+		} else {
+			return fmt.Errorf("no project: give the path of one to untrust")
+		}
+
+		mgr, err := getRepoManager()
+		if err != nil {
+			return err
+		}
+		if err := mgr.UntrustProject(root); err != nil {
+			return err
+		}
+		if err := mgr.SaveProjects(); err != nil {
+			return fmt.Errorf("save trusted projects: %w", err)
+		}
+
+		fmt.Printf("No longer trusting %s\n", root)
+		return nil
+	},
+}
+
+// projectListCmd lists trusted projects
+var projectListCmd = &cobra.Command{
+	Use:     "list",
+	Short:   "List trusted projects",
+	Aliases: []string{"ls"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		mgr, err := getRepoManager()
+		if err != nil {
+			return err
+		}
+
+		projects := mgr.TrustedProjects()
+		if len(projects) == 0 {
+			fmt.Println("No trusted projects.")
+			return nil
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "PROJECT\tTRUSTED\tHASH")
+		for _, p := range projects {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", p.Root, p.TrustedAt.Format("2006-01-02"), p.Hash)
+		}
+		return w.Flush()
+	},
+}
+
+var (
+	project       *repos.Project
+	projectLooked bool
+)
+
+// currentProject returns the project around the working directory, or nil
+func currentProject() *repos.Project {
+	if projectLooked {
+		return project
+	}
+	projectLooked = true
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil
+	}
+	project, _ = repos.FindProject(cwd, getDataDir())
+	return project
+}
+
+func init() {
+	projectCmd.AddCommand(projectStatusCmd)
+	projectCmd.AddCommand(projectTrustCmd)
+	projectCmd.AddCommand(projectUntrustCmd)
+	projectCmd.AddCommand(projectListCmd)
+}
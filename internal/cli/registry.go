@@ -156,11 +156,16 @@ var updateCmd = &cobra.Command{
 	Long: `Check installed commands for available updates.
 
 Use --check to only check without installing.
-Use --all to update all commands at once.`,
+Use --all to update all commands at once. Pinned commands are not updated;
+the previous version of each updated command is kept for
+'scmd repo rollback'.
+If an update's permissions differ, you are asked to allow them; pass --yes
+to accept them without asking.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		ctx := context.Background()
 		checkOnly, _ := cmd.Flags().GetBool("check")
 		updateAll, _ := cmd.Flags().GetBool("all")
+		yes, _ := cmd.Flags().GetBool("yes")
 
 		dataDir := getDataDir()
 		cache := repos.NewCache(dataDir)
@@ -173,12 +178,21 @@ Use --all to update all commands at once.`,
 			return err
 		}
 
-		// Check for updates
+		// Check for updates, syncing each git repository once
+		synced := make(map[string]error)
 		updates, err := cache.CheckUpdates(func(repo, name string) (string, error) {
 			r, ok := mgr.Get(repo)
 			if !ok {
 				return "", fmt.Errorf("repo not found")
 			}
+			syncErr, done := synced[repo]
+			if !done {
+				syncErr = mgr.Sync(ctx, r)
+				synced[repo] = syncErr
+			}
+			if syncErr != nil {
+				return "", syncErr
+			}
 			manifest, err := mgr.FetchManifest(ctx, r)
 			if err != nil {
 				return "", err
@@ -223,116 +237,209 @@ Use --all to update all commands at once.`,
 			return nil
 		}
 
-		// Install updates
-		installDir := filepath.Join(dataDir, "commands")
+		// Install updates the way 'scmd repo upgrade' does: pinned commands
+		// are skipped, dependencies are resolved and the previous versions
+		// are kept
+		var targets []repos.InstalledCmd
 		for _, u := range updates {
-			fmt.Printf("Updating %s/%s to %s...\n", u.Repo, u.Command, u.Latest)
-
-			repo, _ := mgr.Get(u.Repo)
-			manifest, _ := mgr.FetchManifest(ctx, repo)
-
-			var cmdFile string
-			for _, c := range manifest.Commands {
-				if c.Name == u.Command {
-					cmdFile = c.File
-					break
-				}
-			}
-
-			spec, err := mgr.FetchCommand(ctx, repo, cmdFile)
+			c, err := cache.FindInstalled(u.Repo + "/" + u.Command)
 			if err != nil {
-				fmt.Printf("  Error: %v\n", err)
+				fmt.Printf("Skipping %s/%s: %v\n", u.Repo, u.Command, err)
 				continue
 			}
-
-			if err := mgr.InstallCommand(spec, installDir); err != nil {
-				fmt.Printf("  Error: %v\n", err)
-				continue
-			}
-
-			cache.MarkInstalled(u.Repo, u.Command, spec.Version)
-			fmt.Printf("  Updated to %s\n", spec.Version)
+			targets = append(targets, c)
+		}
+		installDir := filepath.Join(dataDir, "commands")
+		upgraded, err := upgradeInstalled(ctx, mgr, cache, installDir, targets, upgradeOptions{all: updateAll, yes: yes})
+		if err != nil {
+			return err
+		}
+		if upgraded == 0 {
+			return nil
 		}
 
 		if err := cache.Save(); err != nil {
 			return fmt.Errorf("save cache: %w", err)
 		}
-
+		fmt.Printf("Updated %d command(s)\n", upgraded)
 		return nil
 	},
 }
 
-// lockCmd manages lockfiles for reproducible installations
-var lockCmd = &cobra.Command{
-	Use:   "lock",
-	Short: "Manage command lockfiles",
-	Long: `Create and use lockfiles for reproducible command installations.
+// newLockCmd builds the lockfile commands. They are available both as
+// 'scmd lock' and 'scmd registry lock'.
+func newLockCmd() *cobra.Command {
+	lockCmd := &cobra.Command{
+		Use:   "lock",
+		Short: "Manage command lockfiles",
+		Long: `Create and use lockfiles for reproducible command installations.
+
+Lockfiles record the exact version, source URL and content hash of installed
+commands, allowing teams to share consistent command configurations.
+Installing from a lockfile fails if a repository serves anything else.`,
+	}
+
+	generateCmd := &cobra.Command{
+		Use:   "generate",
+		Short: "Generate a lockfile from installed commands",
+		RunE:  runLockGenerate,
+	}
+	generateCmd.Flags().StringP("output", "o", "scmd.lock", "output file")
+
+	installCmd := &cobra.Command{
+		Use:   "install [lockfile]",
+		Short: "Install commands from a lockfile",
+		Long: `Install the commands in a lockfile, checking each one against its recorded
+hash. Nothing is installed if any command does not match.
+
+With --frozen, only what the lockfile records is used: entries must name
+their command file and repositories must not have moved.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: runLockInstall,
+	}
+	installCmd.Flags().Bool("frozen", false, "use only what the lockfile records")
+	installCmd.Flags().BoolP("yes", "y", false, "accept the commands' permissions without asking")
+
+	verifyCmd := &cobra.Command{
+		Use:   "verify [lockfile]",
+		Short: "Check installed commands against a lockfile",
+		Long: `Check that every command in the lockfile is installed with the recorded
+content. With --remote, also check that the repositories still serve it.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: runLockVerify,
+	}
+	verifyCmd.Flags().Bool("remote", false, "also check the repositories")
 
-Lockfiles record exact versions of installed commands, allowing
-teams to share consistent command configurations.`,
+	lockCmd.AddCommand(generateCmd)
+	lockCmd.AddCommand(installCmd)
+	lockCmd.AddCommand(verifyCmd)
+	return lockCmd
 }
 
-// lockGenerateCmd generates a lockfile
-var lockGenerateCmd = &cobra.Command{
-	Use:   "generate",
-	Short: "Generate a lockfile from installed commands",
-	RunE: func(cmd *cobra.Command, args []string) error {
-		output, _ := cmd.Flags().GetString("output")
-		if output == "" {
-			output = "scmd.lock"
-		}
+// runLockGenerate generates a lockfile
+func runLockGenerate(cmd *cobra.Command, args []string) error {
+	output, _ := cmd.Flags().GetString("output")
+	if output == "" {
+		output = "scmd.lock"
+	}
 
-		dataDir := getDataDir()
-		cache := repos.NewCache(dataDir)
-		if err := cache.Load(); err != nil {
-			return fmt.Errorf("load cache: %w", err)
-		}
+	dataDir := getDataDir()
+	cache := repos.NewCache(dataDir)
+	if err := cache.Load(); err != nil {
+		return fmt.Errorf("load cache: %w", err)
+	}
 
-		lf := cache.GenerateLockfile()
+	lf := cache.GenerateLockfile()
 
-		if err := repos.SaveLockfile(lf, output); err != nil {
-			return fmt.Errorf("save lockfile: %w", err)
-		}
+	if err := repos.SaveLockfile(lf, output); err != nil {
+		return fmt.Errorf("save lockfile: %w", err)
+	}
 
-		fmt.Printf("Generated lockfile: %s (%d commands)\n", output, len(lf.Commands))
-		return nil
-	},
+	fmt.Printf("Generated lockfile: %s (%d commands)\n", output, len(lf.Commands))
+	for _, c := range lf.Commands {
+		if c.URL == "" || c.File == "" {
+			fmt.Fprintf(os.Stderr, "Warning: %s/%s has no recorded source; reinstall it to lock its source\n", c.Repo, c.Name)
+		}
+	}
+	return nil
 }
 
-// lockInstallCmd installs from a lockfile
-var lockInstallCmd = &cobra.Command{
-	Use:   "install [lockfile]",
-	Short: "Install commands from a lockfile",
-	Args:  cobra.MaximumNArgs(1),
-	RunE: func(cmd *cobra.Command, args []string) error {
-		ctx := context.Background()
-		input := "scmd.lock"
-		if len(args) > 0 {
-			input = args[0]
-		}
+// runLockInstall installs from a lockfile
+func runLockInstall(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+	frozen, _ := cmd.Flags().GetBool("frozen")
+	yes, _ := cmd.Flags().GetBool("yes")
+	input := "scmd.lock"
+	if len(args) > 0 {
+		input = args[0]
+	}
 
-		lf, err := repos.LoadLockfile(input)
-		if err != nil {
-			return fmt.Errorf("load lockfile: %w", err)
-		}
+	lf, err := repos.LoadLockfile(input)
+	if err != nil {
+		return fmt.Errorf("load lockfile: %w", err)
+	}
 
-		fmt.Printf("Installing %d commands from %s...\n", len(lf.Commands), input)
+	fmt.Printf("Installing %d commands from %s...\n", len(lf.Commands), input)
 
-		mgr, err := getRepoManager()
-		if err != nil {
-			return err
-		}
+	mgr, err := getRepoManager()
+	if err != nil {
+		return err
+	}
 
-		dataDir := getDataDir()
-		installDir := filepath.Join(dataDir, "commands")
+	dataDir := getDataDir()
+	installDir := filepath.Join(dataDir, "commands")
+	cache := repos.NewCache(dataDir)
+	if err := cache.Load(); err != nil {
+		return fmt.Errorf("load cache: %w", err)
+	}
+
+	sources, err := mgr.FetchLockfile(ctx, lf, frozen)
+	if err != nil {
+		return fmt.Errorf("install: %w", err)
+	}
+	if !confirmPermissions(lockedPermissions(sources, repos.InstalledFrom(cache, installDir)), yes) {
+		return fmt.Errorf("permissions not allowed; nothing was installed")
+	}
+	if err := mgr.InstallLocked(sources, installDir, cache); err != nil {
+		return fmt.Errorf("install: %w", err)
+	}
+	// Keep repositories the lockfile added
+	if err := mgr.Save(); err != nil {
+		return fmt.Errorf("save repos: %w", err)
+	}
+	if err := cache.Save(); err != nil {
+		return fmt.Errorf("save cache: %w", err)
+	}
 
-		if err := mgr.InstallFromLockfile(ctx, lf, installDir); err != nil {
-			return fmt.Errorf("install: %w", err)
+	fmt.Println("Done.")
+	return nil
+}
+
+// runLockVerify checks installed commands against a lockfile
+func runLockVerify(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+	remote, _ := cmd.Flags().GetBool("remote")
+	input := "scmd.lock"
+	if len(args) > 0 {
+		input = args[0]
+	}
+
+	lf, err := repos.LoadLockfile(input)
+	if err != nil {
+		return fmt.Errorf("load lockfile: %w", err)
+	}
+
+	mgr, err := getRepoManager()
+	if err != nil {
+		return err
+	}
+
+	installDir := filepath.Join(getDataDir(), "commands")
+	checks := mgr.VerifyLockfile(ctx, lf, installDir, remote)
+
+	failed := 0
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "COMMAND\tVERSION\tSTATUS")
+	for _, c := range checks {
+		status := "ok"
+		switch {
+		case c.Installed != nil:
+			status = "installed: " + c.Installed.Error()
+		case c.Remote != nil:
+			status = "remote: " + c.Remote.Error()
 		}
+		if !c.OK() {
+			failed++
+		}
+		fmt.Fprintf(w, "%s/%s\t%s\t%s\n", c.Command.Repo, c.Command.Name, c.Command.Version, status)
+	}
+	w.Flush()
 
-		fmt.Println("Done.")
-		return nil
-	},
+	if failed > 0 {
+		return fmt.Errorf("%d of %d commands do not match %s", failed, len(checks), input)
+	}
+	fmt.Printf("All %d commands match %s\n", len(checks), input)
+	return nil
 }
 
 // cacheCmd manages the local cache
@@ -403,15 +510,12 @@ func init() {
 	registryCmd.AddCommand(registrySearchCmd)
 	registryCmd.AddCommand(registryFeaturedCmd)
 	registryCmd.AddCommand(registryCategoriesCmd)
+	registryCmd.AddCommand(newLockCmd())
 
 	// Update flags
 	updateCmd.Flags().Bool("check", false, "check only, don't install")
 	updateCmd.Flags().Bool("all", false, "update all commands")
-
-	// Lock subcommands
-	lockGenerateCmd.Flags().StringP("output", "o", "scmd.lock", "output file")
-	lockCmd.AddCommand(lockGenerateCmd)
-	lockCmd.AddCommand(lockInstallCmd)
+	updateCmd.Flags().BoolP("yes", "y", false, "accept changed permissions without asking")
 
 	// Cache subcommands
 	cacheCmd.AddCommand(cacheStatsCmd)
@@ -2,9 +2,11 @@ package cli
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"text/tabwriter"
 
 	"github.com/spf13/cobra"
@@ -27,13 +29,38 @@ Each repository contains a manifest (scmd-repo.yaml) listing available commands.
 
 // repoAddCmd adds a new repository
 var repoAddCmd = &cobra.Command{
-	Use:   "add <name> <url>",
+	Use:   "add <name> <url|path>",
 	Short: "Add a repository",
 	Args:  cobra.ExactArgs(2),
+	Long: `Add a repository.
+
+Repositories are served over HTTP(S), read from a local directory (a path or
+a file:// URL), or cloned from git with the local git binary: git+https://,
+git+ssh:// or git+file:// URLs, with an optional #branch, #tag or #commit.
+Git repositories are fetched again by 'scmd repo update'.
+
+Signed repositories publish a minisign public key as scmd-repo.pub. Pass the
+key you expect with --key; otherwise the published key is shown and you are
+asked to trust it. Once a key is trusted, every manifest and command fetched
+from the repository must carry a valid signature.`,
 	Example: `  scmd repo add community https://raw.githubusercontent.com/scmd-community/commands/main
-  scmd repo add myrepo https://example.com/scmd-commands`,
+  scmd repo add team ./path/to/commands
+  scmd repo add team git+ssh://git@git.example.com/team/commands.git#main
+  scmd repo add myrepo https://example.com/scmd-commands --key ./myrepo.pub
+  scmd repo add myrepo https://example.com/scmd-commands --key RWQf6LRCGA9i53mlYecO4IzT51TGPpvWucNSCh1CBM0QTaLn73Y7GFO3`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		name, url := args[0], args[1]
+		keyArg, _ := cmd.Flags().GetString("key")
+		yes, _ := cmd.Flags().GetBool("yes")
+		ctx := context.Background()
+
+		var key *repos.PublicKey
+		if keyArg != "" {
+			var err error
+			if key, err = repos.LoadPublicKey(keyArg); err != nil {
+				return err
+			}
+		}
 
 		mgr, err := getRepoManager()
 		if err != nil {
@@ -43,19 +70,49 @@ var repoAddCmd = &cobra.Command{
 		if err := mgr.Add(name, url); err != nil {
 			return err
 		}
+		repo, _ := mgr.Get(name)
+
+		// Clone git repositories now, so a bad URL or ref fails the add
+		if err := mgr.Sync(ctx, repo); err != nil {
+			mgr.Remove(name)
+			return fmt.Errorf("repository '%s' was not added: %w", name, err)
+		}
+
+		if key != nil {
+			mgr.Trust(name, key, repos.TrustSourceFlag)
+		} else {
+			// A key that can't be fetched or parsed fails the add, so breaking
+			// the key file can't downgrade the repository to unsigned
+			trusted, published, err := trustPublishedKey(ctx, mgr, repo, yes)
+			if err != nil {
+				mgr.Remove(name)
+				return fmt.Errorf("repository '%s' was not added: %w (pass --key with the key you expect)", name, err)
+			} else if published && !trusted {
+				mgr.Remove(name)
+				return fmt.Errorf("key not trusted; repository '%s' was not added", name)
+			} else if !published {
+				fmt.Fprintf(os.Stderr, "Warning: repository '%s' is not signed; its commands cannot be verified\n", name)
+			}
+		}
+
+		// Fetch the manifest to validate the repository and its signature
+		manifest, fetchErr := mgr.FetchManifest(ctx, repo)
+		if errors.Is(fetchErr, repos.ErrSignature) {
+			mgr.Remove(name)
+			return fmt.Errorf("repository '%s' was not added: %w", name, fetchErr)
+		}
 
 		if err := mgr.Save(); err != nil {
 			return fmt.Errorf("save repos: %w", err)
 		}
 
-		fmt.Printf("Added repository '%s' (%s)\n", name, url)
+		fmt.Printf("Added repository '%s' (%s)\n", name, repo.URL)
+		if k, ok := mgr.TrustedKey(name); ok {
+			fmt.Printf("  Trusted signing key %s\n", k.KeyID)
+		}
 
-		// Try to fetch manifest to validate
-		ctx := context.Background()
-		repo, _ := mgr.Get(name)
-		manifest, err := mgr.FetchManifest(ctx, repo)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Warning: could not fetch manifest: %v\n", err)
+		if fetchErr != nil {
+			fmt.Fprintf(os.Stderr, "Warning: could not fetch manifest: %v\n", fetchErr)
 			return nil
 		}
 
@@ -111,13 +168,17 @@ var repoListCmd = &cobra.Command{
 		}
 
 		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-		fmt.Fprintln(w, "NAME\tURL\tENABLED")
+		fmt.Fprintln(w, "NAME\tURL\tENABLED\tKEY")
 		for _, r := range repoList {
 			enabled := "yes"
 			if !r.Enabled {
 				enabled = "no"
 			}
-			fmt.Fprintf(w, "%s\t%s\t%s\n", r.Name, r.URL, enabled)
+			key := "unsigned"
+			if k, ok := mgr.TrustedKey(r.Name); ok {
+				key = k.KeyID
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", r.Name, r.URL, enabled, key)
 		}
 		w.Flush()
 
@@ -129,8 +190,13 @@ var repoListCmd = &cobra.Command{
 var repoUpdateCmd = &cobra.Command{
 	Use:   "update",
 	Short: "Update repository manifests",
+	Long: `Fetch the latest manifests of all enabled repositories, verifying their
+signatures. Git repositories are fetched and checked out at their ref first.
+Repositories that have started publishing a signing key since they were added
+are offered for trust.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		ctx := context.Background()
+		yes, _ := cmd.Flags().GetBool("yes")
 
 		mgr, err := getRepoManager()
 		if err != nil {
@@ -143,20 +209,40 @@ var repoUpdateCmd = &cobra.Command{
 			return nil
 		}
 
+		newKeys := false
 		fmt.Println("Updating repositories...")
 		for _, r := range repoList {
 			if !r.Enabled {
 				continue
 			}
+			if err := mgr.Sync(ctx, r); err != nil {
+				fmt.Printf("  %s: error: %v\n", r.Name, err)
+				continue
+			}
+			if _, ok := mgr.TrustedKey(r.Name); !ok {
+				if trusted, _, err := trustPublishedKey(ctx, mgr, r, yes); err == nil && trusted {
+					newKeys = true
+				}
+			}
+
 			fmt.Printf("  %s: ", r.Name)
 			manifest, err := mgr.FetchManifest(ctx, r)
 			if err != nil {
 				fmt.Printf("error: %v\n", err)
 				continue
 			}
-			fmt.Printf("%d commands\n", len(manifest.Commands))
+			status := "unsigned"
+			if k, ok := mgr.TrustedKey(r.Name); ok {
+				status = "verified with " + k.KeyID
+			}
+			fmt.Printf("%d commands (%s)\n", len(manifest.Commands), status)
 		}
 
+		if newKeys {
+			if err := mgr.Save(); err != nil {
+				return fmt.Errorf("save repos: %w", err)
+			}
+		}
 		return nil
 	},
 }
@@ -300,6 +386,13 @@ var repoShowCmd = &cobra.Command{
 			}
 		}
 
+		if lines := spec.PermissionSummary(); len(lines) > 0 {
+			fmt.Println("\nPermissions:")
+			for _, line := range lines {
+				fmt.Printf("  %s\n", line)
+			}
+		}
+
 		if len(spec.Examples) > 0 {
 			fmt.Println("\nExamples:")
 			for _, ex := range spec.Examples {
@@ -313,16 +406,42 @@ var repoShowCmd = &cobra.Command{
 
 // repoInstallCmd installs a command from a repository
 var repoInstallCmd = &cobra.Command{
-	Use:   "install <repo>/<command>",
+	Use:   "install <repo>/<command>[@version]",
 	Short: "Install a command from a repository",
 	Args:  cobra.ExactArgs(1),
+	Long: `Install a command from a repository.
+
+With --frozen, the command must be in the lockfile (scmd.lock, or --lockfile)
+and is installed exactly as locked: the repository must serve content with
+the recorded hash. Its locked dependencies are installed the same way.
+
+Dependencies are resolved from the constraints in the command's
+dependencies block; installed versions are kept when they satisfy them.
+
+Each installed version is kept, so 'scmd repo rollback' and 'scmd repo pin'
+can switch between them. @version installs a version the repository lists
+other than its latest.
+
+Before installing, scmd shows what each new command may do, from its
+permissions block: the programs it may run, the files it may read and
+write, the hosts it may fetch from and its shell hooks. Commands without a
+permissions block may use every built-in tool. Pass --yes to accept without
+asking.`,
 	Example: `  scmd repo install official/git-commit
-  scmd repo install community/docker-compose`,
+  scmd repo install official/git-commit@1.2.0
+  scmd repo install community/docker-compose
+  scmd repo install official/git-commit --frozen`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		ctx := context.Background()
+		frozen, _ := cmd.Flags().GetBool("frozen")
+		lockPath, _ := cmd.Flags().GetString("lockfile")
+		yes, _ := cmd.Flags().GetBool("yes")
 
-		// Parse repo/command format
-		repoCmd := args[0]
+		// Parse repo/command[@version] format
+		repoCmd, version, _ := strings.Cut(args[0], "@")
+		if frozen && version != "" {
+			return fmt.Errorf("--frozen installs the locked version; leave out @%s", version)
+		}
 		var repoName, cmdName string
 		for i, c := range repoCmd {
 			if c == '/' {
@@ -341,47 +460,106 @@ var repoInstallCmd = &cobra.Command{
 			return err
 		}
 
-		repo, ok := mgr.Get(repoName)
-		if !ok {
-			return fmt.Errorf("repository '%s' not found", repoName)
+		installDir := filepath.Join(getDataDir(), "commands")
+		if err := os.MkdirAll(installDir, 0755); err != nil {
+			return fmt.Errorf("create install dir: %w", err)
 		}
 
-		// Fetch manifest to find command
-		manifest, err := mgr.FetchManifest(ctx, repo)
-		if err != nil {
-			return fmt.Errorf("fetch manifest: %w", err)
+		cache := repos.NewCache(getDataDir())
+		if err := cache.Load(); err != nil {
+			return fmt.Errorf("load cache: %w", err)
 		}
 
-		var cmdEntry *repos.Command
-		for i := range manifest.Commands {
-			if manifest.Commands[i].Name == cmdName {
-				cmdEntry = &manifest.Commands[i]
-				break
+		var src *repos.LockedSource
+		res := &repos.Resolution{}
+		if frozen {
+			lf, err := repos.LoadLockfile(lockPath)
+			if err != nil {
+				return fmt.Errorf("load lockfile: %w", err)
+			}
+			locked, err := lf.Closure(repoName, cmdName)
+			if err != nil {
+				return fmt.Errorf("%w %s", err, lockPath)
+			}
+			// The command comes last, after its locked dependencies
+			for _, lc := range locked {
+				s, err := mgr.FetchLocked(ctx, lc, true)
+				if err != nil {
+					return err
+				}
+				if lc.Repo == repoName && lc.Name == cmdName {
+					src, res.Dependencies = s, lc.Dependencies
+					continue
+				}
+				res.Commands = append(res.Commands, &repos.ResolvedCmd{
+					Repo: s.Repo, Name: lc.Name, Version: s.Spec.Version, File: s.File,
+					Spec: s.Spec, Data: s.Data, Dependencies: lc.Dependencies,
+				})
+			}
+		} else {
+			repo, ok := mgr.Get(repoName)
+			if !ok {
+				return fmt.Errorf("repository '%s' not found", repoName)
 			}
-		}
 
-		if cmdEntry == nil {
-			return fmt.Errorf("command '%s' not found in repository '%s'", cmdName, repoName)
-		}
+			if version != "" {
+				if src, err = repos.NewResolver(mgr).Fetch(ctx, repoName, cmdName, version); err != nil {
+					return err
+				}
+			} else {
+				// Fetch manifest to find command
+				manifest, err := mgr.FetchManifest(ctx, repo)
+				if err != nil {
+					return fmt.Errorf("fetch manifest: %w", err)
+				}
 
-		// Fetch full command spec
-		spec, err := mgr.FetchCommand(ctx, repo, cmdEntry.File)
-		if err != nil {
-			return fmt.Errorf("fetch command: %w", err)
-		}
+				var cmdEntry *repos.Command
+				for i := range manifest.Commands {
+					if manifest.Commands[i].Name == cmdName {
+						cmdEntry = &manifest.Commands[i]
+						break
+					}
+				}
 
-		// Save command locally
-		installDir := filepath.Join(getDataDir(), "commands")
-		if err := os.MkdirAll(installDir, 0755); err != nil {
-			return fmt.Errorf("create install dir: %w", err)
+				if cmdEntry == nil {
+					return fmt.Errorf("command '%s' not found in repository '%s'", cmdName, repoName)
+				}
+
+				// Fetch full command spec
+				spec, data, err := mgr.FetchCommandData(ctx, repo, cmdEntry.File)
+				if err != nil {
+					return fmt.Errorf("fetch command: %w", err)
+				}
+				src = &repos.LockedSource{Repo: repo, File: cmdEntry.File, Spec: spec, Data: data}
+			}
+
+			if pin := cache.Pins()[repoName+"/"+cmdName]; pin != "" && pin != src.Spec.Version {
+				return fmt.Errorf("'%s/%s' is pinned to %s; use 'scmd repo pin %s/%s@%s' or unpin it first",
+					repoName, cmdName, pin, repoName, cmdName, src.Spec.Version)
+			}
+
+			if res, err = resolveDependencies(ctx, mgr, cache, installDir, src); err != nil {
+				return err
+			}
 		}
 
-		// Save as installed command
-		if err := mgr.InstallCommand(spec, installDir); err != nil {
-			return fmt.Errorf("install command: %w", err)
+		if !confirmPermissions(newPermissions(src, res, repos.InstalledFrom(cache, installDir)), yes) {
+			return fmt.Errorf("permissions not allowed; '%s' was not installed", cmdName)
+		}
+		if err := installWithDependencies(mgr, cache, installDir, src, res); err != nil {
+			return err
+		}
+		if err := cache.Save(); err != nil {
+			return fmt.Errorf("save cache: %w", err)
 		}
 
 		fmt.Printf("Installed '%s' from '%s'\n", cmdName, repoName)
+		if k, ok := mgr.TrustedKey(repoName); ok {
+			fmt.Printf("Signature verified (key %s)\n", k.KeyID)
+		}
+		if frozen {
+			fmt.Printf("Matches %s (%s)\n", lockPath, repos.ContentHash(src.Data))
+		}
 		fmt.Printf("Run with: scmd %s\n", cmdName)
 
 		return nil
@@ -421,4 +599,21 @@ func init() {
 	repoCmd.AddCommand(repoSearchCmd)
 	repoCmd.AddCommand(repoShowCmd)
 	repoCmd.AddCommand(repoInstallCmd)
+	repoCmd.AddCommand(repoTrustCmd)
+	repoCmd.AddCommand(repoOutdatedCmd)
+	repoCmd.AddCommand(repoUpgradeCmd)
+	repoCmd.AddCommand(repoPinCmd)
+	repoCmd.AddCommand(repoUnpinCmd)
+	repoCmd.AddCommand(repoRollbackCmd)
+
+	repoAddCmd.Flags().String("key", "", "minisign public key (or path to a .pub file) the repository must be signed with")
+	repoAddCmd.Flags().BoolP("yes", "y", false, "trust the repository's published key without asking")
+	repoUpdateCmd.Flags().BoolP("yes", "y", false, "trust newly published keys without asking")
+	repoInstallCmd.Flags().Bool("frozen", false, "only install commands in the lockfile, exactly as locked")
+	repoInstallCmd.Flags().String("lockfile", "scmd.lock", "lockfile used by --frozen")
+	repoInstallCmd.Flags().BoolP("yes", "y", false, "accept the commands' permissions without asking")
+	repoUpgradeCmd.Flags().Bool("all", false, "upgrade every installed command that isn't pinned")
+	repoUpgradeCmd.Flags().Bool("dry-run", false, "show what would be upgraded without installing")
+	repoUpgradeCmd.Flags().BoolP("yes", "y", false, "accept changed permissions without asking")
+	repoPinCmd.Flags().BoolP("yes", "y", false, "accept changed permissions without asking")
 }
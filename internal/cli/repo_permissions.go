@@ -0,0 +1,86 @@
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"golang.org/x/term"
+
+	"github.com/scmd/scmd/internal/repos"
+)
+
+// confirmPermissions shows what the commands being installed may do and
+// asks the user to allow it. Commands that may do nothing need no answer.
+// Without a terminal the permissions are only accepted with --yes.
+func confirmPermissions(specs []*repos.CommandSpec, yes bool) bool {
+	shown := false
+	for _, spec := range specs {
+		if printPermissions(spec) {
+			shown = true
+		}
+	}
+	if !shown || yes {
+		return true
+	}
+
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		fmt.Fprintln(os.Stderr, "Not accepting permissions without a terminal; pass --yes to accept them")
+		return false
+	}
+	fmt.Print("Allow this? [y/N]: ")
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+// printPermissions prints what a command may do, and reports whether it
+// may do anything
+func printPermissions(spec *repos.CommandSpec) bool {
+	lines := spec.PermissionSummary()
+	if len(lines) == 0 {
+		return false
+	}
+	fmt.Printf("'%s' may:\n", spec.Name)
+	for _, line := range lines {
+		fmt.Printf("  %s\n", line)
+	}
+	return true
+}
+
+// newPermissions returns the commands of an install whose permissions the
+// user hasn't agreed to: those not installed yet, or installed with
+// different permissions
+func newPermissions(src *repos.LockedSource, res *repos.Resolution, installed func(repo, name string) (*repos.CommandSpec, bool)) []*repos.CommandSpec {
+	var specs []*repos.CommandSpec
+	for _, dep := range res.Commands {
+		if !dep.Installed && permissionsChanged(installed, dep.Repo.Name, dep.Spec) {
+			specs = append(specs, dep.Spec)
+		}
+	}
+	if permissionsChanged(installed, src.Repo.Name, src.Spec) {
+		specs = append(specs, src.Spec)
+	}
+	return specs
+}
+
+// lockedPermissions returns the commands of a lockfile install whose
+// permissions the user hasn't agreed to
+func lockedPermissions(sources []*repos.LockedSource, installed func(repo, name string) (*repos.CommandSpec, bool)) []*repos.CommandSpec {
+	var specs []*repos.CommandSpec
+	for _, src := range sources {
+		if permissionsChanged(installed, src.Repo.Name, src.Spec) {
+			specs = append(specs, src.Spec)
+		}
+	}
+	return specs
+}
+
+// permissionsChanged reports whether a command isn't installed, or is
+// installed with different permissions
+func permissionsChanged(installed func(repo, name string) (*repos.CommandSpec, bool), repo string, spec *repos.CommandSpec) bool {
+	old, ok := installed(repo, spec.Name)
+	return !ok || !slices.Equal(old.PermissionSummary(), spec.PermissionSummary())
+}
@@ -0,0 +1,116 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+
+	"github.com/scmd/scmd/internal/repos"
+)
+
+// repoTrustCmd is the parent command for repository signing keys
+var repoTrustCmd = &cobra.Command{
+	Use:   "trust",
+	Short: "Manage trusted repository signing keys",
+	Long: `Manage the signing keys trusted for each repository.
+
+Keys are trusted with 'scmd repo add --key' or when first seen. Revoking a
+key makes the repository unsigned until a key is trusted again.`,
+}
+
+// repoTrustListCmd lists trusted keys
+var repoTrustListCmd = &cobra.Command{
+	Use:     "list",
+	Short:   "List trusted signing keys",
+	Aliases: []string{"ls"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		mgr, err := getRepoManager()
+		if err != nil {
+			return err
+		}
+
+		keys := mgr.TrustedKeys()
+		if len(keys) == 0 {
+			fmt.Println("No trusted keys.")
+			fmt.Println("Use 'scmd repo add <name> <url> --key <key>' to add a signed repository.")
+			return nil
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "REPO\tKEY ID\tSOURCE\tADDED\tPUBLIC KEY")
+		for _, k := range keys {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", k.Repo, k.KeyID, k.Source, k.AddedAt.Format("2006-01-02"), k.PublicKey)
+		}
+		w.Flush()
+
+		return nil
+	},
+}
+
+// repoTrustRevokeCmd forgets a repository's key
+var repoTrustRevokeCmd = &cobra.Command{
+	Use:     "revoke <repo>",
+	Short:   "Stop trusting a repository's signing key",
+	Aliases: []string{"rm"},
+	Args:    cobra.ExactArgs(1),
+	Example: `  scmd repo trust revoke community`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		mgr, err := getRepoManager()
+		if err != nil {
+			return err
+		}
+
+		if err := mgr.Revoke(args[0]); err != nil {
+			return err
+		}
+		if err := mgr.Save(); err != nil {
+			return fmt.Errorf("save repos: %w", err)
+		}
+
+		fmt.Printf("Revoked the signing key of '%s'\n", args[0])
+		return nil
+	},
+}
+
+// trustPublishedKey offers to trust the key a repository publishes. It
+// reports whether the key was trusted and whether the repository publishes
+// one at all. Without a terminal the key is only trusted with --yes.
+func trustPublishedKey(ctx context.Context, mgr *repos.Manager, repo *repos.Repository, yes bool) (trusted, published bool, err error) {
+	key, err := mgr.FetchPublicKey(ctx, repo)
+	if err != nil {
+		return false, false, err
+	}
+	if key == nil {
+		return false, false, nil
+	}
+
+	fmt.Printf("Repository '%s' is signed with key %s\n", repo.Name, key.KeyID())
+	fmt.Printf("  %s\n", key)
+
+	if !yes {
+		if !term.IsTerminal(int(os.Stdin.Fd())) {
+			fmt.Fprintln(os.Stderr, "Not trusting the key without a terminal; pass --yes, or --key with the key you expect")
+			return false, true, nil
+		}
+		fmt.Print("Trust this key? Check it against one published by the repository's authors [y/N]: ")
+		answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		answer = strings.ToLower(strings.TrimSpace(answer))
+		if answer != "y" && answer != "yes" {
+			return false, true, nil
+		}
+	}
+
+	mgr.Trust(repo.Name, key, repos.TrustSourceTOFU)
+	return true, true, nil
+}
+
+func init() {
+	repoTrustCmd.AddCommand(repoTrustListCmd)
+	repoTrustCmd.AddCommand(repoTrustRevokeCmd)
+}
@@ -0,0 +1,433 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/scmd/scmd/internal/diff"
+	"github.com/scmd/scmd/internal/repos"
+)
+
+// repoOutdatedCmd lists installed commands with newer versions
+var repoOutdatedCmd = &cobra.Command{
+	Use:   "outdated",
+	Short: "List installed commands with newer versions",
+	Long: `List installed commands that have newer versions in their repositories.
+
+WANTED is the newest version 'scmd repo upgrade' would install: the
+dependency constraints of other installed commands can hold a command back,
+and pinned commands are not upgraded at all.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := context.Background()
+
+		mgr, cache, installDir, err := loadInstalled()
+		if err != nil {
+			return err
+		}
+
+		planner := repos.NewResolver(mgr)
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		rows := 0
+		for _, c := range cache.Installed() {
+			up, err := mgr.PlanUpgrade(ctx, planner, cache, installDir, c)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: %s: %v\n", c.Key(), err)
+				continue
+			}
+			if !newer(up.Latest, c.Version) {
+				continue
+			}
+
+			wanted := c.Version
+			if up.Target != nil {
+				wanted = up.Target.Spec.Version
+			}
+			var note string
+			switch {
+			case c.Pinned != "":
+				wanted, note = c.Version, "pinned"
+			case len(up.HeldBy) > 0:
+				note = "held back: " + strings.Join(up.HeldBy, ", ")
+			}
+
+			if rows == 0 {
+				fmt.Fprintln(w, "COMMAND\tCURRENT\tWANTED\tLATEST\tNOTE")
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", c.Key(), c.Version, wanted, up.Latest, note)
+			rows++
+		}
+		if rows == 0 {
+			fmt.Println("All installed commands are up to date.")
+			return nil
+		}
+		w.Flush()
+
+		return nil
+	},
+}
+
+// repoUpgradeCmd upgrades installed commands
+var repoUpgradeCmd = &cobra.Command{
+	Use:   "upgrade [command...]",
+	Short: "Upgrade installed commands",
+	Long: `Upgrade installed commands to their newest versions.
+
+Commands are named as repo/command, or just command if only one repository
+provides it. With --all, every installed command is upgraded except pinned
+ones. Before installing, the changelog entries since the installed version
+are shown, or a diff of the command spec if there are none. If the new
+version's permissions differ, you are asked to allow them.
+
+The previous version is kept; 'scmd repo rollback' switches back to it.`,
+	Example: `  scmd repo upgrade git-commit
+  scmd repo upgrade --all
+  scmd repo upgrade --all --dry-run`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := context.Background()
+		all, _ := cmd.Flags().GetBool("all")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		yes, _ := cmd.Flags().GetBool("yes")
+
+		if all == (len(args) > 0) {
+			return fmt.Errorf("name the commands to upgrade, or use --all")
+		}
+
+		mgr, cache, installDir, err := loadInstalled()
+		if err != nil {
+			return err
+		}
+
+		var targets []repos.InstalledCmd
+		if all {
+			targets = cache.Installed()
+		}
+		for _, ref := range args {
+			c, err := cache.FindInstalled(ref)
+			if err != nil {
+				return err
+			}
+			if c.Pinned != "" {
+				return fmt.Errorf("'%s' is pinned to %s; use 'scmd repo unpin %s' first", c.Key(), c.Pinned, c.Key())
+			}
+			targets = append(targets, c)
+		}
+
+		upgraded, err := upgradeInstalled(ctx, mgr, cache, installDir, targets, upgradeOptions{all: all, dryRun: dryRun, yes: yes})
+		if err != nil {
+			return err
+		}
+		if upgraded == 0 {
+			return nil
+		}
+		if err := cache.Save(); err != nil {
+			return fmt.Errorf("save cache: %w", err)
+		}
+		fmt.Printf("Upgraded %d command(s)\n", upgraded)
+		return nil
+	},
+}
+
+// upgradeOptions control upgradeInstalled
+type upgradeOptions struct {
+	// all warns about commands that can't be upgraded and goes on, and
+	// leaves out those that are up to date
+	all    bool
+	dryRun bool
+	yes    bool
+}
+
+// upgradeInstalled upgrades commands to the newest versions the constraints
+// of the other installed commands allow, skipping pinned ones, and returns
+// how many it upgraded. The previous versions are kept. The cache is left
+// for the caller to save.
+func upgradeInstalled(ctx context.Context, mgr *repos.Manager, cache *repos.Cache, installDir string, targets []repos.InstalledCmd, opts upgradeOptions) (int, error) {
+	planner := repos.NewResolver(mgr)
+	upgraded := 0
+	for _, c := range targets {
+		if c.Pinned != "" {
+			fmt.Printf("Skipping %s (pinned to %s)\n", c.Key(), c.Pinned)
+			continue
+		}
+		// An earlier upgrade may have moved this command as a dependency
+		if current, err := cache.FindInstalled(c.Key()); err == nil {
+			c = current
+		}
+
+		up, err := mgr.PlanUpgrade(ctx, planner, cache, installDir, c)
+		if err != nil {
+			if !opts.all {
+				return upgraded, err
+			}
+			fmt.Fprintf(os.Stderr, "Warning: %s: %v\n", c.Key(), err)
+			continue
+		}
+		if up.Target == nil {
+			switch {
+			case len(up.HeldBy) > 0:
+				fmt.Printf("%s is held at %s: %s\n", c.Key(), c.Version, strings.Join(up.HeldBy, ", "))
+			case !opts.all:
+				fmt.Printf("%s is up to date (%s)\n", c.Key(), c.Version)
+			}
+			continue
+		}
+
+		fmt.Printf("Upgrading %s %s -> %s\n", c.Key(), c.Version, up.Target.Spec.Version)
+		showChanges(c, up.Target, installDir)
+		if opts.dryRun {
+			continue
+		}
+
+		res, err := resolveDependencies(ctx, mgr, cache, installDir, up.Target)
+		if err != nil {
+			return upgraded, err
+		}
+		if !confirmPermissions(newPermissions(up.Target, res, repos.InstalledFrom(cache, installDir)), opts.yes) {
+			return upgraded, fmt.Errorf("permissions not allowed; %s was not upgraded", c.Key())
+		}
+		if err := installWithDependencies(mgr, cache, installDir, up.Target, res); err != nil {
+			return upgraded, err
+		}
+		upgraded++
+	}
+	return upgraded, nil
+}
+
+// repoPinCmd pins an installed command to a version
+var repoPinCmd = &cobra.Command{
+	Use:   "pin <command>[@version]",
+	Short: "Pin an installed command to a version",
+	Long: `Pin an installed command to a version, installing it if needed.
+
+Without @version, the installed version is pinned. Pinned commands are left
+alone by 'scmd repo upgrade --all' and kept when resolving dependencies.
+If a version that isn't kept has different permissions, you are asked to
+allow them.`,
+	Args: cobra.ExactArgs(1),
+	Example: `  scmd repo pin git-commit
+  scmd repo pin official/git-commit@1.2.0`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := context.Background()
+		ref, version, _ := strings.Cut(args[0], "@")
+		yes, _ := cmd.Flags().GetBool("yes")
+
+		mgr, cache, installDir, err := loadInstalled()
+		if err != nil {
+			return err
+		}
+		c, err := cache.FindInstalled(ref)
+		if err != nil {
+			return err
+		}
+		if version == "" {
+			version = c.Version
+		}
+
+		if version != c.Version {
+			// The old pin must not hold the command at its current version
+			cache.SetPin(c.Repo, c.Name, "")
+
+			kept, err := mgr.InstalledVersions(installDir, c.Name)
+			if err != nil {
+				return err
+			}
+			if contains(kept, version) {
+				if _, err := mgr.SwitchVersion(installDir, cache, c, version); err != nil {
+					return err
+				}
+			} else {
+				src, err := repos.NewResolver(mgr).Fetch(ctx, c.Repo, c.Name, version)
+				if err != nil {
+					return err
+				}
+				res, err := resolveDependencies(ctx, mgr, cache, installDir, src)
+				if err != nil {
+					return err
+				}
+				if !confirmPermissions(newPermissions(src, res, repos.InstalledFrom(cache, installDir)), yes) {
+					return fmt.Errorf("permissions not allowed; %s was not pinned", c.Key())
+				}
+				if err := installWithDependencies(mgr, cache, installDir, src, res); err != nil {
+					return err
+				}
+			}
+			fmt.Printf("Switched %s from %s to %s\n", c.Key(), c.Version, version)
+		}
+
+		cache.SetPin(c.Repo, c.Name, version)
+		if err := cache.Save(); err != nil {
+			return fmt.Errorf("save cache: %w", err)
+		}
+		fmt.Printf("Pinned %s to %s\n", c.Key(), version)
+		return nil
+	},
+}
+
+// repoUnpinCmd removes a pin
+var repoUnpinCmd = &cobra.Command{
+	Use:     "unpin <command>",
+	Short:   "Let a pinned command be upgraded again",
+	Args:    cobra.ExactArgs(1),
+	Example: `  scmd repo unpin git-commit`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		_, cache, _, err := loadInstalled()
+		if err != nil {
+			return err
+		}
+		c, err := cache.FindInstalled(args[0])
+		if err != nil {
+			return err
+		}
+		if c.Pinned == "" {
+			fmt.Printf("%s is not pinned\n", c.Key())
+			return nil
+		}
+
+		cache.SetPin(c.Repo, c.Name, "")
+		if err := cache.Save(); err != nil {
+			return fmt.Errorf("save cache: %w", err)
+		}
+		fmt.Printf("Unpinned %s\n", c.Key())
+		return nil
+	},
+}
+
+// repoRollbackCmd switches a command back to its previous version
+var repoRollbackCmd = &cobra.Command{
+	Use:   "rollback <command>",
+	Short: "Switch a command back to its previous version",
+	Long: `Switch an installed command back to the version it had before its last
+upgrade, or to the newest kept version older than the installed one.`,
+	Args:    cobra.ExactArgs(1),
+	Example: `  scmd repo rollback git-commit`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		mgr, cache, installDir, err := loadInstalled()
+		if err != nil {
+			return err
+		}
+		c, err := cache.FindInstalled(args[0])
+		if err != nil {
+			return err
+		}
+		if c.Pinned != "" {
+			return fmt.Errorf("'%s' is pinned to %s; pin another version instead", c.Key(), c.Pinned)
+		}
+
+		version, err := mgr.RollbackTarget(installDir, c)
+		if err != nil {
+			return err
+		}
+		if _, err := mgr.SwitchVersion(installDir, cache, c, version); err != nil {
+			return err
+		}
+		if err := cache.Save(); err != nil {
+			return fmt.Errorf("save cache: %w", err)
+		}
+
+		fmt.Printf("Rolled back %s from %s to %s\n", c.Key(), c.Version, version)
+		return nil
+	},
+}
+
+// loadInstalled loads the repository manager and the cache of installed
+// commands
+func loadInstalled() (*repos.Manager, *repos.Cache, string, error) {
+	mgr, err := getRepoManager()
+	if err != nil {
+		return nil, nil, "", err
+	}
+	cache := repos.NewCache(getDataDir())
+	if err := cache.Load(); err != nil {
+		return nil, nil, "", fmt.Errorf("load cache: %w", err)
+	}
+	return mgr, cache, filepath.Join(getDataDir(), "commands"), nil
+}
+
+// resolveDependencies resolves the dependencies of a fetched command,
+// keeping installed versions that satisfy them and pinned versions
+func resolveDependencies(ctx context.Context, mgr *repos.Manager, cache *repos.Cache, installDir string, src *repos.LockedSource) (*repos.Resolution, error) {
+	if len(src.Spec.Dependencies) == 0 {
+		return &repos.Resolution{}, nil
+	}
+
+	resolver := repos.NewResolver(mgr)
+	resolver.Installed = repos.InstalledFrom(cache, installDir)
+	resolver.Pins = cache.Pins()
+	res, err := resolver.Resolve(ctx, src.Repo.Name, src.Spec)
+	if err != nil {
+		return nil, err
+	}
+	for _, skipped := range res.Skipped {
+		fmt.Printf("Skipping optional dependency %s\n", skipped)
+	}
+	return res, nil
+}
+
+// installWithDependencies installs a command after its resolved
+// dependencies, so it never runs without them, and records them in cache
+func installWithDependencies(mgr *repos.Manager, cache *repos.Cache, installDir string, src *repos.LockedSource, res *repos.Resolution) error {
+	if err := mgr.InstallResolution(res, installDir, cache); err != nil {
+		return err
+	}
+	for _, dep := range res.Commands {
+		if !dep.Installed {
+			fmt.Printf("Installed dependency %s@%s\n", dep.Key(), dep.Version)
+		}
+	}
+
+	// Save the command exactly as served, and record where it came from
+	if err := mgr.InstallCommandData(src.Spec, src.Data, installDir); err != nil {
+		return fmt.Errorf("install command: %w", err)
+	}
+	cache.RecordInstall(src.Repo, src.File, src.Spec, src.Data)
+	cache.RecordDependencies(src.Repo.Name, src.Spec.Name, res.Dependencies)
+	return nil
+}
+
+// showChanges prints the changelog of an upgrade, or a diff of the spec if
+// the new version has no changelog entries
+func showChanges(c repos.InstalledCmd, target *repos.LockedSource, installDir string) {
+	if changes := repos.ChangesSince(target.Spec, c.Version); len(changes) > 0 {
+		for _, e := range changes {
+			fmt.Printf("  %s:\n", e.Version)
+			for _, change := range e.Changes {
+				fmt.Printf("    - %s\n", change)
+			}
+		}
+		return
+	}
+
+	old, err := os.ReadFile(filepath.Join(installDir, c.Name+".yaml"))
+	if err != nil {
+		return
+	}
+	fmt.Print(diff.Unified(c.Name+"@"+c.Version, c.Name+"@"+target.Spec.Version, string(old), string(target.Data), 3))
+}
+
+// newer reports whether version a is newer than b; versions that don't parse
+// are newer if they differ
+func newer(a, b string) bool {
+	if a == "" {
+		return false
+	}
+	av, aErr := repos.ParseVersion(a)
+	bv, bErr := repos.ParseVersion(b)
+	if aErr != nil || bErr != nil {
+		return a != b
+	}
+	return av.Compare(bv) > 0
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -2,12 +2,14 @@ package cli
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 
 	"github.com/scmd/scmd/internal/backend"
 	"github.com/scmd/scmd/internal/backend/llamacpp"
@@ -83,15 +85,21 @@ func init() {
 	rootCmd.AddCommand(versionCmd)
 	rootCmd.AddCommand(explainCmd)
 	rootCmd.AddCommand(reviewCmd)
+	rootCmd.AddCommand(summarizeCmd)
+	rootCmd.AddCommand(explainErrorCmd)
+	rootCmd.AddCommand(undoCmd)
+	rootCmd.AddCommand(cmdCmd)
 	rootCmd.AddCommand(configCmd)
 	rootCmd.AddCommand(killProcessCmd)
 	rootCmd.AddCommand(backendsCmd)
 	rootCmd.AddCommand(doctorCmd)
 	rootCmd.AddCommand(serverCmd)
 	rootCmd.AddCommand(repoCmd)
+	rootCmd.AddCommand(commandCmd)
+	rootCmd.AddCommand(projectCmd)
 	rootCmd.AddCommand(registryCmd)
 	rootCmd.AddCommand(updateCmd)
-	rootCmd.AddCommand(lockCmd)
+	rootCmd.AddCommand(newLockCmd())
 	rootCmd.AddCommand(cacheCmd)
 	rootCmd.AddCommand(slashCmd)
 	rootCmd.AddCommand(modelsCmd)
@@ -207,21 +215,126 @@ var explainCmd = &cobra.Command{
 
 // reviewCmd wraps the builtin review command
 var reviewCmd = &cobra.Command{
-	Use:     "review [file]",
+	Use:     "review [file|range]",
 	Short:   "Review code for issues and improvements",
 	Aliases: []string{"r"},
 	Example: `  scmd review main.go
   git diff | scmd review
-  scmd review auth.py --template security-review`,
+  scmd review auth.py --template security-review
+  scmd review main.go --report sarif > review.sarif
+  scmd review main.go --report json --report-file findings.json
+  scmd review --diff main..HEAD
+  scmd review --diff --staged --report sarif
+  scmd review handler.go --apply
+
+Structured reviews (--report or --diff) exit with 0 when clean or notes only,
+2 when the highest severity is a warning and 3 when it is an error. Files that
+could not be reviewed are listed in the report; if they leave it otherwise
+clean, the exit code is 1.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		return runBuiltinCommandWithCmd(cmd, "review", args)
 	},
 }
 
+// summarizeCmd wraps the builtin summarize command
+var summarizeCmd = &cobra.Command{
+	Use:     "summarize [file|text]",
+	Short:   "Summarize text, logs, or diffs",
+	Aliases: []string{"sum", "tldr"},
+	Example: `  scmd summarize article.md
+  cat server.log | scmd summarize --length short
+  git diff main | scmd summarize --style paragraph`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runBuiltinCommandWithCmd(cmd, "summarize", args)
+	},
+}
+
+// explainErrorCmd wraps the builtin explain-error command
+var explainErrorCmd = &cobra.Command{
+	Use:     "explain-error [error|file]",
+	Short:   "Explain compiler errors and stack traces and propose a fix",
+	Aliases: []string{"fix", "err"},
+	Example: `  go build ./... 2>&1 | scmd explain-error
+  python app.py 2>&1 | scmd fix
+  scmd explain-error crash.log --context-lines 10
+  go test ./... 2>&1 | scmd fix --apply`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runBuiltinCommandWithCmd(cmd, "explain-error", args)
+	},
+}
+
+// cmdCmd wraps the builtin cmd command
+var cmdCmd = &cobra.Command{
+	Use:     "cmd <question>",
+	Short:   "Generate a shell command from a question, optionally running it",
+	Aliases: []string{"howto"},
+	Example: `  scmd cmd "find files modified in the last 24 hours"
+  scmd /cmd --first "count lines in all .go files"
+  scmd /cmd --run "show disk usage of this directory"
+  scmd /cmd -i "delete merged git branches"
+
+Suggestions are checked against man pages and --help output; ones using a
+missing program or an unknown flag are dropped. Pick from the rest, or pass
+--first to take the top-ranked one.
+
+With --run the command is executed in your shell after a preview; destructive
+commands always ask first. --interactive asks before every command. If the
+command fails, its error output is sent back for one corrected attempt.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runBuiltinCommandWithCmd(cmd, "cmd", args)
+	},
+}
+
 func init() {
 	// Add --template flag to review and explain commands
 	reviewCmd.Flags().String("template", "", "Use a prompt template")
 	explainCmd.Flags().String("template", "", "Use a prompt template")
+
+	reviewCmd.Flags().String("report", "", "emit structured findings: json, sarif")
+	reviewCmd.Flags().String("report-file", "", "write the --report output to a file and print markdown")
+	reviewCmd.Flags().Bool("diff", false, "review git changes file by file (optionally a range such as main..HEAD)")
+	reviewCmd.Flags().Bool("staged", false, "with --diff, review staged changes")
+
+	summarizeCmd.Flags().String("length", "medium", "summary length: short, medium, long")
+	summarizeCmd.Flags().String("style", "bullets", "summary style: bullets, paragraph")
+	summarizeCmd.Flags().String("type", "auto", "input type: auto, log, diff, prose, code")
+
+	explainErrorCmd.Flags().Int("context-lines", 5, "source lines to include around each error location")
+
+	cmdCmd.Flags().Bool("first", false, "use the top-ranked command instead of choosing")
+	cmdCmd.Flags().Bool("run", false, "run the generated command after a preview")
+	cmdCmd.Flags().BoolP("interactive", "i", false, "edit, dry-run or run the generated command")
+
+	killProcessCmd.Flags().String("pattern", "", "match a regular expression against the full command line")
+	killProcessCmd.Flags().Int("port", 0, "match processes listening on a TCP port")
+	killProcessCmd.Flags().Int("tree", 0, "match a process and all of its descendants")
+	killProcessCmd.Flags().StringP("signal", "s", "TERM", "signal to send: TERM, INT, QUIT, HUP, KILL or a number")
+	killProcessCmd.Flags().String("timeout", "5s", "wait this long before escalating to SIGKILL")
+	killProcessCmd.Flags().Bool("no-force", false, "never escalate to SIGKILL")
+	killProcessCmd.Flags().Bool("list", false, "only list matching processes")
+	killProcessCmd.Flags().BoolP("yes", "y", false, "kill without confirming")
+
+	undoCmd.Flags().Bool("force", false, "revert files even if they were edited after the patch")
+
+	// Apply mode: the model proposes a patch that is previewed hunk by hunk
+	for _, c := range []*cobra.Command{reviewCmd, explainErrorCmd} {
+		c.Flags().Bool("apply", false, "propose a patch, preview each hunk and apply the accepted ones")
+		c.Flags().Bool("yes", false, "with --apply, accept every hunk without prompting")
+	}
+}
+
+// undoCmd wraps the builtin undo command
+var undoCmd = &cobra.Command{
+	Use:   "undo",
+	Short: "Revert the last patch applied with --apply",
+	Long: `Revert the last patch applied with --apply.
+
+Files edited after the patch was applied are not overwritten: undo fails
+and lists them, unless --force is given.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runBuiltinCommandWithCmd(cmd, "undo", args)
+	},
 }
 
 // configCmd wraps the builtin config command
@@ -239,14 +352,17 @@ var configCmd = &cobra.Command{
 
 // killProcessCmd wraps the builtin kill-process command
 var killProcessCmd = &cobra.Command{
-	Use:     "kill-process <name>",
-	Short:   "Find and kill processes by name",
+	Use:     "kill-process [name]",
+	Short:   "Find and kill processes by name, command line, port or parent",
 	Aliases: []string{"kp", "killp"},
 	Example: `  scmd kill-process cursor
   scmd /kp node
-  scmd kill-process chrome`,
+  scmd kill-process --port 3000
+  scmd kill-process --pattern 'python .*runserver'
+  scmd kill-process --tree 4242 --signal INT --timeout 10s
+  scmd kill-process chrome --list`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return runBuiltinCommand("kill-process", args)
+		return runBuiltinCommandWithCmd(cmd, "kill-process", args)
 	},
 }
 
@@ -313,11 +429,9 @@ func runBuiltinCommandWithCmd(cmd *cobra.Command, name string, args []string) er
 		cmdArgs.Options["stdin"] = stdinContent
 	}
 
-	// Pass --template flag if present (for review and explain commands)
+	// Pass command-specific flags (e.g. --template, --length) that were set
 	if cmd != nil {
-		if templateFlag, _ := cmd.Flags().GetString("template"); templateFlag != "" {
-			cmdArgs.Options["template"] = templateFlag
-		}
+		addLocalFlags(cmd, cmdArgs)
 	}
 
 	// Execute
@@ -343,6 +457,15 @@ func runBuiltinCommandWithCmd(cmd *cobra.Command, name string, args []string) er
 		return fmt.Errorf("%s", result.Error)
 	}
 
+	if result.ExitCode != 0 {
+		if cmd != nil {
+			// The exit code is the signal; don't print it as an error
+			cmd.SilenceErrors = true
+			cmd.SilenceUsage = true
+		}
+		return &ExitError{Code: result.ExitCode}
+	}
+
 	return nil
 }
 
@@ -464,6 +587,9 @@ func preRun(cmd *cobra.Command, _ []string) error {
 	_ = mgr.Load() // Ignore error, repos may not exist yet
 
 	loader := repos.NewLoader(mgr, filepath.Join(dataDir, "commands"))
+	if p := currentProject(); p != nil {
+		loader.SetProject(p) // project commands take precedence
+	}
 	_ = loader.RegisterAll(cmdRegistry) // Ignore errors, commands may not exist yet
 
 	return nil
@@ -706,6 +832,10 @@ func formatAndWriteOutput(output *OutputWriter, result *command.Result, format s
 		}
 		return output.WriteLine(result.Output)
 	default: // "text"
+		// Machine-readable output (e.g. review reports) is written verbatim
+		if looksLikeJSON(result.Output) {
+			return output.WriteLine(result.Output)
+		}
 		// Check if the output looks like markdown (has headers, code blocks, etc.)
 		if looksLikeMarkdown(result.Output) {
 			return output.WriteMarkdown(result.Output)
@@ -714,6 +844,12 @@ func formatAndWriteOutput(output *OutputWriter, result *command.Result, format s
 	}
 }
 
+// looksLikeJSON checks if a string is a JSON document
+func looksLikeJSON(s string) bool {
+	s = strings.TrimSpace(s)
+	return (strings.HasPrefix(s, "{") || strings.HasPrefix(s, "[")) && json.Valid([]byte(s))
+}
+
 // looksLikeMarkdown checks if a string appears to be markdown
 func looksLikeMarkdown(s string) bool {
 	// Check for common markdown patterns
@@ -808,14 +944,26 @@ func runSlashCommand(cmd string, args []string) error {
 	// Strip leading slash
 	cmdName := strings.TrimPrefix(cmd, "/")
 
+	// Builtins with a cobra wrapper also accept their own flags (e.g. /cmd --run)
+	flagCmd := rootCmd
+	if sub, _, err := rootCmd.Find([]string{cmdName}); err == nil && sub != rootCmd {
+		flagCmd = sub
+	}
+
+	// Plugin commands declare their own --name=value inputs
+	var pluginOptions map[string]string
+	if flagCmd == rootCmd {
+		args, pluginOptions = splitCommandOptions(rootCmd, args)
+	}
+
 	// Parse flags from args (e.g., --backend, --model, etc.)
 	// This sets the global flag variables like backendFlag, modelFlag
-	if err := rootCmd.ParseFlags(args); err != nil {
+	if err := flagCmd.ParseFlags(args); err != nil {
 		return err
 	}
 
 	// Get the non-flag arguments (the actual command arguments)
-	cmdArgs := rootCmd.Flags().Args()
+	cmdArgs := flagCmd.Flags().Args()
 
 	// Initialize everything via preRun
 	if err := preRun(rootCmd, nil); err != nil {
@@ -893,6 +1041,12 @@ func runSlashCommand(cmd string, args []string) error {
 	if stdinContent != "" {
 		commandArgs.Options["stdin"] = stdinContent
 	}
+	if flagCmd != rootCmd {
+		addLocalFlags(flagCmd, commandArgs)
+	}
+	for name, value := range pluginOptions {
+		commandArgs.Options[name] = value
+	}
 
 	// Execute
 	result, err := c.Execute(ctx, commandArgs, execCtx)
@@ -916,9 +1070,45 @@ func runSlashCommand(cmd string, args []string) error {
 		return fmt.Errorf("%s", result.Error)
 	}
 
+	if result.ExitCode != 0 {
+		return &ExitError{Code: result.ExitCode}
+	}
+
 	return nil
 }
 
+// addLocalFlags copies the command-specific flags that were set into args
+func addLocalFlags(cmd *cobra.Command, args *command.Args) {
+	cmd.LocalNonPersistentFlags().Visit(func(f *pflag.Flag) {
+		if f.Value.Type() == "bool" {
+			args.Flags[f.Name] = f.Value.String() == "true"
+			return
+		}
+		args.Options[f.Name] = f.Value.String()
+	})
+}
+
+// splitCommandOptions removes --name=value arguments that are not scmd
+// flags, leaving the rest for flag parsing
+func splitCommandOptions(cmd *cobra.Command, args []string) ([]string, map[string]string) {
+	rest := make([]string, 0, len(args))
+	options := make(map[string]string)
+	for i, arg := range args {
+		if arg == "--" {
+			rest = append(rest, args[i:]...)
+			break
+		}
+		name, value, ok := strings.Cut(strings.TrimPrefix(arg, "--"), "=")
+		if ok && strings.HasPrefix(arg, "--") && name != "" &&
+			cmd.Flags().Lookup(name) == nil && cmd.PersistentFlags().Lookup(name) == nil {
+			options[name] = value
+			continue
+		}
+		rest = append(rest, arg)
+	}
+	return rest, options
+}
+
 // ConsoleUI implements command.UI for terminal output
 type ConsoleUI struct {
 	mode *IOMode
@@ -11,6 +11,7 @@ import (
 
 	"github.com/spf13/cobra"
 
+	"github.com/scmd/scmd/internal/repos"
 	"github.com/scmd/scmd/internal/slash"
 )
 
@@ -82,9 +83,17 @@ var slashRunCmd = &cobra.Command{
 			return fmt.Errorf("%s", result.Error)
 		}
 
-		fmt.Print(result.Output)
-		if !strings.HasSuffix(result.Output, "\n") {
-			fmt.Println()
+		if result.Output != "" {
+			fmt.Print(result.Output)
+			if !strings.HasSuffix(result.Output, "\n") {
+				fmt.Println()
+			}
+		}
+
+		if result.ExitCode != 0 {
+			cmd.SilenceErrors = true
+			cmd.SilenceUsage = true
+			return &ExitError{Code: result.ExitCode}
 		}
 
 		return nil
@@ -113,16 +122,25 @@ var slashListCmd = &cobra.Command{
 		fmt.Println()
 
 		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-		fmt.Fprintln(w, "COMMAND\tALIASES\tRUNS\tDESCRIPTION")
+		fmt.Fprintln(w, "COMMAND\tALIASES\tRUNS\tSCOPE\tDESCRIPTION")
 		for _, c := range commands {
 			aliases := strings.Join(c.Aliases, ", ")
 			if aliases == "" {
 				aliases = "-"
 			}
-			fmt.Fprintf(w, "/%s\t%s\t%s\t%s\n", c.Name, aliases, c.Command, c.Description)
+			scope := c.Scope
+			if scope == "" {
+				scope = repos.ScopeGlobal
+			}
+			fmt.Fprintf(w, "/%s\t%s\t%s\t%s\t%s\n", c.Name, aliases, c.Command, scope, c.Description)
 		}
 		w.Flush()
 
+		if p := currentProject(); p != nil {
+			fmt.Println()
+			fmt.Printf("Project: %s\n", p.Root)
+		}
+
 		fmt.Println()
 		fmt.Println("Usage: /<command> [args]  or  scmd slash run <command> [args]")
 
@@ -237,7 +255,10 @@ Supported shells: bash, zsh, fish`,
   eval "$(scmd slash init bash)"
 
   # For fish, add to ~/.config/fish/config.fish:
-  scmd slash init fish | source`,
+  scmd slash init fish | source
+
+  # Also add commands run by '/cmd --run' to your shell history:
+  eval "$(scmd slash init bash --history)"`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		runner, err := getSlashRunner()
 		if err != nil {
@@ -249,7 +270,8 @@ Supported shells: bash, zsh, fish`,
 			shell = args[0]
 		}
 
-		integration := runner.GenerateShellIntegration(shell)
+		history, _ := cmd.Flags().GetBool("history")
+		integration := runner.ShellIntegration(shell, history)
 		fmt.Print(integration)
 
 		return nil
@@ -301,7 +323,11 @@ var slashInteractiveCmd = &cobra.Command{
 					if len(c.Aliases) > 0 {
 						aliases = fmt.Sprintf(" (%s)", strings.Join(c.Aliases, ", "))
 					}
-					fmt.Printf("  /%s%s - %s\n", c.Name, aliases, c.Description)
+					marker := ""
+					if c.Scope == repos.ScopeProject {
+						marker = " [project]"
+					}
+					fmt.Printf("  /%s%s - %s%s\n", c.Name, aliases, c.Description, marker)
 				}
 				fmt.Println("  /quit - exit")
 				continue
@@ -373,6 +399,11 @@ func getSlashRunner() (*slash.Runner, error) {
 	if err := slashRunner.LoadConfig(); err != nil {
 		return nil, fmt.Errorf("load slash config: %w", err)
 	}
+	if p := currentProject(); p != nil {
+		if err := slashRunner.LoadProject(p); err != nil {
+			return nil, fmt.Errorf("load project slash config: %w", err)
+		}
+	}
 
 	return slashRunner, nil
 }
@@ -382,6 +413,10 @@ func init() {
 	slashAddCmd.Flags().String("alias", "", "comma-separated aliases (e.g., gc,gitc)")
 	slashAddCmd.Flags().String("description", "", "command description")
 	slashAddCmd.Flags().Bool("stdin", true, "command accepts stdin input")
+	slashInitCmd.Flags().Bool("history", false, "add commands run by '/cmd --run' to the shell history")
+
+	// Flags after the command name belong to the command (e.g. /cmd --run)
+	slashRunCmd.Flags().SetInterspersed(false)
 
 	// Add subcommands
 	slashCmd.AddCommand(slashRunCmd)
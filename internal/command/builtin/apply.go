@@ -0,0 +1,297 @@
+package builtin
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/scmd/scmd/internal/backend"
+	"github.com/scmd/scmd/internal/command"
+	"github.com/scmd/scmd/internal/config"
+	"github.com/scmd/scmd/internal/diff"
+	"github.com/scmd/scmd/internal/preview"
+)
+
+const patchSystemPrompt = `You are an expert software engineer who fixes code by writing patches.
+Respond with a single unified diff and nothing else - no explanation, no markdown fences.
+Use this exact format for every file you change:
+--- a/path/to/file
++++ b/path/to/file
+@@ -start,count +start,count @@
+ context line
+-removed line
++added line
+
+Rules:
+- Use the file paths exactly as given. Only change the files you were shown.
+- Include 3 lines of unchanged context around each change, copied exactly.
+- Keep changes minimal and focused on the task.`
+
+// undoDir returns the directory holding the undo journal
+func undoDir(execCtx *command.ExecContext) string {
+	dataDir := execCtx.DataDir
+	if dataDir == "" {
+		dataDir = config.DataDir()
+	}
+	return filepath.Join(dataDir, "undo")
+}
+
+// promptInput returns where to read confirmations from. Piped input occupies
+// stdin, so prompts go to the terminal instead.
+func promptInput(args *command.Args) (io.Reader, func(), error) {
+	if _, piped := args.Options["stdin"]; !piped {
+		return os.Stdin, func() {}, nil
+	}
+	tty, err := os.Open("/dev/tty")
+	if err != nil {
+		return nil, nil, err
+	}
+	return tty, func() { tty.Close() }, nil
+}
+
+// patchRequest describes a change the model should make as a patch
+type patchRequest struct {
+	// Task tells the model what to change
+	Task string
+	// Files maps relative paths to their current content
+	Files map[string]string
+	// Description is recorded in the undo journal
+	Description string
+}
+
+// maxPatchFileSize is the largest file offered to the model for patching
+const maxPatchFileSize = 100 * 1024
+
+// patchableFiles reads the given files for a patch request, keyed by their
+// path relative to the working directory. Files outside it, missing files and
+// very large files are skipped.
+func patchableFiles(paths []string) map[string]string {
+	files := make(map[string]string)
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		return files
+	}
+
+	for _, p := range paths {
+		abs, err := filepath.Abs(p)
+		if err != nil {
+			continue
+		}
+		rel, err := filepath.Rel(cwd, abs)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+
+		info, err := os.Stat(abs)
+		if err != nil || info.IsDir() || info.Size() > maxPatchFileSize {
+			continue
+		}
+		data, err := os.ReadFile(abs)
+		if err != nil {
+			continue
+		}
+		files[filepath.ToSlash(rel)] = string(data)
+	}
+
+	return files
+}
+
+// buildPatchPrompt shows the model the task and every file it may change
+func buildPatchPrompt(req *patchRequest) string {
+	var sb strings.Builder
+	sb.WriteString(req.Task)
+	sb.WriteString("\n\nRespond with a unified diff against these files:\n")
+
+	paths := make([]string, 0, len(req.Files))
+	for p := range req.Files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	for _, p := range paths {
+		lang := detectLanguage(p, "")
+		if lang == "auto-detect" {
+			lang = ""
+		}
+		sb.WriteString(fmt.Sprintf("\n%s\n```%s\n%s\n```\n", p, lang, strings.TrimRight(req.Files[p], "\n")))
+	}
+
+	return sb.String()
+}
+
+// extractDiff strips fences and prose around a unified diff
+func extractDiff(s string) string {
+	lines := strings.Split(s, "\n")
+	start := -1
+	for i, l := range lines {
+		if strings.HasPrefix(l, "--- ") || strings.HasPrefix(l, "diff --git ") {
+			start = i
+			break
+		}
+	}
+	if start < 0 {
+		return ""
+	}
+
+	end := len(lines)
+	for i := start; i < len(lines); i++ {
+		if strings.HasPrefix(lines[i], "```") {
+			end = i
+			break
+		}
+	}
+	return strings.Join(lines[start:end], "\n") + "\n"
+}
+
+// requestPatch asks the backend for a patch and validates it against the
+// files on disk under root, retrying once with the problems if it does not
+// parse or apply
+func requestPatch(ctx context.Context, b backend.Backend, req *patchRequest, root string) ([]*diff.FilePatch, error) {
+	prompt := buildPatchPrompt(req)
+	completion := &backend.CompletionRequest{
+		Prompt:       prompt,
+		SystemPrompt: patchSystemPrompt,
+		MaxTokens:    4096,
+		Temperature:  0.1,
+	}
+
+	var lastErr error
+	for attempt := 0; attempt < 2; attempt++ {
+		if lastErr != nil {
+			completion.Prompt = fmt.Sprintf("%s\n\nYour previous patch could not be applied:\n%v\n"+
+				"Respond again with only a corrected unified diff.", prompt, lastErr)
+		}
+
+		resp, err := b.Complete(ctx, completion)
+		if err != nil {
+			return nil, fmt.Errorf("backend error: %v", err)
+		}
+
+		patches, err := validatePatch(resp.Content, req, root)
+		if err == nil {
+			return patches, nil
+		}
+		lastErr = err
+	}
+
+	return nil, fmt.Errorf("model did not produce an applicable patch:\n%v", lastErr)
+}
+
+// validatePatch parses a model response and checks it against the files on disk
+func validatePatch(response string, req *patchRequest, root string) ([]*diff.FilePatch, error) {
+	text := extractDiff(response)
+	if text == "" {
+		return nil, fmt.Errorf("response does not contain a unified diff")
+	}
+
+	files, err := diff.Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("diff contains no file changes")
+	}
+
+	for _, f := range files {
+		if _, ok := req.Files[f.Path()]; !ok {
+			return nil, fmt.Errorf("%s: patch changes a file that was not part of the request", f.Path())
+		}
+	}
+
+	return diff.Plan(files, root, diff.DefaultFuzz)
+}
+
+// proposeAndApply asks the model for a patch, previews it hunk by hunk and
+// applies the accepted hunks atomically with an undo journal entry
+func proposeAndApply(
+	ctx context.Context,
+	args *command.Args,
+	execCtx *command.ExecContext,
+	req *patchRequest,
+) (*command.Result, error) {
+	if execCtx.Backend == nil {
+		return command.NewErrorResult(
+			"no backend available",
+			"Configure a backend with 'scmd config'",
+		), nil
+	}
+
+	if len(req.Files) == 0 {
+		return command.NewErrorResult(
+			"no files to patch in the working directory",
+			"Patches can only change files under the current directory",
+		), nil
+	}
+
+	stop := execCtx.UI.Spinner("Writing patch")
+	patches, err := requestPatch(ctx, execCtx.Backend, req, ".")
+	stop()
+	if err != nil {
+		return command.NewErrorResult(err.Error(), "Try again, or use a larger model for patches"), nil
+	}
+
+	var decisions [][]bool
+	if args.HasFlag("yes") {
+		decisions = make([][]bool, len(patches))
+		for i, p := range patches {
+			decisions[i] = make([]bool, len(p.File.Hunks))
+			for h := range decisions[i] {
+				decisions[i][h] = true
+			}
+		}
+	} else {
+		input, closeInput, err := promptInput(args)
+		if err != nil {
+			return command.NewErrorResult(
+				"cannot prompt for confirmation: no terminal available",
+				"Pass --yes to apply every hunk without prompting",
+			), nil
+		}
+		defer closeInput()
+
+		buffer := preview.NewPatchBuffer(patches)
+		buffer.Input = input
+		buffer.Output = os.Stderr
+
+		action, accepted, err := buffer.Review()
+		if err != nil {
+			return command.NewErrorResult(fmt.Sprintf("preview failed: %v", err)), nil
+		}
+		if action == preview.ActionQuit {
+			return command.NewResult("Patch discarded, no files changed."), nil
+		}
+		decisions = accepted
+	}
+
+	var changes []diff.Change
+	hunks := 0
+	for i, p := range patches {
+		change, ok := p.Change(decisions[i])
+		if !ok {
+			continue
+		}
+		changes = append(changes, change)
+		for _, a := range decisions[i] {
+			if a {
+				hunks++
+			}
+		}
+	}
+
+	if len(changes) == 0 {
+		return command.NewResult("No hunks accepted, no files changed."), nil
+	}
+
+	journal := diff.NewJournal(undoDir(execCtx))
+	if _, err := journal.WriteAtomic(req.Description, changes); err != nil {
+		return command.NewErrorResult(fmt.Sprintf("apply failed: %v", err)), nil
+	}
+
+	return command.NewResult(fmt.Sprintf(
+		"✓ Applied %d hunk(s) to %d file(s). Run 'scmd undo' to revert.", hunks, len(changes))), nil
+}
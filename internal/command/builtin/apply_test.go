@@ -0,0 +1,91 @@
+package builtin
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/scmd/scmd/internal/command"
+	"github.com/scmd/scmd/tests/testutil"
+)
+
+func TestExtractDiff(t *testing.T) {
+	raw := "Here is the fix:\n```diff\n--- a/x.go\n+++ b/x.go\n@@ -1 +1 @@\n-a\n+b\n```\nHope it helps."
+	assert.Equal(t, "--- a/x.go\n+++ b/x.go\n@@ -1 +1 @@\n-a\n+b\n", extractDiff(raw))
+
+	assert.Equal(t, "", extractDiff("no diff here"))
+}
+
+func TestValidatePatch_RejectsUnrequestedFiles(t *testing.T) {
+	req := &patchRequest{Files: map[string]string{"a.go": "x\n"}}
+	_, err := validatePatch("--- a/b.go\n+++ b/b.go\n@@ -1 +1 @@\n-x\n+y\n", req, t.TempDir())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "not part of the request")
+}
+
+func TestReviewCommand_ApplyAndUndo(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	require.NoError(t, os.WriteFile("main.go", []byte("package main\n\nfunc main() {\n\tprintln(1 / 0)\n}\n"), 0644))
+
+	be := &sequenceBackend{
+		MockBackend: testutil.NewMockBackend(),
+		responses: []string{
+			// First patch removes a line that does not exist and is rejected
+			"--- a/main.go\n+++ b/main.go\n@@ -3,2 +3,2 @@\n func main() {\n-\tprintln(2 / 0)\n+\tprintln(1)\n",
+			"```diff\n--- a/main.go\n+++ b/main.go\n@@ -3,3 +3,3 @@\n func main() {\n-\tprintln(1 / 0)\n+\tprintln(1)\n }\n```",
+		},
+	}
+	execCtx := &command.ExecContext{UI: testutil.NewMockUI(), Backend: be, DataDir: filepath.Join(dir, ".data")}
+
+	args := command.NewArgs()
+	args.Positional = []string{"main.go"}
+	args.Flags["apply"] = true
+	args.Flags["yes"] = true
+
+	result, err := NewReviewCommand().Execute(context.Background(), args, execCtx)
+	require.NoError(t, err)
+	require.True(t, result.Success, result.Error)
+	assert.Contains(t, result.Output, "Applied 1 hunk(s) to 1 file(s)")
+	assert.Equal(t, 2, be.calls, "retried after the patch failed to apply")
+
+	data, err := os.ReadFile("main.go")
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "\tprintln(1)\n")
+
+	result, err = NewUndoCommand().Execute(context.Background(), command.NewArgs(), execCtx)
+	require.NoError(t, err)
+	require.True(t, result.Success, result.Error)
+	assert.Contains(t, result.Output, "review --apply main.go")
+
+	data, err = os.ReadFile("main.go")
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "println(1 / 0)")
+
+	result, err = NewUndoCommand().Execute(context.Background(), command.NewArgs(), execCtx)
+	require.NoError(t, err)
+	assert.False(t, result.Success)
+	assert.Contains(t, result.Error, "nothing to undo")
+}
+
+func TestReviewCommand_ValidateApply(t *testing.T) {
+	cmd := NewReviewCommand()
+
+	args := command.NewArgs()
+	args.Options["stdin"] = "code"
+	args.Flags["apply"] = true
+	assert.Error(t, cmd.Validate(args), "--apply needs a file")
+
+	args.Positional = []string{"main.go"}
+	args.Options["report"] = "json"
+	assert.Error(t, cmd.Validate(args))
+
+	args = command.NewArgs()
+	args.Flags["apply"] = true
+	args.Flags["diff"] = true
+	assert.Error(t, cmd.Validate(args))
+}
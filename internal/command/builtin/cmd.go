@@ -3,6 +3,8 @@ package builtin
 import (
 	"context"
 	"fmt"
+	"io"
+	"os"
 	"strings"
 
 	"github.com/scmd/scmd/internal/backend"
@@ -10,12 +12,43 @@ import (
 	"github.com/scmd/scmd/internal/utils/manpage"
 )
 
+const cmdSystemPrompt = `You are a CLI command expert. Generate exact, precise commands based on user questions and man page documentation.
+
+IMPORTANT RULES:
+1. Provide ONLY the exact commands to run - no extra explanation unless asked
+2. Use the most common, safe, and widely compatible options
+3. If the query is unclear, ask for clarification
+4. Always explain what each command does after showing it
+5. Only use flags that appear in the man pages or that you are certain exist
+6. Give up to 3 alternative commands, best first, formatted as:
+   Command 1: <exact command>
+   Explanation: <what it does and why>
+
+   Command 2: <alternative command>
+   Explanation: <what it does and why>
+
+Be precise and accurate. Double-check your commands.`
+
 // CmdCommand implements /cmd - generates exact commands from natural language queries
-type CmdCommand struct{}
+type CmdCommand struct {
+	// shell runs generated commands with --run or --interactive
+	shell  shellRunner
+	stdout io.Writer
+	stderr io.Writer
+	// input overrides where confirmations are read from
+	input io.Reader
+	// validator checks generated commands against man pages and --help
+	validator *manpage.Validator
+}
 
 // NewCmdCommand creates a new cmd command
 func NewCmdCommand() *CmdCommand {
-	return &CmdCommand{}
+	return &CmdCommand{
+		shell:     runInShell,
+		stdout:    os.Stdout,
+		stderr:    os.Stderr,
+		validator: manpage.NewValidator(),
+	}
 }
 
 // Name returns the command name
@@ -31,7 +64,12 @@ func (c *CmdCommand) Description() string {
 
 // Usage returns usage information
 func (c *CmdCommand) Usage() string {
-	return "/cmd <question>"
+	return "/cmd [--first] [--run|--interactive] <question>"
+}
+
+// FlagNames returns the flags /cmd takes before the question
+func (c *CmdCommand) FlagNames() []string {
+	return []string{"first", "run", "interactive"}
 }
 
 // Category returns the command category
@@ -47,6 +85,9 @@ func (c *CmdCommand) Examples() []string {
 		`/cmd "search for text in all .go files"`,
 		`/cmd "compress a directory into a tar.gz file"`,
 		`/cmd "list all running processes sorted by memory usage"`,
+		`/cmd --first "count lines in all .go files"`,
+		`/cmd --run "show the 10 largest files in this directory"`,
+		`/cmd --interactive "delete local branches already merged into main"`,
 		`scmd /cmd "download a file from a URL"`,
 	}
 }
@@ -112,38 +153,56 @@ func (c *CmdCommand) Execute(
 
 	// Show progress
 	stop := execCtx.UI.Spinner("Generating command")
-	defer stop()
 
 	// Call backend
 	req := &backend.CompletionRequest{
-		Prompt:      prompt,
-		MaxTokens:   1024,
-		Temperature: 0.1, // Low temperature for precise command generation
-		SystemPrompt: `You are a CLI command expert. Generate exact, precise commands based on user questions and man page documentation.
-
-IMPORTANT RULES:
-1. Provide ONLY the exact command to run - no extra explanation unless asked
-2. Use the most common, safe, and widely compatible options
-3. If the query is unclear, ask for clarification
-4. Always explain what the command does after showing it
-5. Include helpful flags and options based on the man pages
-6. Format output as:
-   Command: <exact command>
-
-   Explanation: <what it does and why>
-
-Be precise and accurate. Double-check your commands.`,
+		Prompt:       prompt,
+		MaxTokens:    1024,
+		Temperature:  0.1, // Low temperature for precise command generation
+		SystemPrompt: cmdSystemPrompt,
 	}
 
 	resp, err := execCtx.Backend.Complete(ctx, req)
+	stop()
 	if err != nil {
 		return command.NewErrorResult(
 			fmt.Sprintf("backend error: %v", err),
 		), nil
 	}
 
+	candidates, rejected, err := c.validCandidates(ctx, execCtx, req, resp.Content)
+	if err != nil {
+		return command.NewErrorResult(err.Error()), nil
+	}
+
+	if len(candidates) == 0 {
+		if len(rejected) > 0 {
+			return command.NewErrorResult(
+				"no valid command found: every suggestion used a missing program or an unknown flag",
+				rejectedSummary(rejected)...,
+			), nil
+		}
+		if args.HasFlag("run") || args.HasFlag("interactive") {
+			return command.NewErrorResult(
+				"the response does not contain a command to run",
+				"Run without --run to see the full answer",
+			), nil
+		}
+		// The model asked for clarification; show its answer as is
+		return command.NewResult(formatCmdOutput(resp.Content, query)), nil
+	}
+
+	chosen, ok := c.pick(args, candidates, rejected)
+	if !ok {
+		return command.NewResult("Cancelled."), nil
+	}
+
+	if args.HasFlag("run") || args.HasFlag("interactive") {
+		return c.runGenerated(ctx, args, execCtx, req, chosen.Command)
+	}
+
 	// Format the output nicely
-	output := formatCmdOutput(resp.Content, query)
+	output := formatCmdOutput(chosen.String(), query)
 
 	return command.NewResult(output), nil
 }
@@ -0,0 +1,264 @@
+package builtin
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/scmd/scmd/internal/backend"
+	"github.com/scmd/scmd/internal/command"
+	"github.com/scmd/scmd/internal/utils/manpage"
+)
+
+// cmdCandidate is one command suggested by the model
+type cmdCandidate struct {
+	Command     string
+	Explanation string
+	Check       *manpage.Validation
+}
+
+// String renders the candidate in the format /cmd has always printed
+func (c *cmdCandidate) String() string {
+	if c.Explanation == "" {
+		return "Command: " + c.Command
+	}
+	return fmt.Sprintf("Command: %s\n\nExplanation: %s", c.Command, c.Explanation)
+}
+
+var (
+	commandHeader     = regexp.MustCompile(`^(?:\*\*)?Command(?:\s*#?\d+)?(?:\*\*)?:(?:\*\*)?\s*(.*)$`)
+	explanationHeader = regexp.MustCompile(`^(?:\*\*)?Explanation(?:\*\*)?:(?:\*\*)?\s*(.*)$`)
+)
+
+// parseCandidates reads "Command N:" and "Explanation:" pairs from a model
+// response. The command may also follow its header in a fenced block. A
+// response without headers yields the first fenced block, if any.
+func parseCandidates(response string) []*cmdCandidate {
+	var candidates []*cmdCandidate
+	var current *cmdCandidate
+	var explanation []string
+	inExplanation, inFence, awaitCommand := false, false, false
+
+	finish := func() {
+		if current != nil && current.Command != "" {
+			current.Explanation = strings.TrimSpace(strings.Join(explanation, "\n"))
+			candidates = append(candidates, current)
+		}
+		current, explanation, inExplanation = nil, nil, false
+	}
+
+	for _, raw := range strings.Split(response, "\n") {
+		line := strings.TrimSpace(raw)
+
+		if strings.HasPrefix(line, "```") {
+			inFence = !inFence
+			continue
+		}
+
+		if awaitCommand {
+			if line != "" {
+				current.Command = cleanCommand(line)
+				awaitCommand = false
+			}
+			continue
+		}
+		if inFence {
+			if inExplanation {
+				explanation = append(explanation, raw)
+			}
+			continue
+		}
+
+		if m := commandHeader.FindStringSubmatch(line); m != nil {
+			finish()
+			current = &cmdCandidate{Command: cleanCommand(m[1])}
+			awaitCommand = current.Command == ""
+			continue
+		}
+		if current == nil {
+			continue
+		}
+		if m := explanationHeader.FindStringSubmatch(line); m != nil {
+			inExplanation = true
+			explanation = append(explanation, m[1])
+			continue
+		}
+		if inExplanation {
+			explanation = append(explanation, raw)
+		}
+	}
+	finish()
+
+	if len(candidates) == 0 {
+		if block := firstFencedBlock(response); block != "" {
+			candidates = append(candidates, &cmdCandidate{Command: block})
+		}
+	}
+	return candidates
+}
+
+// cleanCommand strips markdown and prompt decoration from a command line
+func cleanCommand(s string) string {
+	s = strings.TrimSpace(s)
+	s = strings.Trim(s, "`")
+	return strings.TrimPrefix(strings.TrimSpace(s), "$ ")
+}
+
+// firstFencedBlock returns the non-empty lines of the first fenced block
+func firstFencedBlock(response string) string {
+	var block []string
+	inBlock := false
+	for _, l := range strings.Split(response, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(l), "```") {
+			if inBlock {
+				break
+			}
+			inBlock = true
+			continue
+		}
+		if inBlock && strings.TrimSpace(l) != "" {
+			block = append(block, cleanCommand(l))
+		}
+	}
+	return strings.Join(block, "\n")
+}
+
+// rankCandidates validates every candidate and returns the valid ones, fully
+// verified commands first and otherwise in the model's order, along with the
+// rejected ones
+func rankCandidates(v *manpage.Validator, candidates []*cmdCandidate) (valid, rejected []*cmdCandidate) {
+	seen := make(map[string]bool)
+	for _, cand := range candidates {
+		if seen[cand.Command] {
+			continue
+		}
+		seen[cand.Command] = true
+
+		cand.Check = v.Validate(cand.Command)
+		if cand.Check.Valid() {
+			valid = append(valid, cand)
+		} else {
+			rejected = append(rejected, cand)
+		}
+	}
+
+	sort.SliceStable(valid, func(i, j int) bool {
+		return valid[i].Check.Verified() && !valid[j].Check.Verified()
+	})
+	return valid, rejected
+}
+
+// rejectedSummary lists why each rejected candidate was dropped
+func rejectedSummary(rejected []*cmdCandidate) []string {
+	var lines []string
+	for _, r := range rejected {
+		lines = append(lines, fmt.Sprintf("%s: %s", r.Command, strings.Join(r.Check.Problems(), ", ")))
+	}
+	return lines
+}
+
+// validCandidates parses and validates the model's suggestions. If there were
+// suggestions but none is valid, the model is asked once more with the
+// problems it made.
+func (c *CmdCommand) validCandidates(
+	ctx context.Context,
+	execCtx *command.ExecContext,
+	req *backend.CompletionRequest,
+	response string,
+) (valid, rejected []*cmdCandidate, err error) {
+	valid, rejected = rankCandidates(c.validator, parseCandidates(response))
+	if len(valid) > 0 || len(rejected) == 0 {
+		return valid, rejected, nil
+	}
+
+	retry := *req
+	retry.Prompt = fmt.Sprintf("%s\n\nThese commands were rejected:\n- %s\n"+
+		"Suggest commands that only use installed programs and flags documented in their man pages.",
+		req.Prompt, strings.Join(rejectedSummary(rejected), "\n- "))
+
+	stop := execCtx.UI.Spinner("Checking alternatives")
+	resp, err := execCtx.Backend.Complete(ctx, &retry)
+	stop()
+	if err != nil {
+		return nil, nil, fmt.Errorf("backend error: %v", err)
+	}
+
+	valid, retried := rankCandidates(c.validator, parseCandidates(resp.Content))
+	return valid, append(rejected, retried...), nil
+}
+
+// pick chooses the command to use: the top candidate with --first or when it
+// is the only one, otherwise the user's choice. It returns false if the user
+// cancelled.
+func (c *CmdCommand) pick(args *command.Args, valid, rejected []*cmdCandidate) (*cmdCandidate, bool) {
+	if len(rejected) > 0 {
+		fmt.Fprintf(c.stderr, "Dropped %d invalid suggestion(s):\n", len(rejected))
+		for _, line := range rejectedSummary(rejected) {
+			fmt.Fprintf(c.stderr, "  ✗ %s\n", line)
+		}
+	}
+
+	if len(valid) == 1 || args.HasFlag("first") {
+		return valid[0], true
+	}
+
+	input := c.input
+	if input == nil {
+		tty, closeInput, err := promptInput(args)
+		if err != nil {
+			// Nobody to ask, use the best candidate
+			return valid[0], true
+		}
+		defer closeInput()
+		input = tty
+	}
+
+	fmt.Fprintln(c.stderr, "\nCandidates:")
+	for i, cand := range valid {
+		mark := "✓ flags checked"
+		if !cand.Check.Verified() {
+			mark = "? no documentation for " + strings.Join(cand.Check.Undocumented, ", ")
+		}
+		fmt.Fprintf(c.stderr, "  %d. %s   (%s)\n", i+1, cand.Command, mark)
+		if cand.Explanation != "" {
+			fmt.Fprintf(c.stderr, "     %s\n", firstLine(cand.Explanation))
+		}
+	}
+
+	return chooseCandidate(input, c.stderr, valid)
+}
+
+// chooseCandidate reads the user's choice; Enter or end of input picks the
+// first candidate
+func chooseCandidate(input io.Reader, output io.Writer, valid []*cmdCandidate) (*cmdCandidate, bool) {
+	reader := bufio.NewReader(input)
+	for {
+		fmt.Fprintf(output, "\nPick a command [1-%d, Enter for 1, q to quit]: ", len(valid))
+		answer, err := reader.ReadString('\n')
+		answer = strings.TrimSpace(strings.ToLower(answer))
+		if err != nil && answer == "" {
+			return valid[0], true
+		}
+
+		switch answer {
+		case "":
+			return valid[0], true
+		case "q", "quit":
+			return nil, false
+		}
+		if n, convErr := strconv.Atoi(answer); convErr == nil && n >= 1 && n <= len(valid) {
+			return valid[n-1], true
+		}
+		fmt.Fprintf(output, "Invalid choice. Please try again.")
+	}
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return line
+}
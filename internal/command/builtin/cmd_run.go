@@ -0,0 +1,179 @@
+package builtin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/scmd/scmd/internal/backend"
+	"github.com/scmd/scmd/internal/command"
+	"github.com/scmd/scmd/internal/preview"
+	"github.com/scmd/scmd/internal/slash"
+)
+
+// maxErrorOutput is how much of a failed command's stderr is sent back to
+// the model when asking for a correction
+const maxErrorOutput = 4 * 1024
+
+// shellRunner runs a command line, streaming its output, and returns the
+// exit code
+type shellRunner func(ctx context.Context, line string, stdout, stderr io.Writer) (int, error)
+
+// runInShell runs a command line with the user's shell
+func runInShell(ctx context.Context, line string, stdout, stderr io.Writer) (int, error) {
+	shell := os.Getenv("SHELL")
+	if shell == "" {
+		shell = "/bin/sh"
+	}
+
+	c := exec.CommandContext(ctx, shell, "-c", line)
+	c.Stdin = os.Stdin
+	c.Stdout = stdout
+	c.Stderr = stderr
+
+	err := c.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode(), nil
+	}
+	if err != nil {
+		return -1, err
+	}
+	return 0, nil
+}
+
+// tailWriter keeps the last max bytes written to it
+type tailWriter struct {
+	buf []byte
+	max int
+}
+
+func (w *tailWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	if len(w.buf) > w.max {
+		w.buf = w.buf[len(w.buf)-w.max:]
+	}
+	return len(p), nil
+}
+
+func (w *tailWriter) String() string { return string(w.buf) }
+
+// appendHistory records a command that was run for the shell integration to
+// add to the shell history. It does nothing outside the integration.
+func appendHistory(line string) {
+	path := os.Getenv(slash.HistoryFileEnv)
+	if path == "" {
+		return
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	// One history entry per line
+	fmt.Fprintln(f, strings.ReplaceAll(line, "\n", "; "))
+}
+
+// runGenerated previews the generated command and runs it in the user's
+// shell. If it fails, the error output goes back to the model once for a
+// corrected command, which is always confirmed before running.
+func (c *CmdCommand) runGenerated(
+	ctx context.Context,
+	args *command.Args,
+	execCtx *command.ExecContext,
+	req *backend.CompletionRequest,
+	line string,
+) (*command.Result, error) {
+	input := c.input
+	if input == nil {
+		tty, closeInput, err := promptInput(args)
+		if err != nil {
+			return command.NewErrorResult(
+				"cannot prompt for confirmation: no terminal available",
+				"Run without --run and copy the command instead",
+			), nil
+		}
+		defer closeInput()
+		input = tty
+	}
+
+	exitCode := 0
+	for attempt := 0; attempt < 2; attempt++ {
+		buffer := preview.NewBuffer(line)
+		buffer.Input = input
+		buffer.Output = c.stderr
+		buffer.Always = args.HasFlag("interactive") || attempt > 0
+
+		action, final, err := buffer.Show()
+		if err != nil {
+			return command.NewErrorResult(fmt.Sprintf("preview failed: %v", err)), nil
+		}
+
+		switch action {
+		case preview.ActionQuit:
+			return command.NewResult("Cancelled, nothing was run."), nil
+		case preview.ActionDryRun:
+			return command.NewResult(fmt.Sprintf("[DRY RUN] Would execute:\n  %s", final)), nil
+		}
+
+		fmt.Fprintf(c.stderr, "$ %s\n", final)
+		errOut := &tailWriter{max: maxErrorOutput}
+		exitCode, err = c.shell(ctx, final, c.stdout, io.MultiWriter(c.stderr, errOut))
+		if err != nil {
+			return command.NewErrorResult(fmt.Sprintf("run command: %v", err)), nil
+		}
+		if exitCode == 0 {
+			appendHistory(final)
+			return command.NewResult(""), nil
+		}
+
+		fmt.Fprintf(c.stderr, "\n✗ Command exited with status %d\n", exitCode)
+		if attempt > 0 {
+			break
+		}
+
+		corrected, err := c.correct(ctx, execCtx, req, final, exitCode, errOut.String())
+		if err != nil {
+			return command.NewErrorResult(err.Error()), nil
+		}
+		if corrected == "" || corrected == final {
+			break
+		}
+		line = corrected
+	}
+
+	return &command.Result{Success: true, ExitCode: exitCode}, nil
+}
+
+// correct asks the model for a fixed command given the failure output. It
+// returns the best valid suggestion, or "" if there is none.
+func (c *CmdCommand) correct(
+	ctx context.Context,
+	execCtx *command.ExecContext,
+	req *backend.CompletionRequest,
+	line string,
+	exitCode int,
+	errOutput string,
+) (string, error) {
+	retry := *req
+	retry.Prompt = fmt.Sprintf("%s\n\nThe command\n  %s\nexited with status %d and this error output:\n%s\n"+
+		"Provide a corrected command in the same format.",
+		req.Prompt, line, exitCode, strings.TrimSpace(errOutput))
+
+	stop := execCtx.UI.Spinner("Asking for a corrected command")
+	resp, err := execCtx.Backend.Complete(ctx, &retry)
+	stop()
+	if err != nil {
+		return "", fmt.Errorf("backend error: %v", err)
+	}
+
+	valid, _ := rankCandidates(c.validator, parseCandidates(resp.Content))
+	if len(valid) == 0 {
+		return "", nil
+	}
+	return valid[0].Command, nil
+}
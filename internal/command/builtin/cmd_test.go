@@ -0,0 +1,261 @@
+package builtin
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/scmd/scmd/internal/command"
+	"github.com/scmd/scmd/internal/slash"
+	"github.com/scmd/scmd/internal/utils/manpage"
+	"github.com/scmd/scmd/tests/testutil"
+)
+
+// fakeShell records the commands it is asked to run and returns the given
+// exit codes in order
+type fakeShell struct {
+	codes []int
+	ran   []string
+}
+
+func (f *fakeShell) run(_ context.Context, line string, _, stderr io.Writer) (int, error) {
+	f.ran = append(f.ran, line)
+	code := f.codes[len(f.ran)-1]
+	if code != 0 {
+		io.WriteString(stderr, "zsh: command not found: lsx\n")
+	}
+	return code, nil
+}
+
+// testValidator knows only the given programs; an empty documentation
+// string means the program is installed but undocumented. A nil map accepts
+// every program.
+func testValidator(installed map[string]string) *manpage.Validator {
+	return &manpage.Validator{
+		LookPath: func(file string) (string, error) {
+			if _, ok := installed[file]; ok || installed == nil {
+				return "/usr/bin/" + file, nil
+			}
+			return "", fmt.Errorf("%s: not found", file)
+		},
+		Sources: []func(program, sub string) string{
+			func(program, sub string) string { return installed[program] },
+		},
+	}
+}
+
+func newTestCmd(shell *fakeShell, input string) *CmdCommand {
+	return &CmdCommand{
+		shell:     shell.run,
+		stdout:    io.Discard,
+		stderr:    &bytes.Buffer{},
+		input:     strings.NewReader(input),
+		validator: testValidator(nil),
+	}
+}
+
+func TestParseCandidates(t *testing.T) {
+	response := "Command 1: `find . -mtime -1`\nExplanation: files changed today\nwithin this directory\n\n" +
+		"**Command 2:**\n```bash\n$ ls -lt | head\n```\nExplanation: newest files first\n"
+
+	cands := parseCandidates(response)
+	require.Len(t, cands, 2)
+	assert.Equal(t, "find . -mtime -1", cands[0].Command)
+	assert.Equal(t, "files changed today\nwithin this directory", cands[0].Explanation)
+	assert.Equal(t, "ls -lt | head", cands[1].Command)
+	assert.Equal(t, "newest files first", cands[1].Explanation)
+
+	cands = parseCandidates("Command: ls -la\n\nExplanation: lists files")
+	require.Len(t, cands, 1)
+	assert.Equal(t, "Command: ls -la\n\nExplanation: lists files", cands[0].String())
+
+	cands = parseCandidates("Try this:\n```\ndu -sh *\n```")
+	require.Len(t, cands, 1)
+	assert.Equal(t, "du -sh *", cands[0].Command)
+
+	assert.Empty(t, parseCandidates("Could you clarify what you mean?"))
+}
+
+func TestRankCandidates(t *testing.T) {
+	v := testValidator(map[string]string{"ls": "-l -a -t", "jq": ""})
+
+	valid, rejected := rankCandidates(v, []*cmdCandidate{
+		{Command: "jq -r .name"},
+		{Command: "ls --hidden"},
+		{Command: "ls -lt"},
+		{Command: "exa -l"},
+		{Command: "ls -lt"},
+	})
+
+	require.Len(t, valid, 2)
+	assert.Equal(t, "ls -lt", valid[0].Command, "verified commands rank first")
+	assert.Equal(t, "jq -r .name", valid[1].Command)
+
+	require.Len(t, rejected, 2)
+	assert.Equal(t, []string{
+		"ls --hidden: ls has no --hidden option",
+		"exa -l: exa is not installed",
+	}, rejectedSummary(rejected))
+}
+
+func TestCmdCommand_QuestionMentioningAFlag(t *testing.T) {
+	args := slash.ParseArgs(strings.Fields("--first how do I use git commit --amend"), (&CmdCommand{}).FlagNames())
+	assert.True(t, args.HasFlag("first"))
+	assert.False(t, args.HasFlag("amend"))
+	assert.Equal(t, "how do I use git commit --amend", strings.Join(args.Positional, " "))
+}
+
+func TestCmdCommand_RunRecordsHistory(t *testing.T) {
+	history := filepath.Join(t.TempDir(), "history")
+	t.Setenv(slash.HistoryFileEnv, history)
+
+	be := testutil.NewMockBackend()
+	be.SetResponse("Command: ls -la\n\nExplanation: lists files")
+
+	shell := &fakeShell{codes: []int{0}}
+	cmd := newTestCmd(shell, "")
+
+	args := command.NewArgs()
+	args.Positional = []string{"show hidden entries here"}
+	args.Flags["run"] = true
+
+	result, err := cmd.Execute(context.Background(), args, &command.ExecContext{Backend: be, UI: testutil.NewMockUI()})
+	require.NoError(t, err)
+	assert.True(t, result.Success)
+	assert.Equal(t, 0, result.ExitCode)
+	assert.Equal(t, []string{"ls -la"}, shell.ran)
+
+	data, err := os.ReadFile(history)
+	require.NoError(t, err)
+	assert.Equal(t, "ls -la\n", string(data))
+}
+
+func TestCmdCommand_RunRetriesWithErrorOutput(t *testing.T) {
+	be := &sequenceBackend{MockBackend: testutil.NewMockBackend(), responses: []string{
+		"Command: lsx -la",
+		"Command: ls -la",
+	}}
+
+	shell := &fakeShell{codes: []int{127, 0}}
+	// The corrected command is always confirmed
+	cmd := newTestCmd(shell, "\n")
+
+	args := command.NewArgs()
+	args.Positional = []string{"show hidden entries here"}
+	args.Flags["run"] = true
+
+	result, err := cmd.Execute(context.Background(), args, &command.ExecContext{Backend: be, UI: testutil.NewMockUI()})
+	require.NoError(t, err)
+	assert.True(t, result.Success)
+	assert.Equal(t, 0, result.ExitCode)
+	assert.Equal(t, []string{"lsx -la", "ls -la"}, shell.ran)
+
+	require.Len(t, be.prompts, 2)
+	assert.Contains(t, be.prompts[1], "exited with status 127")
+	assert.Contains(t, be.prompts[1], "command not found: lsx")
+}
+
+func TestCmdCommand_RunGivesUpAfterOneCorrection(t *testing.T) {
+	be := &sequenceBackend{MockBackend: testutil.NewMockBackend(), responses: []string{
+		"Command: lsx",
+		"Command: lsy",
+	}}
+
+	shell := &fakeShell{codes: []int{127, 127}}
+	cmd := newTestCmd(shell, "\n")
+
+	args := command.NewArgs()
+	args.Positional = []string{"show entries here"}
+	args.Flags["run"] = true
+
+	result, err := cmd.Execute(context.Background(), args, &command.ExecContext{Backend: be, UI: testutil.NewMockUI()})
+	require.NoError(t, err)
+	assert.Equal(t, 127, result.ExitCode)
+	assert.Equal(t, []string{"lsx", "lsy"}, shell.ran)
+	assert.Len(t, be.prompts, 2)
+}
+
+func TestCmdCommand_InteractiveDryRun(t *testing.T) {
+	be := testutil.NewMockBackend()
+	be.SetResponse("Command: ls -la")
+
+	shell := &fakeShell{}
+	cmd := newTestCmd(shell, "d\n")
+
+	args := command.NewArgs()
+	args.Positional = []string{"show entries here"}
+	args.Flags["interactive"] = true
+
+	result, err := cmd.Execute(context.Background(), args, &command.ExecContext{Backend: be, UI: testutil.NewMockUI()})
+	require.NoError(t, err)
+	assert.Contains(t, result.Output, "[DRY RUN] Would execute:\n  ls -la")
+	assert.Empty(t, shell.ran)
+}
+
+func TestCmdCommand_PickCandidate(t *testing.T) {
+	be := testutil.NewMockBackend()
+	be.SetResponse("Command 1: ls -la\nExplanation: long listing\n\nCommand 2: ls -lt\nExplanation: newest first")
+
+	args := command.NewArgs()
+	args.Positional = []string{"show entries here"}
+
+	cmd := newTestCmd(&fakeShell{}, "2\n")
+	result, err := cmd.Execute(context.Background(), args, &command.ExecContext{Backend: be, UI: testutil.NewMockUI()})
+	require.NoError(t, err)
+	assert.Contains(t, result.Output, "Command: ls -lt\n\nExplanation: newest first")
+
+	args.Flags["first"] = true
+	cmd = newTestCmd(&fakeShell{}, "")
+	result, err = cmd.Execute(context.Background(), args, &command.ExecContext{Backend: be, UI: testutil.NewMockUI()})
+	require.NoError(t, err)
+	assert.Contains(t, result.Output, "Command: ls -la")
+}
+
+func TestCmdCommand_RetriesWhenEveryCandidateIsInvalid(t *testing.T) {
+	be := &sequenceBackend{MockBackend: testutil.NewMockBackend(), responses: []string{
+		"Command 1: ls --hidden\n\nCommand 2: exa -a",
+		"Command 1: ls -a",
+	}}
+
+	cmd := newTestCmd(&fakeShell{}, "")
+	cmd.validator = testValidator(map[string]string{"ls": "-a  all\n-l  long"})
+
+	args := command.NewArgs()
+	args.Positional = []string{"show entries here"}
+
+	result, err := cmd.Execute(context.Background(), args, &command.ExecContext{Backend: be, UI: testutil.NewMockUI()})
+	require.NoError(t, err)
+	assert.True(t, result.Success)
+	assert.Contains(t, result.Output, "Command: ls -a")
+
+	require.Len(t, be.prompts, 2)
+	assert.Contains(t, be.prompts[1], "ls --hidden: ls has no --hidden option")
+	assert.Contains(t, be.prompts[1], "exa -a: exa is not installed")
+}
+
+func TestCmdCommand_NoValidCandidate(t *testing.T) {
+	be := &sequenceBackend{MockBackend: testutil.NewMockBackend(), responses: []string{
+		"Command 1: ls --hidden",
+		"Command 1: ls --secret",
+	}}
+
+	cmd := newTestCmd(&fakeShell{}, "")
+	cmd.validator = testValidator(map[string]string{"ls": "-a  all"})
+
+	args := command.NewArgs()
+	args.Positional = []string{"show entries here"}
+
+	result, err := cmd.Execute(context.Background(), args, &command.ExecContext{Backend: be, UI: testutil.NewMockUI()})
+	require.NoError(t, err)
+	assert.False(t, result.Success)
+	assert.Contains(t, result.Error, "no valid command found")
+	assert.Contains(t, result.Suggestions, "ls --secret: ls has no --secret option")
+}
@@ -93,6 +93,16 @@ func (c *ConfigCommand) showAllConfig(execCtx *command.ExecContext) (*command.Re
 	sb.WriteString(fmt.Sprintf("    directory: %s\n", cfg.Models.Directory))
 	sb.WriteString(fmt.Sprintf("    auto_download: %t\n", cfg.Models.AutoDownload))
 
+	sb.WriteString("\n  exec:\n")
+	sb.WriteString(fmt.Sprintf("    env: %s\n", strings.Join(cfg.Exec.Env, ", ")))
+	sb.WriteString(fmt.Sprintf("    timeout: %ds\n", cfg.Exec.Timeout))
+	sb.WriteString(fmt.Sprintf("    max_output: %d\n", cfg.Exec.MaxOutput))
+	sb.WriteString(fmt.Sprintf("    cpu: %ds\n", cfg.Exec.CPU))
+	sb.WriteString(fmt.Sprintf("    memory: %dMB\n", cfg.Exec.Memory))
+	sb.WriteString(fmt.Sprintf("    no_network: %t\n", cfg.Exec.NoNetwork))
+	sb.WriteString(fmt.Sprintf("    require_isolation: %t\n", cfg.Exec.RequireIsolation))
+	sb.WriteString(fmt.Sprintf("    audit: %t\n", cfg.Exec.Audit))
+
 	sb.WriteString(fmt.Sprintf("\nConfig file: %s\n", config.ConfigPath()))
 
 	execCtx.UI.Write(sb.String())
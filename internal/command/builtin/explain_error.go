@@ -0,0 +1,466 @@
+package builtin
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/scmd/scmd/internal/backend"
+	"github.com/scmd/scmd/internal/command"
+)
+
+// maxErrorLocations limits how many referenced source locations are
+// pulled into the prompt
+const maxErrorLocations = 5
+
+// maxSnippetRead caps how much of a referenced file is read; locations
+// past it get no snippet
+const maxSnippetRead = 1 << 20
+
+// ExplainErrorCommand implements /explain-error
+type ExplainErrorCommand struct{}
+
+// NewExplainErrorCommand creates a new explain-error command
+func NewExplainErrorCommand() *ExplainErrorCommand {
+	return &ExplainErrorCommand{}
+}
+
+// Name returns the command name
+func (c *ExplainErrorCommand) Name() string { return "explain-error" }
+
+// Aliases returns command aliases
+func (c *ExplainErrorCommand) Aliases() []string { return []string{"fix", "err"} }
+
+// Description returns the command description
+func (c *ExplainErrorCommand) Description() string {
+	return "Explain compiler errors, panics, and stack traces and propose a fix"
+}
+
+// Usage returns usage information
+func (c *ExplainErrorCommand) Usage() string {
+	return "/explain-error <error text|file> [--context-lines N] [--apply]"
+}
+
+// Category returns the command category
+func (c *ExplainErrorCommand) Category() command.Category { return command.CategoryCode }
+
+// RequiresBackend returns true
+func (c *ExplainErrorCommand) RequiresBackend() bool { return true }
+
+// Examples returns example usages
+func (c *ExplainErrorCommand) Examples() []string {
+	return []string{
+		"go build ./... 2>&1 | scmd /fix",
+		"python app.py 2>&1 | scmd explain-error",
+		"scmd /err \"undefined: foo\"",
+		"scmd explain-error crash.log --context-lines 10",
+		"go test ./... 2>&1 | scmd fix --apply",
+	}
+}
+
+// Validate validates arguments
+func (c *ExplainErrorCommand) Validate(args *command.Args) error {
+	stdin, hasStdin := args.Options["stdin"]
+	stdinEmpty := !hasStdin || strings.TrimSpace(stdin) == ""
+
+	if len(args.Positional) == 0 && stdinEmpty {
+		return fmt.Errorf("no error output provided\n\nUsage:\n  go build 2>&1 | scmd /fix\n  scmd explain-error crash.log\n  scmd /err \"error message\"")
+	}
+
+	if n := args.GetOption("context-lines"); n != "" {
+		if v, err := strconv.Atoi(n); err != nil || v < 0 {
+			return fmt.Errorf("invalid context-lines '%s': must be a non-negative number of lines", n)
+		}
+	}
+
+	return nil
+}
+
+// Execute runs the explain-error command
+func (c *ExplainErrorCommand) Execute(
+	ctx context.Context,
+	args *command.Args,
+	execCtx *command.ExecContext,
+) (*command.Result, error) {
+	if err := c.Validate(args); err != nil {
+		return command.NewErrorResult(err.Error()), nil
+	}
+
+	var errorText string
+	if stdin, ok := args.Options["stdin"]; ok && strings.TrimSpace(stdin) != "" {
+		errorText = stdin
+	} else if len(args.Positional) == 1 && isFile(args.Positional[0]) {
+		data, err := os.ReadFile(args.Positional[0])
+		if err != nil {
+			return command.NewErrorResult(
+				fmt.Sprintf("cannot read file: %v", err),
+				"Check the file path",
+			), nil
+		}
+		errorText = string(data)
+	} else {
+		errorText = strings.Join(args.Positional, " ")
+	}
+
+	contextLines := 5
+	if n := args.GetOption("context-lines"); n != "" {
+		contextLines, _ = strconv.Atoi(n)
+	}
+
+	report := ParseErrorOutput(errorText)
+	snippets := collectSourceSnippets(report.Locations, contextLines, sourceRoots(ctx))
+
+	if args.HasFlag("apply") {
+		return c.applyFix(ctx, args, execCtx, errorText, snippets)
+	}
+
+	if execCtx.Backend == nil {
+		return command.NewErrorResult(
+			"no backend available",
+			"Configure a backend with 'scmd config'",
+		), nil
+	}
+
+	stop := execCtx.UI.Spinner("Diagnosing")
+	defer stop()
+
+	req := &backend.CompletionRequest{
+		Prompt:       buildExplainErrorPrompt(errorText, report, snippets),
+		SystemPrompt: explainErrorSystemPrompt,
+		MaxTokens:    2048,
+		Temperature:  0.2,
+	}
+
+	resp, err := execCtx.Backend.Complete(ctx, req)
+	if err != nil {
+		return command.NewErrorResult(
+			fmt.Sprintf("backend error: %v", err),
+		), nil
+	}
+
+	return command.NewResult(resp.Content), nil
+}
+
+// applyFix asks for a patch fixing the error in the files it points to
+func (c *ExplainErrorCommand) applyFix(
+	ctx context.Context,
+	args *command.Args,
+	execCtx *command.ExecContext,
+	errorText string,
+	snippets []sourceSnippet,
+) (*command.Result, error) {
+	var paths []string
+	for _, s := range snippets {
+		paths = append(paths, s.Location.File)
+	}
+
+	files := patchableFiles(paths)
+	if len(files) == 0 {
+		return command.NewErrorResult(
+			"no source files from the error output were found in the working directory",
+			"Run the command from the project root",
+			"Drop --apply to get an explanation instead",
+		), nil
+	}
+
+	return proposeAndApply(ctx, args, execCtx, &patchRequest{
+		Task: fmt.Sprintf("Fix the root cause of this error:\n\n```\n%s\n```",
+			truncateMiddle(strings.TrimSpace(errorText), maxSummarizeInput/2)),
+		Files:       files,
+		Description: "explain-error --apply",
+	})
+}
+
+const explainErrorSystemPrompt = `You are an expert debugger. Given error output and the source it points to:
+1. State the root cause in one or two sentences.
+2. Explain why it happens, referencing the exact file and line.
+3. Propose a concrete fix as corrected code or a unified diff.
+4. Mention how to verify the fix.
+
+Only reference source lines that were provided. If the cause cannot be
+determined from the information given, say what additional information is needed.
+Format your response in markdown with the headings: Cause, Explanation, Fix, Verify.`
+
+// ErrorKind classifies error output
+type ErrorKind string
+
+const (
+	ErrorKindCompiler   ErrorKind = "compiler"
+	ErrorKindRuntime    ErrorKind = "runtime"
+	ErrorKindStackTrace ErrorKind = "stack trace"
+	ErrorKindUnknown    ErrorKind = "unknown"
+)
+
+// ErrorLocation is a source location referenced by error output
+type ErrorLocation struct {
+	File    string
+	Line    int
+	Column  int
+	Message string
+}
+
+// ErrorReport is the parsed form of error output
+type ErrorReport struct {
+	Kind      ErrorKind
+	Language  string
+	Summary   string
+	Locations []ErrorLocation
+}
+
+var (
+	// file.go:12:5: message (go, gcc, clang, eslint unix format, many others)
+	compilerLocPattern = regexp.MustCompile(`^\s*([^\s:()'"]+\.[A-Za-z0-9]+):(\d+)(?::(\d+))?:?\s*(.*)$`)
+	// src/a.ts(10,5): error TS2304: message
+	tscLocPattern = regexp.MustCompile(`^\s*([^\s()]+\.[A-Za-z0-9]+)\((\d+),(\d+)\):\s*(.*)$`)
+	// --> src/main.rs:2:5
+	rustLocPattern = regexp.MustCompile(`^\s*-->\s*([^\s:]+):(\d+):(\d+)`)
+	// File "app.py", line 10, in main
+	pythonLocPattern = regexp.MustCompile(`^\s*File "([^"]+)", line (\d+)`)
+	// at com.example.Foo.bar(Foo.java:42)
+	javaLocPattern = regexp.MustCompile(`^\s*at\s+[\w.$<>]+\(([\w.$-]+\.(?:java|kt|scala|groovy)):(\d+)\)`)
+	// at fn (/path/app.js:10:15) or at /path/app.js:10:15
+	nodeLocPattern = regexp.MustCompile(`^\s*at\s+(?:.*?\()?((?:file://)?[^\s()]+\.[cm]?[jt]sx?):(\d+):(\d+)\)?`)
+	// \t/path/main.go:42 +0x1d
+	goStackLocPattern = regexp.MustCompile(`^\s+(/?[^\s:]+\.go):(\d+)(?:\s+\+0x[0-9a-f]+)?$`)
+
+	errorLinePattern = regexp.MustCompile(`(?i)\b(error|exception|panic|fatal|undefined|cannot|failed)\b`)
+)
+
+// ParseErrorOutput extracts the kind of error and referenced source locations
+func ParseErrorOutput(text string) *ErrorReport {
+	report := &ErrorReport{Kind: ErrorKindUnknown}
+	seen := make(map[string]bool)
+
+	add := func(loc ErrorLocation) {
+		loc.File = strings.TrimPrefix(loc.File, "file://")
+		key := fmt.Sprintf("%s:%d", loc.File, loc.Line)
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+		report.Locations = append(report.Locations, loc)
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+
+		switch {
+		case strings.HasPrefix(trimmed, "Traceback (most recent call last)"):
+			report.Kind, report.Language = ErrorKindStackTrace, "python"
+		case strings.HasPrefix(trimmed, "panic:"), strings.HasPrefix(trimmed, "goroutine ") && strings.HasSuffix(trimmed, ":"):
+			report.Kind, report.Language = ErrorKindStackTrace, "go"
+		case strings.HasPrefix(trimmed, "Exception in thread"), strings.HasPrefix(trimmed, "Caused by:"):
+			report.Kind, report.Language = ErrorKindStackTrace, "java"
+		case strings.HasPrefix(trimmed, "thread '") && strings.Contains(trimmed, "panicked at"):
+			report.Kind, report.Language = ErrorKindStackTrace, "rust"
+		}
+
+		if m := pythonLocPattern.FindStringSubmatch(line); m != nil {
+			add(ErrorLocation{File: m[1], Line: atoi(m[2])})
+			continue
+		}
+		if m := javaLocPattern.FindStringSubmatch(line); m != nil {
+			add(ErrorLocation{File: m[1], Line: atoi(m[2])})
+			if report.Kind == ErrorKindUnknown {
+				report.Kind, report.Language = ErrorKindStackTrace, "java"
+			}
+			continue
+		}
+		if m := nodeLocPattern.FindStringSubmatch(line); m != nil {
+			add(ErrorLocation{File: m[1], Line: atoi(m[2]), Column: atoi(m[3])})
+			if report.Kind == ErrorKindUnknown {
+				report.Kind, report.Language = ErrorKindStackTrace, "javascript"
+			}
+			continue
+		}
+		if m := goStackLocPattern.FindStringSubmatch(line); m != nil {
+			add(ErrorLocation{File: m[1], Line: atoi(m[2])})
+			continue
+		}
+		if m := rustLocPattern.FindStringSubmatch(line); m != nil {
+			add(ErrorLocation{File: m[1], Line: atoi(m[2]), Column: atoi(m[3])})
+			if report.Kind == ErrorKindUnknown {
+				report.Kind, report.Language = ErrorKindCompiler, "rust"
+			}
+			continue
+		}
+		if m := tscLocPattern.FindStringSubmatch(line); m != nil {
+			add(ErrorLocation{File: m[1], Line: atoi(m[2]), Column: atoi(m[3]), Message: m[4]})
+			if report.Kind == ErrorKindUnknown {
+				report.Kind, report.Language = ErrorKindCompiler, "typescript"
+			}
+			continue
+		}
+		if m := compilerLocPattern.FindStringSubmatch(line); m != nil && !strings.HasPrefix(m[1], "http") {
+			add(ErrorLocation{File: m[1], Line: atoi(m[2]), Column: atoi(m[3]), Message: m[4]})
+			if report.Kind == ErrorKindUnknown {
+				report.Kind = ErrorKindCompiler
+				report.Language = detectLanguage(m[1], "")
+			}
+			continue
+		}
+
+		if report.Summary == "" && errorLinePattern.MatchString(trimmed) {
+			report.Summary = trimmed
+		}
+	}
+
+	if report.Kind == ErrorKindUnknown && report.Summary != "" {
+		report.Kind = ErrorKindRuntime
+	}
+	if report.Summary == "" && len(report.Locations) > 0 {
+		report.Summary = report.Locations[0].Message
+	}
+
+	return report
+}
+
+// sourceSnippet is a window of source lines around an error location
+type sourceSnippet struct {
+	Location ErrorLocation
+	Start    int
+	Lines    []string
+}
+
+// sourceRoots returns the directories error locations may be read from:
+// the working directory and the root of the git repository it is in
+func sourceRoots(ctx context.Context) []string {
+	var roots []string
+	if cwd, err := os.Getwd(); err == nil {
+		roots = append(roots, cwd)
+	}
+	if root, err := runGit(ctx, ".", "rev-parse", "--show-toplevel"); err == nil {
+		roots = append(roots, strings.TrimSpace(root))
+	}
+	return roots
+}
+
+// collectSourceSnippets reads the referenced lines for locations that exist
+// under one of roots. The error text is untrusted, so paths elsewhere, such
+// as ~/.ssh/id_rsa, are never read and sent to the backend.
+func collectSourceSnippets(locations []ErrorLocation, contextLines int, roots []string) []sourceSnippet {
+	var snippets []sourceSnippet
+	for _, loc := range locations {
+		if len(snippets) >= maxErrorLocations {
+			break
+		}
+		if loc.Line <= 0 || !isFile(loc.File) || !underRoots(loc.File, roots) {
+			continue
+		}
+
+		data, err := readHead(loc.File, maxSnippetRead)
+		if err != nil {
+			continue
+		}
+		lines := strings.Split(string(data), "\n")
+		if len(data) == maxSnippetRead {
+			// The last line was cut off
+			lines = lines[:len(lines)-1]
+		}
+		if loc.Line > len(lines) {
+			continue
+		}
+
+		start := loc.Line - contextLines
+		if start < 1 {
+			start = 1
+		}
+		end := loc.Line + contextLines
+		if end > len(lines) {
+			end = len(lines)
+		}
+
+		snippets = append(snippets, sourceSnippet{
+			Location: loc,
+			Start:    start,
+			Lines:    lines[start-1 : end],
+		})
+	}
+	return snippets
+}
+
+// underRoots reports whether path, with symlinks resolved, is inside one of roots
+func underRoots(path string, roots []string) bool {
+	real, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return false
+	}
+	real, err = filepath.Abs(real)
+	if err != nil {
+		return false
+	}
+	for _, root := range roots {
+		rootReal, err := filepath.EvalSymlinks(root)
+		if err != nil {
+			continue
+		}
+		rel, err := filepath.Rel(rootReal, real)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// readHead reads at most limit bytes from the start of a file
+func readHead(path string, limit int64) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(io.LimitReader(f, limit))
+}
+
+// buildExplainErrorPrompt builds the user prompt for an error diagnosis
+func buildExplainErrorPrompt(errorText string, report *ErrorReport, snippets []sourceSnippet) string {
+	var sb strings.Builder
+
+	kind := string(report.Kind)
+	if report.Language != "" && report.Language != "auto-detect" {
+		kind = report.Language + " " + kind
+	}
+	sb.WriteString(fmt.Sprintf("Diagnose the following %s error and propose a fix.\n\n", kind))
+
+	sb.WriteString("Error output:\n```\n")
+	sb.WriteString(truncateMiddle(strings.TrimSpace(errorText), maxSummarizeInput/2))
+	sb.WriteString("\n```\n")
+
+	if len(snippets) > 0 {
+		sb.WriteString("\nReferenced source (the error line is marked with >):\n")
+		for _, s := range snippets {
+			lang := detectLanguage(s.Location.File, "")
+			if lang == "auto-detect" {
+				lang = ""
+			}
+			sb.WriteString(fmt.Sprintf("\n%s:%d\n```%s\n", s.Location.File, s.Location.Line, lang))
+			for i, line := range s.Lines {
+				n := s.Start + i
+				marker := " "
+				if n == s.Location.Line {
+					marker = ">"
+				}
+				sb.WriteString(fmt.Sprintf("%s%5d | %s\n", marker, n, line))
+			}
+			sb.WriteString("```\n")
+		}
+	} else if len(report.Locations) > 0 {
+		sb.WriteString("\nThe referenced files are not available locally; base the fix on the error output alone.\n")
+	}
+
+	return sb.String()
+}
+
+func atoi(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
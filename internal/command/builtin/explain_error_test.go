@@ -0,0 +1,184 @@
+package builtin
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/scmd/scmd/internal/command"
+	"github.com/scmd/scmd/tests/testutil"
+)
+
+func TestExplainErrorCommand_Aliases(t *testing.T) {
+	cmd := NewExplainErrorCommand()
+
+	assert.Equal(t, "explain-error", cmd.Name())
+	assert.Contains(t, cmd.Aliases(), "fix")
+	assert.Contains(t, cmd.Aliases(), "err")
+}
+
+func TestExplainErrorCommand_Validate(t *testing.T) {
+	cmd := NewExplainErrorCommand()
+
+	args := command.NewArgs()
+	assert.Error(t, cmd.Validate(args))
+
+	args.Options["stdin"] = "panic: boom"
+	assert.NoError(t, cmd.Validate(args))
+
+	args.Options["context-lines"] = "-1"
+	assert.Error(t, cmd.Validate(args))
+}
+
+func TestParseErrorOutput(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		kind     ErrorKind
+		language string
+		file     string
+		line     int
+	}{
+		{
+			name:     "go compiler",
+			input:    "# example\n./main.go:12:5: undefined: foo",
+			kind:     ErrorKindCompiler,
+			language: "go",
+			file:     "./main.go",
+			line:     12,
+		},
+		{
+			name: "go panic",
+			input: `panic: runtime error: index out of range [3] with length 3
+
+goroutine 1 [running]:
+main.main()
+	/home/user/app/main.go:8 +0x1d
+exit status 2`,
+			kind:     ErrorKindStackTrace,
+			language: "go",
+			file:     "/home/user/app/main.go",
+			line:     8,
+		},
+		{
+			name: "python traceback",
+			input: `Traceback (most recent call last):
+  File "app.py", line 10, in <module>
+    main()
+ZeroDivisionError: division by zero`,
+			kind:     ErrorKindStackTrace,
+			language: "python",
+			file:     "app.py",
+			line:     10,
+		},
+		{
+			name: "java exception",
+			input: `Exception in thread "main" java.lang.NullPointerException
+	at com.example.App.run(App.java:42)
+	at com.example.App.main(App.java:10)`,
+			kind:     ErrorKindStackTrace,
+			language: "java",
+			file:     "App.java",
+			line:     42,
+		},
+		{
+			name: "node stack",
+			input: `TypeError: Cannot read properties of undefined
+    at handler (/srv/app/index.js:15:7)
+    at process.processTicksAndRejections (node:internal/process/task_queues:95:5)`,
+			kind:     ErrorKindStackTrace,
+			language: "javascript",
+			file:     "/srv/app/index.js",
+			line:     15,
+		},
+		{
+			name: "rust compiler",
+			input: `error[E0425]: cannot find value ` + "`x`" + ` in this scope
+ --> src/main.rs:2:13`,
+			kind:     ErrorKindCompiler,
+			language: "rust",
+			file:     "src/main.rs",
+			line:     2,
+		},
+		{
+			name:     "typescript",
+			input:    "src/a.ts(10,5): error TS2304: Cannot find name 'foo'.",
+			kind:     ErrorKindCompiler,
+			language: "typescript",
+			file:     "src/a.ts",
+			line:     10,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := ParseErrorOutput(tt.input)
+			assert.Equal(t, tt.kind, report.Kind)
+			assert.Equal(t, tt.language, report.Language)
+			require.NotEmpty(t, report.Locations)
+			assert.Equal(t, tt.file, report.Locations[0].File)
+			assert.Equal(t, tt.line, report.Locations[0].Line)
+		})
+	}
+}
+
+func TestParseErrorOutput_RuntimeWithoutLocation(t *testing.T) {
+	report := ParseErrorOutput("Error: connect ECONNREFUSED 127.0.0.1:5432")
+	assert.Equal(t, ErrorKindRuntime, report.Kind)
+	assert.Empty(t, report.Locations)
+	assert.Contains(t, report.Summary, "ECONNREFUSED")
+}
+
+func TestExplainErrorCommand_IncludesSource(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "main.go")
+	require.NoError(t, os.WriteFile(src, []byte("package main\n\nfunc main() {\n\tfoo()\n}\n"), 0644))
+
+	report := ParseErrorOutput(src + ":4:2: undefined: foo")
+	snippets := collectSourceSnippets(report.Locations, 1, []string{dir})
+	require.Len(t, snippets, 1)
+	assert.Equal(t, 3, snippets[0].Start)
+
+	prompt := buildExplainErrorPrompt("undefined: foo", report, snippets)
+	assert.Contains(t, prompt, ">    4 | \tfoo()")
+	assert.Contains(t, prompt, "go compiler")
+}
+
+func TestExplainErrorCommand_OnlyReadsSourceUnderRoots(t *testing.T) {
+	root := t.TempDir()
+	outside := filepath.Join(t.TempDir(), "secret.txt")
+	require.NoError(t, os.WriteFile(outside, []byte("token=abc\n"), 0600))
+	require.NoError(t, os.Symlink(outside, filepath.Join(root, "link.txt")))
+
+	// Past the read cap, the location gets no snippet
+	big := filepath.Join(root, "big.go")
+	require.NoError(t, os.WriteFile(big, []byte(strings.Repeat("x\n", maxSnippetRead)), 0644))
+
+	report := ParseErrorOutput(outside + ":1:1: error: bad\n" +
+		filepath.Join(root, "link.txt") + ":1:1: error: bad\n" +
+		big + ":1000000:1: error: bad")
+	require.Len(t, report.Locations, 3)
+	assert.Empty(t, collectSourceSnippets(report.Locations, 1, []string{root}))
+
+	report = ParseErrorOutput(big + ":10:1: error: bad")
+	assert.Len(t, collectSourceSnippets(report.Locations, 1, []string{root}), 1)
+}
+
+func TestExplainErrorCommand_Execute(t *testing.T) {
+	cmd := NewExplainErrorCommand()
+	be := testutil.NewMockBackend()
+	be.SetResponse("## Cause\nfoo is undefined")
+
+	args := command.NewArgs()
+	args.Options["stdin"] = "./missing.go:1:1: undefined: foo"
+
+	result, err := cmd.Execute(context.Background(), args, &command.ExecContext{UI: testutil.NewMockUI(), Backend: be})
+	require.NoError(t, err)
+	assert.True(t, result.Success)
+	assert.Contains(t, result.Output, "Cause")
+}
@@ -14,6 +14,18 @@ type HelpCommand struct {
 	registry *command.Registry
 }
 
+// scoped is implemented by commands that know where they were loaded from,
+// such as project commands from a .scmd directory
+type scoped interface {
+	Scope() string
+}
+
+// fromProject reports whether a command comes from the current project
+func fromProject(cmd command.Command) bool {
+	s, ok := cmd.(scoped)
+	return ok && s.Scope() == "project"
+}
+
 // NewHelpCommand creates a new help command
 func NewHelpCommand(registry *command.Registry) *HelpCommand {
 	return &HelpCommand{registry: registry}
@@ -76,7 +88,12 @@ func (c *HelpCommand) showAllHelp(execCtx *command.ExecContext) (*command.Result
 	}
 
 	for _, cat := range categories {
-		cmds := c.registry.ListByCategory(cat)
+		var cmds []command.Command
+		for _, cmd := range c.registry.ListByCategory(cat) {
+			if !fromProject(cmd) {
+				cmds = append(cmds, cmd)
+			}
+		}
 		if len(cmds) == 0 {
 			continue
 		}
@@ -87,11 +104,26 @@ func (c *HelpCommand) showAllHelp(execCtx *command.ExecContext) (*command.Result
 		}
 	}
 
+	// Project commands are listed whatever their category
+	var project []command.Command
+	for _, cmd := range c.registry.List() {
+		if fromProject(cmd) {
+			project = append(project, cmd)
+		}
+	}
+	if len(project) > 0 {
+		sb.WriteString("\n  project (.scmd/commands):\n")
+		for _, cmd := range project {
+			sb.WriteString(fmt.Sprintf("    %-12s %s [project]\n", "/"+cmd.Name(), cmd.Description()))
+		}
+	}
+
 	sb.WriteString("\nUse '/help <command>' for more information.\n")
 
 	// Custom Commands section
 	sb.WriteString("\nCustomizing:\n")
 	sb.WriteString("  Local commands:   ~/.scmd/commands/*.yaml\n")
+	sb.WriteString("  Project commands: .scmd/commands/*.yaml in a repository\n")
 	sb.WriteString("  Repositories:     scmd repo list\n")
 	sb.WriteString("  Install commands: scmd repo install <repo>/<command>\n")
 	sb.WriteString("  Examples:         See examples/commands/ in the project\n")
@@ -113,6 +145,9 @@ func (c *HelpCommand) showCommandHelp(name string, execCtx *command.ExecContext)
 	var sb strings.Builder
 	sb.WriteString(fmt.Sprintf("%s - %s\n\n", cmd.Name(), cmd.Description()))
 	sb.WriteString(fmt.Sprintf("Usage: %s\n", cmd.Usage()))
+	if fromProject(cmd) {
+		sb.WriteString("Scope: project (.scmd/commands)\n")
+	}
 
 	if aliases := cmd.Aliases(); len(aliases) > 0 {
 		sb.WriteString(fmt.Sprintf("Aliases: %s\n", strings.Join(aliases, ", ")))
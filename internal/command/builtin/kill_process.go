@@ -3,116 +3,512 @@ package builtin
 import (
 	"context"
 	"fmt"
+	"io"
+	"os"
 	"os/exec"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/scmd/scmd/internal/command"
+	"github.com/scmd/scmd/internal/preview"
+	"github.com/scmd/scmd/internal/utils/procfs"
 )
 
+// defaultKillTimeout is how long to wait after a graceful signal before
+// escalating to SIGKILL
+const defaultKillTimeout = 5 * time.Second
+
+// signalNames maps the signals accepted by --signal to their numbers.
+// Other signals can be given by number.
+var signalNames = map[string]syscall.Signal{
+	"HUP":  syscall.SIGHUP,
+	"INT":  syscall.SIGINT,
+	"QUIT": syscall.SIGQUIT,
+	"KILL": syscall.SIGKILL,
+	"TERM": syscall.SIGTERM,
+}
+
+// gracefulSignals ask a process to exit and are followed by SIGKILL if it
+// does not
+var gracefulSignals = map[syscall.Signal]bool{
+	syscall.SIGINT:  true,
+	syscall.SIGQUIT: true,
+	syscall.SIGTERM: true,
+}
+
 // KillProcessCmd implements process killing with confirmation
-type KillProcessCmd struct{}
+type KillProcessCmd struct {
+	// procs is where processes are discovered
+	procs *procfs.FS
+	// signal and alive are replaceable for tests. alive reports whether the
+	// process found is still running, and not a new one reusing its PID.
+	signal func(pid int, sig syscall.Signal) error
+	alive  func(p *procfs.Process) bool
+	// shell runs the kill command if it is edited in the preview
+	shell  shellRunner
+	stdout io.Writer
+	stderr io.Writer
+	// input overrides where confirmations are read from
+	input io.Reader
+	// poll is how often to check whether signalled processes have exited
+	poll time.Duration
+}
+
+// NewKillProcessCommand creates a new kill-process command
+func NewKillProcessCommand() *KillProcessCmd {
+	c := &KillProcessCmd{
+		procs:  procfs.Default,
+		signal: sendSignal,
+		shell:  runInShell,
+		stdout: os.Stdout,
+		stderr: os.Stderr,
+		poll:   100 * time.Millisecond,
+	}
+	c.alive = func(p *procfs.Process) bool {
+		if c.procs.Available() {
+			return c.procs.Running(p)
+		}
+		return sendSignal(p.PID, syscall.Signal(0)) == nil
+	}
+	return c
+}
 
-func (c *KillProcessCmd) Name() string        { return "kill-process" }
-func (c *KillProcessCmd) Aliases() []string   { return []string{"kp", "killp"} }
-func (c *KillProcessCmd) Description() string { return "Find and kill processes by name" }
+func (c *KillProcessCmd) Name() string      { return "kill-process" }
+func (c *KillProcessCmd) Aliases() []string { return []string{"kp", "killp"} }
+func (c *KillProcessCmd) Description() string {
+	return "Find and kill processes by name, command line, port or parent"
+}
 func (c *KillProcessCmd) Usage() string {
-	return "kill-process <process-name>"
+	return "kill-process [name] [--pattern regex] [--port N] [--tree PID] [--signal SIG] [--timeout 5s] [--list]"
 }
 func (c *KillProcessCmd) Examples() []string {
 	return []string{
 		"scmd kill-process cursor",
 		"scmd /kp node",
-		"scmd kill-process chrome",
+		"scmd kill-process --port 3000",
+		"scmd kill-process --pattern 'python .*manage.py runserver'",
+		"scmd kill-process --tree 4242 --signal INT --timeout 10s",
+		"scmd kill-process chrome --list",
 	}
 }
 func (c *KillProcessCmd) Category() command.Category { return command.CategoryCore }
 func (c *KillProcessCmd) RequiresBackend() bool      { return false }
 
 func (c *KillProcessCmd) Validate(args *command.Args) error {
-	if len(args.Positional) == 0 {
-		return fmt.Errorf("process name required")
+	_, err := killQuery(args)
+	if err != nil {
+		return err
+	}
+	if _, err := parseSignal(args.GetOptionOrDefault("signal", "TERM")); err != nil {
+		return err
+	}
+	if _, err := killTimeout(args); err != nil {
+		return err
 	}
 	return nil
 }
 
+// killQuery builds the process query from the arguments
+func killQuery(args *command.Args) (procfs.Query, error) {
+	var q procfs.Query
+	if len(args.Positional) > 0 {
+		q.Name = args.Positional[0]
+	}
+	if pattern := args.GetOption("pattern"); pattern != "" {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return q, fmt.Errorf("invalid --pattern: %w", err)
+		}
+		q.Pattern = re
+	}
+	for name, dst := range map[string]*int{"port": &q.Port, "tree": &q.Tree} {
+		value := args.GetOption(name)
+		if value == "" || value == "0" {
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			return q, fmt.Errorf("invalid --%s: %q", name, value)
+		}
+		*dst = n
+	}
+	if q.Empty() {
+		return q, fmt.Errorf("process name required (or --pattern, --port, --tree)")
+	}
+	return q, nil
+}
+
+// parseSignal accepts TERM, SIGTERM, sigterm or a signal number
+func parseSignal(s string) (syscall.Signal, error) {
+	name := strings.TrimPrefix(strings.ToUpper(s), "SIG")
+	if sig, ok := signalNames[name]; ok {
+		return sig, nil
+	}
+	if n, err := strconv.Atoi(s); err == nil && n > 0 && n < 65 {
+		return syscall.Signal(n), nil
+	}
+	return 0, fmt.Errorf("unknown signal %q (use HUP, INT, QUIT, TERM, KILL or a number)", s)
+}
+
+// signalName formats a signal the way kill -l does
+func signalName(sig syscall.Signal) string {
+	for name, s := range signalNames {
+		if s == sig {
+			return name
+		}
+	}
+	return strconv.Itoa(int(sig))
+}
+
+func killTimeout(args *command.Args) (time.Duration, error) {
+	value := args.GetOption("timeout")
+	if value == "" {
+		return defaultKillTimeout, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		// Bare numbers are seconds
+		secs, serr := strconv.ParseFloat(value, 64)
+		if serr != nil {
+			return 0, fmt.Errorf("invalid --timeout: %q", value)
+		}
+		d = time.Duration(secs * float64(time.Second))
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("invalid --timeout: %q", value)
+	}
+	return d, nil
+}
+
 func (c *KillProcessCmd) Execute(ctx context.Context, args *command.Args, execCtx *command.ExecContext) (*command.Result, error) {
 	if err := c.Validate(args); err != nil {
 		return command.NewErrorResult(err.Error()), nil
 	}
+	query, _ := killQuery(args)
+	sig, _ := parseSignal(args.GetOptionOrDefault("signal", "TERM"))
+	timeout, _ := killTimeout(args)
 
-	processName := args.Positional[0]
-
-	// Find processes matching the name
-	processes, err := findProcesses(processName)
+	processes, err := c.find(query)
 	if err != nil {
 		return command.NewErrorResult(fmt.Sprintf("Error finding processes: %v", err)), nil
 	}
 
 	if len(processes) == 0 {
 		return command.NewErrorResult(
-			fmt.Sprintf("No processes found matching '%s'", processName),
-			"Try running: ps aux | grep "+processName,
+			fmt.Sprintf("No processes found matching %s", describeQuery(query)),
+			"Try running: ps aux | grep "+query.Name,
 		), nil
 	}
 
-	// Display processes
-	var output strings.Builder
-	output.WriteString(fmt.Sprintf("Found %d process(es) matching '%s':\n\n", len(processes), processName))
-	output.WriteString("PID     USER       COMMAND\n")
-	output.WriteString("-----   --------   -------\n")
-	for _, p := range processes {
-		output.WriteString(fmt.Sprintf("%-7s %-10s %s\n", p.PID, p.User, p.Command))
+	table := formatProcesses(processes, query.Tree != 0, time.Now())
+	header := fmt.Sprintf("Found %d process(es) matching %s:\n\n", len(processes), describeQuery(query))
+
+	if args.HasFlag("list") {
+		return command.NewResult(header + table), nil
 	}
-	output.WriteString("\n")
 
-	// Write the list to stderr so user sees it
-	execCtx.UI.WriteError(output.String())
+	// Show the list on stderr so it stays out of piped output
+	fmt.Fprint(c.stderr, header+table+"\n")
 
-	// Ask for confirmation
-	confirmMsg := fmt.Sprintf("Kill %d process(es)?", len(processes))
-	if !execCtx.UI.Confirm(confirmMsg) {
-		return command.NewResult("Operation cancelled"), nil
+	pids := make([]string, len(processes))
+	for i, p := range processes {
+		pids[i] = strconv.Itoa(p.PID)
 	}
+	line := fmt.Sprintf("kill -%s %s", signalName(sig), strings.Join(pids, " "))
+
+	if !args.HasFlag("yes") {
+		input := c.input
+		if input == nil {
+			tty, closeInput, err := promptInput(args)
+			if err != nil {
+				return command.NewErrorResult(
+					"cannot prompt for confirmation: no terminal available",
+					"Pass --yes to kill without confirming",
+				), nil
+			}
+			defer closeInput()
+			input = tty
+		}
 
-	// Kill the processes
-	killed := []string{}
-	failed := []string{}
+		buffer := preview.NewBuffer(line)
+		buffer.Input = input
+		buffer.Output = c.stderr
+		buffer.Always = true
 
-	for _, p := range processes {
-		err := killProcess(p.PID)
+		action, final, err := buffer.Show()
 		if err != nil {
-			failed = append(failed, fmt.Sprintf("%s (%v)", p.PID, err))
-		} else {
-			killed = append(killed, p.PID)
+			return command.NewErrorResult(fmt.Sprintf("preview failed: %v", err)), nil
+		}
+
+		switch action {
+		case preview.ActionQuit:
+			return command.NewResult("Operation cancelled"), nil
+		case preview.ActionDryRun:
+			return command.NewResult(fmt.Sprintf("[DRY RUN] Would send SIG%s to %d process(es): %s",
+				signalName(sig), len(pids), strings.Join(pids, ", "))), nil
+		case preview.ActionEdit:
+			if final != line {
+				// The edited command runs as written
+				exitCode, err := c.shell(ctx, final, c.stdout, c.stderr)
+				if err != nil {
+					return command.NewErrorResult(fmt.Sprintf("run command: %v", err)), nil
+				}
+				return &command.Result{Success: true, ExitCode: exitCode}, nil
+			}
 		}
 	}
 
-	// Build result message
+	return c.kill(ctx, processes, sig, timeout, !args.HasFlag("no-force")), nil
+}
+
+// find discovers processes, falling back to pgrep where there is no /proc
+func (c *KillProcessCmd) find(q procfs.Query) ([]*procfs.Process, error) {
+	var processes []*procfs.Process
+	if c.procs.Available() {
+		found, err := c.procs.Find(q)
+		if err != nil {
+			return nil, err
+		}
+		processes = found
+	} else {
+		if q.Pattern != nil || q.Port != 0 || q.Tree != 0 {
+			return nil, fmt.Errorf("--pattern, --port and --tree need a /proc filesystem")
+		}
+		found, err := findProcesses(q.Name)
+		if err != nil {
+			return nil, err
+		}
+		processes = found
+	}
+
+	// Never offer to kill ourselves
+	self := os.Getpid()
+	kept := processes[:0]
+	for _, p := range processes {
+		if p.PID != self {
+			kept = append(kept, p)
+		}
+	}
+	return kept, nil
+}
+
+// kill signals each process. Graceful signals are followed by SIGKILL for
+// processes still running after the timeout, unless force is false.
+func (c *KillProcessCmd) kill(
+	ctx context.Context,
+	processes []*procfs.Process,
+	sig syscall.Signal,
+	timeout time.Duration,
+	force bool,
+) *command.Result {
+	var signalled []*procfs.Process
+	var failed []string
+	for _, p := range processes {
+		if err := c.signal(p.PID, sig); err != nil {
+			failed = append(failed, fmt.Sprintf("%d (%v)", p.PID, err))
+			continue
+		}
+		signalled = append(signalled, p)
+	}
+
 	var result strings.Builder
+	if sig != syscall.SIGKILL && !gracefulSignals[sig] {
+		// Signals like HUP are requests, not terminations; nothing to wait for
+		if len(signalled) > 0 {
+			result.WriteString(fmt.Sprintf("✓ Sent SIG%s to %d process(es): %s\n",
+				signalName(sig), len(signalled), joinPIDs(pidsOf(signalled))))
+		}
+		return killResult(&result, failed)
+	}
+
+	var escalated []*procfs.Process
+	running := c.waitExit(ctx, signalled, timeout)
+	if len(running) > 0 && sig != syscall.SIGKILL && force {
+		fmt.Fprintf(c.stderr, "%d process(es) still running after %s, sending SIGKILL\n", len(running), timeout)
+		for _, p := range running {
+			// The PID may have been reused since waitExit checked it
+			if !c.alive(p) {
+				continue
+			}
+			if err := c.signal(p.PID, syscall.SIGKILL); err != nil {
+				failed = append(failed, fmt.Sprintf("%d (%v)", p.PID, err))
+				continue
+			}
+			escalated = append(escalated, p)
+		}
+		running = c.waitExit(ctx, escalated, timeout)
+	}
+
+	stillRunning := make(map[*procfs.Process]bool, len(running))
+	for _, p := range running {
+		stillRunning[p] = true
+	}
+	var killed []int
+	for _, p := range signalled {
+		if !stillRunning[p] {
+			killed = append(killed, p.PID)
+		}
+	}
+
 	if len(killed) > 0 {
-		result.WriteString(fmt.Sprintf("✓ Killed %d process(es): %s\n", len(killed), strings.Join(killed, ", ")))
+		result.WriteString(fmt.Sprintf("✓ Killed %d process(es): %s\n", len(killed), joinPIDs(killed)))
+	}
+	if len(escalated) > 0 {
+		result.WriteString(fmt.Sprintf("  SIG%s was not enough for %s; sent SIGKILL\n", signalName(sig), joinPIDs(pidsOf(escalated))))
 	}
+	for _, p := range running {
+		failed = append(failed, fmt.Sprintf("%d (still running)", p.PID))
+	}
+	return killResult(&result, failed)
+}
+
+func killResult(result *strings.Builder, failed []string) *command.Result {
 	if len(failed) > 0 {
 		result.WriteString(fmt.Sprintf("✗ Failed to kill %d process(es): %s\n", len(failed), strings.Join(failed, ", ")))
+		return command.NewErrorResult(result.String())
 	}
+	return command.NewResult(result.String())
+}
 
-	if len(failed) > 0 {
-		return command.NewErrorResult(result.String()), nil
+// waitExit polls until every process has exited or the timeout passes, and
+// returns those still running. A process whose PID now belongs to another
+// process has exited.
+func (c *KillProcessCmd) waitExit(ctx context.Context, processes []*procfs.Process, timeout time.Duration) []*procfs.Process {
+	deadline := time.Now().Add(timeout)
+	for {
+		var running []*procfs.Process
+		for _, p := range processes {
+			if c.alive(p) {
+				running = append(running, p)
+			}
+		}
+		if len(running) == 0 || !time.Now().Before(deadline) {
+			return running
+		}
+
+		select {
+		case <-ctx.Done():
+			return running
+		case <-time.After(c.poll):
+		}
+		processes = running
+	}
+}
+
+func pidsOf(processes []*procfs.Process) []int {
+	pids := make([]int, len(processes))
+	for i, p := range processes {
+		pids[i] = p.PID
+	}
+	return pids
+}
+
+func joinPIDs(pids []int) string {
+	s := make([]string, len(pids))
+	for i, pid := range pids {
+		s[i] = strconv.Itoa(pid)
+	}
+	return strings.Join(s, ", ")
+}
+
+// describeQuery summarizes a query for messages
+func describeQuery(q procfs.Query) string {
+	var parts []string
+	if q.Name != "" {
+		parts = append(parts, fmt.Sprintf("'%s'", q.Name))
+	}
+	if q.Pattern != nil {
+		parts = append(parts, fmt.Sprintf("/%s/", q.Pattern))
+	}
+	if q.Port != 0 {
+		parts = append(parts, fmt.Sprintf("port %d", q.Port))
+	}
+	if q.Tree != 0 {
+		parts = append(parts, fmt.Sprintf("tree of %d", q.Tree))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// formatProcesses renders a process table. Tree views indent children under
+// their parents.
+func formatProcesses(processes []*procfs.Process, tree bool, now time.Time) string {
+	showPorts := false
+	for _, p := range processes {
+		if len(p.Ports) > 0 {
+			showPorts = true
+		}
+	}
+
+	var depths map[int]int
+	if tree {
+		depths = procfs.Depths(processes)
 	}
 
-	return command.NewResult(result.String()), nil
+	var b strings.Builder
+	b.WriteString("PID     USER       %CPU  %MEM  START  ")
+	if showPorts {
+		b.WriteString("PORTS        ")
+	}
+	b.WriteString("COMMAND\n")
+
+	for _, p := range processes {
+		fmt.Fprintf(&b, "%-7d %-10s %4.1f  %4.1f  %-5s  ", p.PID, truncate(p.User, 10), p.CPUPercent, p.MemPercent, startTime(p.Start, now))
+		if showPorts {
+			ports := make([]string, len(p.Ports))
+			for i, port := range p.Ports {
+				ports[i] = strconv.Itoa(port)
+			}
+			fmt.Fprintf(&b, "%-12s ", strings.Join(ports, ","))
+		}
+		cmd := p.CommandLine()
+		if tree {
+			if d := depths[p.PID]; d > 0 {
+				cmd = strings.Repeat("  ", d-1) + "└─ " + cmd
+			}
+		}
+		b.WriteString(truncate(cmd, 60) + "\n")
+	}
+	return b.String()
 }
 
-// Process represents a running process
-type Process struct {
-	PID     string
-	User    string
-	Command string
+// startTime formats a start time like ps: the time today, the date this
+// year, otherwise the year
+func startTime(t, now time.Time) string {
+	switch {
+	case t.IsZero():
+		return "-"
+	case t.YearDay() == now.YearDay() && t.Year() == now.Year():
+		return t.Format("15:04")
+	case t.Year() == now.Year():
+		return t.Format("Jan02")
+	default:
+		return t.Format("2006")
+	}
 }
 
-// findProcesses finds processes matching the given name
-func findProcesses(name string) ([]Process, error) {
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n-3] + "..."
+}
+
+// sendSignal signals a process by PID
+func sendSignal(pid int, sig syscall.Signal) error {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+	return p.Signal(sig)
+}
+
+// findProcesses finds processes matching the given name with pgrep, for
+// systems without /proc
+func findProcesses(name string) ([]*procfs.Process, error) {
 	// Use pgrep -l to find processes (works on macOS and Linux)
 	cmd := exec.Command("pgrep", "-l", name)
 	output, err := cmd.Output()
@@ -120,7 +516,7 @@ func findProcesses(name string) ([]Process, error) {
 		// pgrep returns exit code 1 if no matches found
 		if exitErr, ok := err.(*exec.ExitError); ok {
 			if exitErr.ExitCode() == 1 {
-				return []Process{}, nil
+				return nil, nil
 			}
 		}
 		return nil, err
@@ -128,74 +524,40 @@ func findProcesses(name string) ([]Process, error) {
 
 	// Parse output (format: "PID name")
 	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
-	processes := []Process{}
+	var processes []*procfs.Process
 
 	for _, line := range lines {
-		if line == "" {
-			continue
-		}
-
 		parts := strings.SplitN(line, " ", 2)
 		if len(parts) < 2 {
 			continue
 		}
 
-		pid := parts[0]
-		cmdName := parts[1]
-
-		// Get user for this PID
-		user := getProcessUser(pid)
-
-		// Get full command line
-		fullCmd := getProcessCommand(pid)
-		if fullCmd == "" {
-			fullCmd = cmdName
+		pid, err := strconv.Atoi(parts[0])
+		if err != nil {
+			continue
 		}
 
-		processes = append(processes, Process{
-			PID:     pid,
-			User:    user,
-			Command: fullCmd,
-		})
+		p := &procfs.Process{PID: pid, Name: parts[1]}
+		p.User, p.Cmdline = psInfo(parts[0])
+		processes = append(processes, p)
 	}
 
+	sort.Slice(processes, func(i, j int) bool { return processes[i].PID < processes[j].PID })
 	return processes, nil
 }
 
-// getProcessUser gets the user owning a process
-func getProcessUser(pid string) string {
-	cmd := exec.Command("ps", "-p", pid, "-o", "user=")
-	output, err := cmd.Output()
-	if err != nil {
-		return "unknown"
-	}
-	return strings.TrimSpace(string(output))
-}
-
-// getProcessCommand gets the full command line for a process
-func getProcessCommand(pid string) string {
-	cmd := exec.Command("ps", "-p", pid, "-o", "command=")
-	output, err := cmd.Output()
-	if err != nil {
-		return ""
+// psInfo gets the user and full command line of a process from ps
+func psInfo(pid string) (string, []string) {
+	user := "unknown"
+	if out, err := exec.Command("ps", "-p", pid, "-o", "user=").Output(); err == nil {
+		user = strings.TrimSpace(string(out))
 	}
-	fullCmd := strings.TrimSpace(string(output))
 
-	// Truncate long commands
-	if len(fullCmd) > 60 {
-		fullCmd = fullCmd[:57] + "..."
-	}
-
-	return fullCmd
-}
-
-// killProcess kills a process by PID
-func killProcess(pid string) error {
-	pidInt, err := strconv.Atoi(pid)
-	if err != nil {
-		return fmt.Errorf("invalid PID: %s", pid)
+	var cmdline []string
+	if out, err := exec.Command("ps", "-p", pid, "-o", "command=").Output(); err == nil {
+		if s := strings.TrimSpace(string(out)); s != "" {
+			cmdline = []string{s}
+		}
 	}
-
-	cmd := exec.Command("kill", fmt.Sprintf("%d", pidInt))
-	return cmd.Run()
+	return user, cmdline
 }
@@ -0,0 +1,254 @@
+package builtin
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/scmd/scmd/internal/command"
+	"github.com/scmd/scmd/internal/utils/procfs"
+	"github.com/scmd/scmd/tests/testutil"
+)
+
+// fakeProcesses is a fake procfs whose processes exit when signalled,
+// except stubborn ones which ignore everything but SIGKILL. A reused PID
+// exits and is taken straight away by a new process.
+type fakeProcesses struct {
+	fs       *procfs.FS
+	running  map[int]bool
+	stubborn map[int]bool
+	reused   map[int]bool
+	sent     []string
+}
+
+func newFakeProcesses(t *testing.T, cmdlines map[int]string) *fakeProcesses {
+	root := t.TempDir()
+	write := func(name, content string) {
+		path := filepath.Join(root, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+	write("stat", "btime 1700000000\n")
+	write("uptime", "1000.00 3000.00\n")
+
+	f := &fakeProcesses{running: map[int]bool{}, stubborn: map[int]bool{}, reused: map[int]bool{}}
+	for pid, cmdline := range cmdlines {
+		args := strings.Fields(cmdline)
+		rest := "S 1" + strings.Repeat(" 0", 20)
+		write(fmt.Sprintf("%d/stat", pid), fmt.Sprintf("%d (%s) %s\n", pid, filepath.Base(args[0]), rest))
+		write(fmt.Sprintf("%d/cmdline", pid), strings.Join(args, "\x00"))
+		f.running[pid] = true
+	}
+	f.fs = procfs.New(root)
+	return f
+}
+
+func (f *fakeProcesses) signal(pid int, sig syscall.Signal) error {
+	f.sent = append(f.sent, fmt.Sprintf("%d:%s", pid, signalName(sig)))
+	if !f.running[pid] {
+		return fmt.Errorf("no such process")
+	}
+	if (sig == syscall.SIGKILL || !f.stubborn[pid]) && !f.reused[pid] {
+		f.running[pid] = false
+	}
+	return nil
+}
+
+// alive checks the PID and, like procfs.FS.Running, that it was not reused
+func (f *fakeProcesses) alive(p *procfs.Process) bool {
+	return f.running[p.PID] && !f.reused[p.PID]
+}
+
+func newTestKill(f *fakeProcesses, shell *fakeShell, input string) (*KillProcessCmd, *bytes.Buffer) {
+	stderr := &bytes.Buffer{}
+	c := &KillProcessCmd{
+		procs:  f.fs,
+		signal: f.signal,
+		alive:  f.alive,
+		shell:  shell.run,
+		stdout: io.Discard,
+		stderr: stderr,
+		input:  strings.NewReader(input),
+		poll:   time.Millisecond,
+	}
+	return c, stderr
+}
+
+func killArgs(positional []string, options map[string]string, flags ...string) *command.Args {
+	args := command.NewArgs()
+	args.Positional = positional
+	for k, v := range options {
+		args.Options[k] = v
+	}
+	for _, f := range flags {
+		args.Flags[f] = true
+	}
+	return args
+}
+
+func TestKillProcess_ConfirmsThroughPreview(t *testing.T) {
+	f := newFakeProcesses(t, map[int]string{100: "node server.js", 101: "node worker.js", 200: "python3 app.py"})
+	c, stderr := newTestKill(f, &fakeShell{}, "\n")
+
+	result, err := c.Execute(context.Background(), killArgs([]string{"node"}, nil), &command.ExecContext{UI: testutil.NewMockUI()})
+	require.NoError(t, err)
+	require.True(t, result.Success, result.Error)
+
+	assert.Contains(t, stderr.String(), "Found 2 process(es) matching 'node'")
+	assert.Contains(t, stderr.String(), "node server.js")
+	assert.Contains(t, stderr.String(), "kill -TERM 100 101")
+	assert.Equal(t, []string{"100:TERM", "101:TERM"}, f.sent)
+	assert.Contains(t, result.Output, "✓ Killed 2 process(es): 100, 101")
+}
+
+func TestKillProcess_Cancel(t *testing.T) {
+	f := newFakeProcesses(t, map[int]string{100: "node server.js"})
+	c, _ := newTestKill(f, &fakeShell{}, "q\n")
+
+	result, err := c.Execute(context.Background(), killArgs([]string{"node"}, nil), &command.ExecContext{})
+	require.NoError(t, err)
+	assert.Equal(t, "Operation cancelled", result.Output)
+	assert.Empty(t, f.sent)
+}
+
+func TestKillProcess_EscalatesAfterTimeout(t *testing.T) {
+	f := newFakeProcesses(t, map[int]string{100: "node server.js", 101: "node worker.js"})
+	f.stubborn[101] = true
+	c, stderr := newTestKill(f, &fakeShell{}, "")
+
+	result, err := c.Execute(context.Background(),
+		killArgs([]string{"node"}, map[string]string{"timeout": "20ms"}, "yes"),
+		&command.ExecContext{})
+	require.NoError(t, err)
+	require.True(t, result.Success, result.Error)
+
+	assert.Equal(t, []string{"100:TERM", "101:TERM", "101:KILL"}, f.sent)
+	assert.Contains(t, stderr.String(), "1 process(es) still running after 20ms, sending SIGKILL")
+	assert.Contains(t, result.Output, "✓ Killed 2 process(es): 100, 101")
+	assert.Contains(t, result.Output, "SIGTERM was not enough for 101")
+}
+
+func TestKillProcess_ReusedPIDIsNotEscalated(t *testing.T) {
+	f := newFakeProcesses(t, map[int]string{101: "node worker.js"})
+	f.reused[101] = true
+	c, _ := newTestKill(f, &fakeShell{}, "")
+
+	result, err := c.Execute(context.Background(),
+		killArgs([]string{"node"}, map[string]string{"timeout": "20ms"}, "yes"),
+		&command.ExecContext{})
+	require.NoError(t, err)
+	require.True(t, result.Success, result.Error)
+
+	// The PID is running again, but as another process; it gets no SIGKILL
+	assert.Equal(t, []string{"101:TERM"}, f.sent)
+	assert.Contains(t, result.Output, "✓ Killed 1 process(es): 101")
+}
+
+func TestKillProcess_NoForce(t *testing.T) {
+	f := newFakeProcesses(t, map[int]string{101: "node worker.js"})
+	f.stubborn[101] = true
+	c, _ := newTestKill(f, &fakeShell{}, "")
+
+	result, err := c.Execute(context.Background(),
+		killArgs([]string{"node"}, map[string]string{"timeout": "5ms", "signal": "int"}, "yes", "no-force"),
+		&command.ExecContext{})
+	require.NoError(t, err)
+	assert.False(t, result.Success)
+	assert.Equal(t, []string{"101:INT"}, f.sent)
+	assert.Contains(t, result.Error, "101 (still running)")
+}
+
+func TestKillProcess_NonTerminatingSignal(t *testing.T) {
+	f := newFakeProcesses(t, map[int]string{100: "nginx -g daemon"})
+	f.stubborn[100] = true
+	c, _ := newTestKill(f, &fakeShell{}, "")
+
+	result, err := c.Execute(context.Background(),
+		killArgs(nil, map[string]string{"pattern": "^nginx", "signal": "SIGHUP"}, "yes"),
+		&command.ExecContext{})
+	require.NoError(t, err)
+	require.True(t, result.Success, result.Error)
+	assert.Equal(t, []string{"100:HUP"}, f.sent)
+	assert.Contains(t, result.Output, "✓ Sent SIGHUP to 1 process(es): 100")
+}
+
+func TestKillProcess_EditedCommandRunsInShell(t *testing.T) {
+	f := newFakeProcesses(t, map[int]string{100: "node server.js"})
+	shell := &fakeShell{codes: []int{0}}
+	c, _ := newTestKill(f, shell, "")
+	// The inline editor reads its own line, so feed input a byte at a time
+	c.input = iotest.OneByteReader(strings.NewReader("e\nkill -9 100\n"))
+	t.Setenv("EDITOR", "scmd-test-no-such-editor")
+
+	result, err := c.Execute(context.Background(), killArgs([]string{"node"}, nil), &command.ExecContext{})
+	require.NoError(t, err)
+	require.True(t, result.Success, result.Error)
+	assert.Equal(t, []string{"kill -9 100"}, shell.ran)
+	assert.Empty(t, f.sent)
+}
+
+func TestKillProcess_List(t *testing.T) {
+	f := newFakeProcesses(t, map[int]string{100: "node server.js"})
+	c, _ := newTestKill(f, &fakeShell{}, "")
+
+	result, err := c.Execute(context.Background(), killArgs([]string{"node"}, nil, "list"), &command.ExecContext{})
+	require.NoError(t, err)
+	assert.Contains(t, result.Output, "PID     USER       %CPU  %MEM  START  COMMAND")
+	assert.Contains(t, result.Output, "100")
+	assert.Empty(t, f.sent)
+}
+
+func TestKillProcess_Validate(t *testing.T) {
+	c := NewKillProcessCommand()
+
+	assert.Error(t, c.Validate(killArgs(nil, nil)))
+	assert.NoError(t, c.Validate(killArgs(nil, map[string]string{"port": "3000"})))
+	assert.Error(t, c.Validate(killArgs(nil, map[string]string{"port": "http"})))
+	assert.Error(t, c.Validate(killArgs(nil, map[string]string{"pattern": "("})))
+	assert.Error(t, c.Validate(killArgs([]string{"node"}, map[string]string{"signal": "BOGUS"})))
+	assert.Error(t, c.Validate(killArgs([]string{"node"}, map[string]string{"timeout": "soon"})))
+}
+
+func TestParseSignal(t *testing.T) {
+	for in, want := range map[string]syscall.Signal{
+		"TERM": syscall.SIGTERM, "sigkill": syscall.SIGKILL, "Hup": syscall.SIGHUP, "10": syscall.Signal(10),
+	} {
+		sig, err := parseSignal(in)
+		require.NoError(t, err, in)
+		assert.Equal(t, want, sig, in)
+	}
+	assert.Equal(t, "10", signalName(syscall.Signal(10)))
+	assert.Equal(t, "TERM", signalName(syscall.SIGTERM))
+}
+
+func TestFormatProcesses_Tree(t *testing.T) {
+	now := time.Date(2026, 3, 4, 15, 0, 0, 0, time.UTC)
+	procs := []*procfs.Process{
+		{PID: 1, User: "root", Cmdline: []string{"make"}, Start: now.Add(-time.Hour), Ports: []int{80}},
+		{PID: 2, PPID: 1, User: "root", Cmdline: []string{"cc", "a.c"}, Start: now.AddDate(0, -1, 0)},
+		{PID: 3, PPID: 2, User: "root", Name: "ld", Start: now.AddDate(-1, 0, 0)},
+	}
+
+	out := formatProcesses(procs, true, now)
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	require.Len(t, lines, 4)
+	assert.Contains(t, lines[0], "PORTS")
+	assert.Contains(t, lines[1], "14:00")
+	assert.Contains(t, lines[1], "80 ")
+	assert.True(t, strings.HasSuffix(lines[2], "└─ cc a.c"))
+	assert.Contains(t, lines[2], "Feb04")
+	assert.True(t, strings.HasSuffix(lines[3], "  └─ [ld]"))
+	assert.Contains(t, lines[3], strconv.Itoa(2025))
+}
@@ -13,9 +13,12 @@ func RegisterAll(registry *command.Registry) error {
 		helpCmd,
 		NewExplainCommand(),
 		NewReviewCommand(),
+		NewSummarizeCommand(),
+		NewExplainErrorCommand(),
+		NewUndoCommand(),
 		NewConfigCommand(),
 		NewCmdCommand(),
-		&KillProcessCmd{},
+		NewKillProcessCommand(),
 	}
 
 	for _, cmd := range commands {
@@ -44,11 +44,43 @@ func (c *ReviewCommand) Examples() []string {
 		"/review main.go",
 		"git diff | scmd review",
 		"/review src/ --focus security",
+		"scmd review main.go --report sarif > review.sarif",
+		"scmd review main.go --report json --report-file findings.json",
+		"scmd review --diff main..HEAD",
+		"scmd review --diff --staged --report sarif",
+		"scmd review handler.go --apply",
 	}
 }
 
 // Validate validates arguments
 func (c *ReviewCommand) Validate(args *command.Args) error {
+	if err := validateReportOptions(args); err != nil {
+		return err
+	}
+
+	// Diff mode reads its input from git
+	if args.HasFlag("diff") {
+		if args.HasFlag("apply") {
+			return fmt.Errorf("--apply cannot be combined with --diff")
+		}
+		if len(args.Positional) > 1 {
+			return fmt.Errorf("--diff takes at most one revision range")
+		}
+		return nil
+	}
+	if args.HasFlag("staged") {
+		return fmt.Errorf("--staged requires --diff")
+	}
+
+	if args.HasFlag("apply") {
+		if args.GetOption("report") != "" {
+			return fmt.Errorf("--apply cannot be combined with --report")
+		}
+		if len(args.Positional) == 0 {
+			return fmt.Errorf("--apply requires a file to patch")
+		}
+	}
+
 	stdin, hasStdin := args.Options["stdin"]
 	stdinEmpty := !hasStdin || strings.TrimSpace(stdin) == ""
 
@@ -64,6 +96,24 @@ func (c *ReviewCommand) Validate(args *command.Args) error {
 	return nil
 }
 
+// validateReportOptions checks the structured-report options
+func validateReportOptions(args *command.Args) error {
+	if report := args.GetOption("report"); report != "" && report != "json" && report != "sarif" {
+		return fmt.Errorf("invalid report format '%s': must be one of: json, sarif", report)
+	}
+
+	if args.GetOption("report-file") != "" && args.GetOption("report") == "" {
+		return fmt.Errorf("--report-file requires --report")
+	}
+
+	structured := args.GetOption("report") != "" || args.HasFlag("diff")
+	if structured && args.GetOption("template") != "" {
+		return fmt.Errorf("--template cannot be combined with --report or --diff")
+	}
+
+	return nil
+}
+
 // Execute runs the review command
 func (c *ReviewCommand) Execute(
 	ctx context.Context,
@@ -75,8 +125,13 @@ func (c *ReviewCommand) Execute(
 		return command.NewErrorResult(err.Error()), nil
 	}
 
+	if args.HasFlag("diff") {
+		return c.executeDiff(ctx, args, execCtx)
+	}
+
 	var content string
 	var subject string
+	file := "stdin"
 
 	// Check for piped input (e.g., git diff)
 	if stdin, ok := args.Options["stdin"]; ok && stdin != "" {
@@ -95,6 +150,7 @@ func (c *ReviewCommand) Execute(
 			}
 			content = string(data)
 			subject = filepath.Base(target)
+			file = filepath.ToSlash(target)
 		} else {
 			return command.NewErrorResult(
 				fmt.Sprintf("file not found: %s", target),
@@ -106,6 +162,23 @@ func (c *ReviewCommand) Execute(
 	// Get focus area if specified
 	focus := args.GetOption("focus")
 
+	if args.HasFlag("apply") {
+		target := filepath.ToSlash(args.Positional[0])
+		task := fmt.Sprintf("Review %s and fix the bugs, security vulnerabilities and other real problems you find.", target)
+		if focus != "" {
+			task += fmt.Sprintf(" Focus especially on: %s.", focus)
+		}
+		return proposeAndApply(ctx, args, execCtx, &patchRequest{
+			Task:        task,
+			Files:       patchableFiles([]string{args.Positional[0]}),
+			Description: "review --apply " + target,
+		})
+	}
+
+	if report := args.GetOption("report"); report != "" {
+		return c.executeStructured(ctx, execCtx, content, subject, file, focus, args)
+	}
+
 	// Check for template
 	templateName := args.GetOption("template")
 	var systemPrompt, prompt string
@@ -180,6 +253,71 @@ Format your response in markdown with sections for each category.`
 	return command.NewResult(resp.Content), nil
 }
 
+// executeStructured asks the backend for line-anchored findings and renders
+// them as SARIF or JSON, with the exit code set by the highest severity
+func (c *ReviewCommand) executeStructured(
+	ctx context.Context,
+	execCtx *command.ExecContext,
+	content, subject, file, focus string,
+	args *command.Args,
+) (*command.Result, error) {
+	if execCtx.Backend == nil {
+		return command.NewErrorResult(
+			"no backend available",
+			"Configure a backend with 'scmd config'",
+		), nil
+	}
+
+	stop := execCtx.UI.Spinner("Reviewing")
+	findings, err := requestFindings(ctx, execCtx.Backend, buildFindingsPrompt(content, subject, focus))
+	stop()
+	if err != nil {
+		return command.NewErrorResult(
+			err.Error(),
+			"Try again, or use a larger model for structured reviews",
+		), nil
+	}
+
+	report := ValidateFindings(findings, file, len(splitLines(content)))
+
+	return renderReport(report, args.GetOption("report"), args.GetOption("report-file"))
+}
+
+// requestFindings asks the backend for JSON findings, retrying once with the
+// parse error if the first response is not valid
+func requestFindings(ctx context.Context, b backend.Backend, prompt string) ([]ReviewFinding, error) {
+	req := &backend.CompletionRequest{
+		Prompt:       prompt,
+		SystemPrompt: findingsSystemPrompt,
+		MaxTokens:    4096,
+		Temperature:  0.1,
+		JSONMode:     true,
+	}
+
+	resp, err := b.Complete(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("backend error: %v", err)
+	}
+
+	findings, parseErr := ParseFindings(resp.Content)
+	if parseErr == nil {
+		return findings, nil
+	}
+
+	req.Prompt = fmt.Sprintf("%s\n\nYour previous response could not be parsed (%v). "+
+		"Respond again with only the JSON object.", prompt, parseErr)
+	resp, err = b.Complete(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("backend error: %v", err)
+	}
+
+	findings, parseErr = ParseFindings(resp.Content)
+	if parseErr != nil {
+		return nil, fmt.Errorf("model did not return valid findings: %v", parseErr)
+	}
+	return findings, nil
+}
+
 func buildReviewPrompt(content, subject, focus string) string {
 	var sb strings.Builder
 
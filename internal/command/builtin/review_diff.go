@@ -0,0 +1,378 @@
+package builtin
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/scmd/scmd/internal/backend"
+	"github.com/scmd/scmd/internal/command"
+	"github.com/scmd/scmd/internal/diff"
+)
+
+const (
+	// diffContextLines is how many working-tree lines surround each hunk
+	diffContextLines = 10
+	// maxParallelReviews caps concurrent per-file reviews on remote backends
+	maxParallelReviews = 4
+)
+
+// diffSource describes where the new side of a diff lives
+type diffSource struct {
+	// GitArgs are the arguments passed to git diff
+	GitArgs []string
+	// NewRev is the revision holding new-file content: "" for the working
+	// tree, ":" for the index, or a commit-ish
+	NewRev string
+}
+
+// parseDiffSpec turns a review --diff argument into git diff arguments.
+// An empty spec reviews all uncommitted changes against HEAD.
+func parseDiffSpec(spec string, staged bool) (*diffSource, error) {
+	base := []string{"diff", "--no-color", "--no-ext-diff", "-U3"}
+
+	if staged {
+		if spec != "" {
+			return nil, fmt.Errorf("--staged cannot be combined with a revision range")
+		}
+		return &diffSource{GitArgs: append(base, "--staged"), NewRev: ":"}, nil
+	}
+
+	if spec == "" {
+		return &diffSource{GitArgs: append(base, "HEAD")}, nil
+	}
+	if strings.HasPrefix(spec, "-") {
+		return nil, fmt.Errorf("invalid revision range '%s'", spec)
+	}
+
+	if strings.Contains(spec, "...") {
+		parts := strings.SplitN(spec, "...", 2)
+		return &diffSource{GitArgs: append(base, spec), NewRev: revOrHead(parts[1])}, nil
+	}
+	if strings.Contains(spec, "..") {
+		parts := strings.SplitN(spec, "..", 2)
+		return &diffSource{GitArgs: append(base, spec), NewRev: revOrHead(parts[1])}, nil
+	}
+
+	// A single revision is compared with the working tree
+	return &diffSource{GitArgs: append(base, spec)}, nil
+}
+
+func revOrHead(rev string) string {
+	if rev == "" {
+		return "HEAD"
+	}
+	return rev
+}
+
+// gitRunner runs git in a directory and returns stdout
+type gitRunner func(ctx context.Context, dir string, args ...string) (string, error)
+
+func runGit(ctx context.Context, dir string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		return "", fmt.Errorf("git %s: %s", args[0], msg)
+	}
+
+	return stdout.String(), nil
+}
+
+// diffReviewer reviews a diff file by file and merges the findings
+type diffReviewer struct {
+	backend  backend.Backend
+	git      gitRunner
+	root     string
+	source   *diffSource
+	focus    string
+	parallel int
+}
+
+// reviewFileResult is the outcome of reviewing one file
+type reviewFileResult struct {
+	report *ReviewReport
+	err    error
+}
+
+// Review reviews every text file in files and returns a consolidated report
+func (r *diffReviewer) Review(ctx context.Context, files []diff.File) *ReviewReport {
+	var targets []diff.File
+	for _, f := range files {
+		if f.Binary || f.IsDeleted() || len(f.AddedLines()) == 0 {
+			continue
+		}
+		targets = append(targets, f)
+	}
+
+	results := make([]reviewFileResult, len(targets))
+	sem := make(chan struct{}, r.parallel)
+	var wg sync.WaitGroup
+
+	for i := range targets {
+		wg.Add(1)
+		go func(idx int) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			report, err := r.reviewFile(ctx, &targets[idx])
+			results[idx] = reviewFileResult{report: report, err: err}
+		}(i)
+	}
+	wg.Wait()
+
+	merged := &ReviewReport{Findings: []ReviewFinding{}}
+	for i, res := range results {
+		if res.err != nil {
+			merged.Errors = append(merged.Errors, fmt.Sprintf("%s: %v", targets[i].Path(), res.err))
+			continue
+		}
+		merged.Findings = append(merged.Findings, res.report.Findings...)
+		merged.Dropped += res.report.Dropped
+		merged.reviewed++
+	}
+	merged.Sort()
+
+	return merged
+}
+
+// reviewFile reviews the hunks of a single file
+func (r *diffReviewer) reviewFile(ctx context.Context, f *diff.File) (*ReviewReport, error) {
+	newLines, err := r.newContent(ctx, f.NewPath)
+	if err != nil {
+		return nil, err
+	}
+
+	windows := hunkWindows(f, len(newLines), diffContextLines)
+	prompt := buildDiffReviewPrompt(f, newLines, windows, r.focus)
+
+	findings, err := requestFindings(ctx, r.backend, prompt)
+	if err != nil {
+		return nil, err
+	}
+
+	return validateInWindows(findings, f.NewPath, windows), nil
+}
+
+// newContent returns the lines of the new side of path
+func (r *diffReviewer) newContent(ctx context.Context, path string) ([]string, error) {
+	var content string
+	if r.source.NewRev == "" {
+		data, err := os.ReadFile(filepath.Join(r.root, filepath.FromSlash(path)))
+		if err != nil {
+			return nil, fmt.Errorf("read working tree: %w", err)
+		}
+		content = string(data)
+	} else {
+		rev := r.source.NewRev + ":" + path
+		if r.source.NewRev == ":" {
+			rev = ":" + path
+		}
+		out, err := r.git(ctx, r.root, "show", rev)
+		if err != nil {
+			return nil, err
+		}
+		content = out
+	}
+	return splitLines(content), nil
+}
+
+// lineWindow is an inclusive range of new-file lines shown to the model
+type lineWindow struct {
+	Start, End int
+}
+
+// hunkWindows returns the merged new-file ranges covering each hunk plus
+// context lines on either side
+func hunkWindows(f *diff.File, lineCount, context int) []lineWindow {
+	var windows []lineWindow
+	for _, h := range f.Hunks {
+		if h.NewLines == 0 {
+			continue
+		}
+		start := h.NewStart - context
+		if start < 1 {
+			start = 1
+		}
+		end := h.NewStart + h.NewLines - 1 + context
+		if end > lineCount {
+			end = lineCount
+		}
+		if start > end {
+			continue
+		}
+		if n := len(windows); n > 0 && start <= windows[n-1].End+1 {
+			if end > windows[n-1].End {
+				windows[n-1].End = end
+			}
+			continue
+		}
+		windows = append(windows, lineWindow{Start: start, End: end})
+	}
+	return windows
+}
+
+// buildDiffReviewPrompt shows the model each window of the new file, with
+// new-file line numbers and added lines marked
+func buildDiffReviewPrompt(f *diff.File, newLines []string, windows []lineWindow, focus string) string {
+	added := make(map[int]bool)
+	for _, n := range f.AddedLines() {
+		added[n] = true
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Review the changes to %s and report findings as JSON.\n", f.NewPath))
+	if f.IsNew() {
+		sb.WriteString("This is a new file.\n")
+	} else if f.OldPath != f.NewPath {
+		sb.WriteString(fmt.Sprintf("The file was renamed from %s.\n", f.OldPath))
+	}
+	sb.WriteString("Lines marked with + were added or changed; the rest is surrounding context.\n")
+	sb.WriteString("Only report issues in the changed lines or caused by them.\n\n")
+	if focus != "" {
+		sb.WriteString(fmt.Sprintf("Focus especially on: %s\n\n", focus))
+	}
+
+	lang := detectLanguage(f.NewPath, "")
+	if lang == "auto-detect" {
+		lang = ""
+	}
+
+	for i, w := range windows {
+		if i > 0 {
+			sb.WriteString("\n")
+		}
+		sb.WriteString("```" + lang + "\n")
+		for n := w.Start; n <= w.End; n++ {
+			marker := " "
+			if added[n] {
+				marker = "+"
+			}
+			sb.WriteString(fmt.Sprintf("%s%5d | %s\n", marker, n, newLines[n-1]))
+		}
+		sb.WriteString("```\n")
+	}
+
+	return sb.String()
+}
+
+// validateInWindows anchors findings to file and keeps only those that start
+// inside a window the model was shown
+func validateInWindows(findings []ReviewFinding, file string, windows []lineWindow) *ReviewReport {
+	report := &ReviewReport{Findings: []ReviewFinding{}}
+
+	for _, f := range findings {
+		w, ok := windowFor(windows, f.StartLine)
+		if !ok {
+			report.Dropped++
+			continue
+		}
+		if f.EndLine < f.StartLine {
+			f.EndLine = f.StartLine
+		}
+		if f.EndLine > w.End {
+			f.EndLine = w.End
+		}
+		f.File = file
+		report.Findings = append(report.Findings, f)
+	}
+
+	return report
+}
+
+func windowFor(windows []lineWindow, line int) (lineWindow, bool) {
+	i := sort.Search(len(windows), func(i int) bool { return windows[i].End >= line })
+	if i < len(windows) && windows[i].Start <= line {
+		return windows[i], true
+	}
+	return lineWindow{}, false
+}
+
+// reviewParallelism returns how many files may be reviewed at once.
+// Local models serve one request at a time, so they get no parallelism.
+func reviewParallelism(b backend.Backend) int {
+	if b.Type() == backend.TypeLocal {
+		return 1
+	}
+	return maxParallelReviews
+}
+
+// executeDiff reviews a git revision range file by file
+func (c *ReviewCommand) executeDiff(
+	ctx context.Context,
+	args *command.Args,
+	execCtx *command.ExecContext,
+) (*command.Result, error) {
+	spec := ""
+	if len(args.Positional) > 0 {
+		spec = args.Positional[0]
+	}
+
+	source, err := parseDiffSpec(spec, args.HasFlag("staged"))
+	if err != nil {
+		return command.NewErrorResult(err.Error(),
+			"Use a range such as main..HEAD, a single revision, or --staged"), nil
+	}
+
+	if execCtx.Backend == nil {
+		return command.NewErrorResult(
+			"no backend available",
+			"Configure a backend with 'scmd config'",
+		), nil
+	}
+
+	root, err := runGit(ctx, ".", "rev-parse", "--show-toplevel")
+	if err != nil {
+		return command.NewErrorResult(err.Error(), "Run this command inside a git repository"), nil
+	}
+	root = strings.TrimSpace(root)
+
+	raw, err := runGit(ctx, root, source.GitArgs...)
+	if err != nil {
+		return command.NewErrorResult(err.Error(), "Check that the revisions exist"), nil
+	}
+
+	files, err := diff.Parse(raw)
+	if err != nil {
+		return command.NewErrorResult(fmt.Sprintf("cannot parse diff: %v", err)), nil
+	}
+	if len(files) == 0 {
+		return command.NewResult("No changes to review."), nil
+	}
+
+	reviewer := &diffReviewer{
+		backend:  execCtx.Backend,
+		git:      runGit,
+		root:     root,
+		source:   source,
+		focus:    args.GetOption("focus"),
+		parallel: reviewParallelism(execCtx.Backend),
+	}
+
+	stop := execCtx.UI.Spinner(fmt.Sprintf("Reviewing %d file(s)", len(files)))
+	report := reviewer.Review(ctx, files)
+	stop()
+
+	// Files that were reviewed are reported even when others failed; the
+	// failures are listed in the report and make the exit code non-zero
+	if report.reviewed == 0 && len(report.Errors) > 0 {
+		return command.NewErrorResult("review failed:\n  " + strings.Join(report.Errors, "\n  ")), nil
+	}
+
+	return renderReport(report, args.GetOption("report"), args.GetOption("report-file"))
+}
@@ -0,0 +1,242 @@
+package builtin
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/scmd/scmd/internal/backend"
+	"github.com/scmd/scmd/internal/command"
+	"github.com/scmd/scmd/internal/diff"
+	"github.com/scmd/scmd/tests/testutil"
+)
+
+// promptBackend answers with findings chosen by the file named in the prompt
+type promptBackend struct {
+	*testutil.MockBackend
+	mu        sync.Mutex
+	prompts   []string
+	responses map[string]string
+	fail      string
+}
+
+func (b *promptBackend) Complete(_ context.Context, req *backend.CompletionRequest) (*backend.CompletionResponse, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.prompts = append(b.prompts, req.Prompt)
+	if b.fail != "" && strings.Contains(req.Prompt, "changes to "+b.fail+" ") {
+		return nil, errors.New("backend unavailable")
+	}
+	for file, resp := range b.responses {
+		if strings.Contains(req.Prompt, "changes to "+file+" ") {
+			return &backend.CompletionResponse{Content: resp}, nil
+		}
+	}
+	return &backend.CompletionResponse{Content: `{"findings": []}`}, nil
+}
+
+func TestParseDiffSpec(t *testing.T) {
+	src, err := parseDiffSpec("", false)
+	require.NoError(t, err)
+	assert.Equal(t, "HEAD", src.GitArgs[len(src.GitArgs)-1])
+	assert.Equal(t, "", src.NewRev)
+
+	src, err = parseDiffSpec("main..feature", false)
+	require.NoError(t, err)
+	assert.Equal(t, "feature", src.NewRev)
+
+	src, err = parseDiffSpec("main..", false)
+	require.NoError(t, err)
+	assert.Equal(t, "HEAD", src.NewRev)
+
+	src, err = parseDiffSpec("main...feature", false)
+	require.NoError(t, err)
+	assert.Equal(t, "feature", src.NewRev)
+
+	src, err = parseDiffSpec("HEAD~2", false)
+	require.NoError(t, err)
+	assert.Equal(t, "", src.NewRev, "single revision compares with the working tree")
+
+	src, err = parseDiffSpec("", true)
+	require.NoError(t, err)
+	assert.Equal(t, ":", src.NewRev)
+	assert.Contains(t, src.GitArgs, "--staged")
+
+	_, err = parseDiffSpec("main..HEAD", true)
+	assert.Error(t, err)
+
+	_, err = parseDiffSpec("--output=/tmp/x", false)
+	assert.Error(t, err)
+}
+
+func TestHunkWindows(t *testing.T) {
+	f := &diff.File{Hunks: []diff.Hunk{
+		{NewStart: 5, NewLines: 2},
+		{NewStart: 12, NewLines: 1},
+		{NewStart: 80, NewLines: 3},
+		{NewStart: 90, NewLines: 0},
+	}}
+
+	windows := hunkWindows(f, 85, 3)
+	assert.Equal(t, []lineWindow{{Start: 2, End: 15}, {Start: 77, End: 85}}, windows)
+}
+
+func TestValidateInWindows(t *testing.T) {
+	windows := []lineWindow{{Start: 1, End: 10}, {Start: 40, End: 50}}
+	findings := []ReviewFinding{
+		{StartLine: 5, EndLine: 20, Severity: SeverityWarning, Message: "m"},
+		{StartLine: 25, Severity: SeverityError, Message: "outside"},
+		{StartLine: 45, Severity: SeverityNote, Message: "m"},
+	}
+
+	report := validateInWindows(findings, "a.go", windows)
+	require.Len(t, report.Findings, 2)
+	assert.Equal(t, 1, report.Dropped)
+	assert.Equal(t, 10, report.Findings[0].EndLine, "end clamped to window")
+	assert.Equal(t, 45, report.Findings[1].EndLine)
+}
+
+func gitCmd(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@example.com",
+		"GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@example.com")
+	out, err := cmd.CombinedOutput()
+	require.NoError(t, err, string(out))
+}
+
+// changedRepo creates a repository whose working tree changes line 20 of a.go
+// and adds line 2 to b.py
+func changedRepo(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+
+	dir := t.TempDir()
+	gitCmd(t, dir, "init", "-q")
+
+	var lines []string
+	for i := 1; i <= 30; i++ {
+		lines = append(lines, "line")
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a.go"), []byte(strings.Join(lines, "\n")+"\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "b.py"), []byte("x = 1\n"), 0644))
+	gitCmd(t, dir, "add", ".")
+	gitCmd(t, dir, "commit", "-q", "-m", "base")
+
+	// Change line 20 of a.go and add a line to b.py in the working tree
+	lines[19] = "changed"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a.go"), []byte(strings.Join(lines, "\n")+"\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "b.py"), []byte("x = 1\ny = x / 0\n"), 0644))
+	return dir
+}
+
+func TestDiffReviewer_Review(t *testing.T) {
+	dir := changedRepo(t)
+
+	source, err := parseDiffSpec("", false)
+	require.NoError(t, err)
+	raw, err := runGit(context.Background(), dir, source.GitArgs...)
+	require.NoError(t, err)
+	files, err := diff.Parse(raw)
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+
+	be := &promptBackend{
+		MockBackend: testutil.NewMockBackend(),
+		responses: map[string]string{
+			"a.go": `{"findings": [
+				{"start_line": 20, "severity": "warning", "rule": "naming", "message": "unclear"},
+				{"start_line": 2, "severity": "error", "rule": "bogus", "message": "not shown to the model"}
+			]}`,
+			"b.py": `{"findings": [{"start_line": 2, "severity": "error", "rule": "zero-division", "message": "divides by zero"}]}`,
+		},
+	}
+
+	reviewer := &diffReviewer{backend: be, git: runGit, root: dir, source: source, parallel: 2}
+	report := reviewer.Review(context.Background(), files)
+
+	require.Len(t, report.Findings, 2)
+	assert.Equal(t, 1, report.Dropped)
+	assert.Equal(t, "a.go", report.Findings[0].File)
+	assert.Equal(t, 20, report.Findings[0].StartLine)
+	assert.Equal(t, "b.py", report.Findings[1].File)
+	assert.Equal(t, ReviewExitError, report.ExitCode())
+
+	require.Len(t, be.prompts, 2)
+	for _, p := range be.prompts {
+		if strings.Contains(p, "a.go") {
+			assert.Contains(t, p, "+   20 | changed")
+			assert.Contains(t, p, "     7 | line", "context comes from the working tree")
+			assert.NotContains(t, p, "     6 | line", "context limited to the window")
+		}
+	}
+}
+
+func TestReviewCommand_DiffPartialFailure(t *testing.T) {
+	dir := changedRepo(t)
+	t.Chdir(dir)
+
+	args := command.NewArgs()
+	args.Flags["diff"] = true
+	be := &promptBackend{MockBackend: testutil.NewMockBackend(), fail: "b.py"}
+	execCtx := &command.ExecContext{Backend: be, UI: testutil.NewMockUI()}
+
+	result, err := NewReviewCommand().Execute(context.Background(), args, execCtx)
+	require.NoError(t, err)
+	assert.True(t, result.Success, result.Error)
+	assert.Equal(t, ReviewExitIncomplete, result.ExitCode)
+	assert.Contains(t, result.Output, "No issues found.")
+	assert.Contains(t, result.Output, "### Not reviewed\n\n- b.py: backend error: backend unavailable")
+
+	be = &promptBackend{MockBackend: testutil.NewMockBackend(), fail: "b.py", responses: map[string]string{
+		"a.go": `{"findings": [{"start_line": 20, "severity": "warning", "rule": "naming", "message": "unclear"}]}`,
+	}}
+	execCtx.Backend = be
+	result, err = NewReviewCommand().Execute(context.Background(), args, execCtx)
+	require.NoError(t, err)
+	assert.Equal(t, ReviewExitWarning, result.ExitCode, "findings keep their exit code")
+	assert.Contains(t, result.Output, "- b.py: backend error: backend unavailable")
+
+	failing := testutil.NewMockBackend()
+	failing.SetError(errors.New("backend unavailable"))
+	execCtx.Backend = failing
+	result, err = NewReviewCommand().Execute(context.Background(), args, execCtx)
+	require.NoError(t, err)
+	assert.False(t, result.Success)
+	assert.Contains(t, result.Error, "review failed:")
+	assert.Contains(t, result.Error, "a.go: backend error: backend unavailable")
+	assert.Contains(t, result.Error, "b.py: backend error: backend unavailable")
+}
+
+func TestReviewCommand_ValidateDiff(t *testing.T) {
+	cmd := NewReviewCommand()
+
+	args := command.NewArgs()
+	args.Flags["diff"] = true
+	assert.NoError(t, cmd.Validate(args), "diff mode needs no input")
+
+	args.Positional = []string{"a..b", "c..d"}
+	assert.Error(t, cmd.Validate(args))
+
+	args = command.NewArgs()
+	args.Flags["staged"] = true
+	args.Options["stdin"] = "code"
+	assert.Error(t, cmd.Validate(args), "--staged requires --diff")
+
+	args = command.NewArgs()
+	args.Flags["diff"] = true
+	args.Options["template"] = "security-review"
+	assert.Error(t, cmd.Validate(args))
+}
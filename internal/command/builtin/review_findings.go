@@ -0,0 +1,453 @@
+package builtin
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/scmd/scmd/internal/command"
+)
+
+// Severity is the severity of a review finding
+type Severity string
+
+// Severity levels, ordered from least to most severe.
+// They map directly onto SARIF result levels.
+const (
+	SeverityNote    Severity = "note"
+	SeverityWarning Severity = "warning"
+	SeverityError   Severity = "error"
+)
+
+// Exit codes for structured reviews. 1 is for tool failures so CI can
+// tell "the review found problems" apart from "the review did not run".
+const (
+	ReviewExitClean      = 0
+	ReviewExitIncomplete = 1
+	ReviewExitWarning    = 2
+	ReviewExitError      = 3
+)
+
+// ReviewFinding is a single line-anchored issue reported by a review
+type ReviewFinding struct {
+	File      string   `json:"file"`
+	StartLine int      `json:"start_line"`
+	EndLine   int      `json:"end_line"`
+	Severity  Severity `json:"severity"`
+	Rule      string   `json:"rule"`
+	Message   string   `json:"message"`
+	Fix       string   `json:"fix,omitempty"`
+}
+
+// ReviewReport is the structured result of a review
+type ReviewReport struct {
+	Findings []ReviewFinding `json:"findings"`
+	// Dropped counts findings discarded because they pointed outside the input
+	Dropped int `json:"dropped,omitempty"`
+	// Errors lists files that could not be reviewed
+	Errors []string `json:"errors,omitempty"`
+
+	// reviewed counts files reviewed without error
+	reviewed int
+}
+
+// rank orders severities so they can be compared
+func (s Severity) rank() int {
+	switch s {
+	case SeverityError:
+		return 3
+	case SeverityWarning:
+		return 2
+	case SeverityNote:
+		return 1
+	}
+	return 0
+}
+
+// normalizeSeverity maps the many ways a model spells severity onto our levels
+func normalizeSeverity(s string) Severity {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "error", "critical", "high", "blocker", "major":
+		return SeverityError
+	case "note", "info", "information", "low", "minor", "suggestion", "style", "nit":
+		return SeverityNote
+	}
+	return SeverityWarning
+}
+
+const findingsSystemPrompt = `You are an expert code reviewer that reports findings as JSON.
+Respond with a single JSON object and nothing else - no prose, no markdown fences.
+The object must match this shape exactly:
+{"findings": [{"start_line": 1, "end_line": 1, "severity": "error|warning|note", "rule": "kebab-case-rule-id", "message": "what is wrong and why", "fix": "concrete suggested change"}]}
+
+Rules:
+- Line numbers refer to the numbers shown in the left margin of the input.
+- Use "error" for bugs and security vulnerabilities, "warning" for likely problems
+  and performance concerns, "note" for style and readability.
+- Use short, stable rule ids such as "sql-injection", "nil-dereference", "unused-variable".
+- Report only real issues. If there are none, respond with {"findings": []}.`
+
+// buildFindingsPrompt builds a prompt asking for JSON findings over numbered lines
+func buildFindingsPrompt(content, subject, focus string) string {
+	var sb strings.Builder
+
+	sb.WriteString(fmt.Sprintf("Review the following code from %s and report findings as JSON.\n\n", subject))
+	if focus != "" {
+		sb.WriteString(fmt.Sprintf("Focus especially on: %s\n\n", focus))
+	}
+
+	sb.WriteString("```\n")
+	sb.WriteString(numberLines(content, 1))
+	sb.WriteString("```")
+
+	return sb.String()
+}
+
+// numberLines prefixes each line with its line number, starting at first
+func numberLines(content string, first int) string {
+	var sb strings.Builder
+	for i, line := range splitLines(content) {
+		sb.WriteString(fmt.Sprintf("%5d | %s\n", first+i, line))
+	}
+	return sb.String()
+}
+
+// splitLines splits content into lines without a trailing empty line
+func splitLines(content string) []string {
+	lines := strings.Split(strings.TrimRight(content, "\n"), "\n")
+	if len(lines) == 1 && lines[0] == "" {
+		return nil
+	}
+	return lines
+}
+
+// ParseFindings extracts findings from a model response.
+// It accepts a {"findings": [...]} object or a bare array, optionally
+// wrapped in a markdown code fence.
+func ParseFindings(raw string) ([]ReviewFinding, error) {
+	text := extractJSON(raw)
+	if text == "" {
+		return nil, fmt.Errorf("response does not contain JSON")
+	}
+
+	var wire struct {
+		Findings []rawFinding `json:"findings"`
+	}
+	if strings.HasPrefix(text, "[") {
+		if err := json.Unmarshal([]byte(text), &wire.Findings); err != nil {
+			return nil, fmt.Errorf("invalid findings JSON: %w", err)
+		}
+	} else if err := json.Unmarshal([]byte(text), &wire); err != nil {
+		return nil, fmt.Errorf("invalid findings JSON: %w", err)
+	}
+
+	findings := make([]ReviewFinding, 0, len(wire.Findings))
+	for _, f := range wire.Findings {
+		if strings.TrimSpace(f.Message) == "" {
+			continue
+		}
+		rule := strings.TrimSpace(f.Rule)
+		if rule == "" {
+			rule = "general"
+		}
+		findings = append(findings, ReviewFinding{
+			File:      f.File,
+			StartLine: f.StartLine,
+			EndLine:   f.EndLine,
+			Severity:  normalizeSeverity(f.Severity),
+			Rule:      rule,
+			Message:   strings.TrimSpace(f.Message),
+			Fix:       strings.TrimSpace(f.Fix),
+		})
+	}
+
+	return findings, nil
+}
+
+// rawFinding is the loosely-typed wire form of a finding
+type rawFinding struct {
+	File      string `json:"file"`
+	StartLine int    `json:"start_line"`
+	EndLine   int    `json:"end_line"`
+	Severity  string `json:"severity"`
+	Rule      string `json:"rule"`
+	Message   string `json:"message"`
+	Fix       string `json:"fix"`
+}
+
+// extractJSON returns the outermost JSON object or array in s
+func extractJSON(s string) string {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "```") {
+		if nl := strings.Index(s, "\n"); nl >= 0 {
+			s = s[nl+1:]
+		}
+		s = strings.TrimSuffix(strings.TrimSpace(s), "```")
+	}
+
+	start := strings.IndexAny(s, "{[")
+	if start < 0 {
+		return ""
+	}
+	closer := byte('}')
+	if s[start] == '[' {
+		closer = ']'
+	}
+	end := strings.LastIndexByte(s, closer)
+	if end < start {
+		return ""
+	}
+	return strings.TrimSpace(s[start : end+1])
+}
+
+// ValidateFindings anchors findings to file and drops those whose lines fall
+// outside [1, lineCount]. End lines are clamped to the input.
+func ValidateFindings(findings []ReviewFinding, file string, lineCount int) *ReviewReport {
+	report := &ReviewReport{Findings: []ReviewFinding{}}
+
+	for _, f := range findings {
+		if f.StartLine < 1 || f.StartLine > lineCount {
+			report.Dropped++
+			continue
+		}
+		if f.EndLine < f.StartLine {
+			f.EndLine = f.StartLine
+		}
+		if f.EndLine > lineCount {
+			f.EndLine = lineCount
+		}
+		f.File = file
+		report.Findings = append(report.Findings, f)
+	}
+
+	report.Sort()
+	return report
+}
+
+// Sort orders findings by file, then line, then severity
+func (r *ReviewReport) Sort() {
+	sort.SliceStable(r.Findings, func(i, j int) bool {
+		a, b := r.Findings[i], r.Findings[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.StartLine != b.StartLine {
+			return a.StartLine < b.StartLine
+		}
+		return a.Severity.rank() > b.Severity.rank()
+	})
+}
+
+// HighestSeverity returns the most severe level in the report, or "" if empty
+func (r *ReviewReport) HighestSeverity() Severity {
+	var highest Severity
+	for _, f := range r.Findings {
+		if f.Severity.rank() > highest.rank() {
+			highest = f.Severity
+		}
+	}
+	return highest
+}
+
+// ExitCode returns the process exit code for the report's highest severity.
+// A report with no warnings or errors that could not review every file is
+// incomplete rather than clean.
+func (r *ReviewReport) ExitCode() int {
+	switch r.HighestSeverity() {
+	case SeverityError:
+		return ReviewExitError
+	case SeverityWarning:
+		return ReviewExitWarning
+	}
+	if len(r.Errors) > 0 {
+		return ReviewExitIncomplete
+	}
+	return ReviewExitClean
+}
+
+// JSON renders the report as indented JSON
+func (r *ReviewReport) JSON() ([]byte, error) {
+	return json.MarshalIndent(r, "", "  ")
+}
+
+// Markdown renders the report for humans
+func (r *ReviewReport) Markdown() string {
+	var sb strings.Builder
+
+	sb.WriteString("## Review findings\n\n")
+	if len(r.Findings) == 0 {
+		sb.WriteString("No issues found.\n")
+	}
+
+	for _, f := range r.Findings {
+		lines := fmt.Sprintf("%d", f.StartLine)
+		if f.EndLine > f.StartLine {
+			lines = fmt.Sprintf("%d-%d", f.StartLine, f.EndLine)
+		}
+		sb.WriteString(fmt.Sprintf("- **%s** `%s:%s` [%s] %s\n", strings.ToUpper(string(f.Severity)), f.File, lines, f.Rule, f.Message))
+		if f.Fix != "" {
+			sb.WriteString(fmt.Sprintf("  - Fix: %s\n", f.Fix))
+		}
+	}
+
+	if len(r.Errors) > 0 {
+		sb.WriteString("\n### Not reviewed\n\n")
+		for _, e := range r.Errors {
+			sb.WriteString(fmt.Sprintf("- %s\n", e))
+		}
+	}
+
+	if r.Dropped > 0 {
+		sb.WriteString(fmt.Sprintf("\n_%d finding(s) discarded because they referenced lines outside the input._\n", r.Dropped))
+	}
+
+	return sb.String()
+}
+
+// SARIF renders the report as a SARIF 2.1.0 log
+func (r *ReviewReport) SARIF() ([]byte, error) {
+	type sarifText struct {
+		Text string `json:"text"`
+	}
+	type sarifRegion struct {
+		StartLine int `json:"startLine"`
+		EndLine   int `json:"endLine"`
+	}
+	type sarifArtifact struct {
+		URI string `json:"uri"`
+	}
+	type sarifPhysical struct {
+		ArtifactLocation sarifArtifact `json:"artifactLocation"`
+		Region           sarifRegion   `json:"region"`
+	}
+	type sarifLocation struct {
+		PhysicalLocation sarifPhysical `json:"physicalLocation"`
+	}
+	type sarifResult struct {
+		RuleID     string            `json:"ruleId"`
+		Level      string            `json:"level"`
+		Message    sarifText         `json:"message"`
+		Locations  []sarifLocation   `json:"locations"`
+		Properties map[string]string `json:"properties,omitempty"`
+	}
+	type sarifRule struct {
+		ID               string    `json:"id"`
+		ShortDescription sarifText `json:"shortDescription"`
+	}
+	type sarifDriver struct {
+		Name           string      `json:"name"`
+		InformationURI string      `json:"informationUri"`
+		Rules          []sarifRule `json:"rules"`
+	}
+	type sarifTool struct {
+		Driver sarifDriver `json:"driver"`
+	}
+	type sarifNotification struct {
+		Level   string    `json:"level"`
+		Message sarifText `json:"message"`
+	}
+	type sarifInvocation struct {
+		ExecutionSuccessful        bool                `json:"executionSuccessful"`
+		ToolExecutionNotifications []sarifNotification `json:"toolExecutionNotifications"`
+	}
+	type sarifRun struct {
+		Tool        sarifTool         `json:"tool"`
+		Invocations []sarifInvocation `json:"invocations,omitempty"`
+		Results     []sarifResult     `json:"results"`
+	}
+	type sarifLog struct {
+		Schema  string     `json:"$schema"`
+		Version string     `json:"version"`
+		Runs    []sarifRun `json:"runs"`
+	}
+
+	run := sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{
+			Name:           "scmd",
+			InformationURI: "https://github.com/scmd/scmd",
+			Rules:          []sarifRule{},
+		}},
+		Results: []sarifResult{},
+	}
+
+	seenRules := make(map[string]bool)
+	for _, f := range r.Findings {
+		if !seenRules[f.Rule] {
+			seenRules[f.Rule] = true
+			run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{
+				ID:               f.Rule,
+				ShortDescription: sarifText{Text: f.Rule},
+			})
+		}
+
+		result := sarifResult{
+			RuleID:  f.Rule,
+			Level:   string(f.Severity),
+			Message: sarifText{Text: f.Message},
+			Locations: []sarifLocation{{PhysicalLocation: sarifPhysical{
+				ArtifactLocation: sarifArtifact{URI: f.File},
+				Region:           sarifRegion{StartLine: f.StartLine, EndLine: f.EndLine},
+			}}},
+		}
+		if f.Fix != "" {
+			result.Properties = map[string]string{"suggestedFix": f.Fix}
+		}
+		run.Results = append(run.Results, result)
+	}
+
+	if len(r.Errors) > 0 {
+		invocation := sarifInvocation{}
+		for _, e := range r.Errors {
+			invocation.ToolExecutionNotifications = append(invocation.ToolExecutionNotifications,
+				sarifNotification{Level: "error", Message: sarifText{Text: e}})
+		}
+		run.Invocations = []sarifInvocation{invocation}
+	}
+
+	return json.MarshalIndent(sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{run},
+	}, "", "  ")
+}
+
+// Encode renders the report in the given machine-readable format
+func (r *ReviewReport) Encode(format string) ([]byte, error) {
+	switch format {
+	case "sarif":
+		return r.SARIF()
+	case "json":
+		return r.JSON()
+	}
+	return nil, fmt.Errorf("unknown report format '%s': must be one of: json, sarif", format)
+}
+
+// renderReport turns a report into a command result. With no format the
+// markdown rendering is returned; with a format the encoded report is
+// returned, or written to reportFile with markdown returned instead.
+// The exit code is set from the highest severity, or marks the review
+// incomplete when files could not be reviewed.
+func renderReport(report *ReviewReport, format, reportFile string) (*command.Result, error) {
+	output := report.Markdown()
+
+	if format != "" {
+		encoded, err := report.Encode(format)
+		if err != nil {
+			return command.NewErrorResult(err.Error()), nil
+		}
+
+		if reportFile != "" {
+			if err := os.WriteFile(reportFile, append(encoded, '\n'), 0644); err != nil {
+				return command.NewErrorResult(fmt.Sprintf("cannot write report: %v", err)), nil
+			}
+		} else {
+			output = string(encoded)
+		}
+	}
+
+	result := command.NewResult(output)
+	result.ExitCode = report.ExitCode()
+	return result, nil
+}
@@ -0,0 +1,222 @@
+package builtin
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/scmd/scmd/internal/backend"
+	"github.com/scmd/scmd/internal/command"
+	"github.com/scmd/scmd/tests/testutil"
+)
+
+// sequenceBackend returns a different response for each call
+type sequenceBackend struct {
+	*testutil.MockBackend
+	responses []string
+	prompts   []string
+	jsonMode  []bool
+	calls     int
+}
+
+func (b *sequenceBackend) Complete(_ context.Context, req *backend.CompletionRequest) (*backend.CompletionResponse, error) {
+	b.prompts = append(b.prompts, req.Prompt)
+	b.jsonMode = append(b.jsonMode, req.JSONMode)
+	resp := b.responses[b.calls]
+	b.calls++
+	return &backend.CompletionResponse{Content: resp}, nil
+}
+
+func TestParseFindings(t *testing.T) {
+	raw := "```json\n" + `{"findings": [
+		{"start_line": 3, "end_line": 4, "severity": "critical", "rule": "sql-injection", "message": "query built from input", "fix": "use placeholders"},
+		{"start_line": 1, "severity": "nit", "message": "rename x"},
+		{"start_line": 2, "severity": "high", "rule": "empty", "message": "  "}
+	]}` + "\n```"
+
+	findings, err := ParseFindings(raw)
+	require.NoError(t, err)
+	require.Len(t, findings, 2)
+
+	assert.Equal(t, SeverityError, findings[0].Severity)
+	assert.Equal(t, "sql-injection", findings[0].Rule)
+	assert.Equal(t, "use placeholders", findings[0].Fix)
+
+	assert.Equal(t, SeverityNote, findings[1].Severity)
+	assert.Equal(t, "general", findings[1].Rule)
+}
+
+func TestParseFindings_BareArray(t *testing.T) {
+	findings, err := ParseFindings(`Here you go: [{"start_line": 1, "severity": "warning", "rule": "r", "message": "m"}]`)
+	require.NoError(t, err)
+	require.Len(t, findings, 1)
+	assert.Equal(t, SeverityWarning, findings[0].Severity)
+}
+
+func TestParseFindings_Invalid(t *testing.T) {
+	_, err := ParseFindings("The code looks fine to me.")
+	assert.Error(t, err)
+
+	_, err = ParseFindings(`{"findings": [{"start_line": "one"}]}`)
+	assert.Error(t, err)
+}
+
+func TestRequestFindings_JSONModeAndRetry(t *testing.T) {
+	be := &sequenceBackend{MockBackend: testutil.NewMockBackend(), responses: []string{
+		"Looks fine to me!",
+		`{"findings": [{"start_line": 1, "severity": "info", "rule": "style", "message": "ok"}]}`,
+	}}
+
+	findings, err := requestFindings(context.Background(), be, "review this")
+	require.NoError(t, err)
+	require.Len(t, findings, 1)
+	assert.Contains(t, be.prompts[1], "could not be parsed")
+	assert.Equal(t, []bool{true, true}, be.jsonMode, "backends constrain output to JSON")
+}
+
+func TestValidateFindings(t *testing.T) {
+	findings := []ReviewFinding{
+		{StartLine: 5, EndLine: 2, Severity: SeverityWarning, Rule: "b", Message: "m"},
+		{StartLine: 1, EndLine: 99, Severity: SeverityError, Rule: "a", Message: "m"},
+		{StartLine: 0, Severity: SeverityError, Rule: "c", Message: "m"},
+		{StartLine: 11, Severity: SeverityError, Rule: "d", Message: "m"},
+	}
+
+	report := ValidateFindings(findings, "main.go", 10)
+
+	require.Len(t, report.Findings, 2)
+	assert.Equal(t, 2, report.Dropped)
+
+	assert.Equal(t, 1, report.Findings[0].StartLine)
+	assert.Equal(t, 10, report.Findings[0].EndLine, "end line clamped to input")
+	assert.Equal(t, 5, report.Findings[1].EndLine, "end line raised to start line")
+	assert.Equal(t, "main.go", report.Findings[1].File)
+}
+
+func TestReviewReport_ExitCode(t *testing.T) {
+	report := &ReviewReport{}
+	assert.Equal(t, ReviewExitClean, report.ExitCode())
+
+	report.Errors = []string{"b.py: backend unavailable"}
+	assert.Equal(t, ReviewExitIncomplete, report.ExitCode())
+	report.Errors = nil
+
+	report.Findings = []ReviewFinding{{Severity: SeverityNote}}
+	assert.Equal(t, ReviewExitClean, report.ExitCode())
+
+	report.Findings = append(report.Findings, ReviewFinding{Severity: SeverityWarning})
+	assert.Equal(t, ReviewExitWarning, report.ExitCode())
+
+	report.Findings = append(report.Findings, ReviewFinding{Severity: SeverityError})
+	assert.Equal(t, ReviewExitError, report.ExitCode())
+	assert.Equal(t, SeverityError, report.HighestSeverity())
+}
+
+func TestReviewReport_SARIF(t *testing.T) {
+	report := &ReviewReport{Findings: []ReviewFinding{
+		{File: "a.go", StartLine: 3, EndLine: 4, Severity: SeverityError, Rule: "nil-deref", Message: "may be nil", Fix: "check err"},
+		{File: "a.go", StartLine: 8, EndLine: 8, Severity: SeverityNote, Rule: "nil-deref", Message: "again"},
+	}}
+
+	data, err := report.SARIF()
+	require.NoError(t, err)
+
+	var log map[string]interface{}
+	require.NoError(t, json.Unmarshal(data, &log))
+	assert.Equal(t, "2.1.0", log["version"])
+
+	run := log["runs"].([]interface{})[0].(map[string]interface{})
+	rules := run["tool"].(map[string]interface{})["driver"].(map[string]interface{})["rules"].([]interface{})
+	assert.Len(t, rules, 1, "rules are deduplicated")
+
+	results := run["results"].([]interface{})
+	require.Len(t, results, 2)
+	first := results[0].(map[string]interface{})
+	assert.Equal(t, "error", first["level"])
+	region := first["locations"].([]interface{})[0].(map[string]interface{})["physicalLocation"].(map[string]interface{})["region"].(map[string]interface{})
+	assert.Equal(t, float64(3), region["startLine"])
+	assert.Equal(t, float64(4), region["endLine"])
+	assert.Nil(t, run["invocations"])
+
+	report.Errors = []string{"b.py: backend unavailable"}
+	data, err = report.SARIF()
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, &log))
+	run = log["runs"].([]interface{})[0].(map[string]interface{})
+	invocation := run["invocations"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, false, invocation["executionSuccessful"])
+	notification := invocation["toolExecutionNotifications"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, "b.py: backend unavailable", notification["message"].(map[string]interface{})["text"])
+}
+
+func TestReviewCommand_Report(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "main.go")
+	require.NoError(t, os.WriteFile(src, []byte("package main\n\nfunc main() {\n\tpanic(nil)\n}\n"), 0644))
+
+	be := testutil.NewMockBackend()
+	be.SetResponse(`{"findings": [
+		{"start_line": 4, "end_line": 4, "severity": "warning", "rule": "panic", "message": "avoid panic"},
+		{"start_line": 40, "severity": "error", "rule": "bogus", "message": "hallucinated"}
+	]}`)
+
+	args := command.NewArgs()
+	args.Positional = []string{src}
+	args.Options["report"] = "json"
+
+	result, err := NewReviewCommand().Execute(context.Background(), args, &command.ExecContext{UI: testutil.NewMockUI(), Backend: be})
+	require.NoError(t, err)
+	require.True(t, result.Success, result.Error)
+	assert.Equal(t, ReviewExitWarning, result.ExitCode)
+
+	var report ReviewReport
+	require.NoError(t, json.Unmarshal([]byte(result.Output), &report))
+	require.Len(t, report.Findings, 1)
+	assert.Equal(t, 1, report.Dropped)
+	assert.Equal(t, filepath.ToSlash(src), report.Findings[0].File)
+}
+
+func TestReviewCommand_ReportFile(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "review.sarif")
+
+	be := &sequenceBackend{
+		MockBackend: testutil.NewMockBackend(),
+		responses: []string{
+			"Looks good overall!",
+			`{"findings": [{"start_line": 1, "severity": "error", "rule": "bug", "message": "broken"}]}`,
+		},
+	}
+
+	args := command.NewArgs()
+	args.Options["stdin"] = "x := 1/0\n"
+	args.Options["report"] = "sarif"
+	args.Options["report-file"] = out
+
+	result, err := NewReviewCommand().Execute(context.Background(), args, &command.ExecContext{UI: testutil.NewMockUI(), Backend: be})
+	require.NoError(t, err)
+	require.True(t, result.Success, result.Error)
+
+	assert.Equal(t, 2, be.calls, "retries once after unparseable response")
+	assert.Equal(t, ReviewExitError, result.ExitCode)
+	assert.Contains(t, result.Output, "**ERROR** `stdin:1`")
+
+	data, err := os.ReadFile(out)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"version": "2.1.0"`)
+}
+
+func TestReviewCommand_ValidateReport(t *testing.T) {
+	args := command.NewArgs()
+	args.Options["stdin"] = "code"
+	args.Options["report"] = "xml"
+	assert.Error(t, NewReviewCommand().Validate(args))
+
+	args.Options["report"] = "sarif"
+	args.Options["template"] = "security-review"
+	assert.Error(t, NewReviewCommand().Validate(args))
+}
@@ -0,0 +1,346 @@
+package builtin
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/scmd/scmd/internal/backend"
+	"github.com/scmd/scmd/internal/command"
+)
+
+// maxSummarizeInput caps the amount of input sent to the backend.
+// Longer input keeps its head and tail, which is where logs and documents
+// usually carry the most signal.
+const maxSummarizeInput = 48000
+
+// SummarizeCommand implements /summarize
+type SummarizeCommand struct{}
+
+// NewSummarizeCommand creates a new summarize command
+func NewSummarizeCommand() *SummarizeCommand {
+	return &SummarizeCommand{}
+}
+
+// Name returns the command name
+func (c *SummarizeCommand) Name() string { return "summarize" }
+
+// Aliases returns command aliases
+func (c *SummarizeCommand) Aliases() []string { return []string{"sum", "tldr"} }
+
+// Description returns the command description
+func (c *SummarizeCommand) Description() string { return "Summarize text, logs, or diffs" }
+
+// Usage returns usage information
+func (c *SummarizeCommand) Usage() string {
+	return "/summarize <file|text> [--length short|medium|long] [--style bullets|paragraph]"
+}
+
+// Category returns the command category
+func (c *SummarizeCommand) Category() command.Category { return command.CategoryCore }
+
+// RequiresBackend returns true
+func (c *SummarizeCommand) RequiresBackend() bool { return true }
+
+// Examples returns example usages
+func (c *SummarizeCommand) Examples() []string {
+	return []string{
+		"/summarize article.md",
+		"cat server.log | scmd /tldr",
+		"git diff main | scmd summarize --length short",
+		"scmd summarize notes.txt --style paragraph",
+	}
+}
+
+// Validate validates arguments
+func (c *SummarizeCommand) Validate(args *command.Args) error {
+	stdin, hasStdin := args.Options["stdin"]
+	stdinEmpty := !hasStdin || strings.TrimSpace(stdin) == ""
+
+	if len(args.Positional) == 0 && stdinEmpty {
+		return fmt.Errorf("no input provided\n\nUsage:\n  scmd summarize <file>\n  cat file.txt | scmd summarize\n  scmd /tldr < server.log")
+	}
+
+	if length := args.GetOption("length"); length != "" && summaryLengths[length] == "" {
+		return fmt.Errorf("invalid length '%s': must be one of: short, medium, long", length)
+	}
+
+	if style := args.GetOption("style"); style != "" && style != "bullets" && style != "paragraph" {
+		return fmt.Errorf("invalid style '%s': must be one of: bullets, paragraph", style)
+	}
+
+	if inputType := args.GetOption("type"); inputType != "" && inputType != "auto" && !isInputType(inputType) {
+		return fmt.Errorf("invalid type '%s': must be one of: auto, log, diff, prose, code", inputType)
+	}
+
+	return nil
+}
+
+// Execute runs the summarize command
+func (c *SummarizeCommand) Execute(
+	ctx context.Context,
+	args *command.Args,
+	execCtx *command.ExecContext,
+) (*command.Result, error) {
+	if err := c.Validate(args); err != nil {
+		return command.NewErrorResult(err.Error()), nil
+	}
+
+	var content string
+	var subject string
+
+	if stdin, ok := args.Options["stdin"]; ok && strings.TrimSpace(stdin) != "" {
+		content = stdin
+		subject = "piped input"
+	} else {
+		target := args.Positional[0]
+		if len(args.Positional) == 1 && isFile(target) {
+			data, err := os.ReadFile(target)
+			if err != nil {
+				return command.NewErrorResult(
+					fmt.Sprintf("cannot read file: %v", err),
+					"Check the file path",
+					"Ensure you have read permissions",
+				), nil
+			}
+			content = string(data)
+			subject = filepath.Base(target)
+		} else {
+			content = strings.Join(args.Positional, " ")
+			subject = "text"
+		}
+	}
+
+	if strings.TrimSpace(content) == "" {
+		return command.NewErrorResult("empty input provided - nothing to summarize"), nil
+	}
+
+	inputType := args.GetOptionOrDefault("type", "auto")
+	if inputType == "auto" {
+		inputType = DetectInputType(content)
+	}
+
+	opts := summaryOptions{
+		Length: args.GetOptionOrDefault("length", "medium"),
+		Style:  args.GetOptionOrDefault("style", "bullets"),
+		Type:   inputType,
+	}
+
+	if execCtx.Backend == nil {
+		return command.NewErrorResult(
+			"no backend available",
+			"Configure a backend with 'scmd config'",
+		), nil
+	}
+
+	stop := execCtx.UI.Spinner("Summarizing")
+	defer stop()
+
+	req := &backend.CompletionRequest{
+		Prompt:       buildSummarizePrompt(content, subject, opts),
+		SystemPrompt: summarizeSystemPrompt,
+		MaxTokens:    summaryMaxTokens[opts.Length],
+		Temperature:  0.2,
+	}
+
+	resp, err := execCtx.Backend.Complete(ctx, req)
+	if err != nil {
+		return command.NewErrorResult(
+			fmt.Sprintf("backend error: %v", err),
+		), nil
+	}
+
+	return command.NewResult(resp.Content), nil
+}
+
+const summarizeSystemPrompt = `You are a precise summarizer. Capture what matters and nothing else.
+Never invent details that are not present in the input. Preserve exact names,
+numbers, file paths, and error messages when you mention them.
+Format your response in markdown.`
+
+// summaryOptions controls the shape of a summary
+type summaryOptions struct {
+	Length string
+	Style  string
+	Type   string
+}
+
+var summaryLengths = map[string]string{
+	"short":  "Keep it very brief: at most 3 bullet points or 2 sentences.",
+	"medium": "Keep it concise: about 5 bullet points or one short paragraph.",
+	"long":   "Be thorough: cover every significant point, grouped under short headings where useful.",
+}
+
+var summaryMaxTokens = map[string]int{
+	"short":  256,
+	"medium": 768,
+	"long":   2048,
+}
+
+var summaryTypeInstructions = map[string]string{
+	"log": `The input is log output. Report:
+- The overall outcome (did the process succeed or fail?)
+- Errors and warnings, grouped by cause, with how often each occurred
+- The first failure and anything that looks like its root cause
+- The time range covered, if timestamps are present`,
+	"diff": `The input is a diff. Report:
+- What changed, file by file, in terms of behavior rather than lines
+- The intent of the change as a whole
+- Anything risky: removed checks, changed public APIs, migrations`,
+	"code": `The input is source code. Report:
+- What the code does and its main entry points
+- Key types, functions, and data flow
+- Notable dependencies or side effects`,
+	"prose": `The input is prose. Report:
+- The main point or conclusion
+- Supporting points and key facts
+- Any decisions, action items, or open questions`,
+}
+
+func isInputType(t string) bool {
+	_, ok := summaryTypeInstructions[t]
+	return ok
+}
+
+var (
+	logLinePattern = regexp.MustCompile(
+		`(?i)^\s*(\[?\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}|\[?\d{2}:\d{2}:\d{2}|[A-Z][a-z]{2}\s+\d+\s+\d{2}:\d{2}:\d{2}|\[?(trace|debug|info|warn|warning|error|fatal|crit|critical)\]?[\s:])`)
+	logLevelPattern = regexp.MustCompile(`(?i)\b(TRACE|DEBUG|INFO|WARN|WARNING|ERROR|FATAL|CRITICAL|PANIC)\b`)
+	codeLinePattern = regexp.MustCompile(
+		`^\s*(func |def |class |import |package |#include|public |private |const |let |var |fn |return\b|}\s*$|\w+\s*:?=\s*.+[;{]?\s*$)`)
+)
+
+// DetectInputType guesses whether content is a diff, log, source code or prose
+func DetectInputType(content string) string {
+	lines := nonEmptyLines(content)
+	if len(lines) == 0 {
+		return "prose"
+	}
+
+	var diffMarkers, hunks, logLines, codeLines int
+	for _, line := range lines {
+		switch {
+		case strings.HasPrefix(line, "diff --git "), strings.HasPrefix(line, "+++ "), strings.HasPrefix(line, "--- "):
+			diffMarkers++
+		case strings.HasPrefix(line, "@@ "):
+			hunks++
+		}
+		if logLinePattern.MatchString(line) {
+			logLines++
+		}
+		if codeLinePattern.MatchString(line) {
+			codeLines++
+		}
+	}
+
+	if hunks > 0 && diffMarkers > 0 {
+		return "diff"
+	}
+	if logLines*10 >= len(lines)*4 {
+		return "log"
+	}
+	if codeLines*10 >= len(lines)*3 {
+		return "code"
+	}
+	return "prose"
+}
+
+// buildSummarizePrompt builds the user prompt for a summary
+func buildSummarizePrompt(content, subject string, opts summaryOptions) string {
+	var sb strings.Builder
+
+	sb.WriteString(fmt.Sprintf("Summarize the following %s from %s.\n\n", opts.Type, subject))
+	sb.WriteString(summaryTypeInstructions[opts.Type])
+	sb.WriteString("\n\n")
+
+	if stats := describeInput(content, opts.Type); stats != "" {
+		sb.WriteString("Input statistics (computed, exact):\n")
+		sb.WriteString(stats)
+		sb.WriteString("\n\n")
+	}
+
+	sb.WriteString(summaryLengths[opts.Length])
+	sb.WriteString("\n")
+	if opts.Style == "paragraph" {
+		sb.WriteString("Write the summary as flowing paragraphs, without bullet points.\n\n")
+	} else {
+		sb.WriteString("Write the summary as a bulleted list.\n\n")
+	}
+
+	sb.WriteString("```\n")
+	sb.WriteString(truncateMiddle(content, maxSummarizeInput))
+	sb.WriteString("\n```")
+
+	return sb.String()
+}
+
+// describeInput computes exact statistics the model would otherwise guess at
+func describeInput(content, inputType string) string {
+	switch inputType {
+	case "log":
+		counts := make(map[string]int)
+		var order []string
+		for _, line := range nonEmptyLines(content) {
+			m := logLevelPattern.FindString(line)
+			if m == "" {
+				continue
+			}
+			level := strings.ToUpper(m)
+			if level == "WARNING" {
+				level = "WARN"
+			}
+			if counts[level] == 0 {
+				order = append(order, level)
+			}
+			counts[level]++
+		}
+		if len(order) == 0 {
+			return ""
+		}
+		parts := make([]string, 0, len(order))
+		for _, level := range order {
+			parts = append(parts, fmt.Sprintf("%s=%d", level, counts[level]))
+		}
+		return fmt.Sprintf("- %d lines; levels: %s", len(nonEmptyLines(content)), strings.Join(parts, ", "))
+
+	case "diff":
+		var files, added, removed int
+		for _, line := range strings.Split(content, "\n") {
+			switch {
+			case strings.HasPrefix(line, "+++ "):
+				files++
+			case strings.HasPrefix(line, "--- "):
+			case strings.HasPrefix(line, "+"):
+				added++
+			case strings.HasPrefix(line, "-"):
+				removed++
+			}
+		}
+		return fmt.Sprintf("- %d file(s) changed, %d line(s) added, %d line(s) removed", files, added, removed)
+	}
+
+	return ""
+}
+
+// truncateMiddle keeps the head and tail of s when it exceeds max bytes
+func truncateMiddle(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	half := max / 2
+	omitted := strings.Count(s[half:len(s)-half], "\n")
+	return s[:half] + fmt.Sprintf("\n\n... (%d lines omitted) ...\n\n", omitted) + s[len(s)-half:]
+}
+
+func nonEmptyLines(s string) []string {
+	var lines []string
+	for _, line := range strings.Split(s, "\n") {
+		if strings.TrimSpace(line) != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
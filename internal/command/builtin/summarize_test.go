@@ -0,0 +1,154 @@
+package builtin
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/scmd/scmd/internal/command"
+	"github.com/scmd/scmd/tests/testutil"
+)
+
+func TestSummarizeCommand_Aliases(t *testing.T) {
+	cmd := NewSummarizeCommand()
+
+	assert.Equal(t, "summarize", cmd.Name())
+	assert.Contains(t, cmd.Aliases(), "sum")
+	assert.Contains(t, cmd.Aliases(), "tldr")
+	assert.True(t, cmd.RequiresBackend())
+}
+
+func TestSummarizeCommand_Validate(t *testing.T) {
+	cmd := NewSummarizeCommand()
+
+	args := command.NewArgs()
+	assert.Error(t, cmd.Validate(args))
+
+	args.Options["stdin"] = "some text"
+	assert.NoError(t, cmd.Validate(args))
+
+	args.Options["length"] = "huge"
+	assert.Error(t, cmd.Validate(args))
+
+	args.Options["length"] = "short"
+	args.Options["style"] = "haiku"
+	assert.Error(t, cmd.Validate(args))
+
+	args.Options["style"] = "paragraph"
+	args.Options["type"] = "spreadsheet"
+	assert.Error(t, cmd.Validate(args))
+}
+
+func TestDetectInputType(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{
+			name: "git diff",
+			content: `diff --git a/main.go b/main.go
+--- a/main.go
++++ b/main.go
+@@ -1,3 +1,4 @@
+ package main
++import "fmt"`,
+			want: "diff",
+		},
+		{
+			name: "timestamped log",
+			content: `2024-01-02 10:00:01 INFO starting server
+2024-01-02 10:00:02 WARN cache miss
+2024-01-02 10:00:03 ERROR connection refused`,
+			want: "log",
+		},
+		{
+			name: "level-prefixed log",
+			content: `[INFO] build started
+[ERROR] compilation failed
+[INFO] done`,
+			want: "log",
+		},
+		{
+			name: "go code",
+			content: `package main
+
+func main() {
+	x := 1
+	return
+}`,
+			want: "code",
+		},
+		{
+			name:    "prose",
+			content: "The meeting covered the roadmap for next quarter. We agreed to ship the new onboarding flow first.",
+			want:    "prose",
+		},
+		{
+			name:    "empty",
+			content: "   ",
+			want:    "prose",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, DetectInputType(tt.content))
+		})
+	}
+}
+
+func TestBuildSummarizePrompt(t *testing.T) {
+	log := "2024-01-02 10:00:01 INFO ok\n2024-01-02 10:00:02 ERROR boom\n2024-01-02 10:00:03 ERROR boom again"
+	prompt := buildSummarizePrompt(log, "piped input", summaryOptions{Length: "short", Style: "paragraph", Type: "log"})
+
+	assert.Contains(t, prompt, "log output")
+	assert.Contains(t, prompt, "INFO=1, ERROR=2")
+	assert.Contains(t, prompt, summaryLengths["short"])
+	assert.Contains(t, prompt, "without bullet points")
+
+	diff := "--- a/x\n+++ b/x\n@@ -1 +1 @@\n-old\n+new\n+more"
+	prompt = buildSummarizePrompt(diff, "diff", summaryOptions{Length: "medium", Style: "bullets", Type: "diff"})
+	assert.Contains(t, prompt, "1 file(s) changed, 2 line(s) added, 1 line(s) removed")
+	assert.Contains(t, prompt, "bulleted list")
+}
+
+func TestTruncateMiddle(t *testing.T) {
+	assert.Equal(t, "short", truncateMiddle("short", 100))
+
+	long := ""
+	for i := 0; i < 100; i++ {
+		long += "line of text\n"
+	}
+	out := truncateMiddle(long, 200)
+	assert.Contains(t, out, "lines omitted")
+	assert.Less(t, len(out), len(long))
+}
+
+func TestSummarizeCommand_Execute(t *testing.T) {
+	cmd := NewSummarizeCommand()
+	ui := testutil.NewMockUI()
+	be := testutil.NewMockBackend()
+	be.SetResponse("- the summary")
+
+	args := command.NewArgs()
+	args.Options["stdin"] = "Some article text that needs summarizing."
+
+	result, err := cmd.Execute(context.Background(), args, &command.ExecContext{UI: ui, Backend: be})
+	require.NoError(t, err)
+	assert.True(t, result.Success)
+	assert.Equal(t, "- the summary", result.Output)
+}
+
+func TestSummarizeCommand_Execute_NoBackend(t *testing.T) {
+	cmd := NewSummarizeCommand()
+	args := command.NewArgs()
+	args.Positional = []string{"summarize", "this"}
+
+	result, err := cmd.Execute(context.Background(), args, &command.ExecContext{UI: testutil.NewMockUI()})
+	require.NoError(t, err)
+	assert.False(t, result.Success)
+	assert.Contains(t, result.Error, "no backend")
+}
@@ -0,0 +1,89 @@
+package builtin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/scmd/scmd/internal/command"
+	"github.com/scmd/scmd/internal/diff"
+)
+
+// UndoCommand implements /undo
+type UndoCommand struct{}
+
+// NewUndoCommand creates a new undo command
+func NewUndoCommand() *UndoCommand {
+	return &UndoCommand{}
+}
+
+// Name returns the command name
+func (c *UndoCommand) Name() string { return "undo" }
+
+// Aliases returns command aliases
+func (c *UndoCommand) Aliases() []string { return []string{} }
+
+// Description returns the command description
+func (c *UndoCommand) Description() string { return "Revert the last patch applied with --apply" }
+
+// Usage returns usage information
+func (c *UndoCommand) Usage() string { return "/undo [--force]" }
+
+// Category returns the command category
+func (c *UndoCommand) Category() command.Category { return command.CategoryCore }
+
+// RequiresBackend returns false
+func (c *UndoCommand) RequiresBackend() bool { return false }
+
+// Examples returns example usages
+func (c *UndoCommand) Examples() []string {
+	return []string{
+		"scmd undo",
+		"scmd undo --force   # revert even files edited since the patch",
+		"/undo",
+	}
+}
+
+// Validate validates arguments
+func (c *UndoCommand) Validate(args *command.Args) error {
+	if len(args.Positional) > 0 {
+		return fmt.Errorf("undo takes no arguments")
+	}
+	return nil
+}
+
+// Execute runs the undo command
+func (c *UndoCommand) Execute(
+	ctx context.Context,
+	args *command.Args,
+	execCtx *command.ExecContext,
+) (*command.Result, error) {
+	if err := c.Validate(args); err != nil {
+		return command.NewErrorResult(err.Error()), nil
+	}
+
+	journal := diff.NewJournal(undoDir(execCtx))
+	entry, err := journal.Undo(args.HasFlag("force"))
+	if errors.Is(err, diff.ErrModified) {
+		return command.NewErrorResult(err.Error(),
+			"Nothing was reverted; undo would overwrite the edits made since",
+			"Run 'scmd undo --force' to revert anyway",
+		), nil
+	}
+	if err != nil {
+		return command.NewErrorResult(err.Error()), nil
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("✓ Reverted %s (%s)\n", entry.Description, entry.Time.Format("2006-01-02 15:04:05")))
+	for _, f := range entry.Files {
+		if f.Existed {
+			sb.WriteString(fmt.Sprintf("  restored %s\n", f.Path))
+		} else {
+			sb.WriteString(fmt.Sprintf("  removed  %s\n", f.Path))
+		}
+	}
+
+	return command.NewResult(strings.TrimRight(sb.String(), "\n")), nil
+}
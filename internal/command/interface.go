@@ -28,6 +28,13 @@ type Command interface {
 	RequiresBackend() bool
 }
 
+// FlagDeclarer is implemented by commands that take --flags when run as
+// slash commands. Only the flags a command declares are parsed; anything
+// else that looks like a flag is an ordinary argument.
+type FlagDeclarer interface {
+	FlagNames() []string
+}
+
 // Category classifies commands
 type Category string
 
@@ -45,6 +52,8 @@ type Args struct {
 	Flags      map[string]bool
 	Options    map[string]string
 	Raw        string
+	// Data is the structured output of the previous pipeline step, if any
+	Data interface{}
 }
 
 // NewArgs creates a new Args instance
@@ -81,6 +90,9 @@ type Result struct {
 	Error       string
 	Suggestions []string
 	ExitCode    int
+	// Data is the parsed value behind Output for commands with structured
+	// (JSON) output
+	Data interface{}
 }
 
 // NewResult creates a successful result
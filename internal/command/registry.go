@@ -43,6 +43,27 @@ func (r *Registry) Register(cmd Command) error {
 	return nil
 }
 
+// Replace adds a command to the registry, replacing any command of the same
+// name and taking over its aliases
+func (r *Registry) Replace(cmd Command) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	name := cmd.Name()
+	if old, exists := r.commands[name]; exists {
+		for _, alias := range old.Aliases() {
+			if r.aliases[alias] == name {
+				delete(r.aliases, alias)
+			}
+		}
+	}
+
+	r.commands[name] = cmd
+	for _, alias := range cmd.Aliases() {
+		r.aliases[alias] = name
+	}
+}
+
 // Get retrieves a command by name or alias
 func (r *Registry) Get(nameOrAlias string) (Command, bool) {
 	r.mu.RLock()
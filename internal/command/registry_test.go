@@ -75,6 +75,27 @@ func TestRegistry_Register_DuplicateAlias(t *testing.T) {
 	assert.Error(t, err)
 }
 
+func TestRegistry_Replace(t *testing.T) {
+	r := NewRegistry()
+
+	require.NoError(t, r.Register(&mockCommand{name: "test", aliases: []string{"t", "old"}}))
+
+	replacement := &mockCommand{name: "test", aliases: []string{"t", "new"}}
+	r.Replace(replacement)
+
+	cmd, ok := r.Get("test")
+	require.True(t, ok)
+	assert.Same(t, replacement, cmd)
+
+	cmd, ok = r.Get("new")
+	require.True(t, ok)
+	assert.Same(t, replacement, cmd)
+
+	_, ok = r.Get("old")
+	assert.False(t, ok)
+	assert.Equal(t, 1, r.Count())
+}
+
 func TestRegistry_Get_NotFound(t *testing.T) {
 	r := NewRegistry()
 
@@ -13,6 +13,7 @@ type Config struct {
 	Backends       BackendsConfig `mapstructure:"backends"`
 	UI             UIConfig       `mapstructure:"ui"`
 	Models         ModelsConfig   `mapstructure:"models"`
+	Exec           ExecConfig     `mapstructure:"exec"`
 	SetupCompleted bool           `mapstructure:"setup_completed"`
 }
 
@@ -44,6 +45,18 @@ type ModelsConfig struct {
 	AutoDownload bool   `mapstructure:"auto_download"`
 }
 
+// ExecConfig limits the programs hooks and the shell tool run
+type ExecConfig struct {
+	Env              []string `mapstructure:"env"`               // Variables passed through besides the defaults
+	Timeout          int      `mapstructure:"timeout"`           // Seconds of wall-clock time
+	MaxOutput        int      `mapstructure:"max_output"`        // Bytes kept of stdout and of stderr
+	CPU              int      `mapstructure:"cpu"`               // Seconds of CPU time
+	Memory           int      `mapstructure:"memory"`            // Megabytes of address space
+	NoNetwork        bool     `mapstructure:"no_network"`        // Run without network where possible
+	RequireIsolation bool     `mapstructure:"require_isolation"` // Fail rather than run with network
+	Audit            bool     `mapstructure:"audit"`             // Record every run in audit.log
+}
+
 // DataDir returns the scmd data directory
 func DataDir() string {
 	// Check for environment variable first (useful for testing)
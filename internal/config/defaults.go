@@ -26,5 +26,12 @@ func Default() *Config {
 			Directory:    filepath.Join(DataDir(), "models"),
 			AutoDownload: true,
 		},
+		Exec: ExecConfig{
+			Timeout:   30,
+			MaxOutput: 1024 * 1024,
+			CPU:       60,
+			Memory:    4096,
+			Audit:     true,
+		},
 	}
 }
@@ -24,6 +24,14 @@ func Load() (*Config, error) {
 	v.SetDefault("ui.verbose", defaults.UI.Verbose)
 	v.SetDefault("models.directory", defaults.Models.Directory)
 	v.SetDefault("models.auto_download", defaults.Models.AutoDownload)
+	v.SetDefault("exec.env", defaults.Exec.Env)
+	v.SetDefault("exec.timeout", defaults.Exec.Timeout)
+	v.SetDefault("exec.max_output", defaults.Exec.MaxOutput)
+	v.SetDefault("exec.cpu", defaults.Exec.CPU)
+	v.SetDefault("exec.memory", defaults.Exec.Memory)
+	v.SetDefault("exec.no_network", defaults.Exec.NoNetwork)
+	v.SetDefault("exec.require_isolation", defaults.Exec.RequireIsolation)
+	v.SetDefault("exec.audit", defaults.Exec.Audit)
 
 	// Config file
 	v.SetConfigName("config")
@@ -62,6 +70,14 @@ func Save(cfg *Config) error {
 	v.Set("backends", cfg.Backends)
 	v.Set("ui", cfg.UI)
 	v.Set("models", cfg.Models)
+	v.Set("exec.env", cfg.Exec.Env)
+	v.Set("exec.timeout", cfg.Exec.Timeout)
+	v.Set("exec.max_output", cfg.Exec.MaxOutput)
+	v.Set("exec.cpu", cfg.Exec.CPU)
+	v.Set("exec.memory", cfg.Exec.Memory)
+	v.Set("exec.no_network", cfg.Exec.NoNetwork)
+	v.Set("exec.require_isolation", cfg.Exec.RequireIsolation)
+	v.Set("exec.audit", cfg.Exec.Audit)
 
 	return v.WriteConfigAs(filepath.Join(dir, "config.yaml"))
 }
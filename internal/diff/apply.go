@@ -0,0 +1,164 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Placement records where a hunk matched in the current file
+type Placement struct {
+	// Start is the 0-based index of the first matched old line
+	Start int
+	// Offset is how far Start is from the position the hunk header claims
+	Offset int
+	// Fuzz is how many context lines were ignored at each end to match
+	Fuzz int
+	// Trim holds the hunk lines actually matched after fuzz was applied
+	Trim []Line
+}
+
+// HunkError explains why a hunk could not be placed
+type HunkError struct {
+	File string
+	Hunk int
+	Err  string
+}
+
+// Error implements the error interface
+func (e *HunkError) Error() string {
+	return fmt.Sprintf("%s: hunk #%d: %s", e.File, e.Hunk+1, e.Err)
+}
+
+// Resolve finds where each hunk of f applies in lines, the current content
+// of the file. Up to maxFuzz context lines may be dropped from either end of a
+// hunk, like patch(1). Trailing whitespace is ignored when comparing lines.
+func Resolve(f *File, lines []string, maxFuzz int) ([]Placement, error) {
+	placements := make([]Placement, len(f.Hunks))
+	offset := 0
+	next := 0 // hunks must apply in order and must not overlap
+
+	for i, h := range f.Hunks {
+		placed := false
+		for fuzz := 0; fuzz <= maxFuzz && !placed; fuzz++ {
+			trim, lead, ok := trimContext(h.Lines, fuzz)
+			if !ok {
+				break
+			}
+			old := oldSide(trim)
+
+			base := h.OldStart - 1 + lead
+			if h.OldLines == 0 {
+				// Pure insertion: OldStart is the line after which to insert
+				base = h.OldStart
+			}
+
+			start, found := search(lines, old, base+offset, next)
+			if !found {
+				continue
+			}
+
+			offset = start - base
+			placements[i] = Placement{Start: start, Offset: offset, Fuzz: fuzz, Trim: trim}
+			next = start + len(old)
+			placed = true
+		}
+
+		if !placed {
+			return nil, &HunkError{File: f.Path(), Hunk: i, Err: "context does not match the current file"}
+		}
+	}
+
+	return placements, nil
+}
+
+// Apply applies the accepted hunks at their placements and returns the new
+// lines. accept may be nil to apply every hunk.
+func Apply(lines []string, placements []Placement, accept []bool) []string {
+	out := make([]string, 0, len(lines))
+	pos := 0
+
+	for i, p := range placements {
+		if accept != nil && !accept[i] {
+			continue
+		}
+		out = append(out, lines[pos:p.Start]...)
+		pos = p.Start
+
+		for _, l := range p.Trim {
+			switch l.Kind {
+			case LineContext:
+				// Keep the file's version of the line, whitespace included
+				out = append(out, lines[pos])
+				pos++
+			case LineRemoved:
+				pos++
+			case LineAdded:
+				out = append(out, l.Text)
+			}
+		}
+	}
+
+	return append(out, lines[pos:]...)
+}
+
+// trimContext drops up to fuzz context lines from each end of a hunk.
+// It returns the trimmed lines and how many lines were dropped at the start.
+func trimContext(lines []Line, fuzz int) ([]Line, int, bool) {
+	lead := 0
+	for lead < fuzz && lead < len(lines) && lines[lead].Kind == LineContext {
+		lead++
+	}
+	tail := 0
+	for tail < fuzz && len(lines)-1-tail > lead && lines[len(lines)-1-tail].Kind == LineContext {
+		tail++
+	}
+	if fuzz > 0 && lead+tail == 0 {
+		// Nothing left to trim, more fuzz will not help
+		return nil, 0, false
+	}
+	return lines[lead : len(lines)-tail], lead, true
+}
+
+func oldSide(lines []Line) []string {
+	var old []string
+	for _, l := range lines {
+		if l.Kind != LineAdded {
+			old = append(old, l.Text)
+		}
+	}
+	return old
+}
+
+// search looks for old in lines starting at expected and moving outward,
+// never matching before min
+func search(lines, old []string, expected, min int) (int, bool) {
+	maxStart := len(lines) - len(old)
+	if expected < min {
+		expected = min
+	}
+	if expected > maxStart {
+		expected = maxStart
+	}
+
+	for delta := 0; ; delta++ {
+		before, after := expected-delta, expected+delta
+		if before < min && after > maxStart {
+			return 0, false
+		}
+		if after <= maxStart && after >= min && matchAt(lines, old, after) {
+			return after, true
+		}
+		if delta > 0 && before >= min && before <= maxStart && matchAt(lines, old, before) {
+			return before, true
+		}
+	}
+}
+
+func matchAt(lines, old []string, at int) bool {
+	for i, o := range old {
+		if strings.TrimRight(lines[at+i], " \t\r") != strings.TrimRight(o, " \t\r") {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,133 @@
+package diff
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func parseOne(t *testing.T, text string) *File {
+	t.Helper()
+	files, err := Parse(text)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	return &files[0]
+}
+
+func numbered(n int) []string {
+	lines := make([]string, n)
+	for i := range lines {
+		lines[i] = "line " + string(rune('a'+i))
+	}
+	return lines
+}
+
+func TestResolve_Exact(t *testing.T) {
+	f := parseOne(t, `--- a/x
++++ b/x
+@@ -2,3 +2,3 @@
+ line b
+-line c
++line C
+ line d
+`)
+	lines := numbered(6)
+
+	placements, err := Resolve(f, lines, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 1, placements[0].Start)
+	assert.Equal(t, 0, placements[0].Offset)
+
+	out := Apply(lines, placements, nil)
+	assert.Equal(t, "line a,line b,line C,line d,line e,line f", strings.Join(out, ","))
+}
+
+func TestResolve_Offset(t *testing.T) {
+	f := parseOne(t, `--- a/x
++++ b/x
+@@ -2,3 +2,3 @@
+ line b
+-line c
++line C
+ line d
+@@ -5,2 +5,3 @@
+ line e
++inserted
+ line f
+`)
+	// Two extra lines at the top shift everything down
+	lines := append([]string{"new 1", "new 2"}, numbered(6)...)
+
+	placements, err := Resolve(f, lines, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 2, placements[0].Offset)
+	assert.Equal(t, 2, placements[1].Offset)
+
+	out := Apply(lines, placements, nil)
+	assert.Equal(t, "new 1,new 2,line a,line b,line C,line d,line e,inserted,line f", strings.Join(out, ","))
+}
+
+func TestResolve_Fuzz(t *testing.T) {
+	f := parseOne(t, `--- a/x
++++ b/x
+@@ -2,3 +2,3 @@
+ stale context
+-line c
++line C
+ line d
+`)
+	lines := numbered(5)
+
+	_, err := Resolve(f, lines, 0)
+	require.Error(t, err)
+	var hunkErr *HunkError
+	require.ErrorAs(t, err, &hunkErr)
+	assert.Equal(t, 0, hunkErr.Hunk)
+
+	placements, err := Resolve(f, lines, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 1, placements[0].Fuzz)
+
+	out := Apply(lines, placements, nil)
+	assert.Equal(t, "line a,line b,line C,line d,line e", strings.Join(out, ","))
+}
+
+func TestResolve_IgnoresTrailingWhitespace(t *testing.T) {
+	f := parseOne(t, "--- a/x\n+++ b/x\n@@ -1,2 +1,2 @@\n keep\n-old\n+new\n")
+
+	placements, err := Resolve(f, []string{"keep  ", "old\r"}, 0)
+	require.NoError(t, err)
+
+	out := Apply([]string{"keep  ", "old\r"}, placements, nil)
+	assert.Equal(t, []string{"keep  ", "new"}, out, "context keeps the file's own whitespace")
+}
+
+func TestApply_PartialAccept(t *testing.T) {
+	f := parseOne(t, `--- a/x
++++ b/x
+@@ -1,2 +1,2 @@
+-line a
++line A
+ line b
+@@ -5,2 +5,2 @@
+ line e
+-line f
++line F
+`)
+	lines := numbered(6)
+	placements, err := Resolve(f, lines, 0)
+	require.NoError(t, err)
+
+	out := Apply(lines, placements, []bool{false, true})
+	assert.Equal(t, "line a,line b,line c,line d,line e,line F", strings.Join(out, ","))
+}
+
+func TestResolve_NewFile(t *testing.T) {
+	f := parseOne(t, "--- /dev/null\n+++ b/x\n@@ -0,0 +1,2 @@\n+one\n+two\n")
+
+	placements, err := Resolve(f, nil, 0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"one", "two"}, Apply(nil, placements, nil))
+}
@@ -0,0 +1,344 @@
+package diff
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxJournalEntries is how many applied changes are kept for undo
+const maxJournalEntries = 20
+
+// ErrModified is returned by Undo when files were changed after the patch
+// was applied, so restoring them would lose those edits
+var ErrModified = errors.New("changed since the patch was applied")
+
+// Change is the new content for one file. Delete removes the file instead.
+type Change struct {
+	Path    string
+	Content string
+	Delete  bool
+}
+
+// Journal stores backups of files changed by applied patches so the most
+// recent change can be undone. Each entry is a directory holding a manifest
+// and a copy of every file as it was before the change.
+type Journal struct {
+	dir string
+}
+
+// JournalEntry describes one applied change
+type JournalEntry struct {
+	ID          string        `json:"id"`
+	Description string        `json:"description"`
+	Time        time.Time     `json:"time"`
+	Files       []JournalFile `json:"files"`
+	dir         string
+}
+
+// JournalFile is the pre-change state of one file, and what the change
+// left there
+type JournalFile struct {
+	Path    string      `json:"path"`
+	Existed bool        `json:"existed"`
+	Mode    os.FileMode `json:"mode,omitempty"`
+	Backup  string      `json:"backup,omitempty"`
+	// Applied is the sha256 of the content written, and Deleted is set if
+	// the change removed the file instead. Entries from before they were
+	// recorded have neither.
+	Applied string `json:"applied,omitempty"`
+	Deleted bool   `json:"deleted,omitempty"`
+}
+
+// NewJournal creates a journal rooted at dir
+func NewJournal(dir string) *Journal {
+	return &Journal{dir: dir}
+}
+
+// WriteAtomic backs up the files touched by changes into the journal and then
+// writes all changes. New content is staged in temporary files next to each
+// target and renamed into place; if any step fails, files already replaced are
+// restored and the journal entry is discarded.
+func (j *Journal) WriteAtomic(description string, changes []Change) (*JournalEntry, error) {
+	entry, err := j.record(description, changes)
+	if err != nil {
+		return nil, err
+	}
+
+	// Stage every file first so a write error leaves the tree untouched
+	temps := make([]string, len(changes))
+	cleanup := func() {
+		for _, t := range temps {
+			if t != "" {
+				os.Remove(t)
+			}
+		}
+	}
+
+	for i, c := range changes {
+		if c.Delete {
+			continue
+		}
+		mode := os.FileMode(0644)
+		if entry.Files[i].Existed {
+			mode = entry.Files[i].Mode
+		}
+		if err := os.MkdirAll(filepath.Dir(c.Path), 0755); err != nil {
+			cleanup()
+			os.RemoveAll(entry.dir)
+			return nil, fmt.Errorf("create directory for %s: %w", c.Path, err)
+		}
+		tmp, err := writeTemp(c.Path, c.Content, mode)
+		if err != nil {
+			cleanup()
+			os.RemoveAll(entry.dir)
+			return nil, err
+		}
+		temps[i] = tmp
+	}
+
+	for i, c := range changes {
+		var err error
+		if c.Delete {
+			err = os.Remove(c.Path)
+		} else {
+			err = os.Rename(temps[i], c.Path)
+			temps[i] = ""
+		}
+		if err != nil {
+			cleanup()
+			restoreErr := entry.restore(i)
+			os.RemoveAll(entry.dir)
+			if restoreErr != nil {
+				return nil, fmt.Errorf("apply %s: %w (rollback failed: %v)", c.Path, err, restoreErr)
+			}
+			return nil, fmt.Errorf("apply %s: %w", c.Path, err)
+		}
+	}
+
+	j.prune()
+	return entry, nil
+}
+
+// record saves backups of every file in changes and writes the manifest
+func (j *Journal) record(description string, changes []Change) (*JournalEntry, error) {
+	now := time.Now()
+	entry := &JournalEntry{
+		ID:          now.UTC().Format("20060102T150405.000000000"),
+		Description: description,
+		Time:        now,
+	}
+	entry.dir = filepath.Join(j.dir, entry.ID)
+
+	if err := os.MkdirAll(entry.dir, 0700); err != nil {
+		return nil, fmt.Errorf("create undo journal: %w", err)
+	}
+
+	for i, c := range changes {
+		abs, err := filepath.Abs(c.Path)
+		if err != nil {
+			os.RemoveAll(entry.dir)
+			return nil, err
+		}
+		jf := JournalFile{Path: abs, Deleted: c.Delete}
+		if !c.Delete {
+			jf.Applied = contentHash([]byte(c.Content))
+		}
+
+		info, err := os.Stat(abs)
+		switch {
+		case err == nil:
+			data, err := os.ReadFile(abs)
+			if err != nil {
+				os.RemoveAll(entry.dir)
+				return nil, fmt.Errorf("back up %s: %w", c.Path, err)
+			}
+			jf.Existed = true
+			jf.Mode = info.Mode().Perm()
+			jf.Backup = fmt.Sprintf("%d.orig", i)
+			if err := os.WriteFile(filepath.Join(entry.dir, jf.Backup), data, 0600); err != nil {
+				os.RemoveAll(entry.dir)
+				return nil, fmt.Errorf("back up %s: %w", c.Path, err)
+			}
+		case !os.IsNotExist(err):
+			os.RemoveAll(entry.dir)
+			return nil, fmt.Errorf("stat %s: %w", c.Path, err)
+		}
+
+		entry.Files = append(entry.Files, jf)
+	}
+
+	data, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		os.RemoveAll(entry.dir)
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(entry.dir, "manifest.json"), data, 0600); err != nil {
+		os.RemoveAll(entry.dir)
+		return nil, fmt.Errorf("write undo journal: %w", err)
+	}
+
+	return entry, nil
+}
+
+// Last returns the most recent entry, or nil if the journal is empty
+func (j *Journal) Last() (*JournalEntry, error) {
+	ids, err := j.ids()
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+
+	id := ids[len(ids)-1]
+	data, err := os.ReadFile(filepath.Join(j.dir, id, "manifest.json"))
+	if err != nil {
+		return nil, fmt.Errorf("read undo journal: %w", err)
+	}
+
+	var entry JournalEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, fmt.Errorf("parse undo journal: %w", err)
+	}
+	entry.dir = filepath.Join(j.dir, id)
+
+	return &entry, nil
+}
+
+// Undo reverts the most recent entry and removes it from the journal.
+// Unless force is set, it fails with ErrModified if any file was changed
+// after the entry was applied.
+func (j *Journal) Undo(force bool) (*JournalEntry, error) {
+	entry, err := j.Last()
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, fmt.Errorf("nothing to undo")
+	}
+	if modified := entry.Modified(); len(modified) > 0 && !force {
+		return entry, fmt.Errorf("%s %w", strings.Join(modified, ", "), ErrModified)
+	}
+
+	if err := entry.restore(len(entry.Files)); err != nil {
+		return nil, err
+	}
+
+	if err := os.RemoveAll(entry.dir); err != nil {
+		return nil, fmt.Errorf("remove undo entry: %w", err)
+	}
+	return entry, nil
+}
+
+// Modified returns the files whose content is no longer what the entry
+// applied
+func (e *JournalEntry) Modified() []string {
+	var modified []string
+	for _, f := range e.Files {
+		data, err := os.ReadFile(f.Path)
+		switch {
+		case f.Deleted:
+			if !os.IsNotExist(err) {
+				modified = append(modified, f.Path)
+			}
+		case f.Applied != "":
+			if err != nil || contentHash(data) != f.Applied {
+				modified = append(modified, f.Path)
+			}
+		}
+	}
+	return modified
+}
+
+func contentHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// restore puts back the first n files of the entry
+func (e *JournalEntry) restore(n int) error {
+	var failed []string
+	for _, f := range e.Files[:n] {
+		var err error
+		if f.Existed {
+			var data []byte
+			data, err = os.ReadFile(filepath.Join(e.dir, f.Backup))
+			if err == nil {
+				var tmp string
+				tmp, err = writeTemp(f.Path, string(data), f.Mode)
+				if err == nil {
+					err = os.Rename(tmp, f.Path)
+				}
+			}
+		} else if err = os.Remove(f.Path); os.IsNotExist(err) {
+			err = nil
+		}
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", f.Path, err))
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("restore failed for %s", strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+// ids returns entry ids in chronological order
+func (j *Journal) ids() ([]string, error) {
+	entries, err := os.ReadDir(j.dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read undo journal: %w", err)
+	}
+
+	var ids []string
+	for _, e := range entries {
+		if e.IsDir() {
+			ids = append(ids, e.Name())
+		}
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// prune drops the oldest entries beyond maxJournalEntries
+func (j *Journal) prune() {
+	ids, err := j.ids()
+	if err != nil {
+		return
+	}
+	for len(ids) > maxJournalEntries {
+		os.RemoveAll(filepath.Join(j.dir, ids[0]))
+		ids = ids[1:]
+	}
+}
+
+// writeTemp writes content to a temporary file in the same directory as path
+func writeTemp(path, content string, mode os.FileMode) (string, error) {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".scmd-*")
+	if err != nil {
+		return "", fmt.Errorf("stage %s: %w", path, err)
+	}
+	if _, err := tmp.WriteString(content); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("stage %s: %w", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("stage %s: %w", path, err)
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("stage %s: %w", path, err)
+	}
+	return tmp.Name(), nil
+}
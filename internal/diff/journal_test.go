@@ -0,0 +1,124 @@
+package diff
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJournal_WriteAndUndo(t *testing.T) {
+	root := t.TempDir()
+	journal := NewJournal(filepath.Join(t.TempDir(), "undo"))
+
+	existing := filepath.Join(root, "a.txt")
+	created := filepath.Join(root, "sub", "b.txt")
+	require.NoError(t, os.WriteFile(existing, []byte("before\n"), 0600))
+
+	entry, err := journal.WriteAtomic("test change", []Change{
+		{Path: existing, Content: "after\n"},
+		{Path: created, Content: "new\n"},
+	})
+	require.NoError(t, err)
+	assert.Len(t, entry.Files, 2)
+
+	data, err := os.ReadFile(existing)
+	require.NoError(t, err)
+	assert.Equal(t, "after\n", string(data))
+
+	info, err := os.Stat(existing)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm(), "mode preserved")
+
+	last, err := journal.Last()
+	require.NoError(t, err)
+	assert.Equal(t, "test change", last.Description)
+
+	undone, err := journal.Undo(false)
+	require.NoError(t, err)
+	assert.Equal(t, entry.ID, undone.ID)
+
+	data, err = os.ReadFile(existing)
+	require.NoError(t, err)
+	assert.Equal(t, "before\n", string(data))
+	assert.NoFileExists(t, created)
+
+	_, err = journal.Undo(false)
+	assert.Error(t, err, "nothing left to undo")
+}
+
+func TestJournal_UndoRefusesEditedFiles(t *testing.T) {
+	root := t.TempDir()
+	journal := NewJournal(filepath.Join(t.TempDir(), "undo"))
+
+	edited := filepath.Join(root, "a.txt")
+	deleted := filepath.Join(root, "b.txt")
+	require.NoError(t, os.WriteFile(edited, []byte("before\n"), 0644))
+	require.NoError(t, os.WriteFile(deleted, []byte("old\n"), 0644))
+
+	_, err := journal.WriteAtomic("test change", []Change{
+		{Path: edited, Content: "after\n"},
+		{Path: deleted, Delete: true},
+	})
+	require.NoError(t, err)
+
+	// The user keeps working on both files after the patch
+	require.NoError(t, os.WriteFile(edited, []byte("after\nmore work\n"), 0644))
+	require.NoError(t, os.WriteFile(deleted, []byte("recreated\n"), 0644))
+
+	_, err = journal.Undo(false)
+	require.ErrorIs(t, err, ErrModified)
+	assert.Contains(t, err.Error(), edited+", "+deleted)
+	data, err := os.ReadFile(edited)
+	require.NoError(t, err)
+	assert.Equal(t, "after\nmore work\n", string(data), "nothing restored")
+
+	_, err = journal.Undo(true)
+	require.NoError(t, err)
+	data, err = os.ReadFile(edited)
+	require.NoError(t, err)
+	assert.Equal(t, "before\n", string(data))
+	data, err = os.ReadFile(deleted)
+	require.NoError(t, err)
+	assert.Equal(t, "old\n", string(data))
+}
+
+func TestJournal_RollbackOnFailure(t *testing.T) {
+	root := t.TempDir()
+	journal := NewJournal(filepath.Join(t.TempDir(), "undo"))
+
+	good := filepath.Join(root, "good.txt")
+	require.NoError(t, os.WriteFile(good, []byte("original\n"), 0644))
+
+	// Deleting a file that does not exist fails after good.txt was replaced
+	_, err := journal.WriteAtomic("broken", []Change{
+		{Path: good, Content: "changed\n"},
+		{Path: filepath.Join(root, "missing.txt"), Delete: true},
+	})
+	require.Error(t, err)
+
+	data, err := os.ReadFile(good)
+	require.NoError(t, err)
+	assert.Equal(t, "original\n", string(data))
+
+	last, err := journal.Last()
+	require.NoError(t, err)
+	assert.Nil(t, last, "failed changes leave no journal entry")
+}
+
+func TestJournal_Prune(t *testing.T) {
+	root := t.TempDir()
+	journal := NewJournal(filepath.Join(t.TempDir(), "undo"))
+	path := filepath.Join(root, "f.txt")
+
+	for i := 0; i < maxJournalEntries+3; i++ {
+		_, err := journal.WriteAtomic("change", []Change{{Path: path, Content: "x\n"}})
+		require.NoError(t, err)
+	}
+
+	ids, err := journal.ids()
+	require.NoError(t, err)
+	assert.Len(t, ids, maxJournalEntries)
+}
@@ -0,0 +1,255 @@
+// Package diff parses unified diffs such as those produced by git diff
+package diff
+
+import (
+	"bufio"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// LineKind identifies the role of a line within a hunk
+type LineKind byte
+
+const (
+	LineContext LineKind = ' '
+	LineAdded   LineKind = '+'
+	LineRemoved LineKind = '-'
+)
+
+// Line is a single line of a hunk.
+// OldLine and NewLine are 1-based and zero when the line does not exist on that side.
+type Line struct {
+	Kind    LineKind
+	Text    string
+	OldLine int
+	NewLine int
+}
+
+// Hunk is a contiguous block of changes
+type Hunk struct {
+	OldStart int
+	OldLines int
+	NewStart int
+	NewLines int
+	Section  string // text after the closing @@, usually the enclosing function
+	Lines    []Line
+}
+
+// File is the set of changes to a single file
+type File struct {
+	OldPath string
+	NewPath string
+	Binary  bool
+	Hunks   []Hunk
+}
+
+// IsNew reports whether the file was created by the diff
+func (f *File) IsNew() bool { return f.OldPath == "" }
+
+// IsDeleted reports whether the file was removed by the diff
+func (f *File) IsDeleted() bool { return f.NewPath == "" }
+
+// Path returns the most relevant path for the file
+func (f *File) Path() string {
+	if f.NewPath != "" {
+		return f.NewPath
+	}
+	return f.OldPath
+}
+
+// AddedLines returns the new-file line numbers of added lines
+func (f *File) AddedLines() []int {
+	var lines []int
+	for _, h := range f.Hunks {
+		for _, l := range h.Lines {
+			if l.Kind == LineAdded {
+				lines = append(lines, l.NewLine)
+			}
+		}
+	}
+	return lines
+}
+
+var hunkHeader = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@ ?(.*)$`)
+
+// Parse parses a unified diff containing one or more files.
+// Both git-style (diff --git) and plain (---/+++) diffs are accepted.
+func Parse(text string) ([]File, error) {
+	var files []File
+	var cur *File
+	var hunk *Hunk
+	var oldLine, newLine, oldLeft, newLeft int
+
+	flushHunk := func() {
+		if cur != nil && hunk != nil {
+			cur.Hunks = append(cur.Hunks, *hunk)
+		}
+		hunk = nil
+	}
+	flushFile := func() {
+		flushHunk()
+		if cur != nil {
+			files = append(files, *cur)
+		}
+		cur = nil
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+	lineNo := 0
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		lineNo++
+
+		// Inside a hunk, consume body lines until the declared counts run out
+		if hunk != nil && (oldLeft > 0 || newLeft > 0) {
+			if line == "" {
+				// Some tools strip the leading space of empty context lines
+				line = " "
+			}
+			switch LineKind(line[0]) {
+			case LineContext:
+				hunk.Lines = append(hunk.Lines, Line{Kind: LineContext, Text: line[1:], OldLine: oldLine, NewLine: newLine})
+				oldLine++
+				newLine++
+				oldLeft--
+				newLeft--
+				continue
+			case LineRemoved:
+				hunk.Lines = append(hunk.Lines, Line{Kind: LineRemoved, Text: line[1:], OldLine: oldLine})
+				oldLine++
+				oldLeft--
+				continue
+			case LineAdded:
+				hunk.Lines = append(hunk.Lines, Line{Kind: LineAdded, Text: line[1:], NewLine: newLine})
+				newLine++
+				newLeft--
+				continue
+			case '\\':
+				continue
+			}
+			return nil, fmt.Errorf("line %d: unexpected line in hunk: %q", lineNo, line)
+		}
+
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			flushFile()
+			cur = &File{}
+			if a, b, ok := splitGitPaths(strings.TrimPrefix(line, "diff --git ")); ok {
+				cur.OldPath, cur.NewPath = a, b
+			}
+
+		case strings.HasPrefix(line, "--- "):
+			flushHunk()
+			if cur == nil || len(cur.Hunks) > 0 {
+				flushFile()
+				cur = &File{}
+			}
+			cur.OldPath = parsePath(strings.TrimPrefix(line, "--- "))
+
+		case strings.HasPrefix(line, "+++ "):
+			if cur == nil {
+				return nil, fmt.Errorf("line %d: '+++' without preceding '---'", lineNo)
+			}
+			cur.NewPath = parsePath(strings.TrimPrefix(line, "+++ "))
+
+		case strings.HasPrefix(line, "new file mode"):
+			if cur != nil {
+				cur.OldPath = ""
+			}
+
+		case strings.HasPrefix(line, "deleted file mode"):
+			if cur != nil {
+				cur.NewPath = ""
+			}
+
+		case strings.HasPrefix(line, "Binary files "), line == "GIT binary patch":
+			if cur != nil {
+				cur.Binary = true
+			}
+
+		case strings.HasPrefix(line, "@@ "):
+			if cur == nil {
+				return nil, fmt.Errorf("line %d: hunk without file header", lineNo)
+			}
+			flushHunk()
+			m := hunkHeader.FindStringSubmatch(line)
+			if m == nil {
+				return nil, fmt.Errorf("line %d: malformed hunk header: %q", lineNo, line)
+			}
+			hunk = &Hunk{
+				OldStart: atoiDefault(m[1], 0),
+				OldLines: atoiDefault(m[2], 1),
+				NewStart: atoiDefault(m[3], 0),
+				NewLines: atoiDefault(m[4], 1),
+				Section:  strings.TrimSpace(m[5]),
+			}
+			oldLine, newLine = hunk.OldStart, hunk.NewStart
+			oldLeft, newLeft = hunk.OldLines, hunk.NewLines
+			// A zero count means the side is empty and the start is the line before
+			if hunk.OldLines == 0 {
+				oldLine++
+			}
+			if hunk.NewLines == 0 {
+				newLine++
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if hunk != nil && (oldLeft > 0 || newLeft > 0) {
+		return nil, fmt.Errorf("truncated hunk in %s: expected %d more old and %d more new lines",
+			cur.Path(), oldLeft, newLeft)
+	}
+	flushFile()
+
+	return files, nil
+}
+
+// parsePath strips the a/ b/ prefixes and timestamps from a ---/+++ path.
+// /dev/null becomes the empty string.
+func parsePath(s string) string {
+	if i := strings.IndexByte(s, '\t'); i >= 0 {
+		s = s[:i]
+	}
+	s = strings.TrimSpace(s)
+	if s == "/dev/null" {
+		return ""
+	}
+	if unq, err := strconv.Unquote(s); err == nil && strings.HasPrefix(s, `"`) {
+		s = unq
+	}
+	if strings.HasPrefix(s, "a/") || strings.HasPrefix(s, "b/") {
+		s = s[2:]
+	}
+	return s
+}
+
+// splitGitPaths splits the "a/x b/x" part of a diff --git header
+func splitGitPaths(s string) (string, string, bool) {
+	if !strings.HasPrefix(s, "a/") {
+		return "", "", false
+	}
+	// Paths are identical except for the prefix in the common case
+	idx := strings.Index(s, " b/")
+	if idx < 0 {
+		return "", "", false
+	}
+	return s[2:idx], s[idx+3:], true
+}
+
+func atoiDefault(s string, def int) int {
+	if s == "" {
+		return def
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return def
+	}
+	return n
+}
@@ -0,0 +1,115 @@
+package diff
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const gitDiff = `diff --git a/main.go b/main.go
+index 1111111..2222222 100644
+--- a/main.go
++++ b/main.go
+@@ -1,5 +1,6 @@ package main
+ package main
+ 
+-import "fmt"
++import (
++	"fmt"
++)
+ 
+ func main() {
+@@ -10,2 +11,3 @@ func main() {
+ 	a()
++	b()
+ }
+diff --git a/new.txt b/new.txt
+new file mode 100644
+index 0000000..3333333
+--- /dev/null
++++ b/new.txt
+@@ -0,0 +1,2 @@
++hello
++world
+diff --git a/old.txt b/old.txt
+deleted file mode 100644
+index 4444444..0000000
+--- a/old.txt
++++ /dev/null
+@@ -1 +0,0 @@
+-bye
+diff --git a/logo.png b/logo.png
+index 5555555..6666666 100644
+Binary files a/logo.png and b/logo.png differ
+`
+
+func TestParse_GitDiff(t *testing.T) {
+	files, err := Parse(gitDiff)
+	require.NoError(t, err)
+	require.Len(t, files, 4)
+
+	mainGo := files[0]
+	assert.Equal(t, "main.go", mainGo.OldPath)
+	assert.Equal(t, "main.go", mainGo.NewPath)
+	require.Len(t, mainGo.Hunks, 2)
+	assert.Equal(t, "package main", mainGo.Hunks[0].Section)
+	assert.Equal(t, []int{3, 4, 5, 12}, mainGo.AddedLines())
+
+	removed := mainGo.Hunks[0].Lines[2]
+	assert.Equal(t, LineRemoved, removed.Kind)
+	assert.Equal(t, 3, removed.OldLine)
+	assert.Equal(t, 0, removed.NewLine)
+
+	newTxt := files[1]
+	assert.True(t, newTxt.IsNew())
+	assert.Equal(t, "new.txt", newTxt.Path())
+	assert.Equal(t, []int{1, 2}, newTxt.AddedLines())
+
+	oldTxt := files[2]
+	assert.True(t, oldTxt.IsDeleted())
+	assert.Equal(t, "old.txt", oldTxt.Path())
+	assert.Equal(t, 1, oldTxt.Hunks[0].Lines[0].OldLine)
+
+	assert.True(t, files[3].Binary)
+	assert.Empty(t, files[3].Hunks)
+}
+
+func TestParse_PlainDiff(t *testing.T) {
+	text := `--- a.txt	2024-01-01 00:00:00
++++ a.txt	2024-01-02 00:00:00
+@@ -1,2 +1,2 @@
+ one
+-two
++TWO
+\ No newline at end of file
+--- b.txt
++++ b.txt
+@@ -1 +1 @@
+-x
++y
+`
+	files, err := Parse(text)
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+	assert.Equal(t, "a.txt", files[0].NewPath)
+	assert.Equal(t, []int{2}, files[0].AddedLines())
+	assert.Equal(t, "b.txt", files[1].NewPath)
+}
+
+func TestParse_Errors(t *testing.T) {
+	_, err := Parse("@@ -1 +1 @@\n-a\n+b\n")
+	assert.Error(t, err, "hunk without file header")
+
+	_, err = Parse("--- a\n+++ b\n@@ -1,3 +1,3 @@\n a\n")
+	assert.Error(t, err, "truncated hunk")
+
+	_, err = Parse("--- a\n+++ b\n@@ bogus @@\n")
+	assert.Error(t, err, "malformed header")
+}
+
+func TestParse_Empty(t *testing.T) {
+	files, err := Parse("")
+	require.NoError(t, err)
+	assert.Empty(t, files)
+}
@@ -0,0 +1,126 @@
+package diff
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultFuzz is the number of context lines that may be ignored at each end
+// of a hunk when it does not match exactly
+const DefaultFuzz = 2
+
+// FilePatch is a parsed file diff validated against the file on disk
+type FilePatch struct {
+	File       File
+	Target     string // path on disk
+	Original   []string
+	Placements []Placement
+	// TrailingNewline records whether the original ended with a newline
+	TrailingNewline bool
+}
+
+// Plan validates every file diff against the files under root. Paths must be
+// relative and stay inside root. Every hunk must match, possibly with fuzz;
+// all problems are reported together.
+func Plan(files []File, root string, maxFuzz int) ([]*FilePatch, error) {
+	var patches []*FilePatch
+	var errs []error
+
+	for i := range files {
+		f := files[i]
+		if f.Binary {
+			errs = append(errs, fmt.Errorf("%s: binary patches are not supported", f.Path()))
+			continue
+		}
+
+		target, err := safeJoin(root, f.Path())
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		fp := &FilePatch{File: f, Target: target, TrailingNewline: true}
+
+		data, err := os.ReadFile(target)
+		switch {
+		case err == nil:
+			if f.IsNew() {
+				errs = append(errs, fmt.Errorf("%s: patch creates a file that already exists", f.Path()))
+				continue
+			}
+			content := string(data)
+			fp.TrailingNewline = content == "" || strings.HasSuffix(content, "\n")
+			fp.Original = splitContent(content)
+		case os.IsNotExist(err):
+			if !f.IsNew() {
+				errs = append(errs, fmt.Errorf("%s: file does not exist", f.Path()))
+				continue
+			}
+		default:
+			errs = append(errs, fmt.Errorf("%s: %w", f.Path(), err))
+			continue
+		}
+
+		placements, err := Resolve(&f, fp.Original, maxFuzz)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		fp.Placements = placements
+		patches = append(patches, fp)
+	}
+
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return patches, nil
+}
+
+// Change returns the resulting file change with only the accepted hunks
+// applied. It returns false if no hunk was accepted.
+func (p *FilePatch) Change(accept []bool) (Change, bool) {
+	accepted := false
+	for _, a := range accept {
+		accepted = accepted || a
+	}
+	if accept != nil && !accepted {
+		return Change{}, false
+	}
+
+	lines := Apply(p.Original, p.Placements, accept)
+	if p.File.IsDeleted() && len(lines) == 0 {
+		return Change{Path: p.Target, Delete: true}, true
+	}
+
+	content := strings.Join(lines, "\n")
+	if len(lines) > 0 && p.TrailingNewline {
+		content += "\n"
+	}
+	return Change{Path: p.Target, Content: content}, true
+}
+
+// safeJoin joins a patch path to root, rejecting paths that escape it
+func safeJoin(root, path string) (string, error) {
+	if path == "" {
+		return "", fmt.Errorf("patch has an empty file path")
+	}
+	if filepath.IsAbs(path) {
+		return "", fmt.Errorf("%s: absolute paths are not allowed", path)
+	}
+	clean := filepath.Clean(filepath.FromSlash(path))
+	if clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s: path escapes the working directory", path)
+	}
+	return filepath.Join(root, clean), nil
+}
+
+// splitContent splits file content into lines without the final newline
+func splitContent(content string) []string {
+	if content == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+}
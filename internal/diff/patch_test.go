@@ -0,0 +1,72 @@
+package diff
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlan(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(root, "a.txt"), []byte("one\ntwo\nthree\n"), 0644))
+
+	files, err := Parse(`--- a/a.txt
++++ b/a.txt
+@@ -1,3 +1,3 @@
+ one
+-two
++TWO
+ three
+--- /dev/null
++++ b/b.txt
+@@ -0,0 +1 @@
++new
+`)
+	require.NoError(t, err)
+
+	patches, err := Plan(files, root, DefaultFuzz)
+	require.NoError(t, err)
+	require.Len(t, patches, 2)
+
+	change, ok := patches[0].Change(nil)
+	require.True(t, ok)
+	assert.Equal(t, "one\nTWO\nthree\n", change.Content)
+
+	change, ok = patches[1].Change([]bool{true})
+	require.True(t, ok)
+	assert.Equal(t, filepath.Join(root, "b.txt"), change.Path)
+	assert.Equal(t, "new\n", change.Content)
+
+	_, ok = patches[0].Change([]bool{false})
+	assert.False(t, ok, "no accepted hunks means no change")
+}
+
+func TestPlan_Rejects(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(root, "exists.txt"), []byte("x\n"), 0644))
+
+	tests := []struct {
+		name string
+		diff string
+		want string
+	}{
+		{"escape", "--- a/../etc/passwd\n+++ b/../etc/passwd\n@@ -1 +1 @@\n-x\n+y\n", "escapes"},
+		{"absolute", "--- /etc/passwd\n+++ /etc/passwd\n@@ -1 +1 @@\n-x\n+y\n", "absolute"},
+		{"missing", "--- a/nope.txt\n+++ b/nope.txt\n@@ -1 +1 @@\n-x\n+y\n", "does not exist"},
+		{"create existing", "--- /dev/null\n+++ b/exists.txt\n@@ -0,0 +1 @@\n+y\n", "already exists"},
+		{"mismatch", "--- a/exists.txt\n+++ b/exists.txt\n@@ -1 +1 @@\n-nope\n+y\n", "does not match"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files, err := Parse(tt.diff)
+			require.NoError(t, err)
+			_, err = Plan(files, root, DefaultFuzz)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.want)
+		})
+	}
+}
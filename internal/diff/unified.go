@@ -0,0 +1,155 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// maxDiffCells bounds the line-matching table; larger inputs are shown as
+// one hunk replacing everything
+const maxDiffCells = 4 << 20
+
+// Unified returns a unified diff from old to new with the given number of
+// context lines, or "" if they are equal
+func Unified(oldPath, newPath, old, new string, context int) string {
+	if old == new {
+		return ""
+	}
+	a, b := splitLines(old), splitLines(new)
+	lines := diffLines(a, b)
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", oldPath, newPath)
+	for _, h := range hunks(lines, context) {
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n", hunkRange(h.OldStart, h.OldLines), hunkRange(h.NewStart, h.NewLines))
+		for _, l := range h.Lines {
+			out.WriteByte(byte(l.Kind))
+			out.WriteString(l.Text)
+			out.WriteByte('\n')
+		}
+	}
+	return out.String()
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// diffLines matches a against b by longest common subsequence
+func diffLines(a, b []string) []Line {
+	var lines []Line
+	if len(a)*len(b) > maxDiffCells {
+		for i, s := range a {
+			lines = append(lines, Line{Kind: LineRemoved, Text: s, OldLine: i + 1})
+		}
+		for j, s := range b {
+			lines = append(lines, Line{Kind: LineAdded, Text: s, NewLine: j + 1})
+		}
+		return lines
+	}
+
+	// lcs[i][j] is the LCS length of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			lines = append(lines, Line{Kind: LineContext, Text: a[i], OldLine: i + 1, NewLine: j + 1})
+			i++
+			j++
+		case j == len(b) || i < len(a) && lcs[i+1][j] >= lcs[i][j+1]:
+			lines = append(lines, Line{Kind: LineRemoved, Text: a[i], OldLine: i + 1})
+			i++
+		default:
+			lines = append(lines, Line{Kind: LineAdded, Text: b[j], NewLine: j + 1})
+			j++
+		}
+	}
+	return lines
+}
+
+// hunks groups changed lines with up to context unchanged lines around them
+func hunks(lines []Line, context int) []Hunk {
+	var out []Hunk
+	oldLine, newLine := 1, 1 // position before lines[k]
+	for k := 0; k < len(lines); {
+		if lines[k].Kind == LineContext {
+			oldLine++
+			newLine++
+			k++
+			continue
+		}
+
+		// Hunks end more than context lines before the next change, so
+		// leading context never overlaps the previous hunk
+		start := max(0, k-context)
+		h := Hunk{OldStart: oldLine - (k - start), NewStart: newLine - (k - start)}
+
+		// Extend past changes until more than 2*context unchanged lines follow
+		end := k
+		for end < len(lines) {
+			if lines[end].Kind != LineContext {
+				end++
+				continue
+			}
+			run := end
+			for run < len(lines) && lines[run].Kind == LineContext {
+				run++
+			}
+			if run == len(lines) || run-end > 2*context {
+				end = min(end+context, len(lines))
+				break
+			}
+			end = run
+		}
+
+		for _, l := range lines[start:end] {
+			h.Lines = append(h.Lines, l)
+			if l.Kind != LineAdded {
+				h.OldLines++
+			}
+			if l.Kind != LineRemoved {
+				h.NewLines++
+			}
+		}
+		out = append(out, h)
+
+		for _, l := range lines[k:end] {
+			if l.Kind != LineAdded {
+				oldLine++
+			}
+			if l.Kind != LineRemoved {
+				newLine++
+			}
+		}
+		k = end
+	}
+	return out
+}
+
+func hunkRange(start, n int) string {
+	if n == 0 {
+		// An empty side is addressed by the line before it
+		return fmt.Sprintf("%d,0", start-1)
+	}
+	if n == 1 {
+		return fmt.Sprintf("%d", start)
+	}
+	return fmt.Sprintf("%d,%d", start, n)
+}
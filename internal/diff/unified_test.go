@@ -0,0 +1,43 @@
+package diff
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnified(t *testing.T) {
+	old := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\n"
+	new := "a\nB\nc\nd\ne\nf\ng\nh\ni\nj\nk\n"
+
+	got := Unified("a/x", "b/x", old, new, 1)
+	assert.Equal(t, `--- a/x
++++ b/x
+@@ -1,3 +1,3 @@
+ a
+-b
++B
+ c
+@@ -10 +10,2 @@
+ j
++k
+`, got)
+
+	assert.Empty(t, Unified("a", "b", old, old, 3))
+
+	// The output parses back and applies
+	files, err := Parse(got)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	lines := strings.Split(strings.TrimSuffix(old, "\n"), "\n")
+	placements, err := Resolve(&files[0], lines, 0)
+	require.NoError(t, err)
+	applied := Apply(lines, placements, []bool{true, true})
+	assert.Equal(t, new, strings.Join(applied, "\n")+"\n")
+}
+
+func TestUnified_NewFile(t *testing.T) {
+	assert.Equal(t, "--- a\n+++ b\n@@ -0,0 +1,2 @@\n+x\n+y\n", Unified("a", "b", "", "x\ny\n", 3))
+}
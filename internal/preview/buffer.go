@@ -16,6 +16,8 @@ type Buffer struct {
 	Impact       *Impact
 	Input        io.Reader
 	Output       io.Writer
+	// Always prompts even when the command is not destructive
+	Always bool
 }
 
 // NewBuffer creates a new command preview buffer
@@ -32,13 +34,15 @@ func NewBuffer(command string) *Buffer {
 // Show displays the command preview and prompts for action
 func (b *Buffer) Show() (Action, string, error) {
 	if !b.DetectResult.IsDestructive {
-		// Not destructive, allow immediate execution
-		return ActionExecute, b.Command, nil
+		if !b.Always {
+			// Not destructive, allow immediate execution
+			return ActionExecute, b.Command, nil
+		}
+	} else {
+		// Display warning banner
+		b.displayWarning()
 	}
 
-	// Display warning banner
-	b.displayWarning()
-
 	// Display command breakdown
 	b.displayBreakdown()
 
@@ -90,7 +94,11 @@ func (b *Buffer) promptAction() (Action, string, error) {
 	fmt.Fprintf(b.Output, "What would you like to do?\n")
 	fmt.Fprintf(b.Output, "  [E]dit command\n")
 	fmt.Fprintf(b.Output, "  [D]ry-run (show what would happen)\n")
-	fmt.Fprintf(b.Output, "  [Enter] Execute anyway\n")
+	if b.DetectResult.IsDestructive {
+		fmt.Fprintf(b.Output, "  [Enter] Execute anyway\n")
+	} else {
+		fmt.Fprintf(b.Output, "  [Enter] Execute\n")
+	}
 	fmt.Fprintf(b.Output, "  [Q]uit / Cancel\n")
 	fmt.Fprintf(b.Output, "\nChoice: ")
 
@@ -0,0 +1,161 @@
+package preview
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/scmd/scmd/internal/diff"
+)
+
+const (
+	ansiRed   = "\033[31m"
+	ansiGreen = "\033[32m"
+	ansiCyan  = "\033[36m"
+	ansiBold  = "\033[1m"
+	ansiReset = "\033[0m"
+)
+
+// PatchBuffer shows a proposed patch hunk by hunk and lets the user accept or
+// reject each one
+type PatchBuffer struct {
+	Patches []*diff.FilePatch
+	Input   io.Reader
+	Output  io.Writer
+	Color   bool
+}
+
+// NewPatchBuffer creates a patch preview reading from stdin and writing to stdout
+func NewPatchBuffer(patches []*diff.FilePatch) *PatchBuffer {
+	return &PatchBuffer{
+		Patches: patches,
+		Input:   os.Stdin,
+		Output:  os.Stdout,
+		Color:   os.Getenv("NO_COLOR") == "",
+	}
+}
+
+// Review walks through every hunk and returns, per patch, which hunks were
+// accepted. It returns ActionQuit if the user cancelled.
+func (b *PatchBuffer) Review() (Action, [][]bool, error) {
+	reader := bufio.NewReader(b.Input)
+	decisions := make([][]bool, len(b.Patches))
+	acceptRest := false
+
+	total := 0
+	for _, p := range b.Patches {
+		total += len(p.File.Hunks)
+	}
+
+	n := 0
+	for i, p := range b.Patches {
+		decisions[i] = make([]bool, len(p.File.Hunks))
+		b.fileHeader(p)
+
+	hunks:
+		for h := range p.File.Hunks {
+			n++
+			b.showHunk(p, h, n, total)
+
+			if acceptRest {
+				decisions[i][h] = true
+				continue
+			}
+
+			choice, err := b.prompt(reader)
+			if err != nil {
+				return ActionQuit, nil, err
+			}
+
+			switch choice {
+			case "y":
+				decisions[i][h] = true
+			case "a":
+				decisions[i][h] = true
+				acceptRest = true
+			case "d":
+				// Reject the remaining hunks of this file
+				break hunks
+			case "q":
+				return ActionQuit, nil, nil
+			}
+		}
+	}
+
+	return ActionExecute, decisions, nil
+}
+
+// prompt asks what to do with a hunk until it gets a valid answer
+func (b *PatchBuffer) prompt(reader *bufio.Reader) (string, error) {
+	for {
+		fmt.Fprintf(b.Output, "Apply this hunk? [y]es / [n]o / [a]ll remaining / [d]one with file / [q]uit: ")
+		input, err := reader.ReadString('\n')
+		if err != nil && input == "" {
+			return "", err
+		}
+
+		switch strings.TrimSpace(strings.ToLower(input)) {
+		case "y", "yes":
+			return "y", nil
+		case "n", "no", "":
+			return "n", nil
+		case "a", "all":
+			return "a", nil
+		case "d", "done":
+			return "d", nil
+		case "q", "quit":
+			return "q", nil
+		}
+		fmt.Fprintf(b.Output, "Invalid choice. Please try again.\n")
+	}
+}
+
+// fileHeader prints the file a group of hunks belongs to
+func (b *PatchBuffer) fileHeader(p *diff.FilePatch) {
+	label := p.File.Path()
+	switch {
+	case p.File.IsNew():
+		label += " (new file)"
+	case p.File.IsDeleted():
+		label += " (deleted)"
+	case p.File.OldPath != p.File.NewPath:
+		label = fmt.Sprintf("%s → %s", p.File.OldPath, p.File.NewPath)
+	}
+
+	fmt.Fprintf(b.Output, "\n%s\n", b.paint(ansiBold, label))
+	fmt.Fprintf(b.Output, "%s\n", strings.Repeat("=", 60))
+}
+
+// showHunk prints one hunk with removed lines in red and added lines in green
+func (b *PatchBuffer) showHunk(p *diff.FilePatch, idx, n, total int) {
+	h := p.File.Hunks[idx]
+	pl := p.Placements[idx]
+
+	header := fmt.Sprintf("@@ -%d,%d +%d,%d @@ %s", h.OldStart, h.OldLines, h.NewStart, h.NewLines, h.Section)
+	fmt.Fprintf(b.Output, "\n[%d/%d] %s\n", n, total, b.paint(ansiCyan, strings.TrimSpace(header)))
+
+	if pl.Offset != 0 || pl.Fuzz != 0 {
+		fmt.Fprintf(b.Output, "  (matched at line %d, offset %+d, fuzz %d)\n", pl.Start+1, pl.Offset, pl.Fuzz)
+	}
+
+	for _, l := range h.Lines {
+		switch l.Kind {
+		case diff.LineAdded:
+			fmt.Fprintln(b.Output, b.paint(ansiGreen, "+"+l.Text))
+		case diff.LineRemoved:
+			fmt.Fprintln(b.Output, b.paint(ansiRed, "-"+l.Text))
+		default:
+			fmt.Fprintln(b.Output, " "+l.Text)
+		}
+	}
+	fmt.Fprintln(b.Output)
+}
+
+func (b *PatchBuffer) paint(code, s string) string {
+	if !b.Color {
+		return s
+	}
+	return code + s + ansiReset
+}
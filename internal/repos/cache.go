@@ -1,13 +1,12 @@
 package repos
 
 import (
-	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
-	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"sync"
 	"time"
 )
@@ -46,6 +45,20 @@ type CachedCmd struct {
 	InstalledAt time.Time `json:"installed_at,omitempty"`
 	UpdateAvail bool      `json:"update_available,omitempty"`
 	LatestVer   string    `json:"latest_version,omitempty"`
+
+	// Where an installed command came from, for lockfiles
+	URL         string `json:"url,omitempty"`
+	File        string `json:"file,omitempty"`
+	ContentHash string `json:"content_hash,omitempty"`
+
+	// Resolved dependencies, as repo/name
+	Dependencies []string `json:"dependencies,omitempty"`
+
+	// Side-by-side versions: where each came from in the repository, the
+	// version active before the last change, and the version pinned to
+	Sources  map[string]string `json:"sources,omitempty"`
+	Previous string            `json:"previous,omitempty"`
+	Pinned   string            `json:"pinned,omitempty"`
 }
 
 // CachedItem is a generic cached item
@@ -374,8 +387,11 @@ type LockedCmd struct {
 	Name    string `json:"name"`
 	Repo    string `json:"repo"`
 	Version string `json:"version"`
-	Hash    string `json:"hash"`
-	URL     string `json:"url"`
+	Hash    string `json:"hash"` // sha256:<hex> of the command file
+	URL     string `json:"url"`  // repository URL
+	File    string `json:"file,omitempty"`
+
+	Dependencies []string `json:"dependencies,omitempty"` // resolved, as repo/name
 }
 
 // GenerateLockfile creates a lockfile from installed commands
@@ -403,14 +419,29 @@ func (c *Cache) GenerateLockfile() *Lockfile {
 			}
 		}
 
+		hash := cmd.ContentHash
+		if hash == "" {
+			hash = cmd.Hash
+		}
 		lf.Commands = append(lf.Commands, LockedCmd{
 			Name:    name,
 			Repo:    repo,
 			Version: cmd.Version,
-			Hash:    cmd.Hash,
+			Hash:    hash,
+			URL:     cmd.URL,
+			File:    cmd.File,
+
+			Dependencies: cmd.Dependencies,
 		})
 	}
 
+	sort.Slice(lf.Commands, func(i, j int) bool {
+		a, b := lf.Commands[i], lf.Commands[j]
+		if a.Repo != b.Repo {
+			return a.Repo < b.Repo
+		}
+		return a.Name < b.Name
+	})
 	return lf
 }
 
@@ -437,56 +468,3 @@ func LoadLockfile(path string) (*Lockfile, error) {
 
 	return &lf, nil
 }
-
-// InstallFromLockfile installs commands from a lockfile
-func (m *Manager) InstallFromLockfile(ctx context.Context, lf *Lockfile, installDir string) error {
-	for _, cmd := range lf.Commands {
-		repo, ok := m.Get(cmd.Repo)
-		if !ok {
-			// Try to add the repo
-			if cmd.URL != "" {
-				if err := m.Add(cmd.Repo, cmd.URL); err != nil {
-					return fmt.Errorf("add repo %s: %w", cmd.Repo, err)
-				}
-				repo, _ = m.Get(cmd.Repo)
-			} else {
-				return fmt.Errorf("repo %s not found", cmd.Repo)
-			}
-		}
-
-		// Find the command file
-		manifest, err := m.FetchManifest(ctx, repo)
-		if err != nil {
-			return fmt.Errorf("fetch manifest for %s: %w", cmd.Repo, err)
-		}
-
-		var cmdFile string
-		for _, c := range manifest.Commands {
-			if c.Name == cmd.Name {
-				cmdFile = c.File
-				break
-			}
-		}
-
-		if cmdFile == "" {
-			return fmt.Errorf("command %s not found in %s", cmd.Name, cmd.Repo)
-		}
-
-		spec, err := m.FetchCommand(ctx, repo, cmdFile)
-		if err != nil {
-			return fmt.Errorf("fetch command %s: %w", cmd.Name, err)
-		}
-
-		// Verify version matches if specified
-		if cmd.Version != "" && spec.Version != cmd.Version {
-			return fmt.Errorf("version mismatch for %s: wanted %s, got %s",
-				cmd.Name, cmd.Version, spec.Version)
-		}
-
-		if err := m.InstallCommand(spec, installDir); err != nil {
-			return fmt.Errorf("install %s: %w", cmd.Name, err)
-		}
-	}
-
-	return nil
-}
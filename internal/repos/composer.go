@@ -3,8 +3,6 @@ package repos
 import (
 	"context"
 	"fmt"
-	"strings"
-	"sync"
 
 	"github.com/scmd/scmd/internal/command"
 )
@@ -23,7 +21,8 @@ func NewComposer(registry *command.Registry, loader *Loader) *Composer {
 	}
 }
 
-// ExecuteComposed runs a composed command (pipeline, parallel, or fallback)
+// ExecuteComposed runs a composed command (workflow, pipeline, parallel, or
+// fallback)
 func (c *Composer) ExecuteComposed(
 	ctx context.Context,
 	spec *CommandSpec,
@@ -34,6 +33,11 @@ func (c *Composer) ExecuteComposed(
 		return nil, fmt.Errorf("command has no composition defined")
 	}
 
+	// Execute workflow
+	if len(spec.Compose.Steps) > 0 {
+		return c.executeWorkflow(ctx, spec.Compose, args, execCtx)
+	}
+
 	// Execute pipeline
 	if len(spec.Compose.Pipeline) > 0 {
 		return c.executePipeline(ctx, spec.Compose.Pipeline, args, execCtx)
@@ -41,7 +45,7 @@ func (c *Composer) ExecuteComposed(
 
 	// Execute parallel
 	if len(spec.Compose.Parallel) > 0 {
-		return c.executeParallel(ctx, spec.Compose.Parallel, args, execCtx)
+		return c.executeParallel(ctx, spec, args, execCtx)
 	}
 
 	// Execute fallback
@@ -60,6 +64,8 @@ func (c *Composer) executePipeline(
 	execCtx *command.ExecContext,
 ) (*command.Result, error) {
 	var lastOutput string
+	// lastData is the structured output of the previous step, if it had one
+	var lastData interface{}
 
 	// Get initial input from args
 	if stdin, ok := args.Options["stdin"]; ok {
@@ -68,14 +74,7 @@ func (c *Composer) executePipeline(
 
 	for i, step := range steps {
 		// Resolve command
-		cmd, ok := c.registry.Get(step.Command)
-		if !ok {
-			// Try loading from installed plugins
-			if err := c.loader.RegisterAll(c.registry); err == nil {
-				cmd, ok = c.registry.Get(step.Command)
-			}
-		}
-
+		cmd, ok := c.lookup(step.Command)
 		if !ok {
 			if step.OnError == "continue" {
 				continue
@@ -86,6 +85,7 @@ func (c *Composer) executePipeline(
 		// Build args for this step
 		stepArgs := command.NewArgs()
 		stepArgs.Options["stdin"] = lastOutput
+		stepArgs.Data = lastData
 
 		// Apply step-specific args
 		for k, v := range step.Args {
@@ -109,73 +109,39 @@ func (c *Composer) executePipeline(
 		}
 
 		// Apply transform if specified
-		output := result.Output
+		output, data := result.Output, result.Data
 		if step.Transform != "" {
-			output = applyTransform(output, step.Transform)
+			output, data, err = applyTransform(step.Transform, output, data)
+			if err != nil {
+				if step.OnError == "continue" {
+					continue
+				}
+				return nil, fmt.Errorf("pipeline step %d (%s): %w", i, step.Command, err)
+			}
 		}
 
-		lastOutput = output
+		lastOutput, lastData = output, data
 	}
 
 	return &command.Result{
 		Success: true,
 		Output:  lastOutput,
+		Data:    lastData,
 	}, nil
 }
 
-// executeParallel runs commands in parallel and merges results
-func (c *Composer) executeParallel(
-	ctx context.Context,
-	commands []string,
-	args *command.Args,
-	execCtx *command.ExecContext,
-) (*command.Result, error) {
-	var wg sync.WaitGroup
-	results := make([]*command.Result, len(commands))
-	errors := make([]error, len(commands))
-
-	for i, cmdName := range commands {
-		wg.Add(1)
-		go func(idx int, name string) {
-			defer wg.Done()
-
-			cmd, ok := c.registry.Get(name)
-			if !ok {
-				errors[idx] = fmt.Errorf("command '%s' not found", name)
-				return
-			}
-
-			result, err := cmd.Execute(ctx, args, execCtx)
-			if err != nil {
-				errors[idx] = err
-				return
-			}
-			results[idx] = result
-		}(i, cmdName)
+// lookup finds a command, loading installed plugins if it isn't registered
+func (c *Composer) lookup(name string) (command.Command, bool) {
+	if cmd, ok := c.registry.Get(name); ok {
+		return cmd, true
 	}
-
-	wg.Wait()
-
-	// Merge results
-	var outputs []string
-	var allSuccess = true
-
-	for i, result := range results {
-		if errors[i] != nil {
-			allSuccess = false
-			outputs = append(outputs, fmt.Sprintf("[%s] Error: %v", commands[i], errors[i]))
-		} else if result != nil {
-			if !result.Success {
-				allSuccess = false
-			}
-			outputs = append(outputs, fmt.Sprintf("## %s\n%s", commands[i], result.Output))
-		}
+	if c.loader == nil {
+		return nil, false
 	}
-
-	return &command.Result{
-		Success: allSuccess,
-		Output:  strings.Join(outputs, "\n\n"),
-	}, nil
+	if err := c.loader.RegisterAll(c.registry); err != nil {
+		return nil, false
+	}
+	return c.registry.Get(name)
 }
 
 // executeFallback tries commands in order until one succeeds
@@ -210,257 +176,43 @@ func (c *Composer) executeFallback(
 	return nil, fmt.Errorf("all fallback commands failed: %w", lastErr)
 }
 
-// applyTransform applies a simple transform to output
-// Supports: trim, upper, lower, lines, first, last, json.field
-func applyTransform(input, transform string) string {
-	switch transform {
-	case "trim":
-		return strings.TrimSpace(input)
-	case "upper":
-		return strings.ToUpper(input)
-	case "lower":
-		return strings.ToLower(input)
-	case "lines":
-		// Return line count
-		return fmt.Sprintf("%d", strings.Count(input, "\n")+1)
-	case "first":
-		// Return first line
-		if idx := strings.Index(input, "\n"); idx >= 0 {
-			return input[:idx]
-		}
-		return input
-	case "last":
-		// Return last line
-		lines := strings.Split(input, "\n")
-		for i := len(lines) - 1; i >= 0; i-- {
-			if strings.TrimSpace(lines[i]) != "" {
-				return lines[i]
-			}
-		}
-		return input
-	default:
-		// Check for json.field pattern
-		if strings.HasPrefix(transform, "json.") {
-			// Simple JSON field extraction (basic implementation)
-			field := transform[5:]
-			// Look for "field": "value" pattern
-			pattern := fmt.Sprintf(`"%s":\s*"([^"]*)"`, field)
-			// This is a simplified version - production would use proper JSON parsing
-			return extractPattern(input, pattern)
-		}
-		return input
-	}
-}
-
-func extractPattern(input, pattern string) string {
-	// Simple pattern matching for JSON-like extraction
-	// In production, use proper regex or JSON parsing
-	return input // Fallback
-}
-
-// ResolveDependencies resolves and installs command dependencies
+// ResolveDependencies resolves the dependencies of spec, a command from the
+// repository named repo, installs those not already registered and
+// registers them
 func (c *Composer) ResolveDependencies(
 	ctx context.Context,
+	repo string,
 	spec *CommandSpec,
 	manager *Manager,
 	installDir string,
-) error {
-	if len(spec.Dependencies) == 0 {
-		return nil
-	}
-
-	for _, dep := range spec.Dependencies {
-		// Check if already installed
-		if cmd, ok := c.registry.Get(dep.Command); ok {
-			_ = cmd // Already available
-			continue
-		}
-
-		// Parse repo/command format
-		parts := strings.SplitN(dep.Command, "/", 2)
-		if len(parts) != 2 {
-			if dep.Optional {
-				continue
-			}
-			return fmt.Errorf("invalid dependency format: %s (expected repo/command)", dep.Command)
-		}
-
-		repoName, cmdName := parts[0], parts[1]
-
-		// Get repo
-		repo, ok := manager.Get(repoName)
+) (*Resolution, error) {
+	resolver := NewResolver(manager)
+	resolver.Installed = func(_, name string) (*CommandSpec, bool) {
+		cmd, ok := c.registry.Get(name)
 		if !ok {
-			if dep.Optional {
-				continue
-			}
-			return fmt.Errorf("dependency repo '%s' not found", repoName)
-		}
-
-		// Fetch manifest
-		manifest, err := manager.FetchManifest(ctx, repo)
-		if err != nil {
-			if dep.Optional {
-				continue
-			}
-			return fmt.Errorf("fetch manifest for dependency: %w", err)
-		}
-
-		// Find command
-		var cmdFile string
-		for _, cmd := range manifest.Commands {
-			if cmd.Name == cmdName {
-				cmdFile = cmd.File
-				break
-			}
-		}
-
-		if cmdFile == "" {
-			if dep.Optional {
-				continue
-			}
-			return fmt.Errorf("dependency command '%s' not found in repo '%s'", cmdName, repoName)
-		}
-
-		// Fetch and install
-		depSpec, err := manager.FetchCommand(ctx, repo, cmdFile)
-		if err != nil {
-			if dep.Optional {
-				continue
-			}
-			return fmt.Errorf("fetch dependency command: %w", err)
-		}
-
-		// Check version constraint
-		if dep.Version != "" && !checkVersionConstraint(depSpec.Version, dep.Version) {
-			if dep.Optional {
-				continue
-			}
-			return fmt.Errorf("dependency %s version %s does not satisfy %s",
-				dep.Command, depSpec.Version, dep.Version)
+			return nil, false
 		}
-
-		// Install
-		if err := manager.InstallCommand(depSpec, installDir); err != nil {
-			if dep.Optional {
-				continue
-			}
-			return fmt.Errorf("install dependency: %w", err)
+		plugin, ok := cmd.(*PluginCommand)
+		if !ok {
+			return nil, false
 		}
-
-		// Register
-		pluginCmd := NewPluginCommand(depSpec)
-		_ = c.registry.Register(pluginCmd)
-	}
-
-	return nil
-}
-
-// checkVersionConstraint checks if version satisfies constraint
-// Supports: =, >=, <=, >, <, ~> (pessimistic)
-func checkVersionConstraint(version, constraint string) bool {
-	if constraint == "" || constraint == "*" {
-		return true
-	}
-
-	// Parse constraint
-	var op string
-	var target string
-
-	if strings.HasPrefix(constraint, ">=") {
-		op = ">="
-		target = strings.TrimPrefix(constraint, ">=")
-	} else if strings.HasPrefix(constraint, "<=") {
-		op = "<="
-		target = strings.TrimPrefix(constraint, "<=")
-	} else if strings.HasPrefix(constraint, ">") {
-		op = ">"
-		target = strings.TrimPrefix(constraint, ">")
-	} else if strings.HasPrefix(constraint, "<") {
-		op = "<"
-		target = strings.TrimPrefix(constraint, "<")
-	} else if strings.HasPrefix(constraint, "~>") {
-		op = "~>"
-		target = strings.TrimPrefix(constraint, "~>")
-	} else if strings.HasPrefix(constraint, "=") {
-		op = "="
-		target = strings.TrimPrefix(constraint, "=")
-	} else {
-		// Default to exact match
-		op = "="
-		target = constraint
+		return plugin.spec, true
 	}
 
-	target = strings.TrimSpace(target)
-
-	// Compare versions (simplified semver comparison)
-	cmp := compareVersions(version, target)
-
-	switch op {
-	case "=":
-		return cmp == 0
-	case ">=":
-		return cmp >= 0
-	case "<=":
-		return cmp <= 0
-	case ">":
-		return cmp > 0
-	case "<":
-		return cmp < 0
-	case "~>":
-		// Pessimistic: >= target but < next major/minor
-		return cmp >= 0 && isSameMajorMinor(version, target)
-	default:
-		return false
+	res, err := resolver.Resolve(ctx, repo, spec)
+	if err != nil {
+		return nil, err
 	}
-}
-
-// compareVersions compares two semver versions
-// Returns: -1 if a < b, 0 if a == b, 1 if a > b
-func compareVersions(a, b string) int {
-	aParts := parseVersion(a)
-	bParts := parseVersion(b)
-
-	for i := 0; i < 3; i++ {
-		if aParts[i] < bParts[i] {
-			return -1
-		}
-		if aParts[i] > bParts[i] {
-			return 1
-		}
+	if err := manager.InstallResolution(res, installDir, nil); err != nil {
+		return nil, err
 	}
-	return 0
-}
 
-func parseVersion(v string) [3]int {
-	var parts [3]int
-	v = strings.TrimPrefix(v, "v")
-
-	segments := strings.Split(v, ".")
-	for i := 0; i < 3 && i < len(segments); i++ {
-		// Parse only numeric part
-		numStr := ""
-		for _, c := range segments[i] {
-			if c >= '0' && c <= '9' {
-				numStr += string(c)
-			} else {
-				break
-			}
-		}
-		if numStr != "" {
-			var n int
-			for _, c := range numStr {
-				n = n*10 + int(c-'0')
-			}
-			parts[i] = n
+	for _, rc := range res.Commands {
+		if !rc.Installed {
+			_ = c.registry.Register(NewPluginCommand(rc.Spec))
 		}
 	}
-	return parts
-}
-
-func isSameMajorMinor(a, b string) bool {
-	aParts := parseVersion(a)
-	bParts := parseVersion(b)
-	return aParts[0] == bParts[0] && aParts[1] == bParts[1]
+	return res, nil
 }
 
 // ChainBuilder helps build command chains programmatically
@@ -0,0 +1,262 @@
+package repos
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// Hook conditions are small boolean expressions over strings:
+//
+//	env.CI                             set and not empty, "0" or "false"
+//	env.DEPLOY_ENV == 'production'
+//	exists('go.mod') && git.dirty
+//	hooks.lint.exit_code != 0 || inputs.mode == "strict"
+//	!contains(git.branch, 'release/')
+//
+// Names:
+//
+//	env.NAME                 environment variable
+//	git.repo, git.dirty, git.clean, git.staged, git.branch
+//	hooks.ID.exit_code, hooks.ID.output, hooks.ID.ok, hooks.ID.ran
+//	inputs.NAME              input, argument or flag value
+//
+// Functions are exists(path) and contains(s, substr). Operators are ==, !=,
+// &&, || and !, with parentheses for grouping. Nothing is ever executed
+// beyond reading git status.
+
+// condResolver looks up a dotted name
+type condResolver func(name string) (string, error)
+
+// EvalCondition evaluates a hook condition
+func EvalCondition(expr string, resolve condResolver) (bool, error) {
+	tokens, err := tokenizeCondition(expr)
+	if err != nil {
+		return false, err
+	}
+	p := &condParser{tokens: tokens, resolve: resolve}
+	v, err := p.or()
+	if err != nil {
+		return false, err
+	}
+	if p.pos < len(p.tokens) {
+		return false, fmt.Errorf("unexpected %q", p.tokens[p.pos].text)
+	}
+	return truthy(v), nil
+}
+
+// truthy treats empty, "0" and "false" as false
+func truthy(s string) bool {
+	return s != "" && s != "0" && s != "false"
+}
+
+func boolString(b bool) string {
+	if b {
+		return "true"
+	}
+	return "false"
+}
+
+type condTokenKind int
+
+const (
+	tokIdent condTokenKind = iota
+	tokString
+	tokOp
+)
+
+type condToken struct {
+	kind condTokenKind
+	text string
+}
+
+func tokenizeCondition(expr string) ([]condToken, error) {
+	var tokens []condToken
+	for i := 0; i < len(expr); {
+		ch := expr[i]
+		switch {
+		case ch == ' ' || ch == '\t' || ch == '\n':
+			i++
+		case ch == '\'' || ch == '"':
+			end := strings.IndexByte(expr[i+1:], ch)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated string")
+			}
+			tokens = append(tokens, condToken{tokString, expr[i+1 : i+1+end]})
+			i += end + 2
+		case strings.HasPrefix(expr[i:], "==") || strings.HasPrefix(expr[i:], "!=") ||
+			strings.HasPrefix(expr[i:], "&&") || strings.HasPrefix(expr[i:], "||"):
+			tokens = append(tokens, condToken{tokOp, expr[i : i+2]})
+			i += 2
+		case strings.ContainsRune("!(),", rune(ch)):
+			tokens = append(tokens, condToken{tokOp, string(ch)})
+			i++
+		case isIdentChar(rune(ch)):
+			start := i
+			for i < len(expr) && (isIdentChar(rune(expr[i])) || expr[i] == '.' || expr[i] == '-') {
+				i++
+			}
+			tokens = append(tokens, condToken{tokIdent, expr[start:i]})
+		default:
+			return nil, fmt.Errorf("unexpected character %q", ch)
+		}
+	}
+	return tokens, nil
+}
+
+func isIdentChar(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+type condParser struct {
+	tokens  []condToken
+	pos     int
+	resolve condResolver
+}
+
+func (p *condParser) peek(op string) bool {
+	return p.pos < len(p.tokens) && p.tokens[p.pos].kind == tokOp && p.tokens[p.pos].text == op
+}
+
+func (p *condParser) expect(op string) error {
+	if !p.peek(op) {
+		if p.pos < len(p.tokens) {
+			return fmt.Errorf("expected %q, got %q", op, p.tokens[p.pos].text)
+		}
+		return fmt.Errorf("expected %q at end of condition", op)
+	}
+	p.pos++
+	return nil
+}
+
+// Both sides are always evaluated so that errors such as unknown names are
+// reported regardless of the values
+func (p *condParser) or() (string, error) {
+	left, err := p.and()
+	if err != nil {
+		return "", err
+	}
+	for p.peek("||") {
+		p.pos++
+		right, err := p.and()
+		if err != nil {
+			return "", err
+		}
+		left = boolString(truthy(left) || truthy(right))
+	}
+	return left, nil
+}
+
+func (p *condParser) and() (string, error) {
+	left, err := p.unary()
+	if err != nil {
+		return "", err
+	}
+	for p.peek("&&") {
+		p.pos++
+		right, err := p.unary()
+		if err != nil {
+			return "", err
+		}
+		left = boolString(truthy(left) && truthy(right))
+	}
+	return left, nil
+}
+
+func (p *condParser) unary() (string, error) {
+	if p.peek("!") {
+		p.pos++
+		v, err := p.unary()
+		if err != nil {
+			return "", err
+		}
+		return boolString(!truthy(v)), nil
+	}
+	return p.comparison()
+}
+
+func (p *condParser) comparison() (string, error) {
+	left, err := p.primary()
+	if err != nil {
+		return "", err
+	}
+	for _, op := range []string{"==", "!="} {
+		if p.peek(op) {
+			p.pos++
+			right, err := p.primary()
+			if err != nil {
+				return "", err
+			}
+			return boolString((left == right) == (op == "==")), nil
+		}
+	}
+	return left, nil
+}
+
+func (p *condParser) primary() (string, error) {
+	if p.pos >= len(p.tokens) {
+		return "", fmt.Errorf("unexpected end of condition")
+	}
+	tok := p.tokens[p.pos]
+	p.pos++
+
+	switch tok.kind {
+	case tokString:
+		return tok.text, nil
+	case tokOp:
+		if tok.text != "(" {
+			return "", fmt.Errorf("unexpected %q", tok.text)
+		}
+		v, err := p.or()
+		if err != nil {
+			return "", err
+		}
+		return v, p.expect(")")
+	}
+
+	if p.peek("(") {
+		return p.call(tok.text)
+	}
+	switch tok.text {
+	case "true", "false":
+		return tok.text, nil
+	}
+	if tok.text[0] >= '0' && tok.text[0] <= '9' {
+		return tok.text, nil
+	}
+	return p.resolve(tok.text)
+}
+
+func (p *condParser) call(name string) (string, error) {
+	p.pos++ // (
+	var args []string
+	for !p.peek(")") {
+		if len(args) > 0 {
+			if err := p.expect(","); err != nil {
+				return "", err
+			}
+		}
+		v, err := p.or()
+		if err != nil {
+			return "", err
+		}
+		args = append(args, v)
+	}
+	p.pos++ // )
+
+	switch name {
+	case "exists":
+		if len(args) != 1 {
+			return "", fmt.Errorf("exists takes 1 argument")
+		}
+		_, err := os.Stat(args[0])
+		return boolString(err == nil), nil
+	case "contains":
+		if len(args) != 2 {
+			return "", fmt.Errorf("contains takes 2 arguments")
+		}
+		return boolString(strings.Contains(args[0], args[1])), nil
+	}
+	return "", fmt.Errorf("unknown function %s", name)
+}
@@ -4,19 +4,30 @@ import (
 	"bytes"
 	"context"
 	"fmt"
-	"os/exec"
+	"io"
 	"strings"
 	"text/template"
 
 	"github.com/scmd/scmd/internal/backend"
 	"github.com/scmd/scmd/internal/command"
 	contextpkg "github.com/scmd/scmd/internal/context"
+	"github.com/scmd/scmd/internal/sandbox"
 	"github.com/scmd/scmd/internal/tools"
 )
 
 // PluginCommand wraps a CommandSpec to implement the command.Command interface
 type PluginCommand struct {
 	spec *CommandSpec
+
+	// promptInput and promptOutput override where missing inputs are
+	// asked for; by default the terminal
+	promptInput  io.Reader
+	promptOutput io.Writer
+
+	// scope is ScopeProject for commands from a project's .scmd directory
+	scope string
+	// trustHooks, when set, must allow the command's hooks before they run
+	trustHooks func(prompt *inputPrompter) error
 }
 
 // NewPluginCommand creates a new plugin command from a spec
@@ -44,6 +55,28 @@ func (c *PluginCommand) Usage() string {
 	return c.spec.Usage
 }
 
+// FlagNames returns the command's declared flags and inputs, which can be
+// set as --name or --name=value
+func (c *PluginCommand) FlagNames() []string {
+	var names []string
+	for _, f := range c.spec.Flags {
+		names = append(names, f.Name)
+	}
+	for _, in := range c.spec.Inputs {
+		names = append(names, in.Name)
+	}
+	return names
+}
+
+// Scope returns where the command comes from: ScopeProject for a
+// project's .scmd directory, otherwise ScopeGlobal
+func (c *PluginCommand) Scope() string {
+	if c.scope == "" {
+		return ScopeGlobal
+	}
+	return c.scope
+}
+
 // Category returns the command category
 func (c *PluginCommand) Category() command.Category {
 	if c.spec.Category != "" {
@@ -62,8 +95,18 @@ func (c *PluginCommand) RequiresBackend() bool {
 	return true
 }
 
-// Validate validates the command arguments
+// Validate validates the command arguments and inputs without prompting
+// for missing ones
 func (c *PluginCommand) Validate(args *command.Args) error {
+	if err := c.validateArgs(args); err != nil {
+		return err
+	}
+	_, err := c.bindInputs(args, nil)
+	return err
+}
+
+// validateArgs checks the positional Args
+func (c *PluginCommand) validateArgs(args *command.Args) error {
 	// Check required args
 	for i, argSpec := range c.spec.Args {
 		if argSpec.Required && i >= len(args.Positional) {
@@ -78,7 +121,17 @@ func (c *PluginCommand) Validate(args *command.Args) error {
 
 // Execute runs the plugin command
 func (c *PluginCommand) Execute(ctx context.Context, args *command.Args, execCtx *command.ExecContext) (*command.Result, error) {
-	if err := c.Validate(args); err != nil {
+	if err := c.validateArgs(args); err != nil {
+		return &command.Result{
+			Success: false,
+			Error:   err.Error(),
+		}, nil
+	}
+
+	prompter, closePrompt := c.inputPrompt(args)
+	defer closePrompt()
+	inputs, err := c.bindInputs(args, prompter)
+	if err != nil {
 		return &command.Result{
 			Success: false,
 			Error:   err.Error(),
@@ -92,15 +145,31 @@ func (c *PluginCommand) Execute(ctx context.Context, args *command.Args, execCtx
 		}, nil
 	}
 
-	// Execute pre-hooks
+	// Build template context
+	tmplCtx := c.buildTemplateContext(args)
+	c.addInputs(tmplCtx, inputs)
+
+	// Hooks run shell commands, so project commands need the project trusted
+	if c.trustHooks != nil && c.spec.Hooks != nil && len(c.spec.Hooks.Pre)+len(c.spec.Hooks.Post) > 0 {
+		if err := c.trustHooks(prompter); err != nil {
+			return &command.Result{
+				Success: false,
+				Error:   err.Error(),
+			}, nil
+		}
+	}
+
+	// Execute pre-hooks; their results are available to the templates
+	hooks := c.newHookRunner(ctx, execCtx, tmplCtx)
 	if c.spec.Hooks != nil && len(c.spec.Hooks.Pre) > 0 {
-		if err := c.executeHooks(ctx, c.spec.Hooks.Pre); err != nil {
+		if err := hooks.run(HookStagePre, c.spec.Hooks.Pre, args.Options["stdin"]); err != nil {
 			return &command.Result{
 				Success: false,
 				Error:   fmt.Sprintf("pre-hook failed: %v", err),
 			}, nil
 		}
 	}
+	c.setTemplateValue(tmplCtx, "hooks", hooks.templateValues())
 
 	// Check for composition - delegate to composer if present
 	if c.spec.Compose != nil && execCtx.Registry != nil {
@@ -119,7 +188,7 @@ func (c *PluginCommand) Execute(ctx context.Context, args *command.Args, execCtx
 
 		// Execute post-hooks after composition
 		if c.spec.Hooks != nil && len(c.spec.Hooks.Post) > 0 {
-			if err := c.executeHooks(ctx, c.spec.Hooks.Post); err != nil {
+			if err := hooks.run(HookStagePost, c.spec.Hooks.Post, result.Output); err != nil {
 				return &command.Result{
 					Success: false,
 					Error:   fmt.Sprintf("post-hook failed: %v", err),
@@ -130,9 +199,6 @@ func (c *PluginCommand) Execute(ctx context.Context, args *command.Args, execCtx
 		return result, nil
 	}
 
-	// Build template context
-	tmplCtx := c.buildTemplateContext(args)
-
 	// Execute prompt template
 	prompt, err := c.executeTemplate(c.spec.Prompt.Template, tmplCtx)
 	if err != nil {
@@ -181,8 +247,77 @@ func (c *PluginCommand) Execute(ctx context.Context, args *command.Args, execCtx
 		}
 	}
 
-	// Use tool calling if backend supports it
+	complete := c.completer(ctx, execCtx, system)
+
 	var output string
+	var data interface{}
+	if c.spec.Outputs.IsJSON() {
+		data, output, err = c.completeJSON(prompt, complete)
+	} else {
+		output, err = complete(prompt)
+	}
+	if err != nil {
+		return &command.Result{
+			Success: false,
+			Error:   err.Error(),
+		}, nil
+	}
+
+	// Execute post-hooks
+	if c.spec.Hooks != nil && len(c.spec.Hooks.Post) > 0 {
+		if err := hooks.run(HookStagePost, c.spec.Hooks.Post, output); err != nil {
+			return &command.Result{
+				Success: false,
+				Error:   fmt.Sprintf("post-hook failed: %v", err),
+			}, nil
+		}
+	}
+
+	return &command.Result{
+		Success: true,
+		Output:  output,
+		Data:    data,
+	}, nil
+}
+
+// RenderPrompt renders the system and prompt templates for args, as
+// Execute would send them, for previews. Hooks don't run, so their values
+// are those of skipped hooks, and automatic context is left out.
+func (c *PluginCommand) RenderPrompt(args *command.Args) (system, prompt string, err error) {
+	if err := c.validateArgs(args); err != nil {
+		return "", "", err
+	}
+	inputs, err := c.bindInputs(args, nil)
+	if err != nil {
+		return "", "", err
+	}
+
+	tmplCtx := c.buildTemplateContext(args)
+	c.addInputs(tmplCtx, inputs)
+	c.setTemplateValue(tmplCtx, "hooks", c.newHookRunner(context.Background(), nil, tmplCtx).templateValues())
+
+	if prompt, err = c.executeTemplate(c.spec.Prompt.Template, tmplCtx); err != nil {
+		return "", "", fmt.Errorf("template error: %w", err)
+	}
+	if c.spec.Prompt.System != "" {
+		if system, err = c.executeTemplate(c.spec.Prompt.System, tmplCtx); err != nil {
+			return "", "", fmt.Errorf("system template error: %w", err)
+		}
+	}
+	if c.spec.Outputs.IsJSON() {
+		prompt += "\n\n" + c.spec.Outputs.jsonInstructions()
+	}
+	return system, prompt, nil
+}
+
+// completer returns a function that sends a prompt to the backend, with
+// tool calling when the backend supports it
+func (c *PluginCommand) completer(
+	ctx context.Context,
+	execCtx *command.ExecContext,
+	system string,
+) func(prompt string) (string, error) {
+	// Use tool calling if backend supports it
 	if execCtx.Backend.SupportsToolCalling() {
 		// Create tool registry with confirmation UI
 		var confirmUI tools.ConfirmUI
@@ -190,23 +325,30 @@ func (c *PluginCommand) Execute(ctx context.Context, args *command.Args, execCtx
 			confirmUI = execCtx.UI
 		}
 
-		toolRegistry := tools.DefaultRegistry(confirmUI)
-		toolExecutor := tools.NewExecutor(toolRegistry, execCtx.Backend)
-
-		output, err = toolExecutor.ExecuteWithTools(ctx, prompt, system)
-		if err != nil {
-			return &command.Result{
-				Success: false,
-				Error:   fmt.Sprintf("tool execution failed: %v", err),
-			}, nil
+		toolRegistry := c.toolRegistry(confirmUI, c.execPolicy(execCtx))
+		// Commands whose permissions allow no tools complete without them
+		if len(toolRegistry.List()) > 0 {
+			toolExecutor := tools.NewExecutor(toolRegistry, execCtx.Backend)
+			toolExecutor.SetJSONMode(c.spec.Outputs.IsJSON())
+
+			return func(prompt string) (string, error) {
+				output, err := toolExecutor.ExecuteWithTools(ctx, prompt, system)
+				if err != nil {
+					return "", fmt.Errorf("tool execution failed: %w", err)
+				}
+				return output, nil
+			}
 		}
-	} else {
+	}
+
+	return func(prompt string) (string, error) {
 		// Fall back to basic completion if no tool calling
 		req := &backend.CompletionRequest{
 			Prompt:       prompt,
 			SystemPrompt: system,
 			MaxTokens:    2048,
 			Temperature:  0.7,
+			JSONMode:     c.spec.Outputs.IsJSON(),
 		}
 
 		// Apply model preferences
@@ -219,28 +361,53 @@ func (c *PluginCommand) Execute(ctx context.Context, args *command.Args, execCtx
 
 		resp, err := execCtx.Backend.Complete(ctx, req)
 		if err != nil {
-			return &command.Result{
-				Success: false,
-				Error:   fmt.Sprintf("completion failed: %v", err),
-			}, nil
+			return "", fmt.Errorf("completion failed: %w", err)
 		}
-		output = resp.Content
+		return resp.Content, nil
 	}
+}
 
-	// Execute post-hooks
-	if c.spec.Hooks != nil && len(c.spec.Hooks.Post) > 0 {
-		if err := c.executeHooks(ctx, c.spec.Hooks.Post); err != nil {
-			return &command.Result{
-				Success: false,
-				Error:   fmt.Sprintf("post-hook failed: %v", err),
-			}, nil
+// toolRegistry returns the tools the command may use: every built-in tool,
+// or only those its permissions allow
+func (c *PluginCommand) toolRegistry(confirmUI tools.ConfirmUI, policy sandbox.Policy) *tools.Registry {
+	if c.spec.Permissions == nil {
+		return tools.DefaultRegistry(confirmUI, policy)
+	}
+	return tools.ScopedRegistry(confirmUI, c.spec.Permissions.toolScope(), policy)
+}
+
+// completeJSON asks for JSON matching the output schema, retrying once with
+// the problems if the first response does not match. It returns the parsed
+// value and the rendered output.
+func (c *PluginCommand) completeJSON(prompt string, complete func(string) (string, error)) (interface{}, string, error) {
+	out := c.spec.Outputs
+	prompt += "\n\n" + out.jsonInstructions()
+
+	response, err := complete(prompt)
+	if err != nil {
+		return nil, "", err
+	}
+
+	value, problems := out.parse(response)
+	if len(problems) > 0 {
+		retry := fmt.Sprintf("%s\n\nYour previous response was not valid:\n- %s\n\nPrevious response:\n%s\n\n"+
+			"Respond again with only the corrected JSON object.",
+			prompt, strings.Join(problems, "\n- "), response)
+		response, err = complete(retry)
+		if err != nil {
+			return nil, "", err
+		}
+		value, problems = out.parse(response)
+		if len(problems) > 0 {
+			return nil, "", fmt.Errorf("output does not match the schema: %s", strings.Join(problems, "; "))
 		}
 	}
 
-	return &command.Result{
-		Success: true,
-		Output:  output,
-	}, nil
+	rendered, err := out.render(value)
+	if err != nil {
+		return nil, "", fmt.Errorf("output template error: %w", err)
+	}
+	return value, rendered, nil
 }
 
 // buildTemplateContext creates the context for template execution
@@ -275,6 +442,11 @@ func (c *PluginCommand) buildTemplateContext(args *command.Args) map[string]inte
 		ctx["input"] = stdin // alias
 	}
 
+	// Add the previous pipeline step's structured output
+	if args.Data != nil {
+		ctx["data"] = args.Data
+	}
+
 	// Add all positional args as array
 	ctx["args"] = args.Positional
 
@@ -284,6 +456,56 @@ func (c *PluginCommand) buildTemplateContext(args *command.Args) map[string]inte
 	return ctx
 }
 
+// addInputs exposes bound inputs to templates as {{.name}} and
+// {{.inputs.name}}; unset optional inputs are empty. File inputs hold the
+// file's contents; their paths are under {{.files.name}}. An arg, flag or
+// input named inputs or files keeps its own value.
+func (c *PluginCommand) addInputs(ctx map[string]interface{}, inputs map[string]*BoundInput) {
+	values := make(map[string]string, len(c.spec.Inputs))
+	files := make(map[string]string)
+	for _, spec := range c.spec.Inputs {
+		value := ""
+		if in, ok := inputs[spec.Name]; ok {
+			value = in.Value
+			if in.Path != "" {
+				files[spec.Name] = in.Path
+			}
+		}
+		values[spec.Name] = value
+		ctx[spec.Name] = value
+	}
+	c.setTemplateValue(ctx, "inputs", values)
+	c.setTemplateValue(ctx, "files", files)
+}
+
+// declares reports whether the command has an arg, flag or input named name
+func (s *CommandSpec) declares(name string) bool {
+	for _, a := range s.Args {
+		if a.Name == name {
+			return true
+		}
+	}
+	for _, f := range s.Flags {
+		if f.Name == name {
+			return true
+		}
+	}
+	for _, in := range s.Inputs {
+		if in.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// setTemplateValue sets a built-in template value, unless the command
+// declares an arg, flag or input of that name, whose value comes first
+func (c *PluginCommand) setTemplateValue(ctx map[string]interface{}, key string, value interface{}) {
+	if !c.spec.declares(key) {
+		ctx[key] = value
+	}
+}
+
 // executeTemplate executes a Go template with the given context
 func (c *PluginCommand) executeTemplate(tmplStr string, ctx map[string]interface{}) (string, error) {
 	tmpl, err := template.New("prompt").Parse(tmplStr)
@@ -299,49 +521,12 @@ func (c *PluginCommand) executeTemplate(tmplStr string, ctx map[string]interface
 	return buf.String(), nil
 }
 
-// executeHooks executes a list of hook commands
-func (c *PluginCommand) executeHooks(ctx context.Context, hooks []HookAction) error {
-	for _, hook := range hooks {
-		// Check condition if present
-		if hook.If != "" {
-			// Simple condition check - in production this would be more sophisticated
-			// For now, skip conditional hooks
-			continue
-		}
-
-		// Execute shell command
-		if hook.Shell != "" {
-			cmd := exec.CommandContext(ctx, "sh", "-c", hook.Shell)
-			output, err := cmd.CombinedOutput()
-			if err != nil {
-				return fmt.Errorf("shell hook failed: %s (output: %s)", err, string(output))
-			}
-			continue
-		}
-
-		// Execute scmd command
-		if hook.Command != "" {
-			// Parse command and args
-			parts := strings.Fields(hook.Command)
-			if len(parts) == 0 {
-				continue
-			}
-			// For now, execute as shell command
-			// TODO: Use command registry to execute scmd commands
-			cmd := exec.CommandContext(ctx, parts[0], parts[1:]...)
-			output, err := cmd.CombinedOutput()
-			if err != nil {
-				return fmt.Errorf("command hook failed: %s (output: %s)", err, string(output))
-			}
-		}
-	}
-	return nil
-}
-
-// Loader loads plugin commands from installed command specs
+// Loader loads plugin commands from installed command specs and, when set,
+// the current project's .scmd/commands
 type Loader struct {
 	manager    *Manager
 	installDir string
+	project    *Project
 }
 
 // NewLoader creates a new plugin loader
@@ -352,21 +537,81 @@ func NewLoader(manager *Manager, installDir string) *Loader {
 	}
 }
 
-// LoadAll loads all installed plugin commands
+// SetProject adds a project's commands, which take precedence over
+// installed commands of the same name
+func (l *Loader) SetProject(p *Project) {
+	l.project = p
+}
+
+// Project returns the project set with SetProject, or nil
+func (l *Loader) Project() *Project {
+	return l.project
+}
+
+// LoadAll loads all installed plugin commands, project commands first
 func (l *Loader) LoadAll() ([]*PluginCommand, error) {
+	var commands []*PluginCommand
+	shadowed := make(map[string]bool)
+	if l.project != nil {
+		specs, err := l.manager.LoadInstalledCommands(l.project.CommandsDir())
+		if err != nil {
+			return nil, err
+		}
+		for _, spec := range specs {
+			cmd := NewPluginCommand(spec)
+			cmd.scope = ScopeProject
+			cmd.trustHooks = l.trustProject
+			commands = append(commands, cmd)
+			shadowed[spec.Name] = true
+		}
+	}
+
 	specs, err := l.manager.LoadInstalledCommands(l.installDir)
 	if err != nil {
 		return nil, err
 	}
-
-	commands := make([]*PluginCommand, len(specs))
-	for i, spec := range specs {
-		commands[i] = NewPluginCommand(spec)
+	for _, spec := range specs {
+		if !shadowed[spec.Name] {
+			commands = append(commands, NewPluginCommand(spec))
+		}
 	}
 
 	return commands, nil
 }
 
+// trustProject allows the project's hooks to run if the user trusted the
+// project as it is now, asking them if it can
+func (l *Loader) trustProject(prompt *inputPrompter) error {
+	trusted, changed, err := l.manager.ProjectTrust(l.project)
+	if err != nil {
+		return err
+	}
+	if trusted {
+		return nil
+	}
+
+	why := "its commands run hooks"
+	if changed {
+		why = "its commands changed since you trusted it"
+	}
+	if prompt == nil {
+		return fmt.Errorf("%w: %s (%s); run 'scmd project trust' there to allow its hooks",
+			ErrProjectNotTrusted, l.project.Root, why)
+	}
+
+	fmt.Fprintf(prompt.out, "The project %s has commands in %s, and %s.\n", l.project.Root, ProjectDirName, why)
+	fmt.Fprintf(prompt.out, "Hooks run shell commands. Trust this project? [y/N]: ")
+	answer, err := prompt.readLine()
+	if err != nil || !strings.EqualFold(answer, "y") && !strings.EqualFold(answer, "yes") {
+		return fmt.Errorf("%w: %s", ErrProjectNotTrusted, l.project.Root)
+	}
+
+	if err := l.manager.TrustProject(l.project); err != nil {
+		return err
+	}
+	return l.manager.SaveProjects()
+}
+
 // RegisterAll registers all plugin commands with the command registry
 func (l *Loader) RegisterAll(registry *command.Registry) error {
 	commands, err := l.LoadAll()
@@ -210,6 +210,57 @@ func TestPluginCommand_ExecuteTemplate_Error(t *testing.T) {
 	assert.Error(t, err)
 }
 
+func TestPluginCommand_RenderPrompt(t *testing.T) {
+	cmd := NewPluginCommand(&CommandSpec{
+		Name: "greet",
+		Args: []ArgSpec{{Name: "name", Required: true}},
+		Inputs: []InputSpec{
+			{Name: "tone", Type: InputTypeChoice, Choices: []string{"warm", "dry"}, Default: "warm"},
+		},
+		Prompt: PromptSpec{
+			System:   "Be {{.tone}}.",
+			Template: "Greet {{.name}}{{if .hooks.check.ran}} (checked){{end}}",
+		},
+		Hooks: &HooksSpec{Pre: []HookAction{{ID: "check", Shell: "exit 1"}}},
+	})
+
+	args := command.NewArgs()
+	args.Positional = []string{"Ada"}
+	system, prompt, err := cmd.RenderPrompt(args)
+	require.NoError(t, err)
+	assert.Equal(t, "Be warm.", system)
+	assert.Equal(t, "Greet Ada", prompt)
+
+	_, _, err = cmd.RenderPrompt(command.NewArgs())
+	assert.ErrorContains(t, err, "missing required argument: name")
+
+	args.Options["tone"] = "loud"
+	_, _, err = cmd.RenderPrompt(args)
+	assert.Error(t, err)
+}
+
+func TestPluginCommand_ArgsNamedLikeBuiltins(t *testing.T) {
+	cmd := NewPluginCommand(&CommandSpec{
+		Name:   "safe-delete",
+		Args:   []ArgSpec{{Name: "files", Required: true}},
+		Flags:  []FlagSpec{{Name: "hooks", Default: "off"}},
+		Inputs: []InputSpec{{Name: "reason", Default: "cleanup"}},
+		Prompt: PromptSpec{
+			Template: "Delete: {{.files}} hooks={{.hooks}} reason={{.inputs.reason}}",
+		},
+	})
+
+	args := command.NewArgs()
+	args.Positional = []string{"*.log"}
+	_, prompt, err := cmd.RenderPrompt(args)
+	require.NoError(t, err)
+	assert.Equal(t, "Delete: *.log hooks=off reason=cleanup", prompt)
+
+	result, err := cmd.Execute(context.Background(), args, &command.ExecContext{Backend: &promptRecorder{Backend: mock.New()}})
+	require.NoError(t, err)
+	assert.True(t, result.Success, result.Error)
+}
+
 func TestLoader_LoadAll(t *testing.T) {
 	tmpDir := t.TempDir()
 	m := NewManager(tmpDir)
@@ -0,0 +1,365 @@
+package repos
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/scmd/scmd/internal/command"
+	"github.com/scmd/scmd/internal/sandbox"
+)
+
+const (
+	// maxHookDepth bounds command hooks that run commands with hooks of
+	// their own
+	maxHookDepth = 4
+
+	// maxHookOutputEnv is the largest output passed to post-hooks in
+	// SCMD_OUTPUT; larger outputs are only on stdin
+	maxHookOutputEnv = 64 * 1024
+)
+
+// Hook stages
+const (
+	HookStagePre  = "pre"
+	HookStagePost = "post"
+)
+
+// unattendedFlags skip a command's confirmation or act on its result, such
+// as kill-process --yes, apply --yes or cmd --run. Hooks run without the
+// user watching, so a command hook may not pass them.
+var unattendedFlags = []string{"yes", "run", "apply"}
+
+type hookDepthKey struct{}
+
+// HookResult records the outcome of a hook
+type HookResult struct {
+	ID       string
+	Ran      bool // false if its condition was not met
+	ExitCode int
+	Output   string // stdout, without the trailing newline
+	Stderr   string
+}
+
+// hookRunner runs a command's hooks and keeps their results for the
+// conditions of later hooks and for the prompt template
+type hookRunner struct {
+	cmd     *PluginCommand
+	execCtx *command.ExecContext
+	ctx     context.Context
+
+	// values are the command's args, flags and inputs
+	values  map[string]string
+	ids     map[string]bool
+	results map[string]*HookResult
+	git     map[string]string
+}
+
+func (c *PluginCommand) newHookRunner(ctx context.Context, execCtx *command.ExecContext, tmplCtx map[string]interface{}) *hookRunner {
+	r := &hookRunner{
+		cmd:     c,
+		execCtx: execCtx,
+		ctx:     ctx,
+		values:  make(map[string]string),
+		ids:     make(map[string]bool),
+		results: make(map[string]*HookResult),
+	}
+
+	var names []string
+	for _, a := range c.spec.Args {
+		names = append(names, a.Name)
+	}
+	for _, f := range c.spec.Flags {
+		names = append(names, f.Name)
+	}
+	for _, in := range c.spec.Inputs {
+		names = append(names, in.Name)
+	}
+	for _, name := range names {
+		if v, ok := tmplCtx[name].(string); ok {
+			r.values[name] = v
+		}
+	}
+
+	if c.spec.Hooks != nil {
+		for i, h := range c.spec.Hooks.Pre {
+			r.ids[hookID(HookStagePre, i, h)] = true
+		}
+		for i, h := range c.spec.Hooks.Post {
+			r.ids[hookID(HookStagePost, i, h)] = true
+		}
+	}
+	return r
+}
+
+// hookID is the hook's id, or its stage and position (pre1, post2, ...)
+func hookID(stage string, i int, h HookAction) string {
+	if h.ID != "" {
+		return h.ID
+	}
+	return fmt.Sprintf("%s%d", stage, i+1)
+}
+
+// run runs hooks in order. stdin is passed to each hook: the command's
+// input for pre-hooks and the LLM output for post-hooks. A hook that fails
+// stops the command unless it sets continue_on_error.
+func (r *hookRunner) run(stage string, hooks []HookAction, stdin string) error {
+	for i, hook := range hooks {
+		id := hookID(stage, i, hook)
+
+		if hook.If != "" {
+			ok, err := EvalCondition(hook.If, r.resolve)
+			if err != nil {
+				return fmt.Errorf("hook %s: condition %q: %w", id, hook.If, err)
+			}
+			if !ok {
+				r.results[id] = &HookResult{ID: id}
+				continue
+			}
+		}
+
+		if (hook.Shell != "" || hook.Command != "") && !r.cmd.spec.allowsHooks() {
+			return fmt.Errorf("hook %s: hooks are not allowed by the command's permissions (set permissions.hooks)", id)
+		}
+
+		var res *HookResult
+		var err error
+		switch {
+		case hook.Shell != "":
+			res, err = r.runShell(id, stage, hook.Shell, stdin)
+		case hook.Command != "":
+			res, err = r.runCommand(hook.Command, stdin)
+		default:
+			return fmt.Errorf("hook %s: needs shell or command", id)
+		}
+		if err != nil {
+			return fmt.Errorf("hook %s: %w", id, err)
+		}
+		res.ID = id
+		r.results[id] = res
+
+		if res.ExitCode != 0 && !hook.ContinueOnError {
+			msg := fmt.Sprintf("hook %s failed with exit code %d", id, res.ExitCode)
+			if detail := strings.TrimSpace(res.Stderr + "\n" + res.Output); detail != "" {
+				msg += ": " + detail
+			}
+			return errors.New(msg)
+		}
+	}
+	return nil
+}
+
+// runShell runs a shell hook under the command's execution policy.
+// Besides stdin and the variables the policy allows, hooks see
+// SCMD_HOOK_STAGE, SCMD_COMMAND, SCMD_INPUT_<NAME> for each arg, flag and
+// input, and for post-hooks SCMD_OUTPUT.
+func (r *hookRunner) runShell(id, stage, script, stdin string) (*HookResult, error) {
+	env := []string{
+		"SCMD_HOOK_STAGE=" + stage,
+		"SCMD_COMMAND=" + r.cmd.Name(),
+	}
+	for name, value := range r.values {
+		env = append(env, "SCMD_INPUT_"+envName(name)+"="+value)
+	}
+	if stage == HookStagePost && len(stdin) <= maxHookOutputEnv {
+		env = append(env, "SCMD_OUTPUT="+stdin)
+	}
+
+	policy := r.cmd.execPolicy(r.execCtx)
+	out, err := policy.Run(r.ctx, sandbox.Cmd{
+		Source: "hook " + id,
+		Args:   []string{"sh", "-c", script},
+		Env:    env,
+		Stdin:  strings.NewReader(stdin),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if out.TimedOut {
+		return nil, fmt.Errorf("timed out after %s", policy.Timeout)
+	}
+	return &HookResult{
+		Ran:      true,
+		ExitCode: out.ExitCode,
+		Output:   strings.TrimRight(out.Stdout, "\n"),
+		Stderr:   strings.TrimSpace(out.Stderr),
+	}, nil
+}
+
+// runCommand runs another registered command, e.g. "summarize --length=short".
+// stdin becomes the command's input unless the line sets --stdin.
+func (r *hookRunner) runCommand(line, stdin string) (*HookResult, error) {
+	args := command.NewParser().Parse(strings.TrimPrefix(strings.TrimSpace(line), "/"))
+	if len(args.Positional) == 0 {
+		return nil, fmt.Errorf("empty command")
+	}
+	name := args.Positional[0]
+	args.Positional = args.Positional[1:]
+	if flag := unattendedFlag(args); flag != "" {
+		return nil, fmt.Errorf("%s: --%s is not allowed in hooks, which run without confirmation", name, flag)
+	}
+	if _, ok := args.Options["stdin"]; !ok && stdin != "" {
+		args.Options["stdin"] = stdin
+	}
+
+	if r.execCtx.Registry == nil {
+		return nil, fmt.Errorf("no command registry to run %s", name)
+	}
+	cmd, ok := r.execCtx.Registry.Get(name)
+	if !ok {
+		return nil, fmt.Errorf("unknown command %s", name)
+	}
+
+	depth, _ := r.ctx.Value(hookDepthKey{}).(int)
+	if depth >= maxHookDepth {
+		return nil, fmt.Errorf("command hooks nested more than %d deep", maxHookDepth)
+	}
+	ctx := context.WithValue(r.ctx, hookDepthKey{}, depth+1)
+
+	if err := cmd.Validate(args); err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	result, err := cmd.Execute(ctx, args, r.execCtx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+
+	res := &HookResult{Ran: true, Output: strings.TrimRight(result.Output, "\n")}
+	if !result.Success {
+		res.ExitCode = result.ExitCode
+		if res.ExitCode == 0 {
+			res.ExitCode = 1
+		}
+		res.Stderr = result.Error
+	}
+	return res, nil
+}
+
+// unattendedFlag returns the first of unattendedFlags args sets, if any
+func unattendedFlag(args *command.Args) string {
+	for _, flag := range unattendedFlags {
+		if _, ok := args.Options[flag]; ok || args.Flags[flag] {
+			return flag
+		}
+	}
+	return ""
+}
+
+// templateValues exposes hook results to templates as
+// {{.hooks.ID.output}}, {{.hooks.ID.exit_code}}, {{.hooks.ID.ok}} and
+// {{.hooks.ID.ran}}. Hooks that have not run look like skipped ones.
+func (r *hookRunner) templateValues() map[string]interface{} {
+	values := make(map[string]interface{}, len(r.ids))
+	for id := range r.ids {
+		res, ok := r.results[id]
+		if !ok {
+			res = &HookResult{ID: id}
+		}
+		values[id] = map[string]interface{}{
+			"output":    res.Output,
+			"exit_code": res.ExitCode,
+			"ok":        res.Ran && res.ExitCode == 0,
+			"ran":       res.Ran,
+		}
+	}
+	return values
+}
+
+// resolve looks up names in hook conditions
+func (r *hookRunner) resolve(name string) (string, error) {
+	scope, rest, _ := strings.Cut(name, ".")
+	switch scope {
+	case "env":
+		if rest == "" {
+			return "", fmt.Errorf("env needs a variable name")
+		}
+		return os.Getenv(rest), nil
+
+	case "inputs":
+		v, ok := r.values[rest]
+		if !ok {
+			return "", fmt.Errorf("unknown input %q", rest)
+		}
+		return v, nil
+
+	case "git":
+		if r.git == nil {
+			r.git = gitState(r.ctx)
+		}
+		v, ok := r.git[rest]
+		if !ok {
+			return "", fmt.Errorf("unknown git property %q (want repo, dirty, clean, staged or branch)", rest)
+		}
+		return v, nil
+
+	case "hooks":
+		id, field, _ := strings.Cut(rest, ".")
+		if !r.ids[id] {
+			return "", fmt.Errorf("unknown hook %q", id)
+		}
+		res, ok := r.results[id]
+		if !ok {
+			// Hooks that have not run yet look like skipped ones
+			res = &HookResult{ID: id}
+		}
+		switch field {
+		case "exit_code":
+			return strconv.Itoa(res.ExitCode), nil
+		case "output":
+			return res.Output, nil
+		case "ok":
+			return boolString(res.Ran && res.ExitCode == 0), nil
+		case "ran":
+			return boolString(res.Ran), nil
+		}
+		return "", fmt.Errorf("unknown hook field %q (want exit_code, output, ok or ran)", field)
+	}
+	return "", fmt.Errorf("unknown name %q", name)
+}
+
+// gitState reads the working tree's git status
+func gitState(ctx context.Context) map[string]string {
+	state := map[string]string{
+		"repo": "false", "dirty": "false", "clean": "false", "staged": "false", "branch": "",
+	}
+	if err := exec.CommandContext(ctx, "git", "rev-parse", "--is-inside-work-tree").Run(); err != nil {
+		return state
+	}
+	state["repo"] = "true"
+
+	if out, err := exec.CommandContext(ctx, "git", "rev-parse", "--abbrev-ref", "HEAD").Output(); err == nil {
+		state["branch"] = strings.TrimSpace(string(out))
+	}
+
+	out, err := exec.CommandContext(ctx, "git", "status", "--porcelain").Output()
+	if err != nil {
+		return state
+	}
+	status := strings.TrimRight(string(out), "\n")
+	state["dirty"] = boolString(status != "")
+	state["clean"] = boolString(status == "")
+	for _, line := range strings.Split(status, "\n") {
+		if len(line) > 1 && line[0] != ' ' && line[0] != '?' {
+			state["staged"] = "true"
+			break
+		}
+	}
+	return state
+}
+
+// envName turns an input name into an environment variable suffix
+func envName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		}
+		return '_'
+	}, name)
+}
@@ -0,0 +1,203 @@
+package repos
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/scmd/scmd/internal/backend/mock"
+	"github.com/scmd/scmd/internal/command"
+	"github.com/scmd/scmd/internal/config"
+)
+
+func TestEvalCondition(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "go.mod")
+	require.NoError(t, os.WriteFile(file, nil, 0644))
+
+	names := map[string]string{
+		"env.CI":           "true",
+		"env.EMPTY":        "",
+		"inputs.mode":      "strict",
+		"hooks.lint.code":  "2",
+		"git.branch":       "release/1.2",
+		"inputs.zero":      "0",
+		"inputs.with-dash": "x",
+	}
+	resolve := func(name string) (string, error) {
+		if v, ok := names[name]; ok {
+			return v, nil
+		}
+		return "", assert.AnError
+	}
+
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{"env.CI", true},
+		{"env.EMPTY", false},
+		{"inputs.zero", false},
+		{"!env.EMPTY", true},
+		{"inputs.mode == 'strict'", true},
+		{`inputs.mode != "strict"`, false},
+		{"hooks.lint.code != 0", true},
+		{"hooks.lint.code == 2 && env.CI", true},
+		{"env.EMPTY || inputs.mode == 'lax'", false},
+		{"!(env.EMPTY || env.CI)", false},
+		{"env.CI && !env.EMPTY || false", true},
+		{"exists('" + file + "')", true},
+		{"exists('" + filepath.Join(dir, "nope") + "')", false},
+		{"contains(git.branch, 'release/')", true},
+		{"inputs.with-dash == 'x'", true},
+	}
+	for _, tt := range tests {
+		got, err := EvalCondition(tt.expr, resolve)
+		require.NoError(t, err, tt.expr)
+		assert.Equal(t, tt.want, got, tt.expr)
+	}
+
+	for expr, want := range map[string]string{
+		"env.CI ==":            "unexpected end of condition",
+		"(env.CI":              `expected ")"`,
+		"'open":                "unterminated string",
+		"env.CI $ 1":           "unexpected character",
+		"run('rm -rf /')":      "unknown function run",
+		"exists()":             "exists takes 1 argument",
+		"env.CI env.CI":        `unexpected "env.CI"`,
+		"env.CI || inputs.bad": assert.AnError.Error(),
+	} {
+		_, err := EvalCondition(expr, resolve)
+		require.Error(t, err, expr)
+		assert.Contains(t, err.Error(), want, expr)
+	}
+}
+
+func hooksSpec(hooks *HooksSpec) *CommandSpec {
+	return &CommandSpec{
+		Name:   "hooked",
+		Args:   []ArgSpec{{Name: "target", Default: "prod"}},
+		Hooks:  hooks,
+		Prompt: PromptSpec{Template: "{{.hooks.info.output}}|{{.hooks.info.exit_code}}|{{.hooks.skipped.ran}}"},
+	}
+}
+
+func TestPluginCommand_PreHooks(t *testing.T) {
+	t.Setenv("SCMD_TEST_HOOKS", "on")
+	spec := hooksSpec(&HooksSpec{Pre: []HookAction{
+		{ID: "info", Shell: `echo "$SCMD_HOOK_STAGE $SCMD_COMMAND $SCMD_INPUT_TARGET $(cat)"; exit 3`, ContinueOnError: true},
+		{ID: "skipped", Shell: "exit 1", If: "hooks.info.exit_code == 0"},
+		{Shell: "exit 1", If: "!env.SCMD_TEST_HOOKS || inputs.target != 'prod'"},
+	}})
+
+	rec := &promptRecorder{Backend: mock.New()}
+	args := command.NewArgs()
+	args.Options["stdin"] = "piped"
+	result, err := NewPluginCommand(spec).Execute(context.Background(), args, &command.ExecContext{Backend: rec})
+	require.NoError(t, err)
+	require.True(t, result.Success, result.Error)
+	assert.Equal(t, "pre hooked prod piped|3|false", rec.prompt)
+}
+
+func TestPluginCommand_HookFailures(t *testing.T) {
+	tests := []struct {
+		name  string
+		hooks *HooksSpec
+		want  string
+	}{
+		{"exit code", &HooksSpec{Pre: []HookAction{{Shell: "echo oops >&2; exit 4"}}},
+			"pre-hook failed: hook pre1 failed with exit code 4: oops"},
+		{"bad condition", &HooksSpec{Pre: []HookAction{{Shell: "true", If: "inputs.missing"}}},
+			`pre-hook failed: hook pre1: condition "inputs.missing": unknown input "missing"`},
+		{"unknown hook", &HooksSpec{Pre: []HookAction{{Shell: "true", If: "hooks.lint.ok"}}},
+			`pre-hook failed: hook pre1: condition "hooks.lint.ok": unknown hook "lint"`},
+		{"unknown command", &HooksSpec{Post: []HookAction{{Command: "nope"}}},
+			"post-hook failed: hook post1: unknown command nope"},
+		{"empty hook", &HooksSpec{Pre: []HookAction{{ID: "x"}}},
+			"pre-hook failed: hook x: needs shell or command"},
+		{"confirmation skipped", &HooksSpec{Pre: []HookAction{{Command: "kill-process sshd --yes"}}},
+			"pre-hook failed: hook pre1: kill-process: --yes is not allowed in hooks, which run without confirmation"},
+		{"result applied", &HooksSpec{Post: []HookAction{{Command: "review --apply=true"}}},
+			"post-hook failed: hook post1: review: --apply is not allowed in hooks, which run without confirmation"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			execCtx := &command.ExecContext{Backend: mock.New(), Registry: command.NewRegistry()}
+			result, err := NewPluginCommand(hooksSpec(tt.hooks)).Execute(context.Background(), command.NewArgs(), execCtx)
+			require.NoError(t, err)
+			assert.False(t, result.Success)
+			assert.Equal(t, tt.want, result.Error)
+		})
+	}
+}
+
+func TestPluginCommand_PostHooksReceiveOutput(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "out")
+	spec := hooksSpec(&HooksSpec{Post: []HookAction{
+		{Shell: `cat > "$OUT_FILE"; printf '|%s' "$SCMD_OUTPUT" >> "$OUT_FILE"`},
+	}})
+	t.Setenv("OUT_FILE", out)
+	// Hooks see only the variables the config lets through
+	cfg := config.Default()
+	cfg.Exec.Env = []string{"OUT_FILE"}
+	cfg.Exec.Audit = false
+
+	be := &scriptedBackend{Backend: mock.New(), responses: []string{"the answer"}}
+	result, err := NewPluginCommand(spec).Execute(context.Background(), command.NewArgs(), &command.ExecContext{Backend: be, Config: cfg})
+	require.NoError(t, err)
+	require.True(t, result.Success, result.Error)
+
+	data, err := os.ReadFile(out)
+	require.NoError(t, err)
+	assert.Equal(t, "the answer|the answer", string(data))
+}
+
+func TestPluginCommand_CommandHooks(t *testing.T) {
+	registry := command.NewRegistry()
+	require.NoError(t, registry.Register(NewPluginCommand(&CommandSpec{
+		Name:   "shout",
+		Args:   []ArgSpec{{Name: "word"}},
+		Flags:  []FlagSpec{{Name: "suffix"}},
+		Prompt: PromptSpec{Template: "shout {{.word}}{{.suffix}} {{.stdin}}"},
+	})))
+
+	spec := hooksSpec(&HooksSpec{
+		Pre:  []HookAction{{ID: "info", Command: `/shout "hi there" --suffix=!`}},
+		Post: []HookAction{{ID: "skipped", Command: "shout done"}},
+	})
+	require.NoError(t, registry.Register(NewPluginCommand(spec)))
+
+	be := &scriptedBackend{Backend: mock.New(), responses: []string{"HI THERE!", "main answer", "DONE"}}
+	args := command.NewArgs()
+	args.Options["stdin"] = "input"
+	result, err := NewPluginCommand(spec).Execute(context.Background(), args, &command.ExecContext{Backend: be, Registry: registry})
+	require.NoError(t, err)
+	require.True(t, result.Success, result.Error)
+
+	require.Len(t, be.requests, 3)
+	assert.Equal(t, "shout hi there! input", be.requests[0].Prompt)
+	assert.Equal(t, "HI THERE!|0|false", be.requests[1].Prompt)
+	assert.Equal(t, "shout done main answer", be.requests[2].Prompt)
+	assert.Equal(t, "main answer", result.Output)
+}
+
+func TestPluginCommand_CommandHooksDepthLimit(t *testing.T) {
+	registry := command.NewRegistry()
+	spec := &CommandSpec{
+		Name:   "loop",
+		Hooks:  &HooksSpec{Pre: []HookAction{{Command: "loop"}}},
+		Prompt: PromptSpec{Template: "x"},
+	}
+	require.NoError(t, registry.Register(NewPluginCommand(spec)))
+
+	result, err := NewPluginCommand(spec).Execute(context.Background(), command.NewArgs(), &command.ExecContext{Backend: mock.New(), Registry: registry})
+	require.NoError(t, err)
+	assert.False(t, result.Success)
+	assert.Contains(t, result.Error, "command hooks nested more than 4 deep")
+	assert.Equal(t, 5, strings.Count(result.Error, "pre-hook failed"))
+}
@@ -0,0 +1,311 @@
+package repos
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/term"
+
+	"github.com/scmd/scmd/internal/command"
+)
+
+// MaxInputFileSize is the largest file a file-typed input may read
+const MaxInputFileSize = 1024 * 1024
+
+// Input types
+const (
+	InputTypeString    = "string"
+	InputTypeFile      = "file"
+	InputTypeChoice    = "choice"
+	InputTypeMultiline = "multiline"
+)
+
+// inputType returns the input's type, defaulting to string
+func (s InputSpec) inputType() string {
+	if s.Type == "" {
+		return InputTypeString
+	}
+	return s.Type
+}
+
+// BoundInput is an input value resolved from the command line, stdin, a
+// default or a prompt
+type BoundInput struct {
+	Spec  InputSpec
+	Value string
+	// Path is the file a file-typed input was read from
+	Path string
+	// Source is where the value came from: option, arg, stdin, default or prompt
+	Source string
+}
+
+// inputPrompter asks the user for a missing input
+type inputPrompter struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+// ask prompts for one input. Multiline inputs end with a line holding only
+// a dot, or at end of input.
+func (p *inputPrompter) ask(spec InputSpec) (string, error) {
+	label := spec.Name
+	if spec.Description != "" {
+		label += " (" + spec.Description + ")"
+	}
+
+	switch spec.inputType() {
+	case InputTypeChoice:
+		fmt.Fprintf(p.out, "%s:\n", label)
+		for i, choice := range spec.Choices {
+			fmt.Fprintf(p.out, "  %d. %s\n", i+1, choice)
+		}
+		fmt.Fprintf(p.out, "Choice: ")
+		line, err := p.readLine()
+		if err != nil {
+			return "", err
+		}
+		if n, err := strconv.Atoi(line); err == nil && n >= 1 && n <= len(spec.Choices) {
+			return spec.Choices[n-1], nil
+		}
+		return line, nil
+
+	case InputTypeMultiline:
+		fmt.Fprintf(p.out, "%s, end with a line containing only '.':\n", label)
+		var lines []string
+		for {
+			line, err := p.in.ReadString('\n')
+			trimmed := strings.TrimRight(line, "\r\n")
+			if trimmed == "." {
+				break
+			}
+			if trimmed != "" || err == nil {
+				lines = append(lines, trimmed)
+			}
+			if err != nil {
+				if len(lines) == 0 {
+					return "", err
+				}
+				break
+			}
+		}
+		return strings.Join(lines, "\n"), nil
+
+	default:
+		if spec.inputType() == InputTypeFile {
+			label += " [path]"
+		}
+		fmt.Fprintf(p.out, "%s: ", label)
+		return p.readLine()
+	}
+}
+
+func (p *inputPrompter) readLine() (string, error) {
+	line, err := p.in.ReadString('\n')
+	if err != nil && line == "" {
+		return "", err
+	}
+	return strings.TrimSpace(line), nil
+}
+
+// optionValue finds an input's --name=value option; dashes and underscores
+// are interchangeable
+func optionValue(args *command.Args, name string) (string, bool) {
+	if v, ok := args.Options[name]; ok {
+		return v, true
+	}
+	for _, alt := range []string{
+		strings.ReplaceAll(name, "_", "-"),
+		strings.ReplaceAll(name, "-", "_"),
+	} {
+		if v, ok := args.Options[alt]; ok {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+// bindInputs resolves the command's declared inputs. Values come from
+// --name=value options, then the positional arguments left over after Args,
+// in declaration order, then stdin for the first multiline input, then
+// defaults. Missing required inputs are asked for when prompt is set.
+func (c *PluginCommand) bindInputs(args *command.Args, prompt *inputPrompter) (map[string]*BoundInput, error) {
+	inputs := c.spec.Inputs
+	bound := make(map[string]*BoundInput, len(inputs))
+
+	for _, spec := range inputs {
+		switch spec.inputType() {
+		case InputTypeString, InputTypeFile, InputTypeMultiline:
+		case InputTypeChoice:
+			if len(spec.Choices) == 0 {
+				return nil, fmt.Errorf("input %s is a choice but has no choices", spec.Name)
+			}
+		default:
+			return nil, fmt.Errorf("input %s has unknown type %q (use string, file, choice or multiline)", spec.Name, spec.Type)
+		}
+		if v, ok := optionValue(args, spec.Name); ok {
+			bound[spec.Name] = &BoundInput{Spec: spec, Value: v, Source: "option"}
+		}
+	}
+
+	var positional []string
+	if len(args.Positional) > len(c.spec.Args) {
+		positional = args.Positional[len(c.spec.Args):]
+	}
+	for _, spec := range inputs {
+		if len(positional) == 0 {
+			break
+		}
+		if _, ok := bound[spec.Name]; !ok {
+			bound[spec.Name] = &BoundInput{Spec: spec, Value: positional[0], Source: "arg"}
+			positional = positional[1:]
+		}
+	}
+
+	stdin, hasStdin := args.Options["stdin"]
+	for _, spec := range inputs {
+		if _, ok := bound[spec.Name]; ok {
+			continue
+		}
+		switch {
+		case hasStdin && spec.inputType() == InputTypeMultiline:
+			bound[spec.Name] = &BoundInput{Spec: spec, Value: stdin, Source: "stdin"}
+			hasStdin = false
+		case spec.Default != "":
+			bound[spec.Name] = &BoundInput{Spec: spec, Value: spec.Default, Source: "default"}
+		case spec.Required && prompt != nil:
+			value, err := prompt.ask(spec)
+			if err != nil {
+				return nil, fmt.Errorf("read input %s: %w", spec.Name, err)
+			}
+			if value == "" {
+				return nil, fmt.Errorf("missing required input: %s", spec.Name)
+			}
+			bound[spec.Name] = &BoundInput{Spec: spec, Value: value, Source: "prompt"}
+		case spec.Required:
+			return nil, fmt.Errorf("missing required input: %s (pass --%s=<value>)", spec.Name, spec.Name)
+		}
+	}
+
+	for _, b := range bound {
+		if err := b.check(args, c.spec.Permissions); err != nil {
+			return nil, err
+		}
+	}
+	return bound, nil
+}
+
+// check validates a value against its type and reads file inputs. A file
+// named by the spec's default, rather than by the user, is only read if
+// perms allow reading it.
+func (b *BoundInput) check(args *command.Args, perms *PermissionsSpec) error {
+	spec := b.Spec
+	switch spec.inputType() {
+	case InputTypeString:
+		if strings.ContainsAny(b.Value, "\r\n") {
+			return fmt.Errorf("input %s must be a single line", spec.Name)
+		}
+
+	case InputTypeChoice:
+		for _, choice := range spec.Choices {
+			if strings.EqualFold(choice, b.Value) {
+				b.Value = choice
+				return nil
+			}
+		}
+		return fmt.Errorf("invalid value %q for input %s: must be one of %s",
+			b.Value, spec.Name, strings.Join(spec.Choices, ", "))
+
+	case InputTypeFile:
+		if b.Value == "-" {
+			stdin, ok := args.Options["stdin"]
+			if !ok {
+				return fmt.Errorf("input %s: '-' given but nothing was piped to stdin", spec.Name)
+			}
+			b.Path, b.Value = "-", stdin
+			return nil
+		}
+		if b.Source == "default" {
+			if _, ok := perms.readScope().AllowsRead(b.Value); !ok {
+				return fmt.Errorf("input %s: default %s is not readable under the command's filesystem.read permissions; pass --%s=<path>",
+					spec.Name, b.Value, spec.Name)
+			}
+		}
+		content, err := readInputFile(b.Value)
+		if err != nil {
+			return fmt.Errorf("input %s: %w", spec.Name, err)
+		}
+		b.Path, b.Value = b.Value, content
+	}
+	return nil
+}
+
+// readInputFile reads a regular text file no larger than MaxInputFileSize.
+// The limit is applied while reading, as a file can grow after it is
+// checked.
+func readInputFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+	if info.IsDir() {
+		return "", fmt.Errorf("%s is a directory", path)
+	}
+	// Devices such as /dev/zero and FIFOs report no size but never end
+	if !info.Mode().IsRegular() {
+		return "", fmt.Errorf("%s is not a regular file", path)
+	}
+	if info.Size() > MaxInputFileSize {
+		return "", fmt.Errorf("%s is %d bytes, larger than the %d byte limit", path, info.Size(), MaxInputFileSize)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(f, MaxInputFileSize+1))
+	if err != nil {
+		return "", err
+	}
+	if len(data) > MaxInputFileSize {
+		return "", fmt.Errorf("%s is larger than the %d byte limit", path, MaxInputFileSize)
+	}
+	if bytes.IndexByte(data, 0) >= 0 {
+		return "", fmt.Errorf("%s is not a text file", path)
+	}
+	return string(data), nil
+}
+
+// inputPrompt returns a prompter when the user can be asked for missing
+// inputs: on a terminal, or on /dev/tty when stdin is piped
+func (c *PluginCommand) inputPrompt(args *command.Args) (*inputPrompter, func()) {
+	out := c.promptOutput
+	if out == nil {
+		out = os.Stderr
+	}
+	if c.promptInput != nil {
+		return &inputPrompter{in: bufio.NewReader(c.promptInput), out: out}, func() {}
+	}
+
+	if _, piped := args.Options["stdin"]; !piped {
+		if term.IsTerminal(int(os.Stdin.Fd())) {
+			return &inputPrompter{in: bufio.NewReader(os.Stdin), out: out}, func() {}
+		}
+		return nil, func() {}
+	}
+	if !term.IsTerminal(int(os.Stderr.Fd())) {
+		return nil, func() {}
+	}
+
+	tty, err := os.Open("/dev/tty")
+	if err != nil {
+		return nil, func() {}
+	}
+	return &inputPrompter{in: bufio.NewReader(tty), out: out}, func() { tty.Close() }
+}
@@ -0,0 +1,205 @@
+package repos
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/scmd/scmd/internal/backend"
+	"github.com/scmd/scmd/internal/backend/mock"
+	"github.com/scmd/scmd/internal/command"
+)
+
+// promptRecorder is a mock backend that keeps the last prompt
+type promptRecorder struct {
+	*mock.Backend
+	prompt string
+}
+
+func (b *promptRecorder) Complete(ctx context.Context, req *backend.CompletionRequest) (*backend.CompletionResponse, error) {
+	b.prompt = req.Prompt
+	return b.Backend.Complete(ctx, req)
+}
+
+func inputsSpec() *CommandSpec {
+	return &CommandSpec{
+		Name: "translate",
+		Args: []ArgSpec{{Name: "target"}},
+		Inputs: []InputSpec{
+			{Name: "source", Type: "file", Required: true},
+			{Name: "tone", Type: "choice", Choices: []string{"formal", "casual"}, Default: "formal"},
+			{Name: "notes", Type: "multiline"},
+			{Name: "max_words", Description: "word limit"},
+		},
+		Prompt: PromptSpec{
+			Template: "{{.target}}|{{.source}}|{{.files.source}}|{{.tone}}|{{.inputs.notes}}|{{.max_words}}",
+		},
+	}
+}
+
+func writeInputFile(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "in.txt")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	return path
+}
+
+func TestBindInputs_Sources(t *testing.T) {
+	path := writeInputFile(t, "hello")
+	cmd := NewPluginCommand(inputsSpec())
+
+	args := command.NewArgs()
+	args.Positional = []string{"french", path}
+	args.Options["tone"] = "CASUAL"
+	args.Options["max-words"] = "50"
+	args.Options["stdin"] = "line one\nline two"
+
+	bound, err := cmd.bindInputs(args, nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, "hello", bound["source"].Value)
+	assert.Equal(t, path, bound["source"].Path)
+	assert.Equal(t, "arg", bound["source"].Source)
+	assert.Equal(t, "casual", bound["tone"].Value)
+	assert.Equal(t, "option", bound["tone"].Source)
+	assert.Equal(t, "line one\nline two", bound["notes"].Value)
+	assert.Equal(t, "stdin", bound["notes"].Source)
+	assert.Equal(t, "50", bound["max_words"].Value)
+}
+
+func TestBindInputs_Defaults(t *testing.T) {
+	cmd := NewPluginCommand(inputsSpec())
+
+	args := command.NewArgs()
+	args.Options["source"] = writeInputFile(t, "x")
+
+	bound, err := cmd.bindInputs(args, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "formal", bound["tone"].Value)
+	assert.Equal(t, "default", bound["tone"].Source)
+	assert.NotContains(t, bound, "notes")
+}
+
+func TestBindInputs_FileDefaultsFollowReadPermissions(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	require.NoError(t, os.WriteFile("README.md", []byte("readme"), 0644))
+	outside := writeInputFile(t, "secret")
+
+	bind := func(def string, perms *PermissionsSpec, options map[string]string) (map[string]*BoundInput, error) {
+		spec := inputsSpec()
+		spec.Inputs[0].Default = def
+		spec.Permissions = perms
+		args := command.NewArgs()
+		for k, v := range options {
+			args.Options[k] = v
+		}
+		return NewPluginCommand(spec).bindInputs(args, nil)
+	}
+
+	// Without a permissions block, defaults are read from the working directory only
+	bound, err := bind("README.md", nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "readme", bound["source"].Value)
+	_, err = bind(outside, nil, nil)
+	assert.ErrorContains(t, err, "input source: default "+outside+" is not readable under the command's filesystem.read permissions; pass --source=<path>")
+
+	// With one, only from the paths it may read
+	_, err = bind("README.md", &PermissionsSpec{}, nil)
+	assert.ErrorContains(t, err, "is not readable under the command's filesystem.read permissions")
+	bound, err = bind(outside, &PermissionsSpec{Filesystem: FilesystemPermissions{Read: []string{filepath.Dir(outside) + "/"}}}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "secret", bound["source"].Value)
+
+	// A path the user gives is read either way
+	bound, err = bind("README.md", &PermissionsSpec{}, map[string]string{"source": outside})
+	require.NoError(t, err)
+	assert.Equal(t, "secret", bound["source"].Value)
+}
+
+func TestBindInputs_Errors(t *testing.T) {
+	bigFile := filepath.Join(t.TempDir(), "big.txt")
+	require.NoError(t, os.WriteFile(bigFile, bytes.Repeat([]byte("a"), MaxInputFileSize+1), 0644))
+	binFile := filepath.Join(t.TempDir(), "bin")
+	require.NoError(t, os.WriteFile(binFile, []byte{0x7f, 'E', 'L', 'F', 0}, 0644))
+
+	tests := []struct {
+		name    string
+		options map[string]string
+		spec    func(*CommandSpec)
+		want    string
+	}{
+		{"missing required", nil, nil, "missing required input: source (pass --source=<value>)"},
+		{"bad choice", map[string]string{"source": "-", "stdin": "x", "tone": "angry"}, nil, `invalid value "angry" for input tone: must be one of formal, casual`},
+		{"missing file", map[string]string{"source": "/no/such/file"}, nil, "input source:"},
+		{"directory", map[string]string{"source": t.TempDir()}, nil, "is a directory"},
+		{"too large", map[string]string{"source": bigFile}, nil, "larger than the 1048576 byte limit"},
+		{"binary", map[string]string{"source": binFile}, nil, "is not a text file"},
+		{"device", map[string]string{"source": os.DevNull}, nil, os.DevNull + " is not a regular file"},
+		{"stdin without pipe", map[string]string{"source": "-"}, nil, "nothing was piped"},
+		{"multiline string", map[string]string{"source": "-", "stdin": "x", "max_words": "1\n2"}, nil, "max_words must be a single line"},
+		{"unknown type", nil, func(s *CommandSpec) { s.Inputs[0].Type = "number" }, `unknown type "number"`},
+		{"choice without choices", map[string]string{"source": "-", "stdin": "x"},
+			func(s *CommandSpec) { s.Inputs[1].Choices = nil }, "has no choices"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := inputsSpec()
+			if tt.spec != nil {
+				tt.spec(spec)
+			}
+			args := command.NewArgs()
+			for k, v := range tt.options {
+				args.Options[k] = v
+			}
+			err := NewPluginCommand(spec).Validate(args)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.want)
+		})
+	}
+}
+
+func TestPluginCommand_PromptsForMissingInputs(t *testing.T) {
+	path := writeInputFile(t, "file body")
+	spec := inputsSpec()
+	spec.Inputs[1].Default = ""
+	spec.Inputs[1].Required = true
+	spec.Inputs[2].Required = true
+
+	cmd := NewPluginCommand(spec)
+	out := &bytes.Buffer{}
+	cmd.promptInput = strings.NewReader(path + "\n2\nfirst\n\nsecond\n.\n")
+	cmd.promptOutput = out
+	rec := &promptRecorder{Backend: mock.New()}
+
+	args := command.NewArgs()
+	args.Positional = []string{"german"}
+
+	result, err := cmd.Execute(context.Background(), args, &command.ExecContext{Backend: rec})
+	require.NoError(t, err)
+	require.True(t, result.Success, result.Error)
+
+	assert.Equal(t, "german|file body|"+path+"|casual|first\n\nsecond|", rec.prompt)
+	assert.Contains(t, out.String(), "source [path]: ")
+	assert.Contains(t, out.String(), "  2. casual")
+	assert.Contains(t, out.String(), "notes, end with a line containing only '.':")
+}
+
+func TestPluginCommand_PromptEndsEarly(t *testing.T) {
+	cmd := NewPluginCommand(inputsSpec())
+	cmd.promptInput = strings.NewReader("")
+	cmd.promptOutput = &bytes.Buffer{}
+	args := command.NewArgs()
+	args.Positional = []string{"german"}
+
+	result, err := cmd.Execute(context.Background(), args, &command.ExecContext{Backend: mock.New()})
+	require.NoError(t, err)
+	assert.False(t, result.Success)
+	assert.Contains(t, result.Error, "read input source: EOF")
+}
@@ -0,0 +1,1122 @@
+package repos
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/scmd/scmd/internal/command"
+	"github.com/scmd/scmd/internal/preview"
+	"github.com/scmd/scmd/internal/tools"
+	"github.com/scmd/scmd/internal/validation"
+)
+
+// Lint severities
+const (
+	LintError   = "error"
+	LintWarning = "warning"
+)
+
+// ManifestFile is the name of a repository's manifest
+const ManifestFile = "scmd-repo.yaml"
+
+// LintIssue is a problem found in a command spec or repository manifest
+type LintIssue struct {
+	File     string `json:"file"`
+	Line     int    `json:"line,omitempty"`
+	Severity string `json:"severity"`
+	Rule     string `json:"rule"`
+	Message  string `json:"message"`
+}
+
+// String formats the issue as file:line: severity: message (rule)
+func (i LintIssue) String() string {
+	loc := i.File
+	if i.Line > 0 {
+		loc += ":" + strconv.Itoa(i.Line)
+	}
+	return fmt.Sprintf("%s: %s: %s (%s)", loc, i.Severity, i.Message, i.Rule)
+}
+
+// Linter checks command specs and repository manifests without running
+// them
+type Linter struct {
+	// Known reports whether a command name can be run, for compose steps
+	// and command hooks. If nil, they are not checked.
+	Known func(name string) bool
+}
+
+// templateBuiltins are the template values every command has, besides its
+// args, flags and inputs
+var templateBuiltins = map[string]bool{
+	"stdin": true, "input": true, "data": true, "args": true, "all_args": true,
+	"inputs": true, "files": true, "hooks": true,
+}
+
+// templateMaps are the built-in values an arg, flag or input of the same
+// name replaces
+var templateMaps = map[string]bool{"inputs": true, "files": true, "hooks": true}
+
+// identPattern matches names usable as {{.name}} in templates
+var identPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// templateErrLine finds the line in a text/template parse error
+var templateErrLine = regexp.MustCompile(`^template: [^:]*:(\d+):`)
+
+// specLinter collects the issues of one command spec
+type specLinter struct {
+	file   string
+	root   *yaml.Node
+	spec   *CommandSpec
+	known  func(name string) bool
+	issues []LintIssue
+}
+
+// LintSpec checks a command spec
+func (l *Linter) LintSpec(file string, data []byte) []LintIssue {
+	_, issues := l.lintSpec(file, data, l.Known)
+	return issues
+}
+
+// lintSpec checks a command spec, returning it if it parsed
+func (l *Linter) lintSpec(file string, data []byte, known func(string) bool) (*CommandSpec, []LintIssue) {
+	s := &specLinter{file: file, known: known}
+
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		s.add(yamlErrLine(err.Error()), LintError, "yaml", "%s", strings.TrimPrefix(err.Error(), "yaml: "))
+		return nil, s.issues
+	}
+	if len(root.Content) == 0 {
+		s.add(0, LintError, "yaml", "file is empty")
+		return nil, s.issues
+	}
+	s.root = &root
+
+	var spec CommandSpec
+	if err := decodeStrict(data, &spec); err != nil {
+		var typeErr *yaml.TypeError
+		if !errors.As(err, &typeErr) {
+			s.add(yamlErrLine(err.Error()), LintError, "schema", "%v", err)
+			return nil, s.issues
+		}
+		// The rest of the spec still decoded
+		for _, msg := range typeErr.Errors {
+			s.add(yamlErrLine(msg), LintError, "schema", "%s", trimYAMLLine(msg))
+		}
+	}
+	s.spec = &spec
+
+	s.lintMetadata()
+	s.lintParams()
+	s.lintTemplates()
+	s.lintDependencies()
+	s.lintCompose()
+	s.lintHooks()
+	s.lintPermissions()
+	s.lintTests()
+	sort.SliceStable(s.issues, func(i, j int) bool { return s.issues[i].Line < s.issues[j].Line })
+	return s.spec, s.issues
+}
+
+func (s *specLinter) add(line int, severity, rule, format string, args ...interface{}) {
+	s.issues = append(s.issues, LintIssue{
+		File:     s.file,
+		Line:     line,
+		Severity: severity,
+		Rule:     rule,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// line returns the line of a field, such as line("hooks", "pre", 0, "shell"),
+// or of its nearest parent that is present
+func (s *specLinter) line(path ...interface{}) int {
+	return nodeLine(s.root, path...)
+}
+
+func (s *specLinter) lintMetadata() {
+	spec := s.spec
+	if err := validation.ValidateCommandName(spec.Name); err != nil {
+		s.add(s.line("name"), LintError, "name", "%v", err)
+	}
+	for i, alias := range spec.Aliases {
+		if err := validation.ValidateCommandName(alias); err != nil {
+			s.add(s.line("aliases", i), LintError, "aliases", "alias %q: %v", alias, err)
+		}
+		if alias == spec.Name {
+			s.add(s.line("aliases", i), LintWarning, "aliases", "alias %q repeats the command name", alias)
+		}
+	}
+
+	switch {
+	case spec.Version == "":
+		s.add(s.line("name"), LintWarning, "version", "no version; dependency constraints and upgrades need one")
+	default:
+		if _, err := ParseVersion(spec.Version); err != nil {
+			s.add(s.line("version"), LintWarning, "version", "version %q is not a semantic version", spec.Version)
+		}
+	}
+	if spec.Description == "" {
+		s.add(s.line("name"), LintWarning, "description", "no description")
+	}
+
+	if spec.Prompt.Template == "" && spec.Compose == nil {
+		s.add(s.line("prompt"), LintError, "prompt", "prompt.template is empty and there is no compose block")
+	}
+}
+
+// lintParams checks args, flags and inputs
+func (s *specLinter) lintParams() {
+	seen := make(map[string]string)
+	param := func(kind, name string, path ...interface{}) {
+		line := s.line(path...)
+		switch {
+		case name == "":
+			s.add(line, LintError, "params", "%s without a name", kind)
+			return
+		case templateBuiltins[name]:
+			s.add(line, LintWarning, "params", "%s %q hides the built-in template value .%s", kind, name, name)
+		case !identPattern.MatchString(name):
+			s.add(line, LintWarning, "params", "%s %q can't be written {{.%s}}; use {{index . %q}}", kind, name, name, name)
+		}
+		if prev, ok := seen[name]; ok {
+			s.add(line, LintError, "params", "%s %q is also declared as %s", kind, name, prev)
+		}
+		seen[name] = kind
+	}
+
+	for i, a := range s.spec.Args {
+		param("arg", a.Name, "args", i, "name")
+	}
+	for i, f := range s.spec.Flags {
+		param("flag", f.Name, "flags", i, "name")
+	}
+	for i, in := range s.spec.Inputs {
+		param("input", in.Name, "inputs", i, "name")
+		line := s.line("inputs", i)
+		switch in.inputType() {
+		case InputTypeString, InputTypeFile, InputTypeMultiline:
+			if len(in.Choices) > 0 {
+				s.add(s.line("inputs", i, "choices"), LintWarning, "inputs", "input %q has choices but is not a choice", in.Name)
+			}
+		case InputTypeChoice:
+			if len(in.Choices) == 0 {
+				s.add(line, LintError, "inputs", "input %q is a choice but has no choices", in.Name)
+			} else if in.Default != "" && !slices.Contains(in.Choices, in.Default) {
+				s.add(s.line("inputs", i, "default"), LintError, "inputs", "default %q of input %q is not one of its choices", in.Default, in.Name)
+			}
+		default:
+			s.add(s.line("inputs", i, "type"), LintError, "inputs", "input %q has unknown type %q (use string, file, choice or multiline)", in.Name, in.Type)
+		}
+	}
+}
+
+// lintTemplates parses the templates and checks the values they use
+func (s *specLinter) lintTemplates() {
+	declared := make(map[string]bool)
+	inputs := make(map[string]bool)
+	for _, a := range s.spec.Args {
+		declared[a.Name] = true
+	}
+	for _, f := range s.spec.Flags {
+		declared[f.Name] = true
+	}
+	for _, in := range s.spec.Inputs {
+		declared[in.Name] = true
+		inputs[in.Name] = true
+	}
+	hookIDs := s.hookIDs()
+
+	check := func(name, text string, path ...interface{}) {
+		if text == "" {
+			return
+		}
+		line := s.line(path...)
+		field := strings.Join(pathNames(path), ".")
+		tmpl, err := template.New(name).Parse(text)
+		if err != nil {
+			s.add(templateLine(s.root, line, err.Error(), path...), LintError, "template", "%s: %s", field,
+				strings.TrimPrefix(err.Error(), "template: "))
+			return
+		}
+		for _, ref := range templateRefs(tmpl.Tree.Root, true) {
+			loc, _ := tmpl.Tree.ErrorContext(ref.node)
+			at := templateLine(s.root, line, loc, path...)
+			head := ref.ident[0]
+			switch {
+			case templateMaps[head] && declared[head]:
+				// The declared value replaces the built-in map
+				if len(ref.ident) > 1 {
+					s.add(at, LintError, "undeclared", "%s uses .%s, but %s is a declared arg, flag or input, which hides the built-in .%s", field,
+						strings.Join(ref.ident, "."), head, head)
+				}
+			case head == "hooks" && len(ref.ident) > 1 && !hookIDs[ref.ident[1]]:
+				s.add(at, LintError, "undeclared", "%s uses .hooks.%s, but there is no hook with that id", field, ref.ident[1])
+			case (head == "inputs" || head == "files") && len(ref.ident) > 1 && !inputs[ref.ident[1]]:
+				s.add(at, LintError, "undeclared", "%s uses .%s.%s, but %s is not an input", field, head, ref.ident[1], ref.ident[1])
+			case !templateBuiltins[head] && !declared[head]:
+				s.add(at, LintError, "undeclared", "%s uses .%s, which is not a declared arg, flag or input", field, head)
+			}
+		}
+	}
+
+	check("prompt", s.spec.Prompt.Template, "prompt", "template")
+	check("prompt", s.spec.Prompt.System, "prompt", "system")
+	if s.spec.Outputs != nil && s.spec.Outputs.Template != "" {
+		// Output templates render the parsed output, not the command's values
+		if _, err := template.New("output").Parse(s.spec.Outputs.Template); err != nil {
+			s.add(s.line("outputs", "template"), LintError, "template", "outputs.template: %s", strings.TrimPrefix(err.Error(), "template: "))
+		}
+	}
+}
+
+func (s *specLinter) hookIDs() map[string]bool {
+	ids := make(map[string]bool)
+	if s.spec.Hooks != nil {
+		for i, h := range s.spec.Hooks.Pre {
+			ids[hookID(HookStagePre, i, h)] = true
+		}
+		for i, h := range s.spec.Hooks.Post {
+			ids[hookID(HookStagePost, i, h)] = true
+		}
+	}
+	return ids
+}
+
+func (s *specLinter) lintDependencies() {
+	for i, d := range s.spec.Dependencies {
+		line := s.line("dependencies", i)
+		repo, name, qualified := strings.Cut(d.Command, "/")
+		if !qualified {
+			name = repo
+		}
+		if err := validation.ValidateCommandName(name); err != nil {
+			s.add(line, LintError, "dependencies", "dependency %q: %v", d.Command, err)
+			continue
+		}
+		if _, err := ParseConstraint(d.Version); err != nil {
+			s.add(s.line("dependencies", i, "version"), LintError, "dependencies", "%v", err)
+		}
+		if name == s.spec.Name && (!qualified || repo == "") {
+			s.add(line, LintError, "cycle", "%s depends on itself", s.spec.Name)
+		}
+	}
+}
+
+func (s *specLinter) lintCompose() {
+	c := s.spec.Compose
+	if c == nil {
+		return
+	}
+	kinds := 0
+	for _, n := range []int{len(c.Steps), len(c.Pipeline), len(c.Parallel), len(c.Fallback)} {
+		if n > 0 {
+			kinds++
+		}
+	}
+	switch {
+	case kinds == 0:
+		s.add(s.line("compose"), LintError, "compose", "compose block has no steps, pipeline, parallel or fallback commands")
+	case kinds > 1:
+		s.add(s.line("compose"), LintWarning, "compose", "compose block has more than one of steps, pipeline, parallel and fallback; only the first is used")
+	}
+	if c.Output != "" && len(c.Steps) == 0 {
+		s.add(s.line("compose", "output"), LintWarning, "compose", "compose output is only used with steps")
+	}
+
+	ref := func(name string, path ...interface{}) {
+		line := s.line(path...)
+		switch {
+		case name == "":
+			s.add(line, LintError, "compose", "compose step without a command")
+		case name == s.spec.Name:
+			s.add(line, LintError, "cycle", "%s composes itself", name)
+		case s.known != nil && !s.known(name):
+			s.add(line, LintError, "compose", "unknown command %q", name)
+		}
+	}
+	for i, step := range c.Pipeline {
+		ref(step.Command, "compose", "pipeline", i, "command")
+		s.lintTransform(step.Transform, "compose", "pipeline", i, "transform")
+		switch step.OnError {
+		case "", "continue", "stop", "fallback":
+		default:
+			s.add(s.line("compose", "pipeline", i, "on_error"), LintError, "compose", "unknown on_error %q (use continue, stop or fallback)", step.OnError)
+		}
+	}
+	s.lintSteps(ref)
+	for i, b := range c.Parallel {
+		if b.Command == "" || len(b.Args) > 0 || b.Timeout != "" {
+			ref(b.Command, "compose", "parallel", i, "command")
+		} else {
+			ref(b.Command, "compose", "parallel", i)
+		}
+		s.lintTimeout(b.Timeout, "compose", "parallel", i, "timeout")
+	}
+	s.lintParallel()
+	for i, name := range c.Fallback {
+		ref(name, "compose", "fallback", i)
+	}
+}
+
+// lintParallel checks the options of parallel composition
+func (s *specLinter) lintParallel() {
+	c := s.spec.Compose
+	if len(c.Parallel) == 0 {
+		for _, opt := range []struct {
+			name string
+			set  bool
+		}{
+			{"max_concurrency", c.MaxConcurrency != 0},
+			{"timeout", c.Timeout != ""},
+			{"mode", c.Mode != ""},
+			{"merge", c.Merge != ""},
+			{"synthesize", c.Synthesize != nil},
+		} {
+			if opt.set {
+				s.add(s.line("compose", opt.name), LintWarning, "compose", "compose %s is only used with parallel", opt.name)
+			}
+		}
+		return
+	}
+
+	if c.MaxConcurrency < 0 {
+		s.add(s.line("compose", "max_concurrency"), LintError, "compose", "max_concurrency must not be negative")
+	}
+	s.lintTimeout(c.Timeout, "compose", "timeout")
+	switch c.Mode {
+	case "", ParallelBestEffort, ParallelFailFast:
+	default:
+		s.add(s.line("compose", "mode"), LintError, "compose", "unknown mode %q (use best_effort or fail_fast)", c.Mode)
+	}
+	switch c.Merge {
+	case "", MergeConcat, MergeJSON, MergeFirst, MergeSynthesize:
+	default:
+		s.add(s.line("compose", "merge"), LintError, "compose", "unknown merge %q (use concat, json, first or synthesize)", c.Merge)
+	}
+	if c.Synthesize != nil {
+		if c.Merge != MergeSynthesize {
+			s.add(s.line("compose", "synthesize"), LintWarning, "compose", "compose synthesize is only used with merge: synthesize")
+		}
+		s.lintSynthesizeTemplate(c.Synthesize.Prompt, "compose", "synthesize", "prompt")
+		s.lintSynthesizeTemplate(c.Synthesize.System, "compose", "synthesize", "system")
+	}
+}
+
+func (s *specLinter) lintSynthesizeTemplate(text string, path ...interface{}) {
+	if text == "" {
+		return
+	}
+	if _, err := template.New("synthesize").Parse(text); err != nil {
+		s.add(templateLine(s.root, s.line(path...), err.Error(), path...), LintError, "template", "%s: %s",
+			strings.Join(pathNames(path), "."), strings.TrimPrefix(err.Error(), "template: "))
+	}
+}
+
+func (s *specLinter) lintTimeout(timeout string, path ...interface{}) {
+	if _, err := parseTimeout(timeout); err != nil {
+		s.add(s.line(path...), LintError, "compose", "%v", err)
+	}
+}
+
+func (s *specLinter) lintTransform(expr string, path ...interface{}) {
+	if expr == "" {
+		return
+	}
+	if _, err := CompileTransform(expr); err != nil {
+		s.add(s.line(path...), LintError, "transform", "%v", err)
+	}
+}
+
+// stepRefPattern finds the steps a condition refers to
+var stepRefPattern = regexp.MustCompile(`\bsteps\.([A-Za-z_][A-Za-z0-9_]*)`)
+
+// lintSteps checks a workflow's ids and needs, and that steps only refer to
+// steps they need
+func (s *specLinter) lintSteps(ref func(name string, path ...interface{})) {
+	steps := s.spec.Compose.Steps
+	ids := make(map[string]bool)
+	for i, step := range steps {
+		ref(step.Command, "compose", "steps", i, "command")
+		s.lintTransform(step.Transform, "compose", "steps", i, "transform")
+		switch {
+		case step.ID == "":
+			s.add(s.line("compose", "steps", i), LintError, "compose", "workflow step without an id")
+		case !identPattern.MatchString(step.ID):
+			s.add(s.line("compose", "steps", i, "id"), LintError, "compose", "invalid step id %q: use letters, digits and underscores", step.ID)
+		case ids[step.ID]:
+			s.add(s.line("compose", "steps", i, "id"), LintError, "compose", "step id %q is used twice", step.ID)
+		}
+		ids[step.ID] = true
+		switch step.OnError {
+		case "", "continue", "stop":
+		default:
+			s.add(s.line("compose", "steps", i, "on_error"), LintError, "compose", "unknown on_error %q (use continue or stop)", step.OnError)
+		}
+	}
+
+	for i, step := range steps {
+		for j, need := range step.Needs {
+			switch {
+			case need == step.ID:
+				s.add(s.line("compose", "steps", i, "needs", j), LintError, "cycle", "step %s needs itself", step.ID)
+			case !ids[need]:
+				s.add(s.line("compose", "steps", i, "needs", j), LintError, "compose", "step %s needs unknown step %q", step.ID, need)
+			}
+		}
+
+		// A step only sees the results of the steps it needs
+		needed := stepsNeeded(steps, step.ID)
+		check := func(refs []string, path ...interface{}) {
+			for _, id := range refs {
+				switch {
+				case !ids[id]:
+					s.add(s.line(path...), LintError, "compose", "step %s refers to unknown step %q", step.ID, id)
+				case !needed[id]:
+					s.add(s.line(path...), LintError, "compose", "step %s uses steps.%s but doesn't need it", step.ID, id)
+				}
+			}
+		}
+
+		if step.If != "" {
+			if _, err := tokenizeCondition(step.If); err != nil {
+				s.add(s.line("compose", "steps", i, "if"), LintError, "condition", "step %s: condition %q: %v", step.ID, step.If, err)
+			}
+			var refs []string
+			for _, m := range stepRefPattern.FindAllStringSubmatch(step.If, -1) {
+				refs = append(refs, m[1])
+			}
+			check(refs, "compose", "steps", i, "if")
+		}
+		check(s.stepRefs(step.Stdin, "compose", "steps", i, "stdin"), "compose", "steps", i, "stdin")
+		for name, arg := range step.Args {
+			check(s.stepRefs(arg, "compose", "steps", i, "args", name), "compose", "steps", i, "args", name)
+		}
+	}
+
+	if cycle := workflowCycle(steps); len(cycle) > 2 {
+		s.add(s.line("compose", "steps"), LintError, "cycle", "steps form a cycle: %s", strings.Join(cycle, " → "))
+	}
+
+	for _, id := range s.stepRefs(s.spec.Compose.Output, "compose", "output") {
+		if !ids[id] {
+			s.add(s.line("compose", "output"), LintError, "compose", "compose output refers to unknown step %q", id)
+		}
+	}
+}
+
+// stepRefs parses a workflow template, reporting syntax errors, and returns
+// the step ids it uses as .steps.ID
+func (s *specLinter) stepRefs(text string, path ...interface{}) []string {
+	if text == "" {
+		return nil
+	}
+	line := s.line(path...)
+	tmpl, err := template.New("step").Parse(text)
+	if err != nil {
+		s.add(templateLine(s.root, line, err.Error(), path...), LintError, "template", "%s: %s",
+			strings.Join(pathNames(path), "."), strings.TrimPrefix(err.Error(), "template: "))
+		return nil
+	}
+	var ids []string
+	for _, ref := range templateRefs(tmpl.Tree.Root, true) {
+		if len(ref.ident) > 1 && ref.ident[0] == "steps" {
+			ids = append(ids, ref.ident[1])
+		}
+	}
+	return ids
+}
+
+func (s *specLinter) lintHooks() {
+	if s.spec.Hooks == nil {
+		return
+	}
+
+	// Conditions are checked the way they run, with every value empty
+	tmplCtx := make(map[string]interface{})
+	for _, a := range s.spec.Args {
+		tmplCtx[a.Name] = ""
+	}
+	for _, f := range s.spec.Flags {
+		tmplCtx[f.Name] = ""
+	}
+	for _, in := range s.spec.Inputs {
+		tmplCtx[in.Name] = ""
+	}
+	runner := (&PluginCommand{spec: s.spec}).newHookRunner(context.Background(), nil, tmplCtx)
+	runner.git = map[string]string{"repo": "", "dirty": "", "clean": "", "staged": "", "branch": ""}
+
+	ids := make(map[string]bool)
+	for _, stage := range []string{HookStagePre, HookStagePost} {
+		hooks := s.spec.Hooks.Pre
+		if stage == HookStagePost {
+			hooks = s.spec.Hooks.Post
+		}
+		for i, h := range hooks {
+			id := hookID(stage, i, h)
+			line := s.line("hooks", stage, i)
+			if ids[id] {
+				s.add(line, LintError, "hooks", "hook id %q is used twice", id)
+			}
+			ids[id] = true
+
+			switch {
+			case h.Shell != "" && h.Command != "":
+				s.add(line, LintError, "hooks", "hook %s has both shell and command", id)
+			case h.Shell == "" && h.Command == "":
+				s.add(line, LintError, "hooks", "hook %s has neither shell nor command", id)
+			case h.Shell != "":
+				s.lintShellHook(id, h.Shell, s.line("hooks", stage, i, "shell"))
+			default:
+				line := s.line("hooks", stage, i, "command")
+				args := command.NewParser().Parse(strings.TrimPrefix(strings.TrimSpace(h.Command), "/"))
+				fields := args.Positional
+				switch {
+				case len(fields) == 0:
+					s.add(line, LintError, "hooks", "hook %s has an empty command", id)
+				case unattendedFlag(args) != "":
+					s.add(line, LintError, "hooks", "hook %s passes --%s, which hooks may not, as they run without confirmation", id, unattendedFlag(args))
+				case fields[0] == s.spec.Name:
+					s.add(line, LintError, "cycle", "hook %s runs %s itself", id, s.spec.Name)
+				case s.known != nil && !s.known(fields[0]):
+					s.add(line, LintError, "hooks", "hook %s runs unknown command %q", id, fields[0])
+				}
+			}
+
+			if h.If != "" {
+				if _, err := EvalCondition(h.If, runner.resolve); err != nil {
+					s.add(s.line("hooks", stage, i, "if"), LintError, "condition", "hook %s: condition %q: %v", id, h.If, err)
+				}
+			}
+		}
+	}
+}
+
+// lintPermissions checks the permissions block and that it allows the
+// command's hooks
+func (s *specLinter) lintPermissions() {
+	p := s.spec.Permissions
+	if p == nil {
+		return
+	}
+
+	for i, program := range p.Shell {
+		if program == "" || strings.ContainsAny(program, " \t") {
+			s.add(s.line("permissions", "shell", i), LintError, "permissions", "shell permission %q should be a program name, e.g. git", program)
+		}
+	}
+	for _, access := range []string{"read", "write"} {
+		globs := p.Filesystem.Read
+		if access == "write" {
+			globs = p.Filesystem.Write
+		}
+		for i, glob := range globs {
+			if err := tools.ValidGlob(glob); err != nil {
+				s.add(s.line("permissions", "filesystem", access, i), LintError, "permissions", "%v", err)
+			}
+		}
+	}
+	for i, host := range p.Network {
+		if host == "" || strings.ContainsAny(host, "/ ") {
+			s.add(s.line("permissions", "network", i), LintError, "permissions", "network permission %q should be a host name, e.g. api.github.com", host)
+		}
+	}
+
+	hasHooks := false
+	if s.spec.Hooks != nil {
+		for _, stage := range []string{HookStagePre, HookStagePost} {
+			hooks := s.spec.Hooks.Pre
+			if stage == HookStagePost {
+				hooks = s.spec.Hooks.Post
+			}
+			for i, h := range hooks {
+				key, what := "shell", "a shell command"
+				switch {
+				case h.Shell != "":
+				case h.Command != "":
+					key, what = "command", "a command"
+				default:
+					continue
+				}
+				hasHooks = true
+				if !p.Hooks {
+					s.add(s.line("hooks", stage, i, key), LintError, "permissions", "hook %s runs %s, which the permissions don't allow (set permissions.hooks)", hookID(stage, i, h), what)
+				}
+			}
+		}
+	}
+	if p.Hooks && !hasHooks {
+		s.add(s.line("permissions", "hooks"), LintWarning, "permissions", "permissions allow hooks, but the command has no hooks")
+	}
+}
+
+// lintTests checks the tests section
+func (s *specLinter) lintTests() {
+	params := make(map[string]bool)
+	for _, f := range s.spec.Flags {
+		params[f.Name] = true
+	}
+	for _, in := range s.spec.Inputs {
+		params[in.Name] = true
+	}
+
+	names := make(map[string]bool)
+	for i, t := range s.spec.Tests {
+		if t.Name != "" && names[t.Name] {
+			s.add(s.line("tests", i, "name"), LintWarning, "tests", "test name %q is used twice", t.Name)
+		}
+		names[t.Name] = true
+
+		if t.Response != "" && t.ResponseFile != "" {
+			s.add(s.line("tests", i), LintError, "tests", "test %d has both response and response_file", i+1)
+		}
+		for name := range t.Options {
+			if !params[name] {
+				s.add(s.line("tests", i, "options"), LintWarning, "tests", "test %d sets %q, which is not a flag or input", i+1, name)
+			}
+		}
+		for j, pattern := range t.Expect.Regex {
+			if _, err := regexp.Compile(pattern); err != nil {
+				s.add(s.line("tests", i, "expect", "regex", j), LintError, "tests", "test %d: %v", i+1, err)
+			}
+		}
+	}
+}
+
+// lintShellHook flags shell hooks that match destructive command patterns
+func (s *specLinter) lintShellHook(id, script string, line int) {
+	result := preview.Detect(script)
+	if !result.IsDestructive {
+		return
+	}
+	severity := LintWarning
+	if result.HighestSeverity >= preview.SeverityHigh {
+		severity = LintError
+	}
+	for _, m := range result.Matches {
+		if m.Pattern.Severity == result.HighestSeverity {
+			s.add(line, severity, "dangerous-hook", "hook %s runs %q: %s (%s risk)", id, m.MatchedText, m.Pattern.Description, result.HighestSeverity)
+			return
+		}
+	}
+}
+
+// LintManifest checks a repository's manifest and every command file it
+// lists. Compose steps and hooks may also use the repository's own
+// commands.
+func (l *Linter) LintManifest(dir string) []LintIssue {
+	file := filepath.Join(dir, ManifestFile)
+	data, err := readRepoFile(dir, ManifestFile)
+	if err != nil {
+		return []LintIssue{{File: file, Severity: LintError, Rule: "manifest", Message: err.Error()}}
+	}
+
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return []LintIssue{{File: file, Line: yamlErrLine(err.Error()), Severity: LintError, Rule: "yaml", Message: strings.TrimPrefix(err.Error(), "yaml: ")}}
+	}
+	var issues []LintIssue
+	add := func(line int, severity, rule, format string, args ...interface{}) {
+		issues = append(issues, LintIssue{File: file, Line: line, Severity: severity, Rule: rule, Message: fmt.Sprintf(format, args...)})
+	}
+
+	var manifest Manifest
+	if err := decodeStrict(data, &manifest); err != nil {
+		var typeErr *yaml.TypeError
+		if !errors.As(err, &typeErr) {
+			add(yamlErrLine(err.Error()), LintError, "schema", "%v", err)
+			return issues
+		}
+		for _, msg := range typeErr.Errors {
+			add(yamlErrLine(msg), LintError, "schema", "%s", trimYAMLLine(msg))
+		}
+	}
+	if manifest.Name == "" {
+		add(nodeLine(&root), LintWarning, "manifest", "manifest has no name")
+	}
+	if len(manifest.Commands) == 0 {
+		add(nodeLine(&root, "commands"), LintWarning, "manifest", "manifest lists no commands")
+	}
+
+	// The repository's own commands, for compose steps and hooks
+	names := make(map[string]bool)
+	for _, c := range manifest.Commands {
+		names[c.Name] = true
+		for _, a := range c.Aliases {
+			names[a] = true
+		}
+	}
+	known := func(name string) bool {
+		return names[name] || l.Known != nil && l.Known(name)
+	}
+	if l.Known == nil {
+		known = nil
+	}
+
+	seen := make(map[string]bool)
+	specs := make(map[string]*CommandSpec)
+	for i, c := range manifest.Commands {
+		line := nodeLine(&root, "commands", i)
+		if err := validation.ValidateCommandName(c.Name); err != nil {
+			add(nodeLine(&root, "commands", i, "name"), LintError, "name", "%v", err)
+			continue
+		}
+		if seen[c.Name] {
+			add(line, LintError, "manifest", "command %q is listed twice", c.Name)
+			continue
+		}
+		seen[c.Name] = true
+		if err := validation.ValidateAliases(c.Aliases); err != nil {
+			add(nodeLine(&root, "commands", i, "aliases"), LintError, "aliases", "%v", err)
+		}
+		if c.Description == "" {
+			add(line, LintWarning, "description", "command %q has no description", c.Name)
+		}
+
+		files := []CommandVersion{{Version: c.Version, File: c.File}}
+		files = append(files, c.Versions...)
+		linted := make(map[string]bool)
+		for j, v := range files {
+			fieldLine := nodeLine(&root, "commands", i, "file")
+			if j > 0 {
+				fieldLine = nodeLine(&root, "commands", i, "versions", j-1, "file")
+			}
+			if v.File == "" {
+				add(fieldLine, LintError, "manifest", "command %q has no file", c.Name)
+				continue
+			}
+			if linted[v.File] {
+				continue
+			}
+			linted[v.File] = true
+
+			spec, specIssues, err := l.lintRepoFile(dir, v.File, known)
+			if err != nil {
+				add(fieldLine, LintError, "file", "command %q: %v", c.Name, err)
+				continue
+			}
+			issues = append(issues, specIssues...)
+			if spec == nil {
+				continue
+			}
+			if spec.Name != c.Name {
+				add(fieldLine, LintError, "manifest", "%s holds command %q, not %q", v.File, spec.Name, c.Name)
+			}
+			if v.Version != "" && spec.Version != v.Version {
+				add(fieldLine, LintError, "manifest", "%s is version %q, but the manifest says %q", v.File, spec.Version, v.Version)
+			}
+			if j == 0 {
+				specs[c.Name] = spec
+			}
+		}
+	}
+
+	for _, cycle := range findSpecCycles(manifest.Name, specs) {
+		add(nodeLine(&root, "commands"), LintError, "cycle", "cycle: %s", strings.Join(cycle, " -> "))
+	}
+	return issues
+}
+
+// lintRepoFile lints a command file of a repository
+func (l *Linter) lintRepoFile(dir, path string, known func(string) bool) (*CommandSpec, []LintIssue, error) {
+	data, err := readRepoFile(dir, path)
+	if err != nil {
+		return nil, nil, err
+	}
+	spec, issues := l.lintSpec(filepath.Join(dir, filepath.FromSlash(path)), data, known)
+	return spec, issues, nil
+}
+
+// findSpecCycles finds cycles among a repository's commands through their
+// dependencies, compose steps and command hooks
+func findSpecCycles(repo string, specs map[string]*CommandSpec) [][]string {
+	edges := make(map[string][]string)
+	for name, spec := range specs {
+		var out []string
+		for _, d := range spec.Dependencies {
+			r, n, qualified := strings.Cut(d.Command, "/")
+			if !qualified {
+				out = append(out, r)
+			} else if r == repo {
+				out = append(out, n)
+			}
+		}
+		if c := spec.Compose; c != nil {
+			for _, step := range c.Steps {
+				out = append(out, step.Command)
+			}
+			for _, step := range c.Pipeline {
+				out = append(out, step.Command)
+			}
+			for _, b := range c.Parallel {
+				out = append(out, b.Command)
+			}
+			out = append(out, c.Fallback...)
+		}
+		if h := spec.Hooks; h != nil {
+			for _, hook := range append(append([]HookAction(nil), h.Pre...), h.Post...) {
+				if fields := strings.Fields(strings.TrimPrefix(strings.TrimSpace(hook.Command), "/")); len(fields) > 0 {
+					out = append(out, fields[0])
+				}
+			}
+		}
+		edges[name] = out
+	}
+
+	names := make([]string, 0, len(specs))
+	for name := range specs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// Depth-first search; each cycle is reported once, from its first
+	// command in name order
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make(map[string]int)
+	var stack []string
+	var cycles [][]string
+	var visit func(name string)
+	visit = func(name string) {
+		state[name] = visiting
+		stack = append(stack, name)
+		for _, next := range edges[name] {
+			if _, ok := specs[next]; !ok || next == name {
+				// Self-references are reported with the command
+				continue
+			}
+			switch state[next] {
+			case unvisited:
+				visit(next)
+			case visiting:
+				for i, n := range stack {
+					if n == next {
+						cycle := append(append([]string(nil), stack[i:]...), next)
+						cycles = append(cycles, cycle)
+						break
+					}
+				}
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[name] = done
+	}
+	for _, name := range names {
+		if state[name] == unvisited {
+			visit(name)
+		}
+	}
+	return cycles
+}
+
+// decodeStrict decodes YAML, rejecting fields the type doesn't have
+func decodeStrict(data []byte, v interface{}) error {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	return dec.Decode(v)
+}
+
+var yamlLinePattern = regexp.MustCompile(`line (\d+):`)
+
+// yamlErrLine finds the line in a YAML error message
+func yamlErrLine(msg string) int {
+	if m := yamlLinePattern.FindStringSubmatch(msg); m != nil {
+		n, _ := strconv.Atoi(m[1])
+		return n
+	}
+	return 0
+}
+
+var yamlUnknownField = regexp.MustCompile(`^field (\S+) not found in type \S+$`)
+
+// trimYAMLLine removes the "line N: " prefix of a YAML type error
+func trimYAMLLine(msg string) string {
+	if loc := yamlLinePattern.FindStringIndex(msg); loc != nil && loc[0] == 0 {
+		msg = strings.TrimSpace(msg[loc[1]:])
+	}
+	if m := yamlUnknownField.FindStringSubmatch(msg); m != nil {
+		return "unknown field " + m[1]
+	}
+	return msg
+}
+
+// nodeLine returns the line of the node at path, following mapping keys
+// (strings) and sequence indexes (ints) as far as they exist
+func nodeLine(root *yaml.Node, path ...interface{}) int {
+	if root == nil {
+		return 0
+	}
+	n := root
+	if n.Kind == yaml.DocumentNode && len(n.Content) > 0 {
+		n = n.Content[0]
+	}
+	line := n.Line
+	for _, p := range path {
+		var next *yaml.Node
+		switch key := p.(type) {
+		case string:
+			if n.Kind != yaml.MappingNode {
+				return line
+			}
+			for i := 0; i+1 < len(n.Content); i += 2 {
+				if n.Content[i].Value == key {
+					line = n.Content[i].Line
+					next = n.Content[i+1]
+					break
+				}
+			}
+		case int:
+			if n.Kind != yaml.SequenceNode || key >= len(n.Content) {
+				return line
+			}
+			next = n.Content[key]
+			line = next.Line
+		}
+		if next == nil {
+			return line
+		}
+		n = next
+	}
+	return line
+}
+
+// templateLine turns a line within a template into a line of the file. loc
+// is a text/template error or ErrorContext location ("name:line:col").
+func templateLine(root *yaml.Node, fieldLine int, loc string, path ...interface{}) int {
+	var tline int
+	if m := templateErrLine.FindStringSubmatch(loc); m != nil {
+		tline, _ = strconv.Atoi(m[1])
+	} else if parts := strings.Split(loc, ":"); len(parts) >= 2 {
+		tline, _ = strconv.Atoi(parts[1])
+	}
+	if tline == 0 {
+		return fieldLine
+	}
+
+	// Block scalars start on the line after the key
+	value := nodeValue(root, path...)
+	if value == nil {
+		return fieldLine
+	}
+	line := value.Line + tline - 1
+	if value.Style&(yaml.LiteralStyle|yaml.FoldedStyle) != 0 {
+		line++
+	}
+	return line
+}
+
+// nodeValue returns the value node at path, or nil
+func nodeValue(root *yaml.Node, path ...interface{}) *yaml.Node {
+	n := root
+	if n.Kind == yaml.DocumentNode && len(n.Content) > 0 {
+		n = n.Content[0]
+	}
+	for _, p := range path {
+		key, ok := p.(string)
+		if !ok || n.Kind != yaml.MappingNode {
+			return nil
+		}
+		var next *yaml.Node
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			if n.Content[i].Value == key {
+				next = n.Content[i+1]
+				break
+			}
+		}
+		if next == nil {
+			return nil
+		}
+		n = next
+	}
+	return n
+}
+
+// pathNames returns the field names in a node path
+func pathNames(path []interface{}) []string {
+	var out []string
+	for _, p := range path {
+		if s, ok := p.(string); ok {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// templateRef is a value a template reads from the top-level context
+type templateRef struct {
+	node  parse.Node
+	ident []string
+}
+
+// templateRefs finds the fields read from the template's top-level
+// context: {{.name}} where dot is the top level, and {{$.name}} anywhere
+func templateRefs(node parse.Node, atRoot bool) []templateRef {
+	var refs []templateRef
+	var walk func(n parse.Node, atRoot bool)
+	walk = func(n parse.Node, atRoot bool) {
+		switch n := n.(type) {
+		case *parse.ListNode:
+			if n == nil {
+				return
+			}
+			for _, c := range n.Nodes {
+				walk(c, atRoot)
+			}
+		case *parse.ActionNode:
+			walk(n.Pipe, atRoot)
+		case *parse.PipeNode:
+			if n == nil {
+				return
+			}
+			for _, c := range n.Cmds {
+				walk(c, atRoot)
+			}
+		case *parse.CommandNode:
+			for _, a := range n.Args {
+				walk(a, atRoot)
+			}
+		case *parse.ChainNode:
+			walk(n.Node, atRoot)
+		case *parse.FieldNode:
+			if atRoot {
+				refs = append(refs, templateRef{node: n, ident: n.Ident})
+			}
+		case *parse.VariableNode:
+			if len(n.Ident) > 1 && n.Ident[0] == "$" {
+				refs = append(refs, templateRef{node: n, ident: n.Ident[1:]})
+			}
+		case *parse.IfNode:
+			walk(n.Pipe, atRoot)
+			walk(n.List, atRoot)
+			walk(n.ElseList, atRoot)
+		case *parse.RangeNode:
+			// Dot is each element inside range
+			walk(n.Pipe, atRoot)
+			walk(n.List, false)
+			walk(n.ElseList, atRoot)
+		case *parse.WithNode:
+			walk(n.Pipe, atRoot)
+			walk(n.List, false)
+			walk(n.ElseList, atRoot)
+		case *parse.TemplateNode:
+			walk(n.Pipe, atRoot)
+		}
+	}
+	walk(node, atRoot)
+	return refs
+}
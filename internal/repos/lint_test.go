@@ -0,0 +1,365 @@
+package repos
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLinter_LintSpec(t *testing.T) {
+	known := func(name string) bool { return name == "explain" || name == "summarize" }
+	l := &Linter{Known: known}
+
+	t.Run("clean", func(t *testing.T) {
+		issues := l.LintSpec("ok.yaml", []byte(`name: review
+version: 1.0.0
+description: Review a file
+args:
+  - name: file
+inputs:
+  - name: focus
+    type: choice
+    choices: [bugs, style]
+    default: bugs
+prompt:
+  system: You review {{.focus}} issues.
+  template: |
+    Review {{.file}} ({{.inputs.focus}}):
+    {{range .args}}{{.Name}}{{end}}
+    {{with .stdin}}{{.}}{{end}}
+    {{if .hooks.status.ok}}{{$.file}}{{end}}
+hooks:
+  pre:
+    - id: status
+      shell: git status --short
+      if: git.repo && inputs.focus == "bugs"
+  post:
+    - command: summarize
+compose:
+  pipeline:
+    - command: explain
+      on_error: continue
+`))
+		assert.Empty(t, issues)
+	})
+
+	t.Run("problems", func(t *testing.T) {
+		issues := l.LintSpec("bad.yaml", []byte(`name: bad/name
+colour: red
+args:
+  - name: file
+flags:
+  - name: file
+inputs:
+  - name: mode
+    type: dropdown
+prompt:
+  template: |
+    {{.file}}
+    {{.missing}} {{.inputs.nope}}
+    {{if}}
+dependencies:
+  - command: other
+    version: ">>1"
+compose:
+  parallel: [nope, bad/name]
+hooks:
+  pre:
+    - shell: rm -rf /
+    - command: explain
+      if: git.colour
+`))
+		for _, i := range issues {
+			assert.Equal(t, "bad.yaml", i.File)
+		}
+		messages := make(map[string]string)
+		for _, i := range issues {
+			messages[i.Rule] += i.Message + "\n"
+		}
+
+		assert.Contains(t, messages["name"], "invalid command name")
+		assert.Contains(t, messages["schema"], "unknown field colour")
+		assert.Contains(t, messages["params"], `flag "file" is also declared as arg`)
+		assert.Contains(t, messages["inputs"], `unknown type "dropdown"`)
+		assert.Contains(t, messages["template"], "prompt.template")
+		assert.Contains(t, messages["dependencies"], `invalid constraint ">>1"`)
+		assert.Contains(t, messages["compose"], `unknown command "nope"`)
+		assert.Contains(t, messages["dangerous-hook"], `hook pre1 runs "rm -rf"`)
+		assert.Contains(t, messages["condition"], `unknown git property "colour"`)
+		assert.Contains(t, messages["version"], "no version")
+	})
+
+	t.Run("undeclared values", func(t *testing.T) {
+		issues := l.LintSpec("vars.yaml", []byte(`name: vars
+version: 1.0.0
+description: Uses undeclared values
+inputs:
+  - name: lang
+prompt:
+  template: |
+    {{.lang}} {{.inputs.lang}}
+    {{.missing}}
+    {{range .args}}{{$.other}}{{.ignored}}{{end}}
+    {{.hooks.nope.output}}
+`))
+		require.Len(t, issues, 3)
+		assert.Equal(t, 9, issues[0].Line)
+		assert.Contains(t, issues[0].Message, "uses .missing")
+		assert.Equal(t, 10, issues[1].Line)
+		assert.Contains(t, issues[1].Message, "uses .other")
+		assert.Equal(t, 11, issues[2].Line)
+		assert.Contains(t, issues[2].Message, "no hook with that id")
+	})
+
+	t.Run("args named like built-ins", func(t *testing.T) {
+		issues := l.LintSpec("shadow.yaml", []byte(`name: shadow
+version: 1.0.0
+description: Declares an arg named files
+args:
+  - name: files
+prompt:
+  template: |
+    Delete: {{.files}}
+    {{.files.path}}
+`))
+		require.Len(t, issues, 2)
+		assert.Equal(t, LintWarning, issues[0].Severity)
+		assert.Contains(t, issues[0].Message, `arg "files" hides the built-in template value .files`)
+		assert.Equal(t, 9, issues[1].Line)
+		assert.Contains(t, issues[1].Message, "uses .files.path, but files is a declared arg")
+	})
+
+	t.Run("cycles", func(t *testing.T) {
+		issues := l.LintSpec("self.yaml", []byte(`name: self
+version: 1.0.0
+description: Depends on itself
+prompt:
+  template: hi
+dependencies:
+  - command: self
+compose:
+  fallback: [self]
+`))
+		require.Len(t, issues, 2)
+		assert.Equal(t, "cycle", issues[0].Rule)
+		assert.Equal(t, "cycle", issues[1].Rule)
+	})
+
+	t.Run("workflow steps", func(t *testing.T) {
+		issues := (&Linter{}).LintSpec("flow.yaml", []byte(`name: flow
+version: 1.0.0
+description: A workflow
+compose:
+  steps:
+    - id: scan
+      command: scan
+      needs: [report]
+    - id: triage
+      command: triage
+      needs: [missing]
+      if: steps.report.ok
+    - id: report
+      command: report
+      needs: [scan]
+      args:
+        findings: "{{.steps.triage.output}}"
+  output: "{{.steps.nope.output}}"
+`))
+		require.Len(t, issues, 5)
+		assert.Contains(t, issues[0].Message, "steps form a cycle: scan → report → scan")
+		assert.Contains(t, issues[1].Message, `step triage needs unknown step "missing"`)
+		assert.Contains(t, issues[2].Message, "step triage uses steps.report but doesn't need it")
+		assert.Contains(t, issues[3].Message, "step report uses steps.triage but doesn't need it")
+		assert.Contains(t, issues[4].Message, `compose output refers to unknown step "nope"`)
+	})
+
+	t.Run("transforms", func(t *testing.T) {
+		issues := (&Linter{}).LintSpec("t.yaml", []byte(`name: t
+version: 1.0.0
+description: Transforms
+compose:
+  pipeline:
+    - command: a
+      transform: fromjson | .items[] | .title
+    - command: b
+      transform: "map(.x"
+`))
+		require.Len(t, issues, 1)
+		assert.Equal(t, 9, issues[0].Line)
+		assert.Equal(t, "transform", issues[0].Rule)
+		assert.Contains(t, issues[0].Message, `expected ")"`)
+	})
+
+	t.Run("parallel options", func(t *testing.T) {
+		issues := (&Linter{}).LintSpec("p.yaml", []byte(`name: p
+version: 1.0.0
+description: Parallel
+compose:
+  parallel:
+    - a
+    - command: b
+      timeout: soon
+  mode: fastest
+  merge: synthesize
+  synthesize:
+    prompt: "{{range .branches}}"
+`))
+		require.Len(t, issues, 3)
+		assert.Contains(t, issues[0].Message, `invalid timeout "soon"`)
+		assert.Contains(t, issues[1].Message, `unknown mode "fastest"`)
+		assert.Contains(t, issues[2].Message, "compose.synthesize.prompt")
+
+		issues = (&Linter{}).LintSpec("s.yaml", []byte(`name: s
+version: 1.0.0
+description: Pipeline
+compose:
+  pipeline:
+    - command: a
+  merge: json
+`))
+		require.Len(t, issues, 1)
+		assert.Equal(t, LintWarning, issues[0].Severity)
+		assert.Contains(t, issues[0].Message, "compose merge is only used with parallel")
+	})
+
+	t.Run("permissions", func(t *testing.T) {
+		issues := (&Linter{}).LintSpec("p.yaml", []byte(`name: p
+version: 1.0.0
+description: Permissions
+prompt:
+  template: hi
+permissions:
+  shell: [git, "rm -rf"]
+  filesystem:
+    read: ["src/[a"]
+  network: [https://api.github.com]
+hooks:
+  pre:
+    - shell: git fetch
+  post:
+    - command: cmd --run list files
+`))
+		require.Len(t, issues, 6)
+		assert.Contains(t, issues[0].Message, `shell permission "rm -rf" should be a program name`)
+		assert.Contains(t, issues[1].Message, `invalid path glob "src/[a"`)
+		assert.Contains(t, issues[2].Message, `network permission "https://api.github.com" should be a host name`)
+		assert.Equal(t, "hook pre1 runs a shell command, which the permissions don't allow (set permissions.hooks)", issues[3].Message)
+		assert.Equal(t, 13, issues[3].Line)
+		assert.Equal(t, "hook post1 passes --run, which hooks may not, as they run without confirmation", issues[4].Message)
+		assert.Equal(t, "hook post1 runs a command, which the permissions don't allow (set permissions.hooks)", issues[5].Message)
+		assert.Equal(t, 15, issues[5].Line)
+
+		issues = (&Linter{}).LintSpec("h.yaml", []byte(`name: h
+version: 1.0.0
+description: Hooks
+prompt:
+  template: hi
+permissions:
+  hooks: true
+`))
+		require.Len(t, issues, 1)
+		assert.Equal(t, LintWarning, issues[0].Severity)
+	})
+
+	t.Run("unknown commands unchecked without Known", func(t *testing.T) {
+		issues := (&Linter{}).LintSpec("c.yaml", []byte(`name: c
+version: 1.0.0
+description: Composes something
+compose:
+  pipeline:
+    - command: anything
+`))
+		assert.Empty(t, issues)
+	})
+
+	t.Run("tests", func(t *testing.T) {
+		issues := l.LintSpec("tests.yaml", []byte(`name: tested
+version: 1.0.0
+description: Has tests
+flags:
+  - name: format
+prompt:
+  template: "{{.format}}"
+tests:
+  - name: one
+    options: {format: json, colour: red}
+    response: a
+    response_file: a.txt
+  - name: one
+    expect:
+      regex: ["("]
+`))
+		require.Len(t, issues, 4)
+		assert.Contains(t, issues[0].Message, "has both response and response_file")
+		assert.Contains(t, issues[1].Message, `sets "colour", which is not a flag or input`)
+		assert.Contains(t, issues[2].Message, `test name "one" is used twice`)
+		assert.Equal(t, LintError, issues[3].Severity)
+		assert.Contains(t, issues[3].Message, "test 2:")
+	})
+
+	t.Run("invalid yaml", func(t *testing.T) {
+		issues := l.LintSpec("broken.yaml", []byte("name: [unclosed\n"))
+		require.Len(t, issues, 1)
+		assert.Equal(t, "yaml", issues[0].Rule)
+	})
+}
+
+func TestLinter_LintManifest(t *testing.T) {
+	dir := t.TempDir()
+	write := func(path, content string) {
+		full := filepath.Join(dir, path)
+		require.NoError(t, os.MkdirAll(filepath.Dir(full), 0755))
+		require.NoError(t, os.WriteFile(full, []byte(content), 0644))
+	}
+	spec := func(name, version, extra string) string {
+		return "name: " + name + "\nversion: " + version + "\ndescription: d\nprompt:\n  template: hi\n" + extra
+	}
+
+	write(ManifestFile, `name: team
+commands:
+  - name: a
+    description: d
+    file: commands/a.yaml
+  - name: b
+    description: d
+    file: commands/b.yaml
+    version: 2.0.0
+    versions:
+      - version: 1.0.0
+        file: commands/b-1.0.0.yaml
+  - name: c
+    description: d
+    file: commands/missing.yaml
+  - name: a
+    description: d
+    file: commands/a.yaml
+`)
+	write("commands/a.yaml", spec("a", "1.0.0", "dependencies:\n  - command: team/b\ncompose:\n  pipeline:\n    - command: explain\n"))
+	write("commands/b.yaml", spec("b", "2.0.0", "hooks:\n  pre:\n    - command: a --quick\n"))
+	write("commands/b-1.0.0.yaml", spec("b", "1.1.0", ""))
+
+	issues := (&Linter{Known: func(name string) bool { return name == "explain" }}).LintManifest(dir)
+
+	messages := make(map[string][]string)
+	for _, i := range issues {
+		messages[i.Rule] = append(messages[i.Rule], i.Message)
+	}
+	assert.Equal(t, []string{"cycle: a -> b -> a"}, messages["cycle"])
+	assert.Len(t, messages["file"], 1)
+	assert.Contains(t, messages["file"][0], `command "c"`)
+	assert.ElementsMatch(t, []string{
+		`command "a" is listed twice`,
+		`commands/b-1.0.0.yaml is version "1.1.0", but the manifest says "1.0.0"`,
+	}, messages["manifest"])
+	assert.Empty(t, messages["compose"])
+	assert.Empty(t, messages["hooks"])
+
+	t.Run("no manifest", func(t *testing.T) {
+		issues := (&Linter{}).LintManifest(t.TempDir())
+		require.Len(t, issues, 1)
+		assert.Equal(t, "manifest", issues[0].Rule)
+	})
+}
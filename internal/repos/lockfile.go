@@ -0,0 +1,287 @@
+package repos
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const hashPrefix = "sha256:"
+
+// ErrHashMismatch is returned when a command file does not match the hash
+// recorded in the lockfile
+var ErrHashMismatch = errors.New("hash mismatch")
+
+// ErrNotLocked is returned by frozen installs of commands that are not in
+// the lockfile
+var ErrNotLocked = errors.New("not in the lockfile")
+
+// ContentHash returns the lockfile hash of a command file
+func ContentHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hashPrefix + hex.EncodeToString(sum[:])
+}
+
+// checkHash compares a command file with a lockfile hash. Lockfiles from
+// older versions hold a truncated hash of the parsed spec instead.
+func checkHash(want string, data []byte) error {
+	if want == "" {
+		return fmt.Errorf("%w: no hash recorded; regenerate the lockfile with 'scmd lock generate'", ErrHashMismatch)
+	}
+
+	got := ContentHash(data)
+	if !strings.HasPrefix(want, hashPrefix) {
+		var spec CommandSpec
+		if err := yaml.Unmarshal(data, &spec); err != nil {
+			return fmt.Errorf("parse command: %w", err)
+		}
+		j, err := json.Marshal(&spec)
+		if err != nil {
+			return err
+		}
+		got = hashData(j)
+	}
+
+	if got != want {
+		return fmt.Errorf("%w: lockfile has %s, got %s", ErrHashMismatch, want, got)
+	}
+	return nil
+}
+
+// Find returns the locked entry for repo/name
+func (lf *Lockfile) Find(repo, name string) (*LockedCmd, bool) {
+	for i := range lf.Commands {
+		if lf.Commands[i].Repo == repo && lf.Commands[i].Name == name {
+			return &lf.Commands[i], true
+		}
+	}
+	return nil, false
+}
+
+// Closure returns the locked entry for repo/name with its locked
+// dependencies, transitively, each after its own dependencies
+func (lf *Lockfile) Closure(repo, name string) ([]*LockedCmd, error) {
+	var out []*LockedCmd
+	seen := make(map[string]bool)
+
+	var visit func(repo, name, from string) error
+	visit = func(repo, name, from string) error {
+		key := repo + "/" + name
+		if seen[key] {
+			return nil
+		}
+		seen[key] = true
+
+		lc, ok := lf.Find(repo, name)
+		if !ok {
+			if from != "" {
+				return fmt.Errorf("%s (a dependency of %s): %w", key, from, ErrNotLocked)
+			}
+			return fmt.Errorf("%s: %w", key, ErrNotLocked)
+		}
+		for _, dep := range lc.Dependencies {
+			depRepo, depName := splitKey(dep)
+			if err := visit(depRepo, depName, key); err != nil {
+				return err
+			}
+		}
+		out = append(out, lc)
+		return nil
+	}
+
+	if err := visit(repo, name, ""); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// RecordInstall marks a command as installed and records its source and
+// content hash for lockfiles
+func (c *Cache) RecordInstall(repo *Repository, file string, spec *CommandSpec, data []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := repo.Name + "/" + spec.Name
+	cached := c.manifest.Commands[key]
+	if !cached.InstalledAt.IsZero() && cached.Version != spec.Version {
+		cached.Previous = cached.Version
+	}
+	if cached.Sources == nil {
+		cached.Sources = make(map[string]string)
+	}
+	cached.Sources[spec.Version] = file
+	cached.Repo = repo.Name
+	cached.Version = spec.Version
+	cached.URL = repo.URL
+	cached.File = file
+	cached.ContentHash = ContentHash(data)
+	cached.InstalledAt = time.Now()
+	cached.UpdateAvail = false
+	cached.LatestVer = ""
+	c.manifest.Commands[key] = cached
+}
+
+// RecordDependencies records the resolved dependencies of a command, as
+// repo/name, for lockfiles
+func (c *Cache) RecordDependencies(repo, name string, deps []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := repo + "/" + name
+	cached := c.manifest.Commands[key]
+	cached.Dependencies = append([]string(nil), deps...)
+	c.manifest.Commands[key] = cached
+}
+
+// LockedSource is a locked command as fetched from its repository
+type LockedSource struct {
+	Repo *Repository
+	File string
+	Spec *CommandSpec
+	Data []byte
+}
+
+// FetchLocked fetches a locked command and checks it against the lockfile.
+// Frozen fetches only use what the lockfile records: the entry must name its
+// file, and the repository must not have moved.
+func (m *Manager) FetchLocked(ctx context.Context, lc *LockedCmd, frozen bool) (*LockedSource, error) {
+	repo, ok := m.Get(lc.Repo)
+	if !ok {
+		if lc.URL == "" {
+			return nil, fmt.Errorf("repo %s not found", lc.Repo)
+		}
+		if err := m.Add(lc.Repo, lc.URL); err != nil {
+			return nil, fmt.Errorf("add repo %s: %w", lc.Repo, err)
+		}
+		repo, _ = m.Get(lc.Repo)
+	} else if lc.URL != "" && strings.TrimRight(repo.URL, "/") != strings.TrimRight(lc.URL, "/") {
+		return nil, fmt.Errorf("repo %s is %s, but the lockfile was generated from %s", lc.Repo, repo.URL, lc.URL)
+	}
+
+	file := lc.File
+	if file == "" {
+		if frozen {
+			return nil, fmt.Errorf("%s/%s: lockfile does not record the command file; regenerate it with 'scmd lock generate'", lc.Repo, lc.Name)
+		}
+		manifest, err := m.FetchManifest(ctx, repo)
+		if err != nil {
+			return nil, fmt.Errorf("fetch manifest for %s: %w", lc.Repo, err)
+		}
+		for _, c := range manifest.Commands {
+			if c.Name == lc.Name {
+				file = c.File
+				break
+			}
+		}
+		if file == "" {
+			return nil, fmt.Errorf("command %s not found in %s", lc.Name, lc.Repo)
+		}
+	}
+
+	spec, data, err := m.FetchCommandData(ctx, repo, file)
+	if err != nil {
+		return nil, fmt.Errorf("fetch command %s: %w", lc.Name, err)
+	}
+
+	// Verify version matches if specified
+	if lc.Version != "" && spec.Version != lc.Version {
+		return nil, fmt.Errorf("version mismatch for %s: wanted %s, got %s",
+			lc.Name, lc.Version, spec.Version)
+	}
+	if err := checkHash(lc.Hash, data); err != nil {
+		return nil, fmt.Errorf("%s/%s: %w", lc.Repo, lc.Name, err)
+	}
+
+	return &LockedSource{Repo: repo, File: file, Spec: spec, Data: data}, nil
+}
+
+// InstallFromLockfile installs commands from a lockfile. Every command is
+// fetched and checked before any is installed, so a mismatch leaves the
+// installed commands untouched. Installs are recorded in cache, if given.
+func (m *Manager) InstallFromLockfile(ctx context.Context, lf *Lockfile, installDir string, frozen bool, cache *Cache) error {
+	sources, err := m.FetchLockfile(ctx, lf, frozen)
+	if err != nil {
+		return err
+	}
+	return m.InstallLocked(sources, installDir, cache)
+}
+
+// FetchLockfile fetches every command of a lockfile and checks it against
+// its recorded hash
+func (m *Manager) FetchLockfile(ctx context.Context, lf *Lockfile, frozen bool) ([]*LockedSource, error) {
+	var sources []*LockedSource
+	for i := range lf.Commands {
+		src, err := m.FetchLocked(ctx, &lf.Commands[i], frozen)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, src)
+	}
+	return sources, nil
+}
+
+// InstallLocked installs fetched lockfile commands and records them in
+// cache, if it is not nil
+func (m *Manager) InstallLocked(sources []*LockedSource, installDir string, cache *Cache) error {
+	if err := os.MkdirAll(installDir, 0755); err != nil {
+		return fmt.Errorf("create install dir: %w", err)
+	}
+	for _, src := range sources {
+		if err := m.InstallCommandData(src.Spec, src.Data, installDir); err != nil {
+			return fmt.Errorf("install %s: %w", src.Spec.Name, err)
+		}
+		if cache != nil {
+			cache.RecordInstall(src.Repo, src.File, src.Spec, src.Data)
+		}
+	}
+
+	return nil
+}
+
+// LockCheck is the result of verifying one lockfile entry
+type LockCheck struct {
+	Command   LockedCmd
+	Installed error // installed file differs or is missing
+	Remote    error // the repository serves something else; nil if not checked
+}
+
+// OK reports whether the entry verified
+func (c LockCheck) OK() bool {
+	return c.Installed == nil && c.Remote == nil
+}
+
+// VerifyLockfile checks installed commands against a lockfile and, if
+// remote is set, that the repositories still serve the locked content
+func (m *Manager) VerifyLockfile(ctx context.Context, lf *Lockfile, installDir string, remote bool) []LockCheck {
+	checks := make([]LockCheck, 0, len(lf.Commands))
+	for i := range lf.Commands {
+		lc := lf.Commands[i]
+		check := LockCheck{Command: lc}
+
+		data, err := os.ReadFile(filepath.Join(installDir, lc.Name+".yaml"))
+		if err != nil {
+			if os.IsNotExist(err) {
+				check.Installed = fmt.Errorf("not installed")
+			} else {
+				check.Installed = err
+			}
+		} else {
+			check.Installed = checkHash(lc.Hash, data)
+		}
+
+		if remote {
+			_, check.Remote = m.FetchLocked(ctx, &lc, true)
+		}
+		checks = append(checks, check)
+	}
+	return checks
+}
@@ -0,0 +1,146 @@
+package repos
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+const lockedCommand = "name: hello\nversion: 1.0.0\nprompt:\n  template: hi\n"
+
+// lockRepo serves a manifest and commands/hello.yaml with the given content
+func lockRepo(t *testing.T, command *string) *httptest.Server {
+	t.Setenv("SCMD_ALLOW_LOCALHOST", "1")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/scmd-repo.yaml":
+			w.Write([]byte("name: locked\ncommands:\n  - name: hello\n    file: commands/hello.yaml\n"))
+		case "/commands/hello.yaml":
+			w.Write([]byte(*command))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestCache_LockfileRecordsSources(t *testing.T) {
+	c := NewCache(t.TempDir())
+	repo := &Repository{Name: "team", URL: "https://example.com/repo"}
+	c.RecordInstall(repo, "commands/b.yaml", &CommandSpec{Name: "b", Version: "2.0.0"}, []byte("b"))
+	c.RecordInstall(repo, "commands/a.yaml", &CommandSpec{Name: "a", Version: "1.0.0"}, []byte("a"))
+
+	lf := c.GenerateLockfile()
+	require.Len(t, lf.Commands, 2)
+	assert.Equal(t, LockedCmd{
+		Name:    "a",
+		Repo:    "team",
+		Version: "1.0.0",
+		Hash:    "sha256:ca978112ca1bbdcafac231b39a23dc4da786eff8147c4e72b9807785afee48bb",
+		URL:     "https://example.com/repo",
+		File:    "commands/a.yaml",
+	}, lf.Commands[0])
+	assert.Equal(t, "b", lf.Commands[1].Name)
+}
+
+func TestManager_InstallFromLockfile(t *testing.T) {
+	command := lockedCommand
+	server := lockRepo(t, &command)
+	lock := func() *Lockfile {
+		return &Lockfile{Commands: []LockedCmd{{
+			Name: "hello", Repo: "locked", Version: "1.0.0",
+			Hash: ContentHash([]byte(lockedCommand)), URL: server.URL, File: "commands/hello.yaml",
+		}}}
+	}
+
+	t.Run("matching", func(t *testing.T) {
+		dir := t.TempDir()
+		m := NewManager(dir)
+		cache := NewCache(dir)
+		require.NoError(t, m.InstallFromLockfile(context.Background(), lock(), dir, true, cache))
+
+		data, err := os.ReadFile(filepath.Join(dir, "hello.yaml"))
+		require.NoError(t, err)
+		assert.Equal(t, lockedCommand, string(data))
+		repo, ok := m.Get("locked")
+		require.True(t, ok)
+		assert.Equal(t, server.URL, repo.URL)
+		assert.Equal(t, lock().Commands, cache.GenerateLockfile().Commands)
+
+		checks := m.VerifyLockfile(context.Background(), lock(), dir, true)
+		require.Len(t, checks, 1)
+		assert.True(t, checks[0].OK())
+	})
+
+	t.Run("changed upstream", func(t *testing.T) {
+		command = lockedCommand + "# changed\n"
+		defer func() { command = lockedCommand }()
+
+		dir := t.TempDir()
+		m := NewManager(dir)
+		err := m.InstallFromLockfile(context.Background(), lock(), dir, false, nil)
+		assert.ErrorIs(t, err, ErrHashMismatch)
+		assert.NoFileExists(t, filepath.Join(dir, "hello.yaml"))
+	})
+
+	t.Run("frozen needs file", func(t *testing.T) {
+		lf := lock()
+		lf.Commands[0].File = ""
+		m := NewManager(t.TempDir())
+		err := m.InstallFromLockfile(context.Background(), lf, t.TempDir(), true, nil)
+		assert.ErrorContains(t, err, "lockfile does not record the command file")
+
+		// Without --frozen the file is looked up in the manifest
+		require.NoError(t, m.InstallFromLockfile(context.Background(), lf, t.TempDir(), false, nil))
+	})
+
+	t.Run("missing hash", func(t *testing.T) {
+		lf := lock()
+		lf.Commands[0].Hash = ""
+		err := NewManager(t.TempDir()).InstallFromLockfile(context.Background(), lf, t.TempDir(), false, nil)
+		assert.ErrorIs(t, err, ErrHashMismatch)
+		assert.ErrorContains(t, err, "no hash recorded")
+	})
+
+	t.Run("repository moved", func(t *testing.T) {
+		m := NewManager(t.TempDir())
+		require.NoError(t, m.Add("locked", "https://example.com/elsewhere"))
+		err := m.InstallFromLockfile(context.Background(), lock(), t.TempDir(), false, nil)
+		assert.ErrorContains(t, err, "but the lockfile was generated from "+server.URL)
+	})
+}
+
+func TestManager_VerifyLockfile(t *testing.T) {
+	command := lockedCommand
+	server := lockRepo(t, &command)
+	dir := t.TempDir()
+	m := NewManager(dir)
+
+	// Lockfiles from older versions hash the parsed spec
+	var spec CommandSpec
+	require.NoError(t, yaml.Unmarshal([]byte(lockedCommand), &spec))
+	j, _ := json.Marshal(&spec)
+	lf := &Lockfile{Commands: []LockedCmd{
+		{Name: "hello", Repo: "locked", Hash: hashData(j), URL: server.URL, File: "commands/hello.yaml"},
+		{Name: "absent", Repo: "locked", Hash: ContentHash(nil)},
+	}}
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "hello.yaml"), []byte(lockedCommand), 0644))
+
+	checks := m.VerifyLockfile(context.Background(), lf, dir, false)
+	require.Len(t, checks, 2)
+	assert.True(t, checks[0].OK())
+	assert.EqualError(t, checks[1].Installed, "not installed")
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "hello.yaml"), []byte("name: hello\nversion: 9\n"), 0644))
+	checks = m.VerifyLockfile(context.Background(), lf, dir, false)
+	assert.ErrorIs(t, checks[0].Installed, ErrHashMismatch)
+}
@@ -5,7 +5,6 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -44,6 +43,17 @@ type Command struct {
 	Aliases     []string `yaml:"aliases,omitempty"`
 	Category    string   `yaml:"category,omitempty"`
 	File        string   `yaml:"file"` // Path to command YAML file in repo
+
+	// Version of File, and older versions still served, for dependency
+	// resolution. Without them the command file is fetched to find its version.
+	Version  string           `yaml:"version,omitempty"`
+	Versions []CommandVersion `yaml:"versions,omitempty"`
+}
+
+// CommandVersion is a published version of a command
+type CommandVersion struct {
+	Version string `yaml:"version"`
+	File    string `yaml:"file"`
 }
 
 // CommandSpec is the full command specification from a YAML file
@@ -73,6 +83,21 @@ type CommandSpec struct {
 	Inputs       []InputSpec  `yaml:"inputs,omitempty" json:"inputs,omitempty"`
 	Outputs      *OutputSpec  `yaml:"outputs,omitempty" json:"outputs,omitempty"`
 	Context      *ContextSpec `yaml:"context,omitempty" json:"context,omitempty"`
+
+	// Permissions limits the tools and hooks the command may use
+	Permissions *PermissionsSpec `yaml:"permissions,omitempty" json:"permissions,omitempty"`
+
+	// Changelog lists what changed in each version, newest first
+	Changelog []ChangelogEntry `yaml:"changelog,omitempty" json:"changelog,omitempty"`
+
+	// Tests are run by scmd command test against a mock backend
+	Tests []CommandTest `yaml:"tests,omitempty" json:"tests,omitempty"`
+}
+
+// ChangelogEntry describes the changes in one version of a command
+type ChangelogEntry struct {
+	Version string   `yaml:"version" json:"version"`
+	Changes []string `yaml:"changes" json:"changes"`
 }
 
 // Dependency defines a command dependency
@@ -88,9 +113,28 @@ type ComposeSpec struct {
 	// Pipeline chains multiple commands together
 	Pipeline []PipelineStep `yaml:"pipeline,omitempty" json:"pipeline,omitempty"`
 	// Parallel runs commands in parallel and merges results
-	Parallel []string `yaml:"parallel,omitempty" json:"parallel,omitempty"`
+	Parallel []ParallelBranch `yaml:"parallel,omitempty" json:"parallel,omitempty"`
+	// MaxConcurrency limits how many parallel commands run at once
+	MaxConcurrency int `yaml:"max_concurrency,omitempty" json:"max_concurrency,omitempty"`
+	// Timeout limits each parallel command, as a duration (30s, 2m) or seconds
+	Timeout string `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+	// Mode is best_effort (default) or fail_fast, which cancels the other
+	// parallel commands when one fails
+	Mode string `yaml:"mode,omitempty" json:"mode,omitempty"`
+	// Merge is how parallel results are combined: concat (default), json,
+	// first or synthesize
+	Merge string `yaml:"merge,omitempty" json:"merge,omitempty"`
+	// Synthesize is the prompt that combines parallel results for merge:
+	// synthesize
+	Synthesize *SynthesizeSpec `yaml:"synthesize,omitempty" json:"synthesize,omitempty"`
 	// Fallback tries commands in order until one succeeds
 	Fallback []string `yaml:"fallback,omitempty" json:"fallback,omitempty"`
+	// Steps is a workflow of named steps that run once their needs have
+	// finished, independent ones concurrently
+	Steps []WorkflowStep `yaml:"steps,omitempty" json:"steps,omitempty"`
+	// Output is the template for a workflow's output, over .steps; it
+	// defaults to the last step's output
+	Output string `yaml:"output,omitempty" json:"output,omitempty"`
 }
 
 // PipelineStep is a step in a command pipeline
@@ -101,6 +145,73 @@ type PipelineStep struct {
 	OnError   string            `yaml:"on_error,omitempty" json:"on_error,omitempty"`   // continue, stop, fallback
 }
 
+// ParallelBranch is a command run by a parallel composition. It can be
+// written as just the command name.
+type ParallelBranch struct {
+	Command string            `yaml:"command" json:"command"`
+	Args    map[string]string `yaml:"args,omitempty" json:"args,omitempty"`
+	Timeout string            `yaml:"timeout,omitempty" json:"timeout,omitempty"` // Overrides the compose timeout
+}
+
+// parallelBranch has ParallelBranch's fields without its methods
+type parallelBranch ParallelBranch
+
+// UnmarshalYAML accepts a command name or a mapping
+func (b *ParallelBranch) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*b = ParallelBranch{Command: node.Value}
+		return nil
+	}
+	return node.Decode((*parallelBranch)(b))
+}
+
+// MarshalYAML writes a branch with only a command as its name
+func (b ParallelBranch) MarshalYAML() (interface{}, error) {
+	if len(b.Args) == 0 && b.Timeout == "" {
+		return b.Command, nil
+	}
+	return parallelBranch(b), nil
+}
+
+// UnmarshalJSON accepts a command name or an object
+func (b *ParallelBranch) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		*b = ParallelBranch{Command: name}
+		return nil
+	}
+	return json.Unmarshal(data, (*parallelBranch)(b))
+}
+
+// MarshalJSON writes a branch with only a command as its name
+func (b ParallelBranch) MarshalJSON() ([]byte, error) {
+	if len(b.Args) == 0 && b.Timeout == "" {
+		return json.Marshal(b.Command)
+	}
+	return json.Marshal(parallelBranch(b))
+}
+
+// SynthesizeSpec is the prompt that combines parallel results. Its
+// template sees .branches, each with .command, .output, .ok, .error and
+// .duration_ms, and .stdin and .inputs.
+type SynthesizeSpec struct {
+	System string `yaml:"system,omitempty" json:"system,omitempty"`
+	Prompt string `yaml:"prompt,omitempty" json:"prompt,omitempty"`
+}
+
+// WorkflowStep is a named step in a workflow. Args, stdin and the output
+// are templates that can use .steps.ID.output of the steps it needs.
+type WorkflowStep struct {
+	ID        string            `yaml:"id" json:"id"`
+	Command   string            `yaml:"command" json:"command"`
+	Needs     []string          `yaml:"needs,omitempty" json:"needs,omitempty"`
+	If        string            `yaml:"if,omitempty" json:"if,omitempty"`       // Condition, as for hooks
+	Args      map[string]string `yaml:"args,omitempty" json:"args,omitempty"`   // Templates
+	Stdin     string            `yaml:"stdin,omitempty" json:"stdin,omitempty"` // Template; defaults to the last need's output
+	Transform string            `yaml:"transform,omitempty" json:"transform,omitempty"`
+	OnError   string            `yaml:"on_error,omitempty" json:"on_error,omitempty"` // continue or stop
+}
+
 // HooksSpec defines pre/post execution hooks
 type HooksSpec struct {
 	Pre  []HookAction `yaml:"pre,omitempty" json:"pre,omitempty"`
@@ -109,9 +220,29 @@ type HooksSpec struct {
 
 // HookAction is a hook action to run
 type HookAction struct {
-	Shell   string `yaml:"shell,omitempty" json:"shell,omitempty"`     // Shell command to run
-	Command string `yaml:"command,omitempty" json:"command,omitempty"` // Another scmd command
-	If      string `yaml:"if,omitempty" json:"if,omitempty"`           // Condition
+	ID              string `yaml:"id,omitempty" json:"id,omitempty"`                               // Name for conditions and templates
+	Shell           string `yaml:"shell,omitempty" json:"shell,omitempty"`                         // Shell command to run
+	Command         string `yaml:"command,omitempty" json:"command,omitempty"`                     // Another scmd command
+	If              string `yaml:"if,omitempty" json:"if,omitempty"`                               // Condition
+	ContinueOnError bool   `yaml:"continue_on_error,omitempty" json:"continue_on_error,omitempty"` // Don't fail the command
+}
+
+// PermissionsSpec declares what a command may do. A command with a
+// permissions block only gets the tools it allows, each limited to what it
+// lists, and runs shell hooks only if it sets hooks. One without keeps
+// every built-in tool and its hooks.
+type PermissionsSpec struct {
+	Shell      []string              `yaml:"shell,omitempty" json:"shell,omitempty"`           // Programs the shell tool may run
+	Filesystem FilesystemPermissions `yaml:"filesystem,omitempty" json:"filesystem,omitempty"` // Paths the file tools may use
+	Network    []string              `yaml:"network,omitempty" json:"network,omitempty"`       // Hosts http_get may fetch
+	Env        []string              `yaml:"env,omitempty" json:"env,omitempty"`               // Variables hooks and the shell tool see
+	Hooks      bool                  `yaml:"hooks,omitempty" json:"hooks,omitempty"`           // Whether shell hooks may run
+}
+
+// FilesystemPermissions are path globs for reading and writing files
+type FilesystemPermissions struct {
+	Read  []string `yaml:"read,omitempty" json:"read,omitempty"`
+	Write []string `yaml:"write,omitempty" json:"write,omitempty"`
 }
 
 // InputSpec defines structured input (like GitHub Actions inputs)
@@ -173,6 +304,8 @@ type ModelSpec struct {
 type Manager struct {
 	mu         sync.RWMutex
 	repos      map[string]*Repository
+	trusted    map[string]*TrustedKey
+	projects   map[string]*TrustedProject
 	dataDir    string
 	httpClient *http.Client
 }
@@ -180,8 +313,10 @@ type Manager struct {
 // NewManager creates a new repository manager
 func NewManager(dataDir string) *Manager {
 	return &Manager{
-		repos:   make(map[string]*Repository),
-		dataDir: dataDir,
+		repos:    make(map[string]*Repository),
+		trusted:  make(map[string]*TrustedKey),
+		projects: make(map[string]*TrustedProject),
+		dataDir:  dataDir,
 		httpClient: &http.Client{
 			Timeout: 30 * time.Second,
 		},
@@ -198,6 +333,13 @@ func (m *Manager) Load() error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	if err := m.loadTrust(); err != nil {
+		return err
+	}
+	if err := m.loadProjects(); err != nil {
+		return err
+	}
+
 	data, err := os.ReadFile(m.reposFile())
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -220,8 +362,8 @@ func (m *Manager) Load() error {
 
 	// Validate and load repositories
 	for _, r := range repos {
-		// Validate URL (SECURITY: prevent SSRF and file:// access)
-		if err := validation.ValidateRepoURL(r.URL); err != nil {
+		// Validate source (SECURITY: prevent SSRF and unexpected schemes)
+		if err := validation.ValidateRepoSource(r.URL); err != nil {
 			return fmt.Errorf("invalid repository '%s': %w", r.Name, err)
 		}
 		m.repos[r.Name] = r
@@ -249,13 +391,25 @@ func (m *Manager) Save() error {
 		return err
 	}
 
-	return os.WriteFile(m.reposFile(), data, 0644)
+	if err := os.WriteFile(m.reposFile(), data, 0644); err != nil {
+		return err
+	}
+	if err := m.saveTrust(); err != nil {
+		return err
+	}
+	return m.saveProjects()
 }
 
-// Add adds a new repository
+// Add adds a new repository. url may also be a local directory, stored as
+// a file:// URL.
 func (m *Manager) Add(name, url string) error {
-	// Validate URL (SECURITY: prevent SSRF, file:// access, and other attacks)
-	if err := validation.ValidateRepoURL(url); err != nil {
+	url, err := NormalizeSource(url)
+	if err != nil {
+		return fmt.Errorf("invalid repository path: %w", err)
+	}
+
+	// Validate source (SECURITY: prevent SSRF, unexpected schemes, and other attacks)
+	if err := validation.ValidateRepoSource(url); err != nil {
 		return fmt.Errorf("invalid repository URL: %w", err)
 	}
 
@@ -280,11 +434,16 @@ func (m *Manager) Remove(name string) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	if _, exists := m.repos[name]; !exists {
+	repo, exists := m.repos[name]
+	if !exists {
 		return fmt.Errorf("repository '%s' not found", name)
 	}
 
+	if SourceKind(repo.URL) == SourceGit {
+		os.RemoveAll(m.gitDir(repo))
+	}
 	delete(m.repos, name)
+	delete(m.trusted, name)
 	return nil
 }
 
@@ -309,27 +468,14 @@ func (m *Manager) List() []*Repository {
 	return repos
 }
 
-// FetchManifest fetches and parses a repo's manifest
+// FetchManifest fetches and parses a repo's manifest, verifying its
+// signature if the repo has a trusted key
 func (m *Manager) FetchManifest(ctx context.Context, repo *Repository) (*Manifest, error) {
-	url := repo.URL + "/scmd-repo.yaml"
-
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
-	if err != nil {
-		return nil, err
-	}
-
-	resp, err := m.httpClient.Do(req)
+	data, err := m.fetch(ctx, repo, "scmd-repo.yaml")
 	if err != nil {
 		return nil, fmt.Errorf("fetch manifest: %w", err)
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("fetch manifest: status %d", resp.StatusCode)
-	}
-
-	data, err := io.ReadAll(resp.Body)
-	if err != nil {
+	if err := m.verify(ctx, repo, "scmd-repo.yaml", data); err != nil {
 		return nil, err
 	}
 
@@ -341,36 +487,29 @@ func (m *Manager) FetchManifest(ctx context.Context, repo *Repository) (*Manifes
 	return &manifest, nil
 }
 
-// FetchCommand fetches a command spec from a repo
+// FetchCommand fetches a command spec from a repo, verifying its signature
+// if the repo has a trusted key
 func (m *Manager) FetchCommand(ctx context.Context, repo *Repository, cmdPath string) (*CommandSpec, error) {
-	url := repo.URL + "/" + cmdPath
-
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
-	if err != nil {
-		return nil, err
-	}
+	spec, _, err := m.FetchCommandData(ctx, repo, cmdPath)
+	return spec, err
+}
 
-	resp, err := m.httpClient.Do(req)
+// FetchCommandData is FetchCommand that also returns the file as served
+func (m *Manager) FetchCommandData(ctx context.Context, repo *Repository, cmdPath string) (*CommandSpec, []byte, error) {
+	data, err := m.fetch(ctx, repo, cmdPath)
 	if err != nil {
-		return nil, fmt.Errorf("fetch command: %w", err)
+		return nil, nil, fmt.Errorf("fetch command: %w", err)
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("fetch command: status %d", resp.StatusCode)
-	}
-
-	data, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
+	if err := m.verify(ctx, repo, cmdPath, data); err != nil {
+		return nil, nil, err
 	}
 
 	var spec CommandSpec
 	if err := yaml.Unmarshal(data, &spec); err != nil {
-		return nil, fmt.Errorf("parse command: %w", err)
+		return nil, nil, fmt.Errorf("parse command: %w", err)
 	}
 
-	return &spec, nil
+	return &spec, data, nil
 }
 
 // SearchCommands searches for commands across all repos
@@ -475,6 +614,22 @@ func (m *Manager) InstallCommand(spec *CommandSpec, installDir string) error {
 	return nil
 }
 
+// InstallCommandData saves a command file exactly as it was served, so that
+// its hash can later be checked against a lockfile, and keeps a copy of the
+// version
+func (m *Manager) InstallCommandData(spec *CommandSpec, data []byte, installDir string) error {
+	if err := validation.ValidateCommandName(spec.Name); err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(filepath.Join(installDir, spec.Name+".yaml"), data, 0644); err != nil {
+		return fmt.Errorf("write command: %w", err)
+	}
+
+	// Keep this version for rollback and pinning
+	return keepVersion(installDir, spec, data)
+}
+
 // LoadInstalledCommands loads all installed commands from local storage
 func (m *Manager) LoadInstalledCommands(installDir string) ([]*CommandSpec, error) {
 	entries, err := os.ReadDir(installDir)
@@ -523,5 +678,5 @@ func (m *Manager) UninstallCommand(name, installDir string) error {
 		return err
 	}
 
-	return nil
+	return os.RemoveAll(versionsDir(installDir, name))
 }
@@ -0,0 +1,222 @@
+package repos
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// IsJSON reports whether the command's output is structured JSON
+func (o *OutputSpec) IsJSON() bool {
+	return o != nil && strings.EqualFold(o.Format, "json")
+}
+
+// jsonInstructions tells the model how to shape its output
+func (o *OutputSpec) jsonInstructions() string {
+	var b strings.Builder
+	b.WriteString("Respond with only a JSON object: no prose and no code fences.")
+	if len(o.Schema) == 0 {
+		return b.String()
+	}
+
+	b.WriteString(" It must have these fields:\n")
+	for _, name := range sortedKeys(o.Schema) {
+		fmt.Fprintf(&b, "  %q: %s\n", name, o.Schema[name])
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// parse extracts the JSON object from a response and checks it against the
+// schema hints. It returns the parsed value, or the problems found.
+func (o *OutputSpec) parse(text string) (interface{}, []string) {
+	raw := extractJSONValue(text)
+	if raw == "" {
+		return nil, []string{"the response contains no JSON"}
+	}
+
+	var value interface{}
+	if err := json.Unmarshal([]byte(raw), &value); err != nil {
+		return nil, []string{fmt.Sprintf("invalid JSON: %v", err)}
+	}
+
+	if problems := checkSchema(value, o.Schema); len(problems) > 0 {
+		return nil, problems
+	}
+	return value, nil
+}
+
+// checkSchema validates a value against schema hints. Each hint names a
+// field and may start with its type: "string", "integer?" (optional),
+// "string[]" or "array", "boolean - whether ...". Hints that do not start
+// with a type only require the field to be present.
+func checkSchema(value interface{}, schema map[string]string) []string {
+	if len(schema) == 0 {
+		return nil
+	}
+
+	obj, ok := value.(map[string]interface{})
+	if !ok {
+		return []string{fmt.Sprintf("expected a JSON object, got %s", jsonTypeName(value))}
+	}
+
+	var problems []string
+	for _, name := range sortedKeys(schema) {
+		typ, elem, optional := schemaType(schema[name])
+		field, present := obj[name]
+		if !present || field == nil {
+			if !optional {
+				problems = append(problems, fmt.Sprintf("missing field %q", name))
+			}
+			continue
+		}
+		if typ != "" && !matchesType(field, typ) {
+			problems = append(problems, fmt.Sprintf("field %q should be %s, got %s", name, typ, jsonTypeName(field)))
+			continue
+		}
+		if elem != "" {
+			for i, item := range field.([]interface{}) {
+				if !matchesType(item, elem) {
+					problems = append(problems, fmt.Sprintf("field %q item %d should be %s, got %s", name, i, elem, jsonTypeName(item)))
+					break
+				}
+			}
+		}
+	}
+	return problems
+}
+
+// schemaTypes maps the type words a hint may start with to JSON types
+var schemaTypes = map[string]string{
+	"string": "string", "str": "string", "text": "string",
+	"number": "number", "float": "number",
+	"integer": "integer", "int": "integer",
+	"boolean": "boolean", "bool": "boolean",
+	"array": "array", "list": "array",
+	"object": "object", "map": "object",
+}
+
+// schemaType reads the leading type of a schema hint. elem is the item type
+// of typed arrays such as "string[]".
+func schemaType(hint string) (typ, elem string, optional bool) {
+	word, _, _ := strings.Cut(strings.TrimSpace(hint), " ")
+	word = strings.ToLower(strings.TrimRight(word, ",:;"))
+	if rest, ok := strings.CutPrefix(word, "optional"); ok && rest == "" {
+		optional = true
+		fields := strings.Fields(hint)
+		if len(fields) < 2 {
+			return "", "", true
+		}
+		word = strings.ToLower(strings.TrimRight(fields[1], ",:;"))
+	}
+	if strings.HasSuffix(word, "?") {
+		optional = true
+		word = strings.TrimSuffix(word, "?")
+	}
+	if item, ok := strings.CutSuffix(word, "[]"); ok {
+		return "array", schemaTypes[item], optional
+	}
+	return schemaTypes[word], "", optional
+}
+
+func matchesType(v interface{}, typ string) bool {
+	switch typ {
+	case "string":
+		_, ok := v.(string)
+		return ok
+	case "number":
+		_, ok := v.(float64)
+		return ok
+	case "integer":
+		n, ok := v.(float64)
+		return ok && n == math.Trunc(n)
+	case "boolean":
+		_, ok := v.(bool)
+		return ok
+	case "array":
+		_, ok := v.([]interface{})
+		return ok
+	case "object":
+		_, ok := v.(map[string]interface{})
+		return ok
+	}
+	return true
+}
+
+func jsonTypeName(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// render formats the structured value as the command's output: through the
+// template if there is one, otherwise as indented JSON
+func (o *OutputSpec) render(value interface{}) (string, error) {
+	if o.Template == "" {
+		data, err := json.MarshalIndent(value, "", "  ")
+		if err != nil {
+			return "", err
+		}
+		return string(data), nil
+	}
+
+	tmpl, err := template.New("output").Parse(o.Template)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, value); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// extractJSONValue returns the outermost JSON object or array in s,
+// skipping code fences and surrounding prose
+func extractJSONValue(s string) string {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "```") {
+		if nl := strings.Index(s, "\n"); nl >= 0 {
+			s = s[nl+1:]
+		}
+		s = strings.TrimSuffix(strings.TrimSpace(s), "```")
+	}
+
+	start := strings.IndexAny(s, "{[")
+	if start < 0 {
+		return ""
+	}
+	closer := byte('}')
+	if s[start] == '[' {
+		closer = ']'
+	}
+	end := strings.LastIndexByte(s, closer)
+	if end < start {
+		return ""
+	}
+	return strings.TrimSpace(s[start : end+1])
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
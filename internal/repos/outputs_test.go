@@ -0,0 +1,196 @@
+package repos
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/scmd/scmd/internal/backend"
+	"github.com/scmd/scmd/internal/backend/mock"
+	"github.com/scmd/scmd/internal/command"
+)
+
+// scriptedBackend returns canned responses in order and records requests
+type scriptedBackend struct {
+	*mock.Backend
+	responses []string
+	requests  []*backend.CompletionRequest
+}
+
+func (b *scriptedBackend) Complete(_ context.Context, req *backend.CompletionRequest) (*backend.CompletionResponse, error) {
+	b.requests = append(b.requests, req)
+	resp := b.responses[0]
+	if len(b.responses) > 1 {
+		b.responses = b.responses[1:]
+	}
+	return &backend.CompletionResponse{Content: resp}, nil
+}
+
+func TestSchemaType(t *testing.T) {
+	tests := []struct {
+		hint      string
+		typ, elem string
+		optional  bool
+	}{
+		{"string", "string", "", false},
+		{"integer? - how many", "integer", "", true},
+		{"optional bool", "boolean", "", true},
+		{"string[]", "array", "string", false},
+		{"List, of things", "array", "", false},
+		{"the title of the page", "", "", false},
+	}
+	for _, tt := range tests {
+		typ, elem, optional := schemaType(tt.hint)
+		assert.Equal(t, tt.typ, typ, tt.hint)
+		assert.Equal(t, tt.elem, elem, tt.hint)
+		assert.Equal(t, tt.optional, optional, tt.hint)
+	}
+}
+
+func TestCheckSchema(t *testing.T) {
+	schema := map[string]string{
+		"title": "string",
+		"count": "integer",
+		"tags":  "string[]",
+		"notes": "string? extra notes",
+		"ok":    "anything the model likes",
+	}
+
+	var value interface{} = map[string]interface{}{
+		"title": "x", "count": 2.0, "tags": []interface{}{"a"}, "ok": false,
+	}
+	assert.Empty(t, checkSchema(value, schema))
+
+	value = map[string]interface{}{
+		"title": 3.0, "count": 2.5, "tags": []interface{}{"a", 1.0}, "notes": nil,
+	}
+	assert.Equal(t, []string{
+		`field "count" should be integer, got number`,
+		`missing field "ok"`,
+		`field "tags" item 1 should be string, got number`,
+		`field "title" should be string, got number`,
+	}, checkSchema(value, schema))
+
+	assert.Equal(t, []string{"expected a JSON object, got array"}, checkSchema([]interface{}{}, schema))
+	assert.Empty(t, checkSchema([]interface{}{}, nil))
+}
+
+func jsonSpec() *CommandSpec {
+	return &CommandSpec{
+		Name:   "classify",
+		Prompt: PromptSpec{Template: "Classify: {{.stdin}}"},
+		Outputs: &OutputSpec{
+			Format: "json",
+			Schema: map[string]string{"label": "string", "confidence": "number"},
+		},
+	}
+}
+
+func TestPluginCommand_JSONOutput(t *testing.T) {
+	be := &scriptedBackend{Backend: mock.New(), responses: []string{
+		"Sure! ```json\n{\"label\": \"bug\", \"confidence\": 0.9}\n```",
+	}}
+	args := command.NewArgs()
+	args.Options["stdin"] = "it crashes"
+
+	result, err := NewPluginCommand(jsonSpec()).Execute(context.Background(), args, &command.ExecContext{Backend: be})
+	require.NoError(t, err)
+	require.True(t, result.Success, result.Error)
+
+	require.Len(t, be.requests, 1)
+	assert.True(t, be.requests[0].JSONMode)
+	assert.Contains(t, be.requests[0].Prompt, "Respond with only a JSON object")
+	assert.Contains(t, be.requests[0].Prompt, `"confidence": number`)
+
+	assert.Equal(t, map[string]interface{}{"label": "bug", "confidence": 0.9}, result.Data)
+	assert.Equal(t, "{\n  \"confidence\": 0.9,\n  \"label\": \"bug\"\n}", result.Output)
+}
+
+func TestPluginCommand_JSONOutputRetriesOnce(t *testing.T) {
+	spec := jsonSpec()
+	spec.Outputs.Template = "{{.label}} ({{.confidence}})"
+
+	be := &scriptedBackend{Backend: mock.New(), responses: []string{
+		`{"label": "bug"}`,
+		`{"label": "bug", "confidence": 0.5}`,
+	}}
+	result, err := NewPluginCommand(spec).Execute(context.Background(), command.NewArgs(), &command.ExecContext{Backend: be})
+	require.NoError(t, err)
+	require.True(t, result.Success, result.Error)
+
+	require.Len(t, be.requests, 2)
+	assert.Contains(t, be.requests[1].Prompt, "- missing field \"confidence\"")
+	assert.Contains(t, be.requests[1].Prompt, `Previous response:`+"\n"+`{"label": "bug"}`)
+	assert.Equal(t, "bug (0.5)", result.Output)
+}
+
+func TestPluginCommand_JSONOutputFailsAfterRetry(t *testing.T) {
+	be := &scriptedBackend{Backend: mock.New(), responses: []string{"no idea"}}
+	result, err := NewPluginCommand(jsonSpec()).Execute(context.Background(), command.NewArgs(), &command.ExecContext{Backend: be})
+	require.NoError(t, err)
+	assert.False(t, result.Success)
+	assert.Len(t, be.requests, 2)
+	assert.Equal(t, "output does not match the schema: the response contains no JSON", result.Error)
+}
+
+// toolCallingBackend calls a tool once, answering in prose alongside the call
+type toolCallingBackend struct {
+	scriptedBackend
+	toolRequests []*backend.ToolRequest
+}
+
+func (b *toolCallingBackend) SupportsToolCalling() bool { return true }
+
+func (b *toolCallingBackend) CompleteWithTools(_ context.Context, req *backend.ToolRequest) (*backend.ToolResponse, error) {
+	b.toolRequests = append(b.toolRequests, req)
+	return &backend.ToolResponse{
+		Content:   "Let me look at the file first, then I will classify the report for you.",
+		ToolCalls: []backend.ToolCall{{Name: "read_file", Parameters: map[string]interface{}{"path": "missing.txt"}}},
+	}, nil
+}
+
+func TestPluginCommand_JSONOutputWithTools(t *testing.T) {
+	be := &toolCallingBackend{scriptedBackend: scriptedBackend{Backend: mock.New(), responses: []string{
+		`{"label": "bug", "confidence": 0.9}`,
+	}}}
+	result, err := NewPluginCommand(jsonSpec()).Execute(context.Background(), command.NewArgs(), &command.ExecContext{Backend: be})
+	require.NoError(t, err)
+	require.True(t, result.Success, result.Error)
+
+	require.Len(t, be.toolRequests, 1)
+	assert.True(t, be.toolRequests[0].JSONMode)
+	require.Len(t, be.requests, 1)
+	assert.True(t, be.requests[0].JSONMode)
+	assert.Contains(t, be.requests[0].Prompt, "Tool execution results:")
+	assert.Equal(t, map[string]interface{}{"label": "bug", "confidence": 0.9}, result.Data)
+}
+
+func TestComposer_PipelinePassesStructuredOutput(t *testing.T) {
+	registry := command.NewRegistry()
+	require.NoError(t, registry.Register(NewPluginCommand(jsonSpec())))
+	require.NoError(t, registry.Register(NewPluginCommand(&CommandSpec{
+		Name:   "route",
+		Prompt: PromptSpec{Template: "Send {{.data.label}} issues to the right team"},
+	})))
+
+	be := &scriptedBackend{Backend: mock.New(), responses: []string{
+		`{"label": "bug", "confidence": 0.9}`,
+		"the bug team",
+	}}
+	composer := NewComposer(registry, nil)
+	spec := &CommandSpec{Compose: &ComposeSpec{Pipeline: []PipelineStep{
+		{Command: "classify"},
+		{Command: "route"},
+	}}}
+
+	result, err := composer.ExecuteComposed(context.Background(), spec, command.NewArgs(), &command.ExecContext{Backend: be})
+	require.NoError(t, err)
+	require.True(t, result.Success, result.Error)
+
+	require.Len(t, be.requests, 2)
+	assert.Equal(t, "Send bug issues to the right team", be.requests[1].Prompt)
+	assert.Equal(t, "the bug team", result.Output)
+	assert.Nil(t, result.Data)
+}
@@ -0,0 +1,345 @@
+package repos
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/scmd/scmd/internal/backend"
+	"github.com/scmd/scmd/internal/command"
+)
+
+// Parallel modes
+const (
+	ParallelBestEffort = "best_effort"
+	ParallelFailFast   = "fail_fast"
+)
+
+// Parallel merge strategies
+const (
+	MergeConcat     = "concat"
+	MergeJSON       = "json"
+	MergeFirst      = "first"
+	MergeSynthesize = "synthesize"
+)
+
+// defaultSynthesizePrompt combines the successful branches
+const defaultSynthesizePrompt = `Combine the results below into a single answer. Keep what they agree on, point out where they disagree, and don't repeat yourself.
+{{range .branches}}{{if .ok}}
+## {{.command}}
+{{.output}}
+{{end}}{{end}}`
+
+// branchResult records the outcome of a parallel command
+type branchResult struct {
+	Command  string
+	OK       bool
+	Output   string
+	Data     interface{}
+	Error    string
+	Duration time.Duration
+}
+
+// value is the branch as the result's data and synthesis templates see it
+func (r *branchResult) value() map[string]interface{} {
+	return map[string]interface{}{
+		"command":     r.Command,
+		"ok":          r.OK,
+		"output":      r.Output,
+		"data":        r.Data,
+		"error":       r.Error,
+		"duration_ms": float64(r.Duration.Milliseconds()),
+	}
+}
+
+// executeParallel runs the parallel commands, at most max_concurrency at a
+// time, and merges their results. The result's data lists every command
+// with its output, error and duration.
+func (c *Composer) executeParallel(
+	ctx context.Context,
+	spec *CommandSpec,
+	args *command.Args,
+	execCtx *command.ExecContext,
+) (*command.Result, error) {
+	compose := spec.Compose
+	branches := compose.Parallel
+
+	timeout, err := parseTimeout(compose.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	timeouts := make([]time.Duration, len(branches))
+	for i, b := range branches {
+		timeouts[i] = timeout
+		if b.Timeout != "" {
+			if timeouts[i], err = parseTimeout(b.Timeout); err != nil {
+				return nil, fmt.Errorf("%s: %w", b.Command, err)
+			}
+		}
+	}
+
+	mode, merge := compose.Mode, compose.Merge
+	if mode == "" {
+		mode = ParallelBestEffort
+	}
+	if merge == "" {
+		merge = MergeConcat
+	}
+	switch mode {
+	case ParallelBestEffort, ParallelFailFast:
+	default:
+		return nil, fmt.Errorf("unknown parallel mode %q (use best_effort or fail_fast)", mode)
+	}
+	switch merge {
+	case MergeConcat, MergeJSON, MergeFirst, MergeSynthesize:
+	default:
+		return nil, fmt.Errorf("unknown merge %q (use concat, json, first or synthesize)", merge)
+	}
+
+	// Resolve every command before anything runs
+	cmds := make([]command.Command, len(branches))
+	for i, b := range branches {
+		cmds[i], _ = c.lookup(b.Command)
+	}
+
+	limit := compose.MaxConcurrency
+	if limit <= 0 || limit > len(branches) {
+		limit = len(branches)
+	}
+	slots := make(chan struct{}, limit)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make([]*branchResult, len(branches))
+	done := make(chan int)
+	for i, b := range branches {
+		go func(i int, b ParallelBranch) {
+			res := &branchResult{Command: b.Command}
+			defer func() {
+				results[i] = res
+				done <- i
+			}()
+
+			select {
+			case slots <- struct{}{}:
+				defer func() { <-slots }()
+			case <-ctx.Done():
+				res.Error = "cancelled"
+				return
+			}
+			if ctx.Err() != nil {
+				res.Error = "cancelled"
+				return
+			}
+			if cmds[i] == nil {
+				res.Error = fmt.Sprintf("command '%s' not found", b.Command)
+				return
+			}
+			runBranch(ctx, cmds[i], branchArgs(args, b), execCtx, timeouts[i], res)
+		}(i, b)
+	}
+
+	// Wait for every command, cancelling the rest once one fails in
+	// fail_fast mode or, for merge: first, once one succeeds
+	var failure error
+	winner := -1
+	for range branches {
+		i := <-done
+		res := results[i]
+		switch {
+		case res.OK && merge == MergeFirst && winner < 0 && failure == nil:
+			winner = i
+			cancel()
+		case !res.OK && mode == ParallelFailFast && failure == nil && winner < 0:
+			failure = fmt.Errorf("parallel command %s failed: %s", res.Command, res.Error)
+			cancel()
+		}
+	}
+	if failure != nil {
+		return nil, failure
+	}
+
+	data := make([]interface{}, len(results))
+	var failed []string
+	for i, res := range results {
+		data[i] = res.value()
+		if !res.OK {
+			failed = append(failed, fmt.Sprintf("%s: %s", res.Command, res.Error))
+		}
+	}
+	if len(failed) == len(results) {
+		return nil, fmt.Errorf("all parallel commands failed: %s", strings.Join(failed, "; "))
+	}
+
+	switch merge {
+	case MergeFirst:
+		return &command.Result{Success: true, Output: results[winner].Output, Data: data}, nil
+
+	case MergeJSON:
+		return &command.Result{Success: true, Output: renderValue(data, true), Data: data}, nil
+
+	case MergeSynthesize:
+		output, err := synthesize(ctx, spec, args, data, execCtx)
+		if err != nil {
+			return nil, fmt.Errorf("synthesize: %w", err)
+		}
+		return &command.Result{Success: true, Output: output, Data: data}, nil
+	}
+
+	outputs := make([]string, len(results))
+	for i, res := range results {
+		if res.OK {
+			outputs[i] = fmt.Sprintf("## %s\n%s", res.Command, res.Output)
+		} else {
+			outputs[i] = fmt.Sprintf("[%s] Error: %s", res.Command, res.Error)
+		}
+	}
+	return &command.Result{
+		Success: true,
+		Output:  strings.Join(outputs, "\n\n"),
+		Data:    data,
+	}, nil
+}
+
+// runBranch runs a parallel command, giving up when its timeout passes or
+// the composition is cancelled, even if the command doesn't stop
+func runBranch(ctx context.Context, cmd command.Command, args *command.Args, execCtx *command.ExecContext, timeout time.Duration, res *branchResult) {
+	start := time.Now()
+	defer func() { res.Duration = time.Since(start) }()
+
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	type outcome struct {
+		result *command.Result
+		err    error
+	}
+	finished := make(chan outcome, 1)
+	go func() {
+		result, err := cmd.Execute(ctx, args, execCtx)
+		finished <- outcome{result, err}
+	}()
+
+	var o outcome
+	select {
+	case o = <-finished:
+	case <-ctx.Done():
+	}
+
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		res.Error = fmt.Sprintf("timed out after %s", timeout)
+	case ctx.Err() != nil:
+		res.Error = "cancelled"
+	case o.err != nil:
+		res.Error = o.err.Error()
+	case !o.result.Success:
+		res.Error = o.result.Error
+		res.Output = o.result.Output
+	default:
+		res.OK = true
+		res.Output = o.result.Output
+		res.Data = o.result.Data
+	}
+}
+
+// branchArgs copies the composed command's args for a parallel command and
+// adds the command's own
+func branchArgs(args *command.Args, b ParallelBranch) *command.Args {
+	out := command.NewArgs()
+	out.Positional = append(out.Positional, args.Positional...)
+	out.Raw = args.Raw
+	out.Data = args.Data
+	for k, v := range args.Flags {
+		out.Flags[k] = v
+	}
+	for k, v := range args.Options {
+		out.Options[k] = v
+	}
+	for k, v := range b.Args {
+		out.Options[k] = v
+	}
+	return out
+}
+
+// synthesize asks the model to combine the parallel results
+func synthesize(ctx context.Context, spec *CommandSpec, args *command.Args, branches []interface{}, execCtx *command.ExecContext) (string, error) {
+	if execCtx == nil || execCtx.Backend == nil {
+		return "", fmt.Errorf("no backend available")
+	}
+
+	prompt, system := defaultSynthesizePrompt, ""
+	if s := spec.Compose.Synthesize; s != nil {
+		if s.Prompt != "" {
+			prompt = s.Prompt
+		}
+		system = s.System
+	}
+
+	inputs := make(map[string]interface{})
+	for k, v := range args.Options {
+		if k != "stdin" {
+			inputs[k] = v
+		}
+	}
+	values := map[string]interface{}{
+		"branches": branches,
+		"stdin":    args.Options["stdin"],
+		"inputs":   inputs,
+	}
+
+	prompt, err := renderWorkflowTemplate("synthesize", prompt, values)
+	if err != nil {
+		return "", err
+	}
+	if system != "" {
+		if system, err = renderWorkflowTemplate("system", system, values); err != nil {
+			return "", err
+		}
+	}
+
+	req := &backend.CompletionRequest{
+		Prompt:       prompt,
+		SystemPrompt: system,
+		MaxTokens:    2048,
+		Temperature:  0.7,
+	}
+	if spec.Model.MaxTokens > 0 {
+		req.MaxTokens = spec.Model.MaxTokens
+	}
+	if spec.Model.Temperature > 0 {
+		req.Temperature = spec.Model.Temperature
+	}
+
+	resp, err := execCtx.Backend.Complete(ctx, req)
+	if err != nil {
+		return "", fmt.Errorf("completion failed: %w", err)
+	}
+	return resp.Content, nil
+}
+
+// parseTimeout parses a duration such as 30s or 2m, or a number of seconds
+func parseTimeout(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+	if n, err := strconv.ParseFloat(s, 64); err == nil {
+		if n < 0 {
+			return 0, fmt.Errorf("invalid timeout %q", s)
+		}
+		return time.Duration(n * float64(time.Second)), nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid timeout %q: use a duration such as 30s or 2m", s)
+	}
+	return d, nil
+}
@@ -0,0 +1,214 @@
+package repos
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+
+	"github.com/scmd/scmd/internal/backend/mock"
+	"github.com/scmd/scmd/internal/command"
+)
+
+// parallelRegistry has quick commands that echo, a slow one that waits for
+// cancellation and one that fails
+func parallelRegistry(t *testing.T) *command.Registry {
+	t.Helper()
+	registry := command.NewRegistry()
+	for _, name := range []string{"security", "style"} {
+		require.NoError(t, registry.Register(&funcCommand{name: name, run: func(_ context.Context, args *command.Args) (*command.Result, error) {
+			return command.NewResult(fmt.Sprintf("%s review of %s%s", name, args.Options["stdin"], args.Options["focus"])), nil
+		}}))
+	}
+	require.NoError(t, registry.Register(&funcCommand{name: "slow", run: func(ctx context.Context, _ *command.Args) (*command.Result, error) {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(5 * time.Second):
+			return command.NewResult("slow review"), nil
+		}
+	}}))
+	require.NoError(t, registry.Register(&funcCommand{name: "broken", run: func(context.Context, *command.Args) (*command.Result, error) {
+		return &command.Result{Success: false, Error: "model unavailable"}, nil
+	}}))
+	return registry
+}
+
+func runParallel(t *testing.T, registry *command.Registry, compose *ComposeSpec, execCtx *command.ExecContext) (*command.Result, error) {
+	t.Helper()
+	args := command.NewArgs()
+	args.Options["stdin"] = "main.go"
+	if execCtx == nil {
+		execCtx = &command.ExecContext{}
+	}
+	spec := &CommandSpec{Name: "review-all", Compose: compose}
+	return NewComposer(registry, nil).ExecuteComposed(context.Background(), spec, args, execCtx)
+}
+
+func TestParallelBranch_YAML(t *testing.T) {
+	var c ComposeSpec
+	require.NoError(t, yaml.Unmarshal([]byte(`
+parallel:
+  - security
+  - command: style
+    timeout: 30s
+    args:
+      focus: naming
+`), &c))
+	assert.Equal(t, []ParallelBranch{
+		{Command: "security"},
+		{Command: "style", Timeout: "30s", Args: map[string]string{"focus": "naming"}},
+	}, c.Parallel)
+
+	out, err := yaml.Marshal(c)
+	require.NoError(t, err)
+	assert.Contains(t, string(out), "- security\n")
+
+	data, err := json.Marshal(c)
+	require.NoError(t, err)
+	var decoded ComposeSpec
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, c.Parallel, decoded.Parallel)
+}
+
+func TestComposer_Parallel(t *testing.T) {
+	registry := parallelRegistry(t)
+
+	t.Run("concat", func(t *testing.T) {
+		result, err := runParallel(t, registry, &ComposeSpec{Parallel: []ParallelBranch{
+			{Command: "security"},
+			{Command: "style", Args: map[string]string{"focus": " (naming)"}},
+			{Command: "broken"},
+		}}, nil)
+		require.NoError(t, err)
+		require.True(t, result.Success)
+		assert.Equal(t, "## security\nsecurity review of main.go\n\n## style\nstyle review of main.go (naming)\n\n[broken] Error: model unavailable", result.Output)
+
+		branches := result.Data.([]interface{})
+		require.Len(t, branches, 3)
+		first := branches[0].(map[string]interface{})
+		assert.Equal(t, "security", first["command"])
+		assert.Equal(t, true, first["ok"])
+		assert.Contains(t, first, "duration_ms")
+		assert.Equal(t, "model unavailable", branches[2].(map[string]interface{})["error"])
+	})
+
+	t.Run("json", func(t *testing.T) {
+		result, err := runParallel(t, registry, &ComposeSpec{
+			Parallel: []ParallelBranch{{Command: "security"}, {Command: "style"}},
+			Merge:    MergeJSON,
+		}, nil)
+		require.NoError(t, err)
+		var branches []map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(result.Output), &branches))
+		require.Len(t, branches, 2)
+		assert.Equal(t, "style review of main.go", branches[1]["output"])
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		begin := time.Now()
+		result, err := runParallel(t, registry, &ComposeSpec{
+			Parallel: []ParallelBranch{{Command: "security"}, {Command: "slow", Timeout: "50ms"}},
+		}, nil)
+		require.NoError(t, err)
+		assert.Less(t, time.Since(begin), 5*time.Second)
+		assert.Contains(t, result.Output, "[slow] Error: timed out after 50ms")
+	})
+
+	t.Run("fail fast cancels the others", func(t *testing.T) {
+		begin := time.Now()
+		_, err := runParallel(t, registry, &ComposeSpec{
+			Parallel: []ParallelBranch{{Command: "slow"}, {Command: "broken"}},
+			Mode:     ParallelFailFast,
+		}, nil)
+		assert.EqualError(t, err, "parallel command broken failed: model unavailable")
+		assert.Less(t, time.Since(begin), 5*time.Second)
+	})
+
+	t.Run("first success", func(t *testing.T) {
+		begin := time.Now()
+		result, err := runParallel(t, registry, &ComposeSpec{
+			Parallel: []ParallelBranch{{Command: "slow"}, {Command: "broken"}, {Command: "style"}},
+			Merge:    MergeFirst,
+		}, nil)
+		require.NoError(t, err)
+		assert.Equal(t, "style review of main.go", result.Output)
+		assert.Less(t, time.Since(begin), 5*time.Second)
+		assert.Equal(t, "cancelled", result.Data.([]interface{})[0].(map[string]interface{})["error"])
+	})
+
+	t.Run("all failed", func(t *testing.T) {
+		_, err := runParallel(t, registry, &ComposeSpec{
+			Parallel: []ParallelBranch{{Command: "broken"}, {Command: "missing"}},
+		}, nil)
+		assert.EqualError(t, err, "all parallel commands failed: broken: model unavailable; missing: command 'missing' not found")
+	})
+
+	t.Run("synthesize", func(t *testing.T) {
+		be := &recordingBackend{Backend: mock.New()}
+		result, err := runParallel(t, registry, &ComposeSpec{
+			Parallel: []ParallelBranch{{Command: "security"}, {Command: "broken"}, {Command: "style"}},
+			Merge:    MergeSynthesize,
+		}, &command.ExecContext{Backend: be})
+		require.NoError(t, err)
+		assert.Equal(t, "Mock response", result.Output)
+
+		require.Len(t, be.requests, 1)
+		prompt := be.requests[0].Prompt
+		assert.Contains(t, prompt, "## security\nsecurity review of main.go")
+		assert.Contains(t, prompt, "## style\nstyle review of main.go")
+		assert.NotContains(t, prompt, "broken")
+	})
+}
+
+func TestComposer_ParallelMaxConcurrency(t *testing.T) {
+	registry := command.NewRegistry()
+	var running, peak atomic.Int32
+	var branches []ParallelBranch
+	for i := 0; i < 6; i++ {
+		name := fmt.Sprintf("cmd%d", i)
+		branches = append(branches, ParallelBranch{Command: name})
+		require.NoError(t, registry.Register(&funcCommand{name: name, run: func(context.Context, *command.Args) (*command.Result, error) {
+			n := running.Add(1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			time.Sleep(20 * time.Millisecond)
+			running.Add(-1)
+			return command.NewResult(name), nil
+		}}))
+	}
+
+	result, err := runParallel(t, registry, &ComposeSpec{Parallel: branches, MaxConcurrency: 2}, nil)
+	require.NoError(t, err)
+	assert.Len(t, result.Data, 6)
+	assert.Equal(t, int32(2), peak.Load())
+}
+
+func TestParseTimeout(t *testing.T) {
+	for in, want := range map[string]time.Duration{
+		"":      0,
+		"30s":   30 * time.Second,
+		"2m":    2 * time.Minute,
+		"60":    60 * time.Second,
+		"1.5":   1500 * time.Millisecond,
+		"250ms": 250 * time.Millisecond,
+	} {
+		got, err := parseTimeout(in)
+		require.NoError(t, err, in)
+		assert.Equal(t, want, got, in)
+	}
+	for _, in := range []string{"soon", "-1", "-5s"} {
+		_, err := parseTimeout(in)
+		assert.Error(t, err, in)
+	}
+}
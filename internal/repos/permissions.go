@@ -0,0 +1,127 @@
+package repos
+
+import (
+	"strings"
+
+	"github.com/scmd/scmd/internal/command"
+	"github.com/scmd/scmd/internal/config"
+	"github.com/scmd/scmd/internal/sandbox"
+	"github.com/scmd/scmd/internal/tools"
+)
+
+// A permissions block declares what a command may do:
+//
+//	permissions:
+//	  shell: [git, go]
+//	  filesystem:
+//	    read: ["**/*.go", go.mod]
+//	    write: [docs/]
+//	  network: [api.github.com, "*.golang.org"]
+//	  env: [GITHUB_TOKEN]
+//	  hooks: true
+//
+// The command's tools are built from it: the shell tool runs only the
+// listed programs, read_file and write_file use only matching paths, and
+// http_get fetches only from the listed hosts. A tool with nothing listed
+// is left out. Hooks, shell or command, run only with hooks set.
+//
+// Hooks and the shell tool also see the listed environment variables, and
+// run without network if no hosts are listed.
+
+// toolScope is the tools scope the permissions allow
+func (p *PermissionsSpec) toolScope() tools.Scope {
+	return tools.Scope{
+		Shell:   p.Shell,
+		Read:    p.Filesystem.Read,
+		Write:   p.Filesystem.Write,
+		Network: p.Network,
+	}
+}
+
+// readScope is what the command may read on its own, such as the default
+// of a file input: its filesystem.read paths or, without a permissions
+// block, the working directory
+func (p *PermissionsSpec) readScope() tools.Scope {
+	if p == nil {
+		return tools.Scope{Read: []string{"**"}}
+	}
+	return tools.Scope{Read: p.Filesystem.Read}
+}
+
+// execPolicy is how the command's hooks and shell tool run programs: as
+// configured, with the variables its permissions pass through, and without
+// network if they list no hosts
+func (c *PluginCommand) execPolicy(execCtx *command.ExecContext) sandbox.Policy {
+	var cfg *config.Config
+	if execCtx != nil {
+		cfg = execCtx.Config
+	}
+	policy := sandbox.FromConfig(cfg)
+	policy.Command = c.Name()
+	if p := c.spec.Permissions; p != nil {
+		policy.Env = append(policy.Env, p.Env...)
+		if len(p.Network) == 0 {
+			policy.NoNetwork = true
+		}
+	}
+	return policy
+}
+
+// allowsHooks reports whether the command's hooks may run
+func (s *CommandSpec) allowsHooks() bool {
+	return s.Permissions == nil || s.Permissions.Hooks
+}
+
+// hookSummary describes each of the command's hooks, one line each
+func (s *CommandSpec) hookSummary() []string {
+	if s.Hooks == nil {
+		return nil
+	}
+	var lines []string
+	for _, h := range append(append([]HookAction{}, s.Hooks.Pre...), s.Hooks.Post...) {
+		switch {
+		case h.Shell != "":
+			lines = append(lines, "run hook: "+strings.TrimSpace(h.Shell))
+		case h.Command != "":
+			lines = append(lines, "run command hook: "+strings.TrimSpace(h.Command))
+		}
+	}
+	return lines
+}
+
+// PermissionSummary describes what the command may do, one line each, for
+// the user to agree to. It is empty for a command that may do nothing.
+func (s *CommandSpec) PermissionSummary() []string {
+	var lines []string
+	p := s.Permissions
+	if p == nil {
+		lines = append(lines,
+			"no permissions declared, so it may use every built-in tool:",
+			"  run allowlisted shell commands (asking first)",
+			"  read any file",
+			"  write any file (asking first)",
+			"  fetch any URL",
+		)
+	} else {
+		if len(p.Shell) > 0 {
+			lines = append(lines, "run: "+strings.Join(p.Shell, ", "))
+		}
+		if len(p.Filesystem.Read) > 0 {
+			lines = append(lines, "read: "+strings.Join(p.Filesystem.Read, ", "))
+		}
+		if len(p.Filesystem.Write) > 0 {
+			lines = append(lines, "write: "+strings.Join(p.Filesystem.Write, ", "))
+		}
+		if len(p.Network) > 0 {
+			lines = append(lines, "fetch from: "+strings.Join(p.Network, ", "))
+		}
+		if len(p.Env) > 0 {
+			lines = append(lines, "see environment variables: "+strings.Join(p.Env, ", "))
+		}
+	}
+
+	if s.allowsHooks() {
+		lines = append(lines, s.hookSummary()...)
+	}
+	return lines
+}
@@ -0,0 +1,111 @@
+package repos
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+
+	"github.com/scmd/scmd/internal/backend/mock"
+	"github.com/scmd/scmd/internal/command"
+	"github.com/scmd/scmd/internal/config"
+	"github.com/scmd/scmd/internal/sandbox"
+)
+
+func TestCommandSpec_PermissionSummary(t *testing.T) {
+	var spec CommandSpec
+	require.NoError(t, yaml.Unmarshal([]byte(`
+name: changelog
+permissions:
+  shell: [git]
+  filesystem:
+    read: ["**/*.go"]
+    write: [CHANGELOG.md]
+  network: [api.github.com]
+  hooks: true
+hooks:
+  pre:
+    - shell: git fetch --tags
+    - command: summarize
+`), &spec))
+
+	assert.Equal(t, []string{
+		"run: git",
+		"read: **/*.go",
+		"write: CHANGELOG.md",
+		"fetch from: api.github.com",
+		"run hook: git fetch --tags",
+		"run command hook: summarize",
+	}, spec.PermissionSummary())
+
+	// Hooks that won't run aren't listed, and nothing is asked for
+	spec.Permissions = &PermissionsSpec{}
+	assert.Empty(t, spec.PermissionSummary())
+
+	spec.Permissions = nil
+	assert.Contains(t, spec.PermissionSummary(), "run hook: git fetch --tags")
+	assert.Contains(t, spec.PermissionSummary()[0], "no permissions declared")
+}
+
+func TestPluginCommand_PermissionsBlockHooks(t *testing.T) {
+	spec := hooksSpec(&HooksSpec{Pre: []HookAction{{ID: "info", Shell: "echo hi"}}})
+	spec.Permissions = &PermissionsSpec{Shell: []string{"git"}}
+
+	result, err := NewPluginCommand(spec).Execute(context.Background(), command.NewArgs(), &command.ExecContext{Backend: mock.New()})
+	require.NoError(t, err)
+	assert.False(t, result.Success)
+	assert.Equal(t, "pre-hook failed: hook info: hooks are not allowed by the command's permissions (set permissions.hooks)", result.Error)
+
+	// Command hooks are held back the same way
+	commandHook := hooksSpec(&HooksSpec{Pre: []HookAction{{ID: "kill", Command: "kill-process sshd"}}})
+	commandHook.Permissions = &PermissionsSpec{}
+	result, err = NewPluginCommand(commandHook).Execute(context.Background(), command.NewArgs(),
+		&command.ExecContext{Backend: mock.New(), Registry: command.NewRegistry()})
+	require.NoError(t, err)
+	assert.Equal(t, "pre-hook failed: hook kill: hooks are not allowed by the command's permissions (set permissions.hooks)", result.Error)
+
+	spec.Permissions.Hooks = true
+	result, err = NewPluginCommand(spec).Execute(context.Background(), command.NewArgs(), &command.ExecContext{Backend: mock.New()})
+	require.NoError(t, err)
+	assert.True(t, result.Success, result.Error)
+}
+
+func TestPluginCommand_ToolRegistry(t *testing.T) {
+	names := func(spec *CommandSpec) []string {
+		list := NewPluginCommand(spec).toolRegistry(nil, sandbox.DefaultPolicy()).List()
+		sort.Strings(list)
+		return list
+	}
+
+	assert.Equal(t, []string{"http_get", "read_file", "shell", "write_file"}, names(&CommandSpec{Name: "legacy"}))
+	assert.Empty(t, names(&CommandSpec{Name: "none", Permissions: &PermissionsSpec{Hooks: true}}))
+	assert.Equal(t, []string{"read_file", "shell"}, names(&CommandSpec{Name: "scoped", Permissions: &PermissionsSpec{
+		Shell:      []string{"git"},
+		Filesystem: FilesystemPermissions{Read: []string{"**"}},
+	}}))
+}
+
+func TestPluginCommand_ExecPolicy(t *testing.T) {
+	cfg := config.Default()
+	cfg.Exec.Env = []string{"GOPATH"}
+	execCtx := &command.ExecContext{Config: cfg}
+
+	legacy := NewPluginCommand(&CommandSpec{Name: "legacy"}).execPolicy(execCtx)
+	assert.Equal(t, "legacy", legacy.Command)
+	assert.Equal(t, []string{"GOPATH"}, legacy.Env)
+	assert.False(t, legacy.NoNetwork)
+
+	offline := NewPluginCommand(&CommandSpec{Name: "offline", Permissions: &PermissionsSpec{
+		Env: []string{"GITHUB_TOKEN"},
+	}}).execPolicy(execCtx)
+	assert.Equal(t, []string{"GOPATH", "GITHUB_TOKEN"}, offline.Env)
+	assert.True(t, offline.NoNetwork)
+
+	online := NewPluginCommand(&CommandSpec{Name: "online", Permissions: &PermissionsSpec{
+		Network: []string{"api.github.com"},
+	}}).execPolicy(execCtx)
+	assert.False(t, online.NoNetwork)
+}
@@ -0,0 +1,229 @@
+package repos
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// ProjectDirName is the directory a repository keeps its own commands in
+const ProjectDirName = ".scmd"
+
+// Command scopes
+const (
+	ScopeGlobal  = "global"  // installed in the data directory
+	ScopeProject = "project" // from the current project's .scmd directory
+)
+
+// Project is a directory tree with project-local commands in
+// .scmd/commands/*.yaml and slash commands in .scmd/slash.yaml
+type Project struct {
+	Root string // the directory holding .scmd
+}
+
+// FindProject walks up from dir looking for a .scmd directory with commands
+// or slash commands. dataDir, often ~/.scmd itself, is never a project. It
+// returns nil when there is no project.
+func FindProject(dir, dataDir string) (*Project, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	if dataDir != "" {
+		if dataDir, err = filepath.Abs(dataDir); err != nil {
+			return nil, err
+		}
+	}
+
+	for {
+		p := &Project{Root: dir}
+		if p.Dir() != dataDir && p.exists() {
+			return p, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil, nil
+		}
+		dir = parent
+	}
+}
+
+// exists reports whether the project has commands or slash commands
+func (p *Project) exists() bool {
+	if info, err := os.Stat(p.CommandsDir()); err == nil && info.IsDir() {
+		return true
+	}
+	_, err := os.Stat(p.SlashFile())
+	return err == nil
+}
+
+// Dir returns the project's .scmd directory
+func (p *Project) Dir() string {
+	return filepath.Join(p.Root, ProjectDirName)
+}
+
+// CommandsDir returns the directory holding the project's command specs
+func (p *Project) CommandsDir() string {
+	return filepath.Join(p.Dir(), "commands")
+}
+
+// SlashFile returns the path to the project's slash command config
+func (p *Project) SlashFile() string {
+	return filepath.Join(p.Dir(), "slash.yaml")
+}
+
+// Hash returns a hash of the project's command specs and slash config, so
+// that trust is given to particular contents
+func (p *Project) Hash() (string, error) {
+	files := []string{p.SlashFile()}
+	entries, err := os.ReadDir(p.CommandsDir())
+	if err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+	for _, e := range entries {
+		if !e.IsDir() && filepath.Ext(e.Name()) == ".yaml" {
+			files = append(files, filepath.Join(p.CommandsDir(), e.Name()))
+		}
+	}
+	sort.Strings(files)
+
+	h := sha256.New()
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return "", err
+		}
+		rel, _ := filepath.Rel(p.Dir(), file)
+		fmt.Fprintf(h, "%s\x00%d\x00", filepath.ToSlash(rel), len(data))
+		h.Write(data)
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// TrustedProject is a project whose commands the user has allowed to run
+// hooks
+type TrustedProject struct {
+	Root      string    `json:"root"`
+	Hash      string    `json:"hash"`
+	TrustedAt time.Time `json:"trusted_at"`
+}
+
+// ErrProjectNotTrusted is returned when a project command would run hooks
+// in a project the user has not trusted
+var ErrProjectNotTrusted = errors.New("project not trusted")
+
+// projectsFile returns the path to trusted_projects.json
+func (m *Manager) projectsFile() string {
+	return filepath.Join(m.dataDir, "trusted_projects.json")
+}
+
+func (m *Manager) loadProjects() error {
+	data, err := os.ReadFile(m.projectsFile())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	var projects []*TrustedProject
+	if err := json.Unmarshal(data, &projects); err != nil {
+		return fmt.Errorf("parse trusted projects: %w", err)
+	}
+	for _, p := range projects {
+		m.projects[p.Root] = p
+	}
+	return nil
+}
+
+func (m *Manager) saveProjects() error {
+	projects := make([]*TrustedProject, 0, len(m.projects))
+	for _, p := range m.projects {
+		projects = append(projects, p)
+	}
+	sort.Slice(projects, func(i, j int) bool { return projects[i].Root < projects[j].Root })
+
+	data, err := json.MarshalIndent(projects, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(m.projectsFile(), data, 0600)
+}
+
+// SaveProjects saves the trusted projects
+func (m *Manager) SaveProjects() error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if err := os.MkdirAll(m.dataDir, 0755); err != nil {
+		return err
+	}
+	return m.saveProjects()
+}
+
+// TrustProject trusts the project's commands as they are now
+func (m *Manager) TrustProject(p *Project) error {
+	hash, err := p.Hash()
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.projects[p.Root] = &TrustedProject{Root: p.Root, Hash: hash, TrustedAt: time.Now()}
+	return nil
+}
+
+// UntrustProject forgets that a project was trusted
+func (m *Manager) UntrustProject(root string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.projects[root]; !ok {
+		return fmt.Errorf("project '%s' is not trusted", root)
+	}
+	delete(m.projects, root)
+	return nil
+}
+
+// ProjectTrust reports whether the project is trusted. A project trusted
+// before its commands changed is not, and changed is true.
+func (m *Manager) ProjectTrust(p *Project) (trusted, changed bool, err error) {
+	m.mu.RLock()
+	t, ok := m.projects[p.Root]
+	m.mu.RUnlock()
+	if !ok {
+		return false, false, nil
+	}
+
+	hash, err := p.Hash()
+	if err != nil {
+		return false, false, err
+	}
+	if hash != t.Hash {
+		return false, true, nil
+	}
+	return true, false, nil
+}
+
+// TrustedProjects returns all trusted projects, sorted by root
+func (m *Manager) TrustedProjects() []*TrustedProject {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	projects := make([]*TrustedProject, 0, len(m.projects))
+	for _, p := range m.projects {
+		projects = append(projects, p)
+	}
+	sort.Slice(projects, func(i, j int) bool { return projects[i].Root < projects[j].Root })
+	return projects
+}
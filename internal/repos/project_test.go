@@ -0,0 +1,147 @@
+package repos
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/scmd/scmd/internal/backend/mock"
+	"github.com/scmd/scmd/internal/command"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+}
+
+func TestFindProject(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "app", ".scmd", "slash.yaml"), "commands: []\n")
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "app", "src", "pkg"), 0755))
+
+	p, err := FindProject(filepath.Join(root, "app", "src", "pkg"), "")
+	require.NoError(t, err)
+	require.NotNil(t, p)
+	assert.Equal(t, filepath.Join(root, "app"), p.Root)
+
+	p, err = FindProject(root, "")
+	require.NoError(t, err)
+	assert.Nil(t, p)
+
+	t.Run("data directory is not a project", func(t *testing.T) {
+		home := t.TempDir()
+		require.NoError(t, os.MkdirAll(filepath.Join(home, ".scmd", "commands"), 0755))
+
+		p, err := FindProject(home, filepath.Join(home, ".scmd"))
+		require.NoError(t, err)
+		assert.Nil(t, p)
+	})
+}
+
+func TestManager_ProjectTrust(t *testing.T) {
+	p := &Project{Root: t.TempDir()}
+	spec := filepath.Join(p.CommandsDir(), "notes.yaml")
+	writeFile(t, spec, "name: notes\n")
+
+	dataDir := t.TempDir()
+	m := NewManager(dataDir)
+
+	trusted, changed, err := m.ProjectTrust(p)
+	require.NoError(t, err)
+	assert.False(t, trusted)
+	assert.False(t, changed)
+
+	require.NoError(t, m.TrustProject(p))
+	require.NoError(t, m.SaveProjects())
+
+	loaded := NewManager(dataDir)
+	require.NoError(t, loaded.Load())
+	trusted, _, err = loaded.ProjectTrust(p)
+	require.NoError(t, err)
+	assert.True(t, trusted)
+
+	writeFile(t, spec, "name: notes\ndescription: changed\n")
+	trusted, changed, err = loaded.ProjectTrust(p)
+	require.NoError(t, err)
+	assert.False(t, trusted)
+	assert.True(t, changed)
+
+	require.NoError(t, loaded.UntrustProject(p.Root))
+	assert.Empty(t, loaded.TrustedProjects())
+	assert.ErrorContains(t, loaded.UntrustProject(p.Root), "is not trusted")
+}
+
+func TestLoader_Project(t *testing.T) {
+	installDir := t.TempDir()
+	writeFile(t, filepath.Join(installDir, "notes.yaml"), "name: notes\ndescription: global\nprompt:\n  template: global\n")
+	writeFile(t, filepath.Join(installDir, "other.yaml"), "name: other\nprompt:\n  template: other\n")
+
+	p := &Project{Root: t.TempDir()}
+	writeFile(t, filepath.Join(p.CommandsDir(), "notes.yaml"), `name: notes
+description: project
+prompt:
+  template: "project {{.hooks.marker.output}}"
+hooks:
+  pre:
+    - id: marker
+      shell: echo hooked
+`)
+
+	dataDir := t.TempDir()
+	m := NewManager(dataDir)
+	loader := NewLoader(m, installDir)
+	loader.SetProject(p)
+
+	registry := command.NewRegistry()
+	require.NoError(t, loader.RegisterAll(registry))
+
+	cmd, ok := registry.Get("notes")
+	require.True(t, ok)
+	notes := cmd.(*PluginCommand)
+	assert.Equal(t, "project", notes.Description())
+	assert.Equal(t, ScopeProject, notes.Scope())
+
+	other, ok := registry.Get("other")
+	require.True(t, ok)
+	assert.Equal(t, ScopeGlobal, other.(*PluginCommand).Scope())
+
+	run := func(answer string) *command.Result {
+		notes.promptInput = strings.NewReader(answer)
+		notes.promptOutput = io.Discard
+		result, err := notes.Execute(context.Background(), command.NewArgs(), &command.ExecContext{Backend: mock.New()})
+		require.NoError(t, err)
+		return result
+	}
+
+	t.Run("hooks wait for trust", func(t *testing.T) {
+		result := run("n\n")
+		assert.False(t, result.Success)
+		assert.Contains(t, result.Error, "project not trusted")
+
+		trusted, _, err := m.ProjectTrust(p)
+		require.NoError(t, err)
+		assert.False(t, trusted)
+	})
+
+	t.Run("trusting runs them and is remembered", func(t *testing.T) {
+		result := run("y\n")
+		assert.True(t, result.Success, result.Error)
+
+		loaded := NewManager(dataDir)
+		require.NoError(t, loaded.Load())
+		trusted, _, err := loaded.ProjectTrust(p)
+		require.NoError(t, err)
+		assert.True(t, trusted)
+
+		// Not asked again
+		result = run("")
+		assert.True(t, result.Success, result.Error)
+	})
+}
@@ -0,0 +1,669 @@
+package repos
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ErrUnresolvable is returned when no set of versions satisfies every
+// dependency constraint
+var ErrUnresolvable = errors.New("cannot resolve dependencies")
+
+// ResolvedCmd is a command version chosen by the resolver
+type ResolvedCmd struct {
+	Repo         *Repository
+	Name         string
+	Version      string
+	File         string
+	Spec         *CommandSpec
+	Data         []byte
+	Dependencies []string // resolved dependencies, as repo/name
+	Installed    bool     // the installed version was kept
+}
+
+// Key returns the command as repo/name
+func (r *ResolvedCmd) Key() string {
+	return r.Repo.Name + "/" + r.Name
+}
+
+// Resolution is the resolved dependency graph of a command
+type Resolution struct {
+	Dependencies []string       // the command's own dependencies, as repo/name
+	Commands     []*ResolvedCmd // every dependency, after its own dependencies
+	Skipped      []string       // optional dependencies left out, and why
+}
+
+// Resolver picks a version of every command a command depends on,
+// transitively. The newest version that satisfies every constraint on a
+// command wins; earlier choices are revisited when a later constraint rules
+// them out.
+type Resolver struct {
+	manager *Manager
+
+	// Installed returns the spec of an installed command. Installed versions
+	// that satisfy every constraint are kept rather than upgraded.
+	Installed func(repo, name string) (*CommandSpec, bool)
+
+	// Pins restricts commands, by repo/name, to one version
+	Pins map[string]string
+
+	manifests  map[string]*Manifest
+	candidates map[string]*candidateList
+}
+
+// NewResolver creates a resolver that reads repository manifests through m
+func NewResolver(m *Manager) *Resolver {
+	return &Resolver{
+		manager:    m,
+		manifests:  make(map[string]*Manifest),
+		candidates: make(map[string]*candidateList),
+	}
+}
+
+// candidate is one version of a command
+type candidate struct {
+	version   string
+	parsed    Version
+	valid     bool // version parsed
+	file      string
+	installed bool
+	spec      *CommandSpec
+	data      []byte
+}
+
+// candidateList is the versions of a command available to the resolver, in
+// order of preference
+type candidateList struct {
+	repo    *Repository
+	list    []*candidate
+	missing string // why the command is unavailable
+}
+
+// dependency is a parsed Dependency
+type dependency struct {
+	key        string
+	constraint *Constraint
+}
+
+// requirement is a constraint on a command and who placed it
+type requirement struct {
+	from       string
+	constraint *Constraint
+}
+
+// InstalledFrom returns a Resolver.Installed for commands recorded as
+// installed in cache and saved in installDir
+func InstalledFrom(cache *Cache, installDir string) func(repo, name string) (*CommandSpec, bool) {
+	return func(repo, name string) (*CommandSpec, bool) {
+		cache.mu.RLock()
+		cached, ok := cache.manifest.Commands[repo+"/"+name]
+		cache.mu.RUnlock()
+		if !ok || cached.InstalledAt.IsZero() {
+			return nil, false
+		}
+
+		data, err := os.ReadFile(filepath.Join(installDir, name+".yaml"))
+		if err != nil {
+			return nil, false
+		}
+		var spec CommandSpec
+		if err := yaml.Unmarshal(data, &spec); err != nil {
+			return nil, false
+		}
+		return &spec, true
+	}
+}
+
+// Resolve resolves the dependencies of spec, a command from the repository
+// named repo. Dependencies are written repo/command, or just command for one
+// from the same repository. Optional dependencies are left out when the
+// command or a version matching their constraint is not available.
+func (r *Resolver) Resolve(ctx context.Context, repo string, spec *CommandSpec) (*Resolution, error) {
+	root := spec.Name
+	if repo != "" {
+		root = repo + "/" + spec.Name
+	}
+
+	s := &solver{
+		r:        r,
+		ctx:      ctx,
+		selected: make(map[string]*candidate),
+		reqs:     make(map[string][]requirement),
+		edges:    make(map[string][]string),
+		skipped:  make(map[string]string),
+	}
+
+	rootCand := &candidate{version: spec.Version, spec: spec}
+	rootCand.parsed, rootCand.valid = parseCandidate(spec.Version)
+	s.selected[root] = rootCand
+
+	deps, err := s.dependencies(root, rootCand)
+	if err != nil {
+		return nil, err
+	}
+	if !s.choose(root, rootCand, deps, nil) {
+		return nil, s.failure()
+	}
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	if cycle := s.findCycle(root); cycle != nil {
+		return nil, fmt.Errorf("%w: dependency cycle %s", ErrUnresolvable, strings.Join(cycle, " -> "))
+	}
+	return s.resolution(root), nil
+}
+
+// solver is the state of one resolution
+type solver struct {
+	r        *Resolver
+	ctx      context.Context
+	selected map[string]*candidate
+	reqs     map[string][]requirement
+	edges    map[string][]string
+	skipped  map[string]string // optional dependency -> why it was left out
+
+	conflict string // the first dead end, to explain failure
+	err      error  // a fetch or parse error; stops the search
+}
+
+// choose selects cand for key, adds its dependencies and solves the rest of
+// the queue. It undoes everything if that fails.
+func (s *solver) choose(key string, cand *candidate, deps []dependency, queue []string) bool {
+	s.selected[key] = cand
+	label := key + "@" + cand.version
+
+	var added, next []string
+	ok := true
+	for _, d := range deps {
+		s.reqs[d.key] = append(s.reqs[d.key], requirement{from: label, constraint: d.constraint})
+		added = append(added, d.key)
+		next = append(next, d.key)
+
+		if sel, chosen := s.selected[d.key]; chosen && !matches(sel, d.constraint) {
+			s.explainVersions(d.key, fmt.Sprintf("%s requires %s %s, but %s was chosen", label, d.key, d.constraint, sel.version))
+			ok = false
+			break
+		}
+	}
+	s.edges[key] = next
+
+	if ok && s.solve(append(append([]string{}, queue...), next...)) {
+		return true
+	}
+
+	for _, k := range added {
+		s.reqs[k] = s.reqs[k][:len(s.reqs[k])-1]
+	}
+	delete(s.edges, key)
+	delete(s.selected, key)
+	return false
+}
+
+// solve picks a version for each command in queue
+func (s *solver) solve(queue []string) bool {
+	if s.err != nil {
+		return false
+	}
+	if len(queue) == 0 {
+		return true
+	}
+	key, rest := queue[0], queue[1:]
+	if _, ok := s.selected[key]; ok {
+		return s.solve(rest)
+	}
+
+	cl, err := s.r.candidatesFor(s.ctx, key)
+	if err != nil {
+		s.err = err
+		return false
+	}
+	if cl.missing != "" {
+		s.note(s.requiredBy(key) + ", but " + cl.missing)
+		return false
+	}
+
+	// Commands are installed by name, so two repositories can't both
+	// provide one
+	_, name := splitKey(key)
+	for other := range s.selected {
+		if o, n := splitKey(other); n == name && o != "" && other != key {
+			s.note(fmt.Sprintf("%s and %s would both be installed as '%s'", other, key, name))
+			return false
+		}
+	}
+
+	tried := false
+	for _, cand := range cl.list {
+		if !s.allows(key, cand) {
+			continue
+		}
+		tried = true
+
+		deps, err := s.dependencies(key, cand)
+		if err != nil {
+			s.err = err
+			return false
+		}
+		if s.choose(key, cand, deps, rest) {
+			return true
+		}
+		if s.err != nil {
+			return false
+		}
+	}
+
+	if !tried {
+		s.explainVersions(key, "")
+	}
+	return false
+}
+
+// dependencies loads a candidate and parses its dependencies. Optional
+// dependencies that can't be met are recorded as skipped and dropped.
+func (s *solver) dependencies(key string, cand *candidate) ([]dependency, error) {
+	if err := s.r.load(s.ctx, key, cand); err != nil {
+		return nil, err
+	}
+
+	repo, _ := splitKey(key)
+	var deps []dependency
+	for _, d := range cand.spec.Dependencies {
+		depKey := d.Command
+		if !strings.Contains(depKey, "/") {
+			if repo == "" {
+				if d.Optional {
+					s.skipped[depKey] = "not in the form repo/command"
+					continue
+				}
+				return nil, fmt.Errorf("%s: dependency %q must be written repo/command", key, d.Command)
+			}
+			depKey = repo + "/" + depKey
+		}
+
+		c, err := ParseConstraint(d.Version)
+		if err != nil {
+			return nil, fmt.Errorf("%s@%s: dependency %s: %w", key, cand.version, d.Command, err)
+		}
+
+		if d.Optional {
+			if why, err := s.unavailable(depKey, c); err != nil {
+				return nil, err
+			} else if why != "" {
+				s.skipped[depKey] = why
+				continue
+			}
+		}
+		deps = append(deps, dependency{key: depKey, constraint: c})
+	}
+	return deps, nil
+}
+
+// unavailable explains why no version of key can satisfy c, or returns ""
+func (s *solver) unavailable(key string, c *Constraint) (string, error) {
+	cl, err := s.r.candidatesFor(s.ctx, key)
+	if err != nil {
+		return "", err
+	}
+	if cl.missing != "" {
+		return cl.missing, nil
+	}
+	for _, cand := range cl.list {
+		if matches(cand, c) {
+			return "", nil
+		}
+	}
+	return fmt.Sprintf("no version matches %s", c), nil
+}
+
+// allows reports whether cand satisfies every requirement on key
+func (s *solver) allows(key string, cand *candidate) bool {
+	for _, req := range s.reqs[key] {
+		if !matches(cand, req.constraint) {
+			return false
+		}
+	}
+	return true
+}
+
+func matches(cand *candidate, c *Constraint) bool {
+	if !cand.valid {
+		return c.String() == "*"
+	}
+	return c.Check(cand.parsed)
+}
+
+// requiredBy describes who requires key
+func (s *solver) requiredBy(key string) string {
+	var from []string
+	for _, req := range s.reqs[key] {
+		from = append(from, req.from)
+	}
+	return strings.Join(from, ", ") + " requires " + key
+}
+
+// note records the first dead end, to explain a failure
+func (s *solver) note(conflict string) {
+	if s.conflict == "" {
+		s.conflict = conflict
+	}
+}
+
+// explainVersions notes a dead end on the versions of key, listing the
+// constraints on it and the versions available. If some version satisfies
+// every constraint, backtracking may still find a way and nothing is noted.
+func (s *solver) explainVersions(key, lead string) {
+	if s.conflict != "" {
+		return
+	}
+
+	var b strings.Builder
+	if lead != "" {
+		b.WriteString(lead)
+	} else {
+		fmt.Fprintf(&b, "no version of %s satisfies every constraint", key)
+	}
+
+	cl := s.r.candidates[key]
+	if cl == nil {
+		s.conflict = b.String()
+		return
+	}
+	var available []string
+	for _, cand := range cl.list {
+		if s.allows(key, cand) {
+			return
+		}
+		available = append(available, cand.version)
+	}
+	for _, req := range s.reqs[key] {
+		fmt.Fprintf(&b, "\n  %s requires %s", req.from, req.constraint)
+	}
+	fmt.Fprintf(&b, "\n  available: %s", strings.Join(available, ", "))
+	s.conflict = b.String()
+}
+
+func (s *solver) failure() error {
+	if s.err != nil {
+		return s.err
+	}
+	if s.conflict == "" {
+		return ErrUnresolvable
+	}
+	return fmt.Errorf("%w: %s", ErrUnresolvable, s.conflict)
+}
+
+// findCycle returns a dependency cycle reachable from root, as the keys
+// along it with their versions
+func (s *solver) findCycle(root string) []string {
+	const (
+		visiting = 1
+		done     = 2
+	)
+	state := make(map[string]int)
+	var path []string
+
+	var visit func(key string) []string
+	visit = func(key string) []string {
+		state[key] = visiting
+		path = append(path, key)
+		for _, dep := range s.edges[key] {
+			switch state[dep] {
+			case visiting:
+				var cycle []string
+				for i := len(path) - 1; i >= 0; i-- {
+					if path[i] == dep {
+						for _, k := range append(path[i:], dep) {
+							cycle = append(cycle, k+"@"+s.selected[k].version)
+						}
+						return cycle
+					}
+				}
+			case 0:
+				if cycle := visit(dep); cycle != nil {
+					return cycle
+				}
+			}
+		}
+		path = path[:len(path)-1]
+		state[key] = done
+		return nil
+	}
+	return visit(root)
+}
+
+// resolution orders the chosen commands so each follows its dependencies
+func (s *solver) resolution(root string) *Resolution {
+	res := &Resolution{Dependencies: s.edges[root]}
+	seen := map[string]bool{root: true}
+
+	var visit func(key string)
+	visit = func(key string) {
+		for _, dep := range s.edges[key] {
+			if seen[dep] {
+				continue
+			}
+			seen[dep] = true
+			visit(dep)
+
+			cand := s.selected[dep]
+			_, name := splitKey(dep)
+			res.Commands = append(res.Commands, &ResolvedCmd{
+				Repo:         s.r.candidates[dep].repo,
+				Name:         name,
+				Version:      cand.version,
+				File:         cand.file,
+				Spec:         cand.spec,
+				Data:         cand.data,
+				Dependencies: s.edges[dep],
+				Installed:    cand.installed,
+			})
+		}
+	}
+	visit(root)
+
+	for key, why := range s.skipped {
+		res.Skipped = append(res.Skipped, key+": "+why)
+	}
+	sort.Strings(res.Skipped)
+	return res
+}
+
+// candidatesFor lists the versions of key, newest first, with an installed
+// version ahead of the rest
+func (r *Resolver) candidatesFor(ctx context.Context, key string) (*candidateList, error) {
+	if cl, ok := r.candidates[key]; ok {
+		return cl, nil
+	}
+	cl := &candidateList{}
+	r.candidates[key] = cl
+
+	repoName, name := splitKey(key)
+	repo, ok := r.manager.Get(repoName)
+	if !ok {
+		cl.missing = fmt.Sprintf("repository '%s' is not configured", repoName)
+		return cl, nil
+	}
+	cl.repo = repo
+
+	manifest, ok := r.manifests[repoName]
+	if !ok {
+		var err error
+		manifest, err = r.manager.FetchManifest(ctx, repo)
+		if err != nil {
+			return nil, fmt.Errorf("fetch manifest for %s: %w", repoName, err)
+		}
+		r.manifests[repoName] = manifest
+	}
+
+	var entry *Command
+	for i := range manifest.Commands {
+		if manifest.Commands[i].Name == name {
+			entry = &manifest.Commands[i]
+			break
+		}
+	}
+	if entry == nil {
+		cl.missing = fmt.Sprintf("'%s' is not in repository '%s'", name, repoName)
+		return cl, nil
+	}
+
+	files := map[string]bool{}
+	add := func(version, file string) error {
+		if file == "" || files[file] {
+			return nil
+		}
+		files[file] = true
+		cand := &candidate{version: version, file: file}
+		if version == "" {
+			// Unversioned manifest entries are fetched to find their version
+			if err := r.load(ctx, key, cand); err != nil {
+				return err
+			}
+		}
+		cand.parsed, cand.valid = parseCandidate(cand.version)
+		cl.list = append(cl.list, cand)
+		return nil
+	}
+	if err := add(entry.Version, entry.File); err != nil {
+		return nil, err
+	}
+	for _, v := range entry.Versions {
+		if err := add(v.Version, v.File); err != nil {
+			return nil, err
+		}
+	}
+
+	sort.SliceStable(cl.list, func(i, j int) bool {
+		a, b := cl.list[i], cl.list[j]
+		if a.valid != b.valid {
+			return a.valid
+		}
+		return a.valid && a.parsed.Compare(b.parsed) > 0
+	})
+
+	if pin, ok := r.Pins[key]; ok {
+		var pinned []*candidate
+		for _, cand := range cl.list {
+			if cand.version == pin {
+				pinned = append(pinned, cand)
+			}
+		}
+		cl.list = pinned
+	}
+
+	if r.Installed != nil {
+		if spec, ok := r.Installed(repoName, name); ok && (r.Pins[key] == "" || r.Pins[key] == spec.Version) {
+			inst := &candidate{version: spec.Version, spec: spec, installed: true}
+			inst.parsed, inst.valid = parseCandidate(spec.Version)
+			for _, cand := range cl.list {
+				if cand.version == inst.version && inst.file == "" {
+					inst.file = cand.file
+				}
+			}
+			cl.list = append([]*candidate{inst}, cl.list...)
+		}
+	}
+	return cl, nil
+}
+
+// Available lists the published versions of repo/name, newest first
+func (r *Resolver) Available(ctx context.Context, repo, name string) ([]string, error) {
+	cl, err := r.candidatesFor(ctx, repo+"/"+name)
+	if err != nil {
+		return nil, err
+	}
+	if cl.missing != "" {
+		return nil, fmt.Errorf("%s/%s: %s", repo, name, cl.missing)
+	}
+
+	var versions []string
+	for _, cand := range cl.list {
+		if !cand.installed {
+			versions = append(versions, cand.version)
+		}
+	}
+	return versions, nil
+}
+
+// Fetch fetches a published version of repo/name
+func (r *Resolver) Fetch(ctx context.Context, repo, name, version string) (*LockedSource, error) {
+	key := repo + "/" + name
+	cl, err := r.candidatesFor(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if cl.missing != "" {
+		return nil, fmt.Errorf("%s: %s", key, cl.missing)
+	}
+
+	for _, cand := range cl.list {
+		if cand.installed || cand.version != version {
+			continue
+		}
+		if err := r.load(ctx, key, cand); err != nil {
+			return nil, err
+		}
+		return &LockedSource{Repo: cl.repo, File: cand.file, Spec: cand.spec, Data: cand.data}, nil
+	}
+	return nil, fmt.Errorf("%s: version %s is not published", key, version)
+}
+
+func parseCandidate(version string) (Version, bool) {
+	v, err := ParseVersion(version)
+	return v, err == nil
+}
+
+// load fetches a candidate's command file, if not yet fetched
+func (r *Resolver) load(ctx context.Context, key string, cand *candidate) error {
+	if cand.spec != nil {
+		return nil
+	}
+	cl := r.candidates[key]
+	spec, data, err := r.manager.FetchCommandData(ctx, cl.repo, cand.file)
+	if err != nil {
+		return fmt.Errorf("fetch %s: %w", key, err)
+	}
+	if cand.version != "" && spec.Version != cand.version {
+		return fmt.Errorf("%s: manifest lists %s as version %s, but it is %s", key, cand.file, cand.version, spec.Version)
+	}
+	cand.version = spec.Version
+	cand.spec = spec
+	cand.data = data
+	return nil
+}
+
+// splitKey splits repo/name; a bare name has no repo
+func splitKey(key string) (repo, name string) {
+	if i := strings.Index(key, "/"); i >= 0 {
+		return key[:i], key[i+1:]
+	}
+	return "", key
+}
+
+// InstallResolution installs every resolved command not already installed,
+// dependencies first, and records installs and dependencies in cache, if
+// given
+func (m *Manager) InstallResolution(res *Resolution, installDir string, cache *Cache) error {
+	for _, rc := range res.Commands {
+		if !rc.Installed {
+			if err := m.InstallCommandData(rc.Spec, rc.Data, installDir); err != nil {
+				return fmt.Errorf("install %s: %w", rc.Key(), err)
+			}
+			if cache != nil {
+				cache.RecordInstall(rc.Repo, rc.File, rc.Spec, rc.Data)
+			}
+		}
+		if cache != nil {
+			cache.RecordDependencies(rc.Repo.Name, rc.Name, rc.Dependencies)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,189 @@
+package repos
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// depRepo serves commands given as "name@version" -> dependencies block,
+// listing every version of a command in the manifest
+func depRepo(t *testing.T, commands map[string]string) *httptest.Server {
+	files := map[string]string{}
+	versions := map[string][]string{}
+	for key, deps := range commands {
+		name, version, _ := strings.Cut(key, "@")
+		versions[name] = append(versions[name], version)
+		body := fmt.Sprintf("name: %s\nversion: %s\nprompt:\n  template: hi\n", name, version)
+		if deps != "" {
+			body += "dependencies:\n" + deps
+		}
+		files["/commands/"+name+"-"+version+".yaml"] = body
+	}
+
+	var manifest strings.Builder
+	manifest.WriteString("name: deps\ncommands:\n")
+	names := make([]string, 0, len(versions))
+	for name := range versions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(&manifest, "  - name: %s\n    file: commands/%s-%s.yaml\n    versions:\n", name, name, versions[name][0])
+		for _, v := range versions[name] {
+			fmt.Fprintf(&manifest, "      - version: %s\n        file: commands/%s-%s.yaml\n", v, name, v)
+		}
+	}
+	files["/scmd-repo.yaml"] = manifest.String()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := files[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func resolveIn(t *testing.T, commands map[string]string, spec *CommandSpec) (*Resolution, error) {
+	server := depRepo(t, commands)
+	m := NewManager(t.TempDir())
+	m.repos["deps"] = &Repository{Name: "deps", URL: server.URL, Enabled: true}
+	return NewResolver(m).Resolve(context.Background(), "deps", spec)
+}
+
+func resolved(res *Resolution) []string {
+	var out []string
+	for _, rc := range res.Commands {
+		out = append(out, rc.Key()+"@"+rc.Version)
+	}
+	return out
+}
+
+func TestResolver_Resolve(t *testing.T) {
+	commands := map[string]string{
+		"fmt@1.0.0":   "",
+		"fmt@1.4.0":   "",
+		"fmt@2.0.0":   "",
+		"lint@1.0.0":  "  - command: fmt\n    version: \">=1.0.0\"\n",
+		"lint@1.1.0":  "  - command: deps/fmt\n    version: ^1.2\n",
+		"style@1.0.0": "  - command: lint\n  - command: fmt\n    version: <2\n",
+	}
+
+	t.Run("newest compatible, dependencies first", func(t *testing.T) {
+		res, err := resolveIn(t, commands, &CommandSpec{Name: "review", Dependencies: []Dependency{
+			{Command: "deps/style"},
+		}})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"deps/fmt@1.4.0", "deps/lint@1.1.0", "deps/style@1.0.0"}, resolved(res))
+		assert.Equal(t, []string{"deps/style"}, res.Dependencies)
+		assert.Equal(t, []string{"deps/lint", "deps/fmt"}, res.Commands[2].Dependencies)
+	})
+
+	t.Run("backtracks", func(t *testing.T) {
+		// lint@1.1.0 needs fmt ^1.2, which rules out the pinned fmt
+		res, err := resolveIn(t, commands, &CommandSpec{Name: "review", Dependencies: []Dependency{
+			{Command: "fmt", Version: "1.0.0"},
+			{Command: "lint"},
+		}})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"deps/fmt@1.0.0", "deps/lint@1.0.0"}, resolved(res))
+	})
+
+	t.Run("conflict", func(t *testing.T) {
+		_, err := resolveIn(t, commands, &CommandSpec{Name: "review", Version: "3.0.0", Dependencies: []Dependency{
+			{Command: "fmt", Version: ">=2"},
+			{Command: "style"},
+		}})
+		assert.ErrorIs(t, err, ErrUnresolvable)
+		assert.ErrorContains(t, err, "deps/review@3.0.0 requires >=2")
+		assert.ErrorContains(t, err, "deps/style@1.0.0 requires <2")
+		assert.ErrorContains(t, err, "available: 2.0.0, 1.4.0, 1.0.0")
+	})
+
+	t.Run("missing and optional", func(t *testing.T) {
+		res, err := resolveIn(t, commands, &CommandSpec{Name: "review", Dependencies: []Dependency{
+			{Command: "deps/fmt", Version: ">=5", Optional: true},
+			{Command: "other/thing", Optional: true},
+		}})
+		require.NoError(t, err)
+		assert.Empty(t, res.Commands)
+		assert.Equal(t, []string{
+			"deps/fmt: no version matches >=5",
+			"other/thing: repository 'other' is not configured",
+		}, res.Skipped)
+
+		_, err = resolveIn(t, commands, &CommandSpec{Name: "review", Dependencies: []Dependency{{Command: "deps/nope"}}})
+		assert.ErrorContains(t, err, "deps/review@ requires deps/nope, but 'nope' is not in repository 'deps'")
+	})
+
+	t.Run("cycle", func(t *testing.T) {
+		_, err := resolveIn(t, map[string]string{
+			"a@1.0.0": "  - command: b\n",
+			"b@1.0.0": "  - command: a\n",
+		}, &CommandSpec{Name: "review", Dependencies: []Dependency{{Command: "a"}}})
+		assert.ErrorIs(t, err, ErrUnresolvable)
+		assert.ErrorContains(t, err, "dependency cycle deps/a@1.0.0 -> deps/b@1.0.0 -> deps/a@1.0.0")
+	})
+
+	t.Run("keeps installed", func(t *testing.T) {
+		server := depRepo(t, commands)
+		m := NewManager(t.TempDir())
+		m.repos["deps"] = &Repository{Name: "deps", URL: server.URL, Enabled: true}
+		r := NewResolver(m)
+		r.Installed = func(repo, name string) (*CommandSpec, bool) {
+			if name == "fmt" {
+				return &CommandSpec{Name: "fmt", Version: "1.2.0"}, true
+			}
+			return nil, false
+		}
+
+		res, err := r.Resolve(context.Background(), "deps", &CommandSpec{Name: "review", Dependencies: []Dependency{{Command: "lint"}}})
+		require.NoError(t, err)
+		// 1.2.0 satisfies lint's ^1.2, so it isn't upgraded to 1.4.0
+		assert.Equal(t, []string{"deps/fmt@1.2.0", "deps/lint@1.1.0"}, resolved(res))
+		assert.True(t, res.Commands[0].Installed)
+	})
+}
+
+func TestManager_InstallResolutionRecordsGraph(t *testing.T) {
+	dir := t.TempDir()
+	server := depRepo(t, map[string]string{
+		"fmt@1.0.0":  "",
+		"lint@1.0.0": "  - command: fmt\n",
+	})
+	m := NewManager(dir)
+	m.repos["deps"] = &Repository{Name: "deps", URL: server.URL, Enabled: true}
+
+	res, err := NewResolver(m).Resolve(context.Background(), "deps", &CommandSpec{Name: "review", Dependencies: []Dependency{{Command: "lint"}}})
+	require.NoError(t, err)
+	cache := NewCache(dir)
+	require.NoError(t, m.InstallResolution(res, dir, cache))
+	assert.FileExists(t, dir+"/fmt.yaml")
+	assert.FileExists(t, dir+"/lint.yaml")
+
+	lf := cache.GenerateLockfile()
+	require.Len(t, lf.Commands, 2)
+	assert.Equal(t, "lint", lf.Commands[1].Name)
+	assert.Equal(t, []string{"deps/fmt"}, lf.Commands[1].Dependencies)
+
+	locked, err := lf.Closure("deps", "lint")
+	require.NoError(t, err)
+	require.Len(t, locked, 2)
+	assert.Equal(t, "fmt", locked[0].Name)
+
+	lf.Commands = lf.Commands[1:]
+	_, err = lf.Closure("deps", "lint")
+	assert.ErrorIs(t, err, ErrNotLocked)
+	assert.ErrorContains(t, err, "deps/fmt (a dependency of deps/lint)")
+}
@@ -0,0 +1,197 @@
+package repos
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/scmd/scmd/internal/validation"
+)
+
+// Scaffold holds the choices made in scmd command new
+type Scaffold struct {
+	Name        string
+	Description string
+	Category    string
+	Inputs      []InputSpec
+	Stdin       bool // the command works on piped input
+	Prompt      PromptSpec
+	Model       ModelSpec
+}
+
+// Validate checks the command and input names
+func (s *Scaffold) Validate() error {
+	if err := validation.ValidateCommandName(s.Name); err != nil {
+		return err
+	}
+	seen := make(map[string]bool)
+	for _, in := range s.Inputs {
+		if err := ValidateInputName(in.Name); err != nil {
+			return err
+		}
+		if seen[in.Name] {
+			return fmt.Errorf("input %q is declared twice", in.Name)
+		}
+		seen[in.Name] = true
+		if in.inputType() == InputTypeChoice && len(in.Choices) == 0 {
+			return fmt.Errorf("choice input %q needs choices", in.Name)
+		}
+	}
+	return nil
+}
+
+// ValidateInputName checks that an input name can be used in templates, as
+// {{.name}} and {{.inputs.name}}
+func ValidateInputName(name string) error {
+	if !identPattern.MatchString(name) {
+		return fmt.Errorf("invalid input name %q: use letters, digits and underscores", name)
+	}
+	if templateBuiltins[name] {
+		return fmt.Errorf("input name %q is taken by a built-in template value", name)
+	}
+	return nil
+}
+
+// Spec builds a command spec from the scaffold. Without a prompt template,
+// one is written that uses every input, and stdin if the command reads it.
+// The spec gets a first test, for scmd command test, that checks a written
+// template gets the inputs.
+func (s *Scaffold) Spec() *CommandSpec {
+	spec := &CommandSpec{
+		Name:        s.Name,
+		Version:     "0.1.0",
+		Description: s.Description,
+		Category:    s.Category,
+		Inputs:      s.Inputs,
+		Prompt:      s.Prompt,
+		Model:       s.Model,
+	}
+
+	usage := "/" + s.Name
+	for _, in := range s.Inputs {
+		if in.Required {
+			usage += fmt.Sprintf(" --%s=<%s>", in.Name, in.Name)
+		} else {
+			usage += fmt.Sprintf(" [--%s=<%s>]", in.Name, in.Name)
+		}
+	}
+	if s.Stdin {
+		usage = "<input> | " + usage
+	}
+	spec.Usage = usage
+
+	generated := spec.Prompt.Template == ""
+	if generated {
+		spec.Prompt.Template = s.template()
+	}
+
+	// The example runs the command as its test does
+	test := s.test(generated)
+	example := "scmd /" + s.Name
+	for _, in := range s.Inputs {
+		if v, ok := test.Options[in.Name]; ok {
+			example += fmt.Sprintf(" --%s=%q", in.Name, v)
+		}
+	}
+	if s.Stdin {
+		example = "cat input.txt | " + example
+	}
+	spec.Examples = []string{example}
+	spec.Tests = []CommandTest{test}
+	return spec
+}
+
+// template writes a prompt template that uses the inputs and stdin
+func (s *Scaffold) template() string {
+	var sb strings.Builder
+	task := s.Description
+	if task == "" {
+		task = "Help with the following."
+	}
+	sb.WriteString(task + "\n")
+
+	if len(s.Inputs) > 0 {
+		sb.WriteString("\n")
+	}
+	for _, in := range s.Inputs {
+		label := in.Name
+		if in.Description != "" {
+			label = in.Description
+		}
+		if in.Required || in.Default != "" {
+			fmt.Fprintf(&sb, "%s: {{.inputs.%s}}\n", label, in.Name)
+		} else {
+			fmt.Fprintf(&sb, "{{with .inputs.%s}}%s: {{.}}\n{{end}}", in.Name, label)
+		}
+	}
+
+	if s.Stdin {
+		sb.WriteString("\nInput:\n{{.stdin}}\n")
+	}
+	return sb.String()
+}
+
+// test writes a test that gives every required input a value. With a
+// generated template, it checks they reach the prompt.
+func (s *Scaffold) test(checkPrompt bool) CommandTest {
+	t := CommandTest{
+		Name:     "renders the prompt",
+		Response: "Example response",
+		Expect:   TestExpect{Contains: []string{"Example response"}},
+	}
+
+	for _, in := range s.Inputs {
+		if !in.Required {
+			continue
+		}
+		value := "example " + in.Name
+		switch in.inputType() {
+		case InputTypeChoice:
+			if len(in.Choices) > 0 {
+				value = in.Choices[0]
+			}
+		case InputTypeFile:
+			// The prompt gets the file's contents
+			if t.Files == nil {
+				t.Files = make(map[string]string)
+			}
+			t.Files[in.Name+".txt"] = value
+			if t.Options == nil {
+				t.Options = make(map[string]string)
+			}
+			t.Options[in.Name] = in.Name + ".txt"
+			t.Expect.Prompt = append(t.Expect.Prompt, value)
+			continue
+		}
+		if t.Options == nil {
+			t.Options = make(map[string]string)
+		}
+		t.Options[in.Name] = value
+		t.Expect.Prompt = append(t.Expect.Prompt, value)
+	}
+
+	if s.Stdin {
+		t.Stdin = "example input"
+		t.Expect.Prompt = append(t.Expect.Prompt, "example input")
+	}
+	if !checkPrompt {
+		t.Expect.Prompt = nil
+	}
+	return t
+}
+
+// YAML renders the scaffold's spec as a command file
+func (s *Scaffold) YAML() ([]byte, error) {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(s.Spec()); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,60 @@
+package repos
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestScaffold(t *testing.T) {
+	s := &Scaffold{
+		Name:        "tone",
+		Description: "Rewrite text in a tone",
+		Stdin:       true,
+		Inputs: []InputSpec{
+			{Name: "tone", Type: InputTypeChoice, Choices: []string{"formal", "casual"}, Required: true},
+			{Name: "audience", Description: "Who reads it"},
+			{Name: "guide", Type: InputTypeFile, Required: true},
+		},
+		Model: ModelSpec{Temperature: 0.3},
+	}
+	require.NoError(t, s.Validate())
+
+	data, err := s.YAML()
+	require.NoError(t, err)
+	assert.Empty(t, (&Linter{}).LintSpec("tone.yaml", data))
+
+	var spec CommandSpec
+	require.NoError(t, yaml.Unmarshal(data, &spec))
+	assert.Equal(t, "0.1.0", spec.Version)
+	assert.Equal(t, "<input> | /tone --tone=<tone> [--audience=<audience>] --guide=<guide>", spec.Usage)
+	assert.Equal(t, []string{`cat input.txt | scmd /tone --tone="formal" --guide="guide.txt"`}, spec.Examples)
+	assert.Contains(t, spec.Prompt.Template, "{{with .inputs.audience}}Who reads it: {{.}}\n{{end}}")
+	assert.Equal(t, 0.3, spec.Model.Temperature)
+
+	// The scaffolded test passes as written
+	require.Len(t, spec.Tests, 1)
+	assert.Equal(t, []string{"formal", "example guide", "example input"}, spec.Tests[0].Expect.Prompt)
+	results := (&TestRunner{}).Run(context.Background(), &spec, t.TempDir())
+	require.Len(t, results, 1)
+	assert.True(t, results[0].Passed, "%v", results[0].Failures)
+
+	t.Run("own template", func(t *testing.T) {
+		s := &Scaffold{Name: "own", Inputs: []InputSpec{{Name: "topic", Required: true}}, Prompt: PromptSpec{Template: "Write a haiku"}}
+		spec := s.Spec()
+		assert.Equal(t, "Write a haiku", spec.Prompt.Template)
+		assert.Empty(t, spec.Tests[0].Expect.Prompt)
+		assert.Equal(t, map[string]string{"topic": "example topic"}, spec.Tests[0].Options)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		assert.Error(t, (&Scaffold{Name: "bad name"}).Validate())
+		assert.ErrorContains(t, (&Scaffold{Name: "x", Inputs: []InputSpec{{Name: "my-input"}}}).Validate(), "invalid input name")
+		assert.ErrorContains(t, (&Scaffold{Name: "x", Inputs: []InputSpec{{Name: "stdin"}}}).Validate(), "built-in template value")
+		assert.ErrorContains(t, (&Scaffold{Name: "x", Inputs: []InputSpec{{Name: "a"}, {Name: "a"}}}).Validate(), "declared twice")
+		assert.ErrorContains(t, (&Scaffold{Name: "x", Inputs: []InputSpec{{Name: "a", Type: InputTypeChoice}}}).Validate(), "needs choices")
+	})
+}
@@ -0,0 +1,325 @@
+package repos
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Version is a semantic version. Build metadata is ignored.
+type Version struct {
+	Major int
+	Minor int
+	Patch int
+	Pre   string // pre-release, e.g. "beta.1"
+}
+
+// ParseVersion parses a version such as 1.2.3, v1.2 or 2.0.0-rc.1. Missing
+// minor and patch numbers are zero.
+func ParseVersion(s string) (Version, error) {
+	v, n, err := parsePartial(s)
+	if err != nil {
+		return Version{}, err
+	}
+	if n == 0 {
+		return Version{}, fmt.Errorf("invalid version %q", s)
+	}
+	return v, nil
+}
+
+// parsePartial parses a version that may be cut short or end in a wildcard
+// (1.2, 1.x, *), returning how many numbers were given
+func parsePartial(s string) (Version, int, error) {
+	orig := s
+	s = strings.TrimPrefix(strings.TrimSpace(s), "v")
+	if i := strings.IndexByte(s, '+'); i >= 0 {
+		s = s[:i]
+	}
+
+	var v Version
+	if i := strings.IndexByte(s, '-'); i >= 0 {
+		v.Pre = s[i+1:]
+		s = s[:i]
+		if v.Pre == "" {
+			return Version{}, 0, fmt.Errorf("invalid version %q", orig)
+		}
+	}
+
+	parts := strings.Split(s, ".")
+	if len(parts) > 3 {
+		return Version{}, 0, fmt.Errorf("invalid version %q", orig)
+	}
+	nums := []*int{&v.Major, &v.Minor, &v.Patch}
+	n := 0
+	for i, p := range parts {
+		if p == "x" || p == "X" || p == "*" {
+			// Everything after a wildcard is a wildcard too
+			if v.Pre != "" || i < len(parts)-1 && !isWildcard(parts[i+1:]) {
+				return Version{}, 0, fmt.Errorf("invalid version %q", orig)
+			}
+			break
+		}
+		num, err := strconv.Atoi(p)
+		if err != nil || num < 0 {
+			return Version{}, 0, fmt.Errorf("invalid version %q", orig)
+		}
+		*nums[i] = num
+		n++
+	}
+	if v.Pre != "" && n < 3 {
+		return Version{}, 0, fmt.Errorf("invalid version %q: pre-releases need major.minor.patch", orig)
+	}
+	return v, n, nil
+}
+
+func isWildcard(parts []string) bool {
+	for _, p := range parts {
+		if p != "x" && p != "X" && p != "*" {
+			return false
+		}
+	}
+	return true
+}
+
+// String returns the version as major.minor.patch[-pre]
+func (v Version) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if v.Pre != "" {
+		s += "-" + v.Pre
+	}
+	return s
+}
+
+// Compare returns -1, 0 or 1 as v is lower than, equal to or higher than o.
+// Pre-releases sort before the release.
+func (v Version) Compare(o Version) int {
+	for _, d := range []int{v.Major - o.Major, v.Minor - o.Minor, v.Patch - o.Patch} {
+		if d != 0 {
+			return sign(d)
+		}
+	}
+
+	switch {
+	case v.Pre == o.Pre:
+		return 0
+	case v.Pre == "":
+		return 1
+	case o.Pre == "":
+		return -1
+	}
+
+	a, b := strings.Split(v.Pre, "."), strings.Split(o.Pre, ".")
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if c := comparePreIdent(a[i], b[i]); c != 0 {
+			return c
+		}
+	}
+	return sign(len(a) - len(b))
+}
+
+// comparePreIdent compares pre-release identifiers: numbers numerically and
+// before words, words lexically
+func comparePreIdent(a, b string) int {
+	an, aErr := strconv.Atoi(a)
+	bn, bErr := strconv.Atoi(b)
+	switch {
+	case aErr == nil && bErr == nil:
+		return sign(an - bn)
+	case aErr == nil:
+		return -1
+	case bErr == nil:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+func sign(n int) int {
+	switch {
+	case n < 0:
+		return -1
+	case n > 0:
+		return 1
+	}
+	return 0
+}
+
+// Constraint is a version constraint such as ">=1.0.0, <2.0.0" or
+// "^1.2 || ^2.0". Comparisons separated by commas or spaces must all hold;
+// "||" separates alternatives. Supported forms:
+//
+//	1.2.3  =1.2.3  !=1.2.3      exact
+//	>1.2  >=1.2  <2  <=2.0.1    comparisons
+//	1.2  1.2.x  1.*  *          wildcards
+//	~1.2.3                      >=1.2.3 <1.3.0
+//	^1.2.3                      >=1.2.3 <2.0.0 (^0.2.3 is <0.3.0)
+//	~>1.2                       >=1.2.0 <2.0.0 (~>1.2.3 is <1.3.0)
+//
+// Pre-releases only match comparisons that name a pre-release of the same
+// major.minor.patch.
+type Constraint struct {
+	raw  string
+	alts [][]comparison
+}
+
+type comparison struct {
+	op string
+	v  Version
+}
+
+// ParseConstraint parses a version constraint. An empty constraint matches
+// every version.
+func ParseConstraint(s string) (*Constraint, error) {
+	c := &Constraint{raw: strings.TrimSpace(s)}
+	for _, alt := range strings.Split(s, "||") {
+		fields := strings.Fields(strings.ReplaceAll(alt, ",", " "))
+		if len(fields) == 0 && strings.Contains(s, "||") {
+			return nil, fmt.Errorf("invalid constraint %q: empty alternative", s)
+		}
+
+		var comps []comparison
+		for i := 0; i < len(fields); i++ {
+			term := fields[i]
+			// Allow a space after the operator: ">= 1.0"
+			if strings.Trim(term, "=<>!~^") == "" && i+1 < len(fields) {
+				i++
+				term += fields[i]
+			}
+			cs, err := parseComparison(term)
+			if err != nil {
+				return nil, fmt.Errorf("invalid constraint %q: %w", s, err)
+			}
+			comps = append(comps, cs...)
+		}
+		c.alts = append(c.alts, comps)
+	}
+	return c, nil
+}
+
+// parseComparison expands one term into plain comparisons
+func parseComparison(term string) ([]comparison, error) {
+	op := ""
+	for _, o := range []string{"~>", ">=", "<=", "!=", "==", ">", "<", "=", "~", "^"} {
+		if strings.HasPrefix(term, o) {
+			op = o
+			break
+		}
+	}
+	v, n, err := parsePartial(term[len(op):])
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		switch op {
+		case "", "=", "==", ">=":
+			return nil, nil // matches everything
+		}
+		return nil, fmt.Errorf("%q needs a version", term)
+	}
+
+	upTo := func(next Version) []comparison {
+		return []comparison{{">=", v}, {"<", next}}
+	}
+	switch op {
+	case "", "=", "==":
+		if n == 3 {
+			return []comparison{{"=", v}}, nil
+		}
+		// A cut-short version is a wildcard: 1.2 is 1.2.x
+		return upTo(bump(v, n-1)), nil
+	case "!=", ">", ">=", "<", "<=":
+		return []comparison{{op, v}}, nil
+	case "~":
+		if n == 1 {
+			return upTo(bump(v, 0)), nil
+		}
+		return upTo(bump(v, 1)), nil
+	case "~>":
+		if n == 1 {
+			return upTo(bump(v, 0)), nil
+		}
+		return upTo(bump(v, n-2)), nil
+	case "^":
+		switch {
+		case v.Major > 0 || n == 1:
+			return upTo(bump(v, 0)), nil
+		case v.Minor > 0 || n == 2:
+			return upTo(bump(v, 1)), nil
+		default:
+			return upTo(bump(v, 2)), nil
+		}
+	}
+	return nil, fmt.Errorf("unknown operator in %q", term)
+}
+
+// bump returns the lowest release above v's major (0), minor (1) or patch (2)
+func bump(v Version, part int) Version {
+	switch part {
+	case 0:
+		return Version{Major: v.Major + 1}
+	case 1:
+		return Version{Major: v.Major, Minor: v.Minor + 1}
+	}
+	return Version{Major: v.Major, Minor: v.Minor, Patch: v.Patch + 1}
+}
+
+// Check reports whether v satisfies the constraint
+func (c *Constraint) Check(v Version) bool {
+	for _, alt := range c.alts {
+		if matchAll(alt, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchAll(comps []comparison, v Version) bool {
+	preAllowed := v.Pre == ""
+	for _, c := range comps {
+		cmp := v.Compare(c.v)
+		var ok bool
+		switch c.op {
+		case "=":
+			ok = cmp == 0
+		case "!=":
+			ok = cmp != 0
+		case ">":
+			ok = cmp > 0
+		case ">=":
+			ok = cmp >= 0
+		case "<":
+			ok = cmp < 0
+		case "<=":
+			ok = cmp <= 0
+		}
+		if !ok {
+			return false
+		}
+		if c.v.Pre != "" && c.v.Major == v.Major && c.v.Minor == v.Minor && c.v.Patch == v.Patch {
+			preAllowed = true
+		}
+	}
+	return preAllowed
+}
+
+// String returns the constraint as written
+func (c *Constraint) String() string {
+	if c.raw == "" {
+		return "*"
+	}
+	return c.raw
+}
+
+// checkVersionConstraint reports whether version satisfies constraint. An
+// unparseable version only satisfies an empty constraint.
+func checkVersionConstraint(version, constraint string) bool {
+	c, err := ParseConstraint(constraint)
+	if err != nil {
+		return false
+	}
+	v, err := ParseVersion(version)
+	if err != nil {
+		t := strings.TrimSpace(constraint)
+		return t == "" || t == "*"
+	}
+	return c.Check(v)
+}
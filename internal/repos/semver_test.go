@@ -0,0 +1,85 @@
+package repos
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseVersion(t *testing.T) {
+	v, err := ParseVersion("v1.2")
+	require.NoError(t, err)
+	assert.Equal(t, Version{Major: 1, Minor: 2}, v)
+
+	v, err = ParseVersion("2.0.0-rc.1+build.5")
+	require.NoError(t, err)
+	assert.Equal(t, "2.0.0-rc.1", v.String())
+
+	for _, bad := range []string{"", "x", "1.2.3.4", "1.a", "1.2-beta", "1.x.3"} {
+		_, err := ParseVersion(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestVersion_Compare(t *testing.T) {
+	ordered := []string{"0.9.9", "1.0.0-alpha", "1.0.0-alpha.1", "1.0.0-alpha.beta", "1.0.0-beta.2", "1.0.0-beta.11", "1.0.0", "1.0.1", "1.10.0"}
+	for i := 1; i < len(ordered); i++ {
+		a, _ := ParseVersion(ordered[i-1])
+		b, _ := ParseVersion(ordered[i])
+		assert.Equal(t, -1, a.Compare(b), "%s < %s", a, b)
+		assert.Equal(t, 1, b.Compare(a), "%s > %s", b, a)
+	}
+}
+
+func TestConstraint_Check(t *testing.T) {
+	tests := []struct {
+		constraint string
+		match      []string
+		noMatch    []string
+	}{
+		{"", []string{"0.0.1", "9.9.9"}, []string{"1.0.0-beta"}},
+		{"1.2.3", []string{"1.2.3"}, []string{"1.2.4"}},
+		{"1.2", []string{"1.2.0", "1.2.9"}, []string{"1.3.0"}},
+		{">=1.0.0", []string{"1.0.0", "3.0.0"}, []string{"0.9.0"}},
+		{">= 1.0, <2", []string{"1.5.0"}, []string{"2.0.0"}},
+		{"!=1.1.0 >1.0.0", []string{"1.2.0"}, []string{"1.1.0", "1.0.0"}},
+		{"1.x", []string{"1.0.0", "1.9.0"}, []string{"2.0.0"}},
+		{"~1.2.3", []string{"1.2.3", "1.2.9"}, []string{"1.3.0", "1.2.2"}},
+		{"~1", []string{"1.9.0"}, []string{"2.0.0"}},
+		{"^1.2.3", []string{"1.2.3", "1.9.0"}, []string{"2.0.0", "1.2.2"}},
+		{"^0.2.3", []string{"0.2.9"}, []string{"0.3.0"}},
+		{"^0.0.3", []string{"0.0.3"}, []string{"0.0.4"}},
+		{"~>1.2", []string{"1.2.0", "1.9.0"}, []string{"2.0.0"}},
+		{"~>1.2.3", []string{"1.2.5"}, []string{"1.3.0"}},
+		{"^1.0 || ^3.0", []string{"1.4.0", "3.1.0"}, []string{"2.0.0"}},
+		{">=1.0.0-beta.2", []string{"1.0.0-beta.3", "1.0.0", "1.1.0"}, []string{"1.0.0-beta.1", "1.1.0-beta.1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.constraint, func(t *testing.T) {
+			c, err := ParseConstraint(tt.constraint)
+			require.NoError(t, err)
+			for _, s := range tt.match {
+				v, _ := ParseVersion(s)
+				assert.True(t, c.Check(v), "%s should match %s", s, tt.constraint)
+			}
+			for _, s := range tt.noMatch {
+				v, _ := ParseVersion(s)
+				assert.False(t, c.Check(v), "%s should not match %s", s, tt.constraint)
+			}
+		})
+	}
+
+	for _, bad := range []string{">", "^x", "1.0 ||", ">=1.a"} {
+		_, err := ParseConstraint(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestCheckVersionConstraint(t *testing.T) {
+	assert.True(t, checkVersionConstraint("1.5.0", ">=1.0.0"))
+	assert.False(t, checkVersionConstraint("0.5.0", ">=1.0.0"))
+	assert.True(t, checkVersionConstraint("", "*"))
+	assert.False(t, checkVersionConstraint("latest", ">=1.0.0"))
+}
@@ -0,0 +1,178 @@
+package repos
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+)
+
+// Repositories are signed with minisign-compatible ed25519 keys. A repo
+// publishes its public key as scmd-repo.pub and a detached signature next to
+// every file it serves: scmd-repo.yaml.minisig, commands/foo.yaml.minisig.
+// Files are signed with the legacy (non-prehashed) algorithm, and the
+// trusted comment names the file's path in the repo, so a signed file can't
+// be served in place of another:
+//
+//	minisign -G -p scmd-repo.pub -s scmd-repo.key
+//	for f in scmd-repo.yaml commands/*.yaml; do
+//	  minisign -S -l -s scmd-repo.key -m "$f" -t "file:$f"
+//	done
+
+const (
+	// PublicKeyFile is the repo's public key, fetched on first use
+	PublicKeyFile = "scmd-repo.pub"
+
+	// SignatureExt is appended to a file's path to find its signature
+	SignatureExt = ".minisig"
+)
+
+// ErrSignature is returned when a file's signature does not verify
+var ErrSignature = errors.New("signature verification failed")
+
+var (
+	algEd25519   = [2]byte{'E', 'd'}
+	algPrehashed = [2]byte{'E', 'D'}
+)
+
+// PublicKey is a minisign ed25519 public key
+type PublicKey struct {
+	ID  [8]byte
+	Key ed25519.PublicKey
+}
+
+// ParsePublicKey reads a key in minisign format: either the contents of a
+// .pub file or just its base64 line
+func ParsePublicKey(text string) (*PublicKey, error) {
+	line := ""
+	for _, l := range strings.Split(text, "\n") {
+		l = strings.TrimSpace(l)
+		if l != "" && !strings.HasPrefix(l, "untrusted comment:") {
+			line = l
+			break
+		}
+	}
+	raw, err := base64.StdEncoding.DecodeString(line)
+	if err != nil || len(raw) != 2+8+ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid public key: expected a minisign ed25519 key")
+	}
+	if !bytes.Equal(raw[:2], algEd25519[:]) {
+		return nil, fmt.Errorf("invalid public key: unsupported algorithm %q", raw[:2])
+	}
+
+	key := &PublicKey{Key: ed25519.PublicKey(raw[10:])}
+	copy(key.ID[:], raw[2:10])
+	return key, nil
+}
+
+// LoadPublicKey parses a key given on the command line: a path to a .pub
+// file, or the key itself
+func LoadPublicKey(arg string) (*PublicKey, error) {
+	if data, err := os.ReadFile(arg); err == nil {
+		return ParsePublicKey(string(data))
+	}
+	return ParsePublicKey(arg)
+}
+
+// String returns the key's base64 line
+func (k *PublicKey) String() string {
+	raw := make([]byte, 0, 2+8+ed25519.PublicKeySize)
+	raw = append(raw, algEd25519[:]...)
+	raw = append(raw, k.ID[:]...)
+	raw = append(raw, k.Key...)
+	return base64.StdEncoding.EncodeToString(raw)
+}
+
+// KeyID returns the key ID the way minisign prints it
+func (k *PublicKey) KeyID() string {
+	return keyIDString(k.ID)
+}
+
+func keyIDString(id [8]byte) string {
+	// minisign prints the ID as a little-endian 64-bit number
+	var b strings.Builder
+	for i := len(id) - 1; i >= 0; i-- {
+		fmt.Fprintf(&b, "%02X", id[i])
+	}
+	return b.String()
+}
+
+// Signature is a parsed minisign signature file
+type Signature struct {
+	Algorithm      [2]byte
+	KeyID          [8]byte
+	Sig            []byte
+	TrustedComment string
+	GlobalSig      []byte
+}
+
+// ParseSignature reads a minisign signature file
+func ParseSignature(data []byte) (*Signature, error) {
+	lines := strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+	if len(lines) < 4 || !strings.HasPrefix(lines[0], "untrusted comment:") {
+		return nil, fmt.Errorf("invalid signature file")
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[1]))
+	if err != nil || len(raw) != 2+8+ed25519.SignatureSize {
+		return nil, fmt.Errorf("invalid signature")
+	}
+
+	comment, ok := strings.CutPrefix(lines[2], "trusted comment: ")
+	if !ok {
+		return nil, fmt.Errorf("invalid signature file: missing trusted comment")
+	}
+
+	global, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[3]))
+	if err != nil || len(global) != ed25519.SignatureSize {
+		return nil, fmt.Errorf("invalid global signature")
+	}
+
+	sig := &Signature{
+		Sig:            raw[10:],
+		TrustedComment: comment,
+		GlobalSig:      global,
+	}
+	copy(sig.Algorithm[:], raw[:2])
+	copy(sig.KeyID[:], raw[2:10])
+	return sig, nil
+}
+
+// SignedFile returns the path in the trusted comment's file: field, or ""
+// if it has none. Fields are separated by spaces or tabs.
+func (s *Signature) SignedFile() string {
+	for _, field := range strings.Fields(s.TrustedComment) {
+		if file, ok := strings.CutPrefix(field, "file:"); ok {
+			return path.Clean(file)
+		}
+	}
+	return ""
+}
+
+// Verify checks that sig is k's signature of msg, including the trusted
+// comment
+func (k *PublicKey) Verify(msg []byte, sig *Signature) error {
+	if sig.KeyID != k.ID {
+		return fmt.Errorf("%w: signed with key %s, expected %s", ErrSignature, keyIDString(sig.KeyID), k.KeyID())
+	}
+	switch sig.Algorithm {
+	case algEd25519:
+	case algPrehashed:
+		return fmt.Errorf("%w: prehashed signatures are not supported; sign with minisign -l", ErrSignature)
+	default:
+		return fmt.Errorf("%w: unsupported algorithm %q", ErrSignature, sig.Algorithm[:])
+	}
+
+	if !ed25519.Verify(k.Key, msg, sig.Sig) {
+		return fmt.Errorf("%w: content does not match its signature", ErrSignature)
+	}
+	global := append(append([]byte{}, sig.Sig...), sig.TrustedComment...)
+	if !ed25519.Verify(k.Key, global, sig.GlobalSig) {
+		return fmt.Errorf("%w: trusted comment was modified", ErrSignature)
+	}
+	return nil
+}
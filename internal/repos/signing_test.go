@@ -0,0 +1,221 @@
+package repos
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testSigner produces minisign-format keys and signatures
+type testSigner struct {
+	id   [8]byte
+	priv ed25519.PrivateKey
+	pub  *PublicKey
+}
+
+func newTestSigner(t *testing.T, id byte) *testSigner {
+	pubKey, priv, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	s := &testSigner{priv: priv}
+	s.id[0] = id
+	s.pub = &PublicKey{ID: s.id, Key: pubKey}
+	return s
+}
+
+func (s *testSigner) pubFile() string {
+	return "untrusted comment: minisign public key " + s.pub.KeyID() + "\n" + s.pub.String() + "\n"
+}
+
+func (s *testSigner) sign(msg []byte, comment string) []byte {
+	sig := ed25519.Sign(s.priv, msg)
+	raw := append(append(append([]byte{}, algEd25519[:]...), s.id[:]...), sig...)
+	global := ed25519.Sign(s.priv, append(append([]byte{}, sig...), comment...))
+	return []byte(fmt.Sprintf("untrusted comment: signature from minisign secret key\n%s\ntrusted comment: %s\n%s\n",
+		base64.StdEncoding.EncodeToString(raw), comment, base64.StdEncoding.EncodeToString(global)))
+}
+
+func TestParsePublicKey(t *testing.T) {
+	s := newTestSigner(t, 0xAB)
+
+	key, err := ParsePublicKey(s.pubFile())
+	require.NoError(t, err)
+	assert.Equal(t, s.pub.ID, key.ID)
+	assert.Equal(t, s.pub.Key, key.Key)
+	assert.Equal(t, "00000000000000AB", key.KeyID())
+
+	key, err = ParsePublicKey(s.pub.String())
+	require.NoError(t, err)
+	assert.Equal(t, s.pub.Key, key.Key)
+
+	_, err = ParsePublicKey("not a key")
+	assert.Error(t, err)
+}
+
+func TestPublicKey_Verify(t *testing.T) {
+	s := newTestSigner(t, 1)
+	msg := []byte("name: test\n")
+	sigFile := s.sign(msg, "timestamp:1 file:scmd-repo.yaml")
+
+	sig, err := ParseSignature(sigFile)
+	require.NoError(t, err)
+	assert.Equal(t, "timestamp:1 file:scmd-repo.yaml", sig.TrustedComment)
+	require.NoError(t, s.pub.Verify(msg, sig))
+
+	err = s.pub.Verify([]byte("name: evil\n"), sig)
+	assert.ErrorIs(t, err, ErrSignature)
+	assert.Contains(t, err.Error(), "content does not match")
+
+	tampered, _ := ParseSignature(sigFile)
+	tampered.TrustedComment = "timestamp:2 file:scmd-repo.yaml"
+	assert.ErrorContains(t, s.pub.Verify(msg, tampered), "trusted comment was modified")
+
+	other := newTestSigner(t, 2)
+	assert.ErrorContains(t, other.pub.Verify(msg, sig), "signed with key 0000000000000001, expected 0000000000000002")
+
+	prehashed, _ := ParseSignature(sigFile)
+	prehashed.Algorithm = algPrehashed
+	assert.ErrorContains(t, s.pub.Verify(msg, prehashed), "minisign -l")
+
+	_, err = ParseSignature([]byte("garbage"))
+	assert.Error(t, err)
+}
+
+// signedCommand is the command signedRepo serves
+const signedCommand = "name: hello\nversion: 1.0.0\nprompt:\n  template: hi\n"
+
+// signedRepo serves a manifest and command, signed by s, with overrides
+func signedRepo(t *testing.T, s *testSigner, files map[string]string) *httptest.Server {
+	manifest := "name: signed\ncommands:\n  - name: hello\n    file: commands/hello.yaml\n"
+	command := signedCommand
+	served := map[string]string{
+		"/scmd-repo.pub":               s.pubFile(),
+		"/scmd-repo.yaml":              manifest,
+		"/scmd-repo.yaml.minisig":      string(s.sign([]byte(manifest), "file:scmd-repo.yaml")),
+		"/commands/hello.yaml":         command,
+		"/commands/hello.yaml.minisig": string(s.sign([]byte(command), "timestamp:1\tfile:commands/hello.yaml")),
+	}
+	for k, v := range files {
+		if v == "" {
+			delete(served, k)
+		} else {
+			served[k] = v
+		}
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := served[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestManager_VerifiesSignedRepos(t *testing.T) {
+	s := newTestSigner(t, 7)
+	ctx := context.Background()
+
+	t.Run("valid", func(t *testing.T) {
+		server := signedRepo(t, s, nil)
+		m := NewManager(t.TempDir())
+		repo := &Repository{Name: "signed", URL: server.URL}
+
+		key, err := m.FetchPublicKey(ctx, repo)
+		require.NoError(t, err)
+		m.Trust("signed", key, TrustSourceTOFU)
+
+		manifest, err := m.FetchManifest(ctx, repo)
+		require.NoError(t, err)
+		spec, err := m.FetchCommand(ctx, repo, manifest.Commands[0].File)
+		require.NoError(t, err)
+		assert.Equal(t, "hello", spec.Name)
+	})
+
+	failures := map[string]struct {
+		files map[string]string
+		want  string
+	}{
+		"tampered manifest": {map[string]string{"/scmd-repo.yaml": "name: evil\n"}, "content does not match"},
+		"missing signature": {map[string]string{"/commands/hello.yaml.minisig": ""}, "commands/hello.yaml is not signed"},
+		"other file": {map[string]string{
+			"/commands/hello.yaml":         "name: other\n",
+			"/commands/hello.yaml.minisig": string(s.sign([]byte("name: other\n"), "file:commands/other.yaml")),
+		}, "commands/hello.yaml: signature is for commands/other.yaml"},
+		"no file": {map[string]string{
+			"/commands/hello.yaml.minisig": string(s.sign([]byte(signedCommand), "timestamp:1")),
+		}, "trusted comment does not name the file"},
+		"other key": {map[string]string{
+			"/scmd-repo.yaml.minisig": string(newTestSigner(t, 8).sign([]byte("x"), "x")),
+		}, "signed with key 0000000000000008"},
+	}
+	for name, tt := range failures {
+		t.Run(name, func(t *testing.T) {
+			server := signedRepo(t, s, tt.files)
+			m := NewManager(t.TempDir())
+			m.Trust("signed", s.pub, TrustSourceFlag)
+			repo := &Repository{Name: "signed", URL: server.URL}
+
+			_, err := m.FetchManifest(ctx, repo)
+			if err == nil {
+				_, err = m.FetchCommand(ctx, repo, "commands/hello.yaml")
+			}
+			assert.ErrorIs(t, err, ErrSignature)
+			assert.ErrorContains(t, err, tt.want)
+		})
+	}
+
+	t.Run("unsigned", func(t *testing.T) {
+		server := signedRepo(t, s, map[string]string{"/scmd-repo.pub": ""})
+		m := NewManager(t.TempDir())
+		repo := &Repository{Name: "plain", URL: server.URL}
+
+		key, err := m.FetchPublicKey(ctx, repo)
+		require.NoError(t, err)
+		assert.Nil(t, key)
+
+		_, err = m.FetchManifest(ctx, repo)
+		require.NoError(t, err)
+
+		t.Setenv("SCMD_REQUIRE_SIGNED_REPOS", "1")
+		_, err = m.FetchManifest(ctx, repo)
+		assert.ErrorIs(t, err, ErrSignature)
+	})
+}
+
+func TestManager_TrustPersistence(t *testing.T) {
+	dir := t.TempDir()
+	s := newTestSigner(t, 3)
+
+	m := NewManager(dir)
+	require.NoError(t, m.Add("signed", "https://example.com/repo"))
+	require.NoError(t, m.Add("other", "https://example.com/other"))
+	m.Trust("signed", s.pub, TrustSourceFlag)
+	m.Trust("other", s.pub, TrustSourceTOFU)
+	require.NoError(t, m.Save())
+
+	m2 := NewManager(dir)
+	require.NoError(t, m2.Load())
+	keys := m2.TrustedKeys()
+	require.Len(t, keys, 2)
+	assert.Equal(t, "other", keys[0].Repo)
+	assert.Equal(t, TrustSourceTOFU, keys[0].Source)
+	assert.Equal(t, s.pub.String(), keys[1].PublicKey)
+	assert.Equal(t, s.pub.KeyID(), keys[1].KeyID)
+
+	require.NoError(t, m2.Revoke("signed"))
+	assert.Error(t, m2.Revoke("signed"))
+	require.NoError(t, m2.Remove("other"))
+	assert.Empty(t, m2.TrustedKeys())
+	_, ok := m2.Get("other")
+	assert.False(t, ok)
+}
@@ -0,0 +1,217 @@
+package repos
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Repositories are fetched from one of three kinds of source:
+//
+//	https://example.com/commands          HTTP, file by file
+//	file:///home/me/commands              a local directory
+//	git+https://git.example.com/cmds#v2   a git repository, cloned with the
+//	git+ssh://git@host/cmds.git           local git binary and checked out
+//	git+file:///srv/git/cmds.git          at the ref after #, if any
+//
+// scmd repo add also takes a plain path, stored as a file:// URL.
+
+// Source kinds
+const (
+	SourceHTTP = "http"
+	SourceDir  = "dir"
+	SourceGit  = "git"
+)
+
+// SourceKind returns how a repository URL is fetched
+func SourceKind(src string) string {
+	switch {
+	case strings.HasPrefix(src, "file://"):
+		return SourceDir
+	case strings.HasPrefix(src, "git+"):
+		return SourceGit
+	}
+	return SourceHTTP
+}
+
+// NormalizeSource turns a local path into a file:// URL, leaving URLs as
+// they are
+func NormalizeSource(src string) (string, error) {
+	if strings.Contains(src, "://") {
+		return src, nil
+	}
+	if !strings.HasPrefix(src, ".") && !strings.HasPrefix(src, "/") && !strings.HasPrefix(src, "~") {
+		// Not a path; let validation explain what's wrong with it
+		if _, err := os.Stat(src); err != nil {
+			return src, nil
+		}
+	}
+
+	if rest, ok := strings.CutPrefix(src, "~"); ok {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		src = home + rest
+	}
+	abs, err := filepath.Abs(src)
+	if err != nil {
+		return "", err
+	}
+	info, err := os.Stat(abs)
+	if err != nil {
+		return "", err
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("%s is not a directory", src)
+	}
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(abs)}).String(), nil
+}
+
+// fetch reads a file from a repository, returning errNotFound if the
+// repository has no such file
+func (m *Manager) fetch(ctx context.Context, repo *Repository, path string) ([]byte, error) {
+	switch SourceKind(repo.URL) {
+	case SourceDir:
+		u, err := url.Parse(repo.URL)
+		if err != nil {
+			return nil, err
+		}
+		return readRepoFile(filepath.FromSlash(u.Path), path)
+	case SourceGit:
+		dir, err := m.gitCheckout(ctx, repo, false)
+		if err != nil {
+			return nil, err
+		}
+		return readRepoFile(dir, path)
+	}
+	return m.get(ctx, repo.URL+"/"+path)
+}
+
+// readRepoFile reads path from a repository directory. Paths, and the links
+// they follow, may not leave the directory.
+func readRepoFile(root, path string) ([]byte, error) {
+	clean := filepath.Clean(filepath.FromSlash(path))
+	if filepath.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return nil, fmt.Errorf("path %s leaves the repository", path)
+	}
+
+	rootReal, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return nil, fmt.Errorf("repository directory: %w", err)
+	}
+	real, err := filepath.EvalSymlinks(filepath.Join(rootReal, clean))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("%s: %w", path, errNotFound)
+		}
+		return nil, err
+	}
+	if rel, err := filepath.Rel(rootReal, real); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return nil, fmt.Errorf("path %s leaves the repository", path)
+	}
+
+	return os.ReadFile(real)
+}
+
+// Sync brings a repository's local copy up to date. Git repositories are
+// fetched and checked out at their ref; other sources are read directly and
+// need no sync.
+func (m *Manager) Sync(ctx context.Context, repo *Repository) error {
+	if SourceKind(repo.URL) != SourceGit {
+		return nil
+	}
+	_, err := m.gitCheckout(ctx, repo, true)
+	return err
+}
+
+// gitDir is where a git repository is checked out
+func (m *Manager) gitDir(repo *Repository) string {
+	return filepath.Join(m.dataDir, "git", repo.Name+"-"+hashURL(repo.URL))
+}
+
+// parseGitSource splits git+<remote>#<ref>
+func parseGitSource(src string) (remote, ref string) {
+	remote, ref, _ = strings.Cut(strings.TrimPrefix(src, "git+"), "#")
+	return remote, ref
+}
+
+// gitCheckout returns the checkout of a git repository, cloning it if
+// needed. With update, it fetches first.
+func (m *Manager) gitCheckout(ctx context.Context, repo *Repository, update bool) (string, error) {
+	remote, ref := parseGitSource(repo.URL)
+	dir := m.gitDir(repo)
+
+	if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+		if !update {
+			return dir, nil
+		}
+		if _, err := runGit(ctx, dir, "fetch", "--quiet", "--force", "--tags", "origin"); err != nil {
+			return "", err
+		}
+	} else {
+		// Start over after an interrupted clone
+		if err := os.RemoveAll(dir); err != nil {
+			return "", err
+		}
+		if err := os.MkdirAll(filepath.Dir(dir), 0755); err != nil {
+			return "", err
+		}
+		if _, err := runGit(ctx, "", "clone", "--quiet", "--no-checkout", "--", remote, dir); err != nil {
+			os.RemoveAll(dir)
+			return "", err
+		}
+	}
+
+	commit, err := resolveGitRef(ctx, dir, ref)
+	if err != nil {
+		return "", err
+	}
+	if _, err := runGit(ctx, dir, "checkout", "--quiet", "--force", "--detach", commit); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// resolveGitRef finds the commit for a branch, tag or commit; the remote's
+// default branch if ref is empty
+func resolveGitRef(ctx context.Context, dir, ref string) (string, error) {
+	names := []string{"origin/HEAD", "HEAD"}
+	if ref != "" {
+		names = []string{"origin/" + ref, "refs/tags/" + ref, ref}
+	}
+	for _, name := range names {
+		out, err := runGit(ctx, dir, "rev-parse", "--verify", "--quiet", name+"^{commit}")
+		if err == nil {
+			return strings.TrimSpace(out), nil
+		}
+	}
+	return "", fmt.Errorf("git ref '%s' not found", ref)
+}
+
+// runGit runs git in dir, never prompting for credentials
+func runGit(ctx context.Context, dir string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return "", fmt.Errorf("git repositories need git installed: %w", err)
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("git %s: %s", args[0], msg)
+		}
+		return "", fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return stdout.String(), nil
+}
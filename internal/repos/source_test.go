@@ -0,0 +1,126 @@
+package repos
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeRepoDir(t *testing.T, dir, version string) {
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "commands"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "scmd-repo.yaml"),
+		[]byte("name: local\ncommands:\n  - name: hello\n    file: commands/hello.yaml\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "commands", "hello.yaml"),
+		[]byte("name: hello\nversion: "+version+"\nprompt:\n  template: hi\n"), 0644))
+}
+
+func TestManager_DirectorySource(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	repoDir := filepath.Join(root, "commands")
+	writeRepoDir(t, repoDir, "1.0.0")
+	require.NoError(t, os.WriteFile(filepath.Join(root, "secret"), []byte("x"), 0644))
+	require.NoError(t, os.Symlink(filepath.Join(root, "secret"), filepath.Join(repoDir, "link.yaml")))
+
+	t.Chdir(root)
+	m := NewManager(t.TempDir())
+	require.NoError(t, m.Add("local", "./commands"))
+	repo, _ := m.Get("local")
+	assert.Equal(t, "file://"+filepath.ToSlash(repoDir), repo.URL)
+	assert.Equal(t, SourceDir, SourceKind(repo.URL))
+
+	manifest, err := m.FetchManifest(ctx, repo)
+	require.NoError(t, err)
+	spec, err := m.FetchCommand(ctx, repo, manifest.Commands[0].File)
+	require.NoError(t, err)
+	assert.Equal(t, "1.0.0", spec.Version)
+
+	// Edits show up without a sync
+	writeRepoDir(t, repoDir, "1.1.0")
+	spec, err = m.FetchCommand(ctx, repo, "commands/hello.yaml")
+	require.NoError(t, err)
+	assert.Equal(t, "1.1.0", spec.Version)
+
+	_, err = m.FetchCommand(ctx, repo, "../secret")
+	assert.ErrorContains(t, err, "leaves the repository")
+	_, err = m.FetchCommand(ctx, repo, "link.yaml")
+	assert.ErrorContains(t, err, "leaves the repository")
+	_, err = m.FetchCommand(ctx, repo, "commands/missing.yaml")
+	assert.ErrorIs(t, err, errNotFound)
+
+	assert.Error(t, m.Add("bad", "./nope"))
+	assert.Error(t, m.Add("file", filepath.Join(repoDir, "scmd-repo.yaml")))
+}
+
+func TestManager_GitSource(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	ctx := context.Background()
+	root := t.TempDir()
+	bare := filepath.Join(root, "commands.git")
+	work := filepath.Join(root, "work")
+
+	git := func(dir string, args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(),
+			"GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@example.com",
+			"GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@example.com",
+			"GIT_CONFIG_GLOBAL=/dev/null", "GIT_CONFIG_NOSYSTEM=1")
+		out, err := cmd.CombinedOutput()
+		require.NoError(t, err, string(out))
+	}
+	commit := func(version string) {
+		writeRepoDir(t, work, version)
+		git(work, "add", "-A")
+		git(work, "commit", "-q", "-m", version)
+		git(work, "push", "-q", "origin", "HEAD:main")
+	}
+
+	git(root, "init", "-q", "--bare", "-b", "main", bare)
+	git(root, "init", "-q", "-b", "main", work)
+	git(work, "remote", "add", "origin", bare)
+	commit("1.0.0")
+	git(work, "tag", "v1")
+	git(work, "push", "-q", "origin", "v1")
+
+	m := NewManager(t.TempDir())
+	require.NoError(t, m.Add("latest", "git+file://"+bare))
+	require.NoError(t, m.Add("pinned", "git+file://"+bare+"#v1"))
+	latest, _ := m.Get("latest")
+	pinned, _ := m.Get("pinned")
+
+	version := func(repo *Repository) string {
+		t.Helper()
+		manifest, err := m.FetchManifest(ctx, repo)
+		require.NoError(t, err)
+		spec, err := m.FetchCommand(ctx, repo, manifest.Commands[0].File)
+		require.NoError(t, err)
+		return spec.Version
+	}
+	assert.Equal(t, "1.0.0", version(latest))
+	assert.Equal(t, "1.0.0", version(pinned))
+
+	// New commits are picked up by Sync, not by every read
+	commit("2.0.0")
+	assert.Equal(t, "1.0.0", version(latest))
+	require.NoError(t, m.Sync(ctx, latest))
+	require.NoError(t, m.Sync(ctx, pinned))
+	assert.Equal(t, "2.0.0", version(latest))
+	assert.Equal(t, "1.0.0", version(pinned))
+
+	require.NoError(t, m.Add("missing", "git+file://"+bare+"#nope"))
+	missing, _ := m.Get("missing")
+	assert.ErrorContains(t, m.Sync(ctx, missing), "git ref 'nope' not found")
+
+	dir := m.gitDir(latest)
+	require.NoError(t, m.Remove("latest"))
+	assert.NoDirExists(t, dir)
+}
@@ -0,0 +1,315 @@
+package repos
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/scmd/scmd/internal/backend"
+	"github.com/scmd/scmd/internal/backend/mock"
+	"github.com/scmd/scmd/internal/command"
+)
+
+// CommandTest is a test case from the tests section of a command spec. It
+// runs the command against a mock backend that answers with Response.
+type CommandTest struct {
+	Name    string            `yaml:"name" json:"name"`
+	Args    []string          `yaml:"args,omitempty" json:"args,omitempty"`
+	Options map[string]string `yaml:"options,omitempty" json:"options,omitempty"` // flags and inputs by name
+	Stdin   string            `yaml:"stdin,omitempty" json:"stdin,omitempty"`
+	// Files are created in the directory the test runs in
+	Files map[string]string `yaml:"files,omitempty" json:"files,omitempty"`
+
+	// Response is what the backend answers; ResponseFile reads it from a
+	// recorded fixture, relative to the spec file
+	Response     string `yaml:"response,omitempty" json:"response,omitempty"`
+	ResponseFile string `yaml:"response_file,omitempty" json:"response_file,omitempty"`
+
+	// Hooks runs the command's hooks, which are skipped by default
+	Hooks bool `yaml:"hooks,omitempty" json:"hooks,omitempty"`
+
+	Expect TestExpect `yaml:"expect,omitempty" json:"expect,omitempty"`
+}
+
+// TestExpect holds a command test's assertions
+type TestExpect struct {
+	Error       string                 `yaml:"error,omitempty" json:"error,omitempty"` // the command fails with this in its error
+	Contains    []string               `yaml:"contains,omitempty" json:"contains,omitempty"`
+	NotContains []string               `yaml:"not_contains,omitempty" json:"not_contains,omitempty"`
+	Regex       []string               `yaml:"regex,omitempty" json:"regex,omitempty"`
+	JSON        map[string]interface{} `yaml:"json,omitempty" json:"json,omitempty"` // JSON path, such as items.0.name, to value
+	MaxTokens   int                    `yaml:"max_tokens,omitempty" json:"max_tokens,omitempty"`
+	Prompt      []string               `yaml:"prompt,omitempty" json:"prompt,omitempty"` // the rendered prompts contain these
+}
+
+// TestResult is the outcome of a command test
+type TestResult struct {
+	Command  string        `json:"command"`
+	Name     string        `json:"name"`
+	Passed   bool          `json:"passed"`
+	Failures []string      `json:"failures,omitempty"`
+	Output   string        `json:"output,omitempty"`
+	Duration time.Duration `json:"duration_ns"`
+}
+
+// TestRunner runs the tests of command specs
+type TestRunner struct {
+	// Registry runs composed commands and command hooks. Without it,
+	// compose blocks are skipped and the prompt is used.
+	Registry *command.Registry
+	DataDir  string
+}
+
+// testDirMu serializes tests, which change the working directory
+var testDirMu sync.Mutex
+
+// Run runs the tests of spec. dir is the spec file's directory, for
+// response fixtures.
+func (r *TestRunner) Run(ctx context.Context, spec *CommandSpec, dir string) []TestResult {
+	results := make([]TestResult, 0, len(spec.Tests))
+	for i, t := range spec.Tests {
+		if t.Name == "" {
+			t.Name = "test " + strconv.Itoa(i+1)
+		}
+		start := time.Now()
+		res := r.runTest(ctx, spec, dir, t)
+		res.Duration = time.Since(start)
+		results = append(results, res)
+	}
+	return results
+}
+
+func (r *TestRunner) runTest(ctx context.Context, spec *CommandSpec, dir string, t CommandTest) TestResult {
+	res := TestResult{Command: spec.Name, Name: t.Name}
+	fail := func(format string, args ...interface{}) TestResult {
+		res.Failures = append(res.Failures, fmt.Sprintf(format, args...))
+		return res
+	}
+
+	response := t.Response
+	if t.ResponseFile != "" {
+		data, err := readRepoFile(dir, t.ResponseFile)
+		if err != nil {
+			return fail("response_file: %v", err)
+		}
+		response = string(data)
+	}
+
+	work, err := os.MkdirTemp("", "scmd-test-")
+	if err != nil {
+		return fail("%v", err)
+	}
+	defer os.RemoveAll(work)
+	for name, content := range t.Files {
+		if !filepath.IsLocal(name) {
+			return fail("file %s must be a relative path inside the test directory", name)
+		}
+		path := filepath.Join(work, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return fail("%v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			return fail("%v", err)
+		}
+	}
+
+	testDirMu.Lock()
+	defer testDirMu.Unlock()
+	cwd, err := os.Getwd()
+	if err != nil {
+		return fail("%v", err)
+	}
+	if err := os.Chdir(work); err != nil {
+		return fail("%v", err)
+	}
+	defer os.Chdir(cwd)
+
+	// Hooks run shell commands; only when the test asks for them
+	run := *spec
+	if !t.Hooks {
+		run.Hooks = nil
+	}
+	cmd := NewPluginCommand(&run)
+	cmd.promptInput = strings.NewReader("")
+	cmd.promptOutput = io.Discard
+
+	args := command.NewArgs()
+	args.Positional = append(args.Positional, t.Args...)
+	for k, v := range t.Options {
+		args.Options[k] = v
+	}
+	if t.Stdin != "" {
+		args.Options["stdin"] = t.Stdin
+	}
+
+	be := &recordingBackend{Backend: mock.New()}
+	be.SetResponse(response)
+	execCtx := &command.ExecContext{Backend: be, Registry: r.Registry, DataDir: r.DataDir}
+
+	var result *command.Result
+	if err := cmd.Validate(args); err != nil {
+		result = &command.Result{Error: err.Error()}
+	} else if result, err = cmd.Execute(ctx, args, execCtx); err != nil {
+		result = &command.Result{Error: err.Error()}
+	}
+	res.Output = result.Output
+
+	res.Failures = checkExpect(t.Expect, result, be)
+	res.Passed = len(res.Failures) == 0
+	return res
+}
+
+// checkExpect checks a test's assertions against the command's result
+func checkExpect(want TestExpect, result *command.Result, be *recordingBackend) []string {
+	var failures []string
+	failf := func(format string, args ...interface{}) {
+		failures = append(failures, fmt.Sprintf(format, args...))
+	}
+
+	if want.Error != "" {
+		if result.Success {
+			failf("expected an error containing %q, but the command succeeded", want.Error)
+		} else if !strings.Contains(result.Error, want.Error) {
+			failf("expected an error containing %q, got %q", want.Error, result.Error)
+		}
+	} else if !result.Success {
+		failf("command failed: %s", result.Error)
+		return failures
+	}
+
+	for _, s := range want.Contains {
+		if !strings.Contains(result.Output, s) {
+			failf("output does not contain %q", s)
+		}
+	}
+	for _, s := range want.NotContains {
+		if strings.Contains(result.Output, s) {
+			failf("output contains %q", s)
+		}
+	}
+	for _, pattern := range want.Regex {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			failf("regex %q: %v", pattern, err)
+			continue
+		}
+		if !re.MatchString(result.Output) {
+			failf("output does not match /%s/", pattern)
+		}
+	}
+
+	if len(want.JSON) > 0 {
+		data := result.Data
+		if data == nil {
+			if err := json.Unmarshal([]byte(extractJSONValue(result.Output)), &data); err != nil {
+				failf("output is not JSON: %v", err)
+			}
+		}
+		if data != nil {
+			for path, expected := range want.JSON {
+				got, err := jsonPath(data, path)
+				if err != nil {
+					failf("json %s: %v", path, err)
+					continue
+				}
+				if !jsonEqual(got, expected) {
+					failf("json %s: expected %s, got %s", path, jsonString(expected), jsonString(got))
+				}
+			}
+		}
+	}
+
+	if want.MaxTokens > 0 {
+		if n := be.EstimateTokens(result.Output); n > want.MaxTokens {
+			failf("output is about %d tokens, over the maximum of %d", n, want.MaxTokens)
+		}
+	}
+
+	prompts := be.prompts()
+	for _, s := range want.Prompt {
+		if !strings.Contains(prompts, s) {
+			failf("prompt does not contain %q", s)
+		}
+	}
+	return failures
+}
+
+// jsonPath looks up a dotted path such as items.0.name or items[0].name
+func jsonPath(value interface{}, path string) (interface{}, error) {
+	path = strings.NewReplacer("[", ".", "]", "").Replace(path)
+	for _, key := range strings.Split(path, ".") {
+		if key == "" {
+			continue
+		}
+		switch v := value.(type) {
+		case map[string]interface{}:
+			next, ok := v[key]
+			if !ok {
+				return nil, fmt.Errorf("no field %q", key)
+			}
+			value = next
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil, fmt.Errorf("no element %q in a list of %d", key, len(v))
+			}
+			value = v[i]
+		default:
+			return nil, fmt.Errorf("%q is not an object or list", key)
+		}
+	}
+	return value, nil
+}
+
+// jsonEqual compares values as JSON, so that YAML ints equal JSON numbers
+func jsonEqual(a, b interface{}) bool {
+	var na, nb interface{}
+	if json.Unmarshal([]byte(jsonString(a)), &na) != nil || json.Unmarshal([]byte(jsonString(b)), &nb) != nil {
+		return false
+	}
+	return reflect.DeepEqual(na, nb)
+}
+
+func jsonString(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
+
+// recordingBackend is a mock backend that records the prompts it is sent
+type recordingBackend struct {
+	*mock.Backend
+	mu       sync.Mutex
+	requests []*backend.CompletionRequest
+}
+
+// Complete records the request and returns the mock response
+func (b *recordingBackend) Complete(ctx context.Context, req *backend.CompletionRequest) (*backend.CompletionResponse, error) {
+	b.mu.Lock()
+	b.requests = append(b.requests, req)
+	b.mu.Unlock()
+	return b.Backend.Complete(ctx, req)
+}
+
+// prompts returns the system and user prompts sent, joined
+func (b *recordingBackend) prompts() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var parts []string
+	for _, req := range b.requests {
+		parts = append(parts, req.SystemPrompt, req.Prompt)
+	}
+	return strings.Join(parts, "\n")
+}
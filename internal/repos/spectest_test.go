@@ -0,0 +1,142 @@
+package repos
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestTestRunner_Run(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "recorded.json"), []byte(`{"items": [{"name": "a.go", "lines": 12}]}`), 0644))
+
+	var spec CommandSpec
+	require.NoError(t, yaml.Unmarshal([]byte(`name: count
+version: 1.0.0
+description: Count lines
+args:
+  - name: file
+    required: true
+flags:
+  - name: format
+    default: text
+prompt:
+  template: |
+    Count lines in {{.file}} as {{.format}}
+    {{.stdin}}
+tests:
+  - name: passes
+    args: [main.go]
+    options: {format: json}
+    stdin: package main
+    response: "main.go: 12 lines"
+    expect:
+      contains: ["12 lines"]
+      not_contains: ["error"]
+      regex: ['\d+ lines']
+      max_tokens: 50
+      prompt: ["Count lines in main.go as json", "package main"]
+  - name: recorded json
+    args: [a.go]
+    response_file: recorded.json
+    expect:
+      json:
+        items.0.name: a.go
+        items[0].lines: 12
+  - name: expected error
+    expect:
+      error: "missing required argument: file"
+  - args: [b.go]
+    response: "b.go: 3 lines"
+    expect:
+      contains: ["12 lines"]
+      json:
+        lines: 3
+      max_tokens: 1
+      prompt: ["as yaml"]
+  - name: unexpected success
+    args: [c.go]
+    expect:
+      error: missing
+  - name: files
+    args: [notes.txt]
+    files: {notes.txt: hello}
+    response: ok
+    expect:
+      contains: [ok]
+`), &spec))
+
+	results := (&TestRunner{}).Run(context.Background(), &spec, dir)
+	require.Len(t, results, 6)
+
+	for _, res := range results[:3] {
+		assert.Equal(t, "count", res.Command)
+		assert.True(t, res.Passed, "%s: %v", res.Name, res.Failures)
+	}
+
+	failing := results[3]
+	assert.Equal(t, "test 4", failing.Name)
+	assert.False(t, failing.Passed)
+	require.Len(t, failing.Failures, 4)
+	assert.Equal(t, `output does not contain "12 lines"`, failing.Failures[0])
+	assert.Contains(t, failing.Failures[1], "output is not JSON")
+	assert.Contains(t, failing.Failures[2], "over the maximum of 1")
+	assert.Equal(t, `prompt does not contain "as yaml"`, failing.Failures[3])
+
+	assert.Equal(t, []string{`expected an error containing "missing", but the command succeeded`}, results[4].Failures)
+	assert.True(t, results[5].Passed, "%v", results[5].Failures)
+}
+
+// TestBundledCommands runs the tests in the commands shipped with scmd
+func TestBundledCommands(t *testing.T) {
+	root := filepath.Join("..", "..", "commands")
+	ran := 0
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || filepath.Ext(path) != ".yaml" {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		var spec CommandSpec
+		if yaml.Unmarshal(data, &spec) != nil || len(spec.Tests) == 0 {
+			return nil
+		}
+		for _, res := range (&TestRunner{}).Run(context.Background(), &spec, filepath.Dir(path)) {
+			ran++
+			assert.True(t, res.Passed, "%s/%s: %v", res.Command, res.Name, res.Failures)
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	assert.NotZero(t, ran)
+}
+
+func TestJSONPath(t *testing.T) {
+	data := map[string]interface{}{
+		"items": []interface{}{map[string]interface{}{"name": "a"}},
+		"count": float64(1),
+	}
+
+	got, err := jsonPath(data, "items[0].name")
+	require.NoError(t, err)
+	assert.Equal(t, "a", got)
+
+	got, err = jsonPath(data, "count")
+	require.NoError(t, err)
+	assert.True(t, jsonEqual(got, 1))
+
+	_, err = jsonPath(data, "items.3")
+	assert.ErrorContains(t, err, `no element "3" in a list of 1`)
+	_, err = jsonPath(data, "count.x")
+	assert.ErrorContains(t, err, `"x" is not an object or list`)
+	_, err = jsonPath(data, "missing")
+	assert.ErrorContains(t, err, `no field "missing"`)
+}
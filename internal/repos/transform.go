@@ -0,0 +1,2295 @@
+package repos
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Transforms are a subset of jq, run over a step's output before it is
+// passed on. The input is the step's structured output for commands with
+// JSON outputs, and otherwise its text; fromjson and fromyaml parse text,
+// code fences and all.
+//
+//	.items[] | select(.severity == "high") | .title
+//	fromjson | [.findings[] | {file, line}]
+//	fromyaml | .steps | map("\(.name): \(.status)") | join("\n")
+//	.issues | length
+//	.summary // "no summary"
+//
+// Supported: paths (.a.b, .[0], .[1:3], .["key"], .[], ..), pipes, commas,
+// literals, arrays and objects, string interpolation, @json @text @csv
+// @tsv @base64, arithmetic, comparisons, and, or, not, //, if-then-else,
+// ? and these functions: length keys has contains map map_values select
+// empty add any all min max min_by max_by sort sort_by group_by unique
+// unique_by reverse flatten first last limit range to_entries from_entries
+// with_entries tostring tonumber tojson fromjson toyaml fromyaml type
+// ascii_downcase ascii_upcase trim ltrimstr rtrimstr startswith endswith
+// split join test sub gsub floor. Regular expressions use Go syntax.
+//
+// The old one-word transforms still work on the output text: trim, upper,
+// lower, lines (the line count), first (the first line) and last (the last
+// non-empty line). json.field is fromjson | .field.
+
+// legacyTransforms are whole transforms that apply to the output text
+var legacyTransforms = map[string]string{
+	"trim":  "trim",
+	"upper": "ascii_upcase",
+	"lower": "ascii_downcase",
+	"lines": "lines",
+	"first": "first",
+	"last":  "last",
+}
+
+// jqFilter turns an input into zero or more outputs
+type jqFilter func(in interface{}) ([]interface{}, error)
+
+// Transform is a compiled transform expression
+type Transform struct {
+	expr   string
+	filter jqFilter
+	// text is set for the old transforms, which work on the output text
+	text bool
+}
+
+// CompileTransform parses a transform expression
+func CompileTransform(expr string) (*Transform, error) {
+	expr = strings.TrimSpace(expr)
+	t := &Transform{expr: expr}
+	src := expr
+	if name, ok := legacyTransforms[expr]; ok {
+		src, t.text = name, true
+	} else if rest, ok := strings.CutPrefix(expr, "json."); ok && identPath.MatchString(rest) {
+		src, t.text = "fromjson | ."+rest, true
+	}
+
+	filter, err := parseJQ(src)
+	if err != nil {
+		return nil, fmt.Errorf("transform %q: %w", expr, err)
+	}
+	t.filter = filter
+	return t, nil
+}
+
+var identPath = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)*$`)
+
+// Apply runs the transform over a step's output text and structured data.
+// Strings are output as they are and other values as indented JSON, one
+// per line. data is the value for the next step: the single non-string
+// result, an array of several results, or nil.
+func (t *Transform) Apply(text string, data interface{}) (string, interface{}, error) {
+	var in interface{} = text
+	if data != nil && !t.text {
+		in = normalizeValue(data)
+	}
+
+	results, err := t.filter(in)
+	if err != nil {
+		return "", nil, fmt.Errorf("transform %q: %w", t.expr, err)
+	}
+
+	parts := make([]string, len(results))
+	for i, r := range results {
+		parts[i] = renderValue(r, true)
+	}
+	output := strings.Join(parts, "\n")
+
+	switch len(results) {
+	case 0:
+		return output, nil, nil
+	case 1:
+		if _, ok := results[0].(string); ok {
+			return output, nil, nil
+		}
+		return output, results[0], nil
+	}
+	return output, results, nil
+}
+
+// applyTransform compiles and applies a transform
+func applyTransform(expr, text string, data interface{}) (string, interface{}, error) {
+	t, err := CompileTransform(expr)
+	if err != nil {
+		return "", nil, err
+	}
+	return t.Apply(text, data)
+}
+
+// renderValue formats a value as output: strings as they are, other
+// values as JSON
+func renderValue(v interface{}, indent bool) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if indent {
+		enc.SetIndent("", "  ")
+	}
+	if err := enc.Encode(v); err != nil {
+		return fmt.Sprint(v)
+	}
+	return strings.TrimRight(buf.String(), "\n")
+}
+
+// normalizeValue converts decoded YAML and Go values to the JSON model:
+// nil, bool, float64, string, []interface{} and map[string]interface{}
+func normalizeValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case nil, bool, float64, string:
+		return v
+	case int:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case uint64:
+		return float64(v)
+	case float32:
+		return float64(v)
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, e := range v {
+			out[i] = normalizeValue(e)
+		}
+		return out
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			out[k] = normalizeValue(e)
+		}
+		return out
+	case map[interface{}]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			out[fmt.Sprint(k)] = normalizeValue(e)
+		}
+		return out
+	}
+
+	// Anything else, such as YAML timestamps, goes through JSON
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	var out interface{}
+	if json.Unmarshal(data, &out) != nil {
+		return fmt.Sprint(v)
+	}
+	return out
+}
+
+// Lexer
+
+type jqTokenKind int
+
+const (
+	jqEOF jqTokenKind = iota
+	jqIdent
+	jqField  // .name
+	jqNumber // 1, 2.5
+	jqString // "text \(expr)"
+	jqFormat // @csv
+	jqOp
+)
+
+type jqToken struct {
+	kind  jqTokenKind
+	text  string
+	num   float64
+	parts []jqStringPart
+	pos   int
+}
+
+// jqStringPart is literal text or the source of an interpolation
+type jqStringPart struct {
+	text   string
+	interp bool
+}
+
+var jqOps = []string{"//", "==", "!=", "<=", ">=", "..", ".", "[", "]", "(", ")", "{", "}", "|", ",", ":", ";", "?", "<", ">", "+", "-", "*", "/", "%"}
+
+func lexJQ(src string) ([]jqToken, error) {
+	var tokens []jqToken
+	i := 0
+	for i < len(src) {
+		ch := src[i]
+		switch {
+		case ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r':
+			i++
+			continue
+		case ch == '#':
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+			continue
+		case ch == '.' && i+1 < len(src) && isJQIdentStart(src[i+1]):
+			j := i + 1
+			for j < len(src) && isJQIdentChar(src[j]) {
+				j++
+			}
+			tokens = append(tokens, jqToken{kind: jqField, text: src[i+1 : j], pos: i})
+			i = j
+			continue
+		case ch >= '0' && ch <= '9':
+			j := i
+			for j < len(src) && (src[j] >= '0' && src[j] <= '9' || src[j] == '.' || src[j] == 'e' || src[j] == 'E' ||
+				(src[j] == '-' || src[j] == '+') && (src[j-1] == 'e' || src[j-1] == 'E')) {
+				j++
+			}
+			n, err := strconv.ParseFloat(src[i:j], 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid number %q", src[i:j])
+			}
+			tokens = append(tokens, jqToken{kind: jqNumber, text: src[i:j], num: n, pos: i})
+			i = j
+			continue
+		case ch == '"':
+			parts, n, err := lexJQString(src[i:])
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, jqToken{kind: jqString, parts: parts, pos: i})
+			i += n
+			continue
+		case ch == '@' || isJQIdentStart(ch):
+			j := i + 1
+			for j < len(src) && isJQIdentChar(src[j]) {
+				j++
+			}
+			kind := jqIdent
+			if ch == '@' {
+				kind = jqFormat
+			}
+			tokens = append(tokens, jqToken{kind: kind, text: src[i:j], pos: i})
+			i = j
+			continue
+		}
+
+		matched := false
+		for _, op := range jqOps {
+			if strings.HasPrefix(src[i:], op) {
+				tokens = append(tokens, jqToken{kind: jqOp, text: op, pos: i})
+				i += len(op)
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return nil, fmt.Errorf("unexpected %q at offset %d", ch, i)
+		}
+	}
+	return append(tokens, jqToken{kind: jqEOF, pos: len(src)}), nil
+}
+
+// lexJQString reads a string literal starting at src[0], returning its
+// parts and length
+func lexJQString(src string) ([]jqStringPart, int, error) {
+	var parts []jqStringPart
+	var lit strings.Builder
+	i := 1
+	for i < len(src) {
+		ch := src[i]
+		switch ch {
+		case '"':
+			parts = append(parts, jqStringPart{text: lit.String()})
+			return parts, i + 1, nil
+		case '\\':
+			if i+1 >= len(src) {
+				return nil, 0, fmt.Errorf("unterminated string")
+			}
+			esc := src[i+1]
+			switch esc {
+			case '(':
+				// Interpolation: find the matching parenthesis, skipping
+				// strings inside it
+				depth, j := 1, i+2
+				for j < len(src) && depth > 0 {
+					switch src[j] {
+					case '(':
+						depth++
+					case ')':
+						depth--
+					case '"':
+						_, n, err := lexJQString(src[j:])
+						if err != nil {
+							return nil, 0, err
+						}
+						j += n - 1
+					}
+					j++
+				}
+				if depth > 0 {
+					return nil, 0, fmt.Errorf("unterminated interpolation")
+				}
+				parts = append(parts, jqStringPart{text: lit.String()}, jqStringPart{text: src[i+2 : j-1], interp: true})
+				lit.Reset()
+				i = j
+				continue
+			case 'n':
+				lit.WriteByte('\n')
+			case 't':
+				lit.WriteByte('\t')
+			case 'r':
+				lit.WriteByte('\r')
+			case '"', '\\', '/':
+				lit.WriteByte(esc)
+			case 'u':
+				if i+6 > len(src) {
+					return nil, 0, fmt.Errorf("invalid \\u escape")
+				}
+				r, err := strconv.ParseUint(src[i+2:i+6], 16, 32)
+				if err != nil {
+					return nil, 0, fmt.Errorf("invalid \\u escape")
+				}
+				lit.WriteRune(rune(r))
+				i += 6
+				continue
+			default:
+				return nil, 0, fmt.Errorf("invalid escape \\%c", esc)
+			}
+			i += 2
+		default:
+			lit.WriteByte(ch)
+			i++
+		}
+	}
+	return nil, 0, fmt.Errorf("unterminated string")
+}
+
+func isJQIdentStart(ch byte) bool {
+	return ch == '_' || ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z'
+}
+
+func isJQIdentChar(ch byte) bool {
+	return isJQIdentStart(ch) || ch >= '0' && ch <= '9'
+}
+
+// Parser
+
+type jqParser struct {
+	tokens []jqToken
+	pos    int
+}
+
+func parseJQ(src string) (jqFilter, error) {
+	tokens, err := lexJQ(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &jqParser{tokens: tokens}
+	if p.peek().kind == jqEOF {
+		return nil, fmt.Errorf("empty expression")
+	}
+	f, err := p.pipe()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != jqEOF {
+		return nil, fmt.Errorf("unexpected %s", t.describe())
+	}
+	return f, nil
+}
+
+func (t jqToken) describe() string {
+	switch t.kind {
+	case jqEOF:
+		return "end of expression"
+	case jqField:
+		return fmt.Sprintf("%q", "."+t.text)
+	case jqString:
+		return "string"
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+func (p *jqParser) peek() jqToken { return p.tokens[p.pos] }
+
+func (p *jqParser) next() jqToken {
+	t := p.tokens[p.pos]
+	if t.kind != jqEOF {
+		p.pos++
+	}
+	return t
+}
+
+// isOp reports whether the next token is the operator or keyword op
+func (p *jqParser) isOp(op string) bool {
+	t := p.peek()
+	return (t.kind == jqOp || t.kind == jqIdent) && t.text == op
+}
+
+func (p *jqParser) accept(op string) bool {
+	if p.isOp(op) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *jqParser) expect(op string) error {
+	if !p.accept(op) {
+		return fmt.Errorf("expected %q, found %s", op, p.peek().describe())
+	}
+	return nil
+}
+
+func (p *jqParser) pipe() (jqFilter, error) {
+	left, err := p.comma()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("|") {
+		right, err := p.comma()
+		if err != nil {
+			return nil, err
+		}
+		left = jqPipe(left, right)
+	}
+	return left, nil
+}
+
+func jqPipe(left, right jqFilter) jqFilter {
+	return func(in interface{}) ([]interface{}, error) {
+		values, err := left(in)
+		if err != nil {
+			return nil, err
+		}
+		var out []interface{}
+		for _, v := range values {
+			r, err := right(v)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, r...)
+		}
+		return out, nil
+	}
+}
+
+func (p *jqParser) comma() (jqFilter, error) {
+	left, err := p.alternative()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept(",") {
+		right, err := p.alternative()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(in interface{}) ([]interface{}, error) {
+			a, err := l(in)
+			if err != nil {
+				return nil, err
+			}
+			b, err := right(in)
+			if err != nil {
+				return nil, err
+			}
+			return append(a, b...), nil
+		}
+	}
+	return left, nil
+}
+
+// alternative is a // b: the truthy outputs of a, or else those of b
+func (p *jqParser) alternative() (jqFilter, error) {
+	left, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if !p.accept("//") {
+		return left, nil
+	}
+	right, err := p.alternative()
+	if err != nil {
+		return nil, err
+	}
+	return func(in interface{}) ([]interface{}, error) {
+		values, err := left(in)
+		var out []interface{}
+		if err == nil {
+			for _, v := range values {
+				if jqTruthy(v) {
+					out = append(out, v)
+				}
+			}
+		}
+		if len(out) > 0 {
+			return out, nil
+		}
+		return right(in)
+	}, nil
+}
+
+func (p *jqParser) or() (jqFilter, error) {
+	return p.boolean("or", p.and, true)
+}
+
+func (p *jqParser) and() (jqFilter, error) {
+	return p.boolean("and", p.comparison, false)
+}
+
+// boolean parses a chain of and or or, which short-circuits on short
+func (p *jqParser) boolean(op string, operand func() (jqFilter, error), short bool) (jqFilter, error) {
+	left, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept(op) {
+		right, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(in interface{}) ([]interface{}, error) {
+			values, err := l(in)
+			if err != nil {
+				return nil, err
+			}
+			var out []interface{}
+			for _, v := range values {
+				if jqTruthy(v) == short {
+					out = append(out, short)
+					continue
+				}
+				rs, err := right(in)
+				if err != nil {
+					return nil, err
+				}
+				for _, r := range rs {
+					out = append(out, jqTruthy(r))
+				}
+			}
+			return out, nil
+		}
+	}
+	return left, nil
+}
+
+func (p *jqParser) comparison() (jqFilter, error) {
+	left, err := p.additive()
+	if err != nil {
+		return nil, err
+	}
+	for _, op := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+		if !p.accept(op) {
+			continue
+		}
+		right, err := p.additive()
+		if err != nil {
+			return nil, err
+		}
+		return jqBinary(left, right, func(a, b interface{}) (interface{}, error) {
+			c := compareValues(a, b)
+			switch op {
+			case "==":
+				return c == 0, nil
+			case "!=":
+				return c != 0, nil
+			case "<=":
+				return c <= 0, nil
+			case ">=":
+				return c >= 0, nil
+			case "<":
+				return c < 0, nil
+			}
+			return c > 0, nil
+		}), nil
+	}
+	return left, nil
+}
+
+func (p *jqParser) additive() (jqFilter, error) {
+	return p.arithmetic([]string{"+", "-"}, p.multiplicative)
+}
+
+func (p *jqParser) multiplicative() (jqFilter, error) {
+	return p.arithmetic([]string{"*", "/", "%"}, p.postfix)
+}
+
+func (p *jqParser) arithmetic(ops []string, operand func() (jqFilter, error)) (jqFilter, error) {
+	left, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op := ""
+		for _, o := range ops {
+			if p.accept(o) {
+				op = o
+				break
+			}
+		}
+		if op == "" {
+			return left, nil
+		}
+		right, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		left = jqBinary(left, right, func(a, b interface{}) (interface{}, error) {
+			return jqArithmetic(op, a, b)
+		})
+	}
+}
+
+// jqBinary applies op to every pair of outputs of left and right
+func jqBinary(left, right jqFilter, op func(a, b interface{}) (interface{}, error)) jqFilter {
+	return func(in interface{}) ([]interface{}, error) {
+		rs, err := right(in)
+		if err != nil {
+			return nil, err
+		}
+		ls, err := left(in)
+		if err != nil {
+			return nil, err
+		}
+		var out []interface{}
+		for _, r := range rs {
+			for _, l := range ls {
+				v, err := op(l, r)
+				if err != nil {
+					return nil, err
+				}
+				out = append(out, v)
+			}
+		}
+		return out, nil
+	}
+}
+
+func jqArithmetic(op string, a, b interface{}) (interface{}, error) {
+	fail := func() (interface{}, error) {
+		return nil, fmt.Errorf("cannot apply %s to %s and %s", op, jsonTypeName(a), jsonTypeName(b))
+	}
+	if op == "+" {
+		if a == nil {
+			return b, nil
+		}
+		if b == nil {
+			return a, nil
+		}
+	}
+	switch x := a.(type) {
+	case float64:
+		y, ok := b.(float64)
+		if !ok {
+			return fail()
+		}
+		switch op {
+		case "+":
+			return x + y, nil
+		case "-":
+			return x - y, nil
+		case "*":
+			return x * y, nil
+		case "/":
+			if y == 0 {
+				return nil, fmt.Errorf("division by zero")
+			}
+			return x / y, nil
+		case "%":
+			if int64(y) == 0 {
+				return nil, fmt.Errorf("division by zero")
+			}
+			return float64(int64(x) % int64(y)), nil
+		}
+	case string:
+		y, ok := b.(string)
+		if !ok {
+			return fail()
+		}
+		switch op {
+		case "+":
+			return x + y, nil
+		case "/":
+			return splitString(x, y), nil
+		}
+	case []interface{}:
+		y, ok := b.([]interface{})
+		if !ok {
+			return fail()
+		}
+		switch op {
+		case "+":
+			return append(append([]interface{}{}, x...), y...), nil
+		case "-":
+			var out []interface{}
+			for _, e := range x {
+				if !containsValue(y, e) {
+					out = append(out, e)
+				}
+			}
+			if out == nil {
+				out = []interface{}{}
+			}
+			return out, nil
+		}
+	case map[string]interface{}:
+		y, ok := b.(map[string]interface{})
+		if !ok || op != "+" {
+			return fail()
+		}
+		out := make(map[string]interface{}, len(x)+len(y))
+		for k, v := range x {
+			out[k] = v
+		}
+		for k, v := range y {
+			out[k] = v
+		}
+		return out, nil
+	}
+	return fail()
+}
+
+// postfix parses a term followed by paths, indexes and ?
+func (p *jqParser) postfix() (jqFilter, error) {
+	term, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		switch {
+		case t.kind == jqField:
+			p.next()
+			term = jqPipe(term, jqIndexKey(t.text))
+		case t.kind == jqOp && t.text == "." && p.tokens[p.pos+1].kind == jqString:
+			p.next()
+			key, err := p.primary()
+			if err != nil {
+				return nil, err
+			}
+			term = jqIndexBy(term, key)
+		case t.kind == jqOp && t.text == "[":
+			p.next()
+			term, err = p.bracket(term)
+			if err != nil {
+				return nil, err
+			}
+		case t.kind == jqOp && t.text == "?":
+			p.next()
+			term = jqTry(term)
+		default:
+			return term, nil
+		}
+	}
+}
+
+// bracket parses the rest of [], [expr] or [from:to] after term
+func (p *jqParser) bracket(term jqFilter) (jqFilter, error) {
+	if p.accept("]") {
+		return jqPipe(term, jqIterate), nil
+	}
+
+	var from, to jqFilter
+	var err error
+	if !p.isOp(":") {
+		if from, err = p.pipe(); err != nil {
+			return nil, err
+		}
+		if p.accept("]") {
+			return jqIndexBy(term, from), nil
+		}
+	}
+	if err := p.expect(":"); err != nil {
+		return nil, err
+	}
+	if !p.isOp("]") {
+		if to, err = p.pipe(); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.expect("]"); err != nil {
+		return nil, err
+	}
+	return jqSlice(term, from, to), nil
+}
+
+func jqIndexKey(key string) jqFilter {
+	return func(in interface{}) ([]interface{}, error) {
+		v, err := indexValue(in, key)
+		if err != nil {
+			return nil, err
+		}
+		return []interface{}{v}, nil
+	}
+}
+
+// jqIndexBy indexes each output of term by each output of key, which is
+// evaluated against the original input
+func jqIndexBy(term, key jqFilter) jqFilter {
+	return func(in interface{}) ([]interface{}, error) {
+		keys, err := key(in)
+		if err != nil {
+			return nil, err
+		}
+		values, err := term(in)
+		if err != nil {
+			return nil, err
+		}
+		var out []interface{}
+		for _, v := range values {
+			for _, k := range keys {
+				r, err := indexValue(v, k)
+				if err != nil {
+					return nil, err
+				}
+				out = append(out, r)
+			}
+		}
+		return out, nil
+	}
+}
+
+func indexValue(v, key interface{}) (interface{}, error) {
+	switch x := v.(type) {
+	case nil:
+		return nil, nil
+	case map[string]interface{}:
+		if k, ok := key.(string); ok {
+			return x[k], nil
+		}
+	case []interface{}:
+		if f, ok := key.(float64); ok {
+			i := int(math.Floor(f))
+			if i < 0 {
+				i += len(x)
+			}
+			if i < 0 || i >= len(x) {
+				return nil, nil
+			}
+			return x[i], nil
+		}
+	}
+	return nil, fmt.Errorf("cannot index %s with %s", jsonTypeName(v), describeKey(key))
+}
+
+func describeKey(key interface{}) string {
+	if s, ok := key.(string); ok {
+		return strconv.Quote(s)
+	}
+	return jsonTypeName(key)
+}
+
+func jqIterate(in interface{}) ([]interface{}, error) {
+	switch x := in.(type) {
+	case []interface{}:
+		return x, nil
+	case map[string]interface{}:
+		out := make([]interface{}, 0, len(x))
+		for _, k := range sortedMapKeys(x) {
+			out = append(out, x[k])
+		}
+		return out, nil
+	}
+	return nil, fmt.Errorf("cannot iterate over %s", jsonTypeName(in))
+}
+
+func jqSlice(term, from, to jqFilter) jqFilter {
+	bound := func(f jqFilter, in interface{}, def int) (int, error) {
+		if f == nil {
+			return def, nil
+		}
+		vs, err := f(in)
+		if err != nil {
+			return 0, err
+		}
+		if len(vs) != 1 {
+			return 0, fmt.Errorf("slice bounds must have one value")
+		}
+		if vs[0] == nil {
+			return def, nil
+		}
+		n, ok := vs[0].(float64)
+		if !ok {
+			return 0, fmt.Errorf("slice bounds must be numbers, not %s", jsonTypeName(vs[0]))
+		}
+		return int(math.Floor(n)), nil
+	}
+	clamp := func(i, n int) int {
+		if i < 0 {
+			i += n
+		}
+		return max(0, min(i, n))
+	}
+
+	return func(in interface{}) ([]interface{}, error) {
+		values, err := term(in)
+		if err != nil {
+			return nil, err
+		}
+		var out []interface{}
+		for _, v := range values {
+			var n int
+			switch x := v.(type) {
+			case nil:
+				out = append(out, nil)
+				continue
+			case []interface{}:
+				n = len(x)
+			case string:
+				n = utf8.RuneCountInString(x)
+			default:
+				return nil, fmt.Errorf("cannot slice %s", jsonTypeName(v))
+			}
+			lo, err := bound(from, in, 0)
+			if err != nil {
+				return nil, err
+			}
+			hi, err := bound(to, in, n)
+			if err != nil {
+				return nil, err
+			}
+			lo, hi = clamp(lo, n), clamp(hi, n)
+			hi = max(lo, hi)
+			switch x := v.(type) {
+			case []interface{}:
+				out = append(out, append([]interface{}{}, x[lo:hi]...))
+			case string:
+				out = append(out, string([]rune(x)[lo:hi]))
+			}
+		}
+		return out, nil
+	}
+}
+
+// jqTry suppresses errors, giving no output instead
+func jqTry(f jqFilter) jqFilter {
+	return func(in interface{}) ([]interface{}, error) {
+		out, err := f(in)
+		if err != nil {
+			return nil, nil
+		}
+		return out, nil
+	}
+}
+
+func (p *jqParser) primary() (jqFilter, error) {
+	t := p.next()
+	switch t.kind {
+	case jqField:
+		return jqIndexKey(t.text), nil
+
+	case jqNumber:
+		return jqConst(t.num), nil
+
+	case jqString:
+		return p.stringTerm(t, nil)
+
+	case jqFormat:
+		format, ok := jqFormats[t.text]
+		if !ok {
+			return nil, fmt.Errorf("unknown format %s", t.text)
+		}
+		if p.peek().kind == jqString {
+			return p.stringTerm(p.next(), format)
+		}
+		return func(in interface{}) ([]interface{}, error) {
+			s, err := format(in)
+			if err != nil {
+				return nil, err
+			}
+			return []interface{}{s}, nil
+		}, nil
+
+	case jqIdent:
+		switch t.text {
+		case "true":
+			return jqConst(true), nil
+		case "false":
+			return jqConst(false), nil
+		case "null":
+			return jqConst(nil), nil
+		case "if":
+			return p.ifTerm()
+		}
+		return p.call(t.text)
+
+	case jqOp:
+		switch t.text {
+		case ".":
+			if p.peek().kind == jqString {
+				key, err := p.primary()
+				if err != nil {
+					return nil, err
+				}
+				return jqIndexBy(jqIdentity, key), nil
+			}
+			return jqIdentity, nil
+		case "..":
+			return jqRecurse, nil
+		case "(":
+			f, err := p.pipe()
+			if err != nil {
+				return nil, err
+			}
+			return f, p.expect(")")
+		case "[":
+			if p.accept("]") {
+				return jqConstFunc(func() interface{} { return []interface{}{} }), nil
+			}
+			f, err := p.pipe()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+			return func(in interface{}) ([]interface{}, error) {
+				values, err := f(in)
+				if err != nil {
+					return nil, err
+				}
+				if values == nil {
+					values = []interface{}{}
+				}
+				return []interface{}{values}, nil
+			}, nil
+		case "{":
+			return p.object()
+		case "-":
+			f, err := p.postfix()
+			if err != nil {
+				return nil, err
+			}
+			return jqPipe(f, func(in interface{}) ([]interface{}, error) {
+				n, ok := in.(float64)
+				if !ok {
+					return nil, fmt.Errorf("cannot negate %s", jsonTypeName(in))
+				}
+				return []interface{}{-n}, nil
+			}), nil
+		}
+	}
+	return nil, fmt.Errorf("unexpected %s", t.describe())
+}
+
+func jqIdentity(in interface{}) ([]interface{}, error) {
+	return []interface{}{in}, nil
+}
+
+func jqConst(v interface{}) jqFilter {
+	return func(interface{}) ([]interface{}, error) {
+		return []interface{}{v}, nil
+	}
+}
+
+// jqConstFunc makes a fresh value each time, for values that can be changed
+func jqConstFunc(make func() interface{}) jqFilter {
+	return func(interface{}) ([]interface{}, error) {
+		return []interface{}{make()}, nil
+	}
+}
+
+func jqRecurse(in interface{}) ([]interface{}, error) {
+	out := []interface{}{in}
+	switch x := in.(type) {
+	case []interface{}:
+		for _, e := range x {
+			r, _ := jqRecurse(e)
+			out = append(out, r...)
+		}
+	case map[string]interface{}:
+		for _, k := range sortedMapKeys(x) {
+			r, _ := jqRecurse(x[k])
+			out = append(out, r...)
+		}
+	}
+	return out, nil
+}
+
+// stringTerm builds a string with interpolations, formatting the
+// interpolated values with format if set
+func (p *jqParser) stringTerm(t jqToken, format func(interface{}) (string, error)) (jqFilter, error) {
+	if format == nil {
+		format = func(v interface{}) (string, error) { return renderValue(v, false), nil }
+	}
+
+	type piece struct {
+		text   string
+		filter jqFilter
+	}
+	var pieces []piece
+	for _, part := range t.parts {
+		if !part.interp {
+			pieces = append(pieces, piece{text: part.text})
+			continue
+		}
+		f, err := parseJQ(part.text)
+		if err != nil {
+			return nil, fmt.Errorf("in string interpolation: %w", err)
+		}
+		pieces = append(pieces, piece{filter: f})
+	}
+
+	return func(in interface{}) ([]interface{}, error) {
+		// Every combination of the interpolations' outputs
+		results := []string{""}
+		for _, pc := range pieces {
+			if pc.filter == nil {
+				for i := range results {
+					results[i] += pc.text
+				}
+				continue
+			}
+			values, err := pc.filter(in)
+			if err != nil {
+				return nil, err
+			}
+			var next []string
+			for _, r := range results {
+				for _, v := range values {
+					s, err := format(v)
+					if err != nil {
+						return nil, err
+					}
+					next = append(next, r+s)
+				}
+			}
+			results = next
+		}
+		out := make([]interface{}, len(results))
+		for i, r := range results {
+			out[i] = r
+		}
+		return out, nil
+	}, nil
+}
+
+// ifTerm parses the rest of if c then a (elif c then b)* (else d)? end
+func (p *jqParser) ifTerm() (jqFilter, error) {
+	cond, err := p.pipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect("then"); err != nil {
+		return nil, err
+	}
+	then, err := p.pipe()
+	if err != nil {
+		return nil, err
+	}
+
+	otherwise := jqFilter(jqIdentity)
+	switch {
+	case p.accept("elif"):
+		if otherwise, err = p.ifTerm(); err != nil {
+			return nil, err
+		}
+	case p.accept("else"):
+		if otherwise, err = p.pipe(); err != nil {
+			return nil, err
+		}
+		fallthrough
+	default:
+		if err := p.expect("end"); err != nil {
+			return nil, err
+		}
+	}
+
+	return func(in interface{}) ([]interface{}, error) {
+		conds, err := cond(in)
+		if err != nil {
+			return nil, err
+		}
+		var out []interface{}
+		for _, c := range conds {
+			branch := otherwise
+			if jqTruthy(c) {
+				branch = then
+			}
+			r, err := branch(in)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, r...)
+		}
+		return out, nil
+	}, nil
+}
+
+// object parses the rest of {key: value, ...}
+func (p *jqParser) object() (jqFilter, error) {
+	type entry struct {
+		key, value jqFilter
+	}
+	var entries []entry
+	for !p.accept("}") {
+		if len(entries) > 0 {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+
+		var e entry
+		t := p.next()
+		switch {
+		case t.kind == jqIdent:
+			e.key = jqConst(t.text)
+			e.value = jqIndexKey(t.text)
+		case t.kind == jqString:
+			key, err := p.stringTerm(t, nil)
+			if err != nil {
+				return nil, err
+			}
+			e.key = key
+			e.value = jqIndexBy(jqIdentity, key)
+		case t.kind == jqOp && t.text == "(":
+			key, err := p.pipe()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			e.key = key
+		default:
+			return nil, fmt.Errorf("unexpected %s in object", t.describe())
+		}
+
+		if p.accept(":") {
+			value, err := p.objectValue()
+			if err != nil {
+				return nil, err
+			}
+			e.value = value
+		} else if e.value == nil {
+			return nil, fmt.Errorf("expected \":\" after a computed key")
+		}
+		entries = append(entries, e)
+	}
+
+	return func(in interface{}) ([]interface{}, error) {
+		// Every combination of the keys' and values' outputs
+		objects := []map[string]interface{}{{}}
+		for _, e := range entries {
+			keys, err := e.key(in)
+			if err != nil {
+				return nil, err
+			}
+			values, err := e.value(in)
+			if err != nil {
+				return nil, err
+			}
+			var next []map[string]interface{}
+			for _, obj := range objects {
+				for _, k := range keys {
+					ks, ok := k.(string)
+					if !ok {
+						return nil, fmt.Errorf("object keys must be strings, not %s", jsonTypeName(k))
+					}
+					for _, v := range values {
+						o := make(map[string]interface{}, len(obj)+1)
+						for ok, ov := range obj {
+							o[ok] = ov
+						}
+						o[ks] = v
+						next = append(next, o)
+					}
+				}
+			}
+			objects = next
+		}
+		out := make([]interface{}, len(objects))
+		for i, o := range objects {
+			out[i] = o
+		}
+		return out, nil
+	}, nil
+}
+
+// objectValue parses a value in an object, which binds tighter than ,
+func (p *jqParser) objectValue() (jqFilter, error) {
+	left, err := p.alternative()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("|") {
+		right, err := p.alternative()
+		if err != nil {
+			return nil, err
+		}
+		left = jqPipe(left, right)
+	}
+	return left, nil
+}
+
+// call parses a function call with its arguments, separated by ;
+func (p *jqParser) call(name string) (jqFilter, error) {
+	var args []jqFilter
+	if p.accept("(") {
+		for {
+			arg, err := p.pipe()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+			if p.accept(")") {
+				break
+			}
+			if !p.accept(";") {
+				return nil, fmt.Errorf("expected \")\", found %s", p.peek().describe())
+			}
+		}
+	}
+
+	key := fmt.Sprintf("%s/%d", name, len(args))
+	if fn, ok := jqFuncs[key]; ok {
+		return func(in interface{}) ([]interface{}, error) {
+			v, err := fn(in)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+			return []interface{}{v}, nil
+		}, nil
+	}
+	if build, ok := jqHigherFuncs[key]; ok {
+		return build(args), nil
+	}
+	return nil, fmt.Errorf("unknown function %s", key)
+}
+
+// Values
+
+// jqTruthy treats false and null as false
+func jqTruthy(v interface{}) bool {
+	if b, ok := v.(bool); ok {
+		return b
+	}
+	return v != nil
+}
+
+func typeRank(v interface{}) int {
+	switch x := v.(type) {
+	case nil:
+		return 0
+	case bool:
+		if x {
+			return 2
+		}
+		return 1
+	case float64:
+		return 3
+	case string:
+		return 4
+	case []interface{}:
+		return 5
+	}
+	return 6
+}
+
+// compareValues orders values as jq does: null, false, true, numbers,
+// strings, arrays, objects
+func compareValues(a, b interface{}) int {
+	ra, rb := typeRank(a), typeRank(b)
+	if ra != rb {
+		return ra - rb
+	}
+	switch x := a.(type) {
+	case float64:
+		y := b.(float64)
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+		return 0
+	case string:
+		return strings.Compare(x, b.(string))
+	case []interface{}:
+		y := b.([]interface{})
+		for i := 0; i < len(x) && i < len(y); i++ {
+			if c := compareValues(x[i], y[i]); c != 0 {
+				return c
+			}
+		}
+		return len(x) - len(y)
+	case map[string]interface{}:
+		y := b.(map[string]interface{})
+		kx, ky := sortedMapKeys(x), sortedMapKeys(y)
+		if c := compareValues(stringsToValues(kx), stringsToValues(ky)); c != 0 {
+			return c
+		}
+		for _, k := range kx {
+			if c := compareValues(x[k], y[k]); c != 0 {
+				return c
+			}
+		}
+	}
+	return 0
+}
+
+func sortedMapKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func stringsToValues(ss []string) []interface{} {
+	out := make([]interface{}, len(ss))
+	for i, s := range ss {
+		out[i] = s
+	}
+	return out
+}
+
+func containsValue(list []interface{}, v interface{}) bool {
+	for _, e := range list {
+		if compareValues(e, v) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func splitString(s, sep string) []interface{} {
+	return stringsToValues(strings.Split(s, sep))
+}
+
+// jqContains is jq's contains: substrings, and recursively for arrays and
+// objects
+func jqContains(a, b interface{}) bool {
+	switch x := a.(type) {
+	case string:
+		y, ok := b.(string)
+		return ok && strings.Contains(x, y)
+	case []interface{}:
+		y, ok := b.([]interface{})
+		if !ok {
+			return false
+		}
+		for _, want := range y {
+			found := false
+			for _, have := range x {
+				if jqContains(have, want) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		}
+		return true
+	case map[string]interface{}:
+		y, ok := b.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		for k, want := range y {
+			have, ok := x[k]
+			if !ok || !jqContains(have, want) {
+				return false
+			}
+		}
+		return true
+	}
+	return compareValues(a, b) == 0
+}
+
+// Formats
+
+var jqFormats = map[string]func(interface{}) (string, error){
+	"@text": func(v interface{}) (string, error) { return renderValue(v, false), nil },
+	"@json": func(v interface{}) (string, error) {
+		if s, ok := v.(string); ok {
+			data, _ := json.Marshal(s)
+			return string(data), nil
+		}
+		return renderValue(v, false), nil
+	},
+	"@csv": func(v interface{}) (string, error) {
+		return formatRow(v, ",", func(s string) string {
+			return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+		})
+	},
+	"@tsv": func(v interface{}) (string, error) {
+		return formatRow(v, "\t", strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`).Replace)
+	},
+	"@base64": func(v interface{}) (string, error) {
+		return base64.StdEncoding.EncodeToString([]byte(renderValue(v, false))), nil
+	},
+}
+
+// formatRow formats an array as a CSV or TSV row, quoting strings
+func formatRow(v interface{}, sep string, quote func(string) string) (string, error) {
+	row, ok := v.([]interface{})
+	if !ok {
+		return "", fmt.Errorf("cannot format %s as a row; use an array", jsonTypeName(v))
+	}
+	cells := make([]string, len(row))
+	for i, cell := range row {
+		switch c := cell.(type) {
+		case string:
+			cells[i] = quote(c)
+		case nil:
+			cells[i] = ""
+		case []interface{}, map[string]interface{}:
+			return "", fmt.Errorf("cannot put %s in a row", jsonTypeName(cell))
+		default:
+			cells[i] = renderValue(c, false)
+		}
+	}
+	return strings.Join(cells, sep), nil
+}
+
+// Functions
+
+// jqFuncs are functions of their input alone
+var jqFuncs map[string]func(in interface{}) (interface{}, error)
+
+// jqHigherFuncs are functions with arguments
+var jqHigherFuncs map[string]func(args []jqFilter) jqFilter
+
+func init() {
+	jqFuncs = map[string]func(interface{}) (interface{}, error){
+		"length/0":         jqLength,
+		"keys/0":           jqKeys,
+		"add/0":            jqAdd,
+		"any/0":            func(in interface{}) (interface{}, error) { return jqAnyAll(in, true) },
+		"all/0":            func(in interface{}) (interface{}, error) { return jqAnyAll(in, false) },
+		"min/0":            func(in interface{}) (interface{}, error) { return jqExtreme(in, -1) },
+		"max/0":            func(in interface{}) (interface{}, error) { return jqExtreme(in, 1) },
+		"sort/0":           jqSort,
+		"unique/0":         jqUnique,
+		"reverse/0":        jqReverse,
+		"flatten/0":        jqFlatten,
+		"first/0":          func(in interface{}) (interface{}, error) { return jqEnd(in, true) },
+		"last/0":           func(in interface{}) (interface{}, error) { return jqEnd(in, false) },
+		"lines/0":          jqLines,
+		"not/0":            func(in interface{}) (interface{}, error) { return !jqTruthy(in), nil },
+		"type/0":           func(in interface{}) (interface{}, error) { return jsonTypeName(in), nil },
+		"tostring/0":       func(in interface{}) (interface{}, error) { return renderValue(in, false), nil },
+		"tonumber/0":       jqToNumber,
+		"tojson/0":         func(in interface{}) (interface{}, error) { return jqFormats["@json"](in) },
+		"fromjson/0":       jqFromJSON,
+		"toyaml/0":         jqToYAML,
+		"fromyaml/0":       jqFromYAML,
+		"ascii_downcase/0": stringFunc(strings.ToLower),
+		"ascii_upcase/0":   stringFunc(strings.ToUpper),
+		"trim/0":           stringFunc(strings.TrimSpace),
+		"to_entries/0":     jqToEntries,
+		"from_entries/0":   jqFromEntries,
+		"floor/0": func(in interface{}) (interface{}, error) {
+			n, ok := in.(float64)
+			if !ok {
+				return nil, fmt.Errorf("%s is not a number", jsonTypeName(in))
+			}
+			return math.Floor(n), nil
+		},
+	}
+
+	jqHigherFuncs = map[string]func([]jqFilter) jqFilter{
+		"empty/0": func([]jqFilter) jqFilter {
+			return func(interface{}) ([]interface{}, error) { return nil, nil }
+		},
+		"select/1": func(args []jqFilter) jqFilter {
+			return func(in interface{}) ([]interface{}, error) {
+				conds, err := args[0](in)
+				if err != nil {
+					return nil, err
+				}
+				var out []interface{}
+				for _, c := range conds {
+					if jqTruthy(c) {
+						out = append(out, in)
+					}
+				}
+				return out, nil
+			}
+		},
+		"map/1": func(args []jqFilter) jqFilter {
+			return jqCollect(jqPipe(jqIterate, args[0]))
+		},
+		"map_values/1": func(args []jqFilter) jqFilter {
+			return func(in interface{}) ([]interface{}, error) {
+				switch x := in.(type) {
+				case map[string]interface{}:
+					out := make(map[string]interface{}, len(x))
+					for k, v := range x {
+						r, err := args[0](v)
+						if err != nil {
+							return nil, err
+						}
+						if len(r) > 0 {
+							out[k] = r[0]
+						}
+					}
+					return []interface{}{out}, nil
+				case []interface{}:
+					return jqCollect(jqPipe(jqIterate, args[0]))(in)
+				}
+				return nil, fmt.Errorf("map_values: cannot iterate over %s", jsonTypeName(in))
+			}
+		},
+		"with_entries/1": func(args []jqFilter) jqFilter {
+			toEntries := jqFuncFilter(jqToEntries)
+			fromEntries := jqFuncFilter(jqFromEntries)
+			return jqPipe(jqPipe(toEntries, jqCollect(jqPipe(jqIterate, args[0]))), fromEntries)
+		},
+		"sort_by/1":   func(args []jqFilter) jqFilter { return jqBy(args[0], jqSortBy) },
+		"group_by/1":  func(args []jqFilter) jqFilter { return jqBy(args[0], jqGroupBy) },
+		"unique_by/1": func(args []jqFilter) jqFilter { return jqBy(args[0], jqUniqueBy) },
+		"min_by/1": func(args []jqFilter) jqFilter {
+			return jqBy(args[0], func(items []interface{}, keys [][]interface{}) interface{} { return extremeBy(items, keys, -1) })
+		},
+		"max_by/1": func(args []jqFilter) jqFilter {
+			return jqBy(args[0], func(items []interface{}, keys [][]interface{}) interface{} { return extremeBy(items, keys, 1) })
+		},
+		"any/1": func(args []jqFilter) jqFilter {
+			return jqPipe(jqCollect(jqPipe(jqIterate, args[0])), jqFuncFilter(jqFuncs["any/0"]))
+		},
+		"all/1": func(args []jqFilter) jqFilter {
+			return jqPipe(jqCollect(jqPipe(jqIterate, args[0])), jqFuncFilter(jqFuncs["all/0"]))
+		},
+		"first/1": func(args []jqFilter) jqFilter {
+			return func(in interface{}) ([]interface{}, error) {
+				out, err := args[0](in)
+				if err != nil || len(out) == 0 {
+					return nil, err
+				}
+				return out[:1], nil
+			}
+		},
+		"last/1": func(args []jqFilter) jqFilter {
+			return func(in interface{}) ([]interface{}, error) {
+				out, err := args[0](in)
+				if err != nil || len(out) == 0 {
+					return nil, err
+				}
+				return out[len(out)-1:], nil
+			}
+		},
+		"limit/2": func(args []jqFilter) jqFilter {
+			return func(in interface{}) ([]interface{}, error) {
+				return withValues(args[0], in, func(n interface{}) ([]interface{}, error) {
+					count, ok := n.(float64)
+					if !ok {
+						return nil, fmt.Errorf("limit: %s is not a number", jsonTypeName(n))
+					}
+					out, err := args[1](in)
+					if err != nil {
+						return nil, err
+					}
+					if c := int(count); c < len(out) {
+						out = out[:max(c, 0)]
+					}
+					return out, nil
+				})
+			}
+		},
+		"range/1": func(args []jqFilter) jqFilter {
+			return jqRange(jqConst(0.0), args[0])
+		},
+		"range/2": func(args []jqFilter) jqFilter {
+			return jqRange(args[0], args[1])
+		},
+		"has/1": valueFunc(func(in, key interface{}) (interface{}, error) {
+			switch x := in.(type) {
+			case map[string]interface{}:
+				k, ok := key.(string)
+				if !ok {
+					return nil, fmt.Errorf("cannot check whether an object has a %s key", jsonTypeName(key))
+				}
+				_, has := x[k]
+				return has, nil
+			case []interface{}:
+				n, ok := key.(float64)
+				if !ok {
+					return nil, fmt.Errorf("cannot check whether an array has a %s key", jsonTypeName(key))
+				}
+				return n >= 0 && int(n) < len(x), nil
+			}
+			return nil, fmt.Errorf("cannot check whether %s has a key", jsonTypeName(in))
+		}),
+		"contains/1": valueFunc(func(in, v interface{}) (interface{}, error) {
+			if typeRank(in) != typeRank(v) && !(isBool(in) && isBool(v)) {
+				return nil, fmt.Errorf("%s and %s cannot have their containment checked", jsonTypeName(in), jsonTypeName(v))
+			}
+			return jqContains(in, v), nil
+		}),
+		"startswith/1": stringsFunc(func(s, prefix string) interface{} { return strings.HasPrefix(s, prefix) }),
+		"endswith/1":   stringsFunc(func(s, suffix string) interface{} { return strings.HasSuffix(s, suffix) }),
+		"ltrimstr/1":   stringsFunc(func(s, prefix string) interface{} { return strings.TrimPrefix(s, prefix) }),
+		"rtrimstr/1":   stringsFunc(func(s, suffix string) interface{} { return strings.TrimSuffix(s, suffix) }),
+		"split/1":      stringsFunc(func(s, sep string) interface{} { return splitString(s, sep) }),
+		"join/1": valueFunc(func(in, sep interface{}) (interface{}, error) {
+			list, ok := in.([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("cannot join %s", jsonTypeName(in))
+			}
+			s, ok := sep.(string)
+			if !ok {
+				return nil, fmt.Errorf("join separator must be a string")
+			}
+			parts := make([]string, len(list))
+			for i, e := range list {
+				switch e.(type) {
+				case nil:
+				case []interface{}, map[string]interface{}:
+					return nil, fmt.Errorf("cannot join %s", jsonTypeName(e))
+				default:
+					parts[i] = renderValue(e, false)
+				}
+			}
+			return strings.Join(parts, s), nil
+		}),
+		"test/1": valueFunc(func(in, re interface{}) (interface{}, error) {
+			r, err := jqRegexp(re)
+			if err != nil {
+				return nil, err
+			}
+			s, ok := in.(string)
+			if !ok {
+				return nil, fmt.Errorf("cannot match %s against a regular expression", jsonTypeName(in))
+			}
+			return r.MatchString(s), nil
+		}),
+		"sub/2":  jqSub(false),
+		"gsub/2": jqSub(true),
+	}
+
+}
+
+func isBool(v interface{}) bool {
+	_, ok := v.(bool)
+	return ok
+}
+
+// jqFuncFilter wraps a function of the input as a filter
+func jqFuncFilter(fn func(interface{}) (interface{}, error)) jqFilter {
+	return func(in interface{}) ([]interface{}, error) {
+		v, err := fn(in)
+		if err != nil {
+			return nil, err
+		}
+		return []interface{}{v}, nil
+	}
+}
+
+// jqCollect gathers a filter's outputs into an array
+func jqCollect(f jqFilter) jqFilter {
+	return func(in interface{}) ([]interface{}, error) {
+		out, err := f(in)
+		if err != nil {
+			return nil, err
+		}
+		if out == nil {
+			out = []interface{}{}
+		}
+		return []interface{}{out}, nil
+	}
+}
+
+// withValues calls fn with each output of arg
+func withValues(arg jqFilter, in interface{}, fn func(v interface{}) ([]interface{}, error)) ([]interface{}, error) {
+	values, err := arg(in)
+	if err != nil {
+		return nil, err
+	}
+	var out []interface{}
+	for _, v := range values {
+		r, err := fn(v)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, r...)
+	}
+	return out, nil
+}
+
+// valueFunc makes a one-argument function that takes each value of its
+// argument
+func valueFunc(fn func(in, arg interface{}) (interface{}, error)) func([]jqFilter) jqFilter {
+	return func(args []jqFilter) jqFilter {
+		return func(in interface{}) ([]interface{}, error) {
+			return withValues(args[0], in, func(v interface{}) ([]interface{}, error) {
+				r, err := fn(in, v)
+				if err != nil {
+					return nil, err
+				}
+				return []interface{}{r}, nil
+			})
+		}
+	}
+}
+
+// stringsFunc makes a function of a string input and a string argument
+func stringsFunc(fn func(s, arg string) interface{}) func([]jqFilter) jqFilter {
+	return valueFunc(func(in, arg interface{}) (interface{}, error) {
+		s, ok := in.(string)
+		a, aok := arg.(string)
+		if !ok || !aok {
+			return nil, fmt.Errorf("needs a string input and argument, not %s and %s", jsonTypeName(in), jsonTypeName(arg))
+		}
+		return fn(s, a), nil
+	})
+}
+
+// stringFunc makes a function of a string input
+func stringFunc(fn func(string) string) func(interface{}) (interface{}, error) {
+	return func(in interface{}) (interface{}, error) {
+		s, ok := in.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s is not a string", jsonTypeName(in))
+		}
+		return fn(s), nil
+	}
+}
+
+func jqRegexp(v interface{}) (*regexp.Regexp, error) {
+	s, ok := v.(string)
+	if !ok {
+		return nil, fmt.Errorf("regular expression must be a string, not %s", jsonTypeName(v))
+	}
+	return regexp.Compile(s)
+}
+
+// jqSub replaces the first or every match of a regular expression; the
+// replacement can use $1 and ${name}
+func jqSub(global bool) func([]jqFilter) jqFilter {
+	return func(args []jqFilter) jqFilter {
+		return func(in interface{}) ([]interface{}, error) {
+			s, ok := in.(string)
+			if !ok {
+				return nil, fmt.Errorf("cannot substitute in %s", jsonTypeName(in))
+			}
+			return withValues(args[0], in, func(re interface{}) ([]interface{}, error) {
+				r, err := jqRegexp(re)
+				if err != nil {
+					return nil, err
+				}
+				return withValues(args[1], in, func(repl interface{}) ([]interface{}, error) {
+					rs, ok := repl.(string)
+					if !ok {
+						return nil, fmt.Errorf("replacement must be a string, not %s", jsonTypeName(repl))
+					}
+					if global {
+						return []interface{}{r.ReplaceAllString(s, rs)}, nil
+					}
+					loc := r.FindStringSubmatchIndex(s)
+					if loc == nil {
+						return []interface{}{s}, nil
+					}
+					var dst []byte
+					dst = r.ExpandString(dst, rs, s, loc)
+					return []interface{}{s[:loc[0]] + string(dst) + s[loc[1]:]}, nil
+				})
+			})
+		}
+	}
+}
+
+func jqRange(from, to jqFilter) jqFilter {
+	return func(in interface{}) ([]interface{}, error) {
+		return withValues(from, in, func(f interface{}) ([]interface{}, error) {
+			return withValues(to, in, func(t interface{}) ([]interface{}, error) {
+				lo, ok1 := f.(float64)
+				hi, ok2 := t.(float64)
+				if !ok1 || !ok2 {
+					return nil, fmt.Errorf("range bounds must be numbers")
+				}
+				if hi-lo > 100000 {
+					return nil, fmt.Errorf("range of %g values is too large", hi-lo)
+				}
+				var out []interface{}
+				for n := lo; n < hi; n++ {
+					out = append(out, n)
+				}
+				return out, nil
+			})
+		})
+	}
+}
+
+// jqBy evaluates key for each element of the input array and combines
+// the elements and their keys
+func jqBy(key jqFilter, combine func(items []interface{}, keys [][]interface{}) interface{}) jqFilter {
+	return func(in interface{}) ([]interface{}, error) {
+		items, ok := in.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("cannot sort or group %s; use an array", jsonTypeName(in))
+		}
+		keys := make([][]interface{}, len(items))
+		for i, item := range items {
+			k, err := key(item)
+			if err != nil {
+				return nil, err
+			}
+			keys[i] = k
+		}
+		return []interface{}{combine(items, keys)}, nil
+	}
+}
+
+// byKey orders element indexes by their keys, keeping equal ones in order
+func byKey(keys [][]interface{}) []int {
+	order := make([]int, len(keys))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return compareValues(stringsOrValues(keys[order[a]]), stringsOrValues(keys[order[b]])) < 0
+	})
+	return order
+}
+
+func stringsOrValues(v []interface{}) interface{} {
+	if v == nil {
+		return []interface{}{}
+	}
+	return v
+}
+
+func jqSortBy(items []interface{}, keys [][]interface{}) interface{} {
+	out := make([]interface{}, 0, len(items))
+	for _, i := range byKey(keys) {
+		out = append(out, items[i])
+	}
+	return out
+}
+
+func jqGroupBy(items []interface{}, keys [][]interface{}) interface{} {
+	groups := []interface{}{}
+	var group []interface{}
+	var last []interface{}
+	for n, i := range byKey(keys) {
+		if n > 0 && compareValues(stringsOrValues(keys[i]), stringsOrValues(last)) != 0 {
+			groups = append(groups, group)
+			group = nil
+		}
+		group = append(group, items[i])
+		last = keys[i]
+	}
+	if group != nil {
+		groups = append(groups, group)
+	}
+	return groups
+}
+
+func jqUniqueBy(items []interface{}, keys [][]interface{}) interface{} {
+	out := []interface{}{}
+	for _, g := range jqGroupBy(items, keys).([]interface{}) {
+		out = append(out, g.([]interface{})[0])
+	}
+	return out
+}
+
+func extremeBy(items []interface{}, keys [][]interface{}, sign int) interface{} {
+	if len(items) == 0 {
+		return nil
+	}
+	best := 0
+	for i := 1; i < len(items); i++ {
+		c := compareValues(stringsOrValues(keys[i]), stringsOrValues(keys[best]))
+		// max keeps the last of equal elements, min the first
+		if c*sign > 0 || c == 0 && sign > 0 {
+			best = i
+		}
+	}
+	return items[best]
+}
+
+func jqLength(in interface{}) (interface{}, error) {
+	switch x := in.(type) {
+	case nil:
+		return 0.0, nil
+	case bool:
+		return nil, fmt.Errorf("boolean has no length")
+	case float64:
+		return math.Abs(x), nil
+	case string:
+		return float64(utf8.RuneCountInString(x)), nil
+	case []interface{}:
+		return float64(len(x)), nil
+	case map[string]interface{}:
+		return float64(len(x)), nil
+	}
+	return nil, fmt.Errorf("%s has no length", jsonTypeName(in))
+}
+
+func jqKeys(in interface{}) (interface{}, error) {
+	switch x := in.(type) {
+	case map[string]interface{}:
+		return stringsToValues(sortedMapKeys(x)), nil
+	case []interface{}:
+		out := make([]interface{}, len(x))
+		for i := range x {
+			out[i] = float64(i)
+		}
+		return out, nil
+	}
+	return nil, fmt.Errorf("%s has no keys", jsonTypeName(in))
+}
+
+func jqAdd(in interface{}) (interface{}, error) {
+	values, err := jqIterate(in)
+	if err != nil {
+		return nil, err
+	}
+	var sum interface{}
+	for _, v := range values {
+		if sum, err = jqArithmetic("+", sum, v); err != nil {
+			return nil, err
+		}
+	}
+	return sum, nil
+}
+
+// jqAnyAll is any, or all if anyOf is false
+func jqAnyAll(in interface{}, anyOf bool) (interface{}, error) {
+	values, err := jqIterate(in)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range values {
+		if jqTruthy(v) == anyOf {
+			return anyOf, nil
+		}
+	}
+	return !anyOf, nil
+}
+
+func jqExtreme(in interface{}, sign int) (interface{}, error) {
+	items, ok := in.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s has no elements", jsonTypeName(in))
+	}
+	keys := make([][]interface{}, len(items))
+	for i, item := range items {
+		keys[i] = []interface{}{item}
+	}
+	return extremeBy(items, keys, sign), nil
+}
+
+func jqSort(in interface{}) (interface{}, error) {
+	items, ok := in.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("cannot sort %s", jsonTypeName(in))
+	}
+	out := append([]interface{}{}, items...)
+	sort.SliceStable(out, func(a, b int) bool { return compareValues(out[a], out[b]) < 0 })
+	return out, nil
+}
+
+func jqUnique(in interface{}) (interface{}, error) {
+	sorted, err := jqSort(in)
+	if err != nil {
+		return nil, err
+	}
+	out := []interface{}{}
+	for _, v := range sorted.([]interface{}) {
+		if len(out) == 0 || compareValues(out[len(out)-1], v) != 0 {
+			out = append(out, v)
+		}
+	}
+	return out, nil
+}
+
+func jqReverse(in interface{}) (interface{}, error) {
+	switch x := in.(type) {
+	case nil:
+		return []interface{}{}, nil
+	case string:
+		r := []rune(x)
+		for i, j := 0, len(r)-1; i < j; i, j = i+1, j-1 {
+			r[i], r[j] = r[j], r[i]
+		}
+		return string(r), nil
+	case []interface{}:
+		out := make([]interface{}, len(x))
+		for i, v := range x {
+			out[len(x)-1-i] = v
+		}
+		return out, nil
+	}
+	return nil, fmt.Errorf("cannot reverse %s", jsonTypeName(in))
+}
+
+func jqFlatten(in interface{}) (interface{}, error) {
+	items, ok := in.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("cannot flatten %s", jsonTypeName(in))
+	}
+	out := []interface{}{}
+	for _, v := range items {
+		if inner, ok := v.([]interface{}); ok {
+			flat, _ := jqFlatten(inner)
+			out = append(out, flat.([]interface{})...)
+		} else {
+			out = append(out, v)
+		}
+	}
+	return out, nil
+}
+
+// jqEnd is first or last: of an array, its element; of a string, its
+// first line or last non-empty line
+func jqEnd(in interface{}, first bool) (interface{}, error) {
+	switch x := in.(type) {
+	case []interface{}:
+		if len(x) == 0 {
+			return nil, nil
+		}
+		if first {
+			return x[0], nil
+		}
+		return x[len(x)-1], nil
+	case string:
+		if first {
+			line, _, _ := strings.Cut(x, "\n")
+			return line, nil
+		}
+		lines := strings.Split(x, "\n")
+		for i := len(lines) - 1; i >= 0; i-- {
+			if strings.TrimSpace(lines[i]) != "" {
+				return lines[i], nil
+			}
+		}
+		return x, nil
+	}
+	return nil, fmt.Errorf("%s has no elements", jsonTypeName(in))
+}
+
+// jqLines counts the lines of a string
+func jqLines(in interface{}) (interface{}, error) {
+	s, ok := in.(string)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a string", jsonTypeName(in))
+	}
+	return float64(strings.Count(s, "\n") + 1), nil
+}
+
+func jqToNumber(in interface{}) (interface{}, error) {
+	switch x := in.(type) {
+	case float64:
+		return x, nil
+	case string:
+		n, err := strconv.ParseFloat(strings.TrimSpace(x), 64)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse %q as a number", x)
+		}
+		return n, nil
+	}
+	return nil, fmt.Errorf("cannot parse %s as a number", jsonTypeName(in))
+}
+
+// jqFromJSON parses JSON text, finding the JSON in model output wrapped
+// in code fences or prose. Values that are already parsed pass through.
+func jqFromJSON(in interface{}) (interface{}, error) {
+	s, ok := in.(string)
+	if !ok {
+		return in, nil
+	}
+	var v interface{}
+	if err := json.Unmarshal([]byte(strings.TrimSpace(s)), &v); err == nil {
+		return v, nil
+	}
+	raw := extractJSONValue(s)
+	if raw == "" {
+		return nil, fmt.Errorf("the input contains no JSON")
+	}
+	if err := json.Unmarshal([]byte(raw), &v); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %v", err)
+	}
+	return v, nil
+}
+
+// jqFromYAML parses YAML text, skipping code fences. Values that are
+// already parsed pass through.
+func jqFromYAML(in interface{}) (interface{}, error) {
+	s, ok := in.(string)
+	if !ok {
+		return in, nil
+	}
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "```") {
+		if nl := strings.Index(s, "\n"); nl >= 0 {
+			s = s[nl+1:]
+		}
+		s = strings.TrimSuffix(strings.TrimSpace(s), "```")
+	}
+	var v interface{}
+	if err := yaml.Unmarshal([]byte(s), &v); err != nil {
+		return nil, fmt.Errorf("invalid YAML: %v", err)
+	}
+	return normalizeValue(v), nil
+}
+
+func jqToYAML(in interface{}) (interface{}, error) {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(in); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return strings.TrimRight(buf.String(), "\n"), nil
+}
+
+func jqToEntries(in interface{}) (interface{}, error) {
+	m, ok := in.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s has no entries", jsonTypeName(in))
+	}
+	out := make([]interface{}, 0, len(m))
+	for _, k := range sortedMapKeys(m) {
+		out = append(out, map[string]interface{}{"key": k, "value": m[k]})
+	}
+	return out, nil
+}
+
+func jqFromEntries(in interface{}) (interface{}, error) {
+	items, ok := in.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("cannot make an object from %s", jsonTypeName(in))
+	}
+	out := make(map[string]interface{}, len(items))
+	for _, item := range items {
+		e, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("entries must be objects, not %s", jsonTypeName(item))
+		}
+		var key interface{}
+		for _, name := range []string{"key", "k", "name", "Name", "Key", "K"} {
+			if k, ok := e[name]; ok && k != nil {
+				key = k
+				break
+			}
+		}
+		var value interface{}
+		for _, name := range []string{"value", "v", "Value", "V"} {
+			if v, ok := e[name]; ok {
+				value = v
+				break
+			}
+		}
+		switch k := key.(type) {
+		case string:
+			out[k] = value
+		case float64, bool:
+			out[renderValue(k, false)] = value
+		default:
+			return nil, fmt.Errorf("entry key must be a string, not %s", jsonTypeName(key))
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,119 @@
+package repos
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransform_Apply(t *testing.T) {
+	data := map[string]interface{}{
+		"summary": "two issues",
+		"issues": []interface{}{
+			map[string]interface{}{"title": "SQL injection", "severity": "high", "line": 42.0},
+			map[string]interface{}{"title": "Unused import", "severity": "low", "line": 3.0},
+			map[string]interface{}{"title": "Race", "severity": "high", "line": 7.0},
+		},
+	}
+
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{".summary", "two issues"},
+		{".issues | length", "3"},
+		{".issues[0].title", "SQL injection"},
+		{".issues[-1].line", "7"},
+		{".issues[] | select(.severity == \"high\") | .title", "SQL injection\nRace"},
+		{"[.issues[] | .line] | sort | .[1:]", "[\n  7,\n  42\n]"},
+		{".issues | map(\"\\(.line): \\(.title)\") | join(\"; \")", "42: SQL injection; 3: Unused import; 7: Race"},
+		{".issues | sort_by(.line) | first | .title", "Unused import"},
+		{".issues | group_by(.severity) | map({severity: .[0].severity, count: length}) | @json \"\\(.)\"", `[{"count":2,"severity":"high"},{"count":1,"severity":"low"}]`},
+		{".issues | map(select(.line > 5 and .severity != \"low\")) | length", "2"},
+		{".missing // \"none\"", "none"},
+		{"if .issues | any(.severity == \"high\") then \"blocked\" else \"ok\" end", "blocked"},
+		{".issues[] | [.title, .line] | @csv", "\"SQL injection\",42\n\"Unused import\",3\n\"Race\",7"},
+		{"{summary, total: (.issues | length)} | to_entries | map(.key) | join(\",\")", "summary,total"},
+		{".issues | map(.line) | add / length | floor", "17"},
+		{".[\"summary\"] | ascii_upcase | split(\" \") | reverse | join(\" \")", "ISSUES TWO"},
+		{".summary | test(\"^two\") and (. | startswith(\"two\"))", "true"},
+		{".summary | gsub(\"(\\\\w+) (\\\\w+)\"; \"$2 $1\")", "issues two"},
+		{"[limit(2; .issues[])] | length", "2"},
+		{"[range(3)] | map(. * 2) | @tsv", "0\t2\t4"},
+		{".issues[0] | keys | .[0]", "line"},
+		{".issues[5]?.title", "null"},
+		{".summary[0:3]", "two"},
+	}
+	for _, tt := range tests {
+		tr, err := CompileTransform(tt.expr)
+		require.NoError(t, err, tt.expr)
+		got, _, err := tr.Apply("", data)
+		require.NoError(t, err, tt.expr)
+		assert.Equal(t, tt.want, got, tt.expr)
+	}
+}
+
+func TestTransform_ParsesModelOutput(t *testing.T) {
+	text := "Here you go:\n```json\n{\"label\": \"bug\", \"tags\": [\"crash\", \"ui\"]}\n```"
+	output, data, err := applyTransform("fromjson | .tags", text, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "[\n  \"crash\",\n  \"ui\"\n]", output)
+	assert.Equal(t, []interface{}{"crash", "ui"}, data)
+
+	output, data, err = applyTransform("fromyaml | .steps[] | select(.ok) | .name", "```yaml\nsteps:\n  - name: build\n    ok: true\n  - name: test\n    ok: false\n  - name: lint\n    ok: true\n```", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "build\nlint", output)
+	assert.Equal(t, []interface{}{"build", "lint"}, data)
+
+	output, _, err = applyTransform("fromyaml | .count + 1 | tostring", "count: 2", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "3", output)
+
+	output, _, err = applyTransform("{a: 1} | toyaml", "", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "a: 1", output)
+
+	_, _, err = applyTransform("fromjson", "no json here", nil)
+	assert.ErrorContains(t, err, "the input contains no JSON")
+}
+
+func TestTransform_Legacy(t *testing.T) {
+	text := "  first line\nsecond line\nlast line\n\n"
+	data := map[string]interface{}{"ignored": true}
+	tests := map[string]string{
+		"trim":  "first line\nsecond line\nlast line",
+		"upper": "  FIRST LINE\nSECOND LINE\nLAST LINE\n\n",
+		"lower": text,
+		"lines": "5",
+		"first": "  first line",
+		"last":  "last line",
+	}
+	for expr, want := range tests {
+		got, _, err := applyTransform(expr, text, data)
+		require.NoError(t, err, expr)
+		assert.Equal(t, want, got, expr)
+	}
+
+	got, _, err := applyTransform("json.user.name", `{"user": {"name": "ada"}}`, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "ada", got)
+}
+
+func TestCompileTransform_Errors(t *testing.T) {
+	for expr, want := range map[string]string{
+		"":               "empty expression",
+		".a |":           "unexpected end of expression",
+		"map(.a":         `expected ")"`,
+		"nope":           "unknown function nope/0",
+		"\"unterminated": "unterminated string",
+		"{(.a)}":         "computed key",
+		"if . then 1":    `expected "end"`,
+	} {
+		_, err := CompileTransform(expr)
+		assert.ErrorContains(t, err, want, expr)
+	}
+
+	_, _, err := applyTransform(".a.b", "", map[string]interface{}{"a": "text"})
+	assert.ErrorContains(t, err, `cannot index string with "b"`)
+}
@@ -0,0 +1,204 @@
+package repos
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Trust sources
+const (
+	TrustSourceFlag = "key"  // given with scmd repo add --key
+	TrustSourceTOFU = "tofu" // accepted when first seen
+)
+
+// errNotFound is returned by get for a 404
+var errNotFound = errors.New("not found")
+
+// TrustedKey is a repository signing key the user has accepted
+type TrustedKey struct {
+	Repo      string    `json:"repo"`
+	KeyID     string    `json:"key_id"`
+	PublicKey string    `json:"public_key"`
+	Source    string    `json:"source"`
+	AddedAt   time.Time `json:"added_at"`
+}
+
+// RequireSigned reports whether repos without a trusted key are refused,
+// set with SCMD_REQUIRE_SIGNED_REPOS=1
+func RequireSigned() bool {
+	return strings.TrimSpace(os.Getenv("SCMD_REQUIRE_SIGNED_REPOS")) == "1"
+}
+
+// trustFile returns the path to trusted_keys.json
+func (m *Manager) trustFile() string {
+	return filepath.Join(m.dataDir, "trusted_keys.json")
+}
+
+func (m *Manager) loadTrust() error {
+	data, err := os.ReadFile(m.trustFile())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	var keys []*TrustedKey
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return fmt.Errorf("parse trusted keys: %w", err)
+	}
+	for _, k := range keys {
+		m.trusted[k.Repo] = k
+	}
+	return nil
+}
+
+func (m *Manager) saveTrust() error {
+	keys := make([]*TrustedKey, 0, len(m.trusted))
+	for _, k := range m.trusted {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Repo < keys[j].Repo })
+
+	data, err := json.MarshalIndent(keys, "", "  ")
+	if err != nil {
+		return err
+	}
+	// Only the owner may change which keys are trusted
+	return os.WriteFile(m.trustFile(), data, 0600)
+}
+
+// Trust records key as the signing key of a repository, replacing any
+// previous key
+func (m *Manager) Trust(repo string, key *PublicKey, source string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.trusted[repo] = &TrustedKey{
+		Repo:      repo,
+		KeyID:     key.KeyID(),
+		PublicKey: key.String(),
+		Source:    source,
+		AddedAt:   time.Now(),
+	}
+}
+
+// Revoke forgets a repository's signing key
+func (m *Manager) Revoke(repo string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.trusted[repo]; !ok {
+		return fmt.Errorf("no trusted key for repository '%s'", repo)
+	}
+	delete(m.trusted, repo)
+	return nil
+}
+
+// TrustedKey returns a repository's trusted signing key
+func (m *Manager) TrustedKey(repo string) (*TrustedKey, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	k, ok := m.trusted[repo]
+	return k, ok
+}
+
+// TrustedKeys returns all trusted keys, sorted by repository
+func (m *Manager) TrustedKeys() []*TrustedKey {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	keys := make([]*TrustedKey, 0, len(m.trusted))
+	for _, k := range m.trusted {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Repo < keys[j].Repo })
+	return keys
+}
+
+// FetchPublicKey fetches the key a repository publishes as scmd-repo.pub.
+// It returns nil if the repository publishes none.
+func (m *Manager) FetchPublicKey(ctx context.Context, repo *Repository) (*PublicKey, error) {
+	data, err := m.fetch(ctx, repo, PublicKeyFile)
+	if errors.Is(err, errNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("fetch public key: %w", err)
+	}
+	return ParsePublicKey(string(data))
+}
+
+// verify checks a file fetched from repo against its detached signature.
+// Repos without a trusted key are accepted unsigned unless
+// SCMD_REQUIRE_SIGNED_REPOS is set.
+func (m *Manager) verify(ctx context.Context, repo *Repository, path string, data []byte) error {
+	trusted, ok := m.TrustedKey(repo.Name)
+	if !ok {
+		if RequireSigned() {
+			return fmt.Errorf("%w: repository '%s' has no trusted key (see 'scmd repo add --key')", ErrSignature, repo.Name)
+		}
+		return nil
+	}
+
+	key, err := ParsePublicKey(trusted.PublicKey)
+	if err != nil {
+		return fmt.Errorf("trusted key for '%s': %w", repo.Name, err)
+	}
+
+	sigData, err := m.fetch(ctx, repo, path+SignatureExt)
+	if err != nil {
+		if errors.Is(err, errNotFound) {
+			return fmt.Errorf("%w: %s is not signed", ErrSignature, path)
+		}
+		return fmt.Errorf("fetch signature for %s: %w", path, err)
+	}
+	sig, err := ParseSignature(sigData)
+	if err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrSignature, path, err)
+	}
+	if err := key.Verify(data, sig); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	// The comment is signed too, so it binds the signature to the path
+	if signed := sig.SignedFile(); signed != filepath.ToSlash(filepath.Clean(path)) {
+		if signed == "" {
+			return fmt.Errorf("%w: %s: trusted comment does not name the file (sign with -t \"file:%s\")", ErrSignature, path, path)
+		}
+		return fmt.Errorf("%w: %s: signature is for %s", ErrSignature, path, signed)
+	}
+	return nil
+}
+
+// get fetches a URL, returning errNotFound for a 404
+func (m *Manager) get(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := m.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("status %d: %w", resp.StatusCode, errNotFound)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status %d", resp.StatusCode)
+	}
+
+	return io.ReadAll(resp.Body)
+}
@@ -0,0 +1,351 @@
+package repos
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Every installed version of a command is kept under
+// <installDir>/.versions/<name>/<version>.yaml. The active version is also
+// copied to <installDir>/<name>.yaml, which is what the loader reads.
+
+// versionsDirName is hidden so that the loader skips it
+const versionsDirName = ".versions"
+
+var unsafeVersionChars = regexp.MustCompile(`[^0-9A-Za-z.+_-]`)
+
+// versionsDir returns where the versions of a command are kept
+func versionsDir(installDir, name string) string {
+	return filepath.Join(installDir, versionsDirName, name)
+}
+
+// versionPath returns where a version of a command is kept
+func versionPath(installDir, name, version string) string {
+	file := unsafeVersionChars.ReplaceAllString(version, "_")
+	if file == "" || strings.HasPrefix(file, ".") {
+		file = "unversioned" + file
+	}
+	return filepath.Join(versionsDir(installDir, name), file+".yaml")
+}
+
+// keepVersion saves data as a version of a command
+func keepVersion(installDir string, spec *CommandSpec, data []byte) error {
+	path := versionPath(installDir, spec.Name, spec.Version)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("create versions dir: %w", err)
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// InstalledVersions lists the kept versions of a command, newest first
+func (m *Manager) InstalledVersions(installDir, name string) ([]string, error) {
+	entries, err := os.ReadDir(versionsDir(installDir, name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var versions []string
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".yaml") {
+			continue
+		}
+		spec, _, err := readSpec(filepath.Join(versionsDir(installDir, name), e.Name()))
+		if err != nil {
+			continue
+		}
+		versions = append(versions, spec.Version)
+	}
+	sortVersions(versions)
+	return versions, nil
+}
+
+// ActivateVersion makes a kept version of a command the installed one
+func (m *Manager) ActivateVersion(installDir, name, version string) (*CommandSpec, []byte, error) {
+	spec, data, err := readSpec(versionPath(installDir, name, version))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil, fmt.Errorf("version %s of '%s' is not installed", version, name)
+		}
+		return nil, nil, err
+	}
+	if spec.Name != name || spec.Version != version {
+		return nil, nil, fmt.Errorf("kept file for %s@%s holds %s@%s", name, version, spec.Name, spec.Version)
+	}
+
+	if err := os.WriteFile(filepath.Join(installDir, name+".yaml"), data, 0644); err != nil {
+		return nil, nil, fmt.Errorf("write command: %w", err)
+	}
+	return spec, data, nil
+}
+
+func readSpec(path string) (*CommandSpec, []byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	var spec CommandSpec
+	if err := yaml.Unmarshal(data, &spec); err != nil {
+		return nil, nil, fmt.Errorf("parse %s: %w", filepath.Base(path), err)
+	}
+	return &spec, data, nil
+}
+
+// sortVersions sorts newest first; versions that don't parse go last
+func sortVersions(versions []string) {
+	sort.SliceStable(versions, func(i, j int) bool {
+		a, aErr := ParseVersion(versions[i])
+		b, bErr := ParseVersion(versions[j])
+		if (aErr == nil) != (bErr == nil) {
+			return aErr == nil
+		}
+		if aErr != nil {
+			return versions[i] > versions[j]
+		}
+		return a.Compare(b) > 0
+	})
+}
+
+// InstalledCmd is an installed command with its cache entry
+type InstalledCmd struct {
+	Name string
+	CachedCmd
+}
+
+// Key returns the command as repo/name
+func (i InstalledCmd) Key() string {
+	return i.Repo + "/" + i.Name
+}
+
+// Installed returns the installed commands, sorted by repo/name
+func (c *Cache) Installed() []InstalledCmd {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var out []InstalledCmd
+	for key, cmd := range c.manifest.Commands {
+		if cmd.InstalledAt.IsZero() {
+			continue
+		}
+		_, name := splitKey(key)
+		out = append(out, InstalledCmd{Name: name, CachedCmd: cmd})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Key() < out[j].Key() })
+	return out
+}
+
+// FindInstalled looks up an installed command by repo/name, or by name if
+// only one repository provides it
+func (c *Cache) FindInstalled(ref string) (InstalledCmd, error) {
+	var found []InstalledCmd
+	for _, cmd := range c.Installed() {
+		if cmd.Key() == ref || !strings.Contains(ref, "/") && cmd.Name == ref {
+			found = append(found, cmd)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return InstalledCmd{}, fmt.Errorf("'%s' is not installed from a repository", ref)
+	case 1:
+		return found[0], nil
+	}
+	return InstalledCmd{}, fmt.Errorf("'%s' is installed from several repositories; use repo/name", ref)
+}
+
+// SetPin pins an installed command to a version, or unpins it if version
+// is empty
+func (c *Cache) SetPin(repo, name, version string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := repo + "/" + name
+	cached := c.manifest.Commands[key]
+	cached.Pinned = version
+	c.manifest.Commands[key] = cached
+}
+
+// Upgrade is the plan for upgrading one installed command
+type Upgrade struct {
+	Command InstalledCmd
+	Latest  string        // newest published version
+	Target  *LockedSource // newest version allowed; nil if there is none newer
+	HeldBy  []string      // constraints of installed commands that rule out newer versions
+}
+
+// PlanUpgrade finds the newest published version of an installed command
+// that satisfies the dependency constraints of the other installed commands.
+// Pins are left to the caller.
+func (m *Manager) PlanUpgrade(ctx context.Context, r *Resolver, cache *Cache, installDir string, cmd InstalledCmd) (*Upgrade, error) {
+	available, err := r.Available(ctx, cmd.Repo, cmd.Name)
+	if err != nil {
+		return nil, err
+	}
+	up := &Upgrade{Command: cmd}
+	if len(available) == 0 {
+		return up, nil
+	}
+	up.Latest = available[0]
+
+	current, err := ParseVersion(cmd.Version)
+	currentOK := err == nil
+	held := dependentConstraints(cache, installDir, cmd)
+
+	for _, v := range available {
+		if v == cmd.Version {
+			break
+		}
+		parsed, err := ParseVersion(v)
+		if err != nil || currentOK && parsed.Compare(current) <= 0 {
+			continue
+		}
+
+		var blockers []string
+		for _, h := range held {
+			if !h.constraint.Check(parsed) {
+				blockers = append(blockers, fmt.Sprintf("%s requires %s", h.from, h.constraint))
+			}
+		}
+		if len(blockers) > 0 {
+			if len(up.HeldBy) == 0 {
+				up.HeldBy = blockers
+			}
+			continue
+		}
+
+		up.Target, err = r.Fetch(ctx, cmd.Repo, cmd.Name, v)
+		if err != nil {
+			return nil, err
+		}
+		break
+	}
+	return up, nil
+}
+
+// dependentConstraints returns the constraints installed commands place on
+// cmd
+func dependentConstraints(cache *Cache, installDir string, cmd InstalledCmd) []requirement {
+	var reqs []requirement
+	for _, other := range cache.Installed() {
+		if other.Key() == cmd.Key() {
+			continue
+		}
+		spec, _, err := readSpec(filepath.Join(installDir, other.Name+".yaml"))
+		if err != nil {
+			continue
+		}
+		for _, d := range spec.Dependencies {
+			key := d.Command
+			if !strings.Contains(key, "/") {
+				key = other.Repo + "/" + key
+			}
+			if key != cmd.Key() {
+				continue
+			}
+			if c, err := ParseConstraint(d.Version); err == nil {
+				reqs = append(reqs, requirement{from: other.Key() + "@" + other.Version, constraint: c})
+			}
+		}
+	}
+	return reqs
+}
+
+// Pins returns the pinned versions of installed commands, by repo/name
+func (c *Cache) Pins() map[string]string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	pins := make(map[string]string)
+	for key, cmd := range c.manifest.Commands {
+		if cmd.Pinned != "" && !cmd.InstalledAt.IsZero() {
+			pins[key] = cmd.Pinned
+		}
+	}
+	return pins
+}
+
+// RollbackTarget returns the version a rollback of cmd switches to: the
+// version active before the last change if it is still kept, otherwise the
+// newest kept version older than the active one
+func (m *Manager) RollbackTarget(installDir string, cmd InstalledCmd) (string, error) {
+	kept, err := m.InstalledVersions(installDir, cmd.Name)
+	if err != nil {
+		return "", err
+	}
+	for _, v := range kept {
+		if v == cmd.Previous && v != cmd.Version {
+			return v, nil
+		}
+	}
+
+	current, err := ParseVersion(cmd.Version)
+	if err == nil {
+		for _, v := range kept {
+			if parsed, err := ParseVersion(v); err == nil && parsed.Compare(current) < 0 {
+				return v, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("no earlier version of '%s' is installed", cmd.Key())
+}
+
+// SwitchVersion makes a kept version of an installed command active and
+// records it in cache
+func (m *Manager) SwitchVersion(installDir string, cache *Cache, cmd InstalledCmd, version string) (*CommandSpec, error) {
+	spec, data, err := m.ActivateVersion(installDir, cmd.Name, version)
+	if err != nil {
+		return nil, err
+	}
+
+	repo, ok := m.Get(cmd.Repo)
+	if !ok {
+		repo = &Repository{Name: cmd.Repo, URL: cmd.URL}
+	}
+	cache.RecordInstall(repo, cmd.Sources[version], spec, data)
+
+	// The version's dependencies, as far as they are installed
+	installed := make(map[string]bool)
+	for _, other := range cache.Installed() {
+		installed[other.Key()] = true
+	}
+	var deps []string
+	for _, d := range spec.Dependencies {
+		key := d.Command
+		if !strings.Contains(key, "/") {
+			key = cmd.Repo + "/" + key
+		}
+		if installed[key] {
+			deps = append(deps, key)
+		}
+	}
+	cache.RecordDependencies(cmd.Repo, cmd.Name, deps)
+	return spec, nil
+}
+
+// ChangesSince returns the changelog entries of spec newer than version, up
+// to spec's own version
+func ChangesSince(spec *CommandSpec, version string) []ChangelogEntry {
+	from, err := ParseVersion(version)
+	if err != nil {
+		return nil
+	}
+	to, toErr := ParseVersion(spec.Version)
+
+	var out []ChangelogEntry
+	for _, e := range spec.Changelog {
+		v, err := ParseVersion(e.Version)
+		if err != nil || v.Compare(from) <= 0 || toErr == nil && v.Compare(to) > 0 {
+			continue
+		}
+		out = append(out, e)
+	}
+	return out
+}
@@ -0,0 +1,168 @@
+package repos
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// installVersions installs versions of repo/name in order, as repo install
+// would
+func installVersions(t *testing.T, m *Manager, cache *Cache, installDir, repo, name string, versions ...string) {
+	for _, v := range versions {
+		spec := &CommandSpec{Name: name, Version: v}
+		data := []byte("name: " + name + "\nversion: " + v + "\n")
+		require.NoError(t, m.InstallCommandData(spec, data, installDir))
+		cache.RecordInstall(&Repository{Name: repo}, "commands/"+name+"-"+v+".yaml", spec, data)
+	}
+}
+
+func TestManager_Versions(t *testing.T) {
+	dir := t.TempDir()
+	installDir := t.TempDir()
+	m := NewManager(dir)
+	cache := NewCache(dir)
+
+	installVersions(t, m, cache, installDir, "deps", "fmt", "1.0.0", "1.10.0", "1.2.0")
+
+	versions, err := m.InstalledVersions(installDir, "fmt")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1.10.0", "1.2.0", "1.0.0"}, versions)
+
+	// The kept versions are not loaded as commands
+	loaded, err := m.LoadInstalledCommands(installDir)
+	require.NoError(t, err)
+	require.Len(t, loaded, 1)
+	assert.Equal(t, "1.2.0", loaded[0].Version)
+
+	cmd, err := cache.FindInstalled("fmt")
+	require.NoError(t, err)
+	assert.Equal(t, "1.10.0", cmd.Previous)
+
+	t.Run("rollback to previous", func(t *testing.T) {
+		target, err := m.RollbackTarget(installDir, cmd)
+		require.NoError(t, err)
+		assert.Equal(t, "1.10.0", target)
+	})
+
+	t.Run("switch", func(t *testing.T) {
+		spec, err := m.SwitchVersion(installDir, cache, cmd, "1.0.0")
+		require.NoError(t, err)
+		assert.Equal(t, "1.0.0", spec.Version)
+
+		data, err := os.ReadFile(filepath.Join(installDir, "fmt.yaml"))
+		require.NoError(t, err)
+		assert.Contains(t, string(data), "version: 1.0.0")
+
+		switched, err := cache.FindInstalled("deps/fmt")
+		require.NoError(t, err)
+		assert.Equal(t, "1.0.0", switched.Version)
+		assert.Equal(t, "1.2.0", switched.Previous)
+		assert.Equal(t, "commands/fmt-1.0.0.yaml", switched.File)
+
+		_, err = m.SwitchVersion(installDir, cache, switched, "3.0.0")
+		assert.ErrorContains(t, err, "version 3.0.0 of 'fmt' is not installed")
+	})
+
+	t.Run("no earlier version", func(t *testing.T) {
+		cmd := InstalledCmd{Name: "fmt", CachedCmd: CachedCmd{Repo: "deps", Version: "1.0.0"}}
+		_, err := m.RollbackTarget(installDir, cmd)
+		assert.ErrorContains(t, err, "no earlier version of 'deps/fmt' is installed")
+	})
+
+	t.Run("uninstall removes kept versions", func(t *testing.T) {
+		require.NoError(t, m.UninstallCommand("fmt", installDir))
+		versions, err := m.InstalledVersions(installDir, "fmt")
+		require.NoError(t, err)
+		assert.Empty(t, versions)
+	})
+}
+
+func TestCache_Pins(t *testing.T) {
+	dir := t.TempDir()
+	m := NewManager(dir)
+	cache := NewCache(dir)
+	installVersions(t, m, cache, t.TempDir(), "deps", "fmt", "1.0.0")
+
+	cache.SetPin("deps", "fmt", "1.0.0")
+	cache.SetPin("deps", "missing", "2.0.0")
+	assert.Equal(t, map[string]string{"deps/fmt": "1.0.0"}, cache.Pins())
+
+	cache.SetPin("deps", "fmt", "")
+	assert.Empty(t, cache.Pins())
+
+	_, err := cache.FindInstalled("lint")
+	assert.ErrorContains(t, err, "'lint' is not installed")
+}
+
+func TestManager_PlanUpgrade(t *testing.T) {
+	server := depRepo(t, map[string]string{
+		"fmt@1.0.0":  "",
+		"fmt@1.4.0":  "",
+		"fmt@2.0.0":  "",
+		"lint@1.0.0": "  - command: fmt\n    version: ^1.0\n",
+	})
+
+	dir := t.TempDir()
+	installDir := t.TempDir()
+	m := NewManager(dir)
+	m.repos["deps"] = &Repository{Name: "deps", URL: server.URL, Enabled: true}
+	cache := NewCache(dir)
+	ctx := context.Background()
+
+	installVersions(t, m, cache, installDir, "deps", "fmt", "1.0.0")
+	fmtCmd, err := cache.FindInstalled("deps/fmt")
+	require.NoError(t, err)
+
+	up, err := m.PlanUpgrade(ctx, NewResolver(m), cache, installDir, fmtCmd)
+	require.NoError(t, err)
+	assert.Equal(t, "2.0.0", up.Latest)
+	require.NotNil(t, up.Target)
+	assert.Equal(t, "2.0.0", up.Target.Spec.Version)
+	assert.Empty(t, up.HeldBy)
+
+	t.Run("held by dependents", func(t *testing.T) {
+		lint := &CommandSpec{Name: "lint", Version: "1.0.0", Dependencies: []Dependency{{Command: "fmt", Version: "^1.0"}}}
+		data := []byte("name: lint\nversion: 1.0.0\ndependencies:\n  - command: fmt\n    version: ^1.0\n")
+		require.NoError(t, m.InstallCommandData(lint, data, installDir))
+		cache.RecordInstall(m.repos["deps"], "commands/lint-1.0.0.yaml", lint, data)
+
+		up, err := m.PlanUpgrade(ctx, NewResolver(m), cache, installDir, fmtCmd)
+		require.NoError(t, err)
+		require.NotNil(t, up.Target)
+		assert.Equal(t, "1.4.0", up.Target.Spec.Version)
+		assert.Equal(t, []string{"deps/lint@1.0.0 requires ^1.0"}, up.HeldBy)
+	})
+
+	t.Run("up to date", func(t *testing.T) {
+		installVersions(t, m, cache, installDir, "deps", "fmt", "1.4.0")
+		current, err := cache.FindInstalled("fmt")
+		require.NoError(t, err)
+
+		up, err := m.PlanUpgrade(ctx, NewResolver(m), cache, installDir, current)
+		require.NoError(t, err)
+		assert.Nil(t, up.Target)
+		assert.NotEmpty(t, up.HeldBy)
+	})
+}
+
+func TestChangesSince(t *testing.T) {
+	spec := &CommandSpec{Name: "fmt", Version: "1.2.0", Changelog: []ChangelogEntry{
+		{Version: "1.3.0", Changes: []string{"unreleased"}},
+		{Version: "1.2.0", Changes: []string{"wrap long lines"}},
+		{Version: "1.1.0", Changes: []string{"sort imports"}},
+		{Version: "1.0.0", Changes: []string{"first release"}},
+	}}
+
+	changes := ChangesSince(spec, "1.0.0")
+	require.Len(t, changes, 2)
+	assert.Equal(t, "1.2.0", changes[0].Version)
+	assert.Equal(t, "1.1.0", changes[1].Version)
+
+	assert.Empty(t, ChangesSince(spec, "1.2.0"))
+	assert.Empty(t, ChangesSince(spec, "unversioned"))
+}
@@ -0,0 +1,446 @@
+package repos
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+
+	"github.com/scmd/scmd/internal/command"
+)
+
+// A workflow is a compose block of named steps:
+//
+//	compose:
+//	  steps:
+//	    - id: scan
+//	      command: find-issues
+//	    - id: triage
+//	      command: triage-issues
+//	      needs: [scan]
+//	      if: inputs.severity != 'none'
+//	    - id: report
+//	      command: write-report
+//	      needs: [scan, triage]
+//	      args:
+//	        findings: "{{.steps.scan.output}}"
+//	  output: "{{.steps.report.output}}"
+//
+// A step starts once every step it needs has finished, so independent
+// branches run concurrently. Its args, stdin and the workflow's output are
+// templates over .steps.ID.output, .data, .ok, .ran and .error, .inputs and
+// .stdin. Without a stdin template, a step reads the output of its last
+// need, or the command's input if it needs none. A step whose condition is
+// false is skipped; the steps that need it still run and can check
+// steps.ID.ran.
+
+// stepResult records the outcome of a workflow step
+type stepResult struct {
+	Ran    bool // false if its condition was not met
+	OK     bool
+	Output string
+	Data   interface{}
+	Error  string
+}
+
+// stepDone is sent when a running step finishes
+type stepDone struct {
+	id     string
+	result *command.Result
+	err    error
+}
+
+// workflow runs the steps of a compose block
+type workflow struct {
+	steps   []WorkflowStep
+	args    *command.Args
+	results map[string]*stepResult
+	git     map[string]string
+	ctx     context.Context
+}
+
+// executeWorkflow runs a workflow's steps, each once its needs are done
+func (c *Composer) executeWorkflow(
+	ctx context.Context,
+	compose *ComposeSpec,
+	args *command.Args,
+	execCtx *command.ExecContext,
+) (*command.Result, error) {
+	if err := validateWorkflow(compose.Steps); err != nil {
+		return nil, err
+	}
+
+	// Resolve every command before anything runs
+	cmds := make(map[string]command.Command)
+	for _, step := range compose.Steps {
+		cmd, ok := c.lookup(step.Command)
+		if !ok {
+			if step.OnError == "continue" {
+				continue
+			}
+			return nil, fmt.Errorf("step %s: command '%s' not found", step.ID, step.Command)
+		}
+		cmds[step.ID] = cmd
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	w := &workflow{
+		steps:   compose.Steps,
+		args:    args,
+		results: make(map[string]*stepResult),
+		ctx:     ctx,
+	}
+	started := make(map[string]bool)
+	finished := make(chan stepDone)
+	running := 0
+	var failure error
+
+	for {
+		// Start every step whose needs are done; skipped steps are done
+		// at once, so look again until nothing changes
+		for progress := true; progress && failure == nil; {
+			progress = false
+			for _, step := range w.steps {
+				if started[step.ID] || !w.ready(step) {
+					continue
+				}
+				started[step.ID] = true
+				progress = true
+
+				if step.If != "" {
+					ok, err := EvalCondition(step.If, w.resolve)
+					if err != nil {
+						failure = fmt.Errorf("step %s: condition %q: %w", step.ID, step.If, err)
+						break
+					}
+					if !ok {
+						w.results[step.ID] = &stepResult{}
+						continue
+					}
+				}
+
+				cmd, ok := cmds[step.ID]
+				if !ok {
+					w.results[step.ID] = &stepResult{Ran: true, Error: fmt.Sprintf("command '%s' not found", step.Command)}
+					continue
+				}
+
+				stepArgs, err := w.stepArgs(step)
+				if err != nil {
+					failure = fmt.Errorf("step %s: %w", step.ID, err)
+					break
+				}
+
+				running++
+				go func(id string) {
+					result, err := cmd.Execute(ctx, stepArgs, execCtx)
+					finished <- stepDone{id: id, result: result, err: err}
+				}(step.ID)
+			}
+		}
+
+		if failure != nil {
+			cancel()
+		}
+		if running == 0 {
+			break
+		}
+		done := <-finished
+		running--
+
+		step := w.step(done.id)
+		res := &stepResult{Ran: true}
+		switch {
+		case done.err != nil:
+			res.Error = done.err.Error()
+		case !done.result.Success:
+			res.Error = done.result.Error
+			res.Output = done.result.Output
+		default:
+			res.OK = true
+			res.Output = done.result.Output
+			res.Data = done.result.Data
+			if step.Transform != "" {
+				output, data, err := applyTransform(step.Transform, res.Output, res.Data)
+				if err != nil {
+					res.OK = false
+					res.Error = err.Error()
+					break
+				}
+				res.Output, res.Data = output, data
+			}
+		}
+		w.results[done.id] = res
+
+		if !res.OK && step.OnError != "continue" && failure == nil {
+			// The other branches are cancelled and waited for
+			failure = fmt.Errorf("step %s (%s): %s", step.ID, step.Command, res.Error)
+		}
+	}
+
+	if failure != nil {
+		return nil, failure
+	}
+
+	if compose.Output != "" {
+		output, err := renderWorkflowTemplate("output", compose.Output, w.values())
+		if err != nil {
+			return nil, fmt.Errorf("output: %w", err)
+		}
+		return &command.Result{Success: true, Output: output}, nil
+	}
+
+	last := w.results[w.steps[len(w.steps)-1].ID]
+	return &command.Result{
+		Success: true,
+		Output:  last.Output,
+		Data:    last.Data,
+	}, nil
+}
+
+// ready reports whether every step the step needs is done
+func (w *workflow) ready(step WorkflowStep) bool {
+	for _, need := range step.Needs {
+		if _, ok := w.results[need]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func (w *workflow) step(id string) WorkflowStep {
+	for _, step := range w.steps {
+		if step.ID == id {
+			return step
+		}
+	}
+	return WorkflowStep{}
+}
+
+// stepArgs renders a step's args and stdin
+func (w *workflow) stepArgs(step WorkflowStep) (*command.Args, error) {
+	values := w.values()
+	stepArgs := command.NewArgs()
+
+	switch {
+	case step.Stdin != "":
+		stdin, err := renderWorkflowTemplate("stdin", step.Stdin, values)
+		if err != nil {
+			return nil, fmt.Errorf("stdin: %w", err)
+		}
+		stepArgs.Options["stdin"] = stdin
+	case len(step.Needs) > 0:
+		last := w.results[step.Needs[len(step.Needs)-1]]
+		stepArgs.Options["stdin"] = last.Output
+		stepArgs.Data = last.Data
+	default:
+		stepArgs.Options["stdin"] = w.args.Options["stdin"]
+		stepArgs.Data = w.args.Data
+	}
+
+	for k, v := range step.Args {
+		value, err := renderWorkflowTemplate(k, v, values)
+		if err != nil {
+			return nil, fmt.Errorf("arg %s: %w", k, err)
+		}
+		stepArgs.Options[k] = value
+	}
+	return stepArgs, nil
+}
+
+// values is the template context: the command's inputs and stdin, and
+// the results of the steps so far
+func (w *workflow) values() map[string]interface{} {
+	inputs := make(map[string]interface{})
+	for k, v := range w.args.Options {
+		if k != "stdin" {
+			inputs[k] = v
+		}
+	}
+
+	steps := make(map[string]interface{})
+	for _, step := range w.steps {
+		res, ok := w.results[step.ID]
+		if !ok {
+			// Steps that have not run yet look like skipped ones
+			res = &stepResult{}
+		}
+		steps[step.ID] = map[string]interface{}{
+			"output": res.Output,
+			"data":   res.Data,
+			"ok":     res.OK,
+			"ran":    res.Ran,
+			"error":  res.Error,
+		}
+	}
+
+	return map[string]interface{}{
+		"stdin":  w.args.Options["stdin"],
+		"inputs": inputs,
+		"steps":  steps,
+	}
+}
+
+// resolve looks up names in step conditions: env, git and inputs as for
+// hooks, and steps.ID.output, .ok, .ran and .error
+func (w *workflow) resolve(name string) (string, error) {
+	scope, rest, _ := strings.Cut(name, ".")
+	switch scope {
+	case "env":
+		if rest == "" {
+			return "", fmt.Errorf("env needs a variable name")
+		}
+		return os.Getenv(rest), nil
+
+	case "inputs":
+		v, ok := w.args.Options[rest]
+		if !ok || rest == "stdin" {
+			return "", nil
+		}
+		return v, nil
+
+	case "git":
+		if w.git == nil {
+			w.git = gitState(w.ctx)
+		}
+		v, ok := w.git[rest]
+		if !ok {
+			return "", fmt.Errorf("unknown git property %q (want repo, dirty, clean, staged or branch)", rest)
+		}
+		return v, nil
+
+	case "steps":
+		id, field, _ := strings.Cut(rest, ".")
+		if w.step(id).ID == "" {
+			return "", fmt.Errorf("unknown step %q", id)
+		}
+		res, ok := w.results[id]
+		if !ok {
+			res = &stepResult{}
+		}
+		switch field {
+		case "output":
+			return res.Output, nil
+		case "ok":
+			return boolString(res.OK), nil
+		case "ran":
+			return boolString(res.Ran), nil
+		case "error":
+			return res.Error, nil
+		}
+		return "", fmt.Errorf("unknown step field %q (want output, ok, ran or error)", field)
+	}
+	return "", fmt.Errorf("unknown name %q", name)
+}
+
+func renderWorkflowTemplate(name, text string, values map[string]interface{}) (string, error) {
+	tmpl, err := template.New(name).Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, values); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// validateWorkflow checks step ids and needs, and that the steps have no
+// cycle
+func validateWorkflow(steps []WorkflowStep) error {
+	ids := make(map[string]bool)
+	for _, step := range steps {
+		if step.ID == "" {
+			return fmt.Errorf("workflow step without an id (command %q)", step.Command)
+		}
+		if ids[step.ID] {
+			return fmt.Errorf("step id %q is used twice", step.ID)
+		}
+		ids[step.ID] = true
+	}
+	for _, step := range steps {
+		for _, need := range step.Needs {
+			if !ids[need] {
+				return fmt.Errorf("step %s needs unknown step %q", step.ID, need)
+			}
+		}
+	}
+	if cycle := workflowCycle(steps); cycle != nil {
+		return fmt.Errorf("steps form a cycle: %s", strings.Join(cycle, " → "))
+	}
+	return nil
+}
+
+// workflowCycle returns the ids along a cycle of needs, starting and ending
+// with the same step, or nil if there is none. Unknown needs are ignored.
+func workflowCycle(steps []WorkflowStep) []string {
+	needs := make(map[string][]string)
+	for _, step := range steps {
+		needs[step.ID] = step.Needs
+	}
+
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := make(map[string]int)
+	var path []string
+	var visit func(id string) []string
+	visit = func(id string) []string {
+		switch state[id] {
+		case visiting:
+			for i, p := range path {
+				if p == id {
+					return append(append([]string{}, path[i:]...), id)
+				}
+			}
+			return nil
+		case visited:
+			return nil
+		}
+		state[id] = visiting
+		path = append(path, id)
+		for _, need := range needs[id] {
+			if _, ok := needs[need]; !ok {
+				continue
+			}
+			if cycle := visit(need); cycle != nil {
+				return cycle
+			}
+		}
+		path = path[:len(path)-1]
+		state[id] = visited
+		return nil
+	}
+
+	for _, step := range steps {
+		if cycle := visit(step.ID); cycle != nil {
+			return cycle
+		}
+	}
+	return nil
+}
+
+// stepsNeeded returns the steps id depends on, directly or through others
+func stepsNeeded(steps []WorkflowStep, id string) map[string]bool {
+	needs := make(map[string][]string)
+	for _, step := range steps {
+		needs[step.ID] = step.Needs
+	}
+	seen := make(map[string]bool)
+	var walk func(string)
+	walk = func(id string) {
+		for _, need := range needs[id] {
+			if !seen[need] {
+				seen[need] = true
+				walk(need)
+			}
+		}
+	}
+	walk(id)
+	return seen
+}
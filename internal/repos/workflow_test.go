@@ -0,0 +1,178 @@
+package repos
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/scmd/scmd/internal/command"
+)
+
+// funcCommand is a command that runs a function
+type funcCommand struct {
+	name string
+	run  func(ctx context.Context, args *command.Args) (*command.Result, error)
+}
+
+func (c *funcCommand) Name() string                 { return c.name }
+func (c *funcCommand) Aliases() []string            { return nil }
+func (c *funcCommand) Description() string          { return "" }
+func (c *funcCommand) Usage() string                { return "/" + c.name }
+func (c *funcCommand) Examples() []string           { return nil }
+func (c *funcCommand) Category() command.Category   { return command.CategoryCode }
+func (c *funcCommand) RequiresBackend() bool        { return false }
+func (c *funcCommand) Validate(*command.Args) error { return nil }
+func (c *funcCommand) Execute(ctx context.Context, args *command.Args, _ *command.ExecContext) (*command.Result, error) {
+	return c.run(ctx, args)
+}
+
+func runWorkflow(t *testing.T, registry *command.Registry, compose *ComposeSpec, stdin string) (*command.Result, error) {
+	t.Helper()
+	args := command.NewArgs()
+	args.Options["stdin"] = stdin
+	args.Options["severity"] = "high"
+	spec := &CommandSpec{Name: "flow", Compose: compose}
+	return NewComposer(registry, nil).ExecuteComposed(context.Background(), spec, args, &command.ExecContext{})
+}
+
+func TestComposer_Workflow(t *testing.T) {
+	registry := command.NewRegistry()
+	var mu sync.Mutex
+	var calls []string
+	record := func(name string) func(context.Context, *command.Args) (*command.Result, error) {
+		return func(_ context.Context, args *command.Args) (*command.Result, error) {
+			mu.Lock()
+			calls = append(calls, name)
+			mu.Unlock()
+			return command.NewResult(fmt.Sprintf("%s(%s|%s)", name, args.Options["stdin"], args.Options["extra"])), nil
+		}
+	}
+	for _, name := range []string{"scan", "lint", "triage", "report"} {
+		require.NoError(t, registry.Register(&funcCommand{name: name, run: record(name)}))
+	}
+
+	result, err := runWorkflow(t, registry, &ComposeSpec{
+		Steps: []WorkflowStep{
+			{ID: "report", Command: "report", Needs: []string{"scan", "triage"},
+				Stdin: "{{.steps.scan.output}} + {{.steps.triage.output}}",
+				Args:  map[string]string{"extra": "{{.inputs.severity}}"}},
+			{ID: "scan", Command: "scan"},
+			{ID: "triage", Command: "triage", Needs: []string{"scan"}, If: "inputs.severity == 'high'"},
+			{ID: "lint", Command: "lint", Needs: []string{"scan"}, If: "steps.scan.output == 'nothing'"},
+		},
+		Output: "{{.steps.report.output}}; lint ran: {{.steps.lint.ran}}",
+	}, "code")
+	require.NoError(t, err)
+	require.True(t, result.Success, result.Error)
+
+	assert.Equal(t, "report(scan(code|) + triage(scan(code|)|)|high); lint ran: false", result.Output)
+	assert.Equal(t, []string{"scan", "triage", "report"}, calls)
+
+	t.Run("last step's output by default", func(t *testing.T) {
+		result, err := runWorkflow(t, registry, &ComposeSpec{Steps: []WorkflowStep{
+			{ID: "scan", Command: "scan"},
+			{ID: "triage", Command: "triage", Needs: []string{"scan"}, Transform: "upper"},
+		}}, "code")
+		require.NoError(t, err)
+		assert.Equal(t, "TRIAGE(SCAN(CODE|)|)", result.Output)
+	})
+}
+
+func TestComposer_WorkflowConcurrency(t *testing.T) {
+	registry := command.NewRegistry()
+
+	// Both branches must be running at once for either to finish
+	var started sync.WaitGroup
+	started.Add(2)
+	branch := func(name string) *funcCommand {
+		return &funcCommand{name: name, run: func(ctx context.Context, _ *command.Args) (*command.Result, error) {
+			started.Done()
+			done := make(chan struct{})
+			go func() { started.Wait(); close(done) }()
+			select {
+			case <-done:
+				return command.NewResult(name), nil
+			case <-time.After(5 * time.Second):
+				return nil, fmt.Errorf("%s ran alone", name)
+			}
+		}}
+	}
+	require.NoError(t, registry.Register(branch("left")))
+	require.NoError(t, registry.Register(branch("right")))
+
+	result, err := runWorkflow(t, registry, &ComposeSpec{
+		Steps: []WorkflowStep{
+			{ID: "left", Command: "left"},
+			{ID: "right", Command: "right"},
+		},
+		Output: "{{.steps.left.output}} {{.steps.right.output}}",
+	}, "")
+	require.NoError(t, err)
+	assert.Equal(t, "left right", result.Output)
+}
+
+func TestComposer_WorkflowFailure(t *testing.T) {
+	registry := command.NewRegistry()
+	require.NoError(t, registry.Register(&funcCommand{name: "fail", run: func(context.Context, *command.Args) (*command.Result, error) {
+		return &command.Result{Success: false, Error: "boom"}, nil
+	}}))
+	require.NoError(t, registry.Register(&funcCommand{name: "slow", run: func(ctx context.Context, _ *command.Args) (*command.Result, error) {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(5 * time.Second):
+			return command.NewResult("finished"), nil
+		}
+	}}))
+	require.NoError(t, registry.Register(&funcCommand{name: "echo", run: func(_ context.Context, args *command.Args) (*command.Result, error) {
+		return command.NewResult("echo " + args.Options["stdin"]), nil
+	}}))
+
+	t.Run("stops and cancels the other branches", func(t *testing.T) {
+		begin := time.Now()
+		_, err := runWorkflow(t, registry, &ComposeSpec{Steps: []WorkflowStep{
+			{ID: "slow", Command: "slow"},
+			{ID: "bad", Command: "fail"},
+			{ID: "after", Command: "echo", Needs: []string{"bad"}},
+		}}, "")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "step bad (fail): boom")
+		assert.Less(t, time.Since(begin), 5*time.Second)
+	})
+
+	t.Run("continue", func(t *testing.T) {
+		result, err := runWorkflow(t, registry, &ComposeSpec{
+			Steps: []WorkflowStep{
+				{ID: "bad", Command: "fail", OnError: "continue"},
+				{ID: "missing", Command: "not-installed", OnError: "continue"},
+				{ID: "after", Command: "echo", Needs: []string{"bad", "missing"}, If: "!steps.bad.ok"},
+			},
+			Output: "{{.steps.bad.error}}; {{.steps.missing.error}}; {{.steps.after.output}}",
+		}, "")
+		require.NoError(t, err)
+		assert.Equal(t, "boom; command 'not-installed' not found; echo ", result.Output)
+	})
+
+	t.Run("invalid graphs", func(t *testing.T) {
+		_, err := runWorkflow(t, registry, &ComposeSpec{Steps: []WorkflowStep{
+			{ID: "a", Command: "echo", Needs: []string{"b"}},
+			{ID: "b", Command: "echo", Needs: []string{"a"}},
+		}}, "")
+		assert.EqualError(t, err, "steps form a cycle: a → b → a")
+
+		_, err = runWorkflow(t, registry, &ComposeSpec{Steps: []WorkflowStep{
+			{ID: "a", Command: "echo", Needs: []string{"z"}},
+		}}, "")
+		assert.EqualError(t, err, `step a needs unknown step "z"`)
+
+		_, err = runWorkflow(t, registry, &ComposeSpec{Steps: []WorkflowStep{
+			{ID: "a", Command: "not-installed"},
+		}}, "")
+		assert.EqualError(t, err, "step a: command 'not-installed' not found")
+	})
+}
@@ -0,0 +1,86 @@
+package sandbox
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// auditMu keeps concurrent runs from interleaving their records
+var auditMu sync.Mutex
+
+// AuditRecord is a line of the audit log. Environment values are left
+// out, since they may hold secrets.
+type AuditRecord struct {
+	Time       time.Time `json:"time"`
+	Command    string    `json:"command,omitempty"`
+	Source     string    `json:"source"`
+	Args       []string  `json:"args"`
+	Dir        string    `json:"dir"`
+	ExitCode   int       `json:"exit_code"`
+	DurationMS int64     `json:"duration_ms"`
+	TimedOut   bool      `json:"timed_out,omitempty"`
+	Truncated  bool      `json:"truncated,omitempty"`
+	Isolated   bool      `json:"network_isolated,omitempty"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// auditLog appends records to a file; a nil auditLog records nothing
+type auditLog struct {
+	f *os.File
+}
+
+func openAudit(path string) (*auditLog, error) {
+	if path == "" {
+		return nil, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("audit log: %w", err)
+	}
+	// Only the owner may read what was run
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("audit log: %w", err)
+	}
+	return &auditLog{f: f}, nil
+}
+
+func (a *auditLog) record(p Policy, cmd Cmd, dir string, res *Result, runErr error) {
+	if a == nil {
+		return
+	}
+	rec := AuditRecord{
+		Time:       time.Now().UTC(),
+		Command:    p.Command,
+		Source:     cmd.Source,
+		Args:       cmd.Args,
+		Dir:        dir,
+		ExitCode:   res.ExitCode,
+		DurationMS: res.Duration.Milliseconds(),
+		TimedOut:   res.TimedOut,
+		Truncated:  res.Truncated,
+		Isolated:   res.Isolated,
+	}
+	if runErr != nil {
+		rec.Error = runErr.Error()
+	}
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return
+	}
+
+	auditMu.Lock()
+	defer auditMu.Unlock()
+	_, _ = a.f.Write(append(data, '\n'))
+}
+
+// Close closes the log file
+func (a *auditLog) Close() error {
+	if a == nil {
+		return nil
+	}
+	return a.f.Close()
+}
@@ -0,0 +1,38 @@
+//go:build unix
+
+package sandbox
+
+import (
+	"fmt"
+	"os/exec"
+	"syscall"
+)
+
+// limitArgs runs args through sh, which sets the CPU and memory rlimits
+// and then execs the program, so the limits are in place before it starts.
+// A limit above the hard limit already set is left as it is.
+func limitArgs(p Policy, args []string) []string {
+	script := ""
+	if p.CPU > 0 {
+		script += fmt.Sprintf("ulimit -t %d 2>/dev/null; ", max(int64(p.CPU.Seconds()), 1))
+	}
+	if p.Memory > 0 {
+		script += fmt.Sprintf("ulimit -v %d 2>/dev/null; ", max(p.Memory>>10, 1))
+	}
+	if script == "" {
+		return args
+	}
+	return append([]string{"/bin/sh", "-c", script + `exec "$@"`, "sh"}, args...)
+}
+
+// killGroup puts the program in its own process group and kills the whole
+// group when the run is cancelled, so its children don't outlive it
+func killGroup(c *exec.Cmd) {
+	if c.SysProcAttr == nil {
+		c.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	c.SysProcAttr.Setpgid = true
+	c.Cancel = func() error {
+		return syscall.Kill(-c.Process.Pid, syscall.SIGKILL)
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package sandbox
+
+import "os/exec"
+
+// isolateNetwork is only available on Linux
+func isolateNetwork(*exec.Cmd) bool {
+	return false
+}
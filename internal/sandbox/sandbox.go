@@ -0,0 +1,284 @@
+// Package sandbox runs the programs hooks and the shell tool start, with a
+// scrubbed environment, time, output and resource limits, a confined
+// working directory and, where available, no network. Every run is
+// recorded in an audit log.
+package sandbox
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/scmd/scmd/internal/config"
+)
+
+// warnings is where notices about weakened isolation are written
+var warnings io.Writer = os.Stderr
+
+// isolate sets a command up to run without network; replaceable for tests
+var isolate = isolateNetwork
+
+// DefaultEnv are the variables every program sees, if they are set
+var DefaultEnv = []string{
+	"PATH", "HOME", "USER", "LOGNAME", "SHELL", "TERM", "TZ", "TMPDIR", "LANG", "LC_*",
+}
+
+// Policy limits the programs run for a command
+type Policy struct {
+	// Command is the scmd command the programs run for, for the audit log
+	Command string
+	// Env lists the variables passed through besides DefaultEnv; NAME_*
+	// matches every variable starting with NAME_
+	Env []string
+	// Timeout bounds the wall-clock time of a run
+	Timeout time.Duration
+	// MaxOutput is how many bytes of stdout, and of stderr, are kept
+	MaxOutput int
+	// CPU and Memory set RLIMIT_CPU and RLIMIT_AS; they are not enforced
+	// on Windows
+	CPU    time.Duration
+	Memory int64
+	// Root is the directory programs must run in or below
+	Root string
+	// NoNetwork runs programs in a new network namespace, on Linux when
+	// user namespaces are available. Otherwise they run with network, and
+	// a warning is printed.
+	NoNetwork bool
+	// RequireIsolation makes a NoNetwork run fail rather than run with
+	// network when the namespace cannot be created
+	RequireIsolation bool
+	// AuditLog is the file every run is appended to, as a JSON line
+	AuditLog string
+}
+
+// Cmd is a program to run
+type Cmd struct {
+	// Source is what runs the program, such as "hook pre1" or "shell tool"
+	Source string
+	Args   []string
+	// Dir is the working directory; by default Root
+	Dir string
+	// Env are variables set for this run, as NAME=value, on top of the
+	// allowed ones
+	Env   []string
+	Stdin io.Reader
+}
+
+// Result is the outcome of a run. A program that exits with a non-zero
+// code is not an error.
+type Result struct {
+	Stdout   string
+	Stderr   string
+	ExitCode int
+	// TimedOut is set if the program was killed at the timeout
+	TimedOut bool
+	// Truncated is set if output beyond MaxOutput was dropped
+	Truncated bool
+	// Isolated is set if the program ran without network
+	Isolated bool
+	Duration time.Duration
+}
+
+// DefaultPolicy limits programs to 30 seconds, 1MB of output, 60 seconds
+// of CPU and 4GB of memory, in the working directory
+func DefaultPolicy() Policy {
+	root, _ := os.Getwd()
+	return Policy{
+		Timeout:   30 * time.Second,
+		MaxOutput: 1024 * 1024,
+		CPU:       60 * time.Second,
+		Memory:    4 << 30,
+		Root:      root,
+	}
+}
+
+// FromConfig returns the policy set in the exec section of cfg. Without a
+// config it is DefaultPolicy, with no audit log.
+func FromConfig(cfg *config.Config) Policy {
+	p := DefaultPolicy()
+	if cfg == nil {
+		return p
+	}
+	e := cfg.Exec
+	p.Env = append(p.Env, e.Env...)
+	p.Timeout = time.Duration(e.Timeout) * time.Second
+	p.MaxOutput = e.MaxOutput
+	p.CPU = time.Duration(e.CPU) * time.Second
+	p.Memory = int64(e.Memory) << 20
+	p.NoNetwork = e.NoNetwork
+	p.RequireIsolation = e.RequireIsolation
+	if e.Audit {
+		p.AuditLog = filepath.Join(config.DataDir(), "audit.log")
+	}
+	return p
+}
+
+// Run runs cmd under the policy
+func (p Policy) Run(ctx context.Context, cmd Cmd) (*Result, error) {
+	if len(cmd.Args) == 0 {
+		return nil, fmt.Errorf("no program to run")
+	}
+
+	dir, err := p.workDir(cmd.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	// Open the log first, so nothing runs unrecorded
+	audit, err := openAudit(p.AuditLog)
+	if err != nil {
+		return nil, err
+	}
+	defer audit.Close()
+
+	if p.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.Timeout)
+		defer cancel()
+	}
+
+	args := limitArgs(p, cmd.Args)
+	c := exec.CommandContext(ctx, args[0], args[1:]...)
+	c.Dir = dir
+	c.Env = append(p.environ(), cmd.Env...)
+	c.Stdin = cmd.Stdin
+	stdout := &capped{max: p.MaxOutput}
+	stderr := &capped{max: p.MaxOutput}
+	c.Stdout = stdout
+	c.Stderr = stderr
+	// Don't wait on pipes held open by the program's children
+	c.WaitDelay = time.Second
+	killGroup(c)
+
+	res := &Result{}
+	if p.NoNetwork {
+		res.Isolated = isolate(c)
+		if !res.Isolated && p.RequireIsolation {
+			return nil, fmt.Errorf("cannot run %s without network: network isolation needs Linux", cmd.Args[0])
+		}
+	}
+
+	start := time.Now()
+	err = c.Start()
+	if err != nil && res.Isolated && c.Err == nil {
+		// The program was found, but namespaces can be turned off; run
+		// without isolation instead, unless that is not allowed
+		if p.RequireIsolation {
+			err = fmt.Errorf("cannot run %s without network: %w", cmd.Args[0], err)
+		} else {
+			fmt.Fprintf(warnings, "Warning: cannot isolate %s from the network (%v); running it with network\n", cmd.Args[0], err)
+			c = rebuild(ctx, c)
+			res.Isolated = false
+			err = c.Start()
+		}
+	}
+	if err == nil {
+		err = c.Wait()
+	}
+	res.Duration = time.Since(start)
+	res.Stdout, res.Stderr = stdout.String(), stderr.String()
+	res.Truncated = stdout.dropped || stderr.dropped
+
+	var exitErr *exec.ExitError
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		res.TimedOut = true
+		res.ExitCode = -1
+		err = nil
+	case err != nil && errors.Is(ctx.Err(), context.Canceled):
+		// The caller gave up; the program was killed, it did not fail
+		err = fmt.Errorf("%s: %w", cmd.Args[0], ctx.Err())
+	case errors.As(err, &exitErr):
+		res.ExitCode = exitErr.ExitCode()
+		err = nil
+	}
+
+	audit.record(p, cmd, dir, res, err)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// workDir resolves dir and checks it is inside Root
+func (p Policy) workDir(dir string) (string, error) {
+	if p.Root == "" {
+		return dir, nil
+	}
+	root, err := filepath.EvalSymlinks(p.Root)
+	if err != nil {
+		return "", fmt.Errorf("working directory root: %w", err)
+	}
+	if dir == "" {
+		return root, nil
+	}
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(root, dir)
+	}
+	resolved, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return "", fmt.Errorf("working directory: %w", err)
+	}
+	rel, err := filepath.Rel(root, resolved)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("working directory %s is outside %s", dir, root)
+	}
+	return resolved, nil
+}
+
+// environ returns the allowed variables of scmd's environment
+func (p Policy) environ() []string {
+	allowed := append(append([]string{}, DefaultEnv...), p.Env...)
+	var env []string
+	for _, kv := range os.Environ() {
+		name, _, _ := strings.Cut(kv, "=")
+		for _, a := range allowed {
+			if name == a || strings.HasSuffix(a, "*") && strings.HasPrefix(name, strings.TrimSuffix(a, "*")) {
+				env = append(env, kv)
+				break
+			}
+		}
+	}
+	return env
+}
+
+// rebuild copies a command that failed to start, without its process
+// attributes
+func rebuild(ctx context.Context, c *exec.Cmd) *exec.Cmd {
+	n := exec.CommandContext(ctx, c.Path, c.Args[1:]...)
+	n.Dir, n.Env, n.Stdin, n.Stdout, n.Stderr, n.WaitDelay = c.Dir, c.Env, c.Stdin, c.Stdout, c.Stderr, c.WaitDelay
+	killGroup(n)
+	return n
+}
+
+// capped keeps the first max bytes written to it, and drops the rest
+type capped struct {
+	buf     bytes.Buffer
+	max     int
+	dropped bool
+}
+
+func (w *capped) Write(b []byte) (int, error) {
+	n := len(b)
+	if w.max > 0 {
+		if room := w.max - w.buf.Len(); room < len(b) {
+			w.dropped = true
+			b = b[:max(room, 0)]
+		}
+	}
+	w.buf.Write(b)
+	// Report everything written, so the program isn't stopped by a
+	// short write
+	return n, nil
+}
+
+func (w *capped) String() string {
+	return w.buf.String()
+}
@@ -0,0 +1,221 @@
+//go:build unix
+
+package sandbox
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testPolicy(t *testing.T) Policy {
+	p := DefaultPolicy()
+	p.Root = t.TempDir()
+	return p
+}
+
+func TestPolicy_Run(t *testing.T) {
+	res, err := testPolicy(t).Run(context.Background(), Cmd{
+		Args:  []string{"sh", "-c", "cat; echo err >&2; exit 3"},
+		Stdin: strings.NewReader("hello\n"),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "hello\n", res.Stdout)
+	assert.Equal(t, "err\n", res.Stderr)
+	assert.Equal(t, 3, res.ExitCode)
+	assert.False(t, res.TimedOut)
+}
+
+func TestPolicy_Env(t *testing.T) {
+	t.Setenv("SCMD_TEST_SECRET", "s3cret")
+	t.Setenv("SCMD_TEST_ALLOWED", "yes")
+	t.Setenv("GO_TEST_A", "a")
+
+	p := testPolicy(t)
+	p.Env = []string{"SCMD_TEST_ALLOWED", "GO_TEST_*"}
+	res, err := p.Run(context.Background(), Cmd{
+		Args: []string{"sh", "-c", "env"},
+		Env:  []string{"EXTRA=1"},
+	})
+	require.NoError(t, err)
+	assert.NotContains(t, res.Stdout, "SCMD_TEST_SECRET")
+	assert.Contains(t, res.Stdout, "SCMD_TEST_ALLOWED=yes")
+	assert.Contains(t, res.Stdout, "GO_TEST_A=a")
+	assert.Contains(t, res.Stdout, "EXTRA=1")
+	assert.Contains(t, res.Stdout, "PATH=")
+}
+
+func TestPolicy_Timeout(t *testing.T) {
+	p := testPolicy(t)
+	p.Timeout = 100 * time.Millisecond
+
+	start := time.Now()
+	// The background sleep holds the pipes open; the whole group is killed
+	res, err := p.Run(context.Background(), Cmd{Args: []string{"sh", "-c", "sleep 10 & sleep 10"}})
+	require.NoError(t, err)
+	assert.True(t, res.TimedOut)
+	assert.Equal(t, -1, res.ExitCode)
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
+
+func TestPolicy_MaxOutput(t *testing.T) {
+	p := testPolicy(t)
+	p.MaxOutput = 10
+
+	res, err := p.Run(context.Background(), Cmd{Args: []string{"sh", "-c", "printf '%050d' 0"}})
+	require.NoError(t, err)
+	assert.Equal(t, 0, res.ExitCode)
+	assert.Len(t, res.Stdout, 10)
+	assert.True(t, res.Truncated)
+}
+
+func TestPolicy_Limits(t *testing.T) {
+	p := testPolicy(t)
+	p.CPU = 7 * time.Second
+	p.Memory = 512 << 20
+
+	res, err := p.Run(context.Background(), Cmd{Args: []string{"sh", "-c", "ulimit -t; ulimit -v"}})
+	require.NoError(t, err)
+	assert.Equal(t, "7\n524288\n", res.Stdout)
+}
+
+func TestPolicy_WorkDir(t *testing.T) {
+	p := testPolicy(t)
+	sub := filepath.Join(p.Root, "sub")
+	require.NoError(t, os.Mkdir(sub, 0755))
+	outside := t.TempDir()
+	require.NoError(t, os.Symlink(outside, filepath.Join(p.Root, "link")))
+
+	res, err := p.Run(context.Background(), Cmd{Args: []string{"pwd"}})
+	require.NoError(t, err)
+	root, _ := filepath.EvalSymlinks(p.Root)
+	assert.Equal(t, root+"\n", res.Stdout)
+
+	res, err = p.Run(context.Background(), Cmd{Args: []string{"pwd"}, Dir: "sub"})
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(root, "sub")+"\n", res.Stdout)
+
+	for _, dir := range []string{outside, "..", "link"} {
+		_, err = p.Run(context.Background(), Cmd{Args: []string{"pwd"}, Dir: dir})
+		assert.ErrorContains(t, err, "is outside", dir)
+	}
+}
+
+func TestPolicy_NoNetwork(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("network isolation needs Linux")
+	}
+	p := testPolicy(t)
+	p.NoNetwork = true
+
+	res, err := p.Run(context.Background(), Cmd{Args: []string{"cat", "/proc/net/dev"}})
+	require.NoError(t, err)
+	if !res.Isolated {
+		t.Skip("user namespaces are not available")
+	}
+	// Only the loopback interface exists in the new namespace
+	var ifaces []string
+	for _, line := range strings.Split(res.Stdout, "\n")[2:] {
+		if name, _, ok := strings.Cut(line, ":"); ok {
+			ifaces = append(ifaces, strings.TrimSpace(name))
+		}
+	}
+	assert.Equal(t, []string{"lo"}, ifaces)
+}
+
+// failIsolation makes network isolation fail to start, as where user
+// namespaces are turned off, and returns what is warned
+func failIsolation(t *testing.T) *bytes.Buffer {
+	var warned bytes.Buffer
+	oldIsolate, oldWarnings := isolate, warnings
+	isolate = func(c *exec.Cmd) bool {
+		if c.SysProcAttr == nil {
+			c.SysProcAttr = &syscall.SysProcAttr{}
+		}
+		c.SysProcAttr.Chroot = "/nonexistent"
+		return true
+	}
+	warnings = &warned
+	t.Cleanup(func() { isolate, warnings = oldIsolate, oldWarnings })
+	return &warned
+}
+
+func TestPolicy_IsolationFallback(t *testing.T) {
+	warned := failIsolation(t)
+	p := testPolicy(t)
+	p.NoNetwork = true
+
+	res, err := p.Run(context.Background(), Cmd{Args: []string{"echo", "hi"}})
+	require.NoError(t, err)
+	assert.Equal(t, "hi\n", res.Stdout)
+	assert.False(t, res.Isolated)
+	assert.Contains(t, warned.String(), "Warning: cannot isolate echo from the network")
+}
+
+func TestPolicy_RequireIsolation(t *testing.T) {
+	warned := failIsolation(t)
+	p := testPolicy(t)
+	p.NoNetwork = true
+	p.RequireIsolation = true
+
+	_, err := p.Run(context.Background(), Cmd{Args: []string{"echo", "hi"}})
+	assert.ErrorContains(t, err, "cannot run echo without network")
+	assert.Empty(t, warned.String())
+}
+
+func TestPolicy_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	_, err := testPolicy(t).Run(ctx, Cmd{Args: []string{"sleep", "10"}})
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestPolicy_AuditLog(t *testing.T) {
+	p := testPolicy(t)
+	p.Command = "review"
+	p.AuditLog = filepath.Join(t.TempDir(), "logs", "audit.log")
+
+	_, err := p.Run(context.Background(), Cmd{Source: "hook pre1", Args: []string{"true"}})
+	require.NoError(t, err)
+	_, err = p.Run(context.Background(), Cmd{Source: "shell tool", Args: []string{"false"}})
+	require.NoError(t, err)
+	_, err = p.Run(context.Background(), Cmd{Source: "shell tool", Args: []string{"pwd"}, Dir: "/"})
+	require.Error(t, err)
+
+	info, err := os.Stat(p.AuditLog)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	f, err := os.Open(p.AuditLog)
+	require.NoError(t, err)
+	defer f.Close()
+	var records []AuditRecord
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var rec AuditRecord
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &rec))
+		records = append(records, rec)
+	}
+
+	// The run outside the root never started, so it isn't recorded
+	require.Len(t, records, 2)
+	assert.Equal(t, "review", records[0].Command)
+	assert.Equal(t, "hook pre1", records[0].Source)
+	assert.Equal(t, []string{"true"}, records[0].Args)
+	assert.Equal(t, 0, records[0].ExitCode)
+	assert.Equal(t, "shell tool", records[1].Source)
+	assert.Equal(t, 1, records[1].ExitCode)
+}
@@ -23,6 +23,10 @@ type SlashCommand struct {
 	Description string   `yaml:"description"`
 	Args        string   `yaml:"args"`  // Default args to pass
 	Stdin       bool     `yaml:"stdin"` // Whether to read stdin
+
+	// Scope is repos.ScopeProject for commands from a project's
+	// .scmd/slash.yaml, set when loaded
+	Scope string `yaml:"-"`
 }
 
 // Config holds all slash command configurations
@@ -33,6 +37,7 @@ type Config struct {
 // Runner executes slash commands
 type Runner struct {
 	config      *Config
+	project     *Config // the current project's slash commands, if any
 	dataDir     string
 	registry    *command.Registry
 	repoManager *repos.Manager
@@ -89,6 +94,38 @@ func (r *Runner) LoadConfig() error {
 	return nil
 }
 
+// LoadProject adds a project's slash commands and commands. They take
+// precedence over global ones of the same name, and are never saved to the
+// global config.
+func (r *Runner) LoadProject(p *repos.Project) error {
+	r.loader.SetProject(p)
+
+	data, err := os.ReadFile(p.SlashFile())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	var config Config
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return fmt.Errorf("%s: %w", p.SlashFile(), err)
+	}
+	for i := range config.Commands {
+		cmd := &config.Commands[i]
+		if err := validation.ValidateCommandName(cmd.Name); err != nil {
+			return fmt.Errorf("%s: invalid command name '%s' at index %d: %w", p.SlashFile(), cmd.Name, i, err)
+		}
+		if err := validation.ValidateAliases(cmd.Aliases); err != nil {
+			return fmt.Errorf("%s: command '%s' at index %d: %w", p.SlashFile(), cmd.Name, i, err)
+		}
+		cmd.Scope = repos.ScopeProject
+	}
+	r.project = &config
+	return nil
+}
+
 // SaveConfig saves the slash command configuration
 func (r *Runner) SaveConfig() error {
 	// Ensure data directory exists
@@ -185,12 +222,23 @@ func (r *Runner) Parse(input string) (cmd *SlashCommand, args []string, err erro
 	return cmd, args, nil
 }
 
-// FindCommand finds a slash command by name or alias
+// FindCommand finds a slash command by name or alias, in the project's
+// commands first
 func (r *Runner) FindCommand(name string) *SlashCommand {
+	if r.project != nil {
+		if cmd := findCommand(r.project, name); cmd != nil {
+			return cmd
+		}
+	}
+	return findCommand(r.config, name)
+}
+
+// findCommand finds a slash command by name or alias in a config
+func findCommand(config *Config, name string) *SlashCommand {
 	name = strings.ToLower(name)
 
-	for i := range r.config.Commands {
-		cmd := &r.config.Commands[i]
+	for i := range config.Commands {
+		cmd := &config.Commands[i]
 
 		// Check name
 		if strings.ToLower(cmd.Name) == name {
@@ -223,8 +271,7 @@ func (r *Runner) Run(ctx context.Context, slashCmd *SlashCommand, args []string,
 	}
 
 	// Build args
-	cmdArgs := command.NewArgs()
-	cmdArgs.Positional = args
+	cmdArgs := ParseArgs(args, commandFlags(cmd))
 
 	if stdin != "" {
 		cmdArgs.Options["stdin"] = stdin
@@ -251,6 +298,50 @@ func (r *Runner) Run(ctx context.Context, slashCmd *SlashCommand, args []string,
 	return cmd.Execute(ctx, cmdArgs, execCtx)
 }
 
+// ParseArgs splits raw arguments into positionals and the long flags a
+// command declares. --name=value becomes an option and a bare --name a
+// boolean flag; dashes and underscores in names are interchangeable. Other
+// arguments, including undeclared --words such as the --amend in "how do I
+// use git commit --amend", and everything after "--", are positional.
+func ParseArgs(args []string, flags []string) *command.Args {
+	declared := make(map[string]bool, len(flags))
+	for _, f := range flags {
+		declared[flagKey(f)] = true
+	}
+
+	cmdArgs := command.NewArgs()
+	for i, arg := range args {
+		if arg == "--" {
+			cmdArgs.Positional = append(cmdArgs.Positional, args[i+1:]...)
+			break
+		}
+		name, value, hasValue := strings.Cut(strings.TrimPrefix(arg, "--"), "=")
+		if !strings.HasPrefix(arg, "--") || !declared[flagKey(name)] {
+			cmdArgs.Positional = append(cmdArgs.Positional, arg)
+			continue
+		}
+		if hasValue {
+			cmdArgs.Options[name] = value
+		} else {
+			cmdArgs.Flags[name] = true
+		}
+	}
+	return cmdArgs
+}
+
+// commandFlags returns the flags a command declares, if any
+func commandFlags(cmd command.Command) []string {
+	if d, ok := cmd.(command.FlagDeclarer); ok {
+		return d.FlagNames()
+	}
+	return nil
+}
+
+// flagKey normalizes a flag name for matching
+func flagKey(name string) string {
+	return strings.ReplaceAll(name, "_", "-")
+}
+
 // simpleUI implements command.UI with minimal output
 type simpleUI struct{}
 
@@ -263,9 +354,20 @@ func (u *simpleUI) Spinner(message string) func() {
 	return func() {}
 }
 
-// List returns all configured slash commands
+// List returns all configured slash commands: the project's, then the
+// global ones they don't override
 func (r *Runner) List() []SlashCommand {
-	return r.config.Commands
+	if r.project == nil {
+		return r.config.Commands
+	}
+
+	commands := append([]SlashCommand{}, r.project.Commands...)
+	for _, cmd := range r.config.Commands {
+		if findCommand(r.project, cmd.Name) == nil {
+			commands = append(commands, cmd)
+		}
+	}
+	return commands
 }
 
 // Add adds a new slash command
@@ -281,12 +383,12 @@ func (r *Runner) Add(cmd SlashCommand) error {
 	}
 
 	// Check for duplicates
-	if r.FindCommand(cmd.Name) != nil {
+	if findCommand(r.config, cmd.Name) != nil {
 		return fmt.Errorf("slash command '%s' already exists", cmd.Name)
 	}
 
 	for _, alias := range cmd.Aliases {
-		if r.FindCommand(alias) != nil {
+		if findCommand(r.config, alias) != nil {
 			return fmt.Errorf("alias '%s' already exists", alias)
 		}
 	}
@@ -317,7 +419,7 @@ func (r *Runner) AddAlias(cmdName, alias string) error {
 	}
 
 	// Check alias doesn't exist
-	if r.FindCommand(alias) != nil {
+	if findCommand(r.config, alias) != nil {
 		return fmt.Errorf("alias '%s' already exists", alias)
 	}
 
@@ -333,17 +435,28 @@ func (r *Runner) AddAlias(cmdName, alias string) error {
 
 // GenerateShellIntegration generates shell functions for slash commands
 func (r *Runner) GenerateShellIntegration(shell string) string {
+	return r.ShellIntegration(shell, false)
+}
+
+// ShellIntegration generates shell functions for slash commands. With history
+// enabled, commands run by /cmd --run are added to the shell history through
+// the file named by HistoryFileEnv.
+func (r *Runner) ShellIntegration(shell string, history bool) string {
 	switch shell {
 	case "bash", "zsh":
-		return r.generateBashZsh()
+		return r.generateBashZsh(history)
 	case "fish":
-		return r.generateFish()
+		return r.generateFish(history)
 	default:
-		return r.generateBashZsh()
+		return r.generateBashZsh(history)
 	}
 }
 
-func (r *Runner) generateBashZsh() string {
+// HistoryFileEnv names the file commands are appended to for the shell
+// integration to add to its history
+const HistoryFileEnv = "SCMD_HISTORY_FILE"
+
+func (r *Runner) generateBashZsh(history bool) string {
 	var sb strings.Builder
 
 	sb.WriteString(`# scmd slash command integration
@@ -355,14 +468,45 @@ func (r *Runner) generateBashZsh() string {
         scmd slash list
         return
     fi
+`)
 
+	if history {
+		sb.WriteString(`
+    local rc line
+    # A fresh file only this user can write, so nobody can plant history
+    local -x ` + HistoryFileEnv + `
+    ` + HistoryFileEnv + `=$(mktemp "${TMPDIR:-/tmp}/scmd-history.XXXXXX") || ` + HistoryFileEnv + `=
+`)
+	}
+
+	sb.WriteString(`
     # Check if there's piped input
     if [ ! -t 0 ]; then
         cat | scmd slash run "$@"
     else
         scmd slash run "$@"
     fi
-}
+`)
+
+	if history {
+		sb.WriteString(`    rc=$?
+
+    # Add commands run by scmd to the shell history
+    if [ -n "$` + HistoryFileEnv + `" ]; then
+        while IFS= read -r line; do
+            if [ -n "$ZSH_VERSION" ]; then
+                print -rs -- "$line"
+            else
+                history -s "$line"
+            fi
+        done < "$` + HistoryFileEnv + `"
+        rm -f "$` + HistoryFileEnv + `"
+    fi
+    return $rc
+`)
+	}
+
+	sb.WriteString(`}
 
 # Individual command aliases
 `)
@@ -398,7 +542,7 @@ complete -F _scmd_slash_completions /
 	return sb.String()
 }
 
-func (r *Runner) generateFish() string {
+func (r *Runner) generateFish(history bool) string {
 	var sb strings.Builder
 
 	sb.WriteString(`# scmd slash command integration for fish
@@ -409,13 +553,38 @@ function /
         scmd slash list
         return
     end
+`)
+
+	if history {
+		sb.WriteString(`
+    # A fresh file only this user can write, so nobody can plant history
+    set -lx ` + HistoryFileEnv + ` (mktemp -t scmd-history.XXXXXX)
+`)
+	}
 
+	sb.WriteString(`
     if not isatty stdin
         cat | scmd slash run $argv
     else
         scmd slash run $argv
     end
-end
+`)
+
+	if history {
+		sb.WriteString(`    set -l status_code $status
+
+    # Add commands run by scmd to the shell history
+    if test -n "$` + HistoryFileEnv + `"
+        while read -l line
+            builtin history append -- $line
+        end < $` + HistoryFileEnv + `
+        rm -f $` + HistoryFileEnv + `
+    end
+    return $status_code
+`)
+	}
+
+	sb.WriteString(`end
 
 # Individual command aliases
 `)
@@ -6,6 +6,7 @@ import (
 	"strings"
 
 	"github.com/scmd/scmd/internal/backend"
+	"github.com/scmd/scmd/internal/sandbox"
 )
 
 // Executor handles tool execution for LLM tool calling
@@ -13,6 +14,7 @@ type Executor struct {
 	registry  *Registry
 	backend   backend.Backend
 	maxRounds int
+	jsonMode  bool
 }
 
 // NewExecutor creates a new tool executor
@@ -24,6 +26,13 @@ func NewExecutor(registry *Registry, backend backend.Backend) *Executor {
 	}
 }
 
+// SetJSONMode asks the backend for a JSON object as the final answer. Tool
+// rounds still run as usual; once tools have been called, the answer is taken
+// from a closing JSON-mode completion over their results.
+func (e *Executor) SetJSONMode(on bool) {
+	e.jsonMode = on
+}
+
 // ExecuteWithTools runs a completion with tool calling support
 func (e *Executor) ExecuteWithTools(
 	ctx context.Context,
@@ -35,6 +44,7 @@ func (e *Executor) ExecuteWithTools(
 		resp, err := e.backend.Complete(ctx, &backend.CompletionRequest{
 			Prompt:       prompt,
 			SystemPrompt: systemPrompt,
+			JSONMode:     e.jsonMode,
 		})
 		if err != nil {
 			return "", err
@@ -55,6 +65,7 @@ func (e *Executor) ExecuteWithTools(
 				SystemPrompt: systemPrompt,
 				MaxTokens:    2048,
 				Temperature:  0.7,
+				JSONMode:     e.jsonMode,
 			},
 			Tools: tools,
 		}
@@ -90,12 +101,33 @@ func (e *Executor) ExecuteWithTools(
 
 		// If all tools succeeded and we have a final answer, return it
 		if e.hasFinalAnswer(resp.Content) {
-			return e.formatFinalResponse(conversationHistory), nil
+			return e.finish(ctx, currentPrompt, systemPrompt, conversationHistory)
 		}
 	}
 
 	// Max rounds reached
-	return e.formatFinalResponse(conversationHistory), nil
+	return e.finish(ctx, currentPrompt, systemPrompt, conversationHistory)
+}
+
+// finish returns the answer once tools have been called. In JSON mode the
+// last response came back alongside tool calls, so it is not held to JSON;
+// the answer is asked for again over the tool results.
+func (e *Executor) finish(ctx context.Context, prompt, systemPrompt string, history []string) (string, error) {
+	if !e.jsonMode {
+		return e.formatFinalResponse(history), nil
+	}
+
+	resp, err := e.backend.Complete(ctx, &backend.CompletionRequest{
+		Prompt:       prompt,
+		SystemPrompt: systemPrompt,
+		MaxTokens:    2048,
+		Temperature:  0.7,
+		JSONMode:     true,
+	})
+	if err != nil {
+		return "", err
+	}
+	return resp.Content, nil
 }
 
 // buildToolResultPrompt creates a prompt with tool execution results
@@ -140,12 +172,15 @@ func (e *Executor) formatFinalResponse(history []string) string {
 	return strings.TrimSpace(lastResponse)
 }
 
-// DefaultRegistry creates a registry with all built-in tools
-func DefaultRegistry(confirmUI ConfirmUI) *Registry {
+// DefaultRegistry creates a registry with all built-in tools, running
+// shell commands under policy
+func DefaultRegistry(confirmUI ConfirmUI, policy sandbox.Policy) *Registry {
 	registry := NewRegistry(confirmUI)
 
 	// Register all built-in tools
-	registry.Register(NewShellTool(confirmUI))
+	shell := NewShellTool(confirmUI)
+	shell.SetPolicy(policy)
+	registry.Register(shell)
 	registry.Register(NewReadFileTool())
 	registry.Register(NewWriteFileTool(confirmUI))
 	registry.Register(NewHTTPGetTool())
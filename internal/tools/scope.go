@@ -0,0 +1,293 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/scmd/scmd/internal/sandbox"
+)
+
+// Scope limits what the built-in tools may touch. A tool is only available
+// when its scope is not empty.
+type Scope struct {
+	// Shell lists the programs the shell tool may run, e.g. git or go
+	Shell []string
+	// Read and Write are path globs for read_file and write_file. Relative
+	// globs are relative to Dir, and ** matches any number of directories.
+	Read  []string
+	Write []string
+	// Network lists the hosts http_get may fetch; *.example.com matches
+	// any subdomain of example.com
+	Network []string
+	// Dir is the directory relative paths are resolved against; by default
+	// the working directory
+	Dir string
+}
+
+// ScopedRegistry creates a registry with the built-in tools scope allows,
+// each limited to it, running shell commands under policy
+func ScopedRegistry(confirmUI ConfirmUI, scope Scope, policy sandbox.Policy) *Registry {
+	registry := NewRegistry(confirmUI)
+	scope.Dir = scope.dir()
+
+	if len(scope.Shell) > 0 {
+		shell := NewShellTool(confirmUI)
+		shell.SetPolicy(policy)
+		// Edited commands are checked again by the tool itself
+		shell.allowedCommands = make(map[string]bool)
+		shell.bareNames = true
+		for _, program := range scope.Shell {
+			shell.AllowCommand(program)
+		}
+		registry.Register(&scopedTool{
+			Tool:    shell,
+			allowed: "Allowed programs: " + strings.Join(scope.Shell, ", "),
+			check:   scope.checkShell,
+		})
+	}
+	if len(scope.Read) > 0 {
+		registry.Register(&scopedTool{
+			Tool:    NewReadFileTool(),
+			allowed: "Allowed paths: " + strings.Join(scope.Read, ", "),
+			check:   func(params map[string]interface{}) error { return scope.checkPath("read", scope.Read, params) },
+		})
+	}
+	if len(scope.Write) > 0 {
+		registry.Register(&scopedTool{
+			Tool:    NewWriteFileTool(confirmUI),
+			allowed: "Allowed paths: " + strings.Join(scope.Write, ", "),
+			check:   func(params map[string]interface{}) error { return scope.checkPath("write", scope.Write, params) },
+		})
+	}
+	if len(scope.Network) > 0 {
+		httpGet := NewHTTPGetTool()
+		// Redirects must stay on allowed hosts too
+		httpGet.client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 10 {
+				return fmt.Errorf("stopped after 10 redirects")
+			}
+			if !scope.allowsHost(req.URL) {
+				return fmt.Errorf("redirect to %s is not allowed by the command's permissions", req.URL.Host)
+			}
+			return nil
+		}
+		registry.Register(&scopedTool{
+			Tool:    httpGet,
+			allowed: "Allowed hosts: " + strings.Join(scope.Network, ", "),
+			check:   scope.checkURL,
+		})
+	}
+
+	return registry
+}
+
+// scopedTool checks a tool's parameters against a scope before running it
+type scopedTool struct {
+	Tool
+	allowed string
+	check   func(params map[string]interface{}) error
+}
+
+// Description tells the model what the tool may be used on
+func (t *scopedTool) Description() string {
+	return t.Tool.Description() + " " + t.allowed + "."
+}
+
+// Execute runs the tool if its parameters are in scope. The check may
+// rewrite them, e.g. to the path it resolved.
+func (t *scopedTool) Execute(ctx context.Context, params map[string]interface{}) (*Result, error) {
+	checked := make(map[string]interface{}, len(params))
+	for k, v := range params {
+		checked[k] = v
+	}
+	params = checked
+	if err := t.check(params); err != nil {
+		return &Result{
+			Success: false,
+			Error:   err.Error(),
+		}, nil
+	}
+	return t.Tool.Execute(ctx, params)
+}
+
+func (s *Scope) checkShell(params map[string]interface{}) error {
+	cmdStr, _ := params["command"].(string)
+	parts := strings.Fields(cmdStr)
+	if len(parts) == 0 {
+		// The tool reports the missing command
+		return nil
+	}
+	// Only names are allowed: a path such as ./git could be a file the
+	// model wrote, and the tool runs the program found on PATH
+	program := parts[0]
+	if strings.Contains(program, "/") {
+		return fmt.Errorf("running '%s' is not allowed by the command's permissions; give the program name only", program)
+	}
+	for _, allowed := range s.Shell {
+		if program == allowed {
+			return nil
+		}
+	}
+	return fmt.Errorf("running '%s' is not allowed by the command's permissions", program)
+}
+
+func (s *Scope) checkPath(access string, globs []string, params map[string]interface{}) error {
+	p, _ := params["path"].(string)
+	if p == "" {
+		return nil
+	}
+	if resolved, ok := s.allows(globs, p); ok {
+		// The tool uses the path that was checked
+		params["path"] = resolved
+		return nil
+	}
+	return fmt.Errorf("%s access to %s is not allowed by the command's permissions", access, p)
+}
+
+// AllowsRead reports whether read_file may read p, and returns the path
+// it resolved to
+func (s Scope) AllowsRead(p string) (string, bool) {
+	s.Dir = s.dir()
+	return s.allows(s.Read, p)
+}
+
+// allows resolves p and matches it against globs
+func (s *Scope) allows(globs []string, p string) (string, bool) {
+	resolved := s.resolve(p)
+	for _, glob := range globs {
+		if matchGlob(s.pattern(glob), filepath.ToSlash(resolved)) {
+			return resolved, true
+		}
+	}
+	return "", false
+}
+
+// dir returns Dir, or the working directory, with symlinks resolved
+func (s *Scope) dir() string {
+	dir := s.Dir
+	if dir == "" {
+		dir, _ = os.Getwd()
+	}
+	if real, err := filepath.EvalSymlinks(dir); err == nil {
+		dir = real
+	}
+	return dir
+}
+
+func (s *Scope) checkURL(params map[string]interface{}) error {
+	urlStr, _ := params["url"].(string)
+	if urlStr == "" {
+		return nil
+	}
+	u, err := url.Parse(urlStr)
+	if err != nil {
+		return fmt.Errorf("invalid url: %v", err)
+	}
+	if !s.allowsHost(u) {
+		return fmt.Errorf("fetching from %s is not allowed by the command's permissions", u.Host)
+	}
+	return nil
+}
+
+// allowsHost reports whether u's host, with or without its port, is one
+// of the scope's hosts
+func (s *Scope) allowsHost(u *url.URL) bool {
+	host := strings.ToLower(u.Hostname())
+	hostPort := strings.ToLower(u.Host)
+	for _, h := range s.Network {
+		h = strings.ToLower(h)
+		switch {
+		case h == "*", h == host, h == hostPort:
+			return true
+		case strings.HasPrefix(h, "*.") && strings.HasSuffix(host, h[1:]):
+			return true
+		}
+	}
+	return false
+}
+
+// resolve makes p absolute and follows symlinks, so a link can't lead out
+// of the allowed paths. Files that don't exist yet are resolved through
+// their nearest existing directory.
+func (s *Scope) resolve(p string) string {
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(s.Dir, p)
+	}
+	p = filepath.Clean(p)
+	rest := ""
+	for dir := p; ; dir = filepath.Dir(dir) {
+		if real, err := filepath.EvalSymlinks(dir); err == nil {
+			return filepath.Join(real, rest)
+		}
+		if dir == filepath.Dir(dir) {
+			return p
+		}
+		rest = filepath.Join(filepath.Base(dir), rest)
+	}
+}
+
+// pattern makes a glob absolute; a trailing slash matches everything below
+func (s *Scope) pattern(glob string) string {
+	if rest, ok := strings.CutPrefix(glob, "~/"); ok {
+		if home, err := os.UserHomeDir(); err == nil {
+			glob = filepath.Join(home, rest)
+		}
+	}
+	if strings.HasSuffix(glob, "/") {
+		glob += "**"
+	}
+	if !filepath.IsAbs(glob) {
+		glob = filepath.Join(s.Dir, glob)
+	}
+	return filepath.ToSlash(filepath.Clean(glob))
+}
+
+// matchGlob matches a slash-separated path against a pattern in which a
+// ** segment matches any number of directories
+func matchGlob(pattern, name string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(pattern, parts []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			pattern = pattern[1:]
+			if len(pattern) == 0 {
+				return true
+			}
+			for i := range parts {
+				if matchSegments(pattern, parts[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(parts) == 0 {
+			return false
+		}
+		if ok, err := path.Match(pattern[0], parts[0]); err != nil || !ok {
+			return false
+		}
+		pattern, parts = pattern[1:], parts[1:]
+	}
+	return len(parts) == 0
+}
+
+// ValidGlob reports whether glob is a pattern ScopedRegistry can match
+func ValidGlob(glob string) error {
+	for _, segment := range strings.Split(filepath.ToSlash(glob), "/") {
+		if segment == "**" {
+			continue
+		}
+		if _, err := path.Match(segment, ""); err != nil {
+			return fmt.Errorf("invalid path glob %q: %w", glob, err)
+		}
+	}
+	return nil
+}
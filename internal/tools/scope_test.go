@@ -0,0 +1,164 @@
+package tools
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/scmd/scmd/internal/sandbox"
+)
+
+func TestScopedRegistry_Files(t *testing.T) {
+	dir := t.TempDir()
+	outside := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "src", "pkg"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "src", "pkg", "main.go"), []byte("package main"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "secret.env"), []byte("TOKEN=x"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(outside, "other.go"), []byte("package other"), 0644))
+	require.NoError(t, os.Symlink(filepath.Join(outside, "other.go"), filepath.Join(dir, "src", "link.go")))
+
+	registry := ScopedRegistry(nil, Scope{
+		Read:  []string{"**/*.go"},
+		Write: []string{"docs/"},
+		Dir:   dir,
+	}, sandbox.DefaultPolicy())
+	ctx := context.Background()
+
+	result, err := registry.Execute(ctx, "read_file", map[string]interface{}{"path": "src/pkg/main.go"})
+	require.NoError(t, err)
+	assert.True(t, result.Success, result.Error)
+	assert.Equal(t, "package main", result.Output)
+
+	for _, path := range []string{"secret.env", "src/link.go", filepath.Join(outside, "other.go"), "src/../../x.go"} {
+		result, err = registry.Execute(ctx, "read_file", map[string]interface{}{"path": path})
+		require.NoError(t, err)
+		assert.False(t, result.Success, path)
+		assert.Contains(t, result.Error, "is not allowed by the command's permissions", path)
+	}
+
+	result, err = registry.Execute(ctx, "write_file", map[string]interface{}{"path": filepath.Join(dir, "docs", "api", "index.md"), "content": "# API"})
+	require.NoError(t, err)
+	assert.True(t, result.Success, result.Error)
+
+	// A link inside docs can't lead out of it, even to a new directory
+	require.NoError(t, os.Symlink(outside, filepath.Join(dir, "docs", "out")))
+	result, err = registry.Execute(ctx, "write_file", map[string]interface{}{"path": "docs/out/new/file.md", "content": "x"})
+	require.NoError(t, err)
+	assert.False(t, result.Success)
+	assert.NoFileExists(t, filepath.Join(outside, "new", "file.md"))
+
+	result, err = registry.Execute(ctx, "write_file", map[string]interface{}{"path": filepath.Join(dir, "main.go"), "content": "x"})
+	require.NoError(t, err)
+	assert.Equal(t, "write access to "+filepath.Join(dir, "main.go")+" is not allowed by the command's permissions", result.Error)
+
+	_, ok := registry.Get("shell")
+	assert.False(t, ok, "shell has no scope")
+}
+
+func TestScopedRegistry_Shell(t *testing.T) {
+	registry := ScopedRegistry(nil, Scope{Shell: []string{"echo"}}, sandbox.DefaultPolicy())
+
+	result, err := registry.Execute(context.Background(), "shell", map[string]interface{}{"command": "echo scoped"})
+	require.NoError(t, err)
+	assert.True(t, result.Success, result.Error)
+	assert.Equal(t, "scoped\n", result.Output)
+
+	result, err = registry.Execute(context.Background(), "shell", map[string]interface{}{"command": "ls /"})
+	require.NoError(t, err)
+	assert.Equal(t, "running 'ls' is not allowed by the command's permissions", result.Error)
+
+	// A file named like an allowed program is not run in its place
+	fake := filepath.Join(t.TempDir(), "echo")
+	require.NoError(t, os.WriteFile(fake, []byte("#!/bin/sh\necho fake\n"), 0755))
+	for _, program := range []string{fake, "./echo"} {
+		result, err = registry.Execute(context.Background(), "shell", map[string]interface{}{"command": program + " scoped"})
+		require.NoError(t, err)
+		assert.Equal(t, "running '"+program+"' is not allowed by the command's permissions; give the program name only", result.Error)
+	}
+
+	tool, _ := registry.Get("shell")
+	assert.Contains(t, tool.Description(), "Allowed programs: echo.")
+	shell := tool.(*scopedTool).Tool.(*ShellTool)
+	assert.True(t, shell.isAllowed("echo"))
+	assert.False(t, shell.isAllowed(fake), "edited commands are checked the same way")
+}
+
+func TestScopedRegistry_Network(t *testing.T) {
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("other"))
+	}))
+	defer other.Close()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/away" {
+			http.Redirect(w, r, other.URL, http.StatusFound)
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	host := server.Listener.Addr().String()
+	registry := ScopedRegistry(nil, Scope{Network: []string{host}}, sandbox.DefaultPolicy())
+	ctx := context.Background()
+
+	result, err := registry.Execute(ctx, "http_get", map[string]interface{}{"url": server.URL})
+	require.NoError(t, err)
+	assert.True(t, result.Success, result.Error)
+	assert.Equal(t, "ok", result.Output)
+
+	result, err = registry.Execute(ctx, "http_get", map[string]interface{}{"url": other.URL})
+	require.NoError(t, err)
+	assert.Contains(t, result.Error, "is not allowed by the command's permissions")
+
+	result, err = registry.Execute(ctx, "http_get", map[string]interface{}{"url": server.URL + "/away"})
+	require.NoError(t, err)
+	assert.False(t, result.Success)
+	assert.Contains(t, result.Error, "redirect to")
+}
+
+func TestScope_AllowsHost(t *testing.T) {
+	scope := &Scope{Network: []string{"api.github.com", "*.golang.org", "localhost:8080"}}
+	for raw, want := range map[string]bool{
+		"https://api.github.com/repos":  true,
+		"https://API.GitHub.com/":       true,
+		"https://github.com/":           false,
+		"https://pkg.go.golang.org/x":   true,
+		"https://golang.org/":           false,
+		"https://evilgolang.org/":       false,
+		"http://localhost:8080/metrics": true,
+		"http://localhost:9090/":        false,
+	} {
+		u, err := url.Parse(raw)
+		require.NoError(t, err)
+		assert.Equal(t, want, scope.allowsHost(u), raw)
+	}
+}
+
+func TestMatchGlob(t *testing.T) {
+	for _, tt := range []struct {
+		pattern, name string
+		want          bool
+	}{
+		{"/src/**/*.go", "/src/main.go", true},
+		{"/src/**/*.go", "/src/a/b/main.go", true},
+		{"/src/**/*.go", "/src/main.txt", false},
+		{"/src/*.go", "/src/a/main.go", false},
+		{"/docs/**", "/docs", true},
+		{"/docs/**", "/docs/a/b", true},
+		{"/docs/**", "/docsx/a", false},
+		{"/go.mod", "/go.mod", true},
+		{"/**", "/anything/at/all", true},
+	} {
+		assert.Equal(t, tt.want, matchGlob(tt.pattern, tt.name), "%s %s", tt.pattern, tt.name)
+	}
+
+	assert.NoError(t, ValidGlob("src/**/*.go"))
+	assert.Error(t, ValidGlob("src/[a"))
+}
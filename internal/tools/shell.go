@@ -1,33 +1,41 @@
 package tools
 
 import (
-	"bytes"
 	"context"
 	"fmt"
 	"os/exec"
 	"strings"
-	"time"
 
 	"github.com/scmd/scmd/internal/backend"
 	"github.com/scmd/scmd/internal/preview"
+	"github.com/scmd/scmd/internal/sandbox"
 )
 
 // ShellTool executes shell commands
 type ShellTool struct {
 	allowedCommands map[string]bool
-	timeout         time.Duration
+	policy          sandbox.Policy
 	confirmUI       ConfirmUI
+	// bareNames allows only program names, not paths, and runs the
+	// program found on PATH, so a file named like an allowed program
+	// can't be run in its place
+	bareNames bool
 }
 
 // NewShellTool creates a new shell tool
 func NewShellTool(confirmUI ConfirmUI) *ShellTool {
 	return &ShellTool{
 		allowedCommands: getDefaultAllowedCommands(),
-		timeout:         30 * time.Second,
+		policy:          sandbox.DefaultPolicy(),
 		confirmUI:       confirmUI,
 	}
 }
 
+// SetPolicy sets the limits commands run under
+func (t *ShellTool) SetPolicy(policy sandbox.Policy) {
+	t.policy = policy
+}
+
 // Name returns the tool name
 func (t *ShellTool) Name() string {
 	return "shell"
@@ -137,40 +145,59 @@ func (t *ShellTool) Execute(ctx context.Context, params map[string]interface{})
 		}
 	}
 
-	// Create command with timeout
-	cmdCtx, cancel := context.WithTimeout(ctx, t.timeout)
-	defer cancel()
-
-	cmd := exec.CommandContext(cmdCtx, parts[0], parts[1:]...)
-
-	// Set working directory if provided
-	if workDir, ok := params["working_dir"].(string); ok && workDir != "" {
-		cmd.Dir = workDir
+	if t.bareNames {
+		program, err := exec.LookPath(parts[0])
+		if err != nil {
+			return &Result{
+				Success: false,
+				Error:   fmt.Sprintf("command '%s' was not found on PATH", parts[0]),
+			}, nil
+		}
+		parts[0] = program
 	}
 
-	// Capture output
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
-
-	// Execute
-	err := cmd.Run()
+	// Run the command, confined and limited by the policy
+	var workDir string
+	if dir, ok := params["working_dir"].(string); ok {
+		workDir = dir
+	}
+	run, err := t.policy.Run(ctx, sandbox.Cmd{
+		Source: "shell tool",
+		Args:   parts,
+		Dir:    workDir,
+	})
+	if err != nil {
+		return &Result{
+			Success: false,
+			Error:   fmt.Sprintf("command failed: %v", err),
+		}, nil
+	}
 
 	// Build result
-	output := stdout.String()
-	if stderr.Len() > 0 {
+	output := run.Stdout
+	if run.Stderr != "" {
 		if output != "" {
-			output += "\n\nStderr:\n" + stderr.String()
+			output += "\n\nStderr:\n" + run.Stderr
 		} else {
-			output = stderr.String()
+			output = run.Stderr
 		}
 	}
+	if run.Truncated {
+		output += fmt.Sprintf("\n\n[Output truncated at %d bytes]", t.policy.MaxOutput)
+	}
 
-	if err != nil {
+	switch {
+	case run.TimedOut:
 		return &Result{
 			Success: false,
 			Output:  output,
-			Error:   fmt.Sprintf("command failed: %v", err),
+			Error:   fmt.Sprintf("command timed out after %s", t.policy.Timeout),
+		}, nil
+	case run.ExitCode != 0:
+		return &Result{
+			Success: false,
+			Output:  output,
+			Error:   fmt.Sprintf("command failed: exit status %d", run.ExitCode),
 		}, nil
 	}
 
@@ -182,6 +209,10 @@ func (t *ShellTool) Execute(ctx context.Context, params map[string]interface{})
 
 // isAllowed checks if a command is in the allowed list
 func (t *ShellTool) isAllowed(cmd string) bool {
+	if t.bareNames {
+		return !strings.Contains(cmd, "/") && t.allowedCommands[cmd]
+	}
+
 	// Remove path if present
 	parts := strings.Split(cmd, "/")
 	baseName := parts[len(parts)-1]
@@ -0,0 +1,153 @@
+package manpage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+)
+
+// Reader finds documentation for commands and caches parsed pages
+type Reader struct {
+	// CacheDir holds parsed pages keyed by binary path and mtime; empty
+	// disables the cache
+	CacheDir string
+	// TldrPaths are extracted tldr-pages directories or zip archives
+	TldrPaths []string
+
+	// LookPath, Man and Help are replaceable for tests
+	LookPath func(file string) (string, error)
+	Man      func(command string) (string, error)
+	Help     func(path string, args ...string) string
+}
+
+// NewReader creates a reader that caches under dataDir and looks for a tldr
+// archive at dataDir/tldr or dataDir/tldr.zip
+func NewReader(dataDir string) *Reader {
+	return &Reader{
+		CacheDir: filepath.Join(dataDir, "cache", "manpages"),
+		TldrPaths: []string{
+			filepath.Join(dataDir, "tldr"),
+			filepath.Join(dataDir, "tldr.zip"),
+		},
+		LookPath: exec.LookPath,
+		Man:      runMan,
+		Help:     runHelp,
+	}
+}
+
+// cacheEntry is a parsed page stored on disk
+type cacheEntry struct {
+	Path    string    `json:"path"`
+	ModTime time.Time `json:"mod_time"`
+	Size    int64     `json:"size"`
+	Page    *ManPage  `json:"page"`
+}
+
+// Read returns documentation for a command from the cache, man, --help, -h
+// or tldr, in that order
+func (r *Reader) Read(command string) (*ManPage, error) {
+	path, info := r.binary(command)
+	if info != nil {
+		if mp := r.cached(command, path, info); mp != nil {
+			return mp, nil
+		}
+	}
+
+	mp := r.read(command, path)
+	if mp == nil {
+		return nil, fmt.Errorf("no documentation found for '%s'", command)
+	}
+
+	if info != nil {
+		r.store(command, path, info, mp)
+	}
+	return mp, nil
+}
+
+func (r *Reader) read(command, path string) *ManPage {
+	if text, err := r.Man(command); err == nil && text != "" {
+		return parseManText(command, text)
+	}
+
+	if path != "" && !noHelp[command] {
+		for _, flag := range []string{"--help", "-h"} {
+			if text := r.Help(path, flag); looksLikeHelp(text) {
+				return parseHelp(command, text)
+			}
+		}
+	}
+
+	if text := r.tldr(command); text != "" {
+		return parseTldr(command, text)
+	}
+
+	return nil
+}
+
+// binary resolves a command to its path on PATH
+func (r *Reader) binary(command string) (string, os.FileInfo) {
+	path, err := r.LookPath(command)
+	if err != nil {
+		return "", nil
+	}
+	if abs, err := filepath.EvalSymlinks(path); err == nil {
+		path = abs
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", nil
+	}
+	return path, info
+}
+
+func (r *Reader) cacheFile(command, path string) string {
+	sum := sha256.Sum256([]byte(command + "\x00" + path))
+	return filepath.Join(r.CacheDir, hex.EncodeToString(sum[:8])+".json")
+}
+
+// cached returns the cached page if the binary has not changed since
+func (r *Reader) cached(command, path string, info os.FileInfo) *ManPage {
+	if r.CacheDir == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(r.cacheFile(command, path))
+	if err != nil {
+		return nil
+	}
+
+	var entry cacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil || entry.Page == nil {
+		return nil
+	}
+	if entry.Path != path || !entry.ModTime.Equal(info.ModTime()) || entry.Size != info.Size() {
+		return nil
+	}
+	return entry.Page
+}
+
+// store saves a parsed page; failures only cost a re-read next time
+func (r *Reader) store(command, path string, info os.FileInfo, mp *ManPage) {
+	if r.CacheDir == "" {
+		return
+	}
+
+	data, err := json.Marshal(&cacheEntry{
+		Path:    path,
+		ModTime: info.ModTime(),
+		Size:    info.Size(),
+		Page:    mp,
+	})
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(r.CacheDir, 0755); err != nil {
+		return
+	}
+	_ = os.WriteFile(r.cacheFile(command, path), data, 0644)
+}
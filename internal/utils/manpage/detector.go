@@ -1,7 +1,10 @@
 package manpage
 
 import (
+	"os"
+	"path/filepath"
 	"strings"
+	"sync"
 )
 
 // CommonCommands lists frequently used CLI commands
@@ -41,6 +44,61 @@ var CommonCommands = []string{
 	"xargs", "parallel",
 }
 
+// englishWords are ordinary words that happen to name programs; they are
+// only detected when listed in CommonCommands
+var englishWords = map[string]bool{
+	"as": true, "at": true, "be": true, "do": true, "go": true, "in": true,
+	"is": true, "it": true, "on": true, "or": true, "to": true, "id": true,
+	"all": true, "and": true, "the": true, "new": true, "get": true, "set": true,
+	"file": true, "files": true, "test": true, "time": true, "make": true,
+	"which": true, "yes": true, "true": true, "false": true, "from": true,
+	"look": true, "write": true, "split": true, "watch": true, "last": true,
+	"link": true, "install": true, "info": true, "man": true, "users": true,
+	"who": true, "script": true, "dir": true, "host": true, "read": true,
+	"see": true, "fold": true, "open": true, "say": true, "show": true,
+	"list": true, "run": true, "echo": true, "print": true, "size": true,
+	"type": true, "help": true, "stat": true, "sync": true, "wall": true,
+	"lock": true, "start": true, "stop": true, "clear": true, "reset": true,
+	"view": true, "page": true, "login": true, "logout": true, "column": true,
+	"env": true, "top": true, "more": true, "less": true,
+	"tee": true, "seq": true, "rev": true, "od": true, "pr": true, "ex": true,
+}
+
+// pathCommands returns the names of the executables on PATH, read once
+var pathCommands = sync.OnceValue(func() map[string]bool {
+	return scanPath(os.Getenv("PATH"))
+})
+
+// scanPath lists the executables in every directory of a PATH value
+func scanPath(pathEnv string) map[string]bool {
+	names := make(map[string]bool)
+	for _, dir := range filepath.SplitList(pathEnv) {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			if e.IsDir() {
+				continue
+			}
+			info, err := e.Info()
+			if err != nil {
+				continue
+			}
+			// Symlinks are common on PATH; follow them for the mode
+			if info.Mode()&os.ModeSymlink != 0 {
+				if info, err = os.Stat(filepath.Join(dir, e.Name())); err != nil {
+					continue
+				}
+			}
+			if info.Mode().IsRegular() && info.Mode()&0111 != 0 {
+				names[strings.ToLower(e.Name())] = true
+			}
+		}
+	}
+	return names
+}
+
 // DetectCommands detects which commands might be relevant to a query
 func DetectCommands(query string) []string {
 	query = strings.ToLower(query)
@@ -74,6 +132,17 @@ func DetectCommands(query string) []string {
 		}
 	}
 
+	// Programs installed on PATH that are named in the query
+	installed := pathCommands()
+	for _, word := range words {
+		cleaned := strings.Trim(word, ".,;:!?()\"'`")
+		if len(cleaned) < 2 || detected[cleaned] || englishWords[cleaned] || !installed[cleaned] {
+			continue
+		}
+		detected[cleaned] = true
+		result = append(result, cleaned)
+	}
+
 	// Keyword-based detection for common use cases
 	keywordMap := map[string][]string{
 		"find":   {"find", "search", "locate", "files", "directories"},
@@ -0,0 +1,78 @@
+package manpage
+
+import (
+	"context"
+	"os/exec"
+	"strings"
+)
+
+// runHelp runs a program with the given arguments, such as --help, and
+// returns what it printed. Many programs print help to stderr or exit
+// non-zero, so the output is used either way.
+func runHelp(path string, args ...string) string {
+	ctx, cancel := context.WithTimeout(context.Background(), helpTimeout)
+	defer cancel()
+
+	out, _ := exec.CommandContext(ctx, path, args...).CombinedOutput()
+	if ctx.Err() != nil {
+		return ""
+	}
+	return string(out)
+}
+
+// looksLikeHelp reports whether output reads like usage text rather than an
+// error or the program doing something else
+func looksLikeHelp(text string) bool {
+	if len(strings.TrimSpace(text)) < 20 {
+		return false
+	}
+	if strings.Contains(strings.ToLower(text), "usage") {
+		return true
+	}
+	for _, line := range strings.Split(text, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "-") {
+			return true
+		}
+	}
+	return false
+}
+
+// parseHelp turns --help output into a page. The usage lines become the
+// synopsis and lines starting with a dash the options.
+func parseHelp(command, text string) *ManPage {
+	mp := &ManPage{
+		Command:  command,
+		FullText: text,
+		Source:   "help",
+	}
+
+	var synopsis, options, description []string
+	inUsage := false
+	for _, line := range strings.Split(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "":
+			inUsage = false
+		case strings.HasPrefix(strings.ToLower(trimmed), "usage"):
+			synopsis = append(synopsis, trimmed)
+			inUsage = true
+		case strings.HasPrefix(trimmed, "-"):
+			options = append(options, line)
+			inUsage = false
+		case inUsage && line != trimmed:
+			// Indented continuation of the usage line
+			synopsis = append(synopsis, trimmed)
+		default:
+			if mp.Name == "" {
+				mp.Name = command + " - " + trimmed
+			}
+			description = append(description, trimmed)
+			inUsage = false
+		}
+	}
+
+	mp.Synopsis = strings.Join(synopsis, "\n")
+	mp.Options = strings.Join(options, "\n")
+	mp.Description = strings.Join(description, "\n")
+	return mp
+}
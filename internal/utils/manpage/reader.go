@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"os/exec"
 	"strings"
+
+	"github.com/scmd/scmd/internal/config"
 )
 
 // ManPage represents a parsed man page
@@ -16,24 +18,23 @@ type ManPage struct {
 	Options     string
 	Examples    string
 	FullText    string
+	// Source is where the page came from: man, help or tldr
+	Source string
 }
 
-// Read reads and parses a man page for the given command
+// Read reads and parses documentation for the given command. It uses the man
+// page, falling back to --help and -h output and then to an offline tldr-pages
+// archive in the data directory. Results are cached by binary path and mtime.
 func Read(command string) (*ManPage, error) {
-	// Read the man page using 'man' command
-	cmd := exec.Command("man", command)
-	output, err := cmd.Output()
-	if err != nil {
-		// Man page doesn't exist or man command failed
-		return nil, fmt.Errorf("man page not found for '%s': %w", command, err)
-	}
-
-	fullText := string(output)
+	return NewReader(config.DataDir()).Read(command)
+}
 
-	// Parse sections
+// parseManText splits man page output into its sections
+func parseManText(command, fullText string) *ManPage {
 	mp := &ManPage{
 		Command:  command,
 		FullText: fullText,
+		Source:   "man",
 	}
 
 	mp.Name = extractSection(fullText, "NAME")
@@ -42,7 +43,18 @@ func Read(command string) (*ManPage, error) {
 	mp.Options = extractSection(fullText, "OPTIONS")
 	mp.Examples = extractSection(fullText, "EXAMPLES", "EXAMPLE")
 
-	return mp, nil
+	return mp
+}
+
+// runMan returns the output of man for a command
+func runMan(command string) (string, error) {
+	cmd := exec.Command("man", command)
+	output, err := cmd.Output()
+	if err != nil {
+		// Man page doesn't exist or man command failed
+		return "", fmt.Errorf("man page not found for '%s': %w", command, err)
+	}
+	return string(output), nil
 }
 
 // ReadMultiple reads man pages for multiple commands
@@ -154,7 +166,14 @@ func FormatForLLM(manPages map[string]*ManPage) string {
 
 	for cmd, mp := range manPages {
 		var cmdSections []string
-		cmdSections = append(cmdSections, fmt.Sprintf("=== MAN PAGE: %s ===\n", cmd))
+		title := "MAN PAGE"
+		switch mp.Source {
+		case "help":
+			title = "HELP OUTPUT"
+		case "tldr":
+			title = "TLDR PAGE"
+		}
+		cmdSections = append(cmdSections, fmt.Sprintf("=== %s: %s ===\n", title, cmd))
 
 		if mp.Name != "" {
 			cmdSections = append(cmdSections, fmt.Sprintf("NAME:\n%s\n", mp.Name))
@@ -0,0 +1,157 @@
+package manpage
+
+import (
+	"archive/zip"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testReader reads from fakes; bin is a real file so mtimes can change
+type testReader struct {
+	*Reader
+	bin       string
+	manCalls  int
+	helpCalls []string
+	man       string
+	help      map[string]string
+}
+
+func newTestReader(t *testing.T) *testReader {
+	dir := t.TempDir()
+	bin := filepath.Join(dir, "bin", "frob")
+	require.NoError(t, os.MkdirAll(filepath.Dir(bin), 0755))
+	require.NoError(t, os.WriteFile(bin, []byte("#!/bin/sh\n"), 0755))
+
+	tr := &testReader{bin: bin, help: map[string]string{}}
+	tr.Reader = &Reader{
+		CacheDir:  filepath.Join(dir, "cache"),
+		TldrPaths: []string{filepath.Join(dir, "tldr"), filepath.Join(dir, "tldr.zip")},
+		LookPath: func(file string) (string, error) {
+			if file == "frob" {
+				return bin, nil
+			}
+			return "", fmt.Errorf("%s: not found", file)
+		},
+		Man: func(command string) (string, error) {
+			tr.manCalls++
+			if tr.man == "" {
+				return "", fmt.Errorf("no man page")
+			}
+			return tr.man, nil
+		},
+		Help: func(path string, args ...string) string {
+			tr.helpCalls = append(tr.helpCalls, args[0])
+			return tr.help[args[0]]
+		},
+	}
+	return tr
+}
+
+func TestReader_CachesByBinaryMtime(t *testing.T) {
+	tr := newTestReader(t)
+	tr.man = "NAME\n       frob - frobnicate things\n\nOPTIONS\n       -x   extra\n"
+
+	mp, err := tr.Read("frob")
+	require.NoError(t, err)
+	assert.Equal(t, "man", mp.Source)
+	assert.Contains(t, mp.Options, "-x   extra")
+
+	// Served from the cache
+	_, err = tr.Read("frob")
+	require.NoError(t, err)
+	assert.Equal(t, 1, tr.manCalls)
+
+	// A rebuilt binary invalidates the entry
+	later := time.Now().Add(time.Hour)
+	require.NoError(t, os.Chtimes(tr.bin, later, later))
+	_, err = tr.Read("frob")
+	require.NoError(t, err)
+	assert.Equal(t, 2, tr.manCalls)
+}
+
+func TestReader_HelpFallback(t *testing.T) {
+	tr := newTestReader(t)
+	tr.help["--help"] = "frob: unrecognized option '--help'"
+	tr.help["-h"] = "Frobnicate things.\n\nUsage: frob [options] FILE\n  -x, --extra   be extra\n  -q            quiet\n"
+
+	mp, err := tr.Read("frob")
+	require.NoError(t, err)
+	assert.Equal(t, "help", mp.Source)
+	assert.Equal(t, []string{"--help", "-h"}, tr.helpCalls)
+	assert.Equal(t, "frob - Frobnicate things.", mp.Name)
+	assert.Equal(t, "Usage: frob [options] FILE", mp.Synopsis)
+	assert.Equal(t, "  -x, --extra   be extra\n  -q            quiet", mp.Options)
+}
+
+func TestReader_TldrFallback(t *testing.T) {
+	page := "# frob\n\n> Frobnicate things.\n> More information: <https://example.com>.\n\n" +
+		"- Frobnicate a file:\n\n`frob {{path/to/file}}`\n\n- Frobnicate quietly:\n\n`frob -q {{path/to/file}}`\n"
+
+	t.Run("directory", func(t *testing.T) {
+		tr := newTestReader(t)
+		dir := filepath.Join(tr.TldrPaths[0], "pages", "common")
+		require.NoError(t, os.MkdirAll(dir, 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "frob.md"), []byte(page), 0644))
+
+		mp, err := tr.Read("frob")
+		require.NoError(t, err)
+		assert.Equal(t, "tldr", mp.Source)
+		assert.Equal(t, "frob - Frobnicate things.", mp.Name)
+		assert.Equal(t, "Frobnicate a file:\n    frob {{path/to/file}}\nFrobnicate quietly:\n    frob -q {{path/to/file}}", mp.Examples)
+	})
+
+	t.Run("zip", func(t *testing.T) {
+		tr := newTestReader(t)
+		f, err := os.Create(tr.TldrPaths[1])
+		require.NoError(t, err)
+		zw := zip.NewWriter(f)
+		w, err := zw.Create("common/frob.md")
+		require.NoError(t, err)
+		_, err = w.Write([]byte(page))
+		require.NoError(t, err)
+		require.NoError(t, zw.Close())
+		require.NoError(t, f.Close())
+
+		// Commands that are not installed can still have a tldr page
+		tr.LookPath = func(string) (string, error) { return "", fmt.Errorf("not found") }
+
+		mp, err := tr.Read("frob")
+		require.NoError(t, err)
+		assert.Equal(t, "tldr", mp.Source)
+		assert.Empty(t, tr.helpCalls)
+	})
+
+	t.Run("nothing found", func(t *testing.T) {
+		tr := newTestReader(t)
+		_, err := tr.Read("frob")
+		assert.Error(t, err)
+	})
+}
+
+func TestDetectCommands_LearnsFromPath(t *testing.T) {
+	saved := pathCommands
+	pathCommands = func() map[string]bool {
+		return map[string]bool{"ffmpeg": true, "file": true, "jq": true}
+	}
+	defer func() { pathCommands = saved }()
+
+	assert.Equal(t, []string{"ffmpeg"}, DetectCommands("convert a video to mp4 with ffmpeg"))
+	assert.Equal(t, []string{"jq"}, DetectCommands("pretty print json using `jq`"))
+	assert.NotContains(t, DetectCommands("what type of file is this"), "file")
+}
+
+func TestScanPath(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "tool"), []byte("x"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("x"), 0644))
+	require.NoError(t, os.Symlink(filepath.Join(dir, "tool"), filepath.Join(dir, "alias")))
+
+	names := scanPath(dir + string(os.PathListSeparator) + filepath.Join(dir, "missing"))
+	assert.Equal(t, map[string]bool{"tool": true, "alias": true}, names)
+}
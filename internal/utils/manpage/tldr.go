@@ -0,0 +1,122 @@
+package manpage
+
+import (
+	"archive/zip"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// tldrPlatforms lists the tldr-pages directories to search, most specific first
+func tldrPlatforms() []string {
+	switch runtime.GOOS {
+	case "darwin":
+		return []string{"osx", "common"}
+	case "linux", "windows", "freebsd", "openbsd", "netbsd", "android":
+		return []string{runtime.GOOS, "common"}
+	default:
+		return []string{"common"}
+	}
+}
+
+// tldr looks a command up in the offline tldr-pages archives. Each archive is
+// a directory or zip file laid out like the tldr-pages repository, with or
+// without the top-level pages/ directory.
+func (r *Reader) tldr(command string) string {
+	var names []string
+	for _, platform := range tldrPlatforms() {
+		names = append(names,
+			"pages/"+platform+"/"+command+".md",
+			platform+"/"+command+".md")
+	}
+
+	for _, archive := range r.TldrPaths {
+		info, err := os.Stat(archive)
+		if err != nil {
+			continue
+		}
+		if info.IsDir() {
+			for _, name := range names {
+				if data, err := os.ReadFile(filepath.Join(archive, filepath.FromSlash(name))); err == nil {
+					return string(data)
+				}
+			}
+			continue
+		}
+		if text := readZipPage(archive, names); text != "" {
+			return text
+		}
+	}
+	return ""
+}
+
+// readZipPage returns the first of names found in a zip archive
+func readZipPage(archive string, names []string) string {
+	zr, err := zip.OpenReader(archive)
+	if err != nil {
+		return ""
+	}
+	defer zr.Close()
+
+	files := make(map[string]*zip.File, len(zr.File))
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+
+	for _, name := range names {
+		f, ok := files[name]
+		if !ok {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			continue
+		}
+		data, err := io.ReadAll(io.LimitReader(rc, 64*1024))
+		rc.Close()
+		if err == nil {
+			return string(data)
+		}
+	}
+	return ""
+}
+
+// parseTldr turns a tldr page into a page whose examples are the tldr
+// examples:
+//
+//	# tar
+//	> Archiving utility.
+//	- Create an archive from files:
+//	`tar cf {{target.tar}} {{file1 file2}}`
+func parseTldr(command, text string) *ManPage {
+	mp := &ManPage{
+		Command:  command,
+		FullText: text,
+		Source:   "tldr",
+	}
+
+	var description, examples []string
+	for _, line := range strings.Split(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(trimmed, ">"):
+			d := strings.TrimSpace(strings.TrimPrefix(trimmed, ">"))
+			if !strings.HasPrefix(d, "More information:") {
+				description = append(description, d)
+			}
+		case strings.HasPrefix(trimmed, "- "):
+			examples = append(examples, strings.TrimPrefix(trimmed, "- "))
+		case strings.HasPrefix(trimmed, "`") && strings.HasSuffix(trimmed, "`"):
+			examples = append(examples, "    "+strings.Trim(trimmed, "`"))
+		}
+	}
+
+	if len(description) > 0 {
+		mp.Name = command + " - " + description[0]
+	}
+	mp.Description = strings.Join(description, "\n")
+	mp.Examples = strings.Join(examples, "\n")
+	return mp
+}
@@ -0,0 +1,524 @@
+package manpage
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// helpTimeout bounds how long a program may take to print its --help
+const helpTimeout = 3 * time.Second
+
+// shellBuiltins are run by the shell itself and have no binary to check
+var shellBuiltins = map[string]bool{
+	"cd": true, "export": true, "source": true, ".": true, "alias": true,
+	"set": true, "unset": true, "read": true, "eval": true, "exit": true,
+	"return": true, "local": true, "shopt": true, "pushd": true, "popd": true,
+	"ulimit": true, "umask": true, "type": true, "hash": true, "trap": true,
+	"wait": true, "jobs": true, "fg": true, "bg": true, "declare": true,
+	"typeset": true, "builtin": true, "let": true, "shift": true, "[[": true,
+}
+
+// shellKeywords may precede a command without being one
+var shellKeywords = map[string]bool{
+	"if": true, "then": true, "else": true, "elif": true, "do": true,
+	"while": true, "until": true, "!": true, "{": true, "(": true,
+}
+
+// wrappers run another program given later on their command line
+var wrappers = map[string]bool{
+	"sudo": true, "env": true, "xargs": true, "nohup": true, "nice": true,
+	"time": true, "watch": true, "timeout": true, "exec": true, "command": true,
+	"doas": true, "strace": true,
+}
+
+// noHelp lists programs never run with --help or -h, where -h may act
+var noHelp = map[string]bool{
+	"reboot": true, "shutdown": true, "halt": true, "poweroff": true,
+	"init": true, "telinit": true, "kexec": true,
+}
+
+// Invocation is one program call within a shell command line
+type Invocation struct {
+	Program string
+	// Sub is the first positional argument, which may be a subcommand
+	Sub   string
+	Flags []string
+}
+
+// Validation is the result of checking a command line
+type Validation struct {
+	Command string
+	// Missing lists programs that are not on PATH
+	Missing []string
+	// UnknownFlags lists flags not found in any documentation, as "program flag"
+	UnknownFlags []string
+	// Undocumented lists programs for which no documentation was found
+	Undocumented []string
+}
+
+// Valid reports whether every program exists and no flag is known to be wrong
+func (v *Validation) Valid() bool {
+	return len(v.Missing) == 0 && len(v.UnknownFlags) == 0
+}
+
+// Verified reports whether the command is valid and every flag was checked
+func (v *Validation) Verified() bool {
+	return v.Valid() && len(v.Undocumented) == 0
+}
+
+// Problems describes why the command is invalid
+func (v *Validation) Problems() []string {
+	var problems []string
+	for _, p := range v.Missing {
+		problems = append(problems, fmt.Sprintf("%s is not installed", p))
+	}
+	for _, f := range v.UnknownFlags {
+		program, flag, _ := strings.Cut(f, " ")
+		problems = append(problems, fmt.Sprintf("%s has no %s option", program, flag))
+	}
+	return problems
+}
+
+// Validator checks that the programs in a command exist and that the flags
+// they are given are documented
+type Validator struct {
+	// LookPath finds a program on PATH
+	LookPath func(file string) (string, error)
+	// Sources return documentation for a program, or for one of its
+	// subcommands when sub is set. A flag is accepted if any source
+	// mentions it; later sources are only consulted when needed.
+	Sources []func(program, sub string) string
+
+	cache map[string]string
+}
+
+// NewValidator creates a validator that reads man pages and --help output
+func NewValidator() *Validator {
+	return &Validator{
+		LookPath: exec.LookPath,
+		Sources:  []func(program, sub string) string{manText, HelpText},
+	}
+}
+
+// Validate checks every program invoked by a shell command line
+func (v *Validator) Validate(line string) *Validation {
+	result := &Validation{Command: line}
+
+	for _, inv := range ParseInvocations(line, v.onPath) {
+		if shellBuiltins[inv.Program] {
+			continue
+		}
+		if !v.onPath(inv.Program) {
+			result.Missing = appendUnique(result.Missing, inv.Program)
+			continue
+		}
+		if len(inv.Flags) == 0 {
+			continue
+		}
+
+		documented := false
+		for _, flag := range inv.Flags {
+			found, anyDocs := v.flagDocumented(inv, flag)
+			documented = documented || anyDocs
+			if anyDocs && !found {
+				result.UnknownFlags = appendUnique(result.UnknownFlags, inv.Program+" "+flag)
+			}
+		}
+		if !documented {
+			result.Undocumented = appendUnique(result.Undocumented, inv.Program)
+		}
+	}
+
+	return result
+}
+
+// flagDocumented looks for a flag in the program's documentation, then in
+// its subcommand's. It also reports whether any documentation was found.
+func (v *Validator) flagDocumented(inv Invocation, flag string) (found, anyDocs bool) {
+	listed := false
+	for i := range v.Sources {
+		doc := v.doc(i, inv.Program, "")
+		if doc == "" {
+			continue
+		}
+		anyDocs = true
+		if FlagDocumented(doc, flag) {
+			return true, true
+		}
+		listed = listed || (inv.Sub != "" && listsSubcommand(doc, inv.Sub))
+	}
+
+	// Only ask for a subcommand's documentation when the program lists it;
+	// the first argument could be anything, such as a file to delete
+	if !listed {
+		return false, anyDocs
+	}
+	subDocs := false
+	for i := range v.Sources {
+		doc := v.doc(i, inv.Program, inv.Sub)
+		if doc == "" {
+			continue
+		}
+		subDocs = true
+		if FlagDocumented(doc, flag) {
+			return true, true
+		}
+	}
+	// The program's own documentation says nothing about its subcommands'
+	// flags, so without the subcommand's the flag cannot be checked
+	return false, subDocs
+}
+
+func (v *Validator) doc(source int, program, sub string) string {
+	if v.cache == nil {
+		v.cache = make(map[string]string)
+	}
+	key := fmt.Sprintf("%d\x00%s\x00%s", source, program, sub)
+	if doc, ok := v.cache[key]; ok {
+		return doc
+	}
+	doc := v.Sources[source](program, sub)
+	v.cache[key] = doc
+	return doc
+}
+
+func (v *Validator) onPath(program string) bool {
+	if shellBuiltins[program] {
+		return true
+	}
+	if strings.Contains(program, "/") {
+		info, err := os.Stat(program)
+		return err == nil && !info.IsDir() && info.Mode()&0111 != 0
+	}
+	_, err := v.LookPath(program)
+	return err == nil
+}
+
+// manText returns the full man page of a program or of program-sub. A
+// program given as a path is looked up by its name.
+func manText(program, sub string) string {
+	name := filepath.Base(program)
+	if sub != "" {
+		name += "-" + sub
+	}
+	mp, err := Read(name)
+	if err != nil {
+		return ""
+	}
+	return mp.FullText
+}
+
+// HelpText runs a program with --help and returns what it printed. sub, if
+// set, is passed before --help to get a subcommand's help. Programs given
+// as a path, such as ./deploy.sh, are never run: the command has not been
+// confirmed yet, and many scripts ignore --help and just run.
+func HelpText(program, sub string) string {
+	if noHelp[program] || strings.Contains(program, "/") {
+		return ""
+	}
+	if sub != "" {
+		return runHelp(program, sub, "--help")
+	}
+	return runHelp(program, "--help")
+}
+
+// overstrike matches the backspace formatting man uses for bold and underline
+var overstrike = regexp.MustCompile(".\b")
+
+// FlagDocumented reports whether documentation text mentions a flag. Short
+// flags may be clustered (-la) as long as every letter is documented.
+func FlagDocumented(doc, flag string) bool {
+	doc = normalizeDoc(doc)
+
+	if mentionsFlag(doc, flag) {
+		return true
+	}
+
+	// Single-dash numeric flags such as head -5
+	if isNumericFlag(flag) {
+		return true
+	}
+
+	if strings.HasPrefix(flag, "--") || len(flag) <= 2 {
+		return false
+	}
+
+	// Clustered short flags; digits start an attached value (-n5)
+	for _, r := range flag[1:] {
+		if r >= '0' && r <= '9' {
+			break
+		}
+		if !mentionsFlag(doc, "-"+string(r)) {
+			return false
+		}
+	}
+	return true
+}
+
+func normalizeDoc(doc string) string {
+	doc = overstrike.ReplaceAllString(doc, "")
+	// groff may render hyphens as Unicode dashes
+	return strings.NewReplacer("‐", "-", "−", "-").Replace(doc)
+}
+
+func mentionsFlag(doc, flag string) bool {
+	pattern := `(^|[\s,\[|(/"'` + "`" + `])` + regexp.QuoteMeta(flag) + `($|[\s,=\[\]|)<>.:;/"'` + "`" + `])`
+	if regexp.MustCompile(pattern).MatchString(doc) {
+		return true
+	}
+
+	// BSD synopses cluster short flags: ls [-ABCFGHLOPRSTUW@abcdefghiklmnopqrstuwx1%,]
+	if len(flag) == 2 && flag[0] == '-' {
+		cluster := regexp.MustCompile(`\[-[A-Za-z0-9@%,]*` + regexp.QuoteMeta(flag[1:]) + `[A-Za-z0-9@%,]*\]`)
+		return cluster.MatchString(doc)
+	}
+	return false
+}
+
+func isNumericFlag(flag string) bool {
+	digits := strings.TrimPrefix(flag, "-")
+	if digits == flag || digits == "" {
+		return false
+	}
+	for _, r := range digits {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func appendUnique(list []string, s string) []string {
+	for _, existing := range list {
+		if existing == s {
+			return list
+		}
+	}
+	return append(list, s)
+}
+
+// word is a shell token; quoted words are never treated as flags
+type word struct {
+	text   string
+	quoted bool
+	op     bool
+}
+
+// ParseInvocations splits a shell command line into the programs it runs.
+// onPath decides which argument of a wrapper such as sudo or xargs is the
+// program it runs.
+func ParseInvocations(line string, onPath func(string) bool) []Invocation {
+	var invocations []Invocation
+	for _, segment := range splitSegments(tokenize(line)) {
+		invocations = append(invocations, parseSegment(segment, onPath)...)
+	}
+	return invocations
+}
+
+func parseSegment(words []word, onPath func(string) bool) []Invocation {
+	// Skip keywords, variable assignments and redirections before the program
+	i := 0
+	for i < len(words) {
+		w := words[i]
+		if w.quoted || !(shellKeywords[w.text] || isAssignment(w.text) || isRedirect(w.text)) {
+			break
+		}
+		if isRedirect(w.text) {
+			i += redirectWidth(w.text)
+		} else {
+			i++
+		}
+	}
+	if i >= len(words) {
+		return nil
+	}
+
+	first := words[i].text
+	switch first {
+	case "for", "case", "done", "fi", "esac", "}", ")", "select", "function":
+		return nil
+	}
+
+	inv := Invocation{Program: first}
+	rest := words[i+1:]
+	positional := false
+
+	for j := 0; j < len(rest); j++ {
+		w := rest[j]
+		if w.quoted {
+			positional = true
+			continue
+		}
+		if w.text == "--" {
+			break
+		}
+		if isRedirect(w.text) {
+			j += redirectWidth(w.text) - 1
+			continue
+		}
+
+		if wrappers[first] && !strings.HasPrefix(w.text, "-") &&
+			!isAssignment(w.text) && !isNumericFlag("-"+w.text) && onPath(w.text) {
+			// The wrapped program starts here; the wrapper's own flags are
+			// not checked as they often take values
+			return append([]Invocation{{Program: first}}, parseSegment(rest[j:], onPath)...)
+		}
+
+		if isNumericFlag(w.text) {
+			// A negative number such as find -mtime -1, or head -5
+			continue
+		}
+		if strings.HasPrefix(w.text, "-") && w.text != "-" {
+			flag, _, _ := strings.Cut(w.text, "=")
+			inv.Flags = appendUnique(inv.Flags, flag)
+			continue
+		}
+		if !positional && subcommandPattern.MatchString(w.text) {
+			inv.Sub = w.text
+		}
+		positional = true
+	}
+
+	if wrappers[first] {
+		inv.Flags = nil
+	}
+	return []Invocation{inv}
+}
+
+var subcommandPattern = regexp.MustCompile(`^[a-z][a-z0-9-]*$`)
+
+// listsSubcommand reports whether documentation lists sub as a subcommand,
+// i.e. at the start of an indented line
+func listsSubcommand(doc, sub string) bool {
+	pattern := `(?m)^[ \t]{1,8}` + regexp.QuoteMeta(sub) + `([ \t,]|$)`
+	return regexp.MustCompile(pattern).MatchString(normalizeDoc(doc))
+}
+
+var assignmentPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*=`)
+
+func isAssignment(s string) bool {
+	return assignmentPattern.MatchString(s)
+}
+
+var redirectPattern = regexp.MustCompile(`^[0-9&]?(>>?|<<?)(&[0-9-])?`)
+
+func isRedirect(s string) bool {
+	return redirectPattern.MatchString(s)
+}
+
+// redirectWidth is 2 when the redirection target is the next word
+func redirectWidth(s string) int {
+	if redirectPattern.FindString(s) == s && !strings.Contains(s, "&") {
+		return 2
+	}
+	return 1
+}
+
+// splitSegments splits words at pipes and command separators
+func splitSegments(words []word) [][]word {
+	var segments [][]word
+	var current []word
+	for _, w := range words {
+		if w.op {
+			if len(current) > 0 {
+				segments = append(segments, current)
+			}
+			current = nil
+			continue
+		}
+		current = append(current, w)
+	}
+	if len(current) > 0 {
+		segments = append(segments, current)
+	}
+	return segments
+}
+
+// tokenize splits a command line into words, honouring quotes, escapes and
+// the operators | || & && ; and newlines. Command substitutions are kept
+// inside the word they appear in.
+func tokenize(line string) []word {
+	var words []word
+	var sb strings.Builder
+	inWord, quoted := false, false
+	depth := 0 // $( ... ) nesting
+
+	flush := func() {
+		if inWord {
+			words = append(words, word{text: sb.String(), quoted: quoted})
+		}
+		sb.Reset()
+		inWord, quoted = false, false
+	}
+
+	runes := []rune(line)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+
+		if depth > 0 {
+			sb.WriteRune(r)
+			switch {
+			case r == '(':
+				depth++
+			case r == ')':
+				depth--
+			}
+			continue
+		}
+
+		switch {
+		case r == '\\' && i+1 < len(runes):
+			i++
+			sb.WriteRune(runes[i])
+			inWord = true
+		case r == '\'' || r == '"':
+			end := i + 1
+			for end < len(runes) && runes[end] != r {
+				if r == '"' && runes[end] == '\\' && end+1 < len(runes) {
+					end++
+				}
+				end++
+			}
+			sb.WriteString(string(runes[i+1 : min(end, len(runes))]))
+			inWord, quoted = true, true
+			i = end
+		case r == '$' && i+1 < len(runes) && runes[i+1] == '(':
+			sb.WriteString("$(")
+			inWord = true
+			depth = 1
+			i++
+		case r == '|' || r == '&' || r == ';' || r == '\n':
+			// 2>&1 and &> are redirections, not operators
+			if r == '&' && inWord && strings.HasSuffix(sb.String(), ">") {
+				sb.WriteRune(r)
+				continue
+			}
+			if r == '&' && i+1 < len(runes) && runes[i+1] == '>' {
+				sb.WriteRune(r)
+				inWord = true
+				continue
+			}
+			flush()
+			if i+1 < len(runes) && (runes[i+1] == r) {
+				i++
+			}
+			words = append(words, word{text: string(r), op: true})
+		case r == ' ' || r == '\t':
+			flush()
+		case r == '(' || r == ')':
+			flush()
+			words = append(words, word{text: string(r)})
+		default:
+			sb.WriteRune(r)
+			inWord = true
+		}
+	}
+	flush()
+
+	return words
+}
@@ -0,0 +1,128 @@
+package manpage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func fakeValidator(installed map[string]string) *Validator {
+	return &Validator{
+		LookPath: func(file string) (string, error) {
+			if _, ok := installed[file]; ok {
+				return "/usr/bin/" + file, nil
+			}
+			return "", fmt.Errorf("%s: not found", file)
+		},
+		Sources: []func(program, sub string) string{
+			func(program, sub string) string {
+				if sub != "" {
+					return installed[program+"-"+sub]
+				}
+				return installed[program]
+			},
+		},
+	}
+}
+
+func TestParseInvocations(t *testing.T) {
+	onPath := func(s string) bool { return s == "rm" || s == "grep" }
+
+	invs := ParseInvocations(`FOO=1 find . -name "*.go" -mtime -1 2>/dev/null | xargs -0 rm -f && echo 'done -x'`, onPath)
+	if assert.Len(t, invs, 4) {
+		assert.Equal(t, Invocation{Program: "find", Flags: []string{"-name", "-mtime"}}, invs[0])
+		assert.Equal(t, Invocation{Program: "xargs"}, invs[1])
+		assert.Equal(t, Invocation{Program: "rm", Flags: []string{"-f"}}, invs[2])
+		assert.Equal(t, Invocation{Program: "echo"}, invs[3])
+	}
+
+	invs = ParseInvocations("git log --oneline -n5 > out.txt; sudo -u root grep -r TODO $(pwd)", onPath)
+	if assert.Len(t, invs, 3) {
+		assert.Equal(t, Invocation{Program: "git", Sub: "log", Flags: []string{"--oneline", "-n5"}}, invs[0])
+		assert.Equal(t, "sudo", invs[1].Program)
+		assert.Equal(t, Invocation{Program: "grep", Flags: []string{"-r"}}, invs[2])
+	}
+}
+
+func TestFlagDocumented(t *testing.T) {
+	gnu := `       -a, --all
+              do not ignore entries starting with .
+
+       -l     use a long listing format
+
+       --sort=WORD
+              sort by WORD instead of name`
+	bsd := `SYNOPSIS
+     ls [-ABCFGHLOPRSTUW@abcdefghiklmnopqrstuwx1%,] [file ...]`
+
+	assert.True(t, FlagDocumented(gnu, "-a"))
+	assert.True(t, FlagDocumented(gnu, "--all"))
+	assert.True(t, FlagDocumented(gnu, "--sort"))
+	assert.True(t, FlagDocumented(gnu, "-la"))
+	assert.True(t, FlagDocumented(gnu, "-5"))
+	assert.False(t, FlagDocumented(gnu, "--hidden"))
+	assert.False(t, FlagDocumented(gnu, "-lz"))
+	assert.False(t, FlagDocumented(gnu, "--al"))
+
+	assert.True(t, FlagDocumented(bsd, "-l"))
+	assert.True(t, FlagDocumented(bsd, "-lh"))
+	assert.False(t, FlagDocumented(bsd, "-Z"))
+
+	// man's overstrike bold
+	assert.True(t, FlagDocumented("-\b-v\bv\n    verbose", "-v"))
+}
+
+func TestValidator_Validate(t *testing.T) {
+	v := fakeValidator(map[string]string{
+		"ls":      "-l  long\n-a  all",
+		"git":     "usage: git <command>\n   log        Show commit logs\n   status     Show the working tree status\n",
+		"git-log": "--oneline  one line per commit",
+		"jq":      "",
+	})
+
+	check := v.Validate("ls -la | grep foo")
+	assert.False(t, check.Valid())
+	assert.Equal(t, []string{"grep"}, check.Missing)
+
+	check = v.Validate("ls --hidden -l")
+	assert.False(t, check.Valid())
+	assert.Equal(t, []string{"ls --hidden"}, check.UnknownFlags)
+	assert.Equal(t, []string{"ls has no --hidden option"}, check.Problems())
+
+	check = v.Validate("git log --oneline")
+	assert.True(t, check.Verified())
+
+	// Only listed subcommands are looked up
+	check = v.Validate("git build --oneline")
+	assert.Equal(t, []string{"git --oneline"}, check.UnknownFlags)
+
+	// A listed subcommand without documentation cannot be checked
+	check = v.Validate("git status --short")
+	assert.True(t, check.Valid())
+	assert.Equal(t, []string{"git"}, check.Undocumented)
+
+	// Without documentation flags cannot be checked
+	check = v.Validate("jq -r .name")
+	assert.True(t, check.Valid())
+	assert.False(t, check.Verified())
+	assert.Equal(t, []string{"jq"}, check.Undocumented)
+
+	check = v.Validate("cd /tmp && ls -l")
+	assert.True(t, check.Verified())
+}
+
+func TestHelpText_NeverRunsPaths(t *testing.T) {
+	dir := t.TempDir()
+	marker := filepath.Join(dir, "ran")
+	script := filepath.Join(dir, "deploy.sh")
+	require.NoError(t, os.WriteFile(script, []byte("#!/bin/sh\ntouch "+marker+"\n"), 0755))
+
+	check := NewValidator().Validate(script + " --prod")
+	assert.True(t, check.Valid(), "the script exists")
+	assert.Empty(t, HelpText(script, ""))
+	assert.NoFileExists(t, marker)
+}
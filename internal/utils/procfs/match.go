@@ -0,0 +1,112 @@
+package procfs
+
+import (
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Query selects processes. Every set field must match.
+type Query struct {
+	// Name matches the executable name or the base name of argv[0],
+	// case-insensitively, as a substring
+	Name string
+	// Pattern matches the full command line
+	Pattern *regexp.Regexp
+	// Port matches processes listening on a TCP port
+	Port int
+	// Tree matches a process and all of its descendants
+	Tree int
+}
+
+// Empty reports whether the query matches every process
+func (q Query) Empty() bool {
+	return q.Name == "" && q.Pattern == nil && q.Port == 0 && q.Tree == 0
+}
+
+// Match reports whether one process matches the name, pattern and port
+// criteria. Tree needs the whole process list; see Find.
+func (q Query) Match(p *Process) bool {
+	if q.Name != "" {
+		name := strings.ToLower(q.Name)
+		argv0 := ""
+		if len(p.Cmdline) > 0 {
+			argv0 = strings.ToLower(filepath.Base(p.Cmdline[0]))
+		}
+		if !strings.Contains(strings.ToLower(p.Name), name) && !strings.Contains(argv0, name) {
+			return false
+		}
+	}
+	if q.Pattern != nil && !q.Pattern.MatchString(p.CommandLine()) {
+		return false
+	}
+	if q.Port != 0 && !slices.Contains(p.Ports, q.Port) {
+		return false
+	}
+	return true
+}
+
+// Find lists the processes matching a query. Tree queries are returned in
+// tree order, parents before children; others by PID.
+func (fs *FS) Find(q Query) ([]*Process, error) {
+	procs, err := fs.List()
+	if err != nil {
+		return nil, err
+	}
+	if q.Port != 0 {
+		fs.AddPorts(procs)
+	}
+	if q.Tree != 0 {
+		procs = Descendants(procs, q.Tree)
+	}
+
+	var matched []*Process
+	for _, p := range procs {
+		if q.Match(p) {
+			matched = append(matched, p)
+		}
+	}
+	return matched, nil
+}
+
+// Descendants returns root and every process below it, depth first
+func Descendants(procs []*Process, root int) []*Process {
+	children := make(map[int][]*Process)
+	var top *Process
+	for _, p := range procs {
+		if p.PID == root {
+			top = p
+		} else {
+			children[p.PPID] = append(children[p.PPID], p)
+		}
+	}
+	if top == nil {
+		return nil
+	}
+
+	var out []*Process
+	var walk func(p *Process)
+	walk = func(p *Process) {
+		out = append(out, p)
+		for _, c := range children[p.PID] {
+			walk(c)
+		}
+	}
+	walk(top)
+	return out
+}
+
+// Depths returns how far below the first listed ancestor each process is,
+// for indenting tree views
+func Depths(procs []*Process) map[int]int {
+	depth := make(map[int]int, len(procs))
+	for _, p := range procs {
+		if d, ok := depth[p.PPID]; ok {
+			depth[p.PID] = d + 1
+		} else {
+			depth[p.PID] = 0
+		}
+	}
+	return depth
+}
@@ -0,0 +1,84 @@
+package procfs
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// tcpListen is the TCP_LISTEN state in /proc/net/tcp
+const tcpListen = "0A"
+
+// listeningSockets maps socket inodes to the TCP ports listening on them,
+// from /proc/net/tcp and /proc/net/tcp6
+func (fs *FS) listeningSockets() map[string]int {
+	sockets := make(map[string]int)
+	for _, name := range []string{"tcp", "tcp6"} {
+		data, err := os.ReadFile(filepath.Join(fs.Root, "net", name))
+		if err != nil {
+			continue
+		}
+		lines := strings.Split(string(data), "\n")
+		// The first line is a header:
+		//   sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+		for _, line := range lines[1:] {
+			fields := strings.Fields(line)
+			if len(fields) < 10 || fields[3] != tcpListen {
+				continue
+			}
+			i := strings.LastIndexByte(fields[1], ':')
+			if i < 0 {
+				continue
+			}
+			port, err := strconv.ParseUint(fields[1][i+1:], 16, 16)
+			if err != nil || fields[9] == "0" {
+				continue
+			}
+			sockets[fields[9]] = int(port)
+		}
+	}
+	return sockets
+}
+
+// socketInodes returns the inodes of the sockets a process has open. Only
+// processes we may inspect are visible; others return nothing.
+func (fs *FS) socketInodes(pid int) []string {
+	dir := filepath.Join(fs.Root, strconv.Itoa(pid), "fd")
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+
+	var inodes []string
+	for _, e := range entries {
+		target, err := os.Readlink(filepath.Join(dir, e.Name()))
+		if err != nil {
+			continue
+		}
+		if rest, ok := strings.CutPrefix(target, "socket:["); ok {
+			inodes = append(inodes, strings.TrimSuffix(rest, "]"))
+		}
+	}
+	return inodes
+}
+
+// AddPorts fills in the listening TCP ports of each process
+func (fs *FS) AddPorts(procs []*Process) {
+	sockets := fs.listeningSockets()
+	if len(sockets) == 0 {
+		return
+	}
+
+	for _, p := range procs {
+		seen := make(map[int]bool)
+		for _, inode := range fs.socketInodes(p.PID) {
+			if port, ok := sockets[inode]; ok && !seen[port] {
+				seen[port] = true
+				p.Ports = append(p.Ports, port)
+			}
+		}
+		sort.Ints(p.Ports)
+	}
+}
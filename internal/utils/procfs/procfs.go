@@ -0,0 +1,261 @@
+// Package procfs reads process information from a Linux /proc filesystem
+package procfs
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// clockTicks is USER_HZ, the unit of the times in /proc/[pid]/stat. It is
+// 100 on every mainstream Linux architecture.
+const clockTicks = 100
+
+// Process is a snapshot of one process
+type Process struct {
+	PID     int
+	PPID    int
+	Name    string // executable name from stat, at most 15 characters
+	Cmdline []string
+	State   string
+	UID     int
+	User    string
+	// Start is when the process started
+	Start time.Time
+	// StartTicks is the start time in clock ticks after boot, as in stat.
+	// With the PID, it tells the process apart from a later one that
+	// reuses the PID.
+	StartTicks uint64
+	// CPUPercent is CPU time over wall time since start, like ps
+	CPUPercent float64
+	// RSS is the resident set size in bytes
+	RSS        uint64
+	MemPercent float64
+	// Ports lists TCP ports the process is listening on, when requested
+	Ports []int
+}
+
+// CommandLine returns the full command line, or the name in brackets for
+// kernel threads and zombies that have none
+func (p *Process) CommandLine() string {
+	if len(p.Cmdline) == 0 {
+		return "[" + p.Name + "]"
+	}
+	return strings.Join(p.Cmdline, " ")
+}
+
+// FS is a procfs mounted at Root
+type FS struct {
+	Root string
+	// PageSize converts RSS pages to bytes
+	PageSize int
+}
+
+// Default is the system procfs
+var Default = New("/proc")
+
+// New returns a procfs rooted at root
+func New(root string) *FS {
+	return &FS{Root: root, PageSize: os.Getpagesize()}
+}
+
+// Available reports whether the procfs can be read
+func (fs *FS) Available() bool {
+	_, err := os.Stat(filepath.Join(fs.Root, "stat"))
+	return err == nil
+}
+
+// system holds the machine-wide values needed to compute per-process stats
+type system struct {
+	boot     time.Time
+	uptime   float64
+	memTotal uint64
+}
+
+func (fs *FS) system() (*system, error) {
+	sys := &system{}
+
+	data, err := os.ReadFile(filepath.Join(fs.Root, "stat"))
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", filepath.Join(fs.Root, "stat"), err)
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if rest, ok := strings.CutPrefix(line, "btime "); ok {
+			secs, _ := strconv.ParseInt(strings.TrimSpace(rest), 10, 64)
+			sys.boot = time.Unix(secs, 0)
+		}
+	}
+
+	if data, err := os.ReadFile(filepath.Join(fs.Root, "uptime")); err == nil {
+		if fields := strings.Fields(string(data)); len(fields) > 0 {
+			sys.uptime, _ = strconv.ParseFloat(fields[0], 64)
+		}
+	}
+
+	if data, err := os.ReadFile(filepath.Join(fs.Root, "meminfo")); err == nil {
+		for _, line := range strings.Split(string(data), "\n") {
+			if rest, ok := strings.CutPrefix(line, "MemTotal:"); ok {
+				kb, _ := strconv.ParseUint(strings.TrimSuffix(strings.TrimSpace(rest), " kB"), 10, 64)
+				sys.memTotal = kb * 1024
+			}
+		}
+	}
+
+	return sys, nil
+}
+
+// List returns every process, ordered by PID. Processes that exit while
+// being read are skipped.
+func (fs *FS) List() ([]*Process, error) {
+	sys, err := fs.system()
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(fs.Root)
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", fs.Root, err)
+	}
+
+	var procs []*Process
+	for _, e := range entries {
+		pid, err := strconv.Atoi(e.Name())
+		if err != nil || !e.IsDir() {
+			continue
+		}
+		p, err := fs.read(pid, sys)
+		if err != nil {
+			continue
+		}
+		procs = append(procs, p)
+	}
+
+	sort.Slice(procs, func(i, j int) bool { return procs[i].PID < procs[j].PID })
+	return procs, nil
+}
+
+// Get returns one process
+func (fs *FS) Get(pid int) (*Process, error) {
+	sys, err := fs.system()
+	if err != nil {
+		return nil, err
+	}
+	return fs.read(pid, sys)
+}
+
+// Alive reports whether a process exists and is not a zombie
+func (fs *FS) Alive(pid int) bool {
+	data, err := os.ReadFile(filepath.Join(fs.Root, strconv.Itoa(pid), "stat"))
+	if err != nil {
+		return false
+	}
+	_, rest, err := splitStat(data)
+	return err == nil && len(rest) > 0 && rest[0] != "Z"
+}
+
+// Running reports whether p is still running: its PID is alive and was
+// started at the same time, so it is not a new process reusing the PID
+func (fs *FS) Running(p *Process) bool {
+	data, err := os.ReadFile(filepath.Join(fs.Root, strconv.Itoa(p.PID), "stat"))
+	if err != nil {
+		return false
+	}
+	_, rest, err := splitStat(data)
+	if err != nil || len(rest) < 20 || rest[0] == "Z" {
+		return false
+	}
+	return rest[19] == strconv.FormatUint(p.StartTicks, 10)
+}
+
+func (fs *FS) read(pid int, sys *system) (*Process, error) {
+	dir := filepath.Join(fs.Root, strconv.Itoa(pid))
+
+	data, err := os.ReadFile(filepath.Join(dir, "stat"))
+	if err != nil {
+		return nil, err
+	}
+	name, rest, err := splitStat(data)
+	if err != nil {
+		return nil, fmt.Errorf("pid %d: %w", pid, err)
+	}
+	// rest starts at field 3 (state); see proc(5)
+	if len(rest) < 22 {
+		return nil, fmt.Errorf("pid %d: short stat", pid)
+	}
+
+	p := &Process{PID: pid, Name: name, State: rest[0]}
+	p.PPID, _ = strconv.Atoi(rest[1])
+
+	utime, _ := strconv.ParseFloat(rest[11], 64)
+	stime, _ := strconv.ParseFloat(rest[12], 64)
+	p.StartTicks, _ = strconv.ParseUint(rest[19], 10, 64)
+	rssPages, _ := strconv.ParseUint(rest[21], 10, 64)
+
+	started := float64(p.StartTicks) / clockTicks
+	p.Start = sys.boot.Add(time.Duration(started * float64(time.Second)))
+	if elapsed := sys.uptime - started; elapsed > 0 {
+		p.CPUPercent = (utime + stime) / clockTicks / elapsed * 100
+	}
+
+	p.RSS = rssPages * uint64(fs.PageSize)
+	if sys.memTotal > 0 {
+		p.MemPercent = float64(p.RSS) / float64(sys.memTotal) * 100
+	}
+
+	if cmdline, err := os.ReadFile(filepath.Join(dir, "cmdline")); err == nil {
+		cmdline = bytes.TrimRight(cmdline, "\x00")
+		if len(cmdline) > 0 {
+			p.Cmdline = strings.Split(string(cmdline), "\x00")
+		}
+	}
+
+	p.UID = -1
+	if status, err := os.ReadFile(filepath.Join(dir, "status")); err == nil {
+		for _, line := range strings.Split(string(status), "\n") {
+			if rest, ok := strings.CutPrefix(line, "Uid:"); ok {
+				if fields := strings.Fields(rest); len(fields) > 0 {
+					p.UID, _ = strconv.Atoi(fields[0])
+				}
+			}
+		}
+	}
+	p.User = userName(p.UID)
+
+	return p, nil
+}
+
+// splitStat separates the command name, which may contain spaces and
+// parentheses, from the remaining stat fields
+func splitStat(data []byte) (string, []string, error) {
+	open := bytes.IndexByte(data, '(')
+	close := bytes.LastIndexByte(data, ')')
+	if open < 0 || close < open {
+		return "", nil, fmt.Errorf("malformed stat")
+	}
+	return string(data[open+1 : close]), strings.Fields(string(data[close+1:])), nil
+}
+
+var userNames sync.Map
+
+// userName resolves a uid, falling back to the number
+func userName(uid int) string {
+	if uid < 0 {
+		return "?"
+	}
+	if name, ok := userNames.Load(uid); ok {
+		return name.(string)
+	}
+	name := strconv.Itoa(uid)
+	if u, err := user.LookupId(name); err == nil {
+		name = u.Username
+	}
+	userNames.Store(uid, name)
+	return name
+}
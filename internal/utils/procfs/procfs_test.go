@@ -0,0 +1,177 @@
+package procfs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeProc describes one process in a fake procfs
+type fakeProc struct {
+	pid, ppid   int
+	comm        string
+	cmdline     []string
+	uid         int
+	state       string
+	utime       int // clock ticks
+	startTicks  int
+	rssPages    int
+	socketInode []string
+}
+
+// fakeRoot builds a procfs under a temp dir. The machine booted at
+// 1_700_000_000, has been up 1000s and has 1 GiB of memory.
+func fakeRoot(t *testing.T, procs ...fakeProc) *FS {
+	root := t.TempDir()
+	write := func(name, content string) {
+		path := filepath.Join(root, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+
+	write("stat", "cpu  1 2 3 4\nbtime 1700000000\nprocesses 42\n")
+	write("uptime", "1000.00 3000.00\n")
+	write("meminfo", "MemTotal:        1048576 kB\nMemFree:          524288 kB\n")
+
+	for _, p := range procs {
+		dir := strconv.Itoa(p.pid)
+		state := p.state
+		if state == "" {
+			state = "S"
+		}
+		// Fields 3 onwards; utime is field 14, starttime 22 and rss 24
+		fields := make([]string, 22)
+		for i := range fields {
+			fields[i] = "0"
+		}
+		fields[0] = state
+		fields[1] = strconv.Itoa(p.ppid)
+		fields[11] = strconv.Itoa(p.utime)
+		fields[19] = strconv.Itoa(p.startTicks)
+		fields[21] = strconv.Itoa(p.rssPages)
+		write(dir+"/stat", fmt.Sprintf("%d (%s) %s\n", p.pid, p.comm, strings.Join(fields, " ")))
+		write(dir+"/status", fmt.Sprintf("Name:\t%s\nUid:\t%d\t%d\t%d\t%d\n", p.comm, p.uid, p.uid, p.uid, p.uid))
+
+		cmdline := ""
+		if len(p.cmdline) > 0 {
+			cmdline = strings.Join(p.cmdline, "\x00") + "\x00"
+		}
+		write(dir+"/cmdline", cmdline)
+
+		require.NoError(t, os.MkdirAll(filepath.Join(root, dir, "fd"), 0755))
+		for i, inode := range p.socketInode {
+			require.NoError(t, os.Symlink("socket:["+inode+"]", filepath.Join(root, dir, "fd", strconv.Itoa(i+3))))
+		}
+	}
+
+	fs := New(root)
+	fs.PageSize = 4096
+	return fs
+}
+
+func TestFS_List(t *testing.T) {
+	fs := fakeRoot(t,
+		fakeProc{pid: 1, comm: "init", cmdline: []string{"/sbin/init"}},
+		fakeProc{pid: 42, ppid: 1, comm: "my (odd) prog", cmdline: []string{"/usr/bin/prog", "--serve"},
+			utime: 5000, startTicks: 50000, rssPages: 25600},
+		fakeProc{pid: 7, ppid: 2, comm: "kworker/0:1"},
+	)
+	// Not a process
+	require.NoError(t, os.MkdirAll(filepath.Join(fs.Root, "net"), 0755))
+
+	procs, err := fs.List()
+	require.NoError(t, err)
+	require.Len(t, procs, 3)
+	assert.Equal(t, []int{1, 7, 42}, []int{procs[0].PID, procs[1].PID, procs[2].PID})
+
+	p := procs[2]
+	assert.Equal(t, "my (odd) prog", p.Name)
+	assert.Equal(t, 1, p.PPID)
+	assert.Equal(t, "/usr/bin/prog --serve", p.CommandLine())
+	assert.Equal(t, 0, p.UID)
+	assert.Equal(t, time.Unix(1700000500, 0), p.Start)
+	// 50s of CPU over 500s since start
+	assert.InDelta(t, 10.0, p.CPUPercent, 0.001)
+	// 100 MiB of 1 GiB
+	assert.Equal(t, uint64(100*1024*1024), p.RSS)
+	assert.InDelta(t, 9.765625, p.MemPercent, 0.001)
+
+	assert.Equal(t, "[kworker/0:1]", procs[1].CommandLine())
+}
+
+func TestFS_Alive(t *testing.T) {
+	fs := fakeRoot(t,
+		fakeProc{pid: 10, comm: "running"},
+		fakeProc{pid: 11, comm: "zombie", state: "Z"},
+	)
+	assert.True(t, fs.Alive(10))
+	assert.False(t, fs.Alive(11))
+	assert.False(t, fs.Alive(12))
+}
+
+func TestFS_Running(t *testing.T) {
+	fs := fakeRoot(t,
+		fakeProc{pid: 10, comm: "running", startTicks: 500},
+		fakeProc{pid: 11, comm: "zombie", state: "Z", startTicks: 500},
+	)
+	assert.True(t, fs.Running(&Process{PID: 10, StartTicks: 500}))
+	// Same PID, later start: the PID was reused by another process
+	assert.False(t, fs.Running(&Process{PID: 10, StartTicks: 400}))
+	assert.False(t, fs.Running(&Process{PID: 11, StartTicks: 500}))
+	assert.False(t, fs.Running(&Process{PID: 12}))
+
+	procs, err := fs.List()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(500), procs[0].StartTicks)
+}
+
+func TestFS_Find(t *testing.T) {
+	fs := fakeRoot(t,
+		fakeProc{pid: 1, comm: "init", cmdline: []string{"/sbin/init"}},
+		fakeProc{pid: 100, ppid: 1, comm: "node", cmdline: []string{"node", "server.js"}, socketInode: []string{"5001", "9999"}},
+		fakeProc{pid: 101, ppid: 100, comm: "node", cmdline: []string{"node", "worker.js"}},
+		fakeProc{pid: 102, ppid: 101, comm: "sh", cmdline: []string{"sh", "-c", "sleep 100"}},
+		fakeProc{pid: 200, ppid: 1, comm: "python3", cmdline: []string{"/usr/bin/python3", "manage.py", "runserver"}, socketInode: []string{"5002"}},
+	)
+	require.NoError(t, os.MkdirAll(filepath.Join(fs.Root, "net"), 0755))
+	header := "  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode\n"
+	require.NoError(t, os.WriteFile(filepath.Join(fs.Root, "net", "tcp"), []byte(header+
+		"   0: 0100007F:0BB8 00000000:0000 0A 00000000:00000000 00:00000000 00000000  1000        0 5001 1 0 100 0 0 10 0\n"+
+		// An established connection on the same inode space is not listening
+		"   1: 0100007F:D431 0100007F:0BB8 01 00000000:00000000 00:00000000 00000000  1000        0 9999 1 0 20 4 30 10 -1\n"),
+		0644))
+	require.NoError(t, os.WriteFile(filepath.Join(fs.Root, "net", "tcp6"), []byte(header+
+		"   0: 00000000000000000000000000000000:1F40 00000000000000000000000000000000:0000 0A 00000000:00000000 00:00000000 00000000  1000        0 5002 1 0 100 0 0 10 0\n"),
+		0644))
+
+	pids := func(q Query) []int {
+		procs, err := fs.Find(q)
+		require.NoError(t, err)
+		var out []int
+		for _, p := range procs {
+			out = append(out, p.PID)
+		}
+		return out
+	}
+
+	assert.Equal(t, []int{100, 101}, pids(Query{Name: "NODE"}))
+	assert.Equal(t, []int{200}, pids(Query{Pattern: regexp.MustCompile(`manage\.py runserver`)}))
+	assert.Equal(t, []int{100}, pids(Query{Port: 3000}))
+	assert.Equal(t, []int{200}, pids(Query{Port: 8000}))
+	assert.Empty(t, pids(Query{Port: 54321}))
+	assert.Equal(t, []int{100, 101, 102}, pids(Query{Tree: 100}))
+	assert.Equal(t, []int{100, 101}, pids(Query{Tree: 100, Name: "node"}))
+	assert.Empty(t, pids(Query{Tree: 999}))
+
+	procs, err := fs.Find(Query{Tree: 100})
+	require.NoError(t, err)
+	assert.Equal(t, map[int]int{100: 0, 101: 1, 102: 2}, Depths(procs))
+}
@@ -128,6 +128,60 @@ func ValidateRepoURL(urlStr string) error {
 	return nil
 }
 
+// ValidateRepoSource validates where a repository is fetched from:
+// - http(s) URLs, validated with ValidateRepoURL
+// - file:// URLs of absolute local directories
+// - git+https, git+http, git+ssh and git+file URLs, with an optional #ref
+//
+// git+http(s) URLs get the same SSRF protection as http(s) URLs. Refs may not
+// start with '-', so they can't be taken for git options.
+func ValidateRepoSource(src string) error {
+	scheme, _, ok := strings.Cut(src, "://")
+	if !ok {
+		return ValidateRepoURL(src)
+	}
+
+	switch strings.ToLower(scheme) {
+	case "file":
+		u, err := url.Parse(src)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidURL, err)
+		}
+		if u.Host != "" && u.Host != "localhost" {
+			return fmt.Errorf("%w: file URLs must name a local directory", ErrInvalidURL)
+		}
+		if !strings.HasPrefix(u.Path, "/") || strings.Contains(u.Path, "..") {
+			return fmt.Errorf("%w: file URLs need an absolute path", ErrInvalidURL)
+		}
+		return nil
+
+	case "git+https", "git+http", "git+ssh", "git+file":
+		remote, ref, _ := strings.Cut(src[len("git+"):], "#")
+		if strings.HasPrefix(ref, "-") || strings.ContainsAny(ref, " \t\n\x00") {
+			return fmt.Errorf("%w: invalid git ref '%s'", ErrInvalidURL, ref)
+		}
+		u, err := url.Parse(remote)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidURL, err)
+		}
+		switch u.Scheme {
+		case "http", "https":
+			return ValidateRepoURL(remote)
+		case "ssh":
+			if u.Hostname() == "" || strings.HasPrefix(u.Hostname(), "-") {
+				return fmt.Errorf("%w: missing hostname", ErrInvalidURL)
+			}
+		case "file":
+			if !strings.HasPrefix(u.Path, "/") {
+				return fmt.Errorf("%w: file URLs need an absolute path", ErrInvalidURL)
+			}
+		}
+		return nil
+	}
+
+	return ValidateRepoURL(src)
+}
+
 // isLocalhost checks if the hostname is localhost
 func isLocalhost(hostname string) bool {
 	hostname = strings.ToLower(hostname)
@@ -112,6 +112,38 @@ func TestValidateRepoURL(t *testing.T) {
 	}
 }
 
+func TestValidateRepoSource(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		wantErr bool
+	}{
+		{"https", "https://example.com/repo", false},
+		{"local directory", "file:///home/me/commands", false},
+		{"git https", "git+https://github.com/team/commands.git#v1.2.0", false},
+		{"git ssh", "git+ssh://git@github.com/team/commands.git", false},
+		{"git file", "git+file:///srv/git/commands.git#main", false},
+
+		{"http localhost", "http://localhost:8080", true},
+		{"relative file", "file://commands", true},
+		{"remote file host", "file://server/share", true},
+		{"file traversal", "file:///home/me/../../etc", true},
+		{"git https localhost", "git+https://127.0.0.1/repo.git", true},
+		{"git option ref", "git+https://github.com/team/commands.git#--upload-pack=x", true},
+		{"git ext", "git+ext::sh -c x", true},
+		{"ftp", "ftp://example.com", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateRepoSource(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateRepoSource(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+		})
+	}
+}
+
 func TestValidateAliases(t *testing.T) {
 	tests := []struct {
 		name    string
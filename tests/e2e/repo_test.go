@@ -203,7 +203,7 @@ func TestCLIRepoCommands(t *testing.T) {
 
 	// Build the binary
 	binary := filepath.Join(t.TempDir(), "scmd")
-	buildCmd := exec.Command("go", "build", "-o", binary, "../../cmd/scmd")
+	buildCmd := exec.Command("go", "build", "-o", binary, "./cmd/scmd")
 	buildCmd.Dir = filepath.Join("..", "..")
 	err := buildCmd.Run()
 	if err != nil {
@@ -229,7 +229,9 @@ commands:
   - name: test-cmd
     description: Test
     file: test.yaml`))
+			return
 		}
+		http.NotFound(w, r)
 	}))
 	defer testServer.Close()
 
@@ -269,6 +271,44 @@ commands:
 	assert.NotContains(t, string(output), "mytest")
 }
 
+// TestCLIRepoAdd_BadPublishedKey tests that a repository whose published key
+// can't be parsed is not added unsigned
+func TestCLIRepoAdd_BadPublishedKey(t *testing.T) {
+	t.Setenv("SCMD_ALLOW_LOCALHOST", "1")
+
+	binary := filepath.Join(t.TempDir(), "scmd")
+	buildCmd := exec.Command("go", "build", "-o", binary, "./cmd/scmd")
+	buildCmd.Dir = filepath.Join("..", "..")
+	if err := buildCmd.Run(); err != nil {
+		t.Skip("Could not build binary:", err)
+	}
+	env := append(os.Environ(), "HOME="+t.TempDir())
+
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/scmd-repo.yaml":
+			w.Write([]byte("name: test\ncommands: []\n"))
+		case "/scmd-repo.pub":
+			w.Write([]byte("not a key"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer testServer.Close()
+
+	cmd := exec.Command(binary, "repo", "add", "broken", testServer.URL, "--yes")
+	cmd.Env = env
+	output, err := cmd.CombinedOutput()
+	require.Error(t, err, string(output))
+	assert.Contains(t, string(output), "repository 'broken' was not added")
+
+	cmd = exec.Command(binary, "repo", "list")
+	cmd.Env = env
+	output, err = cmd.CombinedOutput()
+	require.NoError(t, err)
+	assert.NotContains(t, string(output), "broken")
+}
+
 // TestRepoInstallAndRun tests installing and running a command from repo
 func TestRepoInstallAndRun(t *testing.T) {
 	// Allow localhost URLs for test server
@@ -276,7 +316,7 @@ func TestRepoInstallAndRun(t *testing.T) {
 
 	// Build the binary
 	binary := filepath.Join(t.TempDir(), "scmd")
-	buildCmd := exec.Command("go", "build", "-o", binary, "../../cmd/scmd")
+	buildCmd := exec.Command("go", "build", "-o", binary, "./cmd/scmd")
 	buildCmd.Dir = filepath.Join("..", "..")
 	err := buildCmd.Run()
 	if err != nil {
@@ -306,7 +346,7 @@ func TestRepoInstallAndRun(t *testing.T) {
 	assert.Contains(t, string(output), "git-commit")
 
 	// Install git-commit command
-	cmd = exec.Command(binary, "repo", "install", "sample/git-commit")
+	cmd = exec.Command(binary, "repo", "install", "sample/git-commit", "--yes")
 	cmd.Env = env
 	output, err = cmd.CombinedOutput()
 	require.NoError(t, err, "install failed: %s", string(output))
@@ -339,7 +379,7 @@ func TestRepoShowCommand(t *testing.T) {
 
 	// Build the binary
 	binary := filepath.Join(t.TempDir(), "scmd")
-	buildCmd := exec.Command("go", "build", "-o", binary, "../../cmd/scmd")
+	buildCmd := exec.Command("go", "build", "-o", binary, "./cmd/scmd")
 	buildCmd.Dir = filepath.Join("..", "..")
 	err := buildCmd.Run()
 	if err != nil {
@@ -380,7 +420,7 @@ func TestRepoUpdateCommand(t *testing.T) {
 
 	// Build the binary
 	binary := filepath.Join(t.TempDir(), "scmd")
-	buildCmd := exec.Command("go", "build", "-o", binary, "../../cmd/scmd")
+	buildCmd := exec.Command("go", "build", "-o", binary, "./cmd/scmd")
 	buildCmd.Dir = filepath.Join("..", "..")
 	err := buildCmd.Run()
 	if err != nil {
@@ -398,7 +438,9 @@ commands:
   - name: cmd1
     description: Command 1
     file: cmd1.yaml`))
+			return
 		}
+		http.NotFound(w, r)
 	}))
 	defer testServer.Close()
 
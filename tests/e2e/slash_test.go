@@ -494,6 +494,68 @@ func TestSlashRunner_GenerateShellIntegration_Fish(t *testing.T) {
 	}
 }
 
+func TestSlashRunner_ShellIntegration_History(t *testing.T) {
+	tmpDir := t.TempDir()
+	registry := command.NewRegistry()
+	repoMgr := repos.NewManager(tmpDir)
+
+	runner := slash.NewRunner(tmpDir, registry, repoMgr)
+	runner.LoadConfig()
+
+	if strings.Contains(runner.GenerateShellIntegration("bash"), slash.HistoryFileEnv) {
+		t.Error("history hook should be opt-in")
+	}
+
+	script := runner.ShellIntegration("bash", true)
+	if !strings.Contains(script, slash.HistoryFileEnv) {
+		t.Error("should export the history file")
+	}
+	if !strings.Contains(script, "history -s") || !strings.Contains(script, "print -rs") {
+		t.Error("should add commands to bash and zsh history")
+	}
+
+	if !strings.Contains(script, "mktemp") || strings.Contains(script, "$$") {
+		t.Error("should create the history file with mktemp, not at a predictable path")
+	}
+
+	fish := runner.ShellIntegration("fish", true)
+	if !strings.Contains(fish, "history append") {
+		t.Error("should add commands to fish history")
+	}
+	if !strings.Contains(fish, "mktemp") || strings.Contains(fish, "$fish_pid") {
+		t.Error("should create the fish history file with mktemp, not at a predictable path")
+	}
+}
+
+func TestSlash_ParseArgs(t *testing.T) {
+	// A query that mentions a flag keeps it
+	args := slash.ParseArgs(strings.Fields("--run how do I use git commit --amend"), []string{"first", "run", "interactive"})
+	if !args.HasFlag("run") {
+		t.Error("should parse the declared --run flag")
+	}
+	if got := strings.Join(args.Positional, " "); got != "how do I use git commit --amend" {
+		t.Errorf("positional = %q, want the whole question", got)
+	}
+	if args.HasFlag("amend") {
+		t.Error("should not parse undeclared --amend")
+	}
+
+	// Declared names match with dashes or underscores
+	args = slash.ParseArgs([]string{"german", "--max-words=10", "--", "--run"}, []string{"max_words", "run"})
+	if args.Options["max-words"] != "10" {
+		t.Errorf("options = %v, want max-words=10", args.Options)
+	}
+	if got := strings.Join(args.Positional, " "); got != "german --run" {
+		t.Errorf("positional = %q, want everything after -- kept", got)
+	}
+
+	// Commands that declare no flags get every argument
+	args = slash.ParseArgs([]string{"--verbose", "text"}, nil)
+	if len(args.Flags) != 0 || len(args.Positional) != 2 {
+		t.Errorf("args = %+v, want all positional", args)
+	}
+}
+
 // ==================== SLASH COMMAND EXECUTION ====================
 
 func TestSlashRunner_Run_WithMockBackend(t *testing.T) {